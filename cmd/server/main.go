@@ -2,18 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 	"github.com/Capstone-E1/aquasmart_backend/config"
 	"github.com/Capstone-E1/aquasmart_backend/internal/database"
 	httphandlers "github.com/Capstone-E1/aquasmart_backend/internal/http"
 	"github.com/Capstone-E1/aquasmart_backend/internal/ml"
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 	"github.com/Capstone-E1/aquasmart_backend/internal/mqtt"
 	"github.com/Capstone-E1/aquasmart_backend/internal/services"
 	"github.com/Capstone-E1/aquasmart_backend/internal/store"
@@ -35,31 +39,93 @@ func main() {
 	log.Printf("📋 Loaded configuration: Server port=%s, DB host=%s", 
 		cfg.Server.Port, cfg.Database.Host)
 
-	// Initialize data store with Aiven database or fallback to in-memory
-	var dataStore store.DataStore
-	
-	db, err := database.Connect(cfg.Database)
+	// Water quality index weights
+	wqiWeights := models.WQIWeights{
+		Ph:        cfg.Quality.WQIWeightPh,
+		Turbidity: cfg.Quality.WQIWeightTurbidity,
+		TDS:       cfg.Quality.WQIWeightTDS,
+	}
+	if err := models.SetWQIWeights(wqiWeights); err != nil {
+		log.Printf("⚠️  Warning: Invalid WQI weights (%v), using defaults", err)
+	}
+
+	// Water quality assessment thresholds, with filter mode overrides
+	thresholdOverrides := map[models.FilterMode]models.QualityThresholds{
+		models.FilterModeDrinking:  qualityThresholds(cfg.Quality.DrinkingThresholds),
+		models.FilterModeHousehold: qualityThresholds(cfg.Quality.HouseholdThresholds),
+	}
+	if err := models.SetQualityThresholds(qualityThresholds(cfg.Quality.Thresholds), thresholdOverrides); err != nil {
+		log.Printf("⚠️  Warning: Invalid quality thresholds (%v), using defaults", err)
+	} else if version := models.QualityRulesVersion(); version != models.BuiltinQualityRulesVersion {
+		log.Printf("🧪 Custom quality thresholds in effect (rules %s)", version)
+	}
+
+	flowUnit := models.FlowUnit(cfg.Units.Flow)
+	if !flowUnit.IsValid() {
+		log.Printf("⚠️  Warning: Unknown UNITS_FLOW %q, using %q", cfg.Units.Flow, models.FlowUnitLitersPerMinute)
+		flowUnit = models.FlowUnitLitersPerMinute
+	}
+
+	// Initialize data store according to STORE_BACKEND (Aiven database, in-memory, or auto fallback)
+	dataStore, storageMode, err := openDataStore(cfg, database.Connect)
 	if err != nil {
-		log.Printf("⚠️  Warning: Failed to connect to database: %v", err)
-		log.Println("📱 Falling back to in-memory storage")
-		// Fallback to in-memory store
-		dataStore = store.NewStore(1000)
-		log.Println("💾 Initialized in-memory data store")
-	} else {
-		log.Println("✅ Connected to Aiven PostgreSQL database")
-		
-		// Run migrations from migrations/ directory
-		if err := database.RunMigrations(db.DB); err != nil {
-			log.Fatalf("❌ Failed to run migrations: %v", err)
+		log.Fatalf("❌ Failed to initialize data store: %v", err)
+	}
+
+	// Fail fast while the database is unreachable instead of waiting on doomed queries
+	var storeBreaker *store.CircuitBreaker
+	if _, ok := dataStore.(*database.DatabaseStore); ok && cfg.Database.BreakerThreshold > 0 {
+		storeBreaker = store.NewCircuitBreaker(cfg.Database.BreakerThreshold, cfg.Database.BreakerCooldown)
+		dataStore = store.NewCircuitBreakerStore(dataStore, storeBreaker, database.IsUnavailable)
+		log.Printf("🔌 Database circuit breaker enabled (threshold=%d, cooldown=%s)",
+			cfg.Database.BreakerThreshold, cfg.Database.BreakerCooldown)
+	}
+
+	// Seed synthetic readings into an empty store for demos (off by default)
+	if cfg.Storage.SeedDemoData {
+		store.SeedDemoData(dataStore, cfg.Storage.SeedDemoDays, cfg.Storage.SeedDemoInterval)
+	}
+
+	// Prune readings, anomalies and predictions past the retention period (off by default)
+	if cfg.Storage.RetentionDays < 0 {
+		log.Printf("⚠️  Warning: Negative DATA_RETENTION_DAYS %d, keeping all data", cfg.Storage.RetentionDays)
+	}
+	retention := services.NewRetention(dataStore, cfg.Storage.RetentionDays, cfg.Storage.RetentionInterval, cfg.Storage.RetentionBatchSize)
+	retention.Start()
+	defer retention.Stop()
+
+	// Re-assess stored readings whose quality predates the current quality rules
+	go func() {
+		updated, err := dataStore.RecomputeReadingQuality()
+		if err != nil {
+			log.Printf("⚠️  Warning: Failed to backfill reading quality: %v", err)
+		} else if updated > 0 {
+			log.Printf("🧮 Backfilled quality for %d readings (rules %s)", updated, models.QualityRulesVersion())
 		}
-		
-		// Use database store
-		dataStore = database.NewDatabaseStore(db.DB)
-		log.Println("💾 Initialized database data store with Aiven PostgreSQL")
+	}()
+
+	// Initialize optional ingestion de-duplication (off by default)
+	deduplicator := store.NewReadingDeduplicator(dataStore, cfg.Ingestion.DedupEnabled, cfg.Ingestion.DedupWindow)
+	if deduplicator.IsEnabled() {
+		log.Printf("♻️  Ingestion de-duplication enabled (window=%s)", cfg.Ingestion.DedupWindow)
+	}
+
+	// Initialize optional device clock-skew handling (off by default)
+	skewPolicy := store.ClockSkewPolicy(cfg.Ingestion.ClockSkewPolicy)
+	if !skewPolicy.IsValid() {
+		log.Printf("⚠️  Warning: Unknown INGEST_CLOCK_SKEW_POLICY %q, using %q", cfg.Ingestion.ClockSkewPolicy, store.ClockSkewClamp)
+		skewPolicy = store.ClockSkewClamp
+	}
+	clockSkew := store.NewClockSkewCorrector(cfg.Ingestion.MaxClockSkew, skewPolicy)
+	if clockSkew.IsEnabled() {
+		log.Printf("⏱️  Clock-skew handling enabled (max=%s, policy=%s)", cfg.Ingestion.MaxClockSkew, skewPolicy)
 	}
 
 	// Initialize WebSocket hub
 	wsHub := ws.NewHub()
+	wsHub.SetFiltrationProcessProvider(dataStore)
+	wsHub.SetCompression(cfg.Server.WSCompression)
+	wsHub.SetPingInterval(cfg.Server.WSPingInterval)
 	go wsHub.Run()
 	log.Println("🔌 Started WebSocket hub")
 
@@ -71,7 +137,16 @@ func main() {
 			"sensor_data":    cfg.MQTT.TopicSensorData,
 			"filter_command": cfg.MQTT.TopicFilterCommand,
 		}
+		for name, topic := range mqttTopics {
+			if _, err := mqtt.ParseTopicTemplate(topic); err != nil {
+				log.Fatalf("❌ Invalid MQTT %s topic: %v", name, err)
+			}
+		}
 		
+		if cfg.MQTT.InsecureSkipVerify && mqtt.IsTLSBroker(cfg.MQTT.BrokerURL) {
+			log.Println("⚠️  Warning: MQTT_INSECURE_SKIP_VERIFY is set, the broker certificate is not verified")
+		}
+
 		client, err := mqtt.NewClient(
 			cfg.MQTT.BrokerURL,
 			cfg.MQTT.ClientID,
@@ -79,6 +154,12 @@ func main() {
 			cfg.MQTT.Password,
 			dataStore,
 			mqttTopics,
+			mqtt.TLSOptions{
+				CACertFile:         cfg.MQTT.CACert,
+				ClientCertFile:     cfg.MQTT.ClientCert,
+				ClientKeyFile:      cfg.MQTT.ClientKey,
+				InsecureSkipVerify: cfg.MQTT.InsecureSkipVerify,
+			},
 		)
 		if err != nil {
 			log.Printf("⚠️  Warning: Failed to connect to MQTT broker: %v", err)
@@ -87,6 +168,8 @@ func main() {
 		} else {
 			log.Printf("📡 MQTT client connected - Broker: %s", cfg.MQTT.BrokerURL)
 			mqttClient = client
+			mqttClient.SetDeduplicator(deduplicator)
+			mqttClient.SetClockSkewCorrector(clockSkew)
 			defer mqttClient.Disconnect()
 		}
 	} else {
@@ -95,18 +178,97 @@ func main() {
 	}
 
 	// Initialize and start scheduler
+	// Minimum interval between filter mode changes, shared by every source of changes
+	modeCooldown := store.NewModeChangeCooldown(cfg.Filter.ModeChangeMinInterval)
+	if modeCooldown.IsEnabled() {
+		log.Printf("⏱️  Filter mode changes limited to one per %s per device", cfg.Filter.ModeChangeMinInterval)
+	}
+
 	scheduler := services.NewScheduler(dataStore, mqttClient)
+	scheduler.SetModeChangeCooldown(modeCooldown)
+	if cfg.AutoMode.Enabled {
+		autoMode := services.NewAutoModePolicy(dataStore, mqttClient, cfg.AutoMode.SustainedReadings, cfg.AutoMode.ManualCooldown)
+		autoMode.SetModeChangeCooldown(modeCooldown)
+		scheduler.SetAutoModePolicy(autoMode)
+		log.Printf("🔁 Auto-mode enabled (%d sustained readings, manual cooldown=%s)", cfg.AutoMode.SustainedReadings, cfg.AutoMode.ManualCooldown)
+	}
+	// Caution clients when the drinking water verdict rests on stale data
+	stalenessPolicy := models.StalenessPolicy(cfg.Safety.StalePolicy)
+	if !stalenessPolicy.IsValid() {
+		log.Printf("⚠️  Warning: Unknown SAFETY_STALE_POLICY %q, using %q", cfg.Safety.StalePolicy, models.StalenessFailSafe)
+		stalenessPolicy = models.StalenessFailSafe
+	}
+	scheduler.SetSafetyMonitor(services.NewSafetyMonitor(dataStore, cfg.Safety.StaleAfter, stalenessPolicy, wsHub))
+	log.Printf("🛡️  Drinking water data stale after %s (policy=%s)", cfg.Safety.StaleAfter, stalenessPolicy)
 	scheduler.Start()
 	log.Println("🕐 Started automated filter mode scheduler")
 
 	// Initialize ML service
 	mlService := ml.NewMLService(dataStore)
+	mlService.SetPredictionConcurrency(cfg.ML.PredictionConcurrency)
+	mlService.SetPredictionDebounce(cfg.ML.PredictionDebounce)
+	mlService.SetAlertThrottleWindow(cfg.ML.AlertThrottleWindow)
+	mlService.SetAlertWebhook(cfg.ML.AlertWebhookURL, cfg.ML.AlertWebhookTimeout)
+	if err := mlService.SetAnomalyMethod(ml.AnomalyMethod(cfg.ML.AnomalyMethod)); err != nil {
+		log.Printf("⚠️  Warning: Unknown ML_ANOMALY_METHOD %q, using %q", cfg.ML.AnomalyMethod, ml.AnomalyMethodZScore)
+	}
+	mlService.EnableRealTimeAnomaly(cfg.ML.EnableAnomaly)
+	mlService.SetFilterHealthBroadcaster(wsHub)
+	mlService.SetReplacementAlertDays(cfg.ML.ReplacementAlertDays)
+	mlService.SetReplacementAlertBroadcaster(wsHub)
+	mlService.SetBaselineResetOnFilterReplacement(cfg.ML.ResetBaselineOnFilterReplacement)
+	mlService.SetEfficiencyMinPreValues(cfg.ML.EfficiencyMinPreTurbidity, cfg.ML.EfficiencyMinPreTDS)
 	mlService.Start()
 	defer mlService.Stop()
 	log.Println("🤖 ML service initialized and started")
 
+	// Fail closed: running without authentication must be asked for explicitly
+	if cfg.Server.JWTSecret == "" {
+		if !cfg.Server.AuthDisabled {
+			log.Fatal("❌ JWT_SECRET is not set. Set it, or set AUTH_DISABLED=true to run without API authentication")
+		}
+		log.Println("⚠️  Warning: AUTH_DISABLED=true and no JWT_SECRET, API authentication is OFF and every /api/v1 endpoint is open to anyone")
+	}
+
+	trustedProxies, err := httphandlers.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Printf("⚠️  Warning: Ignoring TRUSTED_PROXIES: %v", err)
+	}
+	if cfg.Server.RateLimitRPS > 0 && len(trustedProxies) == 0 {
+		log.Println("⚠️  Warning: RATE_LIMIT_RPS is set without TRUSTED_PROXIES, clients behind a reverse proxy share one rate limit")
+	}
+
 	// Setup HTTP routes with scheduler, MQTT and ML support
-	router := httphandlers.SetupRoutes(dataStore, wsHub, scheduler, mqttClient, mlService)
+	router := httphandlers.SetupRoutes(dataStore, wsHub, scheduler, mqttClient, mlService, deduplicator, httphandlers.RouterOptions{
+		ExportMaxRange:     cfg.Export.MaxRange,
+		ExportDefaultDays:  cfg.Export.DefaultDays,
+		FlowUnit:           flowUnit,
+		QueryWarnThreshold: cfg.Server.QueryWarnThreshold,
+		QueryCountHeader:   cfg.Server.QueryCountHeader,
+		ModeChangeCooldown: modeCooldown,
+		AllReadingsLimit:   cfg.Server.AllReadingsLimit,
+		AdminToken:         cfg.Server.AdminToken,
+		ClockSkew:          clockSkew,
+		ExpectedInterval:   cfg.Ingestion.ExpectedInterval,
+		JWTSecret:          cfg.Server.JWTSecret,
+		TokenTTL:           cfg.Server.TokenTTL,
+		AdminUsername:      cfg.Server.AdminUsername,
+		AdminPassword:      cfg.Server.AdminPassword,
+		RateLimitRPS:       cfg.Server.RateLimitRPS,
+		RateLimitBurst:     cfg.Server.RateLimitBurst,
+		TrustedProxies:     trustedProxies,
+		TargetVolumeMin:    cfg.Filter.TargetVolumeMin,
+		TargetVolumeMax:    cfg.Filter.TargetVolumeMax,
+		FilterHealthStale:  &cfg.ML.HealthStaleAfter,
+		ResolvedAnomalies:  &cfg.ML.DashboardResolved,
+		StoreBreaker:       storeBreaker,
+		SafetyMaxAge:       cfg.Safety.StaleAfter,
+		StalenessPolicy:    stalenessPolicy,
+		Retention:          retention,
+	})
+
+	// Log registered endpoints and subsystem readiness
+	logStartupSelfCheck(router, storageMode, mqttClient, scheduler, mlService)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -119,29 +281,6 @@ func main() {
 	// Start HTTP server in a goroutine
 	go func() {
 		log.Printf("🚀 Starting HTTP server on port %s", cfg.Server.Port)
-		log.Println("📡 API endpoints available:")
-		log.Println("  GET /api/v1/stats - System statistics")
-		log.Println("  GET /api/v1/sensors/latest - Latest readings from all devices")
-		log.Println("  GET /api/v1/sensors/recent?limit=50 - Recent readings")
-		log.Println("  GET /api/v1/sensors/all - All sensor data with pagination")
-		log.Println("  GET /api/v1/sensors/all/simple - All sensor data simple format")
-		log.Println("  GET /api/v1/sensors/stats - Sensor data statistics")
-		log.Println("  GET /api/v1/sensors/history - Historical data in time range")
-		log.Println("  GET /api/v1/sensors/quality - Water quality status")
-		log.Println("  GET /api/v1/sensors/best-daily - Best daily values for today")
-		log.Println("  GET /api/v1/sensors/worst-daily - Worst daily values for today")
-		log.Println("  POST /api/v1/sensors/data - Add sensor data (testing)")
-		log.Println("  POST /api/v1/commands/filter - Set filter mode")
-		log.Println("  GET /api/v1/schedules - List all schedules")
-		log.Println("  POST /api/v1/schedules - Create new schedule")
-		log.Println("  GET /api/v1/schedules/{id} - Get schedule details")
-		log.Println("  PUT /api/v1/schedules/{id} - Update schedule")
-		log.Println("  DELETE /api/v1/schedules/{id} - Delete schedule")
-		log.Println("  POST /api/v1/schedules/{id}/toggle - Enable/disable schedule")
-		log.Println("  GET /api/v1/schedules/executions - Schedule execution history")
-		log.Println("  GET /api/v1/export/history.xlsx - Export history to Excel")
-		log.Println("  GET /api/v1/export/history.csv - Export history to CSV")
-		log.Println("  WS /ws - WebSocket for real-time updates")
 		log.Printf("🌐 Server running on port %s (listening on all interfaces)", cfg.Server.Port)
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -168,4 +307,92 @@ func main() {
 	}
 
 	log.Println("✅ Server shutdown complete")
-}
\ No newline at end of file
+}
+
+// openDataStore creates the data store selected by cfg.Storage.Backend and returns
+// it with a description of the storage mode. With "auto" an unreachable database
+// falls back to in-memory storage; with "database" it is an error.
+func openDataStore(cfg *config.Config, connect func(config.DatabaseConfig) (*database.DB, error)) (store.DataStore, string, error) {
+	backend := cfg.Storage.Backend
+	if !config.IsValidStoreBackend(backend) {
+		return nil, "", fmt.Errorf("invalid STORE_BACKEND %q: use %q, %q or %q",
+			backend, config.StoreBackendDatabase, config.StoreBackendMemory, config.StoreBackendAuto)
+	}
+	log.Printf("🗄️  Store backend: %s", backend)
+
+	newMemoryStore := func() (store.DataStore, string, error) {
+		log.Printf("💾 Initialized in-memory data store (max %d readings)", cfg.Storage.MemoryMaxReadings)
+		return store.NewStore(cfg.Storage.MemoryMaxReadings), "in-memory", nil
+	}
+
+	if backend == config.StoreBackendMemory {
+		return newMemoryStore()
+	}
+
+	db, err := connect(cfg.Database)
+	if err != nil {
+		if backend == config.StoreBackendDatabase {
+			return nil, "", fmt.Errorf("failed to connect to database: %w", err)
+		}
+		log.Printf("⚠️  Warning: Failed to connect to database: %v", err)
+		log.Println("📱 Falling back to in-memory storage")
+		return newMemoryStore()
+	}
+	log.Println("✅ Connected to Aiven PostgreSQL database")
+
+	// Run migrations from migrations/ directory
+	if err := database.RunMigrations(db.DB); err != nil {
+		return nil, "", fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	log.Println("💾 Initialized database data store with Aiven PostgreSQL")
+	return database.NewDatabaseStore(db.DB), "PostgreSQL", nil
+}
+
+// qualityThresholds converts configured quality thresholds to the model
+func qualityThresholds(c config.QualityThresholdsConfig) models.QualityThresholds {
+	return models.QualityThresholds{
+		PhMin:                 c.PhMin,
+		PhMax:                 c.PhMax,
+		TurbidityExcellentMax: c.TurbidityExcellentMax,
+		TurbidityGoodMax:      c.TurbidityGoodMax,
+		TDSExcellentMax:       c.TDSExcellentMax,
+		TDSGoodMax:            c.TDSGoodMax,
+		TDSFairMax:            c.TDSFairMax,
+	}
+}
+
+// logStartupSelfCheck logs every route registered on the router followed by a
+// readiness summary of the backend subsystems
+func logStartupSelfCheck(router chi.Routes, storageMode string, mqttClient *mqtt.Client, scheduler *services.Scheduler, mlService *ml.MLService) {
+	routes, err := httphandlers.RegisteredRoutes(router)
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to list registered routes: %v", err)
+	} else {
+		log.Printf("📡 API endpoints available (%d routes):", len(routes))
+		for _, route := range routes {
+			log.Printf("  %s %s", strings.Join(route.Methods, ","), route.Path)
+		}
+	}
+
+	mqttStatus := "disabled"
+	if mqttClient.Connected() {
+		mqttStatus = "connected"
+	} else if mqttClient != nil {
+		mqttStatus = "disconnected"
+	}
+	mlStatus := "stopped"
+	if running, ok := mlService.GetMLServiceStatus()["running"].(bool); ok && running {
+		mlStatus = "running"
+	}
+	schedulerStatus := "stopped"
+	if scheduler.IsRunning() {
+		schedulerStatus = "running"
+	}
+
+	log.Println("🩺 Subsystem readiness:")
+	log.Printf("  Storage:   %s", storageMode)
+	log.Printf("  MQTT:      %s", mqttStatus)
+	log.Printf("  ML:        %s", mlStatus)
+	log.Printf("  Scheduler: %s", schedulerStatus)
+}
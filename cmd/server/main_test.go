@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Capstone-E1/aquasmart_backend/config"
+	"github.com/Capstone-E1/aquasmart_backend/internal/database"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// unreachableDatabase simulates a database that is down
+func unreachableDatabase(config.DatabaseConfig) (*database.DB, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestOpenDataStore_Backends(t *testing.T) {
+	tests := []struct {
+		backend  string
+		wantErr  bool
+		wantMode string
+	}{
+		{config.StoreBackendDatabase, true, ""},
+		{config.StoreBackendAuto, false, "in-memory"},
+		{config.StoreBackendMemory, false, "in-memory"},
+		{"postgres", true, ""},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{Storage: config.StorageConfig{Backend: tt.backend, MemoryMaxReadings: 10}}
+		connected := false
+		connect := func(dbCfg config.DatabaseConfig) (*database.DB, error) {
+			connected = true
+			return unreachableDatabase(dbCfg)
+		}
+
+		dataStore, mode, err := openDataStore(cfg, connect)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.backend, tt.wantErr, err)
+		}
+		if tt.wantErr {
+			if dataStore != nil {
+				t.Errorf("%s: expected no data store on failure", tt.backend)
+			}
+			continue
+		}
+		if _, ok := dataStore.(*store.Store); !ok || mode != tt.wantMode {
+			t.Errorf("%s: expected the in-memory store, got %T (%s)", tt.backend, dataStore, mode)
+		}
+		if tt.backend == config.StoreBackendMemory && connected {
+			t.Error("memory: expected the database not to be contacted")
+		}
+	}
+}
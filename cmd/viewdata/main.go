@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
@@ -47,6 +48,17 @@ func main() {
 	}
 }
 
+// timestampLayout is how timestamps are shown in every table, always in UTC
+const timestampLayout = "2006-01-02 15:04:05"
+
+// formatTimestamp formats a scanned timestamp column, showing "-" for NULL
+func formatTimestamp(timestamp sql.NullTime) string {
+	if !timestamp.Valid {
+		return "-"
+	}
+	return timestamp.Time.UTC().Format(timestampLayout)
+}
+
 func viewSensorReadings(db *database.DB, limit int) {
 	query := `
 		SELECT id, device_id, timestamp, filter_mode, flow, ph, turbidity, tds
@@ -70,7 +82,7 @@ func viewSensorReadings(db *database.DB, limit int) {
 	for rows.Next() {
 		var id int
 		var deviceID, filterMode string
-		var timestamp string
+		var timestamp sql.NullTime
 		var flow, ph, turbidity, tds float64
 
 		err := rows.Scan(&id, &deviceID, &timestamp, &filterMode, &flow, &ph, &turbidity, &tds)
@@ -80,7 +92,7 @@ func viewSensorReadings(db *database.DB, limit int) {
 		}
 
 		fmt.Printf("%-4d %-12s %-20s %-15s %-6.1f %-5.1f %-9.1f %-6.0f\n",
-			id, deviceID, timestamp[:19], filterMode, flow, ph, turbidity, tds)
+			id, deviceID, formatTimestamp(timestamp), filterMode, flow, ph, turbidity, tds)
 		count++
 	}
 
@@ -113,7 +125,8 @@ func viewWaterQuality(db *database.DB, limit int) {
 	count := 0
 	for rows.Next() {
 		var id int
-		var deviceID, timestamp, phStatus, turbidityStatus, tdsStatus, overall string
+		var deviceID, phStatus, turbidityStatus, tdsStatus, overall string
+		var timestamp sql.NullTime
 
 		err := rows.Scan(&id, &deviceID, &timestamp, &phStatus, &turbidityStatus, &tdsStatus, &overall)
 		if err != nil {
@@ -122,7 +135,7 @@ func viewWaterQuality(db *database.DB, limit int) {
 		}
 
 		fmt.Printf("%-4d %-12s %-20s %-12s %-15s %-12s %-15s\n",
-			id, deviceID, timestamp[:19], phStatus, turbidityStatus, tdsStatus, overall)
+			id, deviceID, formatTimestamp(timestamp), phStatus, turbidityStatus, tdsStatus, overall)
 		count++
 	}
 
@@ -154,7 +167,8 @@ func viewDeviceStatus(db *database.DB, limit int) {
 
 	count := 0
 	for rows.Next() {
-		var deviceID, lastSeen, filterMode string
+		var deviceID, filterMode string
+		var lastSeen sql.NullTime
 		var isActive bool
 		var totalReadings int
 
@@ -170,7 +184,7 @@ func viewDeviceStatus(db *database.DB, limit int) {
 		}
 
 		fmt.Printf("%-12s %-20s %-8s %-15s %-8d\n",
-			deviceID, lastSeen[:19], activeStr, filterMode, totalReadings)
+			deviceID, formatTimestamp(lastSeen), activeStr, filterMode, totalReadings)
 		count++
 	}
 
@@ -203,7 +217,8 @@ func viewFilterCommands(db *database.DB, limit int) {
 	count := 0
 	for rows.Next() {
 		var id int
-		var command, mode, timestamp, status string
+		var command, mode, status string
+		var timestamp sql.NullTime
 
 		err := rows.Scan(&id, &command, &mode, &timestamp, &status)
 		if err != nil {
@@ -212,7 +227,7 @@ func viewFilterCommands(db *database.DB, limit int) {
 		}
 
 		fmt.Printf("%-4d %-20s %-18s %-20s %-10s\n",
-			id, command, mode, timestamp[:19], status)
+			id, command, mode, formatTimestamp(timestamp), status)
 		count++
 	}
 
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name      string
+		timestamp sql.NullTime
+		expected  string
+	}{
+		{"full timestamp", sql.NullTime{Time: time.Date(2024, 6, 1, 8, 30, 15, 123456789, time.UTC), Valid: true}, "2024-06-01 08:30:15"},
+		{"other zone shown in UTC", sql.NullTime{Time: time.Date(2024, 6, 1, 3, 0, 0, 0, jakarta), Valid: true}, "2024-05-31 20:00:00"},
+		{"zero time", sql.NullTime{Time: time.Time{}, Valid: true}, "0001-01-01 00:00:00"},
+		{"NULL", sql.NullTime{}, "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTimestamp(tt.timestamp); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
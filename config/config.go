@@ -3,21 +3,45 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration for the water purification IoT backend
 type Config struct {
-	Server   ServerConfig
-	MQTT     MQTTConfig
-	Database DatabaseConfig
+	Server    ServerConfig
+	MQTT      MQTTConfig
+	Database  DatabaseConfig
+	Storage   StorageConfig
+	Ingestion IngestionConfig
+	ML        MLConfig
+	Export    ExportConfig
+	AutoMode  AutoModeConfig
+	Safety    SafetyConfig
+	Filter    FilterConfig
+	Quality   QualityConfig
+	Units     UnitsConfig
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Port         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port               string
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	QueryWarnThreshold int           // Warn when a request makes more store calls than this (0 = off)
+	QueryCountHeader   bool          // Expose per-request store call counts in a debug header
+	AllReadingsLimit   int           // Most readings loaded by "all data" endpoints (0 = unlimited)
+	AdminToken         string        // Bearer token for operator-only endpoints (empty disables them)
+	JWTSecret          string        // HS256 secret for API bearer tokens (required unless AuthDisabled)
+	AuthDisabled       bool          // Run without API authentication when no JWT secret is set
+	TokenTTL           time.Duration // Lifetime of tokens issued by the login endpoint
+	AdminUsername      string        // Credential accepted by the login endpoint
+	AdminPassword      string
+	RateLimitRPS       float64       // Requests per second allowed per client IP (0 = unlimited, the default)
+	RateLimitBurst     int           // Requests a client may burst above the steady rate
+	TrustedProxies     []string      // Proxy IPs or CIDR ranges whose X-Forwarded-For is believed
+	WSCompression      bool          // Negotiate permessage-deflate with WebSocket clients that support it
+	WSPingInterval     time.Duration // How often WebSocket clients are pinged; silent ones are dropped after two intervals
 }
 
 // MQTTConfig holds MQTT broker configuration
@@ -31,6 +55,11 @@ type MQTTConfig struct {
 	ConnectRetry       bool
 	TopicSensorData    string
 	TopicFilterCommand string
+
+	CACert             string // PEM CA certificate for verifying a TLS broker ("" = system roots)
+	ClientCert         string // PEM client certificate for mutual TLS
+	ClientKey          string // PEM key of the client certificate
+	InsecureSkipVerify bool   // Skip verifying the broker certificate (testing only)
 }
 
 // DatabaseConfig holds PostgreSQL database configuration
@@ -41,15 +70,152 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	StatementTimeout time.Duration // Server-side limit on each statement (0 = server default)
+	BreakerThreshold int           // Consecutive unavailable errors that open the circuit breaker (0 = no breaker)
+	BreakerCooldown  time.Duration // How long the open breaker fails fast before probing the database again
+}
+
+// StorageConfig holds data store configuration
+type StorageConfig struct {
+	Backend           string // Data store to use: database, memory or auto
+	MemoryMaxReadings int    // Readings retained by the in-memory store before the oldest are evicted
+
+	SeedDemoData     bool          // Fill an empty store with synthetic readings on startup
+	SeedDemoDays     int           // Days of synthetic readings to seed
+	SeedDemoInterval time.Duration // Time between seeded readings of each device
+
+	RetentionDays      int           // Days of readings, anomalies and predictions kept (0 = keep everything)
+	RetentionInterval  time.Duration // Time between retention runs
+	RetentionBatchSize int           // Rows deleted per statement by a retention run
+}
+
+// Data store backends
+const (
+	StoreBackendAuto     = "auto"     // PostgreSQL, falling back to in-memory when unreachable
+	StoreBackendDatabase = "database" // PostgreSQL only; startup fails when unreachable
+	StoreBackendMemory   = "memory"   // In-memory only; the database is never contacted
+)
+
+// IsValidStoreBackend checks if the backend is a supported data store backend
+func IsValidStoreBackend(backend string) bool {
+	return backend == StoreBackendAuto || backend == StoreBackendDatabase || backend == StoreBackendMemory
+}
+
+// IngestionConfig holds sensor data ingestion configuration
+type IngestionConfig struct {
+	DedupEnabled    bool          // Skip readings identical to the device's last reading
+	DedupWindow     time.Duration // Time window in which identical readings are treated as duplicates
+	MaxClockSkew    time.Duration // Largest allowed device/server clock difference (0 = unchecked)
+	ClockSkewPolicy string        // What to do with skewed readings: reject, clamp or record
+
+	ExpectedInterval time.Duration // How often each device should send a reading (0 = inferred per device)
+}
+
+// MLConfig holds ML background processing configuration
+type MLConfig struct {
+	PredictionConcurrency int           // Maximum prediction updates running at once
+	PredictionDebounce    time.Duration // Minimum interval between prediction updates per device/mode
+	AlertThrottleWindow   time.Duration // Minimum interval between anomaly alerts per device/metric (0 = every anomaly)
+	AlertWebhookURL       string        // Where high and critical anomaly alerts are posted ("" = nowhere)
+	AlertWebhookTimeout   time.Duration // Longest a single webhook delivery attempt may take
+	EnableAnomaly         bool          // Check new readings for anomalies (can be toggled at runtime)
+	AnomalyMethod         string        // How readings are checked: zscore, iqr or both
+	ReplacementAlertDays  []int         // Predicted days remaining at which a filter replacement alert is raised once
+	HealthStaleAfter      time.Duration // Age at which a filter health assessment is flagged as stale
+	DashboardResolved     int           // Recently resolved anomalies shown on the ML dashboard (0 = none)
+
+	ResetBaselineOnFilterReplacement bool // Recompute baselines and resolve efficiency anomalies when a filter is replaced
+
+	EfficiencyMinPreTurbidity float64 // Pre-filtration turbidity (NTU) below which a pair is left out of the turbidity reduction and efficiency
+	EfficiencyMinPreTDS       float64 // Pre-filtration TDS (ppm) below which a pair is left out of the TDS reduction and efficiency
+}
+
+// ExportConfig holds history export configuration
+type ExportConfig struct {
+	MaxRange    time.Duration // Longest date range a single export may cover (0 = unlimited)
+	DefaultDays int           // Days covered when an export request gives no start date
+}
+
+// AutoModeConfig holds the quality-driven automatic filter mode policy configuration
+type AutoModeConfig struct {
+	Enabled           bool          // Switch drinking water to household mode on sustained poor quality
+	SustainedReadings int           // Consecutive dangerous readings required before switching
+	ManualCooldown    time.Duration // How long manual and scheduled mode changes are respected
+}
+
+// SafetyConfig holds the safe-to-drink staleness policy configuration
+type SafetyConfig struct {
+	StaleAfter  time.Duration // Age at which the latest drinking water reading is stale
+	StalePolicy string        // "fail_safe" (stale data is never safe) or "fail_open" (judged with a caution)
+}
+
+// FilterConfig holds filter valve control configuration
+type FilterConfig struct {
+	ModeChangeMinInterval time.Duration // Minimum time between mode changes on a device (0 = unlimited)
+	TargetVolumeMin       float64       // Smallest filtration target volume in liters
+	TargetVolumeMax       float64       // Largest filtration target volume in liters
+}
+
+// QualityConfig holds water quality scoring configuration
+type QualityConfig struct {
+	WQIWeightPh        float64 // Relative weight of pH in the water quality index
+	WQIWeightTurbidity float64 // Relative weight of turbidity in the water quality index
+	WQIWeightTDS       float64 // Relative weight of TDS in the water quality index
+
+	Thresholds          QualityThresholdsConfig // Limits of the per-metric assessment
+	DrinkingThresholds  QualityThresholdsConfig // Drinking mode limits; unset ones inherit Thresholds
+	HouseholdThresholds QualityThresholdsConfig // Household mode limits; unset ones inherit Thresholds
+}
+
+// QualityThresholdsConfig holds the limits the per-metric quality assessment
+// compares readings against
+type QualityThresholdsConfig struct {
+	PhMin                 float64 // Below is dangerously acidic
+	PhMax                 float64 // Above is dangerously alkaline
+	TurbidityExcellentMax float64 // NTU; above is good
+	TurbidityGoodMax      float64 // NTU; above is poor
+	TDSExcellentMax       float64 // PPM; above is good
+	TDSGoodMax            float64 // PPM; above is fair
+	TDSFairMax            float64 // PPM; above is poor
+}
+
+// UnitsConfig holds the units measurements are presented in
+type UnitsConfig struct {
+	Flow string // Flow rate unit: L/min or GPM
 }
 
 // Load loads configuration from environment variables with defaults
 func Load() *Config {
+	qualityThresholds := getQualityThresholdsEnv("QUALITY_", QualityThresholdsConfig{
+		PhMin:                 7.0,
+		PhMax:                 8.5,
+		TurbidityExcellentMax: 1.0,
+		TurbidityGoodMax:      4.0,
+		TDSExcellentMax:       300,
+		TDSGoodMax:            600,
+		TDSFairMax:            900,
+	})
+
 	return &Config{
 		Server: ServerConfig{
-			Port:         getEnv("PORT", "8080"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			Port:               getEnv("PORT", "8080"),
+			ReadTimeout:        getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:       getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			QueryWarnThreshold: getIntEnv("SERVER_QUERY_WARN_THRESHOLD", 20),
+			QueryCountHeader:   getBoolEnv("SERVER_QUERY_COUNT_HEADER", false),
+			AllReadingsLimit:   getIntEnv("SERVER_ALL_READINGS_LIMIT", 10000),
+			AdminToken:         getEnv("ADMIN_API_TOKEN", ""),
+			JWTSecret:          getEnv("JWT_SECRET", ""),
+			AuthDisabled:       getBoolEnv("AUTH_DISABLED", false),
+			TokenTTL:           getDurationEnv("JWT_TTL", 24*time.Hour),
+			AdminUsername:      getEnv("ADMIN_USERNAME", ""),
+			AdminPassword:      getEnv("ADMIN_PASSWORD", ""),
+			RateLimitRPS:       getFloatEnv("RATE_LIMIT_RPS", 0),
+			RateLimitBurst:     getIntEnv("RATE_LIMIT_BURST", 40),
+			TrustedProxies:     getListEnv("TRUSTED_PROXIES", nil),
+			WSCompression:      getBoolEnv("WS_COMPRESSION", false),
+			WSPingInterval:     getDurationEnv("WS_PING_INTERVAL", 30*time.Second),
 		},
 		MQTT: MQTTConfig{ 
 			BrokerURL:          getMQTTBrokerURL(),
@@ -61,6 +227,11 @@ func Load() *Config {
 			ConnectRetry:       getBoolEnv("MQTT_CONNECT_RETRY", true),
 			TopicSensorData:    getEnv("MQTT_TOPIC_SENSOR_DATA", "aquasmart/sensors/data"),
 			TopicFilterCommand: getEnv("MQTT_TOPIC_FILTER_COMMAND", "aquasmart/filter/command"),
+
+			CACert:             getEnv("MQTT_CA_CERT", ""),
+			ClientCert:         getEnv("MQTT_CLIENT_CERT", ""),
+			ClientKey:          getEnv("MQTT_CLIENT_KEY", ""),
+			InsecureSkipVerify: getBoolEnv("MQTT_INSECURE_SKIP_VERIFY", false),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -69,6 +240,77 @@ func Load() *Config {
 			Password: getEnv("DB_PASSWORD", ""),
 			DBName:   getEnv("DB_NAME", "aquasmart"),
 			SSLMode:  getEnv("DB_SSLMODE", "require"),
+
+			StatementTimeout: getDurationEnv("DB_STATEMENT_TIMEOUT", 30*time.Second),
+			BreakerThreshold: getIntEnv("DB_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getDurationEnv("DB_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		Storage: StorageConfig{
+			Backend:           getEnv("STORE_BACKEND", StoreBackendAuto),
+			MemoryMaxReadings: getIntEnv("MEMORY_MAX_READINGS", 1000),
+
+			SeedDemoData:     getBoolEnv("DEMO_SEED_ENABLED", false),
+			SeedDemoDays:     getIntEnv("DEMO_SEED_DAYS", 3),
+			SeedDemoInterval: getDurationEnv("DEMO_SEED_INTERVAL", 10*time.Minute),
+
+			RetentionDays:      getIntEnv("DATA_RETENTION_DAYS", 0),
+			RetentionInterval:  getDurationEnv("DATA_RETENTION_INTERVAL", 6*time.Hour),
+			RetentionBatchSize: getIntEnv("DATA_RETENTION_BATCH_SIZE", 5000),
+		},
+		Ingestion: IngestionConfig{
+			DedupEnabled:    getBoolEnv("INGEST_DEDUP_ENABLED", false),
+			DedupWindow:     getDurationEnv("INGEST_DEDUP_WINDOW", 5*time.Second),
+			MaxClockSkew:    getDurationEnv("INGEST_MAX_CLOCK_SKEW", 0),
+			ClockSkewPolicy: getEnv("INGEST_CLOCK_SKEW_POLICY", "clamp"),
+
+			ExpectedInterval: getDurationEnv("INGEST_EXPECTED_INTERVAL", 0),
+		},
+		ML: MLConfig{
+			PredictionConcurrency: getIntEnv("ML_PREDICTION_CONCURRENCY", 2),
+			PredictionDebounce:    getDurationEnv("ML_PREDICTION_DEBOUNCE", 30*time.Second),
+			AlertThrottleWindow:   getDurationEnv("ML_ALERT_THROTTLE_WINDOW", 15*time.Minute),
+			AlertWebhookURL:       getEnv("ALERT_WEBHOOK_URL", ""),
+			AlertWebhookTimeout:   getDurationEnv("ALERT_WEBHOOK_TIMEOUT", 5*time.Second),
+			EnableAnomaly:         getBoolEnv("ML_ENABLE_ANOMALY", false),
+			AnomalyMethod:         getEnv("ML_ANOMALY_METHOD", "zscore"),
+			ReplacementAlertDays:  getIntListEnv("ML_REPLACEMENT_ALERT_DAYS", []int{14, 7}),
+			HealthStaleAfter:      getDurationEnv("ML_FILTER_HEALTH_STALE_AFTER", 2*time.Hour),
+			DashboardResolved:     getIntEnv("ML_DASHBOARD_RESOLVED_LIMIT", 10),
+
+			ResetBaselineOnFilterReplacement: getBoolEnv("ML_RESET_BASELINE_ON_FILTER_REPLACEMENT", true),
+
+			EfficiencyMinPreTurbidity: getFloatEnv("ML_EFFICIENCY_MIN_PRE_TURBIDITY", 1.0),
+			EfficiencyMinPreTDS:       getFloatEnv("ML_EFFICIENCY_MIN_PRE_TDS", 10),
+		},
+		Export: ExportConfig{
+			MaxRange:    getDurationEnv("EXPORT_MAX_RANGE", 90*24*time.Hour),
+			DefaultDays: getIntEnv("EXPORT_DEFAULT_DAYS", 30),
+		},
+		AutoMode: AutoModeConfig{
+			Enabled:           getBoolEnv("AUTO_MODE_ENABLED", false),
+			SustainedReadings: getIntEnv("AUTO_MODE_SUSTAINED_READINGS", 5),
+			ManualCooldown:    getDurationEnv("AUTO_MODE_MANUAL_COOLDOWN", 30*time.Minute),
+		},
+		Safety: SafetyConfig{
+			StaleAfter:  getDurationEnv("SAFETY_STALE_AFTER", 10*time.Minute),
+			StalePolicy: getEnv("SAFETY_STALE_POLICY", "fail_safe"),
+		},
+		Filter: FilterConfig{
+			ModeChangeMinInterval: getDurationEnv("FILTER_MODE_CHANGE_MIN_INTERVAL", 30*time.Second),
+			TargetVolumeMin:       getFloatEnv("FILTER_TARGET_VOLUME_MIN", 0.5),
+			TargetVolumeMax:       getFloatEnv("FILTER_TARGET_VOLUME_MAX", 1000),
+		},
+		Quality: QualityConfig{
+			WQIWeightPh:        getFloatEnv("WQI_WEIGHT_PH", 0.2),
+			WQIWeightTurbidity: getFloatEnv("WQI_WEIGHT_TURBIDITY", 0.4),
+			WQIWeightTDS:       getFloatEnv("WQI_WEIGHT_TDS", 0.4),
+
+			Thresholds:          qualityThresholds,
+			DrinkingThresholds:  getQualityThresholdsEnv("QUALITY_DRINKING_", qualityThresholds),
+			HouseholdThresholds: getQualityThresholdsEnv("QUALITY_HOUSEHOLD_", qualityThresholds),
+		},
+		Units: UnitsConfig{
+			Flow: getEnv("UNITS_FLOW", "L/min"),
 		},
 	}
 }
@@ -91,6 +333,26 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getIntEnv returns integer environment variable value or default if not set
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getFloatEnv returns float environment variable value or default if not set
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // getBoolEnv returns boolean environment variable value or default if not set
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -101,6 +363,56 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getQualityThresholdsEnv returns the quality thresholds set by the prefixed
+// environment variables, taking unset ones from defaults
+func getQualityThresholdsEnv(prefix string, defaults QualityThresholdsConfig) QualityThresholdsConfig {
+	return QualityThresholdsConfig{
+		PhMin:                 getFloatEnv(prefix+"PH_MIN", defaults.PhMin),
+		PhMax:                 getFloatEnv(prefix+"PH_MAX", defaults.PhMax),
+		TurbidityExcellentMax: getFloatEnv(prefix+"TURBIDITY_EXCELLENT_MAX", defaults.TurbidityExcellentMax),
+		TurbidityGoodMax:      getFloatEnv(prefix+"TURBIDITY_GOOD_MAX", defaults.TurbidityGoodMax),
+		TDSExcellentMax:       getFloatEnv(prefix+"TDS_EXCELLENT_MAX", defaults.TDSExcellentMax),
+		TDSGoodMax:            getFloatEnv(prefix+"TDS_GOOD_MAX", defaults.TDSGoodMax),
+		TDSFairMax:            getFloatEnv(prefix+"TDS_FAIR_MAX", defaults.TDSFairMax),
+	}
+}
+
+// getListEnv returns a comma-separated list environment variable or default if
+// not set
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// getIntListEnv returns a comma-separated integer list environment variable or
+// default if not set or any element is not an integer
+func getIntListEnv(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		intValue, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, intValue)
+	}
+	return values
+}
+
 // getMQTTBrokerURL returns MQTT broker URL with appropriate scheme prefix
 // Supports tcp://, tls://, ssl:// schemes and auto-detects based on MQTT_USE_TLS
 func getMQTTBrokerURL() string {
@@ -112,7 +424,7 @@ func getMQTTBrokerURL() string {
 			return broker
 		}
 	}
-	if len(broker) >= 7 && broker[:7] == "tcps://" {
+	if strings.HasPrefix(broker, "tcps://") || strings.HasPrefix(broker, "mqtts://") {
 		return broker
 	}
 	
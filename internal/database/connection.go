@@ -1,12 +1,20 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/Capstone-E1/aquasmart_backend/config"
 )
 
@@ -30,6 +38,12 @@ func Connect(cfg config.DatabaseConfig) (*DB, error) {
 		log.Printf("Connecting to database at %s:%s/%s", cfg.Host, cfg.Port, cfg.DBName)
 	}
 
+	// Have the server cancel runaway statements even if the client side never does
+	connStr = withStatementTimeout(connStr, cfg.StatementTimeout)
+	if cfg.StatementTimeout > 0 {
+		log.Printf("Database statement timeout: %s", cfg.StatementTimeout)
+	}
+
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
@@ -58,8 +72,63 @@ func (db *DB) Close() error {
 	return nil
 }
 
+// withStatementTimeout adds a statement_timeout startup option to a key=value or
+// URL connection string. Connection strings that already set statement_timeout
+// (or, in key=value form, any options) are left alone, as is a zero timeout.
+func withStatementTimeout(connStr string, timeout time.Duration) string {
+	if timeout <= 0 || strings.Contains(connStr, "statement_timeout") {
+		return connStr
+	}
+	option := fmt.Sprintf("-c statement_timeout=%d", timeout.Milliseconds())
+
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return connStr
+		}
+		query := u.Query()
+		if existing := query.Get("options"); existing != "" {
+			option = existing + " " + option
+		}
+		query.Set("options", option)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	if strings.Contains(connStr, "options=") {
+		return connStr
+	}
+	return connStr + fmt.Sprintf(" options='%s'", option)
+}
+
 // BuildConnectionString builds a PostgreSQL connection string
 func BuildConnectionString(cfg config.DatabaseConfig) string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
-}
\ No newline at end of file
+}
+
+// PostgreSQL error classes that mean the server can't serve queries right now
+var unavailableErrorClasses = map[pq.ErrorClass]bool{
+	"08": true, // Connection exception
+	"53": true, // Insufficient resources (e.g. too many connections)
+	"57": true, // Operator intervention, including statements cancelled by statement_timeout
+}
+
+// IsUnavailable reports whether err means the database could not be reached
+// or did not answer in time, as opposed to rejecting a particular query
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return unavailableErrorClasses[pqErr.Code.Class()]
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
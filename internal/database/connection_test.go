@@ -0,0 +1,88 @@
+package database
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/config"
+)
+
+func TestWithStatementTimeout_ConnectionStringForms(t *testing.T) {
+	tests := []struct {
+		name    string
+		connStr string
+		timeout time.Duration
+		want    string
+	}{
+		{"key/value", "host=localhost dbname=aquasmart", 30 * time.Second,
+			"host=localhost dbname=aquasmart options='-c statement_timeout=30000'"},
+		{"url", "postgres://user:pass@db:5432/aquasmart?sslmode=require", 1500 * time.Millisecond,
+			"postgres://user:pass@db:5432/aquasmart?options=-c+statement_timeout%3D1500&sslmode=require"},
+		{"url with options", "postgres://db/aquasmart?options=-c%20search_path%3Dapp", time.Second,
+			"postgres://db/aquasmart?options=-c+search_path%3Dapp+-c+statement_timeout%3D1000"},
+		{"disabled", "host=localhost", 0, "host=localhost"},
+		{"explicit setting wins", "host=localhost options='-c statement_timeout=5s'", time.Second,
+			"host=localhost options='-c statement_timeout=5s'"},
+	}
+
+	for _, tt := range tests {
+		if got := withStatementTimeout(tt.connStr, tt.timeout); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestConnect_AppliesStatementTimeout needs a reachable PostgreSQL server in
+// TEST_DATABASE_URL and is skipped otherwise
+func TestConnect_AppliesStatementTimeout(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", databaseURL)
+
+	db, err := Connect(config.DatabaseConfig{StatementTimeout: 1234 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	var timeout string
+	if err := db.QueryRow("SHOW statement_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("Failed to read statement_timeout: %v", err)
+	}
+	if timeout != "1234ms" {
+		t.Errorf("Expected statement_timeout 1234ms, got %s", timeout)
+	}
+}
+
+// TestRunMigrations_RestoresStatementTimeout needs a reachable PostgreSQL
+// server in TEST_DATABASE_URL and is skipped otherwise
+func TestRunMigrations_RestoresStatementTimeout(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", databaseURL)
+	t.Chdir("../..") // Migrations are read relative to the repository root
+
+	db, err := Connect(config.DatabaseConfig{StatementTimeout: 1234 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+	// One connection, so the migrations run on the one checked afterwards
+	db.SetMaxOpenConns(1)
+
+	if err := RunMigrations(db.DB); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	var timeout string
+	if err := db.QueryRow("SHOW statement_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("Failed to read statement_timeout: %v", err)
+	}
+	if timeout != "1234ms" {
+		t.Errorf("Expected the 1234ms statement_timeout restored after migrating, got %s", timeout)
+	}
+}
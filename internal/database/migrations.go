@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -10,10 +11,25 @@ import (
 	"strings"
 )
 
-// RunMigrations runs all SQL migration files from the migrations directory
+// RunMigrations runs all SQL migration files from the migrations directory.
+// They run on a single connection without the statement timeout applied to
+// regular queries, since a migration rewriting a large table can take longer.
 func RunMigrations(db *sql.DB) error {
 	log.Println("🔄 Running database migrations...")
 
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection for migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to lift the statement timeout for migrations: %w", err)
+	}
+	// Restore the connection's default timeout before it goes back to the pool
+	defer conn.ExecContext(ctx, "RESET statement_timeout")
+
 	// Create schema_migrations table to track executed migrations
 	createMigrationTable := `
 	CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -22,13 +38,13 @@ func RunMigrations(db *sql.DB) error {
 		executed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 	);`
 
-	if _, err := db.Exec(createMigrationTable); err != nil {
+	if _, err := conn.ExecContext(ctx, createMigrationTable); err != nil {
 		return fmt.Errorf("failed to create schema_migrations table: %w", err)
 	}
 
 	// Mark old migrations as executed if tables already exist (for existing databases)
 	var sensorTableExists bool
-	err := db.QueryRow(`
+	err = conn.QueryRowContext(ctx, `
 		SELECT EXISTS (
 			SELECT FROM information_schema.tables 
 			WHERE table_name = 'sensor_readings'
@@ -51,10 +67,10 @@ func RunMigrations(db *sql.DB) error {
 		
 		for _, filename := range oldMigrations {
 			var count int
-			db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE filename = $1", filename).Scan(&count)
+			conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE filename = $1", filename).Scan(&count)
 			
 			if count == 0 {
-				_, err := db.Exec("INSERT INTO schema_migrations (filename) VALUES ($1)", filename)
+				_, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (filename) VALUES ($1)", filename)
 				if err != nil {
 					log.Printf("⚠️  Warning: Could not mark %s as executed: %v", filename, err)
 				} else {
@@ -84,7 +100,7 @@ func RunMigrations(db *sql.DB) error {
 	for _, filename := range sqlFiles {
 		// Check if migration already executed
 		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE filename = $1", filename).Scan(&count)
+		err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE filename = $1", filename).Scan(&count)
 		if err != nil {
 			return fmt.Errorf("failed to check migration status for %s: %w", filename, err)
 		}
@@ -103,12 +119,12 @@ func RunMigrations(db *sql.DB) error {
 
 		// Execute migration
 		log.Printf("▶️  Running migration: %s", filename)
-		if _, err := db.Exec(string(content)); err != nil {
+		if _, err := conn.ExecContext(ctx, string(content)); err != nil {
 			return fmt.Errorf("failed to execute migration %s: %w", filename, err)
 		}
 
 		// Record migration as executed
-		_, err = db.Exec("INSERT INTO schema_migrations (filename) VALUES ($1)", filename)
+		_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (filename) VALUES ($1)", filename)
 		if err != nil {
 			return fmt.Errorf("failed to record migration %s: %w", filename, err)
 		}
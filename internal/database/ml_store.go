@@ -18,8 +18,8 @@ func (s *DatabaseStore) SaveAnomaly(anomaly *models.AnomalyDetection) error {
 		INSERT INTO anomaly_detections (
 			device_id, detected_at, anomaly_type, severity, affected_metric,
 			expected_value, actual_value, deviation, filter_mode, description,
-			is_false_positive, alert_sent, auto_resolved
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			is_false_positive, alert_sent, suppressed_count, auto_resolved
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING id, created_at`
 
 	err := s.db.QueryRow(
@@ -36,6 +36,7 @@ func (s *DatabaseStore) SaveAnomaly(anomaly *models.AnomalyDetection) error {
 		anomaly.Description,
 		anomaly.IsFalsePositive,
 		anomaly.AlertSent,
+		anomaly.SuppressedCount,
 		anomaly.AutoResolved,
 	).Scan(&anomaly.ID, &anomaly.CreatedAt)
 
@@ -52,7 +53,7 @@ func (s *DatabaseStore) GetAnomalies(limit int) ([]models.AnomalyDetection, erro
 	query := `
 		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
 			   expected_value, actual_value, deviation, filter_mode, description,
-			   is_false_positive, resolved_at, alert_sent, auto_resolved, created_at
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
 		FROM anomaly_detections
 		ORDER BY detected_at DESC
 		LIMIT $1`
@@ -66,12 +67,37 @@ func (s *DatabaseStore) GetAnomalies(limit int) ([]models.AnomalyDetection, erro
 	return s.scanAnomalies(rows)
 }
 
+// GetAnomaly retrieves a single anomaly by ID (nil when not found)
+func (s *DatabaseStore) GetAnomaly(id int) (*models.AnomalyDetection, error) {
+	query := `
+		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
+			   expected_value, actual_value, deviation, filter_mode, description,
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
+		FROM anomaly_detections
+		WHERE id = $1`
+
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomaly: %w", err)
+	}
+	defer rows.Close()
+
+	anomalies, err := s.scanAnomalies(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(anomalies) == 0 {
+		return nil, nil
+	}
+	return &anomalies[0], nil
+}
+
 // GetAnomaliesByDevice retrieves anomalies for a specific device
 func (s *DatabaseStore) GetAnomaliesByDevice(deviceID string, limit int) ([]models.AnomalyDetection, error) {
 	query := `
 		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
 			   expected_value, actual_value, deviation, filter_mode, description,
-			   is_false_positive, resolved_at, alert_sent, auto_resolved, created_at
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
 		FROM anomaly_detections
 		WHERE device_id = $1
 		ORDER BY detected_at DESC
@@ -91,7 +117,7 @@ func (s *DatabaseStore) GetAnomaliesBySeverity(severity string, limit int) ([]mo
 	query := `
 		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
 			   expected_value, actual_value, deviation, filter_mode, description,
-			   is_false_positive, resolved_at, alert_sent, auto_resolved, created_at
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
 		FROM anomaly_detections
 		WHERE severity = $1
 		ORDER BY detected_at DESC
@@ -106,12 +132,33 @@ func (s *DatabaseStore) GetAnomaliesBySeverity(severity string, limit int) ([]mo
 	return s.scanAnomalies(rows)
 }
 
+// GetAnomaliesInRange retrieves the anomalies detected between start and end
+// (inclusive), newest first, limited to filterMode unless it is empty
+func (s *DatabaseStore) GetAnomaliesInRange(start, end time.Time, filterMode models.FilterMode, limit int) ([]models.AnomalyDetection, error) {
+	query := `
+		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
+			   expected_value, actual_value, deviation, filter_mode, description,
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
+		FROM anomaly_detections
+		WHERE detected_at BETWEEN $1 AND $2 AND ($3 = '' OR filter_mode = $3)
+		ORDER BY detected_at DESC
+		LIMIT $4`
+
+	rows, err := s.db.Query(query, start, end, string(filterMode), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies in range: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanAnomalies(rows)
+}
+
 // GetUnresolvedAnomalies retrieves all unresolved anomalies
 func (s *DatabaseStore) GetUnresolvedAnomalies() ([]models.AnomalyDetection, error) {
 	query := `
 		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
 			   expected_value, actual_value, deviation, filter_mode, description,
-			   is_false_positive, resolved_at, alert_sent, auto_resolved, created_at
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
 		FROM anomaly_detections
 		WHERE resolved_at IS NULL AND is_false_positive = false
 		ORDER BY detected_at DESC`
@@ -125,11 +172,41 @@ func (s *DatabaseStore) GetUnresolvedAnomalies() ([]models.AnomalyDetection, err
 	return s.scanAnomalies(rows)
 }
 
-// ResolveAnomaly marks an anomaly as resolved
+// GetRecentlyResolvedAnomalies retrieves the latest resolved anomalies,
+// excluding false positives
+func (s *DatabaseStore) GetRecentlyResolvedAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	query := `
+		SELECT id, device_id, detected_at, anomaly_type, severity, affected_metric,
+			   expected_value, actual_value, deviation, filter_mode, description,
+			   is_false_positive, resolved_at, alert_sent, suppressed_count, auto_resolved, created_at
+		FROM anomaly_detections
+		WHERE resolved_at IS NOT NULL AND is_false_positive = false
+		ORDER BY resolved_at DESC
+		LIMIT $1`
+
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resolved anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanAnomalies(rows)
+}
+
+// ResolveAnomaly marks an anomaly as resolved by a user
 func (s *DatabaseStore) ResolveAnomaly(id int) error {
-	query := `UPDATE anomaly_detections SET resolved_at = NOW() WHERE id = $1`
+	return s.resolveAnomaly(id, false)
+}
 
-	result, err := s.db.Exec(query, id)
+// AutoResolveAnomaly marks an anomaly as resolved by the system
+func (s *DatabaseStore) AutoResolveAnomaly(id int) error {
+	return s.resolveAnomaly(id, true)
+}
+
+func (s *DatabaseStore) resolveAnomaly(id int, auto bool) error {
+	query := `UPDATE anomaly_detections SET resolved_at = NOW(), auto_resolved = $2 WHERE id = $1`
+
+	result, err := s.db.Exec(query, id, auto)
 	if err != nil {
 		return fmt.Errorf("failed to resolve anomaly: %w", err)
 	}
@@ -159,6 +236,23 @@ func (s *DatabaseStore) MarkAnomalyFalsePositive(id int) error {
 	return nil
 }
 
+// SetAnomalyAlertSent records whether an anomaly's alert was sent
+func (s *DatabaseStore) SetAnomalyAlertSent(id int, sent bool) error {
+	query := `UPDATE anomaly_detections SET alert_sent = $2 WHERE id = $1`
+
+	result, err := s.db.Exec(query, id, sent)
+	if err != nil {
+		return fmt.Errorf("failed to update anomaly alert status: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("anomaly not found")
+	}
+
+	return nil
+}
+
 // GetAnomalyStats calculates anomaly statistics
 func (s *DatabaseStore) GetAnomalyStats() (*models.AnomalyStats, error) {
 	stats := &models.AnomalyStats{
@@ -258,6 +352,42 @@ func (s *DatabaseStore) GetAnomalyStats() (*models.AnomalyStats, error) {
 	return stats, nil
 }
 
+// GetAnomalyOpsMetrics aggregates resolution counts and MTTR in a single query
+func (s *DatabaseStore) GetAnomalyOpsMetrics() (*models.AnomalyOpsMetrics, error) {
+	query := `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE resolved_at IS NOT NULL AND NOT is_false_positive),
+			COUNT(*) FILTER (WHERE resolved_at IS NULL AND NOT is_false_positive),
+			COUNT(*) FILTER (WHERE is_false_positive),
+			COUNT(*) FILTER (WHERE resolved_at IS NOT NULL AND NOT is_false_positive AND auto_resolved),
+			COALESCE(AVG(EXTRACT(EPOCH FROM (resolved_at - detected_at)))
+				FILTER (WHERE resolved_at IS NOT NULL AND NOT is_false_positive), 0)
+		FROM anomaly_detections
+	`
+
+	metrics := &models.AnomalyOpsMetrics{}
+	err := s.db.QueryRow(query).Scan(
+		&metrics.TotalAnomalies,
+		&metrics.Resolved,
+		&metrics.Unresolved,
+		&metrics.FalsePositives,
+		&metrics.AutoResolved,
+		&metrics.MeanTimeToResolution,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get anomaly ops metrics: %w", err)
+	}
+
+	metrics.ManuallyResolved = metrics.Resolved - metrics.AutoResolved
+	if metrics.TotalAnomalies > 0 {
+		metrics.ResolutionRate = float64(metrics.Resolved) / float64(metrics.TotalAnomalies) * 100
+		metrics.FalsePositiveRate = float64(metrics.FalsePositives) / float64(metrics.TotalAnomalies) * 100
+	}
+
+	return metrics, nil
+}
+
 // scanAnomalies is a helper to scan anomaly rows
 func (s *DatabaseStore) scanAnomalies(rows *sql.Rows) ([]models.AnomalyDetection, error) {
 	var anomalies []models.AnomalyDetection
@@ -279,6 +409,7 @@ func (s *DatabaseStore) scanAnomalies(rows *sql.Rows) ([]models.AnomalyDetection
 			&a.IsFalsePositive,
 			&a.ResolvedAt,
 			&a.AlertSent,
+			&a.SuppressedCount,
 			&a.AutoResolved,
 			&a.CreatedAt,
 		)
@@ -420,6 +551,15 @@ func (s *DatabaseStore) UpdateBaseline(baseline *models.SensorBaseline) error {
 
 // ML: Filter Health Methods
 
+// filterHealthColumns are the filter_health columns scanned by scanFilterHealth
+const filterHealthColumns = `
+	id, device_id, filter_mode, health_score, predicted_days_remaining,
+	estimated_replacement, current_efficiency, average_efficiency, efficiency_trend,
+	turbidity_reduction, tds_reduction, ph_stabilization,
+	turbidity_pairs_excluded, tds_pairs_excluded,
+	maintenance_required, replacement_urgent, recommendations,
+	last_calculated, created_at, updated_at`
+
 // SaveFilterHealth stores filter health assessment
 func (s *DatabaseStore) SaveFilterHealth(health *models.FilterHealth) error {
 	// Convert recommendations slice to JSON
@@ -433,9 +573,10 @@ func (s *DatabaseStore) SaveFilterHealth(health *models.FilterHealth) error {
 			device_id, filter_mode, health_score, predicted_days_remaining,
 			estimated_replacement, current_efficiency, average_efficiency, efficiency_trend,
 			turbidity_reduction, tds_reduction, ph_stabilization,
+			turbidity_pairs_excluded, tds_pairs_excluded,
 			maintenance_required, replacement_urgent, recommendations,
 			last_calculated, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 		RETURNING id`
 
 	err = s.db.QueryRow(
@@ -443,6 +584,7 @@ func (s *DatabaseStore) SaveFilterHealth(health *models.FilterHealth) error {
 		health.DeviceID, health.FilterMode, health.HealthScore, health.PredictedDaysRemaining,
 		health.EstimatedReplacement, health.CurrentEfficiency, health.AverageEfficiency, health.EfficiencyTrend,
 		health.TurbidityReduction, health.TDSReduction, health.PhStabilization,
+		health.TurbidityPairsExcluded, health.TDSPairsExcluded,
 		health.MaintenanceRequired, health.ReplacementUrgent, recsJSON,
 		health.LastCalculated, health.CreatedAt, health.UpdatedAt,
 	).Scan(&health.ID)
@@ -458,11 +600,7 @@ func (s *DatabaseStore) SaveFilterHealth(health *models.FilterHealth) error {
 // GetLatestFilterHealth retrieves the most recent filter health for a device
 func (s *DatabaseStore) GetLatestFilterHealth(deviceID string) (*models.FilterHealth, error) {
 	query := `
-		SELECT id, device_id, filter_mode, health_score, predicted_days_remaining,
-			   estimated_replacement, current_efficiency, average_efficiency, efficiency_trend,
-			   turbidity_reduction, tds_reduction, ph_stabilization,
-			   maintenance_required, replacement_urgent, recommendations,
-			   last_calculated, created_at, updated_at
+		SELECT `+filterHealthColumns+`
 		FROM filter_health
 		WHERE device_id = $1
 		ORDER BY last_calculated DESC
@@ -475,6 +613,7 @@ func (s *DatabaseStore) GetLatestFilterHealth(deviceID string) (*models.FilterHe
 		&health.ID, &health.DeviceID, &health.FilterMode, &health.HealthScore, &health.PredictedDaysRemaining,
 		&health.EstimatedReplacement, &health.CurrentEfficiency, &health.AverageEfficiency, &health.EfficiencyTrend,
 		&health.TurbidityReduction, &health.TDSReduction, &health.PhStabilization,
+		&health.TurbidityPairsExcluded, &health.TDSPairsExcluded,
 		&health.MaintenanceRequired, &health.ReplacementUrgent, &recsJSON,
 		&health.LastCalculated, &health.CreatedAt, &health.UpdatedAt,
 	)
@@ -497,11 +636,7 @@ func (s *DatabaseStore) GetLatestFilterHealth(deviceID string) (*models.FilterHe
 // GetFilterHealthHistory retrieves filter health history
 func (s *DatabaseStore) GetFilterHealthHistory(deviceID string, limit int) ([]models.FilterHealth, error) {
 	query := `
-		SELECT id, device_id, filter_mode, health_score, predicted_days_remaining,
-			   estimated_replacement, current_efficiency, average_efficiency, efficiency_trend,
-			   turbidity_reduction, tds_reduction, ph_stabilization,
-			   maintenance_required, replacement_urgent, recommendations,
-			   last_calculated, created_at, updated_at
+		SELECT `+filterHealthColumns+`
 		FROM filter_health
 		WHERE device_id = $1
 		ORDER BY last_calculated DESC
@@ -516,14 +651,29 @@ func (s *DatabaseStore) GetFilterHealthHistory(deviceID string, limit int) ([]mo
 	return s.scanFilterHealth(rows)
 }
 
+// GetFilterHealthInRange retrieves a device's filter health records calculated
+// between start and end (inclusive), newest first, limited to filterMode unless it is empty
+func (s *DatabaseStore) GetFilterHealthInRange(deviceID string, start, end time.Time, filterMode models.FilterMode, limit int) ([]models.FilterHealth, error) {
+	query := `
+		SELECT `+filterHealthColumns+`
+		FROM filter_health
+		WHERE device_id = $1 AND last_calculated BETWEEN $2 AND $3 AND ($4 = '' OR filter_mode = $4)
+		ORDER BY last_calculated DESC
+		LIMIT $5`
+
+	rows, err := s.db.Query(query, deviceID, start, end, string(filterMode), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query filter health in range: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanFilterHealth(rows)
+}
+
 // GetAllFilterHealth retrieves all filter health records
 func (s *DatabaseStore) GetAllFilterHealth() ([]models.FilterHealth, error) {
 	query := `
-		SELECT id, device_id, filter_mode, health_score, predicted_days_remaining,
-			   estimated_replacement, current_efficiency, average_efficiency, efficiency_trend,
-			   turbidity_reduction, tds_reduction, ph_stabilization,
-			   maintenance_required, replacement_urgent, recommendations,
-			   last_calculated, created_at, updated_at
+		SELECT `+filterHealthColumns+`
 		FROM filter_health
 		ORDER BY last_calculated DESC`
 
@@ -548,6 +698,7 @@ func (s *DatabaseStore) scanFilterHealth(rows *sql.Rows) ([]models.FilterHealth,
 			&h.ID, &h.DeviceID, &h.FilterMode, &h.HealthScore, &h.PredictedDaysRemaining,
 			&h.EstimatedReplacement, &h.CurrentEfficiency, &h.AverageEfficiency, &h.EfficiencyTrend,
 			&h.TurbidityReduction, &h.TDSReduction, &h.PhStabilization,
+			&h.TurbidityPairsExcluded, &h.TDSPairsExcluded,
 			&h.MaintenanceRequired, &h.ReplacementUrgent, &recsJSON,
 			&h.LastCalculated, &h.CreatedAt, &h.UpdatedAt,
 		)
@@ -659,3 +810,254 @@ func (s *DatabaseStore) scanPredictions(rows *sql.Rows) ([]models.MLPrediction,
 
 	return predictions, nil
 }
+
+// ML: Sensor Prediction Methods
+
+// sensorPredictionColumns are the sensor_predictions columns scanned by scanSensorPredictions
+const sensorPredictionColumns = `
+	id, device_id, filter_mode, predicted_for, COALESCE(prediction_method, ''), COALESCE(confidence_score, 0),
+	COALESCE(predicted_flow, 0), COALESCE(predicted_ph, 0), COALESCE(predicted_turbidity, 0), COALESCE(predicted_tds, 0),
+	actual_flow, actual_ph, actual_turbidity, actual_tds,
+	flow_error, ph_error, turbidity_error, tds_error, overall_accuracy,
+	COALESCE(is_validated, false), validated_at, created_at, updated_at`
+
+// SaveSensorPrediction stores a sensor prediction, replacing an unvalidated
+// prediction for the same device, mode and time; a validated one is kept
+func (s *DatabaseStore) SaveSensorPrediction(prediction *models.SensorPrediction) error {
+	query := `
+		INSERT INTO sensor_predictions (
+			device_id, filter_mode, predicted_for, prediction_method, confidence_score,
+			predicted_flow, predicted_ph, predicted_turbidity, predicted_tds
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (device_id, filter_mode, predicted_for) DO UPDATE SET
+			prediction_method = EXCLUDED.prediction_method,
+			confidence_score = EXCLUDED.confidence_score,
+			predicted_flow = EXCLUDED.predicted_flow,
+			predicted_ph = EXCLUDED.predicted_ph,
+			predicted_turbidity = EXCLUDED.predicted_turbidity,
+			predicted_tds = EXCLUDED.predicted_tds,
+			updated_at = NOW()
+		WHERE sensor_predictions.is_validated = false
+		RETURNING id, created_at, updated_at`
+
+	err := s.db.QueryRow(
+		query,
+		prediction.DeviceID,
+		prediction.FilterMode,
+		prediction.PredictedFor,
+		prediction.PredictionMethod,
+		prediction.ConfidenceScore,
+		prediction.PredictedFlow,
+		prediction.PredictedPh,
+		prediction.PredictedTurbidity,
+		prediction.PredictedTDS,
+	).Scan(&prediction.ID, &prediction.CreatedAt, &prediction.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// The prediction for this time was already validated
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save sensor prediction: %w", err)
+	}
+
+	return nil
+}
+
+// GetSensorPredictions retrieves a device's predictions for times between start
+// and end (inclusive), oldest first
+func (s *DatabaseStore) GetSensorPredictions(deviceID string, start, end time.Time) ([]models.SensorPrediction, error) {
+	query := `SELECT ` + sensorPredictionColumns + `
+		FROM sensor_predictions
+		WHERE device_id = $1 AND predicted_for BETWEEN $2 AND $3
+		ORDER BY predicted_for ASC, id ASC`
+
+	rows, err := s.db.Query(query, deviceID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor predictions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSensorPredictions(rows)
+}
+
+// GetConfidentSensorPredictions retrieves up to limit of a device's predictions
+// for times between start and end (inclusive) whose confidence score is at
+// least minConfidence, oldest first
+func (s *DatabaseStore) GetConfidentSensorPredictions(deviceID string, minConfidence float64, start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	query := `SELECT ` + sensorPredictionColumns + `
+		FROM sensor_predictions
+		WHERE device_id = $1 AND confidence_score >= $2 AND predicted_for BETWEEN $3 AND $4
+		ORDER BY predicted_for ASC, id ASC
+		LIMIT $5`
+
+	rows, err := s.db.Query(query, deviceID, minConfidence, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query confident sensor predictions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSensorPredictions(rows)
+}
+
+// GetUnvalidatedSensorPredictions retrieves predictions for times between start
+// and end (inclusive) that have not been validated yet, oldest first
+func (s *DatabaseStore) GetUnvalidatedSensorPredictions(start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	query := `SELECT ` + sensorPredictionColumns + `
+		FROM sensor_predictions
+		WHERE is_validated = false AND predicted_for BETWEEN $1 AND $2
+		ORDER BY predicted_for ASC, id ASC
+		LIMIT $3`
+
+	rows, err := s.db.Query(query, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unvalidated sensor predictions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSensorPredictions(rows)
+}
+
+// UpdateSensorPredictionActuals records the actual values, errors and accuracy
+// of a validated prediction
+func (s *DatabaseStore) UpdateSensorPredictionActuals(prediction *models.SensorPrediction) error {
+	query := `
+		UPDATE sensor_predictions SET
+			actual_flow = $2, actual_ph = $3, actual_turbidity = $4, actual_tds = $5,
+			flow_error = $6, ph_error = $7, turbidity_error = $8, tds_error = $9,
+			overall_accuracy = $10, is_validated = $11, validated_at = $12, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := s.db.Exec(
+		query,
+		prediction.ID,
+		prediction.ActualFlow,
+		prediction.ActualPh,
+		prediction.ActualTurbidity,
+		prediction.ActualTDS,
+		prediction.FlowError,
+		prediction.PhError,
+		prediction.TurbidityError,
+		prediction.TDSError,
+		prediction.OverallAccuracy,
+		prediction.IsValidated,
+		prediction.ValidatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update sensor prediction: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("sensor prediction %d not found", prediction.ID)
+	}
+
+	return nil
+}
+
+// scanSensorPredictions scans rows selected with sensorPredictionColumns
+func scanSensorPredictions(rows *sql.Rows) ([]models.SensorPrediction, error) {
+	predictions := []models.SensorPrediction{}
+
+	for rows.Next() {
+		var p models.SensorPrediction
+		err := rows.Scan(
+			&p.ID, &p.DeviceID, &p.FilterMode, &p.PredictedFor, &p.PredictionMethod, &p.ConfidenceScore,
+			&p.PredictedFlow, &p.PredictedPh, &p.PredictedTurbidity, &p.PredictedTDS,
+			&p.ActualFlow, &p.ActualPh, &p.ActualTurbidity, &p.ActualTDS,
+			&p.FlowError, &p.PhError, &p.TurbidityError, &p.TDSError, &p.OverallAccuracy,
+			&p.IsValidated, &p.ValidatedAt, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor prediction: %w", err)
+		}
+		predictions = append(predictions, p)
+	}
+
+	return predictions, rows.Err()
+}
+
+// ML: Prediction Accuracy Methods
+
+// SavePredictionAccuracySummary stores or updates the accuracy summary for a device/mode/period
+func (s *DatabaseStore) SavePredictionAccuracySummary(summary *models.PredictionAccuracySummary) error {
+	query := `
+		INSERT INTO prediction_accuracy_summary (
+			device_id, filter_mode, period_start, period_end,
+			total_predictions, validated_predictions,
+			avg_flow_accuracy, avg_ph_accuracy, avg_turbidity_accuracy, avg_tds_accuracy, overall_accuracy,
+			model_version, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		ON CONFLICT (device_id, filter_mode, period_start) DO UPDATE SET
+			period_end = EXCLUDED.period_end,
+			total_predictions = EXCLUDED.total_predictions,
+			validated_predictions = EXCLUDED.validated_predictions,
+			avg_flow_accuracy = EXCLUDED.avg_flow_accuracy,
+			avg_ph_accuracy = EXCLUDED.avg_ph_accuracy,
+			avg_turbidity_accuracy = EXCLUDED.avg_turbidity_accuracy,
+			avg_tds_accuracy = EXCLUDED.avg_tds_accuracy,
+			overall_accuracy = EXCLUDED.overall_accuracy,
+			model_version = EXCLUDED.model_version,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+
+	modelVersion := summary.ModelVersion
+	if modelVersion == "" {
+		modelVersion = "v1.0"
+	}
+
+	err := s.db.QueryRow(
+		query,
+		summary.DeviceID, summary.FilterMode, summary.PeriodStart, summary.PeriodEnd,
+		summary.TotalPredictions, summary.ValidatedPredictions,
+		summary.AvgFlowAccuracy, summary.AvgPhAccuracy, summary.AvgTurbidityAccuracy, summary.AvgTDSAccuracy, summary.OverallAccuracy,
+		modelVersion,
+	).Scan(&summary.ID, &summary.CreatedAt, &summary.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save prediction accuracy summary: %w", err)
+	}
+
+	return nil
+}
+
+// GetPredictionAccuracyHistory retrieves the most recent accuracy summaries in chronological order
+// An empty deviceID returns summaries for all devices
+func (s *DatabaseStore) GetPredictionAccuracyHistory(deviceID string, limit int) ([]models.PredictionAccuracySummary, error) {
+	query := `
+		SELECT * FROM (
+			SELECT id, device_id, filter_mode, period_start, period_end,
+				   total_predictions, validated_predictions,
+				   COALESCE(avg_flow_accuracy, 0), COALESCE(avg_ph_accuracy, 0),
+				   COALESCE(avg_turbidity_accuracy, 0), COALESCE(avg_tds_accuracy, 0),
+				   COALESCE(overall_accuracy, 0), COALESCE(model_version, ''),
+				   created_at, updated_at
+			FROM prediction_accuracy_summary
+			WHERE ($1 = '' OR device_id = $1)
+			ORDER BY period_start DESC
+			LIMIT $2
+		) recent
+		ORDER BY period_start ASC`
+
+	rows, err := s.db.Query(query, deviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prediction accuracy history: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.PredictionAccuracySummary{}
+	for rows.Next() {
+		var summary models.PredictionAccuracySummary
+		err := rows.Scan(
+			&summary.ID, &summary.DeviceID, &summary.FilterMode, &summary.PeriodStart, &summary.PeriodEnd,
+			&summary.TotalPredictions, &summary.ValidatedPredictions,
+			&summary.AvgFlowAccuracy, &summary.AvgPhAccuracy,
+			&summary.AvgTurbidityAccuracy, &summary.AvgTDSAccuracy,
+			&summary.OverallAccuracy, &summary.ModelVersion,
+			&summary.CreatedAt, &summary.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prediction accuracy summary: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
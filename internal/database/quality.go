@@ -0,0 +1,114 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// qualityRecomputeBatch is how many stale readings are re-assessed per round trip
+const qualityRecomputeBatch = 500
+
+// qualityColumn scans the nullable sensor_readings.quality JSONB column into a
+// reading's stored quality assessment
+type qualityColumn struct {
+	quality **models.ReadingQuality
+}
+
+// Scan implements sql.Scanner
+func (c qualityColumn) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*c.quality = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported quality column type %T", src)
+	}
+
+	var quality models.ReadingQuality
+	if err := json.Unmarshal(raw, &quality); err != nil {
+		return fmt.Errorf("failed to decode stored quality: %w", err)
+	}
+	*c.quality = &quality
+	return nil
+}
+
+// qualityValue encodes a stored quality assessment for the quality column
+func qualityValue(quality *models.ReadingQuality) (interface{}, error) {
+	if quality == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(quality)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// RecomputeReadingQuality re-assesses readings whose stored quality is missing
+// or was computed under other quality rules, in batches, returning how many
+// were updated. Each reading is assessed with the thresholds of its filter mode.
+func (s *DatabaseStore) RecomputeReadingQuality() (int, error) {
+	version := models.QualityRulesVersion()
+	selectQuery := `
+		SELECT device_id, timestamp, filter_mode, ph, turbidity, tds
+		FROM sensor_readings
+		WHERE quality IS NULL OR quality->>'rules_version' IS DISTINCT FROM $1
+		LIMIT $2`
+	updateQuery := `UPDATE sensor_readings SET quality = $3 WHERE device_id = $1 AND timestamp = $2`
+
+	updated := 0
+	for {
+		rows, err := s.db.Query(selectQuery, version, qualityRecomputeBatch)
+		if err != nil {
+			return updated, fmt.Errorf("failed to get readings with stale quality: %w", err)
+		}
+
+		var batch []models.SensorReading
+		for rows.Next() {
+			var reading models.SensorReading
+			if err := rows.Scan(&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Ph, &reading.Turbidity, &reading.TDS); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("failed to scan reading: %w", err)
+			}
+			batch = append(batch, reading)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, fmt.Errorf("failed to get readings with stale quality: %w", err)
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return updated, fmt.Errorf("failed to begin quality update: %w", err)
+		}
+		for i := range batch {
+			batch[i].StampQuality()
+			value, err := qualityValue(batch[i].Quality)
+			if err == nil {
+				_, err = tx.Exec(updateQuery, batch[i].DeviceID, batch[i].Timestamp, value)
+			}
+			if err != nil {
+				tx.Rollback()
+				return updated, fmt.Errorf("failed to update reading quality: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return updated, fmt.Errorf("failed to commit quality update: %w", err)
+		}
+		updated += len(batch)
+
+		if len(batch) < qualityRecomputeBatch {
+			return updated, nil
+		}
+	}
+}
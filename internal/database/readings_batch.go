@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// insertReadingsQuery starts an INSERT of readings; the VALUES rows follow
+const insertReadingsQuery = `
+		INSERT INTO sensor_readings (device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality)
+		VALUES `
+
+// upsertReadingsConflict makes a reading for a device and timestamp already
+// stored replace it
+const upsertReadingsConflict = `
+		ON CONFLICT (device_id, timestamp) DO UPDATE SET
+			filter_mode = EXCLUDED.filter_mode,
+			flow = EXCLUDED.flow,
+			ph = EXCLUDED.ph,
+			turbidity = EXCLUDED.turbidity,
+			tds = EXCLUDED.tds,
+			server_received_at = EXCLUDED.server_received_at,
+			quality = EXCLUDED.quality`
+
+// readingColumns is how many values each reading binds in insertReadingsQuery
+const readingColumns = 9
+
+// readingBatchSize is how many readings go into one multi-row INSERT, well
+// under PostgreSQL's limit of 65535 bind parameters per statement
+const readingBatchSize = 1000
+
+// readingKey identifies the row a reading upserts; PostgreSQL keeps
+// timestamps to the microsecond
+type readingKey struct {
+	deviceID  string
+	timestamp int64
+}
+
+// AddSensorReadingsBatch stores readings with multi-row INSERTs in one
+// transaction and then updates each device's status once, rather than the
+// INSERT and status updates AddSensorReading makes per reading. As with
+// AddSensorReading, a reading for a device and timestamp already stored
+// replaces it; within the batch the last one wins. Nothing is stored when an
+// error is returned.
+func (s *DatabaseStore) AddSensorReadingsBatch(readings []models.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	// One statement can't upsert the same row twice, so keep the last reading per row
+	rows := make([]models.SensorReading, 0, len(readings))
+	rowIndex := make(map[readingKey]int, len(readings))
+	for _, reading := range readings {
+		reading.StampQuality()
+		key := readingKey{reading.DeviceID, reading.Timestamp.Round(time.Microsecond).UnixNano()}
+		if i, exists := rowIndex[key]; exists {
+			rows[i] = reading
+			continue
+		}
+		rowIndex[key] = len(rows)
+		rows = append(rows, reading)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin sensor reading batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(rows); start += readingBatchSize {
+		end := start + readingBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := insertReadings(tx, rows[start:end]); err != nil {
+			return err
+		}
+	}
+	if err := updateDeviceStatusesForBatch(tx, readings); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sensor reading batch: %w", err)
+	}
+	s.notifier.Notify()
+	return nil
+}
+
+// insertReadings upserts the readings with a single multi-row INSERT
+func insertReadings(tx *sql.Tx, readings []models.SensorReading) error {
+	var values strings.Builder
+	args := make([]interface{}, 0, len(readings)*readingColumns)
+	for i, reading := range readings {
+		quality, err := qualityValue(reading.Quality)
+		if err != nil {
+			return fmt.Errorf("failed to encode reading quality: %w", err)
+		}
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		values.WriteString("(")
+		for column := 1; column <= readingColumns; column++ {
+			if column > 1 {
+				values.WriteString(", ")
+			}
+			fmt.Fprintf(&values, "$%d", i*readingColumns+column)
+		}
+		values.WriteString(")")
+		args = append(args, reading.DeviceID, reading.Timestamp, reading.FilterMode,
+			reading.Flow, reading.Ph, reading.Turbidity, reading.TDS, reading.ServerReceivedAt, quality)
+	}
+
+	if _, err := tx.Exec(insertReadingsQuery+values.String()+upsertReadingsConflict, args...); err != nil {
+		return fmt.Errorf("failed to store sensor readings: %w", err)
+	}
+	return nil
+}
+
+// updateDeviceStatusesForBatch does what updateDeviceStatus and accumulateFlow
+// do for each reading in turn, with two statements per device
+func updateDeviceStatusesForBatch(tx *sql.Tx, readings []models.SensorReading) error {
+	var devices []string
+	byDevice := make(map[string][]models.SensorReading)
+	for _, reading := range readings {
+		if _, seen := byDevice[reading.DeviceID]; !seen {
+			devices = append(devices, reading.DeviceID)
+		}
+		byDevice[reading.DeviceID] = append(byDevice[reading.DeviceID], reading)
+	}
+
+	for _, deviceID := range devices {
+		deviceReadings := byDevice[deviceID]
+
+		var lastUpdate sql.NullTime
+		var totalFlow sql.NullFloat64
+		err := tx.QueryRow(`
+			INSERT INTO device_status (device_id, last_seen, total_readings, updated_at)
+			VALUES ($1, NOW(), $2, NOW())
+			ON CONFLICT (device_id) DO UPDATE SET
+				last_seen = NOW(),
+				total_readings = device_status.total_readings + EXCLUDED.total_readings,
+				updated_at = NOW()
+			RETURNING last_flow_update_at, total_flow_liters`,
+			deviceID, len(deviceReadings)).Scan(&lastUpdate, &totalFlow)
+		if err != nil {
+			return fmt.Errorf("failed to update device status for %s: %w", deviceID, err)
+		}
+
+		// Accumulate flow between consecutive readings like accumulateFlow
+		flow := totalFlow.Float64
+		for _, reading := range deviceReadings {
+			if lastUpdate.Valid {
+				if minutes := reading.Timestamp.Sub(lastUpdate.Time).Minutes(); store.FlowGapCounts(minutes) {
+					flow += reading.Flow * minutes
+				}
+			}
+			lastUpdate = sql.NullTime{Time: reading.Timestamp, Valid: true}
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE device_status
+			SET total_flow_liters = $1,
+			    last_flow_update_at = $2
+			WHERE device_id = $3`,
+			flow, lastUpdate.Time, deviceID); err != nil {
+			return fmt.Errorf("failed to update flow accumulation for %s: %w", deviceID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// defaultPruneBatchSize is how many rows one DELETE removes when no batch size
+// is given
+const defaultPruneBatchSize = 5000
+
+// PruneDataBefore deletes sensor readings, anomalies and sensor predictions
+// older than cutoff. Each DELETE removes at most batchSize rows in its own
+// transaction, so ingestion isn't blocked behind one long-running delete.
+// Device status, baselines and filter health are kept.
+func (s *DatabaseStore) PruneDataBefore(cutoff time.Time, batchSize int) (*models.PruneResult, error) {
+	if batchSize <= 0 {
+		batchSize = defaultPruneBatchSize
+	}
+
+	result := &models.PruneResult{Cutoff: cutoff}
+	tables := []struct {
+		table   string
+		column  string
+		deleted *int
+	}{
+		{"sensor_readings", "timestamp", &result.SensorReadings},
+		{"anomaly_detections", "detected_at", &result.Anomalies},
+		{"sensor_predictions", "predicted_for", &result.SensorPredictions},
+	}
+	for _, t := range tables {
+		deleted, err := s.deleteInBatches(t.table, t.column, cutoff, batchSize)
+		*t.deleted = deleted
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// deleteInBatches deletes the rows of table whose column is before cutoff,
+// batchSize rows at a time, and returns how many were deleted
+func (s *DatabaseStore) deleteInBatches(table, column string, cutoff time.Time, batchSize int) (int, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[2]s < $1 LIMIT $2)`, table, column)
+
+	total := 0
+	for {
+		res, err := s.db.Exec(query, cutoff, batchSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("failed to count pruned %s: %w", table, err)
+		}
+		total += int(deleted)
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// sensorStatsFilter restricts the stats queries to one filter mode, or to none
+// when $1 is empty
+const sensorStatsFilter = `($1::text = '' OR filter_mode = $1)`
+
+// metricStatKeys name the aggregates metricAggregates selects, in order
+var metricStatKeys = []string{"min", "max", "average", "stddev", "median", "p95"}
+
+// metricAggregates selects the statistics of a sensor_readings column, in
+// metricStatKeys order
+func metricAggregates(column string) string {
+	return fmt.Sprintf(`MIN(%[1]s), MAX(%[1]s), AVG(%[1]s), COALESCE(STDDEV_SAMP(%[1]s), 0),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY %[1]s::float8),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY %[1]s::float8)`, column)
+}
+
+// GetSensorDataStats aggregates the statistics of the readings in the given
+// filter mode ("" = every mode) in SQL, so the cost doesn't grow with what has
+// to be loaded. Returns nil when there are no readings.
+func (s *DatabaseStore) GetSensorDataStats(filterMode models.FilterMode) (*models.SensorDataStats, error) {
+	query := `
+		SELECT COUNT(*), MIN(timestamp), MAX(timestamp),
+			` + metricAggregates("ph") + `,
+			` + metricAggregates("tds") + `,
+			` + metricAggregates("turbidity") + `,
+			` + metricAggregates("flow") + `
+		FROM sensor_readings
+		WHERE ` + sensorStatsFilter + `
+		HAVING COUNT(*) > 0`
+
+	stats := &models.SensorDataStats{
+		DateRange:        make(map[string]string),
+		FilterModes:      make(map[string]int),
+		QualityBreakdown: make(map[string]int),
+	}
+	metrics := []*map[string]float64{&stats.PhStats, &stats.TDSStats, &stats.TurbidityStats, &stats.FlowStats}
+	var earliest, latest time.Time
+	values := make([]float64, len(metrics)*len(metricStatKeys))
+	dest := []interface{}{&stats.TotalReadings, &earliest, &latest}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	err := s.db.QueryRow(query, string(filterMode)).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sensor data stats: %w", err)
+	}
+
+	stats.DateRange["earliest"] = earliest.Format(models.SensorDataStatsTimeFormat)
+	stats.DateRange["latest"] = latest.Format(models.SensorDataStatsTimeFormat)
+	for i, metric := range metrics {
+		*metric = make(map[string]float64, len(metricStatKeys))
+		for j, key := range metricStatKeys {
+			(*metric)[key] = values[i*len(metricStatKeys)+j]
+		}
+	}
+
+	if err := s.countSensorStatsGroups(stats, filterMode); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// countSensorStatsGroups fills in the readings per filter mode and per overall
+// quality, both counted in SQL. Readings whose stored assessment is missing or
+// was made under other rules are counted as pending instead of being loaded
+// and assessed again; the quality backfill soon gives them a current one.
+func (s *DatabaseStore) countSensorStatsGroups(stats *models.SensorDataStats, filterMode models.FilterMode) error {
+	counts := []struct {
+		query  string
+		args   []interface{}
+		target map[string]int
+	}{
+		{
+			query: `SELECT filter_mode, COUNT(*) FROM sensor_readings
+				WHERE ` + sensorStatsFilter + ` GROUP BY filter_mode`,
+			args:   []interface{}{string(filterMode)},
+			target: stats.FilterModes,
+		},
+		{
+			query: `SELECT CASE WHEN quality->>'rules_version' = $2 THEN quality->>'overall_quality' ELSE $3 END, COUNT(*)
+				FROM sensor_readings
+				WHERE ` + sensorStatsFilter + ` GROUP BY 1`,
+			args:   []interface{}{string(filterMode), models.QualityRulesVersion(), models.QualityPendingLabel},
+			target: stats.QualityBreakdown,
+		},
+	}
+	for _, count := range counts {
+		rows, err := s.db.Query(count.query, count.args...)
+		if err != nil {
+			return fmt.Errorf("failed to count sensor data stats groups: %w", err)
+		}
+		for rows.Next() {
+			var group string
+			var n int
+			if err := rows.Scan(&group, &n); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan sensor data stats group: %w", err)
+			}
+			count.target[group] += n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to count sensor data stats groups: %w", err)
+		}
+	}
+	return nil
+}
@@ -1,23 +1,34 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
 )
 
 // DatabaseStore implements persistent storage using PostgreSQL
 type DatabaseStore struct {
-	db *sql.DB
+	db         *sql.DB
+	notifier   *store.ReadingNotifier // Wakes long-poll waiters on new readings
+	ledMu      sync.Mutex
+	ledCommand string // LED state the STM32 picks up when it polls
 }
 
 // NewDatabaseStore creates a new database store
 func NewDatabaseStore(db *sql.DB) *DatabaseStore {
-	return &DatabaseStore{db: db}
+	return &DatabaseStore{
+		db:         db,
+		notifier:   store.NewReadingNotifier(),
+		ledCommand: models.LEDCommandOff,
+	}
 }
 
 // Ping checks if database connection is alive
@@ -27,18 +38,18 @@ func (s *DatabaseStore) Ping() error {
 
 // AddSensorReading stores a sensor reading in the database
 func (s *DatabaseStore) AddSensorReading(reading models.SensorReading) {
-	query := `
-		INSERT INTO sensor_readings (device_id, timestamp, filter_mode, flow, ph, turbidity, tds)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (device_id, timestamp) DO UPDATE SET
-			filter_mode = EXCLUDED.filter_mode,
-			flow = EXCLUDED.flow,
-			ph = EXCLUDED.ph,
-			turbidity = EXCLUDED.turbidity,
-			tds = EXCLUDED.tds`
-
-	_, err := s.db.Exec(query, reading.DeviceID, reading.Timestamp, reading.FilterMode,
-		reading.Flow, reading.Ph, reading.Turbidity, reading.TDS)
+	query := insertReadingsQuery + `($1, $2, $3, $4, $5, $6, $7, $8, $9)` + upsertReadingsConflict
+
+	// Store the quality assessment with the reading
+	reading.StampQuality()
+	quality, err := qualityValue(reading.Quality)
+	if err != nil {
+		log.Printf("❌ Error encoding reading quality: %v", err)
+		return
+	}
+
+	_, err = s.db.Exec(query, reading.DeviceID, reading.Timestamp, reading.FilterMode,
+		reading.Flow, reading.Ph, reading.Turbidity, reading.TDS, reading.ServerReceivedAt, quality)
 	if err != nil {
 		log.Printf("❌ Error storing sensor reading: %v", err)
 		return
@@ -47,6 +58,13 @@ func (s *DatabaseStore) AddSensorReading(reading models.SensorReading) {
 	// Update device status (last_seen, total_readings) and accumulate flow
 	s.updateDeviceStatus(reading.DeviceID)
 	s.accumulateFlow(reading.DeviceID, reading.Flow, reading.Timestamp)
+
+	s.notifier.Notify()
+}
+
+// WaitForNewReading blocks until a reading newer than since is stored or ctx is done
+func (s *DatabaseStore) WaitForNewReading(ctx context.Context, since time.Time) (*models.SensorReading, bool) {
+	return s.notifier.Wait(ctx, since, s.GetLatestReading)
 }
 
 // updateDeviceStatus updates the device status when new data arrives
@@ -99,7 +117,7 @@ func (s *DatabaseStore) accumulateFlow(deviceID string, currentFlowRate float64,
 	timeDiff := timestamp.Sub(*lastUpdate).Minutes()
 	
 	// Avoid negative time or too large gaps (max 5 minutes between readings)
-	if timeDiff < 0 || timeDiff > 5 {
+	if !store.FlowGapCounts(timeDiff) {
 		log.Printf("⚠️  Unusual time gap for flow calculation: %.2f minutes", timeDiff)
 		updateQuery := `
 			UPDATE device_status 
@@ -132,7 +150,7 @@ func (s *DatabaseStore) accumulateFlow(deviceID string, currentFlowRate float64,
 // GetLatestReading returns the most recent sensor reading
 func (s *DatabaseStore) GetLatestReading() (*models.SensorReading, bool) {
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		ORDER BY timestamp DESC
 		LIMIT 1`
@@ -140,7 +158,7 @@ func (s *DatabaseStore) GetLatestReading() (*models.SensorReading, bool) {
 	var reading models.SensorReading
 	err := s.db.QueryRow(query).Scan(
 		&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-		&reading.Ph, &reading.Turbidity, &reading.TDS)
+		&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 
 	if err == sql.ErrNoRows {
 		return nil, false
@@ -156,7 +174,7 @@ func (s *DatabaseStore) GetLatestReading() (*models.SensorReading, bool) {
 // GetLatestReadingByMode returns the most recent reading for a specific filter mode
 func (s *DatabaseStore) GetLatestReadingByMode(mode models.FilterMode) (*models.SensorReading, bool) {
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE filter_mode = $1
 		ORDER BY timestamp DESC
@@ -165,7 +183,7 @@ func (s *DatabaseStore) GetLatestReadingByMode(mode models.FilterMode) (*models.
 	var reading models.SensorReading
 	err := s.db.QueryRow(query, string(mode)).Scan(
 		&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-		&reading.Ph, &reading.Turbidity, &reading.TDS)
+		&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 
 	if err == sql.ErrNoRows {
 		return nil, false
@@ -181,7 +199,7 @@ func (s *DatabaseStore) GetLatestReadingByMode(mode models.FilterMode) (*models.
 // GetLatestReadingByDevice returns the most recent reading for a specific device
 func (s *DatabaseStore) GetLatestReadingByDevice(deviceID string) (*models.SensorReading, bool) {
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE device_id = $1
 		ORDER BY timestamp DESC
@@ -190,7 +208,7 @@ func (s *DatabaseStore) GetLatestReadingByDevice(deviceID string) (*models.Senso
 	var reading models.SensorReading
 	err := s.db.QueryRow(query, deviceID).Scan(
 		&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-		&reading.Ph, &reading.Turbidity, &reading.TDS)
+		&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 
 	if err == sql.ErrNoRows {
 		return nil, false
@@ -207,7 +225,7 @@ func (s *DatabaseStore) GetLatestReadingByDevice(deviceID string) (*models.Senso
 func (s *DatabaseStore) GetAllLatestReadingsByDevice() map[string]models.SensorReading {
 	query := `
 		SELECT DISTINCT ON (device_id)
-			device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+			device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		ORDER BY device_id, timestamp DESC`
 
@@ -223,7 +241,7 @@ func (s *DatabaseStore) GetAllLatestReadingsByDevice() map[string]models.SensorR
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -258,7 +276,7 @@ func (s *DatabaseStore) GetRecentReadings(limit int) []models.SensorReading {
 	}
 
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		ORDER BY timestamp DESC
 		LIMIT $1`
@@ -275,7 +293,7 @@ func (s *DatabaseStore) GetRecentReadings(limit int) []models.SensorReading {
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -293,7 +311,7 @@ func (s *DatabaseStore) GetRecentReadingsByMode(mode models.FilterMode, limit in
 	}
 
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE filter_mode = $1
 		ORDER BY timestamp DESC
@@ -311,7 +329,7 @@ func (s *DatabaseStore) GetRecentReadingsByMode(mode models.FilterMode, limit in
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -329,7 +347,7 @@ func (s *DatabaseStore) GetRecentReadingsByDevice(deviceID string, limit int) []
 	}
 
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE device_id = $1
 		ORDER BY timestamp DESC
@@ -347,7 +365,7 @@ func (s *DatabaseStore) GetRecentReadingsByDevice(deviceID string, limit int) []
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -361,7 +379,7 @@ func (s *DatabaseStore) GetRecentReadingsByDevice(deviceID string, limit int) []
 // GetReadingsByDevice returns all readings for a specific device
 func (s *DatabaseStore) GetReadingsByDevice(deviceID string) []models.SensorReading {
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE device_id = $1
 		ORDER BY timestamp DESC`
@@ -378,7 +396,7 @@ func (s *DatabaseStore) GetReadingsByDevice(deviceID string) []models.SensorRead
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -392,7 +410,7 @@ func (s *DatabaseStore) GetReadingsByDevice(deviceID string) []models.SensorRead
 // GetReadingsInRange returns all readings within a time range
 func (s *DatabaseStore) GetReadingsInRange(start, end time.Time) []models.SensorReading {
 	query := `
-		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 		FROM sensor_readings
 		WHERE timestamp BETWEEN $1 AND $2
 		ORDER BY timestamp DESC`
@@ -409,7 +427,7 @@ func (s *DatabaseStore) GetReadingsInRange(start, end time.Time) []models.Sensor
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			log.Printf("⚠️  Warning: Error scanning reading: %v", err)
 			continue
@@ -427,14 +445,14 @@ func (s *DatabaseStore) GetHistoricalReadings(start, end time.Time, filterMode *
 
 	if filterMode != nil {
 		query = `
-			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 			FROM sensor_readings
 			WHERE timestamp BETWEEN $1 AND $2 AND filter_mode = $3
 			ORDER BY timestamp DESC`
 		args = []interface{}{start, end, string(*filterMode)}
 	} else {
 		query = `
-			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 			FROM sensor_readings
 			WHERE timestamp BETWEEN $1 AND $2
 			ORDER BY timestamp DESC`
@@ -452,16 +470,503 @@ func (s *DatabaseStore) GetHistoricalReadings(start, end time.Time, filterMode *
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, nil
+}
+
+// GetReadingsAround returns a device's readings surrounding a point in time in
+// chronological order: up to before readings at or earlier than at, followed by
+// up to after readings later than at
+func (s *DatabaseStore) GetReadingsAround(deviceID string, at time.Time, before, after int) ([]models.SensorReading, error) {
+	query := `
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
+		FROM (
+			(SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
+			 FROM sensor_readings
+			 WHERE device_id = $1 AND timestamp <= $2
+			 ORDER BY timestamp DESC
+			 LIMIT $3)
+			UNION ALL
+			(SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
+			 FROM sensor_readings
+			 WHERE device_id = $1 AND timestamp > $2
+			 ORDER BY timestamp ASC
+			 LIMIT $4)
+		) surrounding
+		ORDER BY timestamp ASC`
+
+	rows, err := s.db.Query(query, deviceID, at, before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get readings around %s: %w", at.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
+
+	readings := []models.SensorReading{}
+	for rows.Next() {
+		var reading models.SensorReading
+		err := rows.Scan(
+			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read readings around %s: %w", at.Format(time.RFC3339), err)
+	}
+
+	return readings, nil
+}
+
+// readingPredicate is a single "column op $n" condition of a reading query
+type readingPredicate struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+// readingFilterPredicates translates a reading filter into SQL predicates.
+// Column names and operators come from this fixed mapping, never from the request.
+func readingFilterPredicates(filter models.ReadingFilter) []readingPredicate {
+	var predicates []readingPredicate
+	if filter.DeviceID != "" {
+		predicates = append(predicates, readingPredicate{"device_id", "=", filter.DeviceID})
+	}
+	if filter.FilterMode != "" {
+		predicates = append(predicates, readingPredicate{"filter_mode", "=", string(filter.FilterMode)})
+	}
+	if filter.Start != nil {
+		predicates = append(predicates, readingPredicate{"timestamp", ">=", *filter.Start})
+	}
+	if filter.End != nil {
+		predicates = append(predicates, readingPredicate{"timestamp", "<=", *filter.End})
+	}
+
+	ranges := filter.MetricRanges()
+	for _, column := range models.SensorMetrics {
+		valueRange := ranges[column]
+		if valueRange.Min != nil {
+			predicates = append(predicates, readingPredicate{column, ">=", *valueRange.Min})
+		}
+		if valueRange.Max != nil {
+			predicates = append(predicates, readingPredicate{column, "<=", *valueRange.Max})
+		}
+	}
+	return predicates
+}
+
+// buildReadingWhereClause renders predicates as a parameterized WHERE clause
+func buildReadingWhereClause(predicates []readingPredicate) (string, []interface{}) {
+	if len(predicates) == 0 {
+		return "", nil
+	}
+
+	conditions := make([]string, 0, len(predicates))
+	args := make([]interface{}, 0, len(predicates))
+	for _, predicate := range predicates {
+		args = append(args, predicate.value)
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", predicate.column, predicate.op, len(args)))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// QueryReadings returns the page of readings matching the filter along with the
+// total number of matches. All user-supplied values are bound as parameters.
+func (s *DatabaseStore) QueryReadings(filter models.ReadingFilter) ([]models.SensorReading, int, error) {
+	whereClause, args := buildReadingWhereClause(readingFilterPredicates(filter))
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM sensor_readings " + whereClause
+	if err := s.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count matching readings: %w", err)
+	}
+
+	order := "DESC"
+	if filter.SortAsc {
+		order = "ASC"
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultReadingFilterLimit
+	}
+	pageArgs := append(args, limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
+		FROM sensor_readings
+		%s
+		ORDER BY timestamp %s
+		LIMIT $%d OFFSET $%d`, whereClause, order, len(args)+1, len(args)+2)
+
+	rows, err := s.db.Query(query, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []models.SensorReading{}
+	for rows.Next() {
+		var reading models.SensorReading
+		err := rows.Scan(
+			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, total, nil
+}
+
+// readingCursorCondition renders the keyset seek past a cursor as a row
+// comparison, so PostgreSQL can walk the (timestamp, device_id) index instead of
+// skipping rows like OFFSET does. Parameters are numbered from firstParam.
+func readingCursorCondition(sortAsc bool, firstParam int) string {
+	op := "<"
+	if sortAsc {
+		op = ">"
+	}
+	return fmt.Sprintf("(timestamp, device_id) %s ($%d, $%d)", op, firstParam, firstParam+1)
+}
+
+// QueryReadingsAfter returns up to filter.Limit readings matching the filter that
+// come after the cursor in (timestamp, device_id) order, newest first unless
+// filter.SortAsc. A nil cursor starts from the first reading.
+func (s *DatabaseStore) QueryReadingsAfter(filter models.ReadingFilter, after *models.ReadingCursor) ([]models.SensorReading, error) {
+	whereClause, args := buildReadingWhereClause(readingFilterPredicates(filter))
+	if after != nil {
+		condition := readingCursorCondition(filter.SortAsc, len(args)+1)
+		if whereClause == "" {
+			whereClause = "WHERE " + condition
+		} else {
+			whereClause += " AND " + condition
+		}
+		args = append(args, after.Timestamp, after.DeviceID)
+	}
+
+	order := "DESC"
+	if filter.SortAsc {
+		order = "ASC"
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultReadingFilterLimit
+	}
+	query := fmt.Sprintf(`
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
+		FROM sensor_readings
+		%s
+		ORDER BY timestamp %s, device_id %s
+		LIMIT $%d`, whereClause, order, order, len(args)+1)
+
+	rows, err := s.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings page: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []models.SensorReading{}
+	for rows.Next() {
+		var reading models.SensorReading
+		err := rows.Scan(
+			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan reading: %w", err)
 		}
 		readings = append(readings, reading)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read readings page: %w", err)
+	}
 
 	return readings, nil
 }
 
+// GetReadingsPaginated returns one page of readings, optionally restricted to a
+// device and filter mode, along with the number of readings matching those
+// filters. The page and the total come from a single query.
+func (s *DatabaseStore) GetReadingsPaginated(limit, offset int, deviceID string, mode *models.FilterMode, sortAsc bool) ([]models.SensorReading, int, error) {
+	filter := models.ReadingFilter{DeviceID: deviceID}
+	if mode != nil {
+		filter.FilterMode = *mode
+	}
+	whereClause, args := buildReadingWhereClause(readingFilterPredicates(filter))
+
+	order := "DESC"
+	if sortAsc {
+		order = "ASC"
+	}
+	if limit <= 0 {
+		limit = models.DefaultReadingFilterLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	pageArgs := append(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality,
+		       COUNT(*) OVER() AS total
+		FROM sensor_readings
+		%s
+		ORDER BY timestamp %s
+		LIMIT $%d OFFSET $%d`, whereClause, order, len(args)+1, len(args)+2)
+
+	rows, err := s.db.Query(query, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query readings page: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []models.SensorReading{}
+	total := 0
+	for rows.Next() {
+		var reading models.SensorReading
+		err := rows.Scan(
+			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality}, &total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read readings page: %w", err)
+	}
+
+	// A page past the end has no rows to carry the window count
+	if len(readings) == 0 && offset > 0 {
+		countQuery := "SELECT COUNT(*) FROM sensor_readings " + whereClause
+		if err := s.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("failed to count matching readings: %w", err)
+		}
+	}
+
+	return readings, total, nil
+}
+
+// GetMetricHeatmap averages a metric per UTC day-of-week and hour-of-day
+func (s *DatabaseStore) GetMetricHeatmap(metric string, start, end time.Time) ([]models.HeatmapBucket, error) {
+	// The metric is interpolated as a column name, so only whitelisted names are accepted
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT EXTRACT(DOW FROM timestamp AT TIME ZONE 'UTC')::int AS dow,
+		       EXTRACT(HOUR FROM timestamp AT TIME ZONE 'UTC')::int AS hour,
+		       AVG(%s), COUNT(*)
+		FROM sensor_readings
+		WHERE timestamp BETWEEN $1 AND $2
+		GROUP BY dow, hour
+		ORDER BY dow, hour`, metric)
+
+	rows, err := s.db.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metric heatmap: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.HeatmapBucket{}
+	for rows.Next() {
+		var bucket models.HeatmapBucket
+		if err := rows.Scan(&bucket.DayOfWeek, &bucket.Hour, &bucket.Average, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan heatmap bucket: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// GetMetricAggregates computes a metric's min, max and average per UTC hour or
+// day between start and end (inclusive), oldest bucket first
+func (s *DatabaseStore) GetMetricAggregates(metric, interval string, start, end time.Time) ([]models.AggregateBucket, error) {
+	// The metric and interval are interpolated, so only whitelisted names are accepted
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+	if !models.IsValidAggregateInterval(interval) {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT date_trunc('%s', timestamp AT TIME ZONE 'UTC') AS bucket_start,
+		       MIN(%s), MAX(%s), AVG(%s), COUNT(*)
+		FROM sensor_readings
+		WHERE timestamp BETWEEN $1 AND $2
+		GROUP BY bucket_start
+		ORDER BY bucket_start`, interval, metric, metric, metric)
+
+	rows, err := s.db.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metric aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.AggregateBucket{}
+	for rows.Next() {
+		var bucket models.AggregateBucket
+		if err := rows.Scan(&bucket.BucketStart, &bucket.Min, &bucket.Max, &bucket.Avg, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate bucket: %w", err)
+		}
+		// date_trunc yields a timestamp without time zone in UTC
+		bucket.BucketStart = time.Date(bucket.BucketStart.Year(), bucket.BucketStart.Month(), bucket.BucketStart.Day(),
+			bucket.BucketStart.Hour(), 0, 0, 0, time.UTC)
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// GetFilterModeCounts counts the stored readings per filter mode
+func (s *DatabaseStore) GetFilterModeCounts() ([]models.FilterModeCount, error) {
+	query := `
+		SELECT filter_mode, COUNT(*), MAX(timestamp)
+		FROM sensor_readings
+		GROUP BY filter_mode
+		ORDER BY filter_mode`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filter mode counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.FilterModeCount{}
+	for rows.Next() {
+		var count models.FilterModeCount
+		if err := rows.Scan(&count.Mode, &count.ReadingCount, &count.LatestTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan filter mode count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+// GetReadingCountsByDevice counts each device's readings between start and end (inclusive)
+func (s *DatabaseStore) GetReadingCountsByDevice(start, end time.Time) (map[string]int, error) {
+	query := `
+		SELECT device_id, COUNT(*)
+		FROM sensor_readings
+		WHERE timestamp >= $1 AND timestamp <= $2
+		GROUP BY device_id`
+
+	rows, err := s.db.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count readings by device: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var deviceID string
+		var count int
+		if err := rows.Scan(&deviceID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reading count: %w", err)
+		}
+		counts[deviceID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read reading counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetAvailableDays returns the dates, as YYYY-MM-DD in loc, that have at least
+// one reading between start and end (inclusive), oldest first
+func (s *DatabaseStore) GetAvailableDays(start, end time.Time, loc *time.Location) ([]string, error) {
+	query := `
+		SELECT DISTINCT date_trunc('day', timestamp AT TIME ZONE $3) AS day
+		FROM sensor_readings
+		WHERE timestamp BETWEEN $1 AND $2
+		ORDER BY day`
+
+	rows, err := s.db.Query(query, start, end, loc.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available days: %w", err)
+	}
+	defer rows.Close()
+
+	days := []string{}
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("failed to scan available day: %w", err)
+		}
+		days = append(days, day.Format(time.DateOnly))
+	}
+
+	return days, rows.Err()
+}
+
+// GetMetricHistogram buckets a metric's values between start and end (inclusive)
+// into equal-width bins spanning the observed range, counting in the database
+func (s *DatabaseStore) GetMetricHistogram(metric string, start, end time.Time, bins int) (*models.MetricHistogram, error) {
+	// The metric is interpolated as a column name, so only whitelisted names are accepted
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+	if bins < 1 {
+		return nil, fmt.Errorf("bins must be positive")
+	}
+
+	var min, max sql.NullFloat64
+	var total int
+	boundsQuery := fmt.Sprintf(`
+		SELECT MIN(%[1]s), MAX(%[1]s), COUNT(*)
+		FROM sensor_readings
+		WHERE timestamp BETWEEN $1 AND $2`, metric)
+	if err := s.db.QueryRow(boundsQuery, start, end).Scan(&min, &max, &total); err != nil {
+		return nil, fmt.Errorf("failed to get metric range: %w", err)
+	}
+	if total == 0 || !min.Valid || !max.Valid {
+		return models.NewMetricHistogram(metric, start, end, 0, 0, nil), nil
+	}
+	if min.Float64 == max.Float64 {
+		// Every value is identical, so there is no range to split
+		return models.NewMetricHistogram(metric, start, end, min.Float64, max.Float64, []int{total}), nil
+	}
+
+	// width_bucket puts the maximum in bucket bins+1, so clamp into the last bin
+	query := fmt.Sprintf(`
+		SELECT LEAST(GREATEST(width_bucket(%s::float8, $3, $4, $5), 1), $5) AS bin, COUNT(*)
+		FROM sensor_readings
+		WHERE timestamp BETWEEN $1 AND $2
+		GROUP BY bin`, metric)
+
+	rows, err := s.db.Query(query, start, end, min.Float64, max.Float64, bins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metric histogram: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]int, bins)
+	for rows.Next() {
+		var bin, count int
+		if err := rows.Scan(&bin, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan histogram bin: %w", err)
+		}
+		if bin >= 1 && bin <= bins {
+			counts[bin-1] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read histogram bins: %w", err)
+	}
+
+	return models.NewMetricHistogram(metric, start, end, min.Float64, max.Float64, counts), nil
+}
+
 // GetRecentReadingsWithFilter returns recent readings with optional filter mode
 func (s *DatabaseStore) GetRecentReadingsWithFilter(limit int, filterMode *models.FilterMode) ([]models.SensorReading, error) {
 	if limit <= 0 {
@@ -473,7 +978,7 @@ func (s *DatabaseStore) GetRecentReadingsWithFilter(limit int, filterMode *model
 
 	if filterMode != nil {
 		query = `
-			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 			FROM sensor_readings
 			WHERE filter_mode = $1
 			ORDER BY timestamp DESC
@@ -481,7 +986,7 @@ func (s *DatabaseStore) GetRecentReadingsWithFilter(limit int, filterMode *model
 		args = []interface{}{string(*filterMode), limit}
 	} else {
 		query = `
-			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds
+			SELECT device_id, timestamp, filter_mode, flow, ph, turbidity, tds, server_received_at, quality
 			FROM sensor_readings
 			ORDER BY timestamp DESC
 			LIMIT $1`
@@ -499,7 +1004,7 @@ func (s *DatabaseStore) GetRecentReadingsWithFilter(limit int, filterMode *model
 		var reading models.SensorReading
 		err := rows.Scan(
 			&reading.DeviceID, &reading.Timestamp, &reading.FilterMode, &reading.Flow,
-			&reading.Ph, &reading.Turbidity, &reading.TDS)
+			&reading.Ph, &reading.Turbidity, &reading.TDS, &reading.ServerReceivedAt, qualityColumn{&reading.Quality})
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan reading: %w", err)
 		}
@@ -570,7 +1075,8 @@ func (s *DatabaseStore) GetFilterModeTracking() map[string]interface{} {
 	
 	err := s.db.QueryRow(query).Scan(&startedAt, &totalFlow)
 	if err != nil || startedAt == nil {
-		return nil
+		// No device has started a filter mode yet
+		return store.EmptyFilterModeTracking()
 	}
 	
 	// Calculate duration in seconds
@@ -613,23 +1119,7 @@ func (s *DatabaseStore) getFlowStatistics() map[string]interface{} {
 	
 	if todayStats == nil && weekStats == nil && monthStats == nil {
 		log.Printf("⚠️  All statistics are nil!")
-		return map[string]interface{}{
-			"today": map[string]interface{}{
-				"drinking_water_liters": 0,
-				"household_water_liters": 0,
-				"total_liters": 0,
-			},
-			"this_week": map[string]interface{}{
-				"drinking_water_liters": 0,
-				"household_water_liters": 0,
-				"total_liters": 0,
-			},
-			"this_month": map[string]interface{}{
-				"drinking_water_liters": 0,
-				"household_water_liters": 0,
-				"total_liters": 0,
-			},
-		}
+		return store.EmptyFlowStatistics()
 	}
 	
 	return map[string]interface{}{
@@ -726,6 +1216,188 @@ func (s *DatabaseStore) SetCurrentFilterMode(mode models.FilterMode) {
 	log.Printf("✅ Filter mode changed to %s for %d devices (tracking reset)", mode, rowsAffected)
 }
 
+// RecordFilterModeChange appends an entry to the filter mode change audit log
+func (s *DatabaseStore) RecordFilterModeChange(change *models.FilterModeChange) error {
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO filter_mode_changes (from_mode, to_mode, reason, details, changed_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	err := s.db.QueryRow(query,
+		string(change.FromMode),
+		string(change.ToMode),
+		change.Reason,
+		change.Details,
+		change.ChangedAt,
+	).Scan(&change.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record filter mode change: %w", err)
+	}
+
+	return nil
+}
+
+// GetFilterModeChanges returns the most recent filter mode changes, newest first
+func (s *DatabaseStore) GetFilterModeChanges(limit int) ([]models.FilterModeChange, error) {
+	query := `
+		SELECT id, from_mode, to_mode, reason, COALESCE(details, ''), changed_at
+		FROM filter_mode_changes
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $1`
+
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filter mode changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.FilterModeChange{}
+	for rows.Next() {
+		var change models.FilterModeChange
+		var fromMode, toMode string
+		if err := rows.Scan(&change.ID, &fromMode, &toMode, &change.Reason, &change.Details, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan filter mode change: %w", err)
+		}
+		change.FromMode = models.FilterMode(fromMode)
+		change.ToMode = models.FilterMode(toMode)
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// RecordDeviceCommand appends an entry to the device command log
+func (s *DatabaseStore) RecordDeviceCommand(command *models.DeviceCommand) error {
+	if command.IssuedAt.IsZero() {
+		command.IssuedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO device_commands (device_id, command, payload, status, error, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := s.db.QueryRow(query,
+		command.DeviceID,
+		command.Command,
+		command.Payload,
+		command.Status,
+		command.Error,
+		command.IssuedAt,
+	).Scan(&command.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record device command: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateDeviceCommandStatus records the delivery state of a logged command
+func (s *DatabaseStore) UpdateDeviceCommandStatus(id int, status, errMsg string) error {
+	query := `UPDATE device_commands SET status = $2, error = $3 WHERE id = $1`
+
+	result, err := s.db.Exec(query, id, status, errMsg)
+	if err != nil {
+		return fmt.Errorf("failed to update device command status: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("device command not found")
+	}
+
+	return nil
+}
+
+// GetDeviceCommands returns the most recent commands sent to a device, newest first
+func (s *DatabaseStore) GetDeviceCommands(deviceID string, limit int) ([]models.DeviceCommand, error) {
+	query := `
+		SELECT id, device_id, command, COALESCE(payload, ''), status, COALESCE(error, ''), issued_at
+		FROM device_commands
+		WHERE device_id = $1
+		ORDER BY issued_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := s.db.Query(query, deviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device commands: %w", err)
+	}
+	defer rows.Close()
+
+	commands := []models.DeviceCommand{}
+	for rows.Next() {
+		var command models.DeviceCommand
+		err := rows.Scan(&command.ID, &command.DeviceID, &command.Command, &command.Payload,
+			&command.Status, &command.Error, &command.IssuedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device command: %w", err)
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, rows.Err()
+}
+
+// AddMaintenanceEvent appends an entry to a device's maintenance log
+func (s *DatabaseStore) AddMaintenanceEvent(event *models.MaintenanceEvent) error {
+	event.CreatedAt = time.Now()
+	if event.PerformedAt.IsZero() {
+		event.PerformedAt = event.CreatedAt
+	}
+
+	query := `
+		INSERT INTO maintenance_log (device_id, performed_at, type, notes, performed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := s.db.QueryRow(query,
+		event.DeviceID,
+		event.PerformedAt,
+		event.Type,
+		event.Notes,
+		event.PerformedBy,
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add maintenance event: %w", err)
+	}
+
+	return nil
+}
+
+// GetMaintenanceEvents returns a device's most recently performed maintenance, newest first
+func (s *DatabaseStore) GetMaintenanceEvents(deviceID string, limit int) ([]models.MaintenanceEvent, error) {
+	query := `
+		SELECT id, device_id, performed_at, type, COALESCE(notes, ''), performed_by, created_at
+		FROM maintenance_log
+		WHERE device_id = $1
+		ORDER BY performed_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := s.db.Query(query, deviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.MaintenanceEvent{}
+	for rows.Next() {
+		var event models.MaintenanceEvent
+		err := rows.Scan(&event.ID, &event.DeviceID, &event.PerformedAt, &event.Type,
+			&event.Notes, &event.PerformedBy, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
 // GetWaterQualityStatus returns water quality assessment for latest reading
 func (s *DatabaseStore) GetWaterQualityStatus() (*models.WaterQualityStatus, bool) {
 	reading, exists := s.GetLatestReading()
@@ -804,26 +1476,298 @@ func (s *DatabaseStore) GetActiveDevices() []string {
 	return devices
 }
 
+// deviceStatusColumns are the device_status columns scanned by scanDeviceStatus
+const deviceStatusColumns = `
+	device_id, COALESCE(name, ''), is_active, last_seen, COALESCE(total_readings, 0),
+	current_filter_mode, COALESCE(total_flow_liters, 0)`
+
+// scanDeviceStatus scans a row selected with deviceStatusColumns
+func scanDeviceStatus(row interface{ Scan(...interface{}) error }) (*models.DeviceStatus, error) {
+	var status models.DeviceStatus
+	err := row.Scan(&status.DeviceID, &status.Name, &status.IsActive, &status.LastSeen,
+		&status.TotalReadings, &status.CurrentFilterMode, &status.TotalFlowLiters)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetDeviceStatuses returns the status of every device that sent readings, ordered by device ID
+func (s *DatabaseStore) GetDeviceStatuses() ([]models.DeviceStatus, error) {
+	query := `SELECT ` + deviceStatusColumns + ` FROM device_status ORDER BY device_id`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []models.DeviceStatus{}
+	for rows.Next() {
+		status, err := scanDeviceStatus(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device status: %w", err)
+		}
+		statuses = append(statuses, *status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// GetDeviceStatus returns a device's status, if it sent readings
+func (s *DatabaseStore) GetDeviceStatus(deviceID string) (*models.DeviceStatus, bool, error) {
+	query := `SELECT ` + deviceStatusColumns + ` FROM device_status WHERE device_id = $1`
+
+	status, err := scanDeviceStatus(s.db.QueryRow(query, deviceID))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get device status: %w", err)
+	}
+	return status, true, nil
+}
+
+// PatchDeviceStatus renames or (de)activates a device that sent readings. It
+// leaves updated_at alone, which tracks filter mode changes.
+func (s *DatabaseStore) PatchDeviceStatus(deviceID string, update models.DeviceStatusUpdate) (*models.DeviceStatus, bool, error) {
+	query := `
+		UPDATE device_status SET
+			name = COALESCE($2, name),
+			is_active = COALESCE($3, is_active)
+		WHERE device_id = $1
+		RETURNING ` + deviceStatusColumns
+
+	status, err := scanDeviceStatus(s.db.QueryRow(query, deviceID, update.Name, update.IsActive))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update device status: %w", err)
+	}
+	return status, true, nil
+}
+
+// GetDeviceTypeOverrides returns the device type overrides stored in device metadata
+func (s *DatabaseStore) GetDeviceTypeOverrides() (map[string]string, error) {
+	query := `SELECT device_id, device_type FROM device_status WHERE device_type IS NOT NULL`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device type overrides: %w", err)
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]string)
+	for rows.Next() {
+		var deviceID, deviceType string
+		if err := rows.Scan(&deviceID, &deviceType); err != nil {
+			continue
+		}
+		overrides[deviceID] = deviceType
+	}
+
+	return overrides, nil
+}
+
+// SetDeviceTypeOverride sets the device type for a device; an empty type clears the override
+func (s *DatabaseStore) SetDeviceTypeOverride(deviceID string, deviceType string) error {
+	var value interface{}
+	if deviceType != "" {
+		value = deviceType
+	}
+
+	query := `
+		INSERT INTO device_status (device_id, device_type, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (device_id) DO UPDATE SET
+			device_type = EXCLUDED.device_type,
+			updated_at = NOW()`
+
+	if _, err := s.db.Exec(query, deviceID, value); err != nil {
+		return fmt.Errorf("failed to set device type override: %w", err)
+	}
+	return nil
+}
+
+// SetDeviceAPIKeyHash stores the hash of a device's ingestion API key, replacing any previous key
+func (s *DatabaseStore) SetDeviceAPIKeyHash(deviceID string, keyHash string) error {
+	query := `
+		INSERT INTO devices (device_id, api_key_hash, api_key_created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (device_id) DO UPDATE SET
+			api_key_hash = EXCLUDED.api_key_hash,
+			api_key_created_at = NOW(),
+			updated_at = NOW()`
+
+	if _, err := s.db.Exec(query, deviceID, keyHash); err != nil {
+		return fmt.Errorf("failed to set device API key: %w", err)
+	}
+	return nil
+}
+
+// GetDeviceAPIKeyHash returns the hash of a device's ingestion API key, if one is provisioned
+func (s *DatabaseStore) GetDeviceAPIKeyHash(deviceID string) (string, bool, error) {
+	query := `SELECT api_key_hash FROM devices WHERE device_id = $1 AND api_key_hash IS NOT NULL`
+
+	var keyHash string
+	err := s.db.QueryRow(query, deviceID).Scan(&keyHash)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get device API key: %w", err)
+	}
+	return keyHash, true, nil
+}
+
+// CountDeviceAPIKeys returns how many devices have an ingestion API key
+func (s *DatabaseStore) CountDeviceAPIKeys() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM devices WHERE api_key_hash IS NOT NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count device API keys: %w", err)
+	}
+	return count, nil
+}
+
+// SetFilterSpec stores the metadata of the filter installed on a device
+func (s *DatabaseStore) SetFilterSpec(spec models.FilterSpec) error {
+	query := `
+		INSERT INTO devices (device_id, filter_capacity_liters, filter_cost, filter_installed_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (device_id) DO UPDATE SET
+			filter_capacity_liters = EXCLUDED.filter_capacity_liters,
+			filter_cost = EXCLUDED.filter_cost,
+			filter_installed_at = EXCLUDED.filter_installed_at,
+			updated_at = NOW()`
+
+	if _, err := s.db.Exec(query, spec.DeviceID, spec.CapacityLiters, spec.Cost, spec.InstalledAt); err != nil {
+		return fmt.Errorf("failed to set filter spec: %w", err)
+	}
+	return nil
+}
+
+// GetFilterSpec returns the metadata of the filter installed on a device, if recorded
+func (s *DatabaseStore) GetFilterSpec(deviceID string) (models.FilterSpec, bool, error) {
+	query := `
+		SELECT filter_capacity_liters, filter_cost, filter_installed_at
+		FROM devices
+		WHERE device_id = $1 AND filter_capacity_liters IS NOT NULL`
+
+	spec := models.FilterSpec{DeviceID: deviceID}
+	err := s.db.QueryRow(query, deviceID).Scan(&spec.CapacityLiters, &spec.Cost, &spec.InstalledAt)
+	if err == sql.ErrNoRows {
+		return models.FilterSpec{}, false, nil
+	}
+	if err != nil {
+		return models.FilterSpec{}, false, fmt.Errorf("failed to get filter spec: %w", err)
+	}
+	return spec, true, nil
+}
+
+// SetAlertPreferences stores the anomaly alerts muted for a device
+func (s *DatabaseStore) SetAlertPreferences(prefs models.AlertPreferences) error {
+	query := `
+		INSERT INTO devices (device_id, alert_muted_metrics, alert_muted_severities, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (device_id) DO UPDATE SET
+			alert_muted_metrics = EXCLUDED.alert_muted_metrics,
+			alert_muted_severities = EXCLUDED.alert_muted_severities,
+			updated_at = NOW()`
+
+	if _, err := s.db.Exec(query, prefs.DeviceID, pq.Array(nonNilStrings(prefs.MutedMetrics)), pq.Array(nonNilStrings(prefs.MutedSeverities))); err != nil {
+		return fmt.Errorf("failed to set alert preferences: %w", err)
+	}
+	return nil
+}
+
+// GetAlertPreferences returns the anomaly alerts muted for a device, or the
+// defaults when none were set
+func (s *DatabaseStore) GetAlertPreferences(deviceID string) (models.AlertPreferences, error) {
+	query := `
+		SELECT alert_muted_metrics, alert_muted_severities
+		FROM devices
+		WHERE device_id = $1`
+
+	prefs := models.DefaultAlertPreferences(deviceID)
+	err := s.db.QueryRow(query, deviceID).Scan(pq.Array(&prefs.MutedMetrics), pq.Array(&prefs.MutedSeverities))
+	if err == sql.ErrNoRows {
+		return models.DefaultAlertPreferences(deviceID), nil
+	}
+	if err != nil {
+		return models.AlertPreferences{}, fmt.Errorf("failed to get alert preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// nonNilStrings stores an unset list as an empty array rather than NULL
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// GetProcessedVolume estimates the liters that flowed through a device since a
+// time the way accumulateFlow does: each reading's flow rate (L/min) times the
+// minutes since the previous reading, skipping gaps flow isn't accumulated over
+func (s *DatabaseStore) GetProcessedVolume(deviceID string, since time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(flow * gap_minutes), 0)
+		FROM (
+			SELECT flow, EXTRACT(EPOCH FROM (timestamp - LAG(timestamp) OVER (ORDER BY timestamp))) / 60.0 AS gap_minutes
+			FROM sensor_readings
+			WHERE device_id = $1 AND timestamp >= $2
+		) gaps
+		WHERE gap_minutes BETWEEN 0 AND $3`
+
+	var volume float64
+	if err := s.db.QueryRow(query, deviceID, since, store.MaxFlowGapMinutes).Scan(&volume); err != nil {
+		return 0, fmt.Errorf("failed to get processed volume: %w", err)
+	}
+	return volume, nil
+}
+
 // SetLEDCommand sets the LED command (stored in memory, not in database for simplicity)
 // For production, you might want to store this in a commands table
-var ledCommand string = "OFF"
-
 func (s *DatabaseStore) SetLEDCommand(command string) {
-	ledCommand = command
+	s.ledMu.Lock()
+	defer s.ledMu.Unlock()
+	s.ledCommand = command
 }
 
 // GetLEDCommand retrieves the current LED command
 func (s *DatabaseStore) GetLEDCommand() string {
-	return ledCommand
+	s.ledMu.Lock()
+	defer s.ledMu.Unlock()
+	return s.ledCommand
 }
 
 // ===== Schedule Management Methods =====
 
+// scheduleDays returns the schedule's days of the week, never nil since the
+// column is NOT NULL (one-time schedules have none)
+func scheduleDays(schedule *models.FilterSchedule) []string {
+	if schedule.DaysOfWeek == nil {
+		return []string{}
+	}
+	return schedule.DaysOfWeek
+}
+
+// formatRunDate converts a scanned run_date to the schedule's YYYY-MM-DD form
+func formatRunDate(runDate sql.NullTime) *string {
+	if !runDate.Valid {
+		return nil
+	}
+	formatted := runDate.Time.Format(models.RunDateLayout)
+	return &formatted
+}
+
 // CreateSchedule creates a new filter schedule
 func (s *DatabaseStore) CreateSchedule(schedule *models.FilterSchedule) error {
 	query := `
-		INSERT INTO filter_schedules (name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO filter_schedules (name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone, run_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at, updated_at`
 
 	err := s.db.QueryRow(query,
@@ -831,9 +1775,10 @@ func (s *DatabaseStore) CreateSchedule(schedule *models.FilterSchedule) error {
 		schedule.FilterMode,
 		schedule.StartTime,
 		schedule.DurationMinutes,
-		pq.Array(schedule.DaysOfWeek),
+		pq.Array(scheduleDays(schedule)),
 		schedule.IsActive,
 		schedule.Timezone,
+		schedule.RunDate,
 	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
 
 	if err != nil {
@@ -848,12 +1793,13 @@ func (s *DatabaseStore) CreateSchedule(schedule *models.FilterSchedule) error {
 // GetSchedule retrieves a schedule by ID
 func (s *DatabaseStore) GetSchedule(id int) (*models.FilterSchedule, error) {
 	query := `
-		SELECT id, name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone, created_at, updated_at
+		SELECT id, name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone, run_date, created_at, updated_at
 		FROM filter_schedules
 		WHERE id = $1`
 
 	var schedule models.FilterSchedule
 	var startTime time.Time
+	var runDate sql.NullTime
 	err := s.db.QueryRow(query, id).Scan(
 		&schedule.ID,
 		&schedule.Name,
@@ -863,12 +1809,14 @@ func (s *DatabaseStore) GetSchedule(id int) (*models.FilterSchedule, error) {
 		pq.Array(&schedule.DaysOfWeek),
 		&schedule.IsActive,
 		&schedule.Timezone,
+		&runDate,
 		&schedule.CreatedAt,
 		&schedule.UpdatedAt,
 	)
 	
 	// Convert time.Time to HH:MM:SS string format
 	schedule.StartTime = startTime.Format("15:04:05")
+	schedule.RunDate = formatRunDate(runDate)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("schedule not found")
@@ -884,7 +1832,7 @@ func (s *DatabaseStore) GetSchedule(id int) (*models.FilterSchedule, error) {
 // GetAllSchedules retrieves all schedules, optionally filtered by active status
 func (s *DatabaseStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedule, error) {
 	query := `
-		SELECT id, name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone, created_at, updated_at
+		SELECT id, name, filter_mode, start_time, duration_minutes, days_of_week, is_active, timezone, run_date, created_at, updated_at
 		FROM filter_schedules`
 
 	if activeOnly {
@@ -904,6 +1852,7 @@ func (s *DatabaseStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedul
 	for rows.Next() {
 		var schedule models.FilterSchedule
 		var startTime time.Time
+		var runDate sql.NullTime
 		err := rows.Scan(
 			&schedule.ID,
 			&schedule.Name,
@@ -913,6 +1862,7 @@ func (s *DatabaseStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedul
 			pq.Array(&schedule.DaysOfWeek),
 			&schedule.IsActive,
 			&schedule.Timezone,
+			&runDate,
 			&schedule.CreatedAt,
 			&schedule.UpdatedAt,
 		)
@@ -923,6 +1873,7 @@ func (s *DatabaseStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedul
 		
 		// Convert time.Time to HH:MM:SS string format
 		schedule.StartTime = startTime.Format("15:04:05")
+		schedule.RunDate = formatRunDate(runDate)
 		
 		schedules = append(schedules, schedule)
 	}
@@ -935,17 +1886,18 @@ func (s *DatabaseStore) UpdateSchedule(schedule *models.FilterSchedule) error {
 	query := `
 		UPDATE filter_schedules
 		SET name = $1, filter_mode = $2, start_time = $3, duration_minutes = $4, 
-		    days_of_week = $5, is_active = $6, timezone = $7, updated_at = NOW()
-		WHERE id = $8`
+		    days_of_week = $5, is_active = $6, timezone = $7, run_date = $8, updated_at = NOW()
+		WHERE id = $9`
 
 	result, err := s.db.Exec(query,
 		schedule.Name,
 		schedule.FilterMode,
 		schedule.StartTime,
 		schedule.DurationMinutes,
-		pq.Array(schedule.DaysOfWeek),
+		pq.Array(scheduleDays(schedule)),
 		schedule.IsActive,
 		schedule.Timezone,
+		schedule.RunDate,
 		schedule.ID,
 	)
 
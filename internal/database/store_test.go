@@ -0,0 +1,536 @@
+package database
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/config"
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// TestReadingFilterPredicates_MatchInMemoryStore needs a database, see openTestDatabase
+func TestReadingFilterPredicates_MatchInMemoryStore(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings")
+	dbStore := NewDatabaseStore(db.DB)
+
+	memStore := store.NewStore(100)
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		mode := models.FilterModeDrinking
+		if i%3 == 0 {
+			mode = models.FilterModeHousehold
+		}
+		reading := models.SensorReading{
+			DeviceID:   []string{"stm32_pre", "stm32_post"}[i%2],
+			Timestamp:  base.Add(time.Duration(i) * time.Hour),
+			FilterMode: mode,
+			Ph:         6.0 + float64(i%10)*0.25,
+			TDS:        float64(50 * i),
+			Turbidity:  float64(i % 5),
+			Flow:       float64(i % 4),
+		}
+		memStore.AddSensorReading(reading)
+		if _, err := db.Exec(`INSERT INTO sensor_readings (device_id, timestamp, filter_mode, flow, ph, turbidity, tds)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			reading.DeviceID, reading.Timestamp, reading.FilterMode, reading.Flow, reading.Ph, reading.Turbidity, reading.TDS); err != nil {
+			t.Fatalf("Failed to insert reading %d: %v", i, err)
+		}
+	}
+
+	value := func(v float64) *float64 { return &v }
+	start := base.Add(4 * time.Hour)
+	end := base.Add(12 * time.Hour)
+	filters := map[string]models.ReadingFilter{
+		"empty":             {},
+		"device and mode":   {DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking},
+		"inclusive range":   {Start: &start, End: &end, SortAsc: true},
+		"tds above":         {FilterMode: models.FilterModeDrinking, TDS: models.ValueRange{Min: value(600)}},
+		"ph window":         {Ph: models.ValueRange{Min: value(6.5), Max: value(7.5)}},
+		"every predicate":   {DeviceID: "stm32_pre", Start: &start, End: &end, Turbidity: models.ValueRange{Max: value(3)}, Flow: models.ValueRange{Min: value(0), Max: value(2)}},
+		"paged":             {FilterMode: models.FilterModeDrinking, Limit: 3, Offset: 2},
+		"no possible match": {Ph: models.ValueRange{Min: value(14)}},
+	}
+
+	for name, filter := range filters {
+		if filter.Limit == 0 {
+			filter.Limit = models.MaxReadingFilterLimit
+		}
+
+		want, wantTotal, err := memStore.QueryReadings(filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected in-memory error: %v", name, err)
+		}
+		got, gotTotal, err := dbStore.QueryReadings(filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected database error: %v", name, err)
+		}
+
+		if gotTotal != wantTotal || len(got) != len(want) {
+			t.Errorf("%s: database matched %d (page %d), in-memory store matched %d (page %d)", name, gotTotal, len(got), wantTotal, len(want))
+			continue
+		}
+		for i := range want {
+			if got[i].DeviceID != want[i].DeviceID || !got[i].Timestamp.Equal(want[i].Timestamp) {
+				t.Errorf("%s: reading %d is %s at %s in the database, %s at %s in memory", name, i,
+					got[i].DeviceID, got[i].Timestamp, want[i].DeviceID, want[i].Timestamp)
+			}
+		}
+	}
+}
+
+func TestBuildReadingWhereClause_Parameterized(t *testing.T) {
+	maxPh := 7.5
+	filter := models.ReadingFilter{
+		DeviceID: "stm32_pre'; DROP TABLE sensor_readings; --",
+		Ph:       models.ValueRange{Max: &maxPh},
+	}
+
+	whereClause, args := buildReadingWhereClause(readingFilterPredicates(filter))
+
+	if whereClause != "WHERE device_id = $1 AND ph <= $2" {
+		t.Errorf("Unexpected WHERE clause: %s", whereClause)
+	}
+	if strings.Contains(whereClause, "DROP") {
+		t.Error("User input must never be concatenated into the SQL")
+	}
+	if len(args) != 2 || args[0] != filter.DeviceID || args[1] != maxPh {
+		t.Errorf("Unexpected query args: %v", args)
+	}
+
+	if whereClause, args := buildReadingWhereClause(nil); whereClause != "" || len(args) != 0 {
+		t.Errorf("Expected no WHERE clause for an empty filter, got %q %v", whereClause, args)
+	}
+}
+
+func TestReadingCursorCondition_SeeksInSortDirection(t *testing.T) {
+	if got := readingCursorCondition(false, 3); got != "(timestamp, device_id) < ($3, $4)" {
+		t.Errorf("Unexpected newest-first seek: %s", got)
+	}
+	if got := readingCursorCondition(true, 1); got != "(timestamp, device_id) > ($1, $2)" {
+		t.Errorf("Unexpected oldest-first seek: %s", got)
+	}
+}
+
+func TestQualityColumn_RoundTrip(t *testing.T) {
+	reading := models.SensorReading{Ph: 7.2, Turbidity: 2, TDS: 450}
+	reading.StampQuality()
+
+	value, err := qualityValue(reading.Quality)
+	if err != nil {
+		t.Fatalf("Failed to encode quality: %v", err)
+	}
+
+	var scanned models.SensorReading
+	if err := (qualityColumn{&scanned.Quality}).Scan([]byte(value.(string))); err != nil {
+		t.Fatalf("Failed to scan quality: %v", err)
+	}
+	if scanned.Quality == nil || *scanned.Quality != *reading.Quality {
+		t.Errorf("Expected %+v after round trip, got %+v", *reading.Quality, scanned.Quality)
+	}
+
+	// Readings stored before quality was persisted have a NULL column
+	if err := (qualityColumn{&scanned.Quality}).Scan(nil); err != nil || scanned.Quality != nil {
+		t.Errorf("Expected NULL to scan as no stored quality, got %+v (err=%v)", scanned.Quality, err)
+	}
+	if v, _ := qualityValue(nil); v != nil {
+		t.Errorf("Expected no stored quality to encode as NULL, got %v", v)
+	}
+}
+
+// openTestDatabase connects to the migrated PostgreSQL database in
+// TEST_DATABASE_URL, skipping without one. Empty temporary copies of the given
+// tables shadow the real ones, so fixtures never touch stored data.
+func openTestDatabase(tb testing.TB, tables ...string) *DB {
+	tb.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		tb.Skip("TEST_DATABASE_URL not set")
+	}
+	tb.Setenv("DATABASE_URL", databaseURL)
+	tb.Chdir("../..") // Migrations are read relative to the repository root
+
+	db, err := Connect(config.DatabaseConfig{})
+	if err != nil {
+		tb.Fatalf("Failed to connect: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := RunMigrations(db.DB); err != nil {
+		tb.Fatalf("Failed to run migrations: %v", err)
+	}
+	// Temporary tables live on one connection, so keep every query on it
+	db.SetMaxOpenConns(1)
+	for _, table := range tables {
+		if _, err := db.Exec(`CREATE TEMP TABLE ` + table + ` (LIKE public.` + table + ` INCLUDING ALL)`); err != nil {
+			tb.Fatalf("Failed to create the %s fixture table: %v", table, err)
+		}
+	}
+	return db
+}
+
+// TestRecomputeReadingQuality_UsesFilterModeThresholds needs a database, see openTestDatabase
+func TestRecomputeReadingQuality_UsesFilterModeThresholds(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings")
+
+	relaxed := models.DefaultQualityThresholds
+	relaxed.PhMin = 6.5
+	relaxed.TDSExcellentMax = 400
+	if err := models.SetQualityThresholds(models.DefaultQualityThresholds, map[models.FilterMode]models.QualityThresholds{models.FilterModeHousehold: relaxed}); err != nil {
+		t.Fatalf("Failed to set thresholds: %v", err)
+	}
+	defer models.SetQualityThresholds(models.DefaultQualityThresholds, nil)
+
+	// Acidic with good TDS under the defaults, normal and excellent for household water
+	if _, err := db.Exec(`INSERT INTO sensor_readings (device_id, timestamp, filter_mode, flow, ph, turbidity, tds)
+		VALUES ('stm32_post', $1, $2, 1, 6.8, 0.5, 350)`,
+		time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), models.FilterModeHousehold); err != nil {
+		t.Fatalf("Failed to insert reading: %v", err)
+	}
+
+	dbStore := NewDatabaseStore(db.DB)
+	if updated, err := dbStore.RecomputeReadingQuality(); err != nil || updated != 1 {
+		t.Fatalf("Expected 1 reading recomputed, got %d, %v", updated, err)
+	}
+
+	var phStatus, tdsStatus string
+	if err := db.QueryRow(`SELECT quality->>'ph_status', quality->>'tds_status' FROM sensor_readings`).Scan(&phStatus, &tdsStatus); err != nil {
+		t.Fatalf("Failed to read the stored quality: %v", err)
+	}
+	if phStatus != "Normal" || tdsStatus != "Excellent" {
+		t.Errorf("Expected the household thresholds to be used, got pH %s and TDS %s", phStatus, tdsStatus)
+	}
+}
+
+// TestGetSensorDataStats_MatchesInMemoryStats needs a database, see openTestDatabase
+func TestGetSensorDataStats_MatchesInMemoryStats(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings")
+
+	memStore := store.NewStore(1000)
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	pending := map[models.FilterMode]int{}
+	for i := 0; i < 97; i++ {
+		mode := models.FilterModeDrinking
+		if i%4 == 0 {
+			mode = models.FilterModeHousehold
+		}
+		reading := models.SensorReading{
+			DeviceID:   []string{"stm32_pre", "stm32_post"}[i%2],
+			Timestamp:  base.Add(time.Duration(i) * 10 * time.Minute),
+			FilterMode: mode,
+			Flow:       float64(i%13) * 0.25,
+			Ph:         6.5 + float64(i%9)*0.25,
+			Turbidity:  float64(i%17) * 0.5,
+			TDS:        100 + float64(i*37%500),
+		}
+		memStore.AddSensorReading(reading)
+
+		// Every fifth reading waits for the quality backfill
+		var quality interface{}
+		if i%5 != 0 {
+			reading.StampQuality()
+			quality, _ = qualityValue(reading.Quality)
+		} else {
+			pending[mode]++
+			pending[""]++
+		}
+		if _, err := db.Exec(`INSERT INTO sensor_readings (device_id, timestamp, filter_mode, flow, ph, turbidity, tds, quality)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			reading.DeviceID, reading.Timestamp, reading.FilterMode, reading.Flow, reading.Ph, reading.Turbidity, reading.TDS, quality); err != nil {
+			t.Fatalf("Failed to insert reading %d: %v", i, err)
+		}
+	}
+
+	dbStore := NewDatabaseStore(db.DB)
+	for _, mode := range []models.FilterMode{"", models.FilterModeDrinking, models.FilterModeHousehold} {
+		want, _ := memStore.GetSensorDataStats(mode)
+		got, err := dbStore.GetSensorDataStats(mode)
+		if err != nil || got == nil {
+			t.Fatalf("Mode %q: expected stats, got %v, %v", mode, got, err)
+		}
+
+		if got.TotalReadings != want.TotalReadings {
+			t.Errorf("Mode %q: expected %d readings, got %d", mode, want.TotalReadings, got.TotalReadings)
+		}
+		metrics := map[string][2]map[string]float64{
+			"ph":        {want.PhStats, got.PhStats},
+			"tds":       {want.TDSStats, got.TDSStats},
+			"turbidity": {want.TurbidityStats, got.TurbidityStats},
+			"flow":      {want.FlowStats, got.FlowStats},
+		}
+		for metric, pair := range metrics {
+			for _, key := range metricStatKeys {
+				if diff := pair[0][key] - pair[1][key]; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("Mode %q: expected %s %s %.6f, got %.6f", mode, metric, key, pair[0][key], pair[1][key])
+				}
+			}
+		}
+		// Readings waiting for the backfill are counted as pending, not assessed
+		if got.QualityBreakdown[models.QualityPendingLabel] != pending[mode] {
+			t.Errorf("Mode %q: expected %d pending readings, got %v", mode, pending[mode], got.QualityBreakdown)
+		}
+		assessed := 0
+		for _, label := range models.OverallQualityLabels {
+			if got.QualityBreakdown[label] > want.QualityBreakdown[label] {
+				t.Errorf("Mode %q: expected at most %d %s readings, got %v", mode, want.QualityBreakdown[label], label, got.QualityBreakdown)
+			}
+			assessed += got.QualityBreakdown[label]
+		}
+		if assessed+pending[mode] != want.TotalReadings {
+			t.Errorf("Mode %q: expected %d assessed readings, got %v", mode, want.TotalReadings-pending[mode], got.QualityBreakdown)
+		}
+		for filterMode, count := range want.FilterModes {
+			if got.FilterModes[filterMode] != count {
+				t.Errorf("Mode %q: expected %d %s readings, got %v", mode, count, filterMode, got.FilterModes)
+			}
+		}
+	}
+
+	if _, err := db.Exec(`DELETE FROM sensor_readings`); err != nil {
+		t.Fatalf("Failed to clear the fixture table: %v", err)
+	}
+	if stats, err := dbStore.GetSensorDataStats(""); stats != nil || err != nil {
+		t.Errorf("Expected no stats without readings, got %v, %v", stats, err)
+	}
+}
+
+// batchFixture is readings from two devices a minute apart, with a long gap
+// that flow isn't accumulated over and a reading repeated with new values
+func batchFixture(n int) []models.SensorReading {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var readings []models.SensorReading
+	for i := 0; i < n; i++ {
+		minute := i / 2
+		if minute >= n/4 {
+			minute += 30
+		}
+		readings = append(readings, models.SensorReading{
+			DeviceID:   []string{"stm32_pre", "stm32_post"}[i%2],
+			Timestamp:  base.Add(time.Duration(minute) * time.Minute),
+			FilterMode: models.FilterModeDrinking,
+			Flow:       1.5 + float64(i%5)*0.25,
+			Ph:         7.0 + float64(i%4)*0.25,
+			Turbidity:  float64(i%3) * 0.5,
+			TDS:        120 + float64(i%7),
+		})
+	}
+	repeated := readings[n/2]
+	repeated.Ph, repeated.TDS = 8.5, 300
+	return append(readings, repeated)
+}
+
+// TestAddSensorReadingsBatch_MatchesSingleInserts needs a database, see openTestDatabase
+func TestAddSensorReadingsBatch_MatchesSingleInserts(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings", "device_status")
+	dbStore := NewDatabaseStore(db.DB)
+	readings := batchFixture(40)
+
+	type snapshot struct {
+		rows     string
+		statuses string
+	}
+	capture := func() snapshot {
+		var snap snapshot
+		if err := db.QueryRow(`SELECT string_agg(concat_ws(' ', device_id, timestamp, filter_mode, flow, ph, turbidity, tds, quality IS NOT NULL), ';' ORDER BY device_id, timestamp) FROM sensor_readings`).Scan(&snap.rows); err != nil {
+			t.Fatalf("Failed to read readings: %v", err)
+		}
+		if err := db.QueryRow(`SELECT string_agg(concat_ws(' ', device_id, total_readings, total_flow_liters, last_flow_update_at), ';' ORDER BY device_id) FROM device_status`).Scan(&snap.statuses); err != nil {
+			t.Fatalf("Failed to read device statuses: %v", err)
+		}
+		if _, err := db.Exec(`TRUNCATE sensor_readings, device_status`); err != nil {
+			t.Fatalf("Failed to clear the fixture tables: %v", err)
+		}
+		return snap
+	}
+
+	// A stored reading is replaced by both paths
+	dbStore.AddSensorReading(readings[3])
+	for _, reading := range readings {
+		dbStore.AddSensorReading(reading)
+	}
+	want := capture()
+
+	dbStore.AddSensorReading(readings[3])
+	if err := dbStore.AddSensorReadingsBatch(readings); err != nil {
+		t.Fatalf("Failed to add the batch: %v", err)
+	}
+	got := capture()
+
+	if got.rows != want.rows {
+		t.Errorf("Expected the batch to store\n%s\ngot\n%s", want.rows, got.rows)
+	}
+	if got.statuses != want.statuses {
+		t.Errorf("Expected device statuses %s, got %s", want.statuses, got.statuses)
+	}
+}
+
+// BenchmarkAddSensorReadings compares storing 1000 readings one at a time with
+// one batch. It needs a database, see openTestDatabase.
+func BenchmarkAddSensorReadings(b *testing.B) {
+	db := openTestDatabase(b, "sensor_readings", "device_status")
+	dbStore := NewDatabaseStore(db.DB)
+	readings := batchFixture(1000)
+	reset := func() {
+		b.StopTimer()
+		if _, err := db.Exec(`TRUNCATE sensor_readings, device_status`); err != nil {
+			b.Fatalf("Failed to clear the fixture tables: %v", err)
+		}
+		b.StartTimer()
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reset()
+			for _, reading := range readings {
+				dbStore.AddSensorReading(reading)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reset()
+			if err := dbStore.AddSensorReadingsBatch(readings); err != nil {
+				b.Fatalf("Failed to add the batch: %v", err)
+			}
+		}
+	})
+}
+
+// TestGetAvailableDays_MatchesInMemoryStore needs a database, see openTestDatabase
+func TestGetAvailableDays_MatchesInMemoryStore(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings", "device_status")
+	dbStore := NewDatabaseStore(db.DB)
+	memStore := store.NewStore(100)
+
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{10 * time.Hour, 23*time.Hour + 30*time.Minute, 84 * time.Hour, 85 * time.Hour, 19 * 24 * time.Hour} {
+		reading := models.SensorReading{DeviceID: "stm32_pre", Timestamp: base.Add(offset), FilterMode: models.FilterModeDrinking, Ph: 7, TDS: 100}
+		memStore.AddSensorReading(reading)
+		dbStore.AddSensorReading(reading)
+	}
+
+	end := base.Add(9 * 24 * time.Hour)
+	for _, tz := range []string{"UTC", "Asia/Jakarta", "America/New_York"} {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", tz, err)
+		}
+		want, _ := memStore.GetAvailableDays(base, end, loc)
+		got, err := dbStore.GetAvailableDays(base, end, loc)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tz, err)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: expected days %v, got %v", tz, want, got)
+		}
+	}
+}
+
+func TestGetReadingsInRange_IncludesBoundsLikeInMemoryStore(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings", "device_status")
+	dbStore := NewDatabaseStore(db.DB)
+	memStore := store.NewStore(100)
+
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	for i, ts := range []time.Time{start.Add(-time.Microsecond), start, start.Add(30 * time.Minute), end, end.Add(time.Microsecond)} {
+		reading := models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking, TDS: float64(i)}
+		memStore.AddSensorReading(reading)
+		dbStore.AddSensorReading(reading)
+	}
+
+	for name, readings := range map[string][]models.SensorReading{
+		"memory":   memStore.GetReadingsInRange(start, end),
+		"database": dbStore.GetReadingsInRange(start, end),
+	} {
+		got := make(map[float64]bool)
+		for _, reading := range readings {
+			got[reading.TDS] = true
+		}
+		if len(readings) != 3 || !got[1] || !got[2] || !got[3] {
+			t.Errorf("%s: expected readings on and between the bounds (1..3), got %v", name, readings)
+		}
+	}
+}
+
+func TestGetProcessedVolume_MatchesInMemoryStore(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings", "device_status")
+	dbStore := NewDatabaseStore(db.DB)
+	memStore := store.NewStore(100)
+
+	// 2 L/min every 5 minutes for 20 minutes = 40 L, then a 30 minute gap that isn't counted
+	base := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	for _, minutes := range []int{0, 5, 10, 15, 20, 50} {
+		reading := models.SensorReading{DeviceID: "stm32_post", Timestamp: base.Add(time.Duration(minutes) * time.Minute), FilterMode: models.FilterModeDrinking, Flow: 2}
+		memStore.AddSensorReading(reading)
+		dbStore.AddSensorReading(reading)
+	}
+
+	want, _ := memStore.GetProcessedVolume("stm32_post", base)
+	got, err := dbStore.GetProcessedVolume("stm32_post", base)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want != 40 || math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected 40 L from both stores, got %v in memory and %v in the database", want, got)
+	}
+}
+
+func TestPruneDataBefore_DeletesInBatches(t *testing.T) {
+	db := openTestDatabase(t, "sensor_readings", "device_status", "anomaly_detections", "sensor_predictions")
+	dbStore := NewDatabaseStore(db.DB)
+
+	now := time.Now().Truncate(time.Second)
+	cutoff := now.AddDate(0, 0, -90)
+	for i := 0; i < 7; i++ {
+		at := cutoff.Add(time.Duration(i-5) * time.Hour) // Five before the cutoff, two after
+		dbStore.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: at, FilterMode: models.FilterModeDrinking, Ph: 7, TDS: 100})
+		if err := dbStore.SaveAnomaly(&models.AnomalyDetection{DeviceID: "stm32_post", DetectedAt: at, AnomalyType: "spike", Severity: "low", AffectedMetric: "tds", FilterMode: models.FilterModeDrinking}); err != nil {
+			t.Fatalf("Failed to save anomaly: %v", err)
+		}
+		if err := dbStore.SaveSensorPrediction(&models.SensorPrediction{DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, PredictedFor: at, ConfidenceScore: 0.5}); err != nil {
+			t.Fatalf("Failed to save sensor prediction: %v", err)
+		}
+	}
+
+	result, err := dbStore.PruneDataBefore(cutoff, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SensorReadings != 5 || result.Anomalies != 5 || result.SensorPredictions != 5 {
+		t.Errorf("Expected 5 rows pruned from each table, got %+v", result)
+	}
+	if count := dbStore.GetReadingCount(); count != 2 {
+		t.Errorf("Expected 2 readings kept, got %d", count)
+	}
+}
+
+// TestSaveFilterHealth_KeepsExcludedPairCounts needs a database, see openTestDatabase
+func TestSaveFilterHealth_KeepsExcludedPairCounts(t *testing.T) {
+	db := openTestDatabase(t, "filter_health")
+	dbStore := NewDatabaseStore(db.DB)
+
+	now := time.Now()
+	if err := dbStore.SaveFilterHealth(&models.FilterHealth{
+		DeviceID: "stm32_post", HealthScore: 80, TurbidityPairsExcluded: 7, TDSPairsExcluded: 3,
+		Recommendations: []string{}, LastCalculated: now, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Failed to save filter health: %v", err)
+	}
+
+	latest, err := dbStore.GetLatestFilterHealth("stm32_post")
+	if err != nil || latest == nil {
+		t.Fatalf("Expected the saved health, got %v, %v", latest, err)
+	}
+	history, err := dbStore.GetFilterHealthHistory("stm32_post", 10)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected 1 history record, got %d, %v", len(history), err)
+	}
+	for _, health := range []models.FilterHealth{*latest, history[0]} {
+		if health.TurbidityPairsExcluded != 7 || health.TDSPairsExcluded != 3 {
+			t.Errorf("Expected 7 turbidity and 3 TDS pairs excluded, got %d and %d", health.TurbidityPairsExcluded, health.TDSPairsExcluded)
+		}
+	}
+}
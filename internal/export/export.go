@@ -2,8 +2,11 @@ package export
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
@@ -11,11 +14,62 @@ import (
 )
 
 // ExportService handles data export functionality
-type ExportService struct{}
+type ExportService struct {
+	flowUnit models.FlowUnit // Unit flow rates are exported in
+}
 
 // NewExportService creates a new export service instance
 func NewExportService() *ExportService {
-	return &ExportService{}
+	return &ExportService{flowUnit: models.FlowUnitLitersPerMinute}
+}
+
+// SetFlowUnit sets the unit flow rates are exported in; unknown units are ignored
+func (es *ExportService) SetFlowUnit(unit models.FlowUnit) {
+	if unit.IsValid() {
+		es.flowUnit = unit
+	}
+}
+
+// sensorColumn describes one measurement column of the sensor data exports
+type sensorColumn struct {
+	metric   string // Measurement key, independent of the header's unit
+	header   string
+	decimals int // Digits after the decimal point
+	value    func(reading models.SensorReading) float64
+}
+
+// sensorColumns returns the measurement columns with headers and values in the
+// configured units
+func (es *ExportService) sensorColumns() []sensorColumn {
+	return []sensorColumn{
+		{"flow", fmt.Sprintf("Flow (%s)", es.flowUnit), 2, func(r models.SensorReading) float64 { return es.flowUnit.FromLitersPerMinute(r.Flow) }},
+		{"ph", "pH", 2, func(r models.SensorReading) float64 { return r.Ph }},
+		{"turbidity", "Turbidity (NTU)", 2, func(r models.SensorReading) float64 { return r.Turbidity }},
+		{"tds", "TDS (ppm)", 1, func(r models.SensorReading) float64 { return r.TDS }},
+	}
+}
+
+// firstSensorColumn is the Sensor Data sheet column of the first measurement,
+// after the timestamp and filter mode
+const firstSensorColumn = 3
+
+// sensorDataColumn returns the Sensor Data sheet column and the column
+// description of a measurement
+func (es *ExportService) sensorDataColumn(metric string) (int, sensorColumn, error) {
+	for i, column := range es.sensorColumns() {
+		if column.metric == metric {
+			return firstSensorColumn + i, column, nil
+		}
+	}
+	return 0, sensorColumn{}, fmt.Errorf("no sensor data column for %s", metric)
+}
+
+// numberFormat returns the Excel number format showing the given number of decimals
+func numberFormat(decimals int) string {
+	if decimals <= 0 {
+		return "0"
+	}
+	return "0." + strings.Repeat("0", decimals)
 }
 
 // ExportData represents data to be exported
@@ -23,6 +77,8 @@ type ExportData struct {
 	SensorReadings          []models.SensorReading
 	WaterQualityAssessments []models.WaterQualityStatus
 	FiltrationHistory       []FiltrationRecord
+	FilterHealthHistory     []models.FilterHealth
+	Anomalies               []models.AnomalyDetection
 	ExportMetadata          ExportMetadata
 }
 
@@ -46,6 +102,7 @@ type ExportMetadata struct {
 	TotalReadings int       `json:"total_readings"`
 	FilterModes   []string  `json:"filter_modes"`
 	DeviceInfo    string    `json:"device_info"`
+	FlowUnit      string    `json:"flow_unit,omitempty"` // Unit of flow values in the export
 }
 
 // GenerateExcel creates an Excel file with purification history
@@ -77,12 +134,25 @@ func (es *ExportService) GenerateExcel(data ExportData) (*excelize.File, error)
 	// Create Sensor Data sheet
 	es.createSensorDataSheet(f, data.SensorReadings)
 
+	// Create Charts sheet plotting the sensor data
+	if err := es.createChartsSheet(f, len(data.SensorReadings)); err != nil {
+		return nil, err
+	}
+
 	// Create Filtration History sheet
 	es.createFiltrationHistorySheet(f, data.FiltrationHistory)
 
 	// Create Water Quality Analysis sheet
 	es.createWaterQualitySheet(f, data.WaterQualityAssessments)
 
+	// Create ML sheets, only when there is something to show
+	if len(data.FilterHealthHistory) > 0 {
+		es.createFilterHealthSheet(f, data.FilterHealthHistory)
+	}
+	if len(data.Anomalies) > 0 {
+		es.createAnomaliesSheet(f, data.Anomalies)
+	}
+
 	// Set active sheet to Summary
 	f.SetActiveSheet(0)
 
@@ -131,6 +201,10 @@ func (es *ExportService) createSummarySheet(f *excelize.File, data ExportData) e
 	f.SetCellValue(sheetName, "B9", len(data.FiltrationHistory))
 	f.SetCellValue(sheetName, "A10", "Quality Assessments:")
 	f.SetCellValue(sheetName, "B10", len(data.WaterQualityAssessments))
+	f.SetCellValue(sheetName, "A11", "Filter Health Records:")
+	f.SetCellValue(sheetName, "B11", len(data.FilterHealthHistory))
+	f.SetCellValue(sheetName, "A12", "Anomalies:")
+	f.SetCellValue(sheetName, "B12", len(data.Anomalies))
 
 	// Column widths
 	f.SetColWidth(sheetName, "A", "A", 20)
@@ -145,7 +219,11 @@ func (es *ExportService) createSensorDataSheet(f *excelize.File, readings []mode
 	f.NewSheet(sheetName)
 
 	// Headers
-	headers := []string{"Timestamp", "Filter Mode", "Flow (L/min)", "pH", "Turbidity (NTU)", "TDS (ppm)"}
+	columns := es.sensorColumns()
+	headers := []string{"Timestamp", "Filter Mode"}
+	for _, column := range columns {
+		headers = append(headers, column.header)
+	}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -170,10 +248,24 @@ func (es *ExportService) createSensorDataSheet(f *excelize.File, readings []mode
 		row := i + 2
 		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), reading.Timestamp.Format("2006-01-02 15:04:05"))
 		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), reading.FilterMode)
-		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), reading.Flow)
-		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), reading.Ph)
-		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), reading.Turbidity)
-		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), reading.TDS)
+		for j, column := range columns {
+			cell, _ := excelize.CoordinatesToCellName(firstSensorColumn+j, row)
+			f.SetCellValue(sheetName, cell, column.value(reading))
+		}
+	}
+
+	// Number formats matching each measurement's precision
+	if len(readings) > 0 {
+		for j, column := range columns {
+			format := numberFormat(column.decimals)
+			style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+			if err != nil {
+				continue
+			}
+			first, _ := excelize.CoordinatesToCellName(firstSensorColumn+j, 2)
+			last, _ := excelize.CoordinatesToCellName(firstSensorColumn+j, len(readings)+1)
+			f.SetCellStyle(sheetName, first, last, style)
+		}
 	}
 
 	// Format columns
@@ -183,6 +275,68 @@ func (es *ExportService) createSensorDataSheet(f *excelize.File, readings []mode
 	return nil
 }
 
+// createChartsSheet creates a sheet with a line chart of pH, turbidity and TDS
+// over time, referencing the rows of the Sensor Data sheet. TDS is plotted on a
+// secondary axis since it is hundreds of times larger than the other metrics.
+// The chart widens with the number of rows; with fewer than two rows there is
+// no line to draw and the sheet is skipped.
+func (es *ExportService) createChartsSheet(f *excelize.File, rows int) error {
+	if rows < 2 {
+		return nil
+	}
+
+	sheetName := "Charts"
+	f.NewSheet(sheetName)
+
+	dataRange := func(col int) string {
+		first, _ := excelize.CoordinatesToCellName(col, 2, true)
+		last, _ := excelize.CoordinatesToCellName(col, rows+1, true)
+		return fmt.Sprintf("'Sensor Data'!%s:%s", first, last)
+	}
+	series := func(col int) excelize.ChartSeries {
+		header, _ := excelize.CoordinatesToCellName(col, 1, true)
+		return excelize.ChartSeries{
+			Name:       "'Sensor Data'!" + header,
+			Categories: dataRange(1),
+			Values:     dataRange(col),
+			Marker:     excelize.ChartMarker{Symbol: "none"},
+		}
+	}
+	phCol, ph, err := es.sensorDataColumn("ph")
+	if err != nil {
+		return err
+	}
+	turbidityCol, turbidity, err := es.sensorDataColumn("turbidity")
+	if err != nil {
+		return err
+	}
+	tdsCol, tds, err := es.sensorDataColumn("tds")
+	if err != nil {
+		return err
+	}
+
+	width := uint(480 + rows*4)
+	if width > 1600 {
+		width = 1600
+	}
+	dimension := excelize.ChartDimension{Width: width, Height: 360}
+
+	return f.AddChart(sheetName, "A1", &excelize.Chart{
+		Type:      excelize.Line,
+		Series:    []excelize.ChartSeries{series(phCol), series(turbidityCol)},
+		Title:     []excelize.RichTextRun{{Text: "Water Quality Over Time"}},
+		Legend:    excelize.ChartLegend{Position: "bottom"},
+		Dimension: dimension,
+		XAxis:     excelize.ChartAxis{TickLabelSkip: (rows + 9) / 10},
+		YAxis:     excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: ph.header + " / " + turbidity.header}}},
+	}, &excelize.Chart{
+		Type:      excelize.Line,
+		Series:    []excelize.ChartSeries{series(tdsCol)},
+		Dimension: dimension,
+		YAxis:     excelize.ChartAxis{Secondary: true, Title: []excelize.RichTextRun{{Text: tds.header}}},
+	})
+}
+
 // createFiltrationHistorySheet creates the filtration sessions sheet
 func (es *ExportService) createFiltrationHistorySheet(f *excelize.File, history []FiltrationRecord) error {
 	sheetName := "Filtration History"
@@ -276,22 +430,130 @@ func (es *ExportService) createWaterQualitySheet(f *excelize.File, assessments [
 	return nil
 }
 
+// createFilterHealthSheet creates the filter health history sheet
+func (es *ExportService) createFilterHealthSheet(f *excelize.File, history []models.FilterHealth) error {
+	sheetName := "Filter Health"
+	f.NewSheet(sheetName)
+
+	// Headers
+	headers := []string{"Calculated At", "Device", "Filter Mode", "Health Score", "Days Remaining", "Current Efficiency (%)", "Trend", "Turbidity Reduction (%)", "TDS Reduction (%)", "Maintenance Required", "Replacement Urgent"}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+	}
+
+	// Header styling
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"2E75B6"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center"},
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+	})
+	f.SetCellStyle(sheetName, "A1", "K1", headerStyle)
+
+	// Data rows
+	for i, health := range history {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), health.LastCalculated.Format("2006-01-02 15:04:05"))
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), health.DeviceID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), health.FilterMode)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), health.HealthScore)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), health.PredictedDaysRemaining)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), health.CurrentEfficiency)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), health.EfficiencyTrend)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), health.TurbidityReduction)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), health.TDSReduction)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), health.MaintenanceRequired)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), health.ReplacementUrgent)
+	}
+
+	// Format columns
+	f.SetColWidth(sheetName, "A", "A", 20)
+	f.SetColWidth(sheetName, "B", "K", 15)
+
+	return nil
+}
+
+// createAnomaliesSheet creates the detected anomalies sheet
+func (es *ExportService) createAnomaliesSheet(f *excelize.File, anomalies []models.AnomalyDetection) error {
+	sheetName := "Anomalies"
+	f.NewSheet(sheetName)
+
+	// Headers
+	headers := []string{"Detected At", "Device", "Filter Mode", "Type", "Severity", "Metric", "Expected Value", "Actual Value", "Deviation (%)", "Status", "Description"}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+	}
+
+	// Header styling
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"C00000"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center"},
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+	})
+	f.SetCellStyle(sheetName, "A1", "K1", headerStyle)
+
+	// Data rows
+	for i, anomaly := range anomalies {
+		row := i + 2
+		status := "Unresolved"
+		if anomaly.IsFalsePositive {
+			status = "False Positive"
+		} else if anomaly.ResolvedAt != nil {
+			status = "Resolved " + anomaly.ResolvedAt.Format("2006-01-02 15:04:05")
+		}
+
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), anomaly.DetectedAt.Format("2006-01-02 15:04:05"))
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), anomaly.DeviceID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), anomaly.FilterMode)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), anomaly.AnomalyType)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), anomaly.Severity)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), anomaly.AffectedMetric)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), anomaly.ExpectedValue)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), anomaly.ActualValue)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), anomaly.Deviation)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), status)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), anomaly.Description)
+	}
+
+	// Format columns
+	f.SetColWidth(sheetName, "A", "A", 20)
+	f.SetColWidth(sheetName, "B", "J", 15)
+	f.SetColWidth(sheetName, "K", "K", 50)
+
+	return nil
+}
+
 // GenerateCSV creates CSV data for sensor readings
 func (es *ExportService) GenerateCSV(readings []models.SensorReading) ([][]string, error) {
 	// CSV headers
-	records := [][]string{
-		{"Timestamp", "Filter Mode", "Flow (L/min)", "pH", "Turbidity (NTU)", "TDS (ppm)"},
+	columns := es.sensorColumns()
+	header := []string{"Timestamp", "Filter Mode"}
+	for _, column := range columns {
+		header = append(header, column.header)
 	}
+	records := [][]string{header}
 
 	// Add data rows
 	for _, reading := range readings {
 		record := []string{
 			reading.Timestamp.Format("2006-01-02 15:04:05"),
 			string(reading.FilterMode),
-			strconv.FormatFloat(reading.Flow, 'f', 2, 64),
-			strconv.FormatFloat(reading.Ph, 'f', 2, 64),
-			strconv.FormatFloat(reading.Turbidity, 'f', 2, 64),
-			strconv.FormatFloat(reading.TDS, 'f', 1, 64),
+		}
+		for _, column := range columns {
+			record = append(record, strconv.FormatFloat(column.value(reading), 'f', column.decimals, 64))
 		}
 		records = append(records, record)
 	}
@@ -302,4 +564,80 @@ func (es *ExportService) GenerateCSV(readings []models.SensorReading) ([][]strin
 // WriteCSV writes CSV data to a writer
 func (es *ExportService) WriteCSV(w *csv.Writer, records [][]string) error {
 	return w.WriteAll(records)
-}
\ No newline at end of file
+}
+
+// ReadingPageFunc returns up to limit readings following the cursor in export
+// order; a nil cursor starts at the first reading
+type ReadingPageFunc func(after *models.ReadingCursor, limit int) ([]models.SensorReading, error)
+
+// jsonExportPageSize is how many readings WriteJSON fetches at a time
+const jsonExportPageSize = 1000
+
+// WriteJSON streams a JSON export object with the metadata, the sensor readings
+// and a water quality assessment per reading to w. The readings are fetched a
+// page at a time, once for each array, and encoded as they arrive, so neither
+// the readings nor the output are ever held in memory as a whole. Flow values
+// are written as stored, in L/min.
+func (es *ExportService) WriteJSON(w io.Writer, metadata ExportMetadata, pages ReadingPageFunc) error {
+	metadata.FlowUnit = string(models.FlowUnitLitersPerMinute)
+	encoder := json.NewEncoder(w)
+
+	if _, err := io.WriteString(w, `{"export_metadata":`); err != nil {
+		return err
+	}
+	if err := encoder.Encode(metadata); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `,"sensor_readings":[`); err != nil {
+		return err
+	}
+	err := writeJSONPages(w, encoder, pages, func(reading models.SensorReading) interface{} {
+		return reading
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `],"water_quality_assessments":[`); err != nil {
+		return err
+	}
+	err = writeJSONPages(w, encoder, pages, func(reading models.SensorReading) interface{} {
+		return reading.ToWaterQualityStatus()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// writeJSONPages encodes element(reading) for every reading of pages as comma
+// separated JSON array elements
+func writeJSONPages(w io.Writer, encoder *json.Encoder, pages ReadingPageFunc, element func(models.SensorReading) interface{}) error {
+	var after *models.ReadingCursor
+	first := true
+	for {
+		readings, err := pages(after, jsonExportPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to fetch readings: %w", err)
+		}
+		for _, reading := range readings {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if err := encoder.Encode(element(reading)); err != nil {
+				return err
+			}
+		}
+		if len(readings) < jsonExportPageSize {
+			return nil
+		}
+		cursor := models.CursorAfter(readings[len(readings)-1])
+		after = &cursor
+	}
+}
@@ -0,0 +1,323 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+func TestExport_FlowUnitDrivesHeadersAndValues(t *testing.T) {
+	readings := []models.SensorReading{{
+		DeviceID:   "stm32_post",
+		Timestamp:  time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC),
+		FilterMode: models.FilterModeDrinking,
+		Flow:       37.85411784, // 10 US gallons per minute
+		Ph:         7.2,
+		Turbidity:  0.5,
+		TDS:        48,
+	}}
+
+	tests := []struct {
+		unit   models.FlowUnit
+		header string
+		csv    string
+		excel  string
+	}{
+		{models.FlowUnitLitersPerMinute, "Flow (L/min)", "37.85", "37.85"},
+		{models.FlowUnitGallonsPerMinute, "Flow (GPM)", "10.00", "10.00"},
+	}
+
+	for _, tt := range tests {
+		service := NewExportService()
+		service.SetFlowUnit(tt.unit)
+
+		records, err := service.GenerateCSV(readings)
+		if err != nil {
+			t.Fatalf("%s: failed to generate CSV: %v", tt.unit, err)
+		}
+		if records[0][2] != tt.header || records[1][2] != tt.csv {
+			t.Errorf("%s: expected CSV flow column %q = %q, got %q = %q", tt.unit, tt.header, tt.csv, records[0][2], records[1][2])
+		}
+		if records[0][5] != "TDS (ppm)" || records[1][5] != "48.0" {
+			t.Errorf("%s: expected TDS unchanged, got %q = %q", tt.unit, records[0][5], records[1][5])
+		}
+
+		f, err := service.GenerateExcel(ExportData{SensorReadings: readings})
+		if err != nil {
+			t.Fatalf("%s: failed to generate Excel: %v", tt.unit, err)
+		}
+		header, _ := f.GetCellValue("Sensor Data", "C1")
+		value, _ := f.GetCellValue("Sensor Data", "C2")
+		if header != tt.header || value != tt.excel {
+			t.Errorf("%s: expected Excel flow column %q = %q, got %q = %q", tt.unit, tt.header, tt.excel, header, value)
+		}
+	}
+}
+
+func TestExport_UnknownFlowUnitIgnored(t *testing.T) {
+	service := NewExportService()
+	service.SetFlowUnit("m3/h")
+
+	records, _ := service.GenerateCSV(nil)
+	if records[0][2] != "Flow (L/min)" {
+		t.Errorf("Expected the default flow unit to be kept, got %q", records[0][2])
+	}
+}
+
+func TestGenerateExcel_MLSheetsOnlyWithData(t *testing.T) {
+	service := NewExportService()
+
+	f, err := service.GenerateExcel(ExportData{})
+	if err != nil {
+		t.Fatalf("Failed to generate Excel: %v", err)
+	}
+	for _, sheet := range f.GetSheetList() {
+		if sheet == "Filter Health" || sheet == "Anomalies" {
+			t.Errorf("Expected no %s sheet without data", sheet)
+		}
+	}
+
+	resolved := time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)
+	f, err = service.GenerateExcel(ExportData{
+		FilterHealthHistory: []models.FilterHealth{{DeviceID: "stm32_post", HealthScore: 82.5, PredictedDaysRemaining: 40, EfficiencyTrend: "stable"}},
+		Anomalies: []models.AnomalyDetection{
+			{DeviceID: "stm32_pre", AnomalyType: "spike", Severity: "high", AffectedMetric: "ph", ActualValue: 9.1},
+			{DeviceID: "stm32_pre", AnomalyType: "drift", Severity: "low", AffectedMetric: "tds", ResolvedAt: &resolved},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate Excel: %v", err)
+	}
+
+	if score, _ := f.GetCellValue("Filter Health", "D2"); score != "82.5" {
+		t.Errorf("Expected the health score in the Filter Health sheet, got %q", score)
+	}
+	if severity, _ := f.GetCellValue("Anomalies", "E2"); severity != "high" {
+		t.Errorf("Expected the severity in the Anomalies sheet, got %q", severity)
+	}
+	if status, _ := f.GetCellValue("Anomalies", "J3"); status != "Resolved 2024-06-02 09:00:00" {
+		t.Errorf("Expected the resolution in the Anomalies sheet, got %q", status)
+	}
+	if count, _ := f.GetCellValue("Summary", "B12"); count != "2" {
+		t.Errorf("Expected the anomaly count in the summary, got %q", count)
+	}
+}
+
+func TestGenerateExcel_ChartsSheetSizedToRows(t *testing.T) {
+	readings := func(n int) []models.SensorReading {
+		var result []models.SensorReading
+		for i := 0; i < n; i++ {
+			result = append(result, models.SensorReading{Timestamp: time.Date(2024, 6, 1, 8, i, 0, 0, time.UTC), Ph: 7, Turbidity: 1, TDS: 100})
+		}
+		return result
+	}
+
+	for _, n := range []int{0, 1} {
+		f, err := NewExportService().GenerateExcel(ExportData{SensorReadings: readings(n)})
+		if err != nil {
+			t.Fatalf("%d rows: expected no error, got %v", n, err)
+		}
+		if index, _ := f.GetSheetIndex("Charts"); index != -1 {
+			t.Errorf("%d rows: expected no Charts sheet", n)
+		}
+	}
+
+	f, err := NewExportService().GenerateExcel(ExportData{SensorReadings: readings(25)})
+	if err != nil {
+		t.Fatalf("Failed to generate Excel: %v", err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	var chart []byte
+	for _, file := range archive.File {
+		if file.Name == "xl/charts/chart1.xml" {
+			rc, _ := file.Open()
+			chart, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	if chart == nil {
+		t.Fatal("Expected a chart in the workbook")
+	}
+	for _, ref := range []string{"'Sensor Data'!$A$2:$A$26", "'Sensor Data'!$D$2:$D$26", "'Sensor Data'!$E$2:$E$26", "'Sensor Data'!$F$2:$F$26"} {
+		if !bytes.Contains(chart, []byte(strings.ReplaceAll(ref, "'", "&#39;"))) {
+			t.Errorf("Expected the chart to reference %s", ref)
+		}
+	}
+	for _, title := range []string{"pH / Turbidity (NTU)", "TDS (ppm)"} {
+		if !bytes.Contains(chart, []byte(title)) {
+			t.Errorf("Expected an axis titled from the column header %q", title)
+		}
+	}
+}
+
+// slicePages pages through readings like the store does and counts the calls
+func slicePages(readings []models.SensorReading, calls *int) ReadingPageFunc {
+	return func(after *models.ReadingCursor, limit int) ([]models.SensorReading, error) {
+		*calls++
+		i := 0
+		if after != nil {
+			for i < len(readings) && !after.IsPast(readings[i], true) {
+				i++
+			}
+		}
+		return readings[i:min(i+limit, len(readings))], nil
+	}
+}
+
+func TestWriteJSON_StreamsValidDocument(t *testing.T) {
+	readings := []models.SensorReading{
+		{DeviceID: "stm32_pre", Timestamp: time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC), FilterMode: models.FilterModeDrinking, Flow: 1.5, Ph: 7.2, Turbidity: 0.5, TDS: 48},
+		{DeviceID: "stm32_post", Timestamp: time.Date(2024, 6, 1, 8, 1, 0, 0, time.UTC), FilterMode: models.FilterModeDrinking, Flow: 1.5, Ph: 9.5, Turbidity: 0.5, TDS: 48},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		readings []models.SensorReading
+	}{{"readings", readings}, {"empty", nil}} {
+		var buf bytes.Buffer
+		var calls int
+		if err := NewExportService().WriteJSON(&buf, ExportMetadata{TotalReadings: len(tt.readings)}, slicePages(tt.readings, &calls)); err != nil {
+			t.Fatalf("%s: failed to write JSON: %v", tt.name, err)
+		}
+
+		var decoded struct {
+			ExportMetadata          ExportMetadata              `json:"export_metadata"`
+			SensorReadings          []models.SensorReading      `json:"sensor_readings"`
+			WaterQualityAssessments []models.WaterQualityStatus `json:"water_quality_assessments"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s: export is not valid JSON: %v\n%s", tt.name, err, buf.String())
+		}
+		if len(decoded.SensorReadings) != len(tt.readings) || len(decoded.WaterQualityAssessments) != len(tt.readings) {
+			t.Errorf("%s: expected %d readings and assessments, got %d and %d", tt.name, len(tt.readings),
+				len(decoded.SensorReadings), len(decoded.WaterQualityAssessments))
+		}
+		if decoded.ExportMetadata.FlowUnit != "L/min" {
+			t.Errorf("%s: expected the flow unit in the metadata, got %q", tt.name, decoded.ExportMetadata.FlowUnit)
+		}
+	}
+
+	var buf bytes.Buffer
+	var calls int
+	NewExportService().WriteJSON(&buf, ExportMetadata{}, slicePages(readings, &calls))
+	if !bytes.Contains(buf.Bytes(), []byte(`"overall_quality":"Danger"`)) {
+		t.Errorf("Expected the basic reading to be assessed as dangerous, got %s", buf.String())
+	}
+}
+
+func TestWriteJSON_PagesThroughReadings(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	readings := make([]models.SensorReading, 2*jsonExportPageSize+1)
+	for i := range readings {
+		readings[i] = models.SensorReading{DeviceID: "stm32_post", Timestamp: start.Add(time.Duration(i) * time.Minute), Ph: 7, TDS: 100}
+	}
+
+	var buf bytes.Buffer
+	var calls int
+	if err := NewExportService().WriteJSON(&buf, ExportMetadata{}, slicePages(readings, &calls)); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	if calls != 6 {
+		t.Errorf("Expected 3 pages for each array, got %d calls", calls)
+	}
+
+	var decoded struct {
+		SensorReadings          []models.SensorReading      `json:"sensor_readings"`
+		WaterQualityAssessments []models.WaterQualityStatus `json:"water_quality_assessments"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+	if len(decoded.SensorReadings) != len(readings) || len(decoded.WaterQualityAssessments) != len(readings) {
+		t.Fatalf("Expected %d readings and assessments, got %d and %d", len(readings),
+			len(decoded.SensorReadings), len(decoded.WaterQualityAssessments))
+	}
+	for i, reading := range decoded.SensorReadings {
+		if !reading.Timestamp.Equal(readings[i].Timestamp) {
+			t.Fatalf("Reading %d out of order: got %s, want %s", i, reading.Timestamp, readings[i].Timestamp)
+		}
+	}
+}
+
+// checkPDFStructure verifies every xref entry points at its object and returns the page count
+func checkPDFStructure(t *testing.T, pdf []byte) int {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("Missing PDF header or trailer")
+	}
+
+	start := bytes.LastIndex(pdf, []byte("startxref\n"))
+	xref, _ := strconv.Atoi(string(bytes.Fields(pdf[start+len("startxref\n"):])[0]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Fatalf("xref entry %d does not point at %q", i+1, want)
+		}
+	}
+
+	count := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(pdf)
+	pages, _ := strconv.Atoi(string(count[1]))
+	return pages
+}
+
+func TestGeneratePDF_EmptyRangeIsOnePageNotingNoData(t *testing.T) {
+	pdf, err := NewExportService().GeneratePDF(ExportData{ExportMetadata: ExportMetadata{DateRange: "2024-06-01 to 2024-06-02"}})
+	if err != nil {
+		t.Fatalf("Failed to generate PDF: %v", err)
+	}
+	if pages := checkPDFStructure(t, pdf); pages != 1 {
+		t.Errorf("Expected a single page, got %d", pages)
+	}
+	if !bytes.Contains(pdf, []byte("No data")) || !bytes.Contains(pdf, []byte("2024-06-01 to 2024-06-02")) {
+		t.Errorf("Expected the date range and a no data note in the PDF")
+	}
+}
+
+func TestGeneratePDF_LongHistorySpansPages(t *testing.T) {
+	base := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	data := ExportData{}
+	for i := 0; i < 120; i++ {
+		data.WaterQualityAssessments = append(data.WaterQualityAssessments, models.WaterQualityStatus{
+			FilterMode: models.FilterModeDrinking, Ph: 7, OverallQuality: "Good",
+		})
+		data.FiltrationHistory = append(data.FiltrationHistory, FiltrationRecord{
+			ID: i + 1, StartTime: base.AddDate(0, 0, i), EndTime: base.AddDate(0, 0, i).Add(time.Hour),
+			FilterMode: "drinking (pre)", TargetVolume: 5, Status: "completed",
+		})
+	}
+
+	pdf, err := NewExportService().GeneratePDF(data)
+	if err != nil {
+		t.Fatalf("Failed to generate PDF: %v", err)
+	}
+	if pages := checkPDFStructure(t, pdf); pages < 3 {
+		t.Errorf("Expected 120 sessions to span at least 3 pages, got %d", pages)
+	}
+	if !bytes.Contains(pdf, []byte(`drinking \(pre\)`)) {
+		t.Errorf("Expected parentheses in cell text to be escaped")
+	}
+	if got := bytes.Count(pdf, []byte("(Start Time)")); got < 3 {
+		t.Errorf("Expected the history header repeated on each page, found it %d times", got)
+	}
+}
@@ -0,0 +1,287 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// PDF page geometry in points (US Letter)
+const (
+	pdfPageWidth  = 612.0
+	pdfPageHeight = 792.0
+	pdfMargin     = 50.0
+	pdfLineHeight = 14.0
+)
+
+// PDF fonts, registered on every page under these resource names
+const (
+	pdfFontRegular = "F1" // Helvetica
+	pdfFontBold    = "F2" // Helvetica-Bold
+)
+
+// pdfColumn is one column of a PDF table
+type pdfColumn struct {
+	header string
+	width  float64 // Points
+}
+
+// pdfDocument lays out text top to bottom over as many pages as needed and
+// serializes it as a minimal PDF using the standard Type 1 fonts, which every
+// viewer provides, so no fonts are embedded
+type pdfDocument struct {
+	pages []*bytes.Buffer // Content stream of each page
+	y     float64         // Baseline of the next line on the current page
+}
+
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.newPage()
+	return doc
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// ensureSpace starts a new page unless height points fit above the bottom margin
+func (d *pdfDocument) ensureSpace(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+}
+
+// text draws s at x on the current line
+func (d *pdfDocument) text(x float64, font string, size float64, s string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, pdfEscape(s))
+}
+
+// line writes s on its own line and moves down
+func (d *pdfDocument) line(font string, size float64, s string) {
+	d.ensureSpace(pdfLineHeight)
+	d.text(pdfMargin, font, size, s)
+	d.y -= pdfLineHeight
+}
+
+// rule draws a horizontal line across the page just below the current line
+func (d *pdfDocument) rule() {
+	y := d.y + pdfLineHeight - 4
+	fmt.Fprintf(d.pages[len(d.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, y, pdfPageWidth-pdfMargin, y)
+}
+
+// gap leaves vertical space
+func (d *pdfDocument) gap(height float64) {
+	d.y -= height
+}
+
+// row writes one table row, truncating cells to their column width
+func (d *pdfDocument) row(columns []pdfColumn, font string, cells []string) {
+	x := pdfMargin
+	for i, column := range columns {
+		if i < len(cells) {
+			d.text(x, font, 9, truncateToWidth(cells[i], column.width, 9))
+		}
+		x += column.width
+	}
+	d.y -= pdfLineHeight
+}
+
+// table writes a table, repeating the header row at the top of each new page
+func (d *pdfDocument) table(columns []pdfColumn, rows [][]string) {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.header
+	}
+
+	d.ensureSpace(2 * pdfLineHeight)
+	d.row(columns, pdfFontBold, headers)
+	d.rule()
+	for _, cells := range rows {
+		if d.y-pdfLineHeight < pdfMargin {
+			d.newPage()
+			d.row(columns, pdfFontBold, headers)
+			d.rule()
+		}
+		d.row(columns, pdfFontRegular, cells)
+	}
+}
+
+// bytes serializes the document
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes
+	// two objects, the page and its content stream
+	pageRefs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageRefs[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageRefs, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pdfFontRegular, pdfFontBold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal string, replacing characters
+// the standard fonts' encoding can't show
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncateToWidth shortens s to fit width points at the given font size,
+// estimating Helvetica's average glyph width as half the font size
+func truncateToWidth(s string, width, size float64) string {
+	maxChars := int((width - 4) / (size * 0.5))
+	if len(s) <= maxChars || maxChars < 2 {
+		return s
+	}
+	return s[:maxChars-2] + ".."
+}
+
+// GeneratePDF creates a printable purification report with the export
+// metadata, a water quality summary per filter mode and the filtration history
+func (es *ExportService) GeneratePDF(data ExportData) ([]byte, error) {
+	doc := newPDFDocument()
+
+	// Title and metadata header
+	doc.line(pdfFontBold, 16, "AquaSmart Water Purification Report")
+	doc.gap(6)
+	doc.line(pdfFontRegular, 10, "Generated At: "+data.ExportMetadata.GeneratedAt.Format("2006-01-02 15:04:05"))
+	doc.line(pdfFontRegular, 10, "Date Range: "+data.ExportMetadata.DateRange)
+	doc.line(pdfFontRegular, 10, fmt.Sprintf("Total Readings: %d", data.ExportMetadata.TotalReadings))
+	if data.ExportMetadata.DeviceInfo != "" {
+		doc.line(pdfFontRegular, 10, "Device: "+data.ExportMetadata.DeviceInfo)
+	}
+	doc.gap(pdfLineHeight)
+
+	if len(data.WaterQualityAssessments) == 0 && len(data.FiltrationHistory) == 0 {
+		doc.line(pdfFontRegular, 11, "No data was recorded in this date range.")
+		return doc.bytes(), nil
+	}
+
+	// Water quality summary
+	doc.line(pdfFontBold, 12, "Water Quality Summary")
+	doc.gap(4)
+	doc.table([]pdfColumn{
+		{"Filter Mode", 90}, {"Readings", 52}, {"Avg pH", 50}, {"Avg Turbidity", 72},
+		{"Avg TDS", 52}, {"Excellent", 56}, {"Good", 45}, {"Danger", 45},
+	}, waterQualitySummaryRows(data.WaterQualityAssessments))
+	doc.gap(pdfLineHeight)
+
+	// Filtration history, oldest session first
+	history := append([]FiltrationRecord(nil), data.FiltrationHistory...)
+	sort.Slice(history, func(i, j int) bool { return history[i].StartTime.Before(history[j].StartTime) })
+
+	rows := make([][]string, 0, len(history))
+	for _, record := range history {
+		rows = append(rows, []string{
+			record.StartTime.Format("2006-01-02 15:04"),
+			record.EndTime.Format("2006-01-02 15:04"),
+			record.Duration,
+			record.FilterMode,
+			fmt.Sprintf("%.1f", record.TargetVolume),
+			fmt.Sprintf("%.2f", record.ProcessedVolume),
+			fmt.Sprintf("%.1f%%", record.Progress),
+			record.Status,
+		})
+	}
+	doc.ensureSpace(3 * pdfLineHeight)
+	doc.line(pdfFontBold, 12, "Filtration History")
+	doc.gap(4)
+	if len(rows) == 0 {
+		doc.line(pdfFontRegular, 10, "No filtration sessions in this date range.")
+	} else {
+		doc.table([]pdfColumn{
+			{"Start Time", 78}, {"End Time", 78}, {"Duration", 60}, {"Filter Mode", 80},
+			{"Target (L)", 50}, {"Processed (L)", 64}, {"Progress", 46}, {"Status", 56},
+		}, rows)
+	}
+
+	return doc.bytes(), nil
+}
+
+// waterQualitySummaryRows aggregates assessments per filter mode into rows of
+// the water quality summary table
+func waterQualitySummaryRows(assessments []models.WaterQualityStatus) [][]string {
+	type summary struct {
+		count                int
+		ph, turbidity, tds   float64
+		excellent, good, bad int
+	}
+	summaries := make(map[models.FilterMode]*summary)
+	modes := []models.FilterMode{}
+	for _, assessment := range assessments {
+		s, exists := summaries[assessment.FilterMode]
+		if !exists {
+			s = &summary{}
+			summaries[assessment.FilterMode] = s
+			modes = append(modes, assessment.FilterMode)
+		}
+		s.count++
+		s.ph += assessment.Ph
+		s.turbidity += assessment.Turbidity
+		s.tds += assessment.TDS
+		switch assessment.OverallQuality {
+		case "Excellent":
+			s.excellent++
+		case "Good":
+			s.good++
+		default:
+			s.bad++
+		}
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+
+	rows := make([][]string, 0, len(modes))
+	for _, mode := range modes {
+		s := summaries[mode]
+		n := float64(s.count)
+		rows = append(rows, []string{
+			string(mode),
+			fmt.Sprintf("%d", s.count),
+			fmt.Sprintf("%.2f", s.ph/n),
+			fmt.Sprintf("%.2f NTU", s.turbidity/n),
+			fmt.Sprintf("%.1f ppm", s.tds/n),
+			fmt.Sprintf("%d", s.excellent),
+			fmt.Sprintf("%d", s.good),
+			fmt.Sprintf("%d", s.bad),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,211 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned when a bearer token is rejected
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+)
+
+// defaultTokenTTL is how long issued tokens stay valid when no lifetime is configured
+const defaultTokenTTL = 24 * time.Hour
+
+// publicAPIPaths are the /api/v1 routes reachable without a token
+var publicAPIPaths = map[string]bool{
+	"/api/v1/health":     true,
+	"/api/v1/auth/login": true,
+	// Polled by the STM32, which can't log in; it only exposes the filter mode and LED state
+	"/api/v1/sensors/stm32/command": true,
+}
+
+// deviceKeyAPIPaths are the /api/v1 routes devices call with an X-Device-Key
+// instead of a token, since they can't log in; RequireDeviceKey checks the key
+var deviceKeyAPIPaths = map[string]bool{
+	"POST /api/v1/sensors/data": true,
+}
+
+// deviceKeyAuthKey marks a request RequireJWT let through on its device key alone
+type deviceKeyAuthKey struct{}
+
+// authenticatedByDeviceKey reports whether RequireJWT let the request through
+// without a token because it carries a device key
+func authenticatedByDeviceKey(r *http.Request) bool {
+	return r.Context().Value(deviceKeyAuthKey{}) != nil
+}
+
+// jwtHeader is the only JOSE header issued and accepted: HMAC-SHA256 signed JWTs
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// TokenClaims are the claims carried by API tokens
+type TokenClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// IssueToken creates an HS256-signed JWT for subject valid for ttl from now
+func IssueToken(secret []byte, subject string, ttl time.Duration, now time.Time) (string, error) {
+	claims, err := json.Marshal(TokenClaims{
+		Subject:   subject,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signingInput + "." + signToken(secret, signingInput), nil
+}
+
+// ParseToken verifies an HS256-signed JWT and returns its claims. Tokens signed
+// with any other algorithm, without an expiry or past their expiry are rejected.
+func ParseToken(secret []byte, token string, now time.Time) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if header.Alg != "HS256" {
+		return nil, ErrInvalidSignature
+	}
+
+	expected := signToken(secret, parts[0]+"."+parts[1])
+	if subtle.ConstantTimeCompare([]byte(parts[2]), []byte(expected)) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var claims TokenClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil || claims.ExpiresAt == 0 {
+		return nil, ErrMalformedToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+// signToken returns the base64url HMAC-SHA256 signature of a JWT signing input
+func signToken(secret []byte, signingInput string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// RequireJWT rejects API requests without a valid bearer JWT (Authorization:
+// Bearer <token>) with 401. The public API paths are always let through, and so
+// is the static admin token when one is configured, so operator endpoints keep
+// working with it. Device routes sent with an X-Device-Key and no token are left
+// to RequireDeviceKey. With no secret configured authentication is off.
+func RequireJWT(secret string, adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if secret == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions || publicAPIPaths[strings.TrimSuffix(r.URL.Path, "/")] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if (!ok || provided == "") && r.Header.Get(DeviceKeyHeader) != "" &&
+				deviceKeyAPIPaths[r.Method+" "+strings.TrimSuffix(r.URL.Path, "/")] {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), deviceKeyAuthKey{}, true)))
+				return
+			}
+			if !ok || provided == "" {
+				writeAPIError(w, http.StatusUnauthorized, "Missing bearer token")
+				return
+			}
+			if adminToken != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if _, err := ParseToken([]byte(secret), provided, time.Now()); err != nil {
+				writeAPIError(w, http.StatusUnauthorized, "Invalid bearer token: "+err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Login handles POST /api/v1/auth/login, exchanging the configured admin
+// credentials for a bearer token
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	if len(h.jwtSecret) == 0 || h.adminUsername == "" || h.adminPassword == "" {
+		h.sendErrorResponse(w, "Login is disabled: JWT secret or admin credentials not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	usernameOK := subtle.ConstantTimeCompare([]byte(request.Username), []byte(h.adminUsername)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(request.Password), []byte(h.adminPassword)) == 1
+	if !usernameOK || !passwordOK {
+		h.sendErrorResponse(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	ttl := h.tokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	now := time.Now()
+	token, err := IssueToken(h.jwtSecret, request.Username, ttl, now)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: "Login successful",
+		Data: map[string]interface{}{
+			"token":      token,
+			"token_type": "Bearer",
+			"expires_at": now.Add(ttl).UTC(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
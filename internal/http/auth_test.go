@@ -0,0 +1,252 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/Capstone-E1/aquasmart_backend/internal/ws"
+)
+
+const testJWTSecret = "test-secret"
+
+func newAuthRouter() http.Handler {
+	return SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{
+		AdminToken:    "admin-token",
+		JWTSecret:     testJWTSecret,
+		AdminUsername: "admin",
+		AdminPassword: "hunter2",
+	})
+}
+
+func authRequest(t *testing.T, router http.Handler, method, path, token, body string) (int, APIResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response APIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response for %s %s: %v (%s)", method, path, err, rec.Body.String())
+	}
+	return rec.Code, response
+}
+
+func TestParseToken_RejectsExpiredAndMalformedTokens(t *testing.T) {
+	secret := []byte(testJWTSecret)
+	now := time.Now()
+
+	valid, err := IssueToken(secret, "admin", time.Hour, now)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	claims, err := ParseToken(secret, valid, now)
+	if err != nil {
+		t.Fatalf("Expected a freshly issued token to be valid, got %v", err)
+	}
+	if claims.Subject != "admin" {
+		t.Errorf("Expected subject admin, got %q", claims.Subject)
+	}
+
+	expired, _ := IssueToken(secret, "admin", time.Hour, now.Add(-2*time.Hour))
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." +
+		strings.Split(valid, ".")[1] + "."
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", expired, ErrTokenExpired},
+		{"not three segments", "abc.def", ErrMalformedToken},
+		{"garbage header", "!!!.def.ghi", ErrMalformedToken},
+		{"tampered signature", valid[:len(valid)-2] + "xx", ErrInvalidSignature},
+		{"alg none", unsigned, ErrInvalidSignature},
+		{"wrong secret", func() string { tok, _ := IssueToken([]byte("other"), "admin", time.Hour, now); return tok }(), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(secret, tt.token, now); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRequireJWT_ProtectsAPIRoutes(t *testing.T) {
+	router := newAuthRouter()
+	valid, _ := IssueToken([]byte(testJWTSecret), "admin", time.Hour, time.Now())
+	expired, _ := IssueToken([]byte(testJWTSecret), "admin", time.Hour, time.Now().Add(-2*time.Hour))
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"missing token", "/api/v1/stats", "", http.StatusUnauthorized},
+		{"malformed token", "/api/v1/stats", "not-a-jwt", http.StatusUnauthorized},
+		{"expired token", "/api/v1/stats", expired, http.StatusUnauthorized},
+		{"valid token", "/api/v1/stats", valid, http.StatusOK},
+		{"admin token", "/api/v1/stats", "admin-token", http.StatusOK},
+		{"public health", "/api/v1/health", "", http.StatusOK},
+		{"public STM32 command poll", "/api/v1/sensors/stm32/command", "", http.StatusOK},
+		{"root health", "/health", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := authRequest(t, router, http.MethodGet, tt.path, tt.token, "")
+			if status != tt.status {
+				t.Fatalf("Expected status %d, got %d (%+v)", tt.status, status, response)
+			}
+			if status == http.StatusUnauthorized && (response.Success || response.Error == "") {
+				t.Errorf("Expected an APIResponse error body, got %+v", response)
+			}
+		})
+	}
+}
+
+func TestRequireJWT_DisabledWithoutSecret(t *testing.T) {
+	router := SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{})
+
+	if status, _ := authRequest(t, router, http.MethodGet, "/api/v1/stats", "", ""); status != http.StatusOK {
+		t.Errorf("Expected unauthenticated access without a JWT secret, got %d", status)
+	}
+}
+
+func TestLogin_IssuesUsableToken(t *testing.T) {
+	router := newAuthRouter()
+
+	status, _ := authRequest(t, router, http.MethodPost, "/api/v1/auth/login", "", `{"username":"admin","password":"wrong"}`)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for bad credentials, got %d", status)
+	}
+
+	status, response := authRequest(t, router, http.MethodPost, "/api/v1/auth/login", "", `{"username":"admin","password":"hunter2"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200 for valid credentials, got %d (%+v)", status, response)
+	}
+	data, _ := response.Data.(map[string]interface{})
+	token, _ := data["token"].(string)
+	if token == "" || data["token_type"] != "Bearer" {
+		t.Fatalf("Expected a bearer token in the response, got %+v", response.Data)
+	}
+
+	if status, _ := authRequest(t, router, http.MethodGet, "/api/v1/stats", token, ""); status != http.StatusOK {
+		t.Errorf("Expected the issued token to be accepted, got %d", status)
+	}
+}
+
+func TestRequireDeviceKey_ChecksClaimedDevice(t *testing.T) {
+	router := SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{AdminToken: "admin-token"})
+	post := func(deviceID, key string) int {
+		body := `{"device_id":"` + deviceID + `","filter_mode":"drinking_water","flow":1.2,"ph":7.1,"turbidity":0.5,"tds":120}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sensors/data", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(DeviceKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// No keys provisioned: ingestion stays open
+	if status := post("stm32_pre", ""); status != http.StatusOK {
+		t.Fatalf("Expected ingestion without keys to succeed, got %d", status)
+	}
+
+	status, response := authRequest(t, router, http.MethodPost, "/api/v1/devices/stm32_pre/key", "admin-token", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected key registration to succeed, got %d (%+v)", status, response)
+	}
+	data, _ := response.Data.(map[string]interface{})
+	key, _ := data["api_key"].(string)
+	if key == "" {
+		t.Fatalf("Expected the generated key in the response, got %+v", response.Data)
+	}
+
+	tests := []struct {
+		name     string
+		deviceID string
+		key      string
+		status   int
+	}{
+		{"matching key", "stm32_pre", key, http.StatusOK},
+		{"missing key", "stm32_pre", "", http.StatusForbidden},
+		{"wrong key", "stm32_pre", "not-the-key", http.StatusForbidden},
+		{"key for another device", "stm32_post", key, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if status := post(tt.deviceID, tt.key); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, status)
+		}
+	}
+}
+
+func TestRequireJWT_DeviceKeyIngestionWithoutToken(t *testing.T) {
+	router := newAuthRouter()
+	valid, _ := IssueToken([]byte(testJWTSecret), "admin", time.Hour, time.Now())
+	post := func(token, key string) int {
+		body := `{"device_id":"stm32_pre","filter_mode":"drinking_water","flow":1.2,"ph":7.1,"turbidity":0.5,"tds":120}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sensors/data", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if key != "" {
+			req.Header.Set(DeviceKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Without provisioned keys a device key proves nothing, so a token is required
+	if status := post("", "made-up-key"); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a device key before any are provisioned, got %d", status)
+	}
+	if status := post(valid, ""); status != http.StatusOK {
+		t.Errorf("Expected a token to allow ingestion before keys are provisioned, got %d", status)
+	}
+
+	_, response := authRequest(t, router, http.MethodPost, "/api/v1/devices/stm32_pre/key", "admin-token", "")
+	key, _ := response.Data.(map[string]interface{})["api_key"].(string)
+	if key == "" {
+		t.Fatalf("Expected the generated key in the response, got %+v", response)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		key    string
+		status int
+	}{
+		{"device key without token", "", key, http.StatusOK},
+		{"wrong device key without token", "", "not-the-key", http.StatusForbidden},
+		{"neither", "", "", http.StatusUnauthorized},
+		{"token without device key", valid, "", http.StatusForbidden},
+		{"token and device key", valid, key, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status := post(tt.token, tt.key); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, status)
+		}
+	}
+
+	// The device key only stands in for a token on the ingestion route
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set(DeviceKeyHeader, key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a device key on another route, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// DeviceKeyHeader carries a device's ingestion API key
+const DeviceKeyHeader = "X-Device-Key"
+
+// maxIngestBodyBytes bounds how much of an ingestion body is buffered to read the claimed device ID
+const maxIngestBodyBytes = 1 << 20
+
+// GenerateDeviceKey returns a new random device API key and its hash
+func GenerateDeviceKey() (key string, keyHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = hex.EncodeToString(buf)
+	return key, HashDeviceKey(key), nil
+}
+
+// HashDeviceKey returns the hex SHA-256 hash stored for a device API key
+func HashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequireDeviceKey checks the X-Device-Key header of ingestion requests against
+// the key provisioned for the device_id claimed in the JSON body, rejecting
+// missing keys, unknown devices and mismatches with 403. Until at least one
+// device has a key the check is skipped, so unprovisioned setups keep working;
+// a request RequireJWT only let through on its device key then needs a token.
+func RequireDeviceKey(dataStore store.DataStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provisioned, err := dataStore.CountDeviceAPIKeys()
+			if err != nil {
+				log.Printf("❌ Failed to check device API keys: %v", err)
+				writeAPIError(w, http.StatusInternalServerError, "Failed to check device credentials")
+				return
+			}
+			if provisioned == 0 {
+				if authenticatedByDeviceKey(r) {
+					writeAPIError(w, http.StatusUnauthorized, "Missing bearer token: no device keys are provisioned")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Buffer the body to read the claimed device ID, then hand it on untouched
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBodyBytes))
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var claim struct {
+				DeviceID string `json:"device_id"`
+			}
+			if err := json.Unmarshal(body, &claim); err != nil {
+				writeAPIError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+
+			provided := r.Header.Get(DeviceKeyHeader)
+			if provided == "" {
+				writeAPIError(w, http.StatusForbidden, "Missing "+DeviceKeyHeader+" header")
+				return
+			}
+
+			keyHash, exists, err := dataStore.GetDeviceAPIKeyHash(claim.DeviceID)
+			if err != nil {
+				log.Printf("❌ Failed to get API key for device %s: %v", claim.DeviceID, err)
+				writeAPIError(w, http.StatusInternalServerError, "Failed to check device credentials")
+				return
+			}
+			if !exists || subtle.ConstantTimeCompare([]byte(HashDeviceKey(provided)), []byte(keyHash)) != 1 {
+				writeAPIError(w, http.StatusForbidden, "Device key does not match device_id")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RegisterDeviceKey handles POST /api/v1/devices/{deviceID}/key, generating a
+// new ingestion API key for the device. The key is only returned here; any
+// previous key for the device stops working.
+func (h *Handlers) RegisterDeviceKey(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	key, keyHash, err := GenerateDeviceKey()
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to generate device key", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.storeFor(r).SetDeviceAPIKeyHash(deviceID, keyHash); err != nil {
+		h.sendErrorResponse(w, "Failed to register device key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: "Device key generated; store it now, it cannot be retrieved again",
+		Data: map[string]interface{}{
+			"device_id":  deviceID,
+			"api_key":    key,
+			"header":     DeviceKeyHeader,
+			"created_at": time.Now().UTC(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
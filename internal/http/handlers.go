@@ -2,13 +2,17 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/export"
@@ -22,29 +26,100 @@ import (
 
 // Handlers contains all HTTP request handlers
 type Handlers struct {
-	store         store.DataStore
-	exportService *export.ExportService
-	scheduler     *services.Scheduler
-	mqtt          *mqtt.Client
-  mlService     *ml.MLService
+	store          store.DataStore
+	exportService  *export.ExportService
+	scheduler      *services.Scheduler
+	mqtt           *mqtt.Client
+  mlService      *ml.MLService
+	deduplicator   *store.ReadingDeduplicator
+	clockSkew      *store.ClockSkewCorrector
+	expectedInterval time.Duration            // How often each device should send a reading (0 = inferred per device)
+	modeCooldown   *store.ModeChangeCooldown // Minimum interval between filter mode changes
+	targetVolumes  models.TargetVolumeBounds // Allowed filtration target volumes
+	exportMaxRange time.Duration // Longest date range a single export may cover (0 = unlimited)
+	exportDefaultDays int        // Days exported when no start date is given
+	allReadingsLimit int         // Most readings loaded by "all data" endpoints (0 = unlimited)
+	jwtSecret      []byte        // HS256 secret used to sign login tokens (empty = login disabled)
+	tokenTTL       time.Duration // Lifetime of issued tokens
+	adminUsername  string
+	adminPassword  string
+	storeBreaker   *store.CircuitBreaker // Circuit breaker guarding the data store (nil = none)
+	safetyMaxAge    time.Duration          // Age at which the latest drinking water reading is stale
+	stalenessPolicy models.StalenessPolicy // Safe-to-drink verdict on stale data
+	retention       *services.Retention    // Pruning of old data (nil = disabled)
 }
 
 // NewHandlers creates a new handlers instance
 func NewHandlers(dataStore store.DataStore, scheduler *services.Scheduler, mqttClient *mqtt.Client, mlService *ml.MLService) *Handlers {
 	return &Handlers{
-		store:         dataStore,
-		exportService: export.NewExportService(),
-		scheduler:     scheduler,
-		mqtt:          mqttClient,
-		mlService:     mlService,
+		store:          dataStore,
+		exportService:  export.NewExportService(),
+		scheduler:      scheduler,
+		mqtt:           mqttClient,
+		mlService:      mlService,
+		exportMaxRange: defaultExportMaxRange,
+		exportDefaultDays: defaultExportDays,
+		allReadingsLimit: defaultAllReadingsLimit,
+		targetVolumes:  models.DefaultTargetVolumeBounds,
+		safetyMaxAge:    models.DefaultSafetyMaxAge,
+		stalenessPolicy: models.StalenessFailSafe,
 	}
 }
 
+// defaultExportMaxRange is the longest date range a single export may cover
+const defaultExportMaxRange = 90 * 24 * time.Hour
+
+// defaultExportDays is how many days an export covers when no start date is given
+const defaultExportDays = 30
+
+// defaultAllReadingsLimit is the most readings the "all data" endpoints load
+const defaultAllReadingsLimit = 10000
+
+// allReadings returns the most recent readings up to the configured ceiling,
+// newest first, and whether older readings were left out because of it
+func (h *Handlers) allReadings(r *http.Request) ([]models.SensorReading, bool) {
+	limit := h.allReadingsLimit
+	if limit <= 0 {
+		// No ceiling: load every stored reading
+		count := h.storeFor(r).GetReadingCount()
+		if count == 0 {
+			return []models.SensorReading{}, false
+		}
+		return h.storeFor(r).GetRecentReadings(count), false
+	}
+
+	// Ask for one extra reading to tell whether the ceiling was hit
+	readings := h.storeFor(r).GetRecentReadings(limit + 1)
+	if len(readings) > limit {
+		return readings[:limit], true
+	}
+	return readings, false
+}
+
+// scoreCacheMaxAge is how long clients may cache the glanceable score; the
+// endpoint requires authentication, so shared caches must not store it
+const scoreCacheMaxAge = 60 * time.Second
+
+// maxLongPollTimeout caps how long a long-poll request may block
+const maxLongPollTimeout = 60 * time.Second
+
+// deviceOfflineAfter is how long a device may go without a reading before the
+// device overview reports it offline
+const deviceOfflineAfter = 10 * time.Minute
+
 // APIResponse represents a standard API response
+//
+// Response policy:
+//   - Collection endpoints (lists of readings, devices, statuses) always return 200
+//     with an empty collection and a count of 0 when there is no data
+//   - Single-resource lookups (a device's latest reading, a schedule, today's
+//     best/worst values) return 404 when the resource does not exist
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Count   *int        `json:"count,omitempty"` // Set for collection responses
+	Truncated bool      `json:"truncated,omitempty"` // Set when the result was cut at the all-readings ceiling
 	Error   string      `json:"error,omitempty"`
 }
 
@@ -57,13 +132,26 @@ func (h *Handlers) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Optional: Add database health check
-	if err := h.store.Ping(); err != nil {
+	if err := h.storeFor(r).Ping(); err != nil {
 		health["status"] = "unhealthy"
 		health["database"] = "error"
 		w.WriteHeader(http.StatusServiceUnavailable)
 	} else {
 		health["database"] = "connected"
 	}
+	if h.storeBreaker != nil {
+		health["database_circuit"] = h.storeBreaker.State()
+	}
+
+	// The broker is optional, so losing it is reported without failing the check
+	switch {
+	case h.mqtt == nil:
+		health["mqtt"] = "disabled"
+	case h.mqtt.Connected():
+		health["mqtt"] = "connected"
+	default:
+		health["mqtt"] = "disconnected"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(health)
@@ -76,7 +164,7 @@ func (h *Handlers) GetLatestReadings(w http.ResponseWriter, r *http.Request) {
 
 	// If device_id is specified, return reading for that device
 	if deviceID != "" {
-		reading, exists := h.store.GetLatestReadingByDevice(deviceID)
+		reading, exists := h.storeFor(r).GetLatestReadingByDevice(deviceID)
 		if !exists {
 			h.sendErrorResponse(w, "No sensor data available for specified device", http.StatusNotFound)
 			return
@@ -100,7 +188,7 @@ func (h *Handlers) GetLatestReadings(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		reading, exists := h.store.GetLatestReadingByMode(filterMode)
+		reading, exists := h.storeFor(r).GetLatestReadingByMode(filterMode)
 		if !exists {
 			h.sendErrorResponse(w, "No sensor data available for specified filter mode", http.StatusNotFound)
 			return
@@ -117,11 +205,56 @@ func (h *Handlers) GetLatestReadings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return latest reading overall or all latest readings by mode
-	readings := h.store.GetAllLatestReadings()
+	readings := nonNilReadings(h.storeFor(r).GetAllLatestReadings())
+
+	h.sendCollectionResponse(w, readings, len(readings))
+}
+
+// GetLatestReadingLongPoll handles GET /api/v1/sensors/latest/longpoll
+// Blocks until a reading newer than ?since= (RFC3339, default now) is stored or ?timeout= (default 25s) expires
+func (h *Handlers) GetLatestReadingLongPoll(w http.ResponseWriter, r *http.Request) {
+	since := time.Now()
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		parsedSince, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			h.sendErrorResponse(w, "Invalid since format. Use RFC3339 format", http.StatusBadRequest)
+			return
+		}
+		since = parsedSince
+	}
+
+	timeout := 25 * time.Second
+	if timeoutStr := r.URL.Query().Get("timeout"); timeoutStr != "" {
+		parsedTimeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || parsedTimeout <= 0 {
+			h.sendErrorResponse(w, "Invalid timeout. Use a duration such as '25s'", http.StatusBadRequest)
+			return
+		}
+		timeout = parsedTimeout
+	}
+	if timeout > maxLongPollTimeout {
+		timeout = maxLongPollTimeout
+	}
+
+	// Allow the response to outlive the server's default write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second))
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	reading, ok := h.storeFor(r).WaitForNewReading(ctx, since)
+	if !ok {
+		if r.Context().Err() != nil {
+			// Client went away, nothing to respond to
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
 	response := APIResponse{
 		Success: true,
-		Data:    readings,
+		Data:    reading,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -140,7 +273,7 @@ func (h *Handlers) GetWaterQualityStatus(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		status, exists := h.store.GetWaterQualityStatusByMode(filterMode)
+		status, exists := h.storeFor(r).GetWaterQualityStatusByMode(filterMode)
 		if !exists {
 			h.sendErrorResponse(w, "No sensor data available for specified filter mode", http.StatusNotFound)
 			return
@@ -157,15 +290,12 @@ func (h *Handlers) GetWaterQualityStatus(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return status for all filter modes
-	statuses := h.store.GetAllWaterQualityStatus()
-
-	response := APIResponse{
-		Success: true,
-		Data:    statuses,
+	statuses := h.storeFor(r).GetAllWaterQualityStatus()
+	if statuses == nil {
+		statuses = []models.WaterQualityStatus{}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.sendCollectionResponse(w, statuses, len(statuses))
 }
 
 // GetRecentReadings returns recent sensor readings (optionally filtered by mode or device)
@@ -185,7 +315,7 @@ func (h *Handlers) GetRecentReadings(w http.ResponseWriter, r *http.Request) {
 
 	// If device_id is specified, filter by device
 	if deviceID != "" {
-		readings = h.store.GetRecentReadingsByDevice(deviceID, limit)
+		readings = h.storeFor(r).GetRecentReadingsByDevice(deviceID, limit)
 	} else if filterModeStr != "" {
 		// Return readings for specific filter mode
 		filterMode := models.FilterMode(filterModeStr)
@@ -194,19 +324,14 @@ func (h *Handlers) GetRecentReadings(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		readings = h.store.GetRecentReadingsByMode(filterMode, limit)
+		readings = h.storeFor(r).GetRecentReadingsByMode(filterMode, limit)
 	} else {
 		// Return all recent readings
-		readings = h.store.GetRecentReadings(limit)
+		readings = h.storeFor(r).GetRecentReadings(limit)
 	}
 
-	response := APIResponse{
-		Success: true,
-		Data:    readings,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	readings = nonNilReadings(readings)
+	h.sendCollectionResponse(w, readings, len(readings))
 }
 
 // GetReadingsInRange returns sensor readings within a time range
@@ -236,22 +361,22 @@ func (h *Handlers) GetReadingsInRange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	readings := h.store.GetReadingsInRange(start, end)
-
-	response := APIResponse{
-		Success: true,
-		Data:    readings,
+	// With a cursor parameter, return one keyset page instead of the whole range
+	if r.URL.Query().Has("cursor") {
+		h.sendReadingsPageAfter(w, r)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	readings := nonNilReadings(h.storeFor(r).GetReadingsInRange(start, end))
+
+	h.sendCollectionResponse(w, readings, len(readings))
 }
 
 // GetSystemStats returns system statistics
 func (h *Handlers) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 	stats := map[string]interface{}{
-		"total_readings": h.store.GetReadingCount(),
-		"active_devices": len(h.store.GetActiveDevices()),
+		"total_readings": h.storeFor(r).GetReadingCount(),
+		"active_devices": len(h.storeFor(r).GetActiveDevices()),
 		"server_time":    time.Now(),
 	}
 
@@ -267,7 +392,7 @@ func (h *Handlers) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 // DeleteAllSensorData deletes all sensor readings from the database
 func (h *Handlers) DeleteAllSensorData(w http.ResponseWriter, r *http.Request) {
 	// Call the store method to delete all sensor readings
-	err := h.store.DeleteAllSensorReadings()
+	err := h.storeFor(r).DeleteAllSensorReadings()
 	if err != nil {
 		h.sendErrorResponse(w, fmt.Sprintf("Failed to delete sensor data: %v", err), http.StatusInternalServerError)
 		return
@@ -284,6 +409,61 @@ func (h *Handlers) DeleteAllSensorData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// PruneOldData deletes the readings, anomalies and sensor predictions older
+// than the retention period now instead of waiting for the next scheduled run
+func (h *Handlers) PruneOldData(w http.ResponseWriter, r *http.Request) {
+	if !h.retention.IsEnabled() {
+		h.sendErrorResponse(w, "Data retention is disabled: DATA_RETENTION_DAYS is 0", http.StatusServiceUnavailable)
+		return
+	}
+
+	result, err := h.retention.Prune(time.Now())
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to prune old data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Deleted %d rows older than %d days", result.Total(), h.retention.Days()),
+		Data:    result,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendCollectionResponse sends a 200 response for a collection endpoint with its item count
+func (h *Handlers) sendCollectionResponse(w http.ResponseWriter, data interface{}, count int) {
+	response := APIResponse{
+		Success: true,
+		Data:    data,
+		Count:   &count,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// sortReadingsByTimestamp sorts readings in place, oldest first when asc is true
+// and newest first otherwise. Readings with equal timestamps keep their order.
+func sortReadingsByTimestamp(readings []models.SensorReading, asc bool) {
+	sort.SliceStable(readings, func(i, j int) bool {
+		if asc {
+			return readings[i].Timestamp.Before(readings[j].Timestamp)
+		}
+		return readings[i].Timestamp.After(readings[j].Timestamp)
+	})
+}
+
+// nonNilReadings ensures an empty reading list is encoded as [] instead of null
+func nonNilReadings(readings []models.SensorReading) []models.SensorReading {
+	if readings == nil {
+		return []models.SensorReading{}
+	}
+	return readings
+}
+
 // sendErrorResponse sends a standardized error response
 func (h *Handlers) sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	response := APIResponse{
@@ -296,15 +476,36 @@ func (h *Handlers) sendErrorResponse(w http.ResponseWriter, message string, stat
 	json.NewEncoder(w).Encode(response)
 }
 
+// sensorReadingRequest is the body of manually submitted sensor readings
+type sensorReadingRequest struct {
+	DeviceID   string     `json:"device_id"`
+	FilterMode string     `json:"filter_mode"`
+	Timestamp  *time.Time `json:"timestamp,omitempty"` // Optional device timestamp, used only with clock-skew handling on
+	Flow       float64    `json:"flow"`
+	Ph         float64    `json:"ph"`
+	Turbidity  float64    `json:"turbidity"`
+	TDS        float64    `json:"tds"`
+}
+
+// toReading converts the request into a sensor reading
+func (req sensorReadingRequest) toReading() models.SensorReading {
+	reading := models.SensorReading{
+		DeviceID:   req.DeviceID,
+		FilterMode: models.FilterMode(req.FilterMode),
+		Flow:       req.Flow,
+		Ph:         req.Ph,
+		Turbidity:  req.Turbidity,
+		TDS:        req.TDS,
+	}
+	if req.Timestamp != nil {
+		reading.Timestamp = *req.Timestamp
+	}
+	return reading
+}
+
 // AddSensorData handles POST requests to manually add sensor data (for testing)
 func (h *Handlers) AddSensorData(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		FilterMode string  `json:"filter_mode"`
-		Flow       float64 `json:"flow"`
-		Ph         float64 `json:"ph"`
-		Turbidity  float64 `json:"turbidity"`
-		TDS        float64 `json:"tds"`
-	}
+	var request sensorReadingRequest
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -320,13 +521,12 @@ func (h *Handlers) AddSensorData(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create sensor reading
-	reading := models.SensorReading{
-		Timestamp:  time.Now(),
-		FilterMode: filterMode,
-		Flow:       request.Flow,
-		Ph:         request.Ph,
-		Turbidity:  request.Turbidity,
-		TDS:        request.TDS,
+	reading := request.toReading()
+
+	// Stamp the server receive time and handle device clocks that are too far off
+	if _, err := h.clockSkew.Apply(&reading, time.Now()); err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Validate the reading
@@ -335,8 +535,24 @@ func (h *Handlers) AddSensorData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Skip readings that repeat the last stored reading (when de-duplication is enabled)
+	if h.deduplicator.IsDuplicate(reading) {
+		response := APIResponse{
+			Success: true,
+			Message: "Duplicate sensor reading skipped",
+			Data: map[string]interface{}{
+				"deduplicated": true,
+				"reading":      reading,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// Store the reading
-	h.store.AddSensorReading(reading)
+	h.storeFor(r).AddSensorReading(reading)
 
 	// Process reading for ML analysis (anomaly detection & prediction updates)
 	if h.mlService != nil {
@@ -354,12 +570,89 @@ func (h *Handlers) AddSensorData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// BackfillReadingQuality re-assesses stored readings whose quality is missing or
+// was computed under other quality rules
+func (h *Handlers) BackfillReadingQuality(w http.ResponseWriter, r *http.Request) {
+	updated, err := h.storeFor(r).RecomputeReadingQuality()
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to backfill reading quality: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Recomputed quality for %d readings", updated),
+		Data: map[string]interface{}{
+			"updated":       updated,
+			"rules_version": models.QualityRulesVersion(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ValidateSensorData runs a reading through ingestion validation without storing
+// it or passing it to ML, reporting every field and the derived water quality.
+// Meant as a dry run for firmware integration testing.
+func (h *Handlers) ValidateSensorData(w http.ResponseWriter, r *http.Request) {
+	var request sensorReadingRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reading := request.toReading()
+	fields := reading.ValidateFields()
+
+	// Report the timestamp against the clock skew policy as ingestion would apply it
+	timestampCheck := models.FieldValidation{Field: "timestamp", Valid: true}
+	if request.Timestamp != nil {
+		timestampCheck.Value = *request.Timestamp
+	}
+	if _, err := h.clockSkew.Apply(&reading, time.Now()); err != nil {
+		timestampCheck.Valid = false
+		timestampCheck.Error = err.Error()
+	}
+	fields = append(fields, timestampCheck)
+
+	valid := true
+	for _, field := range fields {
+		if !field.Valid {
+			valid = false
+		}
+	}
+
+	result := map[string]interface{}{
+		"valid":   valid,
+		"fields":  fields,
+		"reading": reading,
+		"quality": nil,
+	}
+	message := "Sensor reading failed validation"
+	if valid {
+		result["quality"] = reading.ToWaterQualityStatus()
+		message = "Sensor reading is valid"
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // SetFilterMode handles POST requests to set the water filter mode
 func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Mode           models.FilterMode `json:"mode"`
-		Force          bool              `json:"force,omitempty"`           // Optional: force mode change during filtration
-		OverrideReason string            `json:"override_reason,omitempty"` // Optional: reason for manual override
+		Mode            models.FilterMode `json:"mode"`
+		Force           bool              `json:"force,omitempty"`            // Optional: force mode change during filtration
+		OverrideReason  string            `json:"override_reason,omitempty"`  // Optional: reason for manual override
+		StartFiltration bool              `json:"start_filtration,omitempty"` // Optional: start a filtration process in the new mode
+		TargetVolume    *float64          `json:"target_volume,omitempty"`    // Optional: liters to filter (default 5L)
 	}
 
 	// Parse request body
@@ -368,6 +661,16 @@ func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bound the target volume so the process can neither finish instantly nor never
+	targetVolume := models.DefaultTargetVolume
+	if request.TargetVolume != nil {
+		targetVolume = *request.TargetVolume
+		if err := h.targetVolumes.Validate(targetVolume); err != nil {
+			h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Create filter command
 	filterCommand := models.NewFilterCommand(request.Mode)
 
@@ -384,10 +687,10 @@ func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if filter mode change is allowed
-	canChange, reason := h.store.CanChangeFilterMode()
+	canChange, reason := h.storeFor(r).CanChangeFilterMode()
 	if !canChange && !request.Force {
 		// Get current filtration process details for error response
-		process, exists := h.store.GetFiltrationProcess()
+		process, exists := h.storeFor(r).GetFiltrationProcess()
 		if exists {
 			errorData := map[string]interface{}{
 				"error_code":           reason,
@@ -417,27 +720,58 @@ func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Enforce the minimum interval between mode changes to protect the valves
+	if remaining, ok := h.modeCooldown.Reserve(h.storeFor(r).GetActiveDevices(), time.Now()); !ok {
+		retryAfter := int(math.Ceil(remaining.Seconds()))
+		response := APIResponse{
+			Success: false,
+			Message: "Cannot change filter mode",
+			Error:   fmt.Sprintf("Filter mode was changed recently; retry in %d seconds", retryAfter),
+			Data: map[string]interface{}{
+				"retry_after_seconds": retryAfter,
+				"min_interval":        h.modeCooldown.MinInterval().String(),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// If forced, handle the transition (override CanInterrupt check for testing)
 	if !canChange && request.Force {
 		log.Printf("⚠️  Force flag enabled - interrupting filtration process")
 		
 		// Set process to switching state or clear it
-		if process, exists := h.store.GetFiltrationProcess(); exists {
+		if process, exists := h.storeFor(r).GetFiltrationProcess(); exists {
 			// Check if process naturally allows interruption
 			if process.CanInterrupt {
 				log.Printf("   Process can be interrupted naturally (progress: %.1f%%)", process.Progress)
 				process.State = models.FiltrationStateSwitching
-				h.store.SetFiltrationProcess(process)
+				h.storeFor(r).SetFiltrationProcess(process)
 			} else {
 				// Force override - clear the filtration process entirely
 				log.Printf("   Force override: clearing filtration process (progress: %.1f%%)", process.Progress)
-				h.store.ClearFiltrationProcess()
+				h.storeFor(r).ClearFiltrationProcess()
 			}
 		}
 	}
 
 	// Update current filter mode in store
-	h.store.SetCurrentFilterMode(request.Mode)
+	previousMode := h.storeFor(r).GetCurrentFilterMode()
+	h.storeFor(r).SetCurrentFilterMode(request.Mode)
+
+	modeChange := &models.FilterModeChange{
+		FromMode: previousMode,
+		ToMode:   request.Mode,
+		Reason:   models.FilterModeChangeManual,
+		Details:  request.OverrideReason,
+	}
+	if err := h.storeFor(r).RecordFilterModeChange(modeChange); err != nil {
+		log.Printf("⚠️  Failed to record filter mode change: %v", err)
+	}
 
 	// Publish filter command via MQTT
 	if h.mqtt != nil {
@@ -459,13 +793,9 @@ func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 	log.Printf("✅ Filter mode changed to %s (STM32 will poll for this command)", request.Mode)
 
 	// Optional: Start filtration process only if start_filtration flag is true
-	var startFiltration bool = false // Default: don't start filtration automatically
-	
-	if startFiltration {
-		// Determine target volume based on mode (both set to 5L for now)
-		var targetVolume float64 = 5.0 // 5L for both drinking and household water
+	if request.StartFiltration {
 		// Start new filtration process
-		h.store.StartFiltrationProcess(request.Mode, targetVolume)
+		h.storeFor(r).StartFiltrationProcess(request.Mode, targetVolume)
 		log.Printf("🌊 Started filtration process: mode=%s, target=%.1fL", request.Mode, targetVolume)
 	}
 
@@ -491,13 +821,133 @@ func (h *Handlers) SetFilterMode(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// stm32CommandDevice is the device LED commands are logged under when none is given
+const stm32CommandDevice = "stm32_main"
+
+// stm32Command is the compact command payload the STM32 polls for
+type stm32Command struct {
+	DeviceID   string            `json:"device_id,omitempty"`
+	FilterMode models.FilterMode `json:"filter_mode"`
+	LED        string            `json:"led"`
+	Timestamp  int64             `json:"timestamp"` // Unix seconds
+}
+
+// queuedLEDCommandLookback is how many of a device's recent commands a poll
+// checks for queued LED commands
+const queuedLEDCommandLookback = 50
+
+// GetSTM32Command handles GET /api/v1/sensors/stm32/command
+// Returns the current filter mode and LED command as a flat JSON object for the
+// STM32 to poll. The optional device_id is echoed back; every device currently
+// receives the same commands. Queued LED commands for the device are marked
+// delivered by the poll.
+func (h *Handlers) GetSTM32Command(w http.ResponseWriter, r *http.Request) {
+	command := stm32Command{
+		DeviceID:   r.URL.Query().Get("device_id"),
+		FilterMode: h.storeFor(r).GetCurrentFilterMode(),
+		LED:        h.storeFor(r).GetLEDCommand(),
+		Timestamp:  time.Now().Unix(),
+	}
+
+	deviceID := command.DeviceID
+	if deviceID == "" {
+		deviceID = stm32CommandDevice
+	}
+	h.markLEDCommandsDelivered(h.storeFor(r), deviceID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(command)
+}
+
+// markLEDCommandsDelivered updates the device's queued LED commands after a
+// poll: the newest one reached the device, older ones were replaced before it
+// polled and never will
+func (h *Handlers) markLEDCommandsDelivered(dataStore store.DataStore, deviceID string) {
+	commands, err := dataStore.GetDeviceCommands(deviceID, queuedLEDCommandLookback)
+	if err != nil {
+		log.Printf("⚠️  Failed to load LED commands for %s: %v", deviceID, err)
+		return
+	}
+
+	newest := true
+	for _, command := range commands {
+		if command.Command != models.DeviceCommandSetLED {
+			continue
+		}
+		if command.Status == models.DeviceCommandQueued {
+			status, errMsg := models.DeviceCommandDelivered, ""
+			if !newest {
+				status, errMsg = models.DeviceCommandFailed, "superseded before the device polled"
+			}
+			if err := dataStore.UpdateDeviceCommandStatus(command.ID, status, errMsg); err != nil {
+				log.Printf("⚠️  Failed to update LED command %d: %v", command.ID, err)
+			}
+		}
+		newest = false
+	}
+}
+
+// SetLEDCommand handles POST /api/v1/commands/led
+// Sets the LED command (ON or OFF) the STM32 picks up on its next poll and logs
+// it in the device command log
+func (h *Handlers) SetLEDCommand(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Command  string `json:"command"`
+		DeviceID string `json:"device_id"` // Defaults to stm32_main
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	command := strings.ToUpper(strings.TrimSpace(request.Command))
+	if !models.IsValidLEDCommand(command) {
+		h.sendErrorResponse(w, "Invalid command. Use 'ON' or 'OFF'", http.StatusBadRequest)
+		return
+	}
+	deviceID := request.DeviceID
+	if deviceID == "" {
+		deviceID = stm32CommandDevice
+	}
+
+	h.storeFor(r).SetLEDCommand(command)
+	logged := &models.DeviceCommand{
+		DeviceID: deviceID,
+		Command:  models.DeviceCommandSetLED,
+		Payload:  command,
+		Status:   models.DeviceCommandQueued,
+	}
+	if err := h.storeFor(r).RecordDeviceCommand(logged); err != nil {
+		log.Printf("⚠️  Failed to record LED command: %v", err)
+	}
+	log.Printf("💡 LED command set to %s (STM32 will poll for this command)", command)
+
+	response := APIResponse{
+		Success: true,
+		Message: "LED command set",
+		Data: map[string]interface{}{
+			"command":   models.DeviceCommandSetLED,
+			"led":       command,
+			"device_id": deviceID,
+			"set_at":    logged.IssuedAt,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetFilterStatus handles GET requests to get current filter mode and statistics
 func (h *Handlers) GetFilterStatus(w http.ResponseWriter, r *http.Request) {
 	// Get current filter mode from all active devices
-	currentMode := h.store.GetCurrentFilterMode()
+	currentMode := h.storeFor(r).GetCurrentFilterMode()
 	
-	// Get filter mode tracking with statistics
-	tracking := h.store.GetFilterModeTracking()
+	// Get filter mode tracking with statistics (zeroed rather than null when untracked)
+	tracking := h.storeFor(r).GetFilterModeTracking()
+	if tracking == nil {
+		tracking = store.EmptyFilterModeTracking()
+	}
 	
 	// Build response with full tracking info
 	responseData := map[string]interface{}{
@@ -521,51 +971,78 @@ func (h *Handlers) GetFilterStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// ExportHistoryExcel handles GET requests to export purification history as Excel
-func (h *Handlers) ExportHistoryExcel(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for date range filtering
+// parseExportRange parses the start/end/filter_mode query parameters shared by
+// all export handlers. Without a start date the export covers the configured
+// default number of days before the end; ranges ending before they start,
+// ranges longer than the configured maximum export range and unknown filter
+// modes are rejected. An empty mode means all modes.
+func (h *Handlers) parseExportRange(r *http.Request) (time.Time, time.Time, models.FilterMode, error) {
 	startStr := r.URL.Query().Get("start")
 	endStr := r.URL.Query().Get("end")
-	filterMode := r.URL.Query().Get("filter_mode")
+	mode := models.FilterMode(r.URL.Query().Get("filter_mode"))
 
 	var start, end time.Time
 	var err error
 
-	// Set default time range (last 30 days if not specified)
-	if startStr == "" {
-		start = time.Now().AddDate(0, 0, -30)
+	if endStr == "" {
+		end = time.Now()
 	} else {
-		start, err = time.Parse(time.RFC3339, startStr)
+		end, err = time.Parse(time.RFC3339, endStr)
 		if err != nil {
-			h.sendErrorResponse(w, "Invalid start date format. Use RFC3339 format", http.StatusBadRequest)
-			return
+			return start, end, mode, fmt.Errorf("Invalid end date format. Use RFC3339 format")
 		}
 	}
 
-	if endStr == "" {
-		end = time.Now()
+	if startStr == "" {
+		days := h.exportDefaultDays
+		if days <= 0 {
+			days = defaultExportDays
+		}
+		start = end.AddDate(0, 0, -days)
 	} else {
-		end, err = time.Parse(time.RFC3339, endStr)
+		start, err = time.Parse(time.RFC3339, startStr)
 		if err != nil {
-			h.sendErrorResponse(w, "Invalid end date format. Use RFC3339 format", http.StatusBadRequest)
-			return
+			return start, end, mode, fmt.Errorf("Invalid start date format. Use RFC3339 format")
 		}
 	}
 
-	// Get sensor readings from the store
-	readings := h.store.GetReadingsInRange(start, end)
+	if mode != "" && mode != models.FilterModeDrinking && mode != models.FilterModeHousehold {
+		return start, end, mode, fmt.Errorf("Invalid filter_mode. Use 'drinking_water' or 'household_water'")
+	}
 
-	// Filter by mode if specified
-	if filterMode != "" {
-		filteredReadings := []models.SensorReading{}
-		for _, reading := range readings {
-			if string(reading.FilterMode) == filterMode {
-				filteredReadings = append(filteredReadings, reading)
-			}
+	if end.Before(start) {
+		return start, end, mode, fmt.Errorf("Invalid date range. The end date must not be before the start date")
+	}
+
+	// Guard against exports large enough to exhaust server memory
+	if h.exportMaxRange > 0 && end.Sub(start) > h.exportMaxRange {
+		return start, end, mode, fmt.Errorf("Requested export range of %s exceeds the maximum of %s. Request a smaller range, e.g. by splitting the export into consecutive ranges of at most %s",
+			end.Sub(start).Round(time.Second), h.exportMaxRange, h.exportMaxRange)
+	}
+
+	return start, end, mode, nil
+}
+
+// exportReadings returns the readings in the export range, limited to mode
+// unless it is empty
+func (h *Handlers) exportReadings(r *http.Request, start, end time.Time, mode models.FilterMode) []models.SensorReading {
+	readings := h.storeFor(r).GetReadingsInRange(start, end)
+	if mode == "" {
+		return readings
+	}
+
+	filteredReadings := []models.SensorReading{}
+	for _, reading := range readings {
+		if reading.FilterMode == mode {
+			filteredReadings = append(filteredReadings, reading)
 		}
-		readings = filteredReadings
 	}
+	return filteredReadings
+}
 
+// buildExportData assembles the readings with their water quality assessments,
+// filtration history and export metadata for the Excel and PDF exports
+func (h *Handlers) buildExportData(readings []models.SensorReading, start, end time.Time) export.ExportData {
 	// Generate water quality assessments
 	waterQualityStatuses := []models.WaterQualityStatus{}
 	for _, reading := range readings {
@@ -576,8 +1053,7 @@ func (h *Handlers) ExportHistoryExcel(w http.ResponseWriter, r *http.Request) {
 	// Create mock filtration history (in real implementation, this would come from database)
 	filtrationHistory := h.generateFiltrationHistory(readings)
 
-	// Prepare export data
-	exportData := export.ExportData{
+	return export.ExportData{
 		SensorReadings:          readings,
 		WaterQualityAssessments: waterQualityStatuses,
 		FiltrationHistory:       filtrationHistory,
@@ -589,72 +1065,77 @@ func (h *Handlers) ExportHistoryExcel(w http.ResponseWriter, r *http.Request) {
 			DeviceInfo:    "AquaSmart IoT Device",
 		},
 	}
+}
 
-	// Generate Excel file
-	excelFile, err := h.exportService.GenerateExcel(exportData)
+// exportMLRecordLimit caps the filter health records and anomalies loaded for an export
+const exportMLRecordLimit = 1000
+
+// exportMLHistory returns the post-filtration device's filter health records
+// and the anomalies in the export range, limited to mode unless it is empty.
+// Failures are logged and leave the export without the ML data.
+func (h *Handlers) exportMLHistory(r *http.Request, start, end time.Time, mode models.FilterMode) ([]models.FilterHealth, []models.AnomalyDetection) {
+	_, postDeviceID := store.ResolveFilterDevices(h.storeFor(r))
+	healthHistory, err := h.storeFor(r).GetFilterHealthInRange(postDeviceID, start, end, mode, exportMLRecordLimit)
 	if err != nil {
-		h.sendErrorResponse(w, "Failed to generate Excel file", http.StatusInternalServerError)
-		return
+		log.Printf("⚠️  Failed to load filter health history for export: %v", err)
+		healthHistory = nil
 	}
 
-	// Set response headers
-	filename := fmt.Sprintf("aquasmart_history_%s_to_%s.xlsx",
-		start.Format("2006-01-02"), end.Format("2006-01-02"))
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-
-	// Write Excel file to response
-	if err := excelFile.Write(w); err != nil {
-		h.sendErrorResponse(w, "Failed to write Excel file", http.StatusInternalServerError)
-		return
+	anomalies, err := h.storeFor(r).GetAnomaliesInRange(start, end, mode, exportMLRecordLimit)
+	if err != nil {
+		log.Printf("⚠️  Failed to load anomalies for export: %v", err)
+		anomalies = nil
 	}
+
+	return healthHistory, anomalies
 }
 
-// ExportHistoryCSV handles GET requests to export purification history as CSV
-func (h *Handlers) ExportHistoryCSV(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for date range filtering
-	startStr := r.URL.Query().Get("start")
-	endStr := r.URL.Query().Get("end")
-	filterMode := r.URL.Query().Get("filter_mode")
+// ExportHistoryExcel handles GET requests to export purification history as Excel
+func (h *Handlers) ExportHistoryExcel(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for date range and mode filtering
+	start, end, filterMode, err := h.parseExportRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	var start, end time.Time
-	var err error
+	// Get sensor readings from the store
+	readings := h.exportReadings(r, start, end, filterMode)
+	exportData := h.buildExportData(readings, start, end)
+	exportData.FilterHealthHistory, exportData.Anomalies = h.exportMLHistory(r, start, end, filterMode)
 
-	// Set default time range (last 30 days if not specified)
-	if startStr == "" {
-		start = time.Now().AddDate(0, 0, -30)
-	} else {
-		start, err = time.Parse(time.RFC3339, startStr)
-		if err != nil {
-			h.sendErrorResponse(w, "Invalid start date format. Use RFC3339 format", http.StatusBadRequest)
-			return
-		}
+	// Generate Excel file
+	excelFile, err := h.exportService.GenerateExcel(exportData)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to generate Excel file", http.StatusInternalServerError)
+		return
 	}
 
-	if endStr == "" {
-		end = time.Now()
-	} else {
-		end, err = time.Parse(time.RFC3339, endStr)
-		if err != nil {
-			h.sendErrorResponse(w, "Invalid end date format. Use RFC3339 format", http.StatusBadRequest)
-			return
-		}
-	}
+	// Set response headers
+	filename := fmt.Sprintf("aquasmart_history_%s_to_%s.xlsx",
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 
-	// Get sensor readings from the store
-	readings := h.store.GetReadingsInRange(start, end)
+	// Write Excel file to response
+	if err := excelFile.Write(w); err != nil {
+		h.sendErrorResponse(w, "Failed to write Excel file", http.StatusInternalServerError)
+		return
+	}
+}
 
-	// Filter by mode if specified
-	if filterMode != "" {
-		filteredReadings := []models.SensorReading{}
-		for _, reading := range readings {
-			if string(reading.FilterMode) == filterMode {
-				filteredReadings = append(filteredReadings, reading)
-			}
-		}
-		readings = filteredReadings
+// ExportHistoryCSV handles GET requests to export purification history as CSV
+func (h *Handlers) ExportHistoryCSV(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for date range and mode filtering
+	start, end, filterMode, err := h.parseExportRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	// Get sensor readings from the store
+	readings := h.exportReadings(r, start, end, filterMode)
+
 	// Generate CSV data
 	csvData, err := h.exportService.GenerateCSV(readings)
 	if err != nil {
@@ -676,6 +1157,79 @@ func (h *Handlers) ExportHistoryCSV(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ExportHistoryPDF handles GET requests to export purification history as a PDF report
+func (h *Handlers) ExportHistoryPDF(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for date range and mode filtering
+	start, end, filterMode, err := h.parseExportRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get sensor readings from the store
+	readings := h.exportReadings(r, start, end, filterMode)
+
+	// Generate PDF report
+	pdf, err := h.exportService.GeneratePDF(h.buildExportData(readings, start, end))
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to generate PDF report", http.StatusInternalServerError)
+		return
+	}
+
+	// Set response headers
+	filename := fmt.Sprintf("aquasmart_history_%s_to_%s.pdf",
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+
+	w.Write(pdf)
+}
+
+// ExportHistoryJSON handles GET requests to export purification history as JSON
+func (h *Handlers) ExportHistoryJSON(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for date range and mode filtering
+	start, end, filterMode, err := h.parseExportRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Page through the readings oldest first instead of loading the whole range
+	dataStore := h.storeFor(r)
+	filter := models.ReadingFilter{FilterMode: filterMode, Start: &start, End: &end, SortAsc: true, Limit: 1}
+	_, total, err := dataStore.QueryReadings(filter)
+	if err != nil {
+		log.Printf("❌ Error counting readings for JSON export: %v", err)
+		h.sendErrorResponse(w, "Failed to get sensor readings", http.StatusInternalServerError)
+		return
+	}
+	pages := func(after *models.ReadingCursor, limit int) ([]models.SensorReading, error) {
+		page := filter
+		page.Limit = limit
+		return dataStore.QueryReadingsAfter(page, after)
+	}
+
+	metadata := export.ExportMetadata{
+		GeneratedAt:   time.Now(),
+		DateRange:     fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
+		TotalReadings: total,
+		FilterModes:   []string{"drinking_water", "household_water"},
+		DeviceInfo:    "AquaSmart IoT Device",
+	}
+
+	// Set response headers
+	filename := fmt.Sprintf("aquasmart_history_%s_to_%s.json",
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	// Stream the export; once writing has started the status can no longer change
+	if err := h.exportService.WriteJSON(w, metadata, pages); err != nil {
+		log.Printf("❌ Failed to write JSON export: %v", err)
+	}
+}
+
 // generateFiltrationHistory creates mock filtration history from sensor readings
 // In a real implementation, this would query a dedicated filtration_sessions table
 func (h *Handlers) generateFiltrationHistory(readings []models.SensorReading) []export.FiltrationRecord {
@@ -769,68 +1323,27 @@ func (h *Handlers) GetAllSensorData(w http.ResponseWriter, r *http.Request) {
 		sortOrder = "desc" // Default to newest first
 	}
 
-	// Get all readings
-	allReadings := h.store.GetRecentReadings(10000) // Get a large number to simulate "all"
-
-	// Filter by device if specified
-	var filteredReadings []models.SensorReading
-	if deviceID != "" {
-		for _, reading := range allReadings {
-			if reading.DeviceID == deviceID {
-				filteredReadings = append(filteredReadings, reading)
-			}
-		}
-		allReadings = filteredReadings
-		filteredReadings = []models.SensorReading{} // Reset for mode filtering
-	}
-
-	// Filter by mode if specified
+	var mode *models.FilterMode
 	if filterModeStr != "" {
 		filterMode := models.FilterMode(filterModeStr)
 		if filterMode != models.FilterModeDrinking && filterMode != models.FilterModeHousehold {
 			h.sendErrorResponse(w, "Invalid filter_mode. Use 'drinking_water' or 'household_water'", http.StatusBadRequest)
 			return
 		}
-
-		for _, reading := range allReadings {
-			if reading.FilterMode == filterMode {
-				filteredReadings = append(filteredReadings, reading)
-			}
-		}
-	} else {
-		filteredReadings = allReadings
-	}
-
-	// Sort readings
-	if sortOrder == "asc" {
-		// Sort oldest first
-		for i := 0; i < len(filteredReadings)-1; i++ {
-			for j := i + 1; j < len(filteredReadings); j++ {
-				if filteredReadings[i].Timestamp.After(filteredReadings[j].Timestamp) {
-					filteredReadings[i], filteredReadings[j] = filteredReadings[j], filteredReadings[i]
-				}
-			}
-		}
+		mode = &filterMode
 	}
-	// Default is already desc (newest first)
 
-	// Apply pagination
-	totalRecords := len(filteredReadings)
-	start := offset
-	end := offset + limit
-
-	if start >= totalRecords {
-		filteredReadings = []models.SensorReading{}
-	} else {
-		if end > totalRecords {
-			end = totalRecords
-		}
-		filteredReadings = filteredReadings[start:end]
+	// Filtering and pagination happen in the store, so only one page is loaded
+	pageReadings, totalRecords, err := h.storeFor(r).GetReadingsPaginated(limit, offset, deviceID, mode, sortOrder == "asc")
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get sensor data", http.StatusInternalServerError)
+		return
 	}
+	end := offset + len(pageReadings)
 
 	// Prepare response with metadata
 	responseData := map[string]interface{}{
-		"data": filteredReadings,
+		"data": nonNilReadings(pageReadings),
 		"pagination": map[string]interface{}{
 			"total_records":    totalRecords,
 			"current_page":     (offset / limit) + 1,
@@ -847,267 +1360,752 @@ func (h *Handlers) GetAllSensorData(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := APIResponse{
-		Success: true,
-		Data:    responseData,
+		Success:   true,
+		Data:      responseData,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetDeviceReadings returns all readings for a specific device (path parameter)
-func (h *Handlers) GetDeviceReadings(w http.ResponseWriter, r *http.Request) {
-	deviceID := chi.URLParam(r, "deviceID")
-	
-	if deviceID == "" {
-		h.sendErrorResponse(w, "device_id parameter is required", http.StatusBadRequest)
+// maxSearchOffset bounds how deep /sensors/search may paginate
+const maxSearchOffset = 100000
+
+// SearchSensorReadings searches readings by device, filter mode, time range and
+// per-metric value ranges (e.g. ph_min, tds_max) with sorting and pagination
+//
+// Passing a cursor parameter switches to keyset pagination: an empty cursor
+// returns the first page and each page carries the next_cursor to pass back.
+// Keyset pages stay fast at any depth, where offsets slow down.
+func (h *Handlers) SearchSensorReadings(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("cursor") {
+		h.sendReadingsPageAfter(w, r)
 		return
 	}
 
-	// Get readings for this device
-	readings := h.store.GetReadingsByDevice(deviceID)
+	filter, err := parseReadingFilter(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if len(readings) == 0 {
-		h.sendErrorResponse(w, "No readings found for device: "+deviceID, http.StatusNotFound)
+	readings, total, err := h.storeFor(r).QueryReadings(filter)
+	if err != nil {
+		log.Printf("❌ Error searching sensor readings: %v", err)
+		h.sendErrorResponse(w, "Failed to search sensor readings", http.StatusInternalServerError)
 		return
 	}
 
+	sortOrder := "desc"
+	if filter.SortAsc {
+		sortOrder = "asc"
+	}
+
+	responseData := map[string]interface{}{
+		"data": nonNilReadings(readings),
+		"pagination": map[string]interface{}{
+			"total_records": total,
+			"current_page":  (filter.Offset / filter.Limit) + 1,
+			"per_page":      filter.Limit,
+			"total_pages":   (total + filter.Limit - 1) / filter.Limit,
+			"has_next":      filter.Offset+len(readings) < total,
+			"has_previous":  filter.Offset > 0,
+		},
+		"filters": map[string]interface{}{
+			"device_id":   filter.DeviceID,
+			"filter_mode": filter.FilterMode,
+			"start":       filter.Start,
+			"end":         filter.End,
+			"ph":          filter.Ph,
+			"tds":         filter.TDS,
+			"turbidity":   filter.Turbidity,
+			"flow":        filter.Flow,
+			"sort_order":  sortOrder,
+		},
+	}
+
+	count := len(readings)
 	response := APIResponse{
 		Success: true,
-		Data:    readings,
+		Data:    responseData,
+		Count:   &count,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetAllDevicesLatest returns the latest reading for each device
-func (h *Handlers) GetAllDevicesLatest(w http.ResponseWriter, r *http.Request) {
-	latestReadings := h.store.GetAllLatestReadingsByDevice()
+// sendReadingsPageAfter responds with the keyset page of readings following the
+// request's cursor, filtered like a search, plus the cursor of the next page
+func (h *Handlers) sendReadingsPageAfter(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReadingFilter(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Offset != 0 {
+		h.sendErrorResponse(w, "Use either cursor or offset, not both", http.StatusBadRequest)
+		return
+	}
 
-	if len(latestReadings) == 0 {
-		h.sendErrorResponse(w, "No devices with readings found", http.StatusNotFound)
+	var after *models.ReadingCursor
+	if encoded := r.URL.Query().Get("cursor"); encoded != "" {
+		cursor, err := models.ParseReadingCursor(encoded)
+		if err != nil {
+			h.sendErrorResponse(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = &cursor
+	}
+
+	// Fetch one extra reading to learn whether another page follows
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+	readings, err := h.storeFor(r).QueryReadingsAfter(filter, after)
+	if err != nil {
+		log.Printf("❌ Error paging sensor readings: %v", err)
+		h.sendErrorResponse(w, "Failed to get sensor readings", http.StatusInternalServerError)
 		return
 	}
 
+	hasNext := len(readings) > pageSize
+	var nextCursor *string
+	if hasNext {
+		readings = readings[:pageSize]
+		encoded := models.CursorAfter(readings[len(readings)-1]).Encode()
+		nextCursor = &encoded
+	}
+
+	count := len(readings)
 	response := APIResponse{
 		Success: true,
-		Data:    latestReadings,
+		Data: map[string]interface{}{
+			"data": nonNilReadings(readings),
+			"pagination": map[string]interface{}{
+				"per_page":    pageSize,
+				"has_next":    hasNext,
+				"next_cursor": nextCursor,
+			},
+		},
+		Count: &count,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetAllSensorDataSimple returns all sensor data in simple format (just array)
-func (h *Handlers) GetAllSensorDataSimple(w http.ResponseWriter, r *http.Request) {
-	filterModeStr := r.URL.Query().Get("filter_mode")
-	sortOrder := r.URL.Query().Get("sort") // "asc" or "desc"
-
-	// Get all readings
-	allReadings := h.store.GetRecentReadings(10000) // Get a large number
+// parseReadingFilter builds a validated reading filter from search query parameters
+func parseReadingFilter(r *http.Request) (models.ReadingFilter, error) {
+	query := r.URL.Query()
+	filter := models.ReadingFilter{
+		DeviceID: query.Get("device_id"),
+		Limit:    models.DefaultReadingFilterLimit,
+	}
 
-	// Filter by mode if specified
-	var filteredReadings []models.SensorReading
-	if filterModeStr != "" {
-		filterMode := models.FilterMode(filterModeStr)
-		if filterMode != models.FilterModeDrinking && filterMode != models.FilterModeHousehold {
-			h.sendErrorResponse(w, "Invalid filter_mode. Use 'drinking_water' or 'household_water'", http.StatusBadRequest)
-			return
+	if mode := query.Get("filter_mode"); mode != "" {
+		filter.FilterMode = models.FilterMode(mode)
+		if filter.FilterMode != models.FilterModeDrinking && filter.FilterMode != models.FilterModeHousehold {
+			return filter, fmt.Errorf("Invalid filter_mode. Use 'drinking_water' or 'household_water'")
 		}
+	}
 
-		for _, reading := range allReadings {
-			if reading.FilterMode == filterMode {
-				filteredReadings = append(filteredReadings, reading)
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"start", &filter.Start}, {"end", &filter.End}} {
+		if value := query.Get(bound.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("Invalid %s date format. Use RFC3339 format", bound.name)
 			}
+			*bound.target = &parsed
 		}
-	} else {
-		filteredReadings = allReadings
 	}
-
-	// Sort readings if specified
-	if sortOrder == "asc" {
-		// Sort oldest first
-		for i := 0; i < len(filteredReadings)-1; i++ {
-			for j := i + 1; j < len(filteredReadings); j++ {
-				if filteredReadings[i].Timestamp.After(filteredReadings[j].Timestamp) {
-					filteredReadings[i], filteredReadings[j] = filteredReadings[j], filteredReadings[i]
-				}
+	if filter.Start != nil && filter.End != nil && filter.End.Before(*filter.Start) {
+		return filter, fmt.Errorf("end must not be before start")
+	}
+
+	for _, metric := range []struct {
+		name   string
+		target *models.ValueRange
+	}{{"ph", &filter.Ph}, {"tds", &filter.TDS}, {"turbidity", &filter.Turbidity}, {"flow", &filter.Flow}} {
+		for _, end := range []struct {
+			suffix string
+			target **float64
+		}{{"_min", &metric.target.Min}, {"_max", &metric.target.Max}} {
+			key := metric.name + end.suffix
+			value := query.Get(key)
+			if value == "" {
+				continue
 			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				return filter, fmt.Errorf("Invalid %s. Must be a finite number", key)
+			}
+			*end.target = &parsed
+		}
+		if metric.target.Min != nil && metric.target.Max != nil && *metric.target.Min > *metric.target.Max {
+			return filter, fmt.Errorf("%s_min must not be greater than %s_max", metric.name, metric.name)
+		}
+	}
+
+	switch query.Get("sort") {
+	case "", "desc":
+	case "asc":
+		filter.SortAsc = true
+	default:
+		return filter, fmt.Errorf("Invalid sort. Use 'asc' or 'desc'")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > models.MaxReadingFilterLimit {
+			return filter, fmt.Errorf("Invalid limit. Must be between 1 and %d", models.MaxReadingFilterLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 || offset > maxSearchOffset {
+			return filter, fmt.Errorf("Invalid offset. Must be between 0 and %d", maxSearchOffset)
 		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// GetMetricHeatmap returns a metric's average per day-of-week and hour-of-day
+// (a 7x24 grid) for calendar heatmap visualizations
+func (h *Handlers) GetMetricHeatmap(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if !models.IsValidSensorMetric(metric) {
+		h.sendErrorResponse(w, "Invalid metric. Use one of: ph, tds, turbidity, flow", http.StatusBadRequest)
+		return
+	}
+
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := h.storeFor(r).GetMetricHeatmap(metric, start, end)
+	if err != nil {
+		log.Printf("❌ Error building %s heatmap: %v", metric, err)
+		h.sendErrorResponse(w, "Failed to build heatmap", http.StatusInternalServerError)
+		return
 	}
 
 	response := APIResponse{
 		Success: true,
-		Data:    filteredReadings,
+		Data:    models.NewMetricHeatmap(metric, start, end, buckets),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetSensorDataStats returns statistics about all sensor data
-func (h *Handlers) GetSensorDataStats(w http.ResponseWriter, r *http.Request) {
-	filterModeStr := r.URL.Query().Get("filter_mode")
-
-	// Get all readings
-	allReadings := h.store.GetRecentReadings(10000)
-
-	// Filter by mode if specified
-	var filteredReadings []models.SensorReading
-	if filterModeStr != "" {
-		filterMode := models.FilterMode(filterModeStr)
-		if filterMode != models.FilterModeDrinking && filterMode != models.FilterModeHousehold {
-			h.sendErrorResponse(w, "Invalid filter_mode. Use 'drinking_water' or 'household_water'", http.StatusBadRequest)
-			return
+// parseAnalyticsRange reads the optional start/end (RFC3339) query parameters of
+// the metric analytics endpoints, defaulting to the last 30 days
+func parseAnalyticsRange(r *http.Request) (time.Time, time.Time, error) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -30)
+	var err error
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		if start, err = time.Parse(time.RFC3339, startStr); err != nil {
+			return start, end, fmt.Errorf("Invalid start date format. Use RFC3339 format")
 		}
-
-		for _, reading := range allReadings {
-			if reading.FilterMode == filterMode {
-				filteredReadings = append(filteredReadings, reading)
-			}
+	}
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		if end, err = time.Parse(time.RFC3339, endStr); err != nil {
+			return start, end, fmt.Errorf("Invalid end date format. Use RFC3339 format")
 		}
-	} else {
-		filteredReadings = allReadings
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("end must not be before start")
+	}
+	return start, end, nil
+}
+
+// availableDaysMaxRange is the longest range the available days may be listed for
+const availableDaysMaxRange = 366 * 24 * time.Hour
+
+// GetAvailableDays handles GET /api/v1/sensors/available-days
+// Query params: start/end (RFC3339, default last 30 days, at most a year
+// apart) and tz (IANA name, default UTC). Returns the dates in tz that have at
+// least one reading, oldest first, so date pickers can disable the rest.
+func (h *Handlers) GetAvailableDays(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if end.Sub(start) > availableDaysMaxRange {
+		h.sendErrorResponse(w, "Range too long. start and end may be at most 366 days apart", http.StatusBadRequest)
+		return
 	}
 
-	if len(filteredReadings) == 0 {
-		h.sendErrorResponse(w, "No sensor data found", http.StatusNotFound)
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		tz = "UTC"
+	}
+	// "Local" would depend on the server's zone
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		h.sendErrorResponse(w, "Invalid tz. Use an IANA time zone name such as Asia/Jakarta", http.StatusBadRequest)
 		return
 	}
 
-	// Calculate statistics
-	stats := calculateSensorStats(filteredReadings)
+	days, err := h.storeFor(r).GetAvailableDays(start, end, loc)
+	if err != nil {
+		log.Printf("❌ Error getting available days: %v", err)
+		h.sendErrorResponse(w, "Failed to get available days", http.StatusInternalServerError)
+		return
+	}
 
 	response := APIResponse{
 		Success: true,
-		Data:    stats,
+		Data: map[string]interface{}{
+			"days":     days,
+			"count":    len(days),
+			"timezone": loc.String(),
+			"start":    start,
+			"end":      end,
+		},
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// SensorDataStats represents statistics about sensor data
-type SensorDataStats struct {
-	TotalReadings int                    `json:"total_readings"`
-	DateRange     map[string]string      `json:"date_range"`
-	PhStats       map[string]float64     `json:"ph_stats"`
-	TDSStats      map[string]float64     `json:"tds_stats"`
-	TurbidityStats map[string]float64    `json:"turbidity_stats"`
-	FlowStats     map[string]float64     `json:"flow_stats"`
-	FilterModes   map[string]int         `json:"filter_modes"`
-	QualityBreakdown map[string]int      `json:"quality_breakdown"`
-}
+// GetQualityBreakdown handles GET /api/v1/sensors/quality/breakdown
+// Query params: group_by (mode or day, default mode), start/end (RFC3339, default
+// last 30 days). Returns how many readings of each group were assessed as each
+// overall quality label.
+func (h *Handlers) GetQualityBreakdown(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = models.QualityGroupByMode
+	}
+	if groupBy != models.QualityGroupByMode && groupBy != models.QualityGroupByDay {
+		h.sendErrorResponse(w, "Invalid group_by. Use one of: mode, day", http.StatusBadRequest)
+		return
+	}
 
-// calculateSensorStats calculates comprehensive statistics
-func calculateSensorStats(readings []models.SensorReading) SensorDataStats {
-	if len(readings) == 0 {
-		return SensorDataStats{}
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Initialize stats
-	stats := SensorDataStats{
-		TotalReadings: len(readings),
-		DateRange:     make(map[string]string),
-		PhStats:       make(map[string]float64),
-		TDSStats:      make(map[string]float64),
-		TurbidityStats: make(map[string]float64),
-		FlowStats:     make(map[string]float64),
-		FilterModes:   make(map[string]int),
-		QualityBreakdown: make(map[string]int),
-	}
-
-	// Initialize values with first reading
-	minPh, maxPh := readings[0].Ph, readings[0].Ph
-	minTDS, maxTDS := readings[0].TDS, readings[0].TDS
-	minTurbidity, maxTurbidity := readings[0].Turbidity, readings[0].Turbidity
-	minFlow, maxFlow := readings[0].Flow, readings[0].Flow
-	sumPh, sumTDS, sumTurbidity, sumFlow := 0.0, 0.0, 0.0, 0.0
-
-	earliestTime := readings[0].Timestamp
-	latestTime := readings[0].Timestamp
-
-	// Calculate stats
-	for _, reading := range readings {
-		// Date range
-		if reading.Timestamp.Before(earliestTime) {
-			earliestTime = reading.Timestamp
-		}
-		if reading.Timestamp.After(latestTime) {
-			latestTime = reading.Timestamp
-		}
+	groups := models.NewQualityBreakdown(h.storeFor(r).GetReadingsInRange(start, end), groupBy)
+	h.sendCollectionResponse(w, groups, len(groups))
+}
 
-		// pH stats
-		if reading.Ph < minPh {
-			minPh = reading.Ph
+// GetMetricAggregates handles GET /api/v1/sensors/aggregate
+// Query params: interval (hour or day, default hour), metric (ph, tds, turbidity, flow),
+// start/end (RFC3339, default last 30 days). Returns min/max/avg per UTC bucket, oldest first.
+func (h *Handlers) GetMetricAggregates(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if !models.IsValidSensorMetric(metric) {
+		h.sendErrorResponse(w, "Invalid metric. Use one of: ph, tds, turbidity, flow", http.StatusBadRequest)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = models.AggregateIntervalHour
+	}
+	if !models.IsValidAggregateInterval(interval) {
+		h.sendErrorResponse(w, "Invalid interval. Use one of: hour, day", http.StatusBadRequest)
+		return
+	}
+
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := h.storeFor(r).GetMetricAggregates(metric, interval, start, end)
+	if err != nil {
+		log.Printf("❌ Error aggregating %s per %s: %v", metric, interval, err)
+		h.sendErrorResponse(w, "Failed to aggregate readings", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendCollectionResponse(w, buckets, len(buckets))
+}
+
+// Ingestion rate window bounds and classification for /sensors/ingest-rate
+const (
+	defaultIngestRateWindow = 10 * time.Minute
+	minIngestRateWindow     = time.Minute
+	maxIngestRateWindow     = 24 * time.Hour
+	ingestRateTolerance     = 0.5 // Fraction a rate may stray from the expected one before it is flagged
+	ingestRateHistory       = 50  // Readings before the window used to infer a device's interval
+)
+
+// GetIngestRate handles GET /api/v1/sensors/ingest-rate
+// Query params: window (duration between 1m and 24h, default 10m). Returns each
+// device's readings per minute over the window, compared to the configured
+// reading interval or, without one, the device's median interval before the window.
+func (h *Handlers) GetIngestRate(w http.ResponseWriter, r *http.Request) {
+	window := defaultIngestRateWindow
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		parsed, err := time.ParseDuration(windowStr)
+		if err != nil || parsed < minIngestRateWindow || parsed > maxIngestRateWindow {
+			h.sendErrorResponse(w, "Invalid window. Use a duration between 1m and 24h, e.g. 10m", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	dataStore := h.storeFor(r)
+	end := time.Now()
+	start := end.Add(-window)
+	counts, err := dataStore.GetReadingCountsByDevice(start, end)
+	if err != nil {
+		log.Printf("❌ Error counting readings per device: %v", err)
+		h.sendErrorResponse(w, "Failed to count readings", http.StatusInternalServerError)
+		return
+	}
+
+	// Active devices that sent nothing in the window are reported too
+	devices, err := dataStore.GetDeviceStatuses()
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	deviceIDs := make([]string, 0, len(counts))
+	for deviceID := range counts {
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	for _, device := range devices {
+		if _, counted := counts[device.DeviceID]; !counted && device.IsActive {
+			deviceIDs = append(deviceIDs, device.DeviceID)
+		}
+	}
+	sort.Strings(deviceIDs)
+
+	rates := make([]models.IngestRate, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		interval, source := h.expectedInterval, models.ExpectedRateConfig
+		if interval <= 0 {
+			history, err := dataStore.GetReadingsAround(deviceID, start, ingestRateHistory, 0)
+			if err != nil {
+				log.Printf("⚠️  Failed to load reading history of %s: %v", deviceID, err)
+			}
+			interval, _ = models.MedianReadingInterval(history)
+			source = models.ExpectedRateInferred
+		}
+		rates = append(rates, models.NewIngestRate(deviceID, counts[deviceID], window, interval, source, ingestRateTolerance))
+	}
+
+	response := APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"window":    window.String(),
+			"start":     start,
+			"end":       end,
+			"tolerance": ingestRateTolerance,
+			"devices":   rates,
+			"count":     len(rates),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Histogram bin count bounds for /sensors/histogram
+const (
+	defaultHistogramBins = 20
+	maxHistogramBins     = 100
+)
+
+// GetMetricHistogram handles GET /api/v1/sensors/histogram
+// Query params: metric (ph, tds, turbidity, flow), bins (1-100, default 20),
+// start/end (RFC3339, default last 30 days)
+func (h *Handlers) GetMetricHistogram(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if !models.IsValidSensorMetric(metric) {
+		h.sendErrorResponse(w, "Invalid metric. Use one of: ph, tds, turbidity, flow", http.StatusBadRequest)
+		return
+	}
+
+	bins := defaultHistogramBins
+	if binsStr := r.URL.Query().Get("bins"); binsStr != "" {
+		parsed, err := strconv.Atoi(binsStr)
+		if err != nil || parsed < 1 || parsed > maxHistogramBins {
+			h.sendErrorResponse(w, fmt.Sprintf("Invalid bins. Must be between 1 and %d", maxHistogramBins), http.StatusBadRequest)
+			return
+		}
+		bins = parsed
+	}
+
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	histogram, err := h.storeFor(r).GetMetricHistogram(metric, start, end, bins)
+	if err != nil {
+		log.Printf("❌ Error building %s histogram: %v", metric, err)
+		h.sendErrorResponse(w, "Failed to build histogram", http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Data:    histogram,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetWaterQualityIndex returns the composite water quality index of each device's
+// latest reading, or of a single device with ?device_id=
+func (h *Handlers) GetWaterQualityIndex(w http.ResponseWriter, r *http.Request) {
+	toDeviceWQI := func(reading *models.SensorReading) models.DeviceWQI {
+		return models.DeviceWQI{
+			DeviceID:   reading.DeviceID,
+			Timestamp:  reading.Timestamp,
+			FilterMode: reading.FilterMode,
+			WQI:        reading.WaterQualityIndex(),
 		}
-		if reading.Ph > maxPh {
-			maxPh = reading.Ph
+	}
+
+	if deviceID := r.URL.Query().Get("device_id"); deviceID != "" {
+		reading, exists := h.storeFor(r).GetLatestReadingByDevice(deviceID)
+		if !exists || reading == nil {
+			h.sendErrorResponse(w, "No readings available for device "+deviceID, http.StatusNotFound)
+			return
 		}
-		sumPh += reading.Ph
 
-		// TDS stats
-		if reading.TDS < minTDS {
-			minTDS = reading.TDS
+		response := APIResponse{
+			Success: true,
+			Data:    toDeviceWQI(reading),
 		}
-		if reading.TDS > maxTDS {
-			maxTDS = reading.TDS
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	latestReadings := h.storeFor(r).GetAllLatestReadingsByDevice()
+	indexes := make([]models.DeviceWQI, 0, len(latestReadings))
+	for _, reading := range latestReadings {
+		indexes = append(indexes, toDeviceWQI(&reading))
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].DeviceID < indexes[j].DeviceID
+	})
+
+	h.sendCollectionResponse(w, indexes, len(indexes))
+}
+
+// GetWaterQualityScore returns only the WQI score and label of the latest
+// drinking water reading, without the usual response envelope, for glanceable
+// displays. A reading older than the safety max age is flagged stale and, under
+// the fail-safe policy, scored as unknown like the safe-to-drink verdict.
+// Responses are cacheable by the client and revalidate with an ETag that
+// changes with the reading and its staleness.
+func (h *Handlers) GetWaterQualityScore(w http.ResponseWriter, r *http.Request) {
+	score := struct {
+		WQI    *float64 `json:"wqi"`
+		Status string   `json:"status"`
+		Stale  bool     `json:"stale,omitempty"`
+	}{Status: "Unknown"}
+
+	reading, exists := h.storeFor(r).GetLatestReadingByMode(models.FilterModeDrinking)
+	if exists {
+		score.Stale = time.Since(reading.Timestamp) > h.safetyMaxAge
+		if !score.Stale || h.stalenessPolicy == models.StalenessFailOpen {
+			index := reading.WaterQualityIndex()
+			score.WQI = &index.Score
+			score.Status = index.Label
 		}
-		sumTDS += reading.TDS
+	}
 
-		// Turbidity stats
-		if reading.Turbidity < minTurbidity {
-			minTurbidity = reading.Turbidity
+	etag := `"none"`
+	if exists {
+		etag = fmt.Sprintf("%x", reading.Timestamp.UnixNano())
+		if score.WQI != nil {
+			etag += fmt.Sprintf("-%g", *score.WQI)
 		}
-		if reading.Turbidity > maxTurbidity {
-			maxTurbidity = reading.Turbidity
+		if score.Stale {
+			etag += "-stale"
 		}
-		sumTurbidity += reading.Turbidity
+		etag = `"` + etag + `"`
+		w.Header().Set("Last-Modified", reading.Timestamp.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d",
+		int(scoreCacheMaxAge.Seconds()), int(2*scoreCacheMaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(score)
+}
+
+// GetFilterModeCounts lists every filter mode present in the stored readings,
+// including modes the backend does not know about, with counts and latest timestamp
+func (h *Handlers) GetFilterModeCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.storeFor(r).GetFilterModeCounts()
+	if err != nil {
+		log.Printf("❌ Error getting filter mode counts: %v", err)
+		h.sendErrorResponse(w, "Failed to get filter modes", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendCollectionResponse(w, counts, len(counts))
+}
+
+// GetDeviceReadings returns all readings for a specific device (path parameter)
+func (h *Handlers) GetDeviceReadings(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+	
+	if deviceID == "" {
+		h.sendErrorResponse(w, "device_id parameter is required", http.StatusBadRequest)
+		return
+	}
 
-		// Flow stats
-		if reading.Flow < minFlow {
-			minFlow = reading.Flow
+	// Get readings for this device (empty list if the device has no readings)
+	readings := nonNilReadings(h.storeFor(r).GetReadingsByDevice(deviceID))
+
+	h.sendCollectionResponse(w, readings, len(readings))
+}
+
+// GetAllDevicesLatest returns the latest reading for each device
+func (h *Handlers) GetAllDevicesLatest(w http.ResponseWriter, r *http.Request) {
+	latestReadings := h.storeFor(r).GetAllLatestReadingsByDevice()
+	if latestReadings == nil {
+		latestReadings = map[string]models.SensorReading{}
+	}
+
+	// Keyed by device ID; empty object when no device has reported yet
+	h.sendCollectionResponse(w, latestReadings, len(latestReadings))
+}
+
+// GetAllSensorDataSimple returns all sensor data in simple format (just array)
+func (h *Handlers) GetAllSensorDataSimple(w http.ResponseWriter, r *http.Request) {
+	filterModeStr := r.URL.Query().Get("filter_mode")
+	sortOrder := r.URL.Query().Get("sort") // "asc" or "desc"
+
+	// Get all readings (up to the configured ceiling)
+	allReadings, truncated := h.allReadings(r)
+
+	// Filter by mode if specified
+	var filteredReadings []models.SensorReading
+	if filterModeStr != "" {
+		filterMode := models.FilterMode(filterModeStr)
+		if filterMode != models.FilterModeDrinking && filterMode != models.FilterModeHousehold {
+			h.sendErrorResponse(w, "Invalid filter_mode. Use 'drinking_water' or 'household_water'", http.StatusBadRequest)
+			return
 		}
-		if reading.Flow > maxFlow {
-			maxFlow = reading.Flow
+
+		for _, reading := range allReadings {
+			if reading.FilterMode == filterMode {
+				filteredReadings = append(filteredReadings, reading)
+			}
 		}
-		sumFlow += reading.Flow
+	} else {
+		filteredReadings = allReadings
+	}
 
-		// Filter modes count
-		stats.FilterModes[string(reading.FilterMode)]++
+	// Sort readings, newest first unless asc is requested
+	sortReadingsByTimestamp(filteredReadings, sortOrder == "asc")
 
-		// Quality breakdown
-		quality := reading.ToWaterQualityStatus().OverallQuality
-		stats.QualityBreakdown[quality]++
+	filteredReadings = nonNilReadings(filteredReadings)
+	count := len(filteredReadings)
+	response := APIResponse{
+		Success:   true,
+		Data:      filteredReadings,
+		Count:     &count,
+		Truncated: truncated,
 	}
 
-	count := float64(len(readings))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSensorDataStats returns statistics about all sensor data. The store
+// aggregates them, so they cover every stored reading however many there are.
+// Without readings the statistics are empty rather than missing.
+func (h *Handlers) GetSensorDataStats(w http.ResponseWriter, r *http.Request) {
+	filterMode := models.FilterMode(r.URL.Query().Get("filter_mode"))
+	if filterMode != "" && filterMode != models.FilterModeDrinking && filterMode != models.FilterModeHousehold {
+		h.sendErrorResponse(w, "Invalid filter_mode. Use 'drinking_water' or 'household_water'", http.StatusBadRequest)
+		return
+	}
 
-	// Fill stats
-	stats.DateRange["earliest"] = earliestTime.Format("2006-01-02 15:04:05")
-	stats.DateRange["latest"] = latestTime.Format("2006-01-02 15:04:05")
+	stats, err := h.storeFor(r).GetSensorDataStats(filterMode)
+	if err != nil {
+		log.Printf("Error calculating sensor data stats: %v", err)
+		h.sendErrorResponse(w, "Failed to calculate sensor data statistics", http.StatusInternalServerError)
+		return
+	}
+	response := APIResponse{
+		Success: true,
+		Data:    stats,
+	}
+	if stats == nil {
+		empty := models.EmptySensorDataStats()
+		response.Data = &empty
+		response.Message = "No sensor data found"
+	}
 
-	stats.PhStats["min"] = minPh
-	stats.PhStats["max"] = maxPh
-	stats.PhStats["average"] = sumPh / count
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-	stats.TDSStats["min"] = minTDS
-	stats.TDSStats["max"] = maxTDS
-	stats.TDSStats["average"] = sumTDS / count
+// GetSafeToDrink answers whether the water is safe to drink right now, based on
+// the latest drinking-water reading. Missing data yields status "unknown" with
+// safe=false rather than an error; stale data does too under the fail-safe
+// policy, and is judged with a caution under fail-open.
+func (h *Handlers) GetSafeToDrink(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	reading, exists := h.storeFor(r).GetLatestReadingByMode(models.FilterModeDrinking)
+	if !exists {
+		reading = nil
+	}
+	safety := models.AssessDrinkingSafety(reading, now, h.safetyMaxAge, h.stalenessPolicy)
 
-	stats.TurbidityStats["min"] = minTurbidity
-	stats.TurbidityStats["max"] = maxTurbidity
-	stats.TurbidityStats["average"] = sumTurbidity / count
+	result := map[string]interface{}{
+		"safe":    safety.Safe,
+		"status":  safety.Status,
+		"reasons": safety.Reasons,
+		"stale":   safety.Stale,
+		"policy":  h.stalenessPolicy,
+		"max_age": h.safetyMaxAge.String(),
+	}
+	if safety.Caution != "" {
+		result["caution"] = safety.Caution
+	}
+	if reading != nil {
+		result["reading"] = reading
+		result["reading_age_seconds"] = int(now.Sub(reading.Timestamp).Seconds())
+	}
 
-	stats.FlowStats["min"] = minFlow
-	stats.FlowStats["max"] = maxFlow
-	stats.FlowStats["average"] = sumFlow / count
+	response := APIResponse{
+		Success: true,
+		Data:    result,
+	}
 
-	return stats
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-// GetBestDailyValues returns the best pH, TDS, and Turbidity values for today
+// GetBestDailyValues returns the best pH, TDS, and Turbidity values for today.
+// Before the first reading of the day the values are null and total_readings is 0.
 func (h *Handlers) GetBestDailyValues(w http.ResponseWriter, r *http.Request) {
 	// Get today's date
 	now := time.Now()
@@ -1115,46 +2113,36 @@ func (h *Handlers) GetBestDailyValues(w http.ResponseWriter, r *http.Request) {
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
 	// Get all readings for today
-	readings := h.store.GetReadingsInRange(startOfDay, endOfDay)
+	readings := h.storeFor(r).GetReadingsInRange(startOfDay, endOfDay)
 
-	var bestValues BestDailyValues
-
-	if len(readings) == 0 {
-		// Return default values instead of 404 error when no data exists
-		bestValues = BestDailyValues{
-			Date:          now.Format("2006-01-02"),
-			BestPH:        7.0,
-			BestTDS:       0,
-			BestTurbidity: 0,
-			TotalReadings: 0,
-		}
-	} else {
-		// Calculate best values from actual readings
-		bestValues = calculateBestValues(readings)
-	}
+	// Calculate best values from actual readings
+	bestValues := calculateBestValues(readings)
 
 	response := APIResponse{
 		Success: true,
 		Data:    bestValues,
 	}
+	if len(readings) == 0 {
+		response.Message = "No sensor data available for today"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// BestDailyValues represents the best values for a day
+// BestDailyValues represents the best values for a day; nil without readings
 type BestDailyValues struct {
-	Date          string  `json:"date"`
-	BestPH        float64 `json:"best_ph"`
-	BestTDS       float64 `json:"best_tds"`
-	BestTurbidity float64 `json:"best_turbidity"`
-	TotalReadings int     `json:"total_readings"`
+	Date          string   `json:"date"`
+	BestPH        *float64 `json:"best_ph"`
+	BestTDS       *float64 `json:"best_tds"`
+	BestTurbidity *float64 `json:"best_turbidity"`
+	TotalReadings int      `json:"total_readings"`
 }
 
 // calculateBestValues calculates the best pH, TDS, and Turbidity values from readings
 func calculateBestValues(readings []models.SensorReading) BestDailyValues {
 	if len(readings) == 0 {
-		return BestDailyValues{}
+		return BestDailyValues{Date: time.Now().Format("2006-01-02")}
 	}
 
 	// For pH: ideal range is 6.5-8.5, so best is closest to 7.0
@@ -1184,9 +2172,9 @@ func calculateBestValues(readings []models.SensorReading) BestDailyValues {
 
 	return BestDailyValues{
 		Date:          time.Now().Format("2006-01-02"),
-		BestPH:        bestPH,
-		BestTDS:       bestTDS,
-		BestTurbidity: bestTurbidity,
+		BestPH:        &bestPH,
+		BestTDS:       &bestTDS,
+		BestTurbidity: &bestTurbidity,
 		TotalReadings: len(readings),
 	}
 }
@@ -1199,7 +2187,8 @@ func abs(x float64) float64 {
 	return x
 }
 
-// GetWorstDailyValues returns the worst pH, TDS, and Turbidity values for today
+// GetWorstDailyValues returns the worst pH, TDS, and Turbidity values for today.
+// Before the first reading of the day the values are null and total_readings is 0.
 func (h *Handlers) GetWorstDailyValues(w http.ResponseWriter, r *http.Request) {
 	// Get today's date
 	now := time.Now()
@@ -1207,46 +2196,36 @@ func (h *Handlers) GetWorstDailyValues(w http.ResponseWriter, r *http.Request) {
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
 	// Get all readings for today
-	readings := h.store.GetReadingsInRange(startOfDay, endOfDay)
-
-	var worstValues WorstDailyValues
+	readings := h.storeFor(r).GetReadingsInRange(startOfDay, endOfDay)
 
-	if len(readings) == 0 {
-		// Return default values instead of 404 error when no data exists
-		worstValues = WorstDailyValues{
-			Date:          now.Format("2006-01-02"),
-			WorstPH:       7.0,
-			WorstTDS:      400,
-			WorstTurbidity: 0.5,
-			TotalReadings: 0,
-		}
-	} else {
-		// Calculate worst values from actual readings
-		worstValues = calculateWorstValues(readings)
-	}
+	// Calculate worst values from actual readings
+	worstValues := calculateWorstValues(readings)
 
 	response := APIResponse{
 		Success: true,
 		Data:    worstValues,
 	}
+	if len(readings) == 0 {
+		response.Message = "No sensor data available for today"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// WorstDailyValues represents the worst values for a day
+// WorstDailyValues represents the worst values for a day; nil without readings
 type WorstDailyValues struct {
-	Date           string  `json:"date"`
-	WorstPH        float64 `json:"worst_ph"`
-	WorstTDS       float64 `json:"worst_tds"`
-	WorstTurbidity float64 `json:"worst_turbidity"`
-	TotalReadings  int     `json:"total_readings"`
+	Date           string   `json:"date"`
+	WorstPH        *float64 `json:"worst_ph"`
+	WorstTDS       *float64 `json:"worst_tds"`
+	WorstTurbidity *float64 `json:"worst_turbidity"`
+	TotalReadings  int      `json:"total_readings"`
 }
 
 // calculateWorstValues calculates the worst pH, TDS, and Turbidity values from readings
 func calculateWorstValues(readings []models.SensorReading) WorstDailyValues {
 	if len(readings) == 0 {
-		return WorstDailyValues{}
+		return WorstDailyValues{Date: time.Now().Format("2006-01-02")}
 	}
 
 	// For pH: worst is farthest from 7.0 (ideal)
@@ -1276,15 +2255,41 @@ func calculateWorstValues(readings []models.SensorReading) WorstDailyValues {
 
 	return WorstDailyValues{
 		Date:           time.Now().Format("2006-01-02"),
-		WorstPH:        worstPH,
-		WorstTDS:       worstTDS,
-		WorstTurbidity: worstTurbidity,
+		WorstPH:        &worstPH,
+		WorstTDS:       &worstTDS,
+		WorstTurbidity: &worstTurbidity,
 		TotalReadings:  len(readings),
 	}
 }
 
 // ===== Schedule Management Handlers =====
 
+// rejectScheduleConflicts responds with 409 and returns true when the schedule
+// is active and overlaps another active schedule on a shared day, since the
+// scheduler would switch back and forth between their modes
+func (h *Handlers) rejectScheduleConflicts(w http.ResponseWriter, r *http.Request, schedule *models.FilterSchedule) bool {
+	if !schedule.IsActive {
+		return false
+	}
+
+	active, err := h.storeFor(r).GetAllSchedules(true)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to check schedule conflicts: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	conflicts := models.FindScheduleConflicts(schedule, active)
+	if len(conflicts) == 0 {
+		return false
+	}
+	names := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		names[i] = fmt.Sprintf("'%s' (ID %d)", conflict.Name, conflict.ID)
+	}
+	h.sendErrorResponse(w, "Schedule overlaps active schedule "+strings.Join(names, ", ")+" on a shared day", http.StatusConflict)
+	return true
+}
+
 // CreateSchedule handles POST /api/v1/schedules
 func (h *Handlers) CreateSchedule(w http.ResponseWriter, r *http.Request) {
 	var request models.CreateScheduleRequest
@@ -1323,12 +2328,17 @@ func (h *Handlers) CreateSchedule(w http.ResponseWriter, r *http.Request) {
 		StartTime:       normalizedTime,
 		DurationMinutes: request.DurationMinutes,
 		DaysOfWeek:      normalizedDays,
+		RunDate:         request.RunDate,
 		IsActive:        request.IsActive,
 		Timezone:        request.Timezone,
 	}
 
+	if h.rejectScheduleConflicts(w, r, schedule) {
+		return
+	}
+
 	// Save to database
-	if err := h.store.CreateSchedule(schedule); err != nil {
+	if err := h.storeFor(r).CreateSchedule(schedule); err != nil {
 		h.sendErrorResponse(w, "Failed to create schedule: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1356,7 +2366,7 @@ func (h *Handlers) GetAllSchedules(w http.ResponseWriter, r *http.Request) {
 	activeOnlyStr := r.URL.Query().Get("active_only")
 	activeOnly := activeOnlyStr == "true"
 
-	schedules, err := h.store.GetAllSchedules(activeOnly)
+	schedules, err := h.storeFor(r).GetAllSchedules(activeOnly)
 	if err != nil {
 		h.sendErrorResponse(w, "Failed to get schedules: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1372,13 +2382,7 @@ func (h *Handlers) GetAllSchedules(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response := APIResponse{
-		Success: true,
-		Data:    schedulesWithNext,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.sendCollectionResponse(w, schedulesWithNext, len(schedulesWithNext))
 }
 
 // GetSchedule handles GET /api/v1/schedules/{id}
@@ -1390,14 +2394,14 @@ func (h *Handlers) GetSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	schedule, err := h.store.GetSchedule(id)
+	schedule, err := h.storeFor(r).GetSchedule(id)
 	if err != nil {
 		h.sendErrorResponse(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	// Get recent executions
-	executions, _ := h.store.GetScheduleExecutions(id, 5)
+	executions, _ := h.storeFor(r).GetScheduleExecutions(id, 5)
 
 	// Calculate next execution
 	nextExecution := schedule.CalculateNextExecution()
@@ -1425,7 +2429,7 @@ func (h *Handlers) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get existing schedule
-	existing, err := h.store.GetSchedule(id)
+	existing, err := h.storeFor(r).GetSchedule(id)
 	if err != nil {
 		h.sendErrorResponse(w, "Schedule not found", http.StatusNotFound)
 		return
@@ -1458,6 +2462,11 @@ func (h *Handlers) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 	}
 	if request.DaysOfWeek != nil {
 		existing.DaysOfWeek = models.NormalizeDaysOfWeek(request.DaysOfWeek)
+		existing.RunDate = nil
+	}
+	if request.RunDate != nil {
+		existing.RunDate = request.RunDate
+		existing.DaysOfWeek = []string{}
 	}
 	if request.IsActive != nil {
 		existing.IsActive = *request.IsActive
@@ -1466,8 +2475,12 @@ func (h *Handlers) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 		existing.Timezone = *request.Timezone
 	}
 
+	if h.rejectScheduleConflicts(w, r, existing) {
+		return
+	}
+
 	// Save updated schedule
-	if err := h.store.UpdateSchedule(existing); err != nil {
+	if err := h.storeFor(r).UpdateSchedule(existing); err != nil {
 		h.sendErrorResponse(w, "Failed to update schedule: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1482,6 +2495,77 @@ func (h *Handlers) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// Execution count bounds for /schedules/{id}/preview
+const (
+	defaultSchedulePreviewCount = 5
+	maxSchedulePreviewCount     = 50
+)
+
+// schedulePreviewEntry is one upcoming execution of a schedule preview
+type schedulePreviewEntry struct {
+	ExecutesAt time.Time `json:"executes_at"` // UTC
+	LocalTime  string    `json:"local_time"`  // Execution time in the schedule's timezone
+}
+
+// PreviewSchedule handles GET /api/v1/schedules/{id}/preview
+// Query params: count (1-50, default 5). Lists the schedule's next executions;
+// an inactive schedule gets the ones it would have once enabled, with is_active false.
+func (h *Handlers) PreviewSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	count := defaultSchedulePreviewCount
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			h.sendErrorResponse(w, "Invalid count. Use a positive integer", http.StatusBadRequest)
+			return
+		}
+		if count > maxSchedulePreviewCount {
+			count = maxSchedulePreviewCount
+		}
+	}
+
+	schedule, err := h.storeFor(r).GetSchedule(id)
+	if err != nil {
+		h.sendErrorResponse(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	executions := []schedulePreviewEntry{}
+	for _, at := range schedule.UpcomingExecutions(time.Now(), count) {
+		executions = append(executions, schedulePreviewEntry{
+			ExecutesAt: at,
+			LocalTime:  at.In(loc).Format(time.RFC3339),
+		})
+	}
+
+	response := APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"schedule_id": schedule.ID,
+			"name":        schedule.Name,
+			"timezone":    schedule.Timezone,
+			"is_active":   schedule.IsActive,
+			"executions":  executions,
+			"count":       len(executions),
+		},
+	}
+	if !schedule.IsActive {
+		response.Message = "Schedule is inactive; these executions only happen once it is enabled"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // DeleteSchedule handles DELETE /api/v1/schedules/{id}
 func (h *Handlers) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -1496,7 +2580,7 @@ func (h *Handlers) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
 		h.scheduler.CancelExecution(id)
 	}
 
-	if err := h.store.DeleteSchedule(id); err != nil {
+	if err := h.storeFor(r).DeleteSchedule(id); err != nil {
 		h.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1528,7 +2612,19 @@ func (h *Handlers) ToggleSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.ToggleSchedule(id, request.IsActive); err != nil {
+	if request.IsActive {
+		schedule, err := h.storeFor(r).GetSchedule(id)
+		if err != nil {
+			h.sendErrorResponse(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		schedule.IsActive = true
+		if h.rejectScheduleConflicts(w, r, schedule) {
+			return
+		}
+	}
+
+	if err := h.storeFor(r).ToggleSchedule(id, request.IsActive); err != nil {
 		h.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1568,10 +2664,10 @@ func (h *Handlers) GetScheduleExecutionHistory(w http.ResponseWriter, r *http.Re
 			h.sendErrorResponse(w, "Invalid schedule_id", http.StatusBadRequest)
 			return
 		}
-		executions, err = h.store.GetScheduleExecutions(scheduleID, limit)
+		executions, err = h.storeFor(r).GetScheduleExecutions(scheduleID, limit)
 	} else {
 		// Get all executions
-		executions, err = h.store.GetAllScheduleExecutions(limit)
+		executions, err = h.storeFor(r).GetAllScheduleExecutions(limit)
 	}
 
 	if err != nil {
@@ -1579,9 +2675,705 @@ func (h *Handlers) GetScheduleExecutionHistory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if executions == nil {
+		executions = []models.ScheduleExecution{}
+	}
+
+	h.sendCollectionResponse(w, executions, len(executions))
+}
+
+// GetFilterModeChanges handles GET /api/v1/commands/filter/changes
+// Returns the filter mode change audit log, newest first
+func (h *Handlers) GetFilterModeChanges(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	changes, err := h.storeFor(r).GetFilterModeChanges(limit)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get filter mode changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []models.FilterModeChange{}
+	}
+
+	h.sendCollectionResponse(w, changes, len(changes))
+}
+
+// SetDeviceFilter handles PUT /api/v1/devices/{deviceID}/filter
+// Records the rated capacity, cost and install date of the device's filter,
+// used for filter economics. installed_at defaults to now.
+func (h *Handlers) SetDeviceFilter(w http.ResponseWriter, r *http.Request) {
+	var spec models.FilterSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	spec.DeviceID = chi.URLParam(r, "deviceID")
+	if spec.InstalledAt.IsZero() {
+		spec.InstalledAt = time.Now()
+	}
+	if err := spec.Validate(); err != nil {
+		h.sendErrorResponse(w, "Invalid filter metadata: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storeFor(r).SetFilterSpec(spec); err != nil {
+		h.sendErrorResponse(w, "Failed to save filter metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: "Filter metadata updated",
+		Data:    spec,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAlertPreferences handles GET /api/v1/devices/{deviceID}/alert-prefs
+// Returns the anomaly metrics and severities muted for the device; nothing is
+// muted until preferences are set
+func (h *Handlers) GetAlertPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := h.storeFor(r).GetAlertPreferences(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get alert preferences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{Success: true, Data: prefs})
+}
+
+// SetAlertPreferences handles PUT /api/v1/devices/{deviceID}/alert-prefs
+// Replaces the anomaly metrics and severities muted for the device. Muted
+// anomalies are still recorded, they just don't alert.
+func (h *Handlers) SetAlertPreferences(w http.ResponseWriter, r *http.Request) {
+	var prefs models.AlertPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	prefs.DeviceID = chi.URLParam(r, "deviceID")
+	if prefs.MutedMetrics == nil {
+		prefs.MutedMetrics = []string{}
+	}
+	if prefs.MutedSeverities == nil {
+		prefs.MutedSeverities = []string{}
+	}
+	if err := prefs.Validate(); err != nil {
+		h.sendErrorResponse(w, "Invalid alert preferences: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storeFor(r).SetAlertPreferences(prefs); err != nil {
+		h.sendErrorResponse(w, "Failed to save alert preferences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: "Alert preferences updated",
+		Data:    prefs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDeviceCommands handles GET /api/v1/devices/{deviceID}/commands
+// Returns the most recent commands sent to the device and their delivery status, newest first
+func (h *Handlers) GetDeviceCommands(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	commands, err := h.storeFor(r).GetDeviceCommands(deviceID, limit)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device commands: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if commands == nil {
+		commands = []models.DeviceCommand{}
+	}
+
+	h.sendCollectionResponse(w, commands, len(commands))
+}
+
+// AddMaintenanceEvent handles POST /api/v1/devices/{deviceID}/maintenance
+// Logs maintenance performed on the device; performed_at defaults to now
+func (h *Handlers) AddMaintenanceEvent(w http.ResponseWriter, r *http.Request) {
+	var event models.MaintenanceEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	event.ID = 0
+	event.DeviceID = chi.URLParam(r, "deviceID")
+	if event.PerformedAt.IsZero() {
+		event.PerformedAt = time.Now()
+	}
+	if err := event.Validate(); err != nil {
+		h.sendErrorResponse(w, "Invalid maintenance event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storeFor(r).AddMaintenanceEvent(&event); err != nil {
+		h.sendErrorResponse(w, "Failed to save maintenance event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A new filter changes what the device's readings should look like
+	message := "Maintenance event logged"
+	if event.Type == models.MaintenanceFilterReplacement && h.mlService != nil {
+		reset, err := h.mlService.HandleFilterReplacement(event.DeviceID, event.PerformedAt)
+		if err != nil {
+			log.Printf("Warning: Failed to reset baselines after filter replacement on %s: %v", event.DeviceID, err)
+		} else if reset != nil {
+			message = fmt.Sprintf("Maintenance event logged; %d baselines reset and %d anomalies resolved",
+				len(reset.BaselinesReset), reset.AnomaliesResolved)
+		}
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: message,
+		Data:    event,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMaintenanceEvents handles GET /api/v1/devices/{deviceID}/maintenance
+// Returns the device's maintenance log, most recently performed first
+func (h *Handlers) GetMaintenanceEvents(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	events, err := h.storeFor(r).GetMaintenanceEvents(deviceID, limit)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get maintenance events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.MaintenanceEvent{}
+	}
+
+	h.sendCollectionResponse(w, events, len(events))
+}
+
+// GetNextSchedule handles GET /api/v1/schedules/next
+func (h *Handlers) GetNextSchedule(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.storeFor(r).GetAllSchedules(true)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get schedules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	next := models.FindNextScheduledRun(schedules)
+
+	response := APIResponse{
+		Success: true,
+		Data:    next,
+	}
+	if next == nil {
+		response.Message = "No upcoming scheduled executions"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDevices handles GET /api/v1/devices
+// Lists every device that sent readings with its name, activation and operational state
+func (h *Handlers) GetDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := h.storeFor(r).GetDeviceStatuses()
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if devices == nil {
+		devices = []models.DeviceStatus{}
+	}
+
+	h.sendCollectionResponse(w, devices, len(devices))
+}
+
+// GetDevice handles GET /api/v1/devices/{deviceID}
+func (h *Handlers) GetDevice(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	device, found, err := h.storeFor(r).GetDeviceStatus(deviceID)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		h.sendErrorResponse(w, "Device not found: "+deviceID, http.StatusNotFound)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Data:    device,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateDevice handles PATCH /api/v1/devices/{deviceID}
+// Sets the device's name and/or is_active; omitted fields are left unchanged
+func (h *Handlers) UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	var update models.DeviceStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		update.Name = &name
+	}
+	if err := update.Validate(); err != nil {
+		h.sendErrorResponse(w, "Invalid device update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, found, err := h.storeFor(r).PatchDeviceStatus(deviceID, update)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to update device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		h.sendErrorResponse(w, "Device not found: "+deviceID, http.StatusNotFound)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Message: "Device updated",
+		Data:    device,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetEffectiveDeviceConfig handles GET /api/v1/devices/{deviceID}/effective-config
+// Returns every rule that applies to the device after overrides resolve, each
+// with the source it came from: device values shadow global ones, which shadow
+// the built-in defaults
+func (h *Handlers) GetEffectiveDeviceConfig(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	device, found, err := h.storeFor(r).GetDeviceStatus(deviceID)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		h.sendErrorResponse(w, "Device not found: "+deviceID, http.StatusNotFound)
+		return
+	}
+
+	config, err := h.effectiveDeviceConfig(h.storeFor(r), device)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to resolve device config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Success: true,
+		Data:    config,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// effectiveDeviceConfig composes the device's configuration from its metadata,
+// the server configuration and the built-in defaults
+func (h *Handlers) effectiveDeviceConfig(dataStore store.DataStore, device *models.DeviceStatus) (*models.EffectiveDeviceConfig, error) {
+	overrides, err := dataStore.GetDeviceTypeOverrides()
+	if err != nil {
+		return nil, err
+	}
+	spec, hasSpec, err := dataStore.GetFilterSpec(device.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &models.EffectiveDeviceConfig{
+		DeviceID:    device.DeviceID,
+		Name:        device.Name,
+		IsActive:    device.IsActive,
+		Calibration: models.ConfigSetting{Value: models.DefaultSensorCalibration, Source: models.ConfigSourceDefault},
+	}
+
+	// Device type: a metadata override shadows inference from the ID
+	typeInfo := models.ClassifyDeviceType(device.DeviceID, overrides)
+	deviceType := models.ConfigSetting{Source: models.ConfigSourceDevice}
+	if typeInfo.Overridden {
+		deviceType.Value = typeInfo.DeviceType
+	}
+	config.DeviceType = models.ResolveSetting(
+		models.ConfigSetting{Value: typeInfo.InferredType, Source: models.ConfigSourceDefault},
+		deviceType,
+	)
+
+	// Filter mode: the mode of the device's latest reading shadows the system mode
+	deviceMode := models.ConfigSetting{Source: models.ConfigSourceDevice}
+	if latest, exists := dataStore.GetLatestReadingByDevice(device.DeviceID); exists && latest.FilterMode != "" {
+		deviceMode.Value = latest.FilterMode
+	}
+	config.FilterMode = models.ResolveSetting(
+		models.ConfigSetting{Value: dataStore.GetCurrentFilterMode(), Source: models.ConfigSourceGlobal},
+		deviceMode,
+	)
+
+	rulesVersion := models.ConfigSetting{Source: models.ConfigSourceGlobal}
+	if version := models.QualityRulesVersion(); version != models.BuiltinQualityRulesVersion {
+		rulesVersion.Value = version
+	}
+	config.QualityRules = models.ResolveSetting(
+		models.ConfigSetting{Value: models.BuiltinQualityRulesVersion, Source: models.ConfigSourceDefault},
+		rulesVersion,
+	)
+	limits := models.ConfigSetting{Source: models.ConfigSourceGlobal}
+	if thresholds := models.QualityThresholdsFor(config.FilterMode.Value.(models.FilterMode)); thresholds != models.DefaultQualityThresholds {
+		limits.Value = thresholds
+	}
+	config.QualityThresholds = models.ResolveSetting(
+		models.ConfigSetting{Value: models.DefaultQualityThresholds, Source: models.ConfigSourceDefault},
+		limits,
+	)
+
+	// Anomaly detection checks readings against the baseline of the device in its mode
+	detector := ml.NewAnomalyDetector()
+	if h.mlService != nil {
+		detector = h.mlService.GetAnomalyDetector()
+	}
+	baseline, err := dataStore.GetBaseline(device.DeviceID, config.FilterMode.Value.(models.FilterMode))
+	if err != nil {
+		return nil, err
+	}
+	config.AnomalyDetection = models.EffectiveAnomalyConfig{
+		ZScoreThreshold: models.ConfigSetting{Value: detector.ZScoreThreshold(), Source: models.ConfigSourceDefault},
+		SpikeMultiplier: models.ConfigSetting{Value: detector.SpikeMultiplier(), Source: models.ConfigSourceDefault},
+		Baseline:        baseline,
+		Active:          baseline != nil && baseline.SampleSize >= ml.MinBaselineSamples,
+	}
+
+	config.TargetVolume = models.EffectiveTargetVolume{
+		Default: models.ConfigSetting{Value: models.DefaultTargetVolume, Source: models.ConfigSourceDefault},
+		Min:     configuredSetting(models.DefaultTargetVolumeBounds.Min, h.targetVolumes.Min),
+		Max:     configuredSetting(models.DefaultTargetVolumeBounds.Max, h.targetVolumes.Max),
+	}
+
+	config.Cooldowns.ModeChange = models.ConfigSetting{Value: h.modeCooldown.MinInterval().String(), Source: models.ConfigSourceGlobal}
+	config.Filter.ReplacementAlertDays = models.ConfigSetting{Source: models.ConfigSourceGlobal}
+	if h.mlService != nil {
+		config.Cooldowns.AnomalyAlerts = models.ConfigSetting{Value: h.mlService.AlertThrottleWindow().String(), Source: models.ConfigSourceGlobal}
+		config.Filter.ReplacementAlertDays.Value = h.mlService.ReplacementAlertDays()
+	}
+	if hasSpec {
+		config.Filter.Spec = &spec
+	}
+
+	return config, nil
+}
+
+// configuredSetting resolves a value that the server configuration may change
+// from its built-in default
+func configuredSetting(builtin, configured interface{}) models.ConfigSetting {
+	global := models.ConfigSetting{Source: models.ConfigSourceGlobal}
+	if configured != builtin {
+		global.Value = configured
+	}
+	return models.ResolveSetting(models.ConfigSetting{Value: builtin, Source: models.ConfigSourceDefault}, global)
+}
+
+// ML profile limits: how many recent anomalies are included and how far
+// around now predictions are included
+const (
+	mlProfileAnomalyLimit     = 20
+	mlProfilePredictionWindow = 24 * time.Hour
+)
+
+// ML profile section names, listed in empty_sections when the device has no data for them
+const (
+	mlProfileBaselines    = "baselines"
+	mlProfileFilterHealth = "filter_health"
+	mlProfileAnomalies    = "recent_anomalies"
+	mlProfilePredictions  = "recent_predictions"
+)
+
+// deviceFlowTracking is the flow accumulated by a device and, system-wide,
+// since the current filter mode started
+type deviceFlowTracking struct {
+	CurrentFilterMode models.FilterMode      `json:"current_filter_mode"`
+	TotalFlowLiters   float64                `json:"total_flow_liters"`
+	LastSeen          *time.Time             `json:"last_seen"`
+	System            map[string]interface{} `json:"system"` // Filter mode tracking across devices
+}
+
+// deviceMLProfile is everything the ML features know about one device
+type deviceMLProfile struct {
+	DeviceID          string                    `json:"device_id"`
+	GeneratedAt       time.Time                 `json:"generated_at"`
+	Baselines         []models.SensorBaseline   `json:"baselines"`          // One per filter mode with a baseline
+	FilterHealth      *models.FilterHealth      `json:"filter_health"`      // Null until the device's filter is analyzed
+	RecentAnomalies   []models.AnomalyDetection `json:"recent_anomalies"`   // Most recent first
+	RecentPredictions []models.SensorPrediction `json:"recent_predictions"` // Predicted for the last and next 24h, oldest first
+	FlowTracking      deviceFlowTracking        `json:"flow_tracking"`
+	EmptySections     []string                  `json:"empty_sections"` // Sections without any data for the device
+}
+
+// GetDeviceMLProfile handles GET /api/v1/devices/{deviceID}/ml-profile
+// Returns the device's baselines, latest filter health, recent anomalies and
+// predictions and flow tracking in one document for support sessions
+func (h *Handlers) GetDeviceMLProfile(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+	dataStore := h.storeFor(r)
+
+	device, found, err := dataStore.GetDeviceStatus(deviceID)
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		h.sendErrorResponse(w, "Device not found: "+deviceID, http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	profile := deviceMLProfile{
+		DeviceID:    deviceID,
+		GeneratedAt: now,
+		Baselines:   []models.SensorBaseline{},
+		FlowTracking: deviceFlowTracking{
+			CurrentFilterMode: device.CurrentFilterMode,
+			TotalFlowLiters:   device.TotalFlowLiters,
+			LastSeen:          device.LastSeen,
+			System:            dataStore.GetFilterModeTracking(),
+		},
+		EmptySections: []string{},
+	}
+	if profile.FlowTracking.System == nil {
+		profile.FlowTracking.System = store.EmptyFilterModeTracking()
+	}
+
+	for _, mode := range []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold} {
+		baseline, err := dataStore.GetBaseline(deviceID, mode)
+		if err != nil {
+			h.sendErrorResponse(w, "Failed to get baselines: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if baseline != nil {
+			profile.Baselines = append(profile.Baselines, *baseline)
+		}
+	}
+
+	if profile.FilterHealth, err = dataStore.GetLatestFilterHealth(deviceID); err != nil {
+		h.sendErrorResponse(w, "Failed to get filter health: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile.RecentAnomalies, err = dataStore.GetAnomaliesByDevice(deviceID, mlProfileAnomalyLimit); err != nil {
+		h.sendErrorResponse(w, "Failed to get anomalies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile.RecentAnomalies == nil {
+		profile.RecentAnomalies = []models.AnomalyDetection{}
+	}
+
+	profile.RecentPredictions, err = dataStore.GetSensorPredictions(deviceID, now.Add(-mlProfilePredictionWindow), now.Add(mlProfilePredictionWindow))
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get predictions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile.RecentPredictions == nil {
+		profile.RecentPredictions = []models.SensorPrediction{}
+	}
+
+	for section, empty := range map[string]bool{
+		mlProfileBaselines:    len(profile.Baselines) == 0,
+		mlProfileFilterHealth: profile.FilterHealth == nil,
+		mlProfileAnomalies:    len(profile.RecentAnomalies) == 0,
+		mlProfilePredictions:  len(profile.RecentPredictions) == 0,
+	} {
+		if empty {
+			profile.EmptySections = append(profile.EmptySections, section)
+		}
+	}
+	sort.Strings(profile.EmptySections)
+
+	response := APIResponse{
+		Success: true,
+		Data:    profile,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDeviceTypes handles GET /api/v1/devices/types
+func (h *Handlers) GetDeviceTypes(w http.ResponseWriter, r *http.Request) {
+	devices, err := store.ClassifyDevices(h.storeFor(r))
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendCollectionResponse(w, devices, len(devices))
+}
+
+// GetDevicesOverview handles GET /api/v1/devices/overview, returning every known
+// device's status, latest quality and filter health, most urgent first
+func (h *Handlers) GetDevicesOverview(w http.ResponseWriter, r *http.Request) {
+	overviews, err := buildDeviceOverviews(h.storeFor(r), time.Now())
+	if err != nil {
+		log.Printf("❌ Error building device overview: %v", err)
+		h.sendErrorResponse(w, "Failed to build device overview", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendCollectionResponse(w, overviews, len(overviews))
+}
+
+// attentionRank orders attention levels for sorting, most urgent highest
+var attentionRank = map[string]int{
+	models.AttentionCritical: 2,
+	models.AttentionWarning:  1,
+	models.AttentionOK:       0,
+}
+
+// buildDeviceOverviews composes the overview of every known device from the
+// latest readings (fetched once for all devices) and each device's filter health
+func buildDeviceOverviews(dataStore store.DataStore, now time.Time) ([]models.DeviceOverview, error) {
+	devices, err := store.ClassifyDevices(dataStore)
+	if err != nil {
+		return nil, err
+	}
+	latestReadings := dataStore.GetAllLatestReadingsByDevice()
+	_, postDeviceID := store.ResolveFilterDevices(dataStore)
+
+	overviews := make([]models.DeviceOverview, 0, len(devices))
+	for _, device := range devices {
+		overview := models.DeviceOverview{
+			DeviceID:         device.DeviceID,
+			DeviceType:       device.DeviceType,
+			AttentionReasons: []string{},
+		}
+		level := models.AttentionOK
+		raise := func(to, reason string) {
+			if attentionRank[to] > attentionRank[level] {
+				level = to
+			}
+			overview.AttentionReasons = append(overview.AttentionReasons, reason)
+		}
+
+		if reading, exists := latestReadings[device.DeviceID]; exists {
+			lastSeen := reading.Timestamp
+			quality := reading.ToWaterQualityStatus()
+			overview.LastSeen = &lastSeen
+			overview.FilterMode = reading.FilterMode
+			overview.LatestQuality = &quality
+			overview.Online = now.Sub(lastSeen) <= deviceOfflineAfter
+
+			if !overview.Online {
+				raise(models.AttentionCritical, fmt.Sprintf("offline since %s", lastSeen.Format(time.RFC3339)))
+			}
+			// Raw water before filtration is expected to be poor
+			if quality.OverallQuality == "Danger" && device.DeviceType != models.DeviceTypePreFiltration {
+				raise(models.AttentionCritical, "dangerous water quality")
+			}
+		} else {
+			raise(models.AttentionWarning, "no readings received")
+		}
+
+		var health *models.FilterHealth
+		if device.DeviceID == postDeviceID {
+			health, err = latestSystemFilterHealth(dataStore)
+		} else {
+			health, err = dataStore.GetLatestFilterHealth(device.DeviceID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get filter health for %s: %w", device.DeviceID, err)
+		}
+		if health != nil {
+			overview.FilterHealth = health
+			if health.ReplacementUrgent {
+				raise(models.AttentionCritical, "filter replacement urgent")
+			} else if health.MaintenanceRequired {
+				raise(models.AttentionWarning, "filter maintenance required")
+			}
+		}
+
+		overview.AttentionLevel = level
+		overviews = append(overviews, overview)
+	}
+
+	// Devices needing attention first; ClassifyDevices already ordered them by ID
+	sort.SliceStable(overviews, func(i, j int) bool {
+		return attentionRank[overviews[i].AttentionLevel] > attentionRank[overviews[j].AttentionLevel]
+	})
+	return overviews, nil
+}
+
+// SetDeviceType handles PUT /api/v1/devices/{deviceID}/type
+func (h *Handlers) SetDeviceType(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "deviceID")
+
+	var request struct {
+		DeviceType string `json:"device_type"` // Empty clears the override
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.DeviceType != "" && !models.IsValidDeviceType(request.DeviceType) {
+		h.sendErrorResponse(w, "Invalid device_type. Use 'pre_filtration', 'post_filtration' or 'unknown'", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storeFor(r).SetDeviceTypeOverride(deviceID, request.DeviceType); err != nil {
+		h.sendErrorResponse(w, "Failed to set device type: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	overrides, err := h.storeFor(r).GetDeviceTypeOverrides()
+	if err != nil {
+		h.sendErrorResponse(w, "Failed to get device types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	response := APIResponse{
 		Success: true,
-		Data:    executions,
+		Message: "Device type updated",
+		Data:    models.ClassifyDeviceType(deviceID, overrides),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1,10 +1,20 @@
 package http
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/services"
 	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/Capstone-E1/aquasmart_backend/internal/ws"
+	"github.com/go-chi/chi/v5"
 )
 
 // TestFiltrationBlocking tests the core filtration blocking logic
@@ -136,4 +146,2006 @@ func TestAPIResponse_Structure(t *testing.T) {
 	if response.Data == nil {
 		t.Error("Expected data to be set")
 	}
-}
\ No newline at end of file
+}
+
+// newTestRouter wires the sensor endpoints covered by the response policy tests
+func newTestRouter(dataStore store.DataStore) *chi.Mux {
+	handlers := NewHandlers(dataStore, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/latest", handlers.GetLatestReadings)
+	r.Get("/sensors/recent", handlers.GetRecentReadings)
+	r.Get("/sensors/best-daily", handlers.GetBestDailyValues)
+	r.Get("/sensors/worst-daily", handlers.GetWorstDailyValues)
+	r.Get("/sensors/stats", handlers.GetSensorDataStats)
+	r.Get("/sensors/devices/latest", handlers.GetAllDevicesLatest)
+	r.Get("/sensors/devices/{deviceID}", handlers.GetDeviceReadings)
+	return r
+}
+
+// doRequest performs a GET request and decodes the standard API response
+func doRequest(t *testing.T, router http.Handler, path string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response for %s: %v", path, err)
+	}
+	return rec.Code, body
+}
+
+// TestResponsePolicy_EmptyCollections tests that collection endpoints return 200 with an empty collection and count
+func TestResponsePolicy_EmptyCollections(t *testing.T) {
+	router := newTestRouter(store.NewStore(100))
+
+	paths := []string{
+		"/sensors/devices/stm32_pre",
+		"/sensors/devices/latest",
+		"/sensors/recent",
+		"/sensors/latest",
+	}
+
+	for _, path := range paths {
+		code, body := doRequest(t, router, path)
+		if code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, code)
+		}
+		if body["count"] != float64(0) {
+			t.Errorf("%s: expected count 0, got %v", path, body["count"])
+		}
+		if body["data"] == nil {
+			t.Errorf("%s: expected empty collection, got null", path)
+		}
+	}
+}
+
+// TestResponsePolicy_CollectionCount tests that collection endpoints report the item count
+func TestResponsePolicy_CollectionCount(t *testing.T) {
+	s := store.NewStore(100)
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 7.0})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 7.1})
+	router := newTestRouter(s)
+
+	code, body := doRequest(t, router, "/sensors/devices/stm32_pre")
+	if code != http.StatusOK || body["count"] != float64(1) {
+		t.Errorf("Expected 200 with count 1 for device readings, got %d with count %v", code, body["count"])
+	}
+
+	code, body = doRequest(t, router, "/sensors/devices/latest")
+	if code != http.StatusOK || body["count"] != float64(2) {
+		t.Errorf("Expected 200 with count 2 for devices latest, got %d with count %v", code, body["count"])
+	}
+}
+
+// TestResponsePolicy_MissingSingleResource tests that single-resource lookups return 404 when missing
+func TestResponsePolicy_MissingSingleResource(t *testing.T) {
+	router := newTestRouter(store.NewStore(100))
+
+	paths := []string{
+		"/sensors/latest?device_id=stm32_pre",
+	}
+
+	for _, path := range paths {
+		code, body := doRequest(t, router, path)
+		if code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, code)
+		}
+		if body["success"] != false {
+			t.Errorf("%s: expected success=false, got %v", path, body["success"])
+		}
+	}
+}
+
+// TestResponsePolicy_EmptySummaries tests that summaries of no data return 200
+// with their usual shape, zero readings and null values
+func TestResponsePolicy_EmptySummaries(t *testing.T) {
+	router := newTestRouter(store.NewStore(100))
+
+	t.Run("stats", func(t *testing.T) {
+		code, body := doRequest(t, router, "/sensors/stats")
+		if code != http.StatusOK || body["success"] != true {
+			t.Fatalf("Expected 200 with success, got %d: %v", code, body)
+		}
+		data := body["data"].(map[string]interface{})
+		if data["total_readings"] != float64(0) {
+			t.Errorf("Expected total_readings 0, got %v", data["total_readings"])
+		}
+		for _, key := range []string{"date_range", "ph_stats", "tds_stats", "turbidity_stats", "flow_stats", "filter_modes", "quality_breakdown"} {
+			if group, ok := data[key].(map[string]interface{}); !ok || len(group) != 0 {
+				t.Errorf("Expected %s to be an empty object, got %v", key, data[key])
+			}
+		}
+	})
+
+	for path, keys := range map[string][]string{
+		"/sensors/best-daily":  {"best_ph", "best_tds", "best_turbidity"},
+		"/sensors/worst-daily": {"worst_ph", "worst_tds", "worst_turbidity"},
+	} {
+		t.Run(path, func(t *testing.T) {
+			code, body := doRequest(t, router, path)
+			if code != http.StatusOK || body["success"] != true {
+				t.Fatalf("Expected 200 with success, got %d: %v", code, body)
+			}
+			data := body["data"].(map[string]interface{})
+			if data["total_readings"] != float64(0) || data["date"] != time.Now().Format("2006-01-02") {
+				t.Errorf("Expected today with no readings, got %v", data)
+			}
+			for _, key := range keys {
+				if value, exists := data[key]; !exists || value != nil {
+					t.Errorf("Expected %s to be null, got %v", key, value)
+				}
+			}
+		})
+	}
+}
+
+// TestResponsePolicy_DailyValuesWithReadings tests that a reading of today is
+// reported as both the best and worst value
+func TestResponsePolicy_DailyValuesWithReadings(t *testing.T) {
+	s := store.NewStore(100)
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 0, TDS: 120, Turbidity: 0.5})
+	router := newTestRouter(s)
+
+	_, body := doRequest(t, router, "/sensors/best-daily")
+	if data := body["data"].(map[string]interface{}); data["best_ph"] != float64(0) || data["best_tds"] != float64(120) || data["total_readings"] != float64(1) {
+		t.Errorf("Expected the reading's values, including a pH of 0, got %v", data)
+	}
+	_, body = doRequest(t, router, "/sensors/worst-daily")
+	if data := body["data"].(map[string]interface{}); data["worst_ph"] != float64(0) || data["worst_turbidity"] != 0.5 {
+		t.Errorf("Expected the reading's values, including a pH of 0, got %v", data)
+	}
+}
+
+// TestLongPoll_ReadingUnblocksWait tests that a reading stored during the wait is returned
+func TestLongPoll_ReadingUnblocksWait(t *testing.T) {
+	s := store.NewStore(100)
+	handlers := NewHandlers(s, nil, nil, nil)
+
+	since := time.Now().Add(-time.Second)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 7.2})
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sensors/latest/longpoll?timeout=5s&since="+since.Format(time.RFC3339), nil)
+	rec := httptest.NewRecorder()
+
+	started := time.Now()
+	handlers.GetLatestReadingLongPoll(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if time.Since(started) > 2*time.Second {
+		t.Errorf("Expected the new reading to unblock the wait, took %v", time.Since(started))
+	}
+
+	var body struct {
+		Data models.SensorReading `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Data.DeviceID != "stm32_post" {
+		t.Errorf("Expected reading from stm32_post, got %q", body.Data.DeviceID)
+	}
+}
+
+// TestLongPoll_Timeout tests that no new reading within the timeout returns 204
+func TestLongPoll_Timeout(t *testing.T) {
+	s := store.NewStore(100)
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now().Add(-time.Minute), FilterMode: models.FilterModeDrinking})
+	handlers := NewHandlers(s, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sensors/latest/longpoll?timeout=100ms", nil)
+	rec := httptest.NewRecorder()
+	handlers.GetLatestReadingLongPoll(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+}
+
+func TestExportRange_MaxRangeEnforced(t *testing.T) {
+	handlers := NewHandlers(store.NewStore(100), nil, nil, nil)
+	handlers.exportMaxRange = 7 * 24 * time.Hour
+	r := chi.NewRouter()
+	r.Get("/export/history.csv", handlers.ExportHistoryCSV)
+	r.Get("/export/history.xlsx", handlers.ExportHistoryExcel)
+	r.Get("/export/history.json", handlers.ExportHistoryJSON)
+	r.Get("/export/history.pdf", handlers.ExportHistoryPDF)
+
+	end := time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)
+	atLimit := "start=" + end.Add(-7*24*time.Hour).Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+	overLimit := "start=" + end.Add(-7*24*time.Hour-time.Second).Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+
+	for _, path := range []string{"/export/history.csv", "/export/history.xlsx", "/export/history.json", "/export/history.pdf"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?"+atLimit, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for an at-limit range, got %d", path, rec.Code)
+		}
+
+		code, body := doRequest(t, r, path+"?"+overLimit)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an over-limit range, got %d", path, code)
+		}
+		if body["error"] == nil {
+			t.Errorf("%s: expected an error message for an over-limit range", path)
+		}
+
+		reversed := "start=" + end.Format(time.RFC3339) + "&end=" + end.Add(-time.Hour).Format(time.RFC3339)
+		if code, _ := doRequest(t, r, path+"?"+reversed); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an end before the start, got %d", path, code)
+		}
+	}
+}
+
+func TestExportHistoryJSON_StreamsReadingsInRange(t *testing.T) {
+	s := store.NewStore(100)
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		mode := models.FilterModeDrinking
+		if i == 2 {
+			mode = models.FilterModeHousehold
+		}
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: start.Add(time.Duration(i) * time.Hour), FilterMode: mode, Ph: 7, TDS: 100})
+	}
+	handlers := NewHandlers(s, nil, nil, nil)
+
+	query := "start=" + start.Add(time.Hour).Format(time.RFC3339) + "&end=" + start.Add(4*time.Hour).Format(time.RFC3339) + "&filter_mode=drinking_water"
+	rec := httptest.NewRecorder()
+	handlers.ExportHistoryJSON(rec, httptest.NewRequest(http.MethodGet, "/export/history.json?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		ExportMetadata struct {
+			TotalReadings int `json:"total_readings"`
+		} `json:"export_metadata"`
+		SensorReadings []models.SensorReading `json:"sensor_readings"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+	if body.ExportMetadata.TotalReadings != 3 || len(body.SensorReadings) != 3 {
+		t.Fatalf("Expected 3 drinking water readings in range, got total %d and %d readings", body.ExportMetadata.TotalReadings, len(body.SensorReadings))
+	}
+	for i, hour := range []int{1, 3, 4} {
+		if want := start.Add(time.Duration(hour) * time.Hour); !body.SensorReadings[i].Timestamp.Equal(want) {
+			t.Errorf("Reading %d: expected %s, got %s", i, want, body.SensorReadings[i].Timestamp)
+		}
+	}
+}
+
+func TestParseExportRange_DefaultsAndValidation(t *testing.T) {
+	handlers := NewHandlers(store.NewStore(100), nil, nil, nil)
+	handlers.exportDefaultDays = 7
+
+	parse := func(query string) (time.Time, time.Time, models.FilterMode, error) {
+		return handlers.parseExportRange(httptest.NewRequest(http.MethodGet, "/export/history.csv?"+query, nil))
+	}
+
+	// No range: the configured number of days up to now
+	start, end, mode, err := parse("")
+	if err != nil {
+		t.Fatalf("Unexpected error for the default range: %v", err)
+	}
+	if time.Since(end) > time.Minute || end.Sub(start) != 7*24*time.Hour || mode != "" {
+		t.Errorf("Expected the last 7 days for all modes, got %s to %s (mode %q)", start, end, mode)
+	}
+
+	// Only an end date: the default span before it
+	start, _, _, err = parse("end=2024-06-08T00:00:00Z")
+	if err != nil || !start.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the default span before the end date, got %s (%v)", start, err)
+	}
+
+	// Explicit range and mode are used as given
+	start, end, mode, err = parse("start=2024-05-01T00:00:00Z&end=2024-05-03T12:00:00Z&filter_mode=household_water")
+	if err != nil {
+		t.Fatalf("Unexpected error for an explicit range: %v", err)
+	}
+	if !start.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)) || mode != models.FilterModeHousehold {
+		t.Errorf("Unexpected explicit range %s to %s (mode %q)", start, end, mode)
+	}
+
+	for _, query := range []string{"start=yesterday", "end=2024-13-01", "filter_mode=pool_water", "start=2024-05-03T00:00:00Z&end=2024-05-01T00:00:00Z"} {
+		if _, _, _, err := parse(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestSearchSensorReadings_FilterCombinations(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		mode := models.FilterModeDrinking
+		if i%2 == 1 {
+			mode = models.FilterModeHousehold
+		}
+		device := "stm32_pre"
+		if i >= 5 {
+			device = "stm32_post"
+		}
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   device,
+			Timestamp:  base.Add(time.Duration(i) * time.Hour),
+			FilterMode: mode,
+			Ph:         6.0 + float64(i)*0.2,
+			TDS:        float64(100 * i),
+			Turbidity:  1,
+			Flow:       2,
+		})
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/search", handlers.SearchSensorReadings)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantTotal int
+		wantPage  int
+	}{
+		{"no filters", "", 10, 10},
+		{"device", "device_id=stm32_post", 5, 5},
+		{"mode and tds predicate", "filter_mode=drinking_water&tds_min=600", 2, 2},
+		{"ph range", "ph_min=6.4&ph_max=7.0", 4, 4},
+		{"time range", "start=2024-06-01T02:00:00Z&end=2024-06-01T04:00:00Z", 3, 3},
+		{"combined with pagination", "device_id=stm32_pre&tds_max=400&limit=2&offset=2", 5, 2},
+		{"offset past end", "offset=50", 10, 0},
+	}
+	for _, tt := range tests {
+		code, body := doRequest(t, r, "/sensors/search?"+tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", tt.name, code)
+			continue
+		}
+		data := body["data"].(map[string]interface{})
+		pagination := data["pagination"].(map[string]interface{})
+		if total := int(pagination["total_records"].(float64)); total != tt.wantTotal {
+			t.Errorf("%s: expected %d total records, got %d", tt.name, tt.wantTotal, total)
+		}
+		if page := len(data["data"].([]interface{})); page != tt.wantPage {
+			t.Errorf("%s: expected %d readings in page, got %d", tt.name, tt.wantPage, page)
+		}
+	}
+
+	// Sorting: ascending returns the oldest reading first
+	_, body := doRequest(t, r, "/sensors/search?sort=asc&limit=1")
+	first := body["data"].(map[string]interface{})["data"].([]interface{})[0].(map[string]interface{})
+	if first["timestamp"] != base.Format(time.RFC3339) {
+		t.Errorf("Expected oldest reading first with sort=asc, got %v", first["timestamp"])
+	}
+}
+
+func TestSearchSensorReadings_InvalidParams(t *testing.T) {
+	handlers := NewHandlers(store.NewStore(10), nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/search", handlers.SearchSensorReadings)
+
+	for _, query := range []string{
+		"filter_mode=unknown",
+		"start=yesterday",
+		"start=2024-06-02T00:00:00Z&end=2024-06-01T00:00:00Z",
+		"ph_min=abc",
+		"tds_min=NaN",
+		"ph_min=8&ph_max=7",
+		"sort=sideways",
+		"limit=0",
+		"limit=5000",
+		"offset=-1",
+		"offset=1000000",
+	} {
+		if code, _ := doRequest(t, r, "/sensors/search?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestSearchSensorReadings_CursorPagesCoverAllReadings(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Pre and post devices report at the same instants, so pages split ties
+	for i := 0; i < 12; i++ {
+		for _, device := range []string{"stm32_pre", "stm32_post"} {
+			s.AddSensorReading(models.SensorReading{
+				DeviceID:   device,
+				Timestamp:  base.Add(time.Duration(i) * time.Minute),
+				FilterMode: models.FilterModeDrinking,
+				Ph:         7,
+			})
+		}
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/search", handlers.SearchSensorReadings)
+
+	for _, sort := range []string{"desc", "asc"} {
+		seen := map[string]bool{}
+		var previous time.Time
+		cursor, pages := "", 0
+		for {
+			code, body := doRequest(t, r, "/sensors/search?limit=5&sort="+sort+"&cursor="+cursor)
+			if code != http.StatusOK {
+				t.Fatalf("%s: expected 200, got %d (%v)", sort, code, body)
+			}
+			pages++
+			data := body["data"].(map[string]interface{})
+			for _, item := range data["data"].([]interface{}) {
+				reading := item.(map[string]interface{})
+				key := reading["device_id"].(string) + "@" + reading["timestamp"].(string)
+				if seen[key] {
+					t.Fatalf("%s: reading %s returned on more than one page", sort, key)
+				}
+				seen[key] = true
+
+				ts, _ := time.Parse(time.RFC3339, reading["timestamp"].(string))
+				if !previous.IsZero() && ((sort == "asc" && ts.Before(previous)) || (sort == "desc" && ts.After(previous))) {
+					t.Fatalf("%s: readings out of order at %s", sort, key)
+				}
+				previous = ts
+			}
+
+			pagination := data["pagination"].(map[string]interface{})
+			if pagination["has_next"] != true {
+				if pagination["next_cursor"] != nil {
+					t.Errorf("%s: expected no next_cursor on the last page", sort)
+				}
+				break
+			}
+			cursor = pagination["next_cursor"].(string)
+			if pages > 10 {
+				t.Fatalf("%s: pagination did not terminate", sort)
+			}
+		}
+
+		if len(seen) != 24 || pages != 5 {
+			t.Errorf("%s: expected 24 readings over 5 pages, got %d over %d", sort, len(seen), pages)
+		}
+	}
+
+	for _, query := range []string{"cursor=not-a-cursor", "cursor=&offset=5"} {
+		if code, _ := doRequest(t, r, "/sensors/search?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetMetricHeatmap_GridAndAverages(t *testing.T) {
+	s := store.NewStore(500)
+	monday := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC) // A Monday
+	for week := 0; week < 2; week++ {
+		for hour := 0; hour < 24; hour++ {
+			tds := 100.0
+			if hour == 8 {
+				tds = 600 + float64(week)*200 // Weekday-morning spike: 600 then 800
+			}
+			s.AddSensorReading(models.SensorReading{
+				DeviceID:   "stm32_pre",
+				Timestamp:  monday.AddDate(0, 0, 7*week).Add(time.Duration(hour) * time.Hour),
+				FilterMode: models.FilterModeDrinking,
+				TDS:        tds,
+			})
+		}
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/heatmap", handlers.GetMetricHeatmap)
+
+	code, body := doRequest(t, r, "/sensors/heatmap?metric=tds&start=2024-06-01T00:00:00Z&end=2024-06-30T00:00:00Z")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	data := body["data"].(map[string]interface{})
+	averages := data["averages"].([]interface{})
+	if len(averages) != 7 {
+		t.Fatalf("Expected 7 day rows, got %d", len(averages))
+	}
+	for day, row := range averages {
+		if len(row.([]interface{})) != 24 {
+			t.Fatalf("Expected 24 hour columns for day %d, got %d", day, len(row.([]interface{})))
+		}
+	}
+
+	mondayRow := averages[int(time.Monday)].([]interface{})
+	if mondayRow[8] != 700.0 {
+		t.Errorf("Expected Monday 08:00 average of 700, got %v", mondayRow[8])
+	}
+	if mondayRow[9] != 100.0 {
+		t.Errorf("Expected Monday 09:00 average of 100, got %v", mondayRow[9])
+	}
+	if averages[int(time.Tuesday)].([]interface{})[8] != nil {
+		t.Error("Expected cells without readings to be null")
+	}
+	if count := data["counts"].([]interface{})[int(time.Monday)].([]interface{})[8]; count != 2.0 {
+		t.Errorf("Expected 2 readings in Monday 08:00 cell, got %v", count)
+	}
+	if days := data["days"].([]interface{}); days[0] != "Sunday" || days[6] != "Saturday" {
+		t.Errorf("Unexpected day labels: %v", days)
+	}
+
+	for _, query := range []string{"", "metric=temperature", "metric=tds&start=bad", "metric=tds&start=2024-06-30T00:00:00Z&end=2024-06-01T00:00:00Z"} {
+		if code, _ := doRequest(t, r, "/sensors/heatmap?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetQualityBreakdown_GroupsByModeAndDay(t *testing.T) {
+	excellent := models.SensorReading{Ph: 7.5, Turbidity: 0.5, TDS: 100}
+	good := models.SensorReading{Ph: 7.5, Turbidity: 2.0, TDS: 100}
+	danger := models.SensorReading{Ph: 6.0, Turbidity: 0.5, TDS: 100}
+	fixture := []struct {
+		day     int
+		mode    models.FilterMode
+		reading models.SensorReading
+	}{
+		{0, models.FilterModeDrinking, excellent},
+		{0, models.FilterModeDrinking, excellent},
+		{0, models.FilterModeDrinking, good},
+		{0, models.FilterModeHousehold, danger},
+		{1, models.FilterModeDrinking, danger},
+		{1, models.FilterModeHousehold, good},
+		{1, models.FilterModeHousehold, excellent},
+		{1, models.FilterModeHousehold, danger},
+	}
+
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)
+	for i, f := range fixture {
+		reading := f.reading
+		reading.DeviceID = "stm32_post"
+		reading.FilterMode = f.mode
+		reading.Timestamp = base.AddDate(0, 0, f.day).Add(time.Duration(i) * time.Minute)
+		s.AddSensorReading(reading)
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/quality/breakdown", handlers.GetQualityBreakdown)
+	const window = "&start=2024-06-01T00:00:00Z&end=2024-06-10T00:00:00Z"
+
+	check := func(path string, want map[string][4]float64) {
+		t.Helper()
+		code, body := doRequest(t, r, path)
+		if code != http.StatusOK || body["count"] != float64(len(want)) {
+			t.Fatalf("%s: expected 200 with %d groups, got %d: %v", path, len(want), code, body)
+		}
+		for _, item := range body["data"].([]interface{}) {
+			group := item.(map[string]interface{})
+			counts := group["counts"].(map[string]interface{})
+			expected, ok := want[group["group"].(string)]
+			got := [4]float64{group["total"].(float64), counts["Excellent"].(float64), counts["Good"].(float64), counts["Danger"].(float64)}
+			if !ok || got != expected {
+				t.Errorf("%s: group %v expected total/excellent/good/danger %v, got %v", path, group["group"], expected, got)
+			}
+		}
+	}
+
+	check("/sensors/quality/breakdown?group_by=mode"+window, map[string][4]float64{
+		"drinking_water":  {4, 2, 1, 1},
+		"household_water": {4, 1, 1, 2},
+	})
+	check("/sensors/quality/breakdown?group_by=day"+window, map[string][4]float64{
+		"2024-06-03": {4, 2, 1, 1},
+		"2024-06-04": {4, 1, 1, 2},
+	})
+
+	// Days come out in order, and the range limits the readings counted
+	_, body := doRequest(t, r, "/sensors/quality/breakdown?group_by=day"+window)
+	if first := body["data"].([]interface{})[0].(map[string]interface{}); first["group"] != "2024-06-03" {
+		t.Errorf("Expected the earliest day first, got %v", first["group"])
+	}
+	check("/sensors/quality/breakdown?start=2024-06-04T00:00:00Z&end=2024-06-05T00:00:00Z", map[string][4]float64{
+		"drinking_water":  {1, 0, 0, 1},
+		"household_water": {3, 1, 1, 1},
+	})
+
+	if code, _ := doRequest(t, r, "/sensors/quality/breakdown?group_by=week"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown grouping, got %d", code)
+	}
+}
+
+func TestGetMetricAggregates_HourlyAndDailyBuckets(t *testing.T) {
+	s := store.NewStore(500)
+	base := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	// Two days of readings every 20 minutes; pH rises by 0.1 each hour of the day
+	for day := 0; day < 2; day++ {
+		for minute := 0; minute < 24*60; minute += 20 {
+			ts := base.AddDate(0, 0, day).Add(time.Duration(minute) * time.Minute)
+			s.AddSensorReading(models.SensorReading{
+				DeviceID:   "stm32_pre",
+				Timestamp:  ts,
+				FilterMode: models.FilterModeDrinking,
+				Ph:         6.0 + float64(ts.Hour())/10 + float64(minute%60)/200,
+			})
+		}
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/aggregate", handlers.GetMetricAggregates)
+
+	code, body := doRequest(t, r, "/sensors/aggregate?interval=hour&metric=ph&start=2024-06-03T00:00:00Z&end=2024-06-04T23:59:59Z")
+	if code != http.StatusOK || body["count"] != 48.0 {
+		t.Fatalf("Expected 200 with 48 hourly buckets, got %d: %v", code, body["count"])
+	}
+	buckets := body["data"].([]interface{})
+	third := buckets[2].(map[string]interface{})
+	if third["bucket_start"] != "2024-06-03T02:00:00Z" || third["count"] != 3.0 {
+		t.Errorf("Expected the 02:00 bucket with 3 readings, got %v", third)
+	}
+	if third["min"] != 6.2 || third["max"] != 6.4 || math.Abs(third["avg"].(float64)-6.3) > 1e-9 {
+		t.Errorf("Expected min 6.2, max 6.4 and avg 6.3, got %v", third)
+	}
+
+	code, body = doRequest(t, r, "/sensors/aggregate?interval=day&metric=ph&start=2024-06-03T00:00:00Z&end=2024-06-04T23:59:59Z")
+	if code != http.StatusOK || body["count"] != 2.0 {
+		t.Fatalf("Expected 200 with 2 daily buckets, got %d: %v", code, body["count"])
+	}
+	days := body["data"].([]interface{})
+	first := days[0].(map[string]interface{})
+	second := days[1].(map[string]interface{})
+	if first["bucket_start"] != "2024-06-03T00:00:00Z" || second["bucket_start"] != "2024-06-04T00:00:00Z" {
+		t.Errorf("Expected daily buckets oldest first, got %v and %v", first["bucket_start"], second["bucket_start"])
+	}
+	if first["count"] != 72.0 || first["min"] != 6.0 || first["max"] != 8.5 {
+		t.Errorf("Expected 72 readings from 6.0 to 8.5 on the first day, got %v", first)
+	}
+
+	for _, query := range []string{
+		"metric=ph&interval=week",
+		"metric=temperature&interval=day",
+		"metric=ph&interval=day&start=2024-06-04T00:00:00Z&end=2024-06-03T00:00:00Z",
+	} {
+		if code, _ := doRequest(t, r, "/sensors/aggregate?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetMetricHistogram_BinBoundariesAndCounts(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	for i, ph := range []float64{6.0, 6.2, 6.5, 7.0, 7.9, 8.0} {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   "stm32_pre",
+			Timestamp:  base.Add(time.Duration(i) * time.Hour),
+			FilterMode: models.FilterModeDrinking,
+			Ph:         ph,
+		})
+	}
+	// Outside the requested range
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: base.AddDate(0, 1, 0), Ph: 14})
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/histogram", handlers.GetMetricHistogram)
+
+	code, body := doRequest(t, r, "/sensors/histogram?metric=ph&bins=4&start=2024-06-01T00:00:00Z&end=2024-06-30T00:00:00Z")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	data := body["data"].(map[string]interface{})
+	if data["min"] != 6.0 || data["max"] != 8.0 || data["total"] != 6.0 {
+		t.Errorf("Expected min 6, max 8, total 6, got %v/%v/%v", data["min"], data["max"], data["total"])
+	}
+
+	// Width 0.5: [6, 6.5) [6.5, 7) [7, 7.5) [7.5, 8], with the maximum in the last bin
+	expected := []struct{ lower, upper, count float64 }{
+		{6.0, 6.5, 2},
+		{6.5, 7.0, 1},
+		{7.0, 7.5, 1},
+		{7.5, 8.0, 2},
+	}
+	bins := data["bins"].([]interface{})
+	if len(bins) != len(expected) {
+		t.Fatalf("Expected %d bins, got %d", len(expected), len(bins))
+	}
+	for i, want := range expected {
+		bin := bins[i].(map[string]interface{})
+		if bin["lower"] != want.lower || bin["upper"] != want.upper || bin["count"] != want.count {
+			t.Errorf("Bin %d: expected [%.1f, %.1f) x%.0f, got %v", i, want.lower, want.upper, want.count, bin)
+		}
+	}
+
+	for _, query := range []string{"", "metric=temperature", "metric=ph&bins=0", "metric=ph&bins=101", "metric=ph&bins=x"} {
+		if code, _ := doRequest(t, r, "/sensors/histogram?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetSafeToDrink(t *testing.T) {
+	tests := []struct {
+		name       string
+		reading    *models.SensorReading
+		wantSafe   bool
+		wantStatus string
+		wantReason string
+	}{
+		{
+			name:       "safe",
+			reading:    &models.SensorReading{Timestamp: time.Now(), Ph: 7.5, Turbidity: 0.5, TDS: 150},
+			wantSafe:   true,
+			wantStatus: "safe",
+		},
+		{
+			name:       "unsafe pH",
+			reading:    &models.SensorReading{Timestamp: time.Now(), Ph: 6.2, Turbidity: 0.5, TDS: 150},
+			wantStatus: "unsafe",
+			wantReason: "pH 6.20",
+		},
+		{
+			name:       "stale data",
+			reading:    &models.SensorReading{Timestamp: time.Now().Add(-time.Hour), Ph: 7.5, Turbidity: 0.5, TDS: 150},
+			wantStatus: "unknown",
+			wantReason: "old",
+		},
+		{
+			name:       "no data",
+			wantStatus: "unknown",
+			wantReason: "no drinking water reading",
+		},
+	}
+
+	for _, tt := range tests {
+		s := store.NewStore(10)
+		if tt.reading != nil {
+			tt.reading.DeviceID = "stm32_post"
+			tt.reading.FilterMode = models.FilterModeDrinking
+			s.AddSensorReading(*tt.reading)
+		}
+		r := chi.NewRouter()
+		r.Get("/sensors/safe-to-drink", NewHandlers(s, nil, nil, nil).GetSafeToDrink)
+
+		code, body := doRequest(t, r, "/sensors/safe-to-drink")
+		if code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", tt.name, code)
+			continue
+		}
+		data := body["data"].(map[string]interface{})
+		if data["safe"] != tt.wantSafe || data["status"] != tt.wantStatus {
+			t.Errorf("%s: expected safe=%v status=%s, got safe=%v status=%v", tt.name, tt.wantSafe, tt.wantStatus, data["safe"], data["status"])
+		}
+		reasons := data["reasons"].([]interface{})
+		if tt.wantReason == "" && len(reasons) != 0 {
+			t.Errorf("%s: expected no reasons, got %v", tt.name, reasons)
+		}
+		if tt.wantReason != "" && (len(reasons) == 0 || !strings.Contains(reasons[0].(string), tt.wantReason)) {
+			t.Errorf("%s: expected a reason containing %q, got %v", tt.name, tt.wantReason, reasons)
+		}
+	}
+}
+
+func TestGetSafeToDrink_StalenessPolicy(t *testing.T) {
+	s := store.NewStore(10)
+	s.AddSensorReading(models.SensorReading{
+		DeviceID: "stm32_post", Timestamp: time.Now().Add(-time.Hour), FilterMode: models.FilterModeDrinking,
+		Ph: 7.5, Turbidity: 0.5, TDS: 150,
+	})
+
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		policy    models.StalenessPolicy
+		wantSafe  bool
+		wantStale bool
+	}{
+		{"fail-safe", 10 * time.Minute, models.StalenessFailSafe, false, true},
+		{"fail-open", 10 * time.Minute, models.StalenessFailOpen, true, true},
+		{"longer window", 2 * time.Hour, models.StalenessFailSafe, true, false},
+	}
+
+	for _, tt := range tests {
+		handlers := NewHandlers(s, nil, nil, nil)
+		handlers.safetyMaxAge = tt.maxAge
+		handlers.stalenessPolicy = tt.policy
+		r := chi.NewRouter()
+		r.Get("/sensors/safe-to-drink", handlers.GetSafeToDrink)
+
+		_, body := doRequest(t, r, "/sensors/safe-to-drink")
+		data := body["data"].(map[string]interface{})
+		if data["safe"] != tt.wantSafe || data["stale"] != tt.wantStale {
+			t.Errorf("%s: expected safe=%v stale=%v, got %v", tt.name, tt.wantSafe, tt.wantStale, data)
+		}
+		if _, hasCaution := data["caution"]; hasCaution != tt.wantStale {
+			t.Errorf("%s: expected a caution only for stale data, got %v", tt.name, data["caution"])
+		}
+	}
+}
+
+func TestGetAvailableDays_OnlyDaysWithData(t *testing.T) {
+	s := store.NewStore(100)
+	for _, at := range []string{
+		"2024-06-01T10:00:00Z",
+		"2024-06-01T23:30:00Z", // June 2nd in Jakarta (UTC+7)
+		"2024-06-04T12:00:00Z",
+		"2024-06-04T13:00:00Z",
+		"2024-06-20T12:00:00Z", // Outside the range
+	} {
+		timestamp, _ := time.Parse(time.RFC3339, at)
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: timestamp, FilterMode: models.FilterModeDrinking})
+	}
+	r := chi.NewRouter()
+	r.Get("/sensors/available-days", NewHandlers(s, nil, nil, nil).GetAvailableDays)
+
+	const rangeQuery = "start=2024-06-01T00:00:00Z&end=2024-06-10T00:00:00Z"
+	for tz, want := range map[string]string{
+		"":             "[2024-06-01 2024-06-04]",
+		"Asia/Jakarta": "[2024-06-01 2024-06-02 2024-06-04]",
+	} {
+		code, body := doRequest(t, r, "/sensors/available-days?"+rangeQuery+"&tz="+tz)
+		if code != http.StatusOK {
+			t.Fatalf("tz %q: expected 200, got %d: %v", tz, code, body)
+		}
+		data := body["data"].(map[string]interface{})
+		if got := fmt.Sprint(data["days"]); got != want {
+			t.Errorf("tz %q: expected days %s, got %s", tz, want, got)
+		}
+	}
+
+	for _, query := range []string{
+		rangeQuery + "&tz=Mars/Olympus",
+		rangeQuery + "&tz=Local",
+		"start=2024-06-10T00:00:00Z&end=2024-06-01T00:00:00Z",
+		"start=2023-01-01T00:00:00Z&end=2024-06-01T00:00:00Z",
+		"start=yesterday",
+	} {
+		if code, _ := doRequest(t, r, "/sensors/available-days?"+query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetFilterStatus_UntrackedModeReturnsZeroedTracking(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/commands/filter", NewHandlers(store.NewStore(10), nil, nil, nil).GetFilterStatus)
+
+	code, body := doRequest(t, r, "/commands/filter")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	tracking, ok := body["data"].(map[string]interface{})["filter_mode_tracking"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected filter_mode_tracking to be an object, got %v", body["data"])
+	}
+	if tracking["started_at"] != nil || tracking["duration_seconds"] != 0.0 || tracking["total_flow_liters"] != 0.0 {
+		t.Errorf("Expected zeroed tracking, got %v", tracking)
+	}
+
+	statistics := tracking["statistics"].(map[string]interface{})
+	for _, period := range []string{"today", "this_week", "this_month"} {
+		stats, ok := statistics[period].(map[string]interface{})
+		if !ok || stats["total_liters"] != 0.0 {
+			t.Errorf("Expected zeroed %s statistics, got %v", period, statistics[period])
+		}
+	}
+}
+
+func TestSetFilterMode_ModeChangeCooldown(t *testing.T) {
+	handlers := NewHandlers(store.NewStore(10), nil, nil, nil)
+	handlers.modeCooldown = store.NewModeChangeCooldown(100 * time.Millisecond)
+	r := chi.NewRouter()
+	r.Post("/commands/filter", handlers.SetFilterMode)
+
+	setMode := func(mode models.FilterMode) *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"mode":"` + string(mode) + `"}`)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/commands/filter", body))
+		return rec
+	}
+
+	if rec := setMode(models.FilterModeHousehold); rec.Code != http.StatusOK {
+		t.Fatalf("Expected first change to succeed, got %d", rec.Code)
+	}
+
+	rec := setMode(models.FilterModeDrinking)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 within the cooldown, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+	var response APIResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := response.Data.(map[string]interface{})["retry_after_seconds"]; !ok {
+		t.Errorf("Expected retry_after_seconds in response data, got %v", response.Data)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if rec := setMode(models.FilterModeDrinking); rec.Code != http.StatusOK {
+		t.Errorf("Expected change after the cooldown to succeed, got %d", rec.Code)
+	}
+}
+
+func TestSetFilterMode_TargetVolumeBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		target float64 // Expected process target volume (0 = no process)
+	}{
+		{"zero", `{"mode":"drinking_water","start_filtration":true,"target_volume":0}`, http.StatusBadRequest, 0},
+		{"negative", `{"mode":"drinking_water","start_filtration":true,"target_volume":-5}`, http.StatusBadRequest, 0},
+		{"huge", `{"mode":"drinking_water","start_filtration":true,"target_volume":1e9}`, http.StatusBadRequest, 0},
+		{"below configured min", `{"mode":"drinking_water","target_volume":1}`, http.StatusBadRequest, 0},
+		{"in range", `{"mode":"drinking_water","start_filtration":true,"target_volume":20}`, http.StatusOK, 20},
+		{"at configured max", `{"mode":"household_water","start_filtration":true,"target_volume":50}`, http.StatusOK, 50},
+		{"default", `{"mode":"drinking_water","start_filtration":true}`, http.StatusOK, models.DefaultTargetVolume},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataStore := store.NewStore(10)
+			handlers := NewHandlers(dataStore, nil, nil, nil)
+			handlers.targetVolumes = models.TargetVolumeBounds{Min: 2, Max: 50}
+			r := chi.NewRouter()
+			r.Post("/commands/filter", handlers.SetFilterMode)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/commands/filter", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d (%s)", tt.status, rec.Code, rec.Body.String())
+			}
+
+			process, exists := dataStore.GetFiltrationProcess()
+			if tt.target == 0 {
+				if exists {
+					t.Errorf("Expected no filtration process, got %+v", process)
+				}
+			} else if !exists || process.TargetVolume != tt.target {
+				t.Errorf("Expected a process targeting %gL, got %+v", tt.target, process)
+			}
+		})
+	}
+}
+
+func TestAllReadingsLimit_SetsTruncatedFlag(t *testing.T) {
+	dataStore := store.NewStore(100)
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		dataStore.AddSensorReading(models.SensorReading{
+			DeviceID:   "stm32_pre",
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			FilterMode: models.FilterModeDrinking,
+			Ph:         7.0,
+		})
+	}
+
+	handlers := NewHandlers(dataStore, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/all", handlers.GetAllSensorData)
+	r.Get("/sensors/all/simple", handlers.GetAllSensorDataSimple)
+	r.Get("/sensors/stats", handlers.GetSensorDataStats)
+
+	handlers.allReadingsLimit = 3
+	code, body := doRequest(t, r, "/sensors/all/simple")
+	if code != http.StatusOK {
+		t.Fatalf("/sensors/all/simple: expected 200, got %d", code)
+	}
+	if body["truncated"] != true {
+		t.Errorf("/sensors/all/simple: expected truncated flag when over the ceiling, got %v", body["truncated"])
+	}
+	if body["count"] != 3.0 {
+		t.Errorf("Expected 3 readings at the ceiling, got %v", body["count"])
+	}
+
+	// Stats are aggregated by the store and cover every reading
+	_, body = doRequest(t, r, "/sensors/stats")
+	if _, ok := body["truncated"]; ok {
+		t.Errorf("/sensors/stats: expected no truncated flag, got %v", body["truncated"])
+	}
+	if total := body["data"].(map[string]interface{})["total_readings"]; total != 5.0 {
+		t.Errorf("/sensors/stats: expected all 5 readings, got %v", total)
+	}
+
+	// The paginated endpoint pages in the store and is not bound by the ceiling
+	_, body = doRequest(t, r, "/sensors/all")
+	if _, ok := body["truncated"]; ok {
+		t.Errorf("/sensors/all: expected no truncated flag, got %v", body["truncated"])
+	}
+	pagination := body["data"].(map[string]interface{})["pagination"].(map[string]interface{})
+	if pagination["total_records"] != 5.0 {
+		t.Errorf("/sensors/all: expected 5 total records, got %v", pagination["total_records"])
+	}
+
+	for _, limit := range []int{5, 0} {
+		handlers.allReadingsLimit = limit
+		_, body := doRequest(t, r, "/sensors/all/simple")
+		if _, ok := body["truncated"]; ok {
+			t.Errorf("Limit %d: expected no truncated flag, got %v", limit, body["truncated"])
+		}
+		if body["count"] != 5.0 {
+			t.Errorf("Limit %d: expected all 5 readings, got %v", limit, body["count"])
+		}
+	}
+}
+
+func TestGetAllSensorData_PaginatesFilteredReadings(t *testing.T) {
+	dataStore := store.NewStore(100)
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 12; i++ {
+		mode := models.FilterModeDrinking
+		if i%3 == 0 {
+			mode = models.FilterModeHousehold
+		}
+		dataStore.AddSensorReading(models.SensorReading{
+			DeviceID:   []string{"stm32_pre", "stm32_post"}[i%2],
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			FilterMode: mode,
+		})
+	}
+
+	handlers := NewHandlers(dataStore, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/all", handlers.GetAllSensorData)
+
+	// stm32_post drinking water: i = 1, 5, 7, 11
+	code, body := doRequest(t, r, "/sensors/all?device_id=stm32_post&filter_mode=drinking_water&limit=3&offset=3&sort=asc")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	data := body["data"].(map[string]interface{})
+	pagination := data["pagination"].(map[string]interface{})
+	if pagination["total_records"] != 4.0 || pagination["total_pages"] != 2.0 {
+		t.Errorf("Expected 4 records over 2 pages, got %v", pagination)
+	}
+	if pagination["has_next"] != false || pagination["has_previous"] != true {
+		t.Errorf("Expected only a previous page, got %v", pagination)
+	}
+	page := data["data"].([]interface{})
+	if len(page) != 1 {
+		t.Fatalf("Expected 1 reading on the last page, got %d", len(page))
+	}
+	expected := base.Add(11 * time.Minute)
+	if ts, _ := time.Parse(time.RFC3339Nano, page[0].(map[string]interface{})["timestamp"].(string)); !ts.Equal(expected) {
+		t.Errorf("Expected the newest matching reading last, got %v", ts)
+	}
+
+	if code, _ := doRequest(t, r, "/sensors/all?filter_mode=bogus"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid filter mode, got %d", code)
+	}
+}
+
+func TestAddSensorData_ClockSkewPolicy(t *testing.T) {
+	skewed := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	body := `{"device_id":"stm32_post","filter_mode":"drinking_water","timestamp":"` + skewed + `","flow":2,"ph":7.2,"turbidity":1,"tds":50}`
+
+	for _, policy := range []store.ClockSkewPolicy{store.ClockSkewReject, store.ClockSkewClamp, store.ClockSkewRecord} {
+		s := store.NewStore(100)
+		h := NewHandlers(s, nil, nil, nil)
+		h.clockSkew = store.NewClockSkewCorrector(5*time.Minute, policy)
+
+		rec := httptest.NewRecorder()
+		h.AddSensorData(rec, httptest.NewRequest(http.MethodPost, "/sensors/data", strings.NewReader(body)))
+
+		latest, exists := s.GetLatestReading()
+		if policy == store.ClockSkewReject {
+			if rec.Code != http.StatusBadRequest || exists {
+				t.Errorf("reject: expected 400 and nothing stored, got %d (stored=%v)", rec.Code, exists)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK || !exists {
+			t.Fatalf("%s: expected the reading to be stored, got %d: %s", policy, rec.Code, rec.Body.String())
+		}
+		if latest.ServerReceivedAt == nil || time.Since(*latest.ServerReceivedAt) > time.Minute {
+			t.Errorf("%s: expected a recent server receive time, got %v", policy, latest.ServerReceivedAt)
+		}
+		clamped := time.Since(latest.Timestamp) < time.Minute
+		if clamped != (policy == store.ClockSkewClamp) {
+			t.Errorf("%s: unexpected stored timestamp %s", policy, latest.Timestamp)
+		}
+	}
+}
+
+func TestValidateSensorData_DryRun(t *testing.T) {
+	s := store.NewStore(100)
+	h := NewHandlers(s, nil, nil, nil)
+
+	validate := func(body string) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ValidateSensorData(rec, httptest.NewRequest(http.MethodPost, "/sensors/validate", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response["data"].(map[string]interface{})
+	}
+
+	data := validate(`{"device_id":"stm32_post","filter_mode":"drinking_water","flow":2,"ph":7.2,"turbidity":0.5,"tds":50}`)
+	if data["valid"] != true {
+		t.Errorf("Expected a valid reading, got %v", data["fields"])
+	}
+	quality, ok := data["quality"].(map[string]interface{})
+	if !ok || quality["overall_quality"] != "Excellent" {
+		t.Errorf("Expected the computed water quality, got %v", data["quality"])
+	}
+
+	data = validate(`{"device_id":"esp32","filter_mode":"drinking_water","flow":-1,"ph":15,"turbidity":0.5,"tds":50}`)
+	if data["valid"] != false || data["quality"] != nil {
+		t.Errorf("Expected an invalid reading without quality, got valid=%v quality=%v", data["valid"], data["quality"])
+	}
+	failed := map[string]bool{}
+	for _, field := range data["fields"].([]interface{}) {
+		result := field.(map[string]interface{})
+		if result["valid"] == false {
+			if result["error"] == "" {
+				t.Errorf("Expected an error message for %v", result["field"])
+			}
+			failed[result["field"].(string)] = true
+		}
+	}
+	if len(failed) != 3 || !failed["device_id"] || !failed["flow"] || !failed["ph"] {
+		t.Errorf("Expected device_id, flow and ph to fail, got %v", failed)
+	}
+
+	if s.GetReadingCount() != 0 {
+		t.Errorf("Expected nothing stored by a dry run, got %d readings", s.GetReadingCount())
+	}
+}
+
+func TestGetFilterModeCounts_IncludesNewModes(t *testing.T) {
+	s := store.NewStore(100)
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/modes", handlers.GetFilterModeCounts)
+
+	code, body := doRequest(t, r, "/sensors/modes")
+	if code != http.StatusOK || body["count"] != 0.0 {
+		t.Fatalf("Expected 200 with no modes for an empty store, got %d: %v", code, body)
+	}
+
+	base := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	modes := []models.FilterMode{
+		models.FilterModeDrinking, models.FilterModeDrinking, models.FilterModeDrinking,
+		models.FilterModeHousehold,
+		"irrigation_water", "irrigation_water",
+	}
+	for i, mode := range modes {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   "stm32_post",
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			FilterMode: mode,
+		})
+	}
+
+	code, body = doRequest(t, r, "/sensors/modes")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	expected := []struct {
+		mode   string
+		count  float64
+		latest time.Time
+	}{
+		{"drinking_water", 3, base.Add(2 * time.Minute)},
+		{"household_water", 1, base.Add(3 * time.Minute)},
+		{"irrigation_water", 2, base.Add(5 * time.Minute)},
+	}
+	data := body["data"].([]interface{})
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d modes, got %d: %v", len(expected), len(data), data)
+	}
+	for i, want := range expected {
+		got := data[i].(map[string]interface{})
+		if got["mode"] != want.mode || got["reading_count"] != want.count || got["latest_timestamp"] != want.latest.Format(time.RFC3339) {
+			t.Errorf("Mode %d: expected %s x%v latest %s, got %v", i, want.mode, want.count, want.latest.Format(time.RFC3339), got)
+		}
+	}
+}
+
+func TestGetDevicesOverview_SortedByAttention(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now()
+	s.AddSensorReading(models.SensorReading{
+		DeviceID: "stm32_pre", Timestamp: now.Add(-time.Minute), FilterMode: models.FilterModeDrinking,
+		Flow: 2.0, Ph: 6.5, Turbidity: 10.0, TDS: 300,
+	})
+	s.AddSensorReading(models.SensorReading{
+		DeviceID: "stm32_post", Timestamp: now.Add(-time.Minute), FilterMode: models.FilterModeDrinking,
+		Flow: 2.0, Ph: 7.2, Turbidity: 1.0, TDS: 50,
+	})
+	s.AddSensorReading(models.SensorReading{
+		DeviceID: "stm32_main", Timestamp: now.Add(-2 * time.Hour), FilterMode: models.FilterModeHousehold,
+		Flow: 1.0, Ph: 7.2, Turbidity: 1.0, TDS: 50,
+	})
+	if err := s.SaveFilterHealth(&models.FilterHealth{
+		DeviceID: "stm32_post", HealthScore: 60, PredictedDaysRemaining: 40, MaintenanceRequired: true, TotalFlowProcessed: 1200,
+	}); err != nil {
+		t.Fatalf("Failed to save filter health: %v", err)
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/devices/overview", handlers.GetDevicesOverview)
+
+	code, body := doRequest(t, r, "/devices/overview")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	expected := []struct {
+		deviceID string
+		level    string
+		online   bool
+	}{
+		{"stm32_main", models.AttentionCritical, false},
+		{"stm32_post", models.AttentionWarning, true},
+		{"stm32_pre", models.AttentionOK, true}, // Poor raw water is expected before filtration
+	}
+	data := body["data"].([]interface{})
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d devices, got %d: %v", len(expected), len(data), data)
+	}
+	for i, want := range expected {
+		got := data[i].(map[string]interface{})
+		if got["device_id"] != want.deviceID || got["attention_level"] != want.level || got["online"] != want.online {
+			t.Errorf("Position %d: expected %s (%s, online=%v), got %v", i, want.deviceID, want.level, want.online, got)
+		}
+	}
+
+	post := data[1].(map[string]interface{})
+	health, _ := post["filter_health"].(map[string]interface{})
+	if health == nil || health["total_flow_processed"] != 1200.0 {
+		t.Errorf("Expected the post device's filter health, got %v", post["filter_health"])
+	}
+	if quality, _ := post["latest_quality"].(map[string]interface{}); quality == nil || quality["overall_quality"] == "" {
+		t.Errorf("Expected the post device's latest quality, got %v", post["latest_quality"])
+	}
+	if main := data[0].(map[string]interface{}); main["filter_health"] != nil || main["filter_mode"] != "household_water" {
+		t.Errorf("Expected the offline device's last mode and no filter health, got %v", main)
+	}
+}
+
+func TestGetWaterQualityScore_MinimalCacheableBody(t *testing.T) {
+	s := store.NewStore(100)
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/score", handlers.GetWaterQualityScore)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/sensors/score", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(""); strings.TrimSpace(rec.Body.String()) != `{"wqi":null,"status":"Unknown"}` {
+		t.Errorf("Expected an unknown score without readings, got %s", rec.Body.String())
+	}
+
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 7, Turbidity: 0, TDS: 0})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeHousehold, Ph: 4, Turbidity: 20, TDS: 2000})
+
+	rec := get("")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"wqi":100,"status":"Excellent"}` {
+		t.Errorf("Expected only the drinking water score and label, got %s", body)
+	}
+	if cache := rec.Header().Get("Cache-Control"); !strings.Contains(cache, "private") || !strings.Contains(cache, "max-age=60") {
+		t.Errorf("Expected a private max-age=60 Cache-Control header, got %q", cache)
+	}
+	if rec.Header().Get("Last-Modified") == "" {
+		t.Error("Expected a Last-Modified header")
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+	if rec := get(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Expected 304 with no body for a matching ETag, got %d (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetWaterQualityScore_StaleReading(t *testing.T) {
+	s := store.NewStore(100)
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now().Add(-3 * time.Hour), FilterMode: models.FilterModeDrinking, Ph: 7})
+
+	get := func(policy models.StalenessPolicy) *httptest.ResponseRecorder {
+		handlers := NewHandlers(s, nil, nil, nil)
+		handlers.safetyMaxAge = time.Hour
+		handlers.stalenessPolicy = policy
+		rec := httptest.NewRecorder()
+		handlers.GetWaterQualityScore(rec, httptest.NewRequest(http.MethodGet, "/sensors/score", nil))
+		return rec
+	}
+
+	failSafe := get(models.StalenessFailSafe)
+	if body := strings.TrimSpace(failSafe.Body.String()); body != `{"wqi":null,"status":"Unknown","stale":true}` {
+		t.Errorf("Expected no score for a stale reading under fail_safe, got %s", body)
+	}
+	failOpen := get(models.StalenessFailOpen)
+	if body := strings.TrimSpace(failOpen.Body.String()); body != `{"wqi":100,"status":"Excellent","stale":true}` {
+		t.Errorf("Expected the stale reading's score flagged under fail_open, got %s", body)
+	}
+	if failSafe.Header().Get("ETag") == failOpen.Header().Get("ETag") {
+		t.Error("Expected the ETag to change with the served score")
+	}
+}
+
+func TestGetWaterQualityIndex_LatestPerDevice(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now()
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: now, FilterMode: models.FilterModeDrinking, Ph: 5.5, Turbidity: 12, TDS: 1200})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: now.Add(-time.Minute), FilterMode: models.FilterModeDrinking, Ph: 8.5, Turbidity: 4, TDS: 400})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: now, FilterMode: models.FilterModeDrinking, Ph: 7.3, Turbidity: 1, TDS: 100})
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/wqi", handlers.GetWaterQualityIndex)
+
+	code, body := doRequest(t, r, "/sensors/wqi")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	data := body["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("Expected 2 devices, got %d", len(data))
+	}
+	post := data[0].(map[string]interface{})
+	wqi := post["wqi"].(map[string]interface{})
+	if post["device_id"] != "stm32_post" || wqi["score"] != 90.0 || wqi["label"] != "Excellent" {
+		t.Errorf("Expected the latest post reading to score 90, got %v", post)
+	}
+
+	code, body = doRequest(t, r, "/sensors/wqi?device_id=stm32_pre")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if wqi := body["data"].(map[string]interface{})["wqi"].(map[string]interface{}); wqi["score"] != 10.0 || wqi["label"] != "Danger" {
+		t.Errorf("Expected the raw water to score 10, got %v", wqi)
+	}
+
+	if code, _ := doRequest(t, r, "/sensors/wqi?device_id=unknown"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a device without readings, got %d", code)
+	}
+}
+
+// shuffledReadings returns n readings one second apart in a scrambled order
+func shuffledReadings(n int) []models.SensorReading {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	readings := make([]models.SensorReading, n)
+	for i := range readings {
+		// 7919 is prime, so i*7919 mod n visits every offset once when n is not a multiple of it
+		readings[i] = models.SensorReading{DeviceID: "stm32_post", Timestamp: base.Add(time.Duration(i*7919%n) * time.Second)}
+	}
+	return readings
+}
+
+func TestSortReadingsByTimestamp_BothOrders(t *testing.T) {
+	readings := shuffledReadings(500)
+
+	sortReadingsByTimestamp(readings, true)
+	for i := 1; i < len(readings); i++ {
+		if readings[i].Timestamp.Before(readings[i-1].Timestamp) {
+			t.Fatalf("asc: reading %d is older than reading %d", i, i-1)
+		}
+	}
+
+	sortReadingsByTimestamp(readings, false)
+	for i := 1; i < len(readings); i++ {
+		if readings[i].Timestamp.After(readings[i-1].Timestamp) {
+			t.Fatalf("desc: reading %d is newer than reading %d", i, i-1)
+		}
+	}
+}
+
+func BenchmarkSortReadingsByTimestamp_10k(b *testing.B) {
+	original := shuffledReadings(10000)
+	readings := make([]models.SensorReading, len(original))
+	for i := 0; i < b.N; i++ {
+		copy(readings, original)
+		sortReadingsByTimestamp(readings, true)
+	}
+}
+
+// BenchmarkNestedLoopSort_10k measures the nested-loop sort the handlers used
+// before, for comparison with BenchmarkSortReadingsByTimestamp_10k
+func BenchmarkNestedLoopSort_10k(b *testing.B) {
+	original := shuffledReadings(10000)
+	readings := make([]models.SensorReading, len(original))
+	for i := 0; i < b.N; i++ {
+		copy(readings, original)
+		for x := 0; x < len(readings)-1; x++ {
+			for y := x + 1; y < len(readings); y++ {
+				if readings[x].Timestamp.After(readings[y].Timestamp) {
+					readings[x], readings[y] = readings[y], readings[x]
+				}
+			}
+		}
+	}
+}
+
+func TestGetDeviceCommands_NewestFirstWithLimit(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold, models.FilterModeDrinking}
+	for i, mode := range modes {
+		s.RecordDeviceCommand(&models.DeviceCommand{
+			DeviceID: "stm32_main",
+			Command:  models.DeviceCommandSetFilterMode,
+			Payload:  string(mode),
+			Status:   models.DeviceCommandSent,
+			IssuedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	s.RecordDeviceCommand(&models.DeviceCommand{DeviceID: "stm32_post", Command: models.DeviceCommandSetFilterMode, Status: models.DeviceCommandFailed})
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/devices/{deviceID}/commands", handlers.GetDeviceCommands)
+
+	code, body := doRequest(t, r, "/devices/stm32_main/commands?limit=2")
+	if code != http.StatusOK || body["count"] != 2.0 {
+		t.Fatalf("Expected 200 with 2 commands, got %d: %v", code, body)
+	}
+	commands := body["data"].([]interface{})
+	first := commands[0].(map[string]interface{})
+	second := commands[1].(map[string]interface{})
+	if first["issued_at"] != base.Add(2*time.Minute).Format(time.RFC3339) || second["payload"] != string(models.FilterModeHousehold) {
+		t.Errorf("Expected the newest commands first, got %v", commands)
+	}
+	if first["status"] != models.DeviceCommandSent {
+		t.Errorf("Expected the delivery status, got %v", first["status"])
+	}
+
+	code, body = doRequest(t, r, "/devices/esp32_unknown/commands")
+	if code != http.StatusOK || body["count"] != 0.0 {
+		t.Errorf("Expected 200 with no commands for an unknown device, got %d: %v", code, body)
+	}
+	if commands, ok := body["data"].([]interface{}); !ok || len(commands) != 0 {
+		t.Errorf("Expected an empty list, got %v", body["data"])
+	}
+}
+
+// TestMaintenanceLog_CreateAndListByDate tests that logged maintenance is listed
+// most recently performed first, even when entries are backdated
+func TestMaintenanceLog_CreateAndListByDate(t *testing.T) {
+	s := store.NewStore(100)
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Post("/devices/{deviceID}/maintenance", handlers.AddMaintenanceEvent)
+	r.Get("/devices/{deviceID}/maintenance", handlers.GetMaintenanceEvents)
+
+	post := func(deviceID, body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/devices/"+deviceID+"/maintenance", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	base := time.Now().Add(-72 * time.Hour).UTC().Truncate(time.Second)
+	events := []struct {
+		at   time.Time
+		kind string
+	}{
+		{base.Add(24 * time.Hour), models.MaintenanceCleaning},
+		{base, models.MaintenanceFilterReplacement}, // Backdated: logged after a later event
+		{base.Add(48 * time.Hour), models.MaintenanceInspection},
+	}
+	for _, event := range events {
+		body := fmt.Sprintf(`{"performed_at":%q,"type":%q,"notes":"routine","performed_by":"technician"}`, event.at.Format(time.RFC3339), event.kind)
+		if code := post("stm32_post", body); code != http.StatusCreated {
+			t.Fatalf("Expected 201 logging %s, got %d", event.kind, code)
+		}
+	}
+	if code := post("stm32_main", `{"type":"cleaning","performed_by":"technician"}`); code != http.StatusCreated {
+		t.Fatalf("Expected 201 with performed_at defaulted to now, got %d", code)
+	}
+
+	if code := post("stm32_post", `{"type":"repainting","performed_by":"technician"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown type, got %d", code)
+	}
+	if code := post("stm32_post", `{"type":"cleaning"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without performed_by, got %d", code)
+	}
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if code := post("stm32_post", `{"type":"cleaning","performed_by":"technician","performed_at":"`+future+`"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a future performed_at, got %d", code)
+	}
+
+	code, body := doRequest(t, r, "/devices/stm32_post/maintenance")
+	if code != http.StatusOK || body["count"] != 3.0 {
+		t.Fatalf("Expected 200 with 3 events, got %d: %v", code, body)
+	}
+	listed := body["data"].([]interface{})
+	wantTypes := []string{models.MaintenanceInspection, models.MaintenanceCleaning, models.MaintenanceFilterReplacement}
+	for i, want := range wantTypes {
+		event := listed[i].(map[string]interface{})
+		if event["type"] != want || event["device_id"] != "stm32_post" || event["performed_by"] != "technician" {
+			t.Errorf("Event %d: expected %s by technician, got %v", i, want, event)
+		}
+	}
+
+	code, body = doRequest(t, r, "/devices/stm32_post/maintenance?limit=1")
+	if code != http.StatusOK || body["count"] != 1.0 {
+		t.Errorf("Expected the limit to apply, got %d: %v", code, body)
+	}
+}
+
+// TestAlertPreferences_DefaultAllOnAndReplace tests that nothing is muted until
+// preferences are set, and that only known metrics and severities can be muted
+func TestAlertPreferences_DefaultAllOnAndReplace(t *testing.T) {
+	handlers := NewHandlers(store.NewStore(100), nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/devices/{deviceID}/alert-prefs", handlers.GetAlertPreferences)
+	r.Put("/devices/{deviceID}/alert-prefs", handlers.SetAlertPreferences)
+
+	put := func(body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/devices/stm32_post/alert-prefs", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	code, body := doRequest(t, r, "/devices/stm32_post/alert-prefs")
+	prefs := body["data"].(map[string]interface{})
+	if code != http.StatusOK || len(prefs["muted_metrics"].([]interface{})) != 0 || len(prefs["muted_severities"].([]interface{})) != 0 {
+		t.Fatalf("Expected every alert on by default, got %d: %v", code, body)
+	}
+
+	if code := put(`{"muted_metrics":["tds","ph"],"muted_severities":["low"]}`); code != http.StatusOK {
+		t.Fatalf("Expected 200 muting tds, ph and low, got %d", code)
+	}
+	if code := put(`{"muted_metrics":["salinity"]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown metric, got %d", code)
+	}
+	if code := put(`{"muted_severities":["minor"]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown severity, got %d", code)
+	}
+
+	_, body = doRequest(t, r, "/devices/stm32_post/alert-prefs")
+	prefs = body["data"].(map[string]interface{})
+	if fmt.Sprint(prefs["muted_metrics"]) != "[tds ph]" || fmt.Sprint(prefs["muted_severities"]) != "[low]" || prefs["device_id"] != "stm32_post" {
+		t.Errorf("Expected the saved preferences, got %v", prefs)
+	}
+
+	// Another device keeps the defaults
+	_, body = doRequest(t, r, "/devices/stm32_pre/alert-prefs")
+	if muted := body["data"].(map[string]interface{})["muted_metrics"].([]interface{}); len(muted) != 0 {
+		t.Errorf("Expected other devices unaffected, got %v", muted)
+	}
+}
+
+func TestDeviceStatus_ListGetAndPatch(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	for i, deviceID := range []string{"stm32_post", "stm32_pre", "stm32_pre"} {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   deviceID,
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			FilterMode: models.FilterModeDrinking,
+		})
+	}
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/devices", handlers.GetDevices)
+	r.Get("/devices/{deviceID}", handlers.GetDevice)
+	r.Patch("/devices/{deviceID}", handlers.UpdateDevice)
+	patch := func(deviceID, body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/devices/"+deviceID, strings.NewReader(body)))
+		var decoded map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &decoded)
+		return rec.Code, decoded
+	}
+
+	code, body := doRequest(t, r, "/devices")
+	if code != http.StatusOK || body["count"] != 2.0 {
+		t.Fatalf("Expected 200 with 2 devices, got %d: %v", code, body)
+	}
+	devices := body["data"].([]interface{})
+	pre := devices[1].(map[string]interface{})
+	if devices[0].(map[string]interface{})["device_id"] != "stm32_post" || pre["device_id"] != "stm32_pre" {
+		t.Errorf("Expected devices ordered by ID, got %v", devices)
+	}
+	if pre["total_readings"] != 2.0 || pre["last_seen"] != base.Add(2*time.Minute).Format(time.RFC3339) ||
+		pre["is_active"] != true || pre["current_filter_mode"] != string(models.FilterModeDrinking) {
+		t.Errorf("Unexpected stm32_pre status: %v", pre)
+	}
+
+	code, body = patch("stm32_pre", `{"name":"  Tank inlet  "}`)
+	if code != http.StatusOK || body["data"].(map[string]interface{})["name"] != "Tank inlet" {
+		t.Fatalf("Expected the trimmed name to be set, got %d: %v", code, body)
+	}
+	code, body = patch("stm32_pre", `{"is_active":false}`)
+	if data := body["data"].(map[string]interface{}); code != http.StatusOK || data["is_active"] != false || data["name"] != "Tank inlet" {
+		t.Fatalf("Expected deactivation to keep the name, got %d: %v", code, body)
+	}
+
+	code, body = doRequest(t, r, "/devices/stm32_pre")
+	if data := body["data"].(map[string]interface{}); code != http.StatusOK || data["is_active"] != false || data["name"] != "Tank inlet" {
+		t.Errorf("Expected the patched profile on GET, got %d: %v", code, body)
+	}
+
+	if code, _ := doRequest(t, r, "/devices/esp32_unknown"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown device, got %d", code)
+	}
+	if code, _ := patch("esp32_unknown", `{"is_active":true}`); code != http.StatusNotFound {
+		t.Errorf("Expected 404 patching an unknown device, got %d", code)
+	}
+	for _, body := range []string{`{}`, `{"name":"` + strings.Repeat("x", 101) + `"}`, `not json`} {
+		if code, _ := patch("stm32_pre", body); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, code)
+		}
+	}
+}
+
+func TestSTM32Command_PollReflectsFilterModeAndLED(t *testing.T) {
+	s := store.NewStore(100)
+	s.SetCurrentFilterMode(models.FilterModeHousehold)
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/sensors/stm32/command", handlers.GetSTM32Command)
+	r.Post("/commands/led", handlers.SetLEDCommand)
+	setLED := func(body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/commands/led", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	code, body := doRequest(t, r, "/sensors/stm32/command")
+	if code != http.StatusOK || body["filter_mode"] != string(models.FilterModeHousehold) || body["led"] != models.LEDCommandOff {
+		t.Fatalf("Expected household mode with the LED off, got %d: %v", code, body)
+	}
+	if _, exists := body["device_id"]; exists {
+		t.Errorf("Expected no device_id without one in the query, got %v", body)
+	}
+	if timestamp, ok := body["timestamp"].(float64); !ok || math.Abs(timestamp-float64(time.Now().Unix())) > 5 {
+		t.Errorf("Expected a current unix timestamp, got %v", body["timestamp"])
+	}
+
+	// The first LED command is replaced before the device polls
+	for _, led := range []string{`{"command":"off"}`, `{"command":"on"}`} {
+		if code := setLED(led); code != http.StatusOK {
+			t.Fatalf("Expected 200 setting the LED, got %d", code)
+		}
+	}
+	commands, err := s.GetDeviceCommands("stm32_main", 10)
+	if err != nil || len(commands) != 2 {
+		t.Fatalf("Expected two logged commands, got %v (err %v)", commands, err)
+	}
+	if commands[0].Command != models.DeviceCommandSetLED || commands[0].Payload != models.LEDCommandOn ||
+		commands[0].Status != models.DeviceCommandQueued {
+		t.Errorf("Expected the command queued until the device polls, got %+v", commands[0])
+	}
+
+	code, body = doRequest(t, r, "/sensors/stm32/command?device_id=stm32_main")
+	if code != http.StatusOK || body["led"] != models.LEDCommandOn || body["device_id"] != "stm32_main" {
+		t.Errorf("Expected the LED on for stm32_main, got %d: %v", code, body)
+	}
+
+	commands, _ = s.GetDeviceCommands("stm32_main", 10)
+	if commands[0].Status != models.DeviceCommandDelivered {
+		t.Errorf("Expected the polled command to be marked delivered, got %+v", commands[0])
+	}
+	if commands[1].Status != models.DeviceCommandFailed || commands[1].Error == "" {
+		t.Errorf("Expected the replaced command to be marked failed, got %+v", commands[1])
+	}
+
+	for _, body := range []string{`{"command":"BLINK"}`, `{}`, `not json`} {
+		if code := setLED(body); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, code)
+		}
+	}
+	if led := s.GetLEDCommand(); led != models.LEDCommandOn {
+		t.Errorf("Expected rejected commands to leave the LED on, got %s", led)
+	}
+}
+
+func TestGetEffectiveDeviceConfig_DeviceOverridesShadowGlobals(t *testing.T) {
+	s := store.NewStore(100)
+	s.SetCurrentFilterMode(models.FilterModeDrinking)
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now(), FilterMode: models.FilterModeHousehold})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking})
+	s.SetDeviceTypeOverride("stm32_pre", models.DeviceTypePostFiltration)
+	s.SaveBaseline(&models.SensorBaseline{DeviceID: "stm32_pre", FilterMode: models.FilterModeDrinking, PhMean: 7.0, SampleSize: 50})
+	s.SaveBaseline(&models.SensorBaseline{DeviceID: "stm32_pre", FilterMode: models.FilterModeHousehold, PhMean: 7.6, SampleSize: 50})
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	handlers.targetVolumes.Max = 200
+	r := chi.NewRouter()
+	r.Get("/devices/{deviceID}/effective-config", handlers.GetEffectiveDeviceConfig)
+
+	setting := func(config map[string]interface{}, path ...string) map[string]interface{} {
+		for _, key := range path {
+			config = config[key].(map[string]interface{})
+		}
+		return config
+	}
+
+	code, body := doRequest(t, r, "/devices/stm32_pre/effective-config")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", code, body)
+	}
+	config := body["data"].(map[string]interface{})
+	if got := setting(config, "device_type"); got["value"] != models.DeviceTypePostFiltration || got["source"] != models.ConfigSourceDevice {
+		t.Errorf("Expected the device type override to shadow inference, got %v", got)
+	}
+	if got := setting(config, "filter_mode"); got["value"] != string(models.FilterModeHousehold) || got["source"] != models.ConfigSourceDevice {
+		t.Errorf("Expected the device's own mode to shadow the system mode, got %v", got)
+	}
+	anomaly := setting(config, "anomaly_detection")
+	if baseline := setting(anomaly, "baseline"); baseline["ph_mean"] != 7.6 || anomaly["active"] != true {
+		t.Errorf("Expected the household baseline to apply, got %v", anomaly)
+	}
+	if got := setting(anomaly, "z_score_threshold"); got["value"] != 3.0 || got["source"] != models.ConfigSourceDefault {
+		t.Errorf("Expected the built-in z-score threshold, got %v", got)
+	}
+	if got := setting(config, "target_volume", "max"); got["value"] != 200.0 || got["source"] != models.ConfigSourceGlobal {
+		t.Errorf("Expected the configured max target volume, got %v", got)
+	}
+	if got := setting(config, "target_volume", "min"); got["value"] != models.DefaultTargetVolumeBounds.Min || got["source"] != models.ConfigSourceDefault {
+		t.Errorf("Expected the default min target volume, got %v", got)
+	}
+
+	_, body = doRequest(t, r, "/devices/stm32_post/effective-config")
+	config = body["data"].(map[string]interface{})
+	if got := setting(config, "device_type"); got["value"] != models.DeviceTypePostFiltration || got["source"] != models.ConfigSourceDefault {
+		t.Errorf("Expected the inferred device type without an override, got %v", got)
+	}
+	if anomaly := setting(config, "anomaly_detection"); anomaly["baseline"] != nil || anomaly["active"] != false {
+		t.Errorf("Expected inactive anomaly detection without a baseline, got %v", anomaly)
+	}
+
+	if code, _ := doRequest(t, r, "/devices/esp32_unknown/effective-config"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown device, got %d", code)
+	}
+}
+
+func TestGetIngestRate_FlagsTooFastAndTooSlowDevices(t *testing.T) {
+	s := store.NewStore(1000)
+	now := time.Now()
+	addEvery := func(deviceID string, count int, interval time.Duration, before time.Time) {
+		for i := 0; i < count; i++ {
+			s.AddSensorReading(models.SensorReading{DeviceID: deviceID, Timestamp: before.Add(-time.Second - time.Duration(i)*interval), FilterMode: models.FilterModeDrinking})
+		}
+	}
+	addEvery("stm32_fast", 30, 20*time.Second, now) // 3/min
+	addEvery("stm32_slow", 2, 4*time.Minute, now)   // 0.2/min
+	addEvery("stm32_main", 10, time.Minute, now)    // 1/min
+	addEvery("stm32_silent", 5, time.Minute, now.Add(-time.Hour))
+
+	handlers := NewHandlers(s, nil, nil, nil)
+	handlers.expectedInterval = time.Minute
+	r := chi.NewRouter()
+	r.Get("/sensors/ingest-rate", handlers.GetIngestRate)
+
+	statuses := func(path string) map[string]map[string]interface{} {
+		code, body := doRequest(t, r, path)
+		if code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %v", path, code, body)
+		}
+		byDevice := make(map[string]map[string]interface{})
+		for _, device := range body["data"].(map[string]interface{})["devices"].([]interface{}) {
+			rate := device.(map[string]interface{})
+			byDevice[rate["device_id"].(string)] = rate
+		}
+		return byDevice
+	}
+
+	rates := statuses("/sensors/ingest-rate?window=10m")
+	expected := map[string]string{
+		"stm32_fast":   models.IngestRateTooFast,
+		"stm32_slow":   models.IngestRateTooSlow,
+		"stm32_main":   models.IngestRateOK,
+		"stm32_silent": models.IngestRateTooSlow,
+	}
+	for deviceID, status := range expected {
+		rate := rates[deviceID]
+		if rate == nil || rate["status"] != status || rate["expected_per_minute"] != 1.0 || rate["expected_source"] != models.ExpectedRateConfig {
+			t.Errorf("Expected %s to be %s against 1/min, got %v", deviceID, status, rate)
+		}
+	}
+	if rates["stm32_fast"]["readings_per_minute"] != 3.0 || rates["stm32_silent"]["readings"] != 0.0 {
+		t.Errorf("Unexpected rates: %v", rates)
+	}
+
+	// Without a configured interval each device is compared to its own history
+	handlers.expectedInterval = 0
+	addEvery("stm32_post", 20, 2*time.Minute, now.Add(-10*time.Minute))
+	addEvery("stm32_post", 15, 40*time.Second, now)
+	rates = statuses("/sensors/ingest-rate")
+	if post := rates["stm32_post"]; post["status"] != models.IngestRateTooFast || post["expected_per_minute"] != 0.5 ||
+		post["expected_source"] != models.ExpectedRateInferred {
+		t.Errorf("Expected stm32_post to be too fast against its inferred 0.5/min, got %v", post)
+	}
+	if fast := rates["stm32_fast"]; fast["status"] != models.IngestRateUnknown || fast["expected_per_minute"] != nil {
+		t.Errorf("Expected an unknown status without enough history, got %v", fast)
+	}
+
+	for _, window := range []string{"soon", "30s", "48h"} {
+		if code, _ := doRequest(t, r, "/sensors/ingest-rate?window="+window); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for window %s, got %d", window, code)
+		}
+	}
+}
+
+// scheduleLookupStore serves one schedule on top of the in-memory store, which
+// doesn't manage schedules
+type scheduleLookupStore struct {
+	*store.Store
+	schedule models.FilterSchedule
+}
+
+func (s *scheduleLookupStore) GetSchedule(id int) (*models.FilterSchedule, error) {
+	if id != s.schedule.ID {
+		return nil, fmt.Errorf("schedule not found")
+	}
+	schedule := s.schedule
+	return &schedule, nil
+}
+
+func TestPreviewSchedule_ListsNextExecutions(t *testing.T) {
+	s := &scheduleLookupStore{
+		Store: store.NewStore(10),
+		schedule: models.FilterSchedule{
+			ID:              7,
+			Name:            "Evening flush",
+			FilterMode:      models.FilterModeHousehold,
+			StartTime:       "18:30:00",
+			DurationMinutes: 15,
+			DaysOfWeek:      []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"},
+			IsActive:        false,
+			Timezone:        "Asia/Jakarta",
+		},
+	}
+	handlers := NewHandlers(s, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/schedules/{id}/preview", handlers.PreviewSchedule)
+
+	code, body := doRequest(t, r, "/schedules/7/preview?count=3")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", code, body)
+	}
+	data := body["data"].(map[string]interface{})
+	executions := data["executions"].([]interface{})
+	if data["is_active"] != false || data["count"] != 3.0 || len(executions) != 3 {
+		t.Fatalf("Expected 3 executions flagged inactive, got %v", data)
+	}
+	var previous time.Time
+	for _, entry := range executions {
+		execution := entry.(map[string]interface{})
+		at, _ := time.Parse(time.RFC3339, execution["executes_at"].(string))
+		local, _ := time.Parse(time.RFC3339, execution["local_time"].(string))
+		if !at.After(previous) || local.Format("15:04") != "18:30" || !local.Equal(at) {
+			t.Errorf("Expected daily executions at 18:30 Jakarta time, got %v", execution)
+		}
+		previous = at
+	}
+
+	if _, body := doRequest(t, r, "/schedules/7/preview?count=500"); body["data"].(map[string]interface{})["count"] != 50.0 {
+		t.Errorf("Expected count to be capped at 50, got %v", body["data"].(map[string]interface{})["count"])
+	}
+	for path, status := range map[string]int{
+		"/schedules/7/preview?count=0":   http.StatusBadRequest,
+		"/schedules/7/preview?count=abc": http.StatusBadRequest,
+		"/schedules/8/preview":           http.StatusNotFound,
+	} {
+		if code, _ := doRequest(t, r, path); code != status {
+			t.Errorf("Expected %d for %s, got %d", status, path, code)
+		}
+	}
+}
+
+func TestGetDeviceMLProfile_ReportsEachSectionOrMarksItEmpty(t *testing.T) {
+	s := store.NewStore(100)
+	router := SetupRoutes(s, ws.NewHub(), nil, nil, nil, nil, RouterOptions{AdminToken: "admin-token"})
+	now := time.Now()
+	for _, deviceID := range []string{"stm32_pre", "stm32_post"} {
+		s.AddSensorReading(models.SensorReading{DeviceID: deviceID, Timestamp: now.Add(-time.Minute), FilterMode: models.FilterModeDrinking, Flow: 1.5, Ph: 7.0, Turbidity: 1.0, TDS: 100})
+	}
+	s.SaveBaseline(&models.SensorBaseline{DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 50, TDSMean: 100})
+	s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", HealthScore: 82, LastCalculated: now})
+	s.SaveAnomaly(&models.AnomalyDetection{DeviceID: "stm32_post", AffectedMetric: "tds", AnomalyType: "spike", DetectedAt: now})
+	s.SaveSensorPrediction(&models.SensorPrediction{DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, PredictedFor: now.Add(time.Hour), PredictedTDS: 101})
+
+	if status, _ := authRequest(t, router, http.MethodGet, "/api/v1/devices/stm32_post/ml-profile", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected the profile to require the admin token, got %d", status)
+	}
+	if status, _ := authRequest(t, router, http.MethodGet, "/api/v1/devices/stm32_unknown/ml-profile", "admin-token", ""); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a device that never reported, got %d", status)
+	}
+
+	profile := func(deviceID string) map[string]interface{} {
+		t.Helper()
+		status, response := authRequest(t, router, http.MethodGet, "/api/v1/devices/"+deviceID+"/ml-profile", "admin-token", "")
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d (%+v)", deviceID, status, response)
+		}
+		return response.Data.(map[string]interface{})
+	}
+
+	full := profile("stm32_post")
+	if len(full["baselines"].([]interface{})) != 1 || full["filter_health"] == nil ||
+		len(full["recent_anomalies"].([]interface{})) != 1 || len(full["recent_predictions"].([]interface{})) != 1 {
+		t.Errorf("Expected every section populated for the post device, got %+v", full)
+	}
+	if empty := full["empty_sections"].([]interface{}); len(empty) != 0 {
+		t.Errorf("Expected no empty sections, got %v", empty)
+	}
+	flow := full["flow_tracking"].(map[string]interface{})
+	if flow["current_filter_mode"] != string(models.FilterModeDrinking) || flow["system"] == nil {
+		t.Errorf("Expected the device's flow tracking with the system tracking, got %+v", flow)
+	}
+
+	bare := profile("stm32_pre")
+	if len(bare["baselines"].([]interface{})) != 0 || bare["filter_health"] != nil ||
+		len(bare["recent_anomalies"].([]interface{})) != 0 || len(bare["recent_predictions"].([]interface{})) != 0 {
+		t.Errorf("Expected empty sections as empty lists or null for the pre device, got %+v", bare)
+	}
+	expected := []interface{}{"baselines", "filter_health", "recent_anomalies", "recent_predictions"}
+	if empty := bare["empty_sections"].([]interface{}); fmt.Sprint(empty) != fmt.Sprint(expected) {
+		t.Errorf("Expected empty sections %v, got %v", expected, empty)
+	}
+}
+
+func TestPruneOldData_RequiresAdminAndEnabledRetention(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now()
+	for _, age := range []time.Duration{100 * 24 * time.Hour, time.Hour} {
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: now.Add(-age), FilterMode: models.FilterModeDrinking, Ph: 7, TDS: 100})
+	}
+
+	disabled := SetupRoutes(s, ws.NewHub(), nil, nil, nil, nil, RouterOptions{AdminToken: "admin-token"})
+	if status, _ := authRequest(t, disabled, http.MethodPost, "/api/v1/admin/prune", "admin-token", ""); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without retention configured, got %d", status)
+	}
+
+	router := SetupRoutes(s, ws.NewHub(), nil, nil, nil, nil, RouterOptions{
+		AdminToken: "admin-token",
+		Retention:  services.NewRetention(s, 90, 0, 0),
+	})
+	if status, _ := authRequest(t, router, http.MethodPost, "/api/v1/admin/prune", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected the prune endpoint to require the admin token, got %d", status)
+	}
+	status, response := authRequest(t, router, http.MethodPost, "/api/v1/admin/prune", "admin-token", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%+v)", status, response)
+	}
+	if data := response.Data.(map[string]interface{}); data["sensor_readings"] != float64(1) {
+		t.Errorf("Expected one reading pruned, got %+v", data)
+	}
+	if count := s.GetReadingCount(); count != 1 {
+		t.Errorf("Expected the recent reading kept, got %d readings", count)
+	}
+}
+
+func TestSetDeviceFilter_RequiresAdminToken(t *testing.T) {
+	router := SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{AdminToken: "admin-token"})
+	body := `{"capacity_liters":1200,"cost":30}`
+
+	if status, _ := authRequest(t, router, http.MethodPut, "/api/v1/devices/stm32_post/filter", "", body); status != http.StatusUnauthorized {
+		t.Errorf("Expected the filter metadata to require the admin token, got %d", status)
+	}
+	if status, response := authRequest(t, router, http.MethodPut, "/api/v1/devices/stm32_post/filter", "admin-token", body); status != http.StatusOK {
+		t.Errorf("Expected the admin to set the filter metadata, got %d (%+v)", status, response)
+	}
+}
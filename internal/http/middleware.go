@@ -0,0 +1,172 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// QueryCountHeader is the debug response header carrying a request's store call count
+const QueryCountHeader = "X-Query-Count"
+
+// QueryCountMiddleware attaches a store call counter to each request and logs a
+// warning when a request makes more than warnThreshold calls (0 disables the
+// warning), which usually points at an N+1 query pattern. When exposeHeader is
+// set, the count is returned in the X-Query-Count response header.
+func QueryCountMiddleware(warnThreshold int, exposeHeader bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, counter := store.WithQueryCounter(r.Context())
+			if exposeHeader {
+				w = &queryCountWriter{ResponseWriter: w, counter: counter}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			if count := counter.Count(); warnThreshold > 0 && count > warnThreshold {
+				log.Printf("⚠️  Warning: %s %s issued %d store queries (threshold %d), possible N+1 pattern",
+					r.Method, r.URL.Path, count, warnThreshold)
+			}
+		})
+	}
+}
+
+// RequireAdminToken guards operator-only endpoints with a static bearer token
+// (Authorization: Bearer <token>). When no token is configured the endpoints
+// are disabled rather than left open.
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeAPIError(w, http.StatusForbidden, "Admin endpoints are disabled: no admin token configured")
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeAPIError(w, http.StatusUnauthorized, "Missing or invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeAPIError writes the standard APIResponse error shape
+func writeAPIError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(APIResponse{Success: false, Error: message})
+}
+
+// StoreBreakerMiddleware answers API requests with 503 while the data store's
+// circuit breaker is open, instead of letting handlers wait on queries that
+// are bound to fail. Health checks are let through so they can report it.
+// Requests let through while the circuit is half-open may have their store
+// calls refused while the probe is in flight; a 500 they end in is answered
+// as 503 too, the same as while the circuit is open.
+func StoreBreakerMiddleware(breaker *store.CircuitBreaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/health") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if retryAfter := breaker.RetryAfter(); retryAfter > 0 {
+				setRetryAfter(w, retryAfter)
+				writeAPIError(w, http.StatusServiceUnavailable, "Data store temporarily unavailable, try again later")
+				return
+			}
+			next.ServeHTTP(&breakerStatusWriter{ResponseWriter: w, breaker: breaker}, r)
+		})
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
+
+// breakerStatusWriter turns a 500 into 503 when the circuit is not closed by
+// the time the response is written
+type breakerStatusWriter struct {
+	http.ResponseWriter
+	breaker     *store.CircuitBreaker
+	wroteHeader bool
+}
+
+func (bw *breakerStatusWriter) WriteHeader(statusCode int) {
+	if !bw.wroteHeader {
+		bw.wroteHeader = true
+		if statusCode == http.StatusInternalServerError && bw.breaker.State() != store.CircuitClosed {
+			statusCode = http.StatusServiceUnavailable
+			setRetryAfter(bw, max(bw.breaker.RetryAfter(), time.Second))
+		}
+	}
+	bw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (bw *breakerStatusWriter) Write(b []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	return bw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (bw *breakerStatusWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// queryCountWriter sets the query count header just before the response headers are sent
+type queryCountWriter struct {
+	http.ResponseWriter
+	counter     *store.QueryCounter
+	wroteHeader bool
+}
+
+func (qw *queryCountWriter) WriteHeader(statusCode int) {
+	if !qw.wroteHeader {
+		qw.wroteHeader = true
+		qw.Header().Set(QueryCountHeader, strconv.Itoa(qw.counter.Count()))
+	}
+	qw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (qw *queryCountWriter) Write(b []byte) (int, error) {
+	if !qw.wroteHeader {
+		qw.WriteHeader(http.StatusOK)
+	}
+	return qw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (qw *queryCountWriter) Unwrap() http.ResponseWriter {
+	return qw.ResponseWriter
+}
+
+// requestStore returns dataStore wrapped to count calls against the request's
+// query counter, or dataStore itself when the request is not being counted
+func requestStore(r *http.Request, dataStore store.DataStore) store.DataStore {
+	if counter, ok := store.QueryCounterFromContext(r.Context()); ok {
+		return store.NewCountingStore(dataStore, counter)
+	}
+	return dataStore
+}
+
+// storeFor returns the data store to use while serving r
+func (h *Handlers) storeFor(r *http.Request) store.DataStore {
+	return requestStore(r, h.store)
+}
+
+// storeFor returns the data store to use while serving r
+func (h *MLHandlers) storeFor(r *http.Request) store.DataStore {
+	return requestStore(r, h.store)
+}
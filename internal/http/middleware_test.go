@@ -0,0 +1,209 @@
+package http
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/Capstone-E1/aquasmart_backend/internal/ws"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestQueryCountMiddleware_MultiQueryHandlerTripsThreshold(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	handlers := NewHandlers(store.NewStore(10), nil, nil, nil)
+	r := chi.NewRouter()
+	r.Use(QueryCountMiddleware(1, true))
+	r.Get("/stats", handlers.GetSystemStats)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	count, err := strconv.Atoi(rec.Header().Get(QueryCountHeader))
+	if err != nil {
+		t.Fatalf("Expected a numeric %s header, got %q", QueryCountHeader, rec.Header().Get(QueryCountHeader))
+	}
+	if count <= 1 {
+		t.Errorf("Expected the stats handler to make several store calls, got %d", count)
+	}
+	if !strings.Contains(logs.String(), "possible N+1 pattern") {
+		t.Errorf("Expected an N+1 warning to be logged, got %q", logs.String())
+	}
+}
+
+func TestQueryCountMiddleware_UnderThresholdStaysQuiet(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	handlers := NewHandlers(store.NewStore(10), nil, nil, nil)
+	r := chi.NewRouter()
+	r.Use(QueryCountMiddleware(50, false))
+	r.Get("/stats", handlers.GetSystemStats)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	if rec.Header().Get(QueryCountHeader) != "" {
+		t.Error("Expected no query count header when the debug header is disabled")
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warning under the threshold, got %q", logs.String())
+	}
+}
+
+func TestRequireAdminToken_DisabledWithoutToken(t *testing.T) {
+	handler := RequireAdminToken("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the handler not to run")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when no admin token is configured, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_RefillsAndSweepsIdleClients(t *testing.T) {
+	limiter := NewRateLimiter(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("10.0.0.1", now); !ok {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	ok, wait := limiter.Allow("10.0.0.1", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("Expected the 4th request to wait 500ms for a token, got allowed=%v wait=%v", ok, wait)
+	}
+	if ok, _ := limiter.Allow("10.0.0.2", now); !ok {
+		t.Error("Expected another client to have its own bucket")
+	}
+	if ok, _ := limiter.Allow("10.0.0.1", now.Add(500*time.Millisecond)); !ok {
+		t.Error("Expected a token to be refilled after 500ms")
+	}
+
+	limiter.Allow("10.0.0.3", now.Add(rateLimitIdleTTL+time.Minute))
+	if clients := limiter.Clients(); clients != 1 {
+		t.Errorf("Expected idle clients to be swept, %d buckets remain", clients)
+	}
+}
+
+func TestRateLimitMiddleware_RejectsWithRetryAfter(t *testing.T) {
+	// httptest requests come from 192.0.2.1, trusted here along with 10.0.0.0/8
+	proxies, err := ParseTrustedProxies([]string{"192.0.2.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Use(RateLimitMiddleware(NewRateLimiter(1, 2), proxies))
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	request := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	request("203.0.113.7, 10.0.0.1")
+	request("203.0.113.7")
+	rec := request("198.51.100.9, 203.0.113.7, 10.0.0.2")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 once the burst is spent, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After: 1, got %q", rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), `"success":false`) {
+		t.Errorf("Expected the standard error body, got %s", rec.Body.String())
+	}
+
+	if rec := request("198.51.100.4"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a different forwarded client to be allowed, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddleware_IgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(RateLimitMiddleware(NewRateLimiter(1, 1), nil))
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	// A client can't dodge the limit by sending a fresh X-Forwarded-For each time
+	for i, forwardedFor := range []string{"203.0.113.7", "203.0.113.8"} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; rec.Code != want {
+			t.Errorf("Request %d: expected %d, got %d", i+1, want, rec.Code)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("Expected an invalid trusted proxy to be rejected")
+	}
+}
+
+func TestStoreBreakerMiddleware_FailsFastWhileOpen(t *testing.T) {
+	breaker := store.NewCircuitBreaker(1, time.Minute)
+	router := SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{StoreBreaker: breaker})
+
+	if code, _ := doRequest(t, router, "/api/v1/stats"); code != http.StatusOK {
+		t.Fatalf("Expected 200 while the circuit is closed, got %d", code)
+	}
+
+	breaker.Record(true)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while the circuit is open, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After: 60, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	code, body := doRequest(t, router, "/health")
+	if code != http.StatusOK || body["database_circuit"] != string(store.CircuitOpen) {
+		t.Errorf("Expected health to report the open circuit, got %d %v", code, body)
+	}
+}
+
+func TestStoreBreakerMiddleware_MapsRefusedCallsWhileHalfOpen(t *testing.T) {
+	breaker := store.NewCircuitBreaker(1, 0)
+	handler := StoreBreakerMiddleware(breaker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusInternalServerError, "Failed to get readings")
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sensors/latest", nil))
+		return rec
+	}
+
+	if rec := serve(); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 to stand while the circuit is closed, got %d", rec.Code)
+	}
+
+	// With no cooldown the circuit is half-open right away, and another call holds the probe
+	breaker.Record(true)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected the probe allowed, got %v", err)
+	}
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 503 with Retry-After: 1 while half-open, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -2,6 +2,8 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -20,27 +22,86 @@ type MLHandlers struct {
 	filterPredictor  *ml.FilterPredictor
 	sensorPredictor  *ml.SensorPredictor
 	mlService        *ml.MLService
+	healthStaleAfter time.Duration // Age at which a filter health assessment is flagged as stale; 0 never flags it
+	resolvedLimit    int           // Recently resolved anomalies shown on the dashboard
+}
+
+// defaultFilterHealthStaleAfter flags filter health once several scheduled
+// analyses (every 30 minutes) have been missed
+const defaultFilterHealthStaleAfter = 2 * time.Hour
+
+// defaultDashboardResolvedLimit is how many recently resolved anomalies the
+// dashboard shows, matching its recent anomalies
+const defaultDashboardResolvedLimit = 10
+
+// filterHealthMaintenanceLimit is how many recent maintenance events accompany filter health
+const filterHealthMaintenanceLimit = 5
+
+// maxFilterHealthWindow bounds the readings per device a filter health analysis loads
+const maxFilterHealthWindow = 1000
+
+// maxFilterHealthRange bounds the period a historical filter health analysis
+// covers, and is the period before end analyzed when no start is given
+const maxFilterHealthRange = 90 * 24 * time.Hour
+
+// insufficientDataStatus is the status ML endpoints report when there are too
+// few readings to compute a result, next to a message saying what is missing
+const insufficientDataStatus = "insufficient_data"
+
+// filterHealthResponse is a filter health assessment with how current it is
+// and the maintenance recently performed on the device
+type filterHealthResponse struct {
+	*models.FilterHealth
+	IsStale                  bool                      `json:"is_stale"`                    // Older than the staleness threshold; the score may be outdated
+	LastCalculatedAgeSeconds int64                     `json:"last_calculated_age_seconds"` // Seconds since the assessment was calculated
+	RecentMaintenance        []models.MaintenanceEvent `json:"recent_maintenance"`          // Most recently performed first
+}
+
+// newFilterHealthResponse classifies health as fresh or stale as of now
+func newFilterHealthResponse(health *models.FilterHealth, now time.Time, staleAfter time.Duration) filterHealthResponse {
+	age := now.Sub(health.LastCalculated)
+	if age < 0 {
+		age = 0
+	}
+	return filterHealthResponse{
+		FilterHealth:             health,
+		IsStale:                  staleAfter > 0 && age > staleAfter,
+		LastCalculatedAgeSeconds: int64(age.Seconds()),
+	}
 }
 
 // NewMLHandlers creates a new ML handlers instance
 func NewMLHandlers(dataStore store.DataStore, mlService *ml.MLService) *MLHandlers {
+	// Share the service's filter predictor so on-demand analyses use the configured rules
+	filterPredictor := ml.NewFilterPredictor()
+	if mlService != nil {
+		filterPredictor = mlService.GetFilterPredictor()
+	}
+
 	return &MLHandlers{
 		store:           dataStore,
 		anomalyDetector: ml.NewAnomalyDetector(),
-		filterPredictor: ml.NewFilterPredictor(),
+		filterPredictor: filterPredictor,
 		sensorPredictor: ml.NewSensorPredictor(),
 		mlService:       mlService,
+		healthStaleAfter: defaultFilterHealthStaleAfter,
+		resolvedLimit:    defaultDashboardResolvedLimit,
 	}
 }
 
-// GetFilterHealth returns the latest filter health assessment
+// GetFilterHealth returns the latest filter health assessment, flagged with
+// is_stale when it was calculated longer ago than the staleness threshold
+// Defaults to the post-filtration device the analysis records health under
 func (h *MLHandlers) GetFilterHealth(w http.ResponseWriter, r *http.Request) {
 	deviceID := r.URL.Query().Get("device_id")
-	if deviceID == "" {
-		deviceID = "filter_system"
-	}
 
-	health, err := h.store.GetLatestFilterHealth(deviceID)
+	var health *models.FilterHealth
+	var err error
+	if deviceID != "" {
+		health, err = h.storeFor(r).GetLatestFilterHealth(deviceID)
+	} else {
+		health, err = latestSystemFilterHealth(h.storeFor(r))
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to get filter health", err)
 		return
@@ -48,50 +109,396 @@ func (h *MLHandlers) GetFilterHealth(w http.ResponseWriter, r *http.Request) {
 
 	if health == nil {
 		respondWithJSON(w, http.StatusOK, map[string]string{
+			"status":  insufficientDataStatus,
 			"message": "No filter health data available yet. Analysis requires pre and post filtration readings.",
 		})
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, health)
+	response := newFilterHealthResponse(health, time.Now(), h.healthStaleAfter)
+	response.RecentMaintenance, err = h.storeFor(r).GetMaintenanceEvents(health.DeviceID, filterHealthMaintenanceLimit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get maintenance log", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// latestSystemFilterHealth returns the latest health of the post-filtration
+// device, falling back to records saved under the legacy system-wide ID
+func latestSystemFilterHealth(dataStore store.DataStore) (*models.FilterHealth, error) {
+	_, postDeviceID := store.ResolveFilterDevices(dataStore)
+	health, err := dataStore.GetLatestFilterHealth(postDeviceID)
+	if err != nil || health != nil {
+		return health, err
+	}
+	return dataStore.GetLatestFilterHealth(ml.LegacyFilterHealthDeviceID)
+}
+
+// recordFilterHealth saves a filter health assessment through the ML service so it
+// is also broadcast to live clients, or straight to the store without a service
+func (h *MLHandlers) recordFilterHealth(r *http.Request, health *models.FilterHealth) error {
+	if h.mlService != nil {
+		return h.mlService.RecordFilterHealth(health)
+	}
+	return h.storeFor(r).SaveFilterHealth(health)
+}
+
+// RunFilterHealthAnalysis forces a filter health analysis of the latest readings,
+// records and broadcasts it like the scheduled analysis, and returns the result.
+// Optional device_id selects the post-filtration device to analyze.
+func (h *MLHandlers) RunFilterHealthAnalysis(w http.ResponseWriter, r *http.Request) {
+	if h.mlService == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "ML service not available", fmt.Errorf("ML service not configured"))
+		return
+	}
+
+	health, err := h.mlService.AnalyzeFilterHealthNow(r.URL.Query().Get("device_id"))
+	if err != nil {
+		var insufficient *ml.InsufficientFilterDataError
+		if errors.As(err, &insufficient) {
+			respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"error":         "Insufficient data for analysis",
+				"details":       insufficient.Error(),
+				"pre_readings":  insufficient.PreReadings,
+				"post_readings": insufficient.PostReadings,
+				"required":      insufficient.Required,
+			})
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to analyze filter health", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Filter health analysis completed",
+		"health":  health,
+	})
+}
+
+// anomalyConfig returns the real-time anomaly detection settings
+func (h *MLHandlers) anomalyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":               h.mlService.RealTimeAnomalyEnabled(),
+		"alert_throttle_window": h.mlService.AlertThrottleWindow().String(),
+		"min_baseline_samples":  ml.MinBaselineSamples,
+	}
+}
+
+// GetAnomalyConfig returns whether new readings are checked for anomalies
+func (h *MLHandlers) GetAnomalyConfig(w http.ResponseWriter, r *http.Request) {
+	if h.mlService == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "ML service not available", fmt.Errorf("ML service not configured"))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h.anomalyConfig())
+}
+
+// UpdateAnomalyConfig switches real-time anomaly detection on or off
+func (h *MLHandlers) UpdateAnomalyConfig(w http.ResponseWriter, r *http.Request) {
+	if h.mlService == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "ML service not available", fmt.Errorf("ML service not configured"))
+		return
+	}
+
+	var request struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.Enabled == nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", fmt.Errorf("enabled is required"))
+		return
+	}
+
+	h.mlService.EnableRealTimeAnomaly(*request.Enabled)
+
+	respondWithJSON(w, http.StatusOK, h.anomalyConfig())
+}
+
+// SetFilterHealth records a manually supplied filter health assessment, e.g.
+// from an external lab result or to seed a demo, bypassing the computed analysis
+func (h *MLHandlers) SetFilterHealth(w http.ResponseWriter, r *http.Request) {
+	var health models.FilterHealth
+	if err := json.NewDecoder(r.Body).Decode(&health); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := health.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter health", err)
+		return
+	}
+
+	if health.DeviceID == "" {
+		_, health.DeviceID = store.ResolveFilterDevices(h.storeFor(r))
+	}
+	if health.FilterMode == "" {
+		health.FilterMode = h.storeFor(r).GetCurrentFilterMode()
+	}
+	if health.EstimatedReplacement.IsZero() {
+		health.EstimatedReplacement = time.Now().AddDate(0, 0, health.PredictedDaysRemaining)
+	}
+	if health.EfficiencyTrend == "" {
+		health.EfficiencyTrend = "stable"
+	}
+	if health.Recommendations == nil {
+		health.Recommendations = []string{}
+	}
+	// Same thresholds as the computed analysis
+	health.MaintenanceRequired = health.HealthScore < 75
+	health.ReplacementUrgent = health.HealthScore < 30 || health.PredictedDaysRemaining < 7
+	health.LastCalculated = time.Now()
+
+	if err := h.recordFilterHealth(r, &health); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save filter health", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "Filter health recorded",
+		"health":  health,
+	})
 }
 
 // AnalyzeFilterHealth triggers a new filter health analysis
+// Optional query params: device_id (post-filtration device to analyze and record the
+// health under, defaults to the classified one), window (readings per device, default
+// 100), start/end (RFC3339) to analyze a historical period of at most 90 days, and
+// persist=false to skip saving the result
 func (h *MLHandlers) AnalyzeFilterHealth(w http.ResponseWriter, r *http.Request) {
-	// Get recent pre and post filtration readings
-	preReadings := h.store.GetRecentReadingsByDevice("stm32_pre", 100)
-	postReadings := h.store.GetRecentReadingsByDevice("stm32_post", 100)
+	window := 100
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		parsedWindow, err := strconv.Atoi(windowStr)
+		if err != nil || parsedWindow <= 0 || parsedWindow > maxFilterHealthWindow {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid window. Must be between 1 and %d readings", maxFilterHealthWindow),
+				fmt.Errorf("invalid window: %q", windowStr))
+			return
+		}
+		window = parsedWindow
+	}
+
+	persist := r.URL.Query().Get("persist") != "false"
+
+	startStr := r.URL.Query().Get("start")
+	endStr := r.URL.Query().Get("end")
+
+	// Pre/post devices follow the device type classification (including metadata overrides)
+	preDeviceID, postDeviceID := store.ResolveFilterDevices(h.storeFor(r))
+	if deviceID := r.URL.Query().Get("device_id"); deviceID != "" {
+		postDeviceID = deviceID
+	}
+
+	var preReadings, postReadings []models.SensorReading
+	if startStr != "" || endStr != "" {
+		// Historical analysis over the requested period
+		end := time.Now()
+		var err error
+
+		if endStr != "" {
+			end, err = time.Parse(time.RFC3339, endStr)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid end date format. Use RFC3339 format", err)
+				return
+			}
+		}
+		start := end.Add(-maxFilterHealthRange)
+		if startStr != "" {
+			start, err = time.Parse(time.RFC3339, startStr)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid start date format. Use RFC3339 format", err)
+				return
+			}
+		}
+		if end.Before(start) || end.Sub(start) > maxFilterHealthRange {
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid date range. The end must not be before the start, nor more than %d days after it", int(maxFilterHealthRange.Hours()/24)),
+				fmt.Errorf("invalid range %s to %s", start.Format(time.RFC3339), end.Format(time.RFC3339)))
+			return
+		}
+
+		// The latest window readings of each device in the period, newest first
+		for _, device := range []struct {
+			id       string
+			readings *[]models.SensorReading
+		}{{preDeviceID, &preReadings}, {postDeviceID, &postReadings}} {
+			filter := models.ReadingFilter{DeviceID: device.id, Start: &start, End: &end, Limit: window}
+			*device.readings, _, err = h.storeFor(r).QueryReadings(filter)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to get sensor readings", err)
+				return
+			}
+		}
+	} else {
+		// Get recent pre and post filtration readings
+		preReadings = h.storeFor(r).GetRecentReadingsByDevice(preDeviceID, window)
+		postReadings = h.storeFor(r).GetRecentReadingsByDevice(postDeviceID, window)
+	}
 
 	if len(preReadings) < 20 || len(postReadings) < 20 {
-		respondWithJSON(w, http.StatusOK, map[string]string{
-			"message": "Insufficient data for analysis. Need at least 20 pre and post filtration readings.",
-			"pre_readings": strconv.Itoa(len(preReadings)),
-			"post_readings": strconv.Itoa(len(postReadings)),
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":        insufficientDataStatus,
+			"message":       "Insufficient data for analysis. Need at least 20 pre and post filtration readings.",
+			"pre_readings":  len(preReadings),
+			"post_readings": len(postReadings),
+			"required":      20,
 		})
 		return
 	}
 
 	// Get current filter mode
-	filterMode := h.store.GetCurrentFilterMode()
+	filterMode := h.storeFor(r).GetCurrentFilterMode()
 
 	// Perform analysis
-	health, err := h.filterPredictor.AnalyzeFilterHealth(preReadings, postReadings, filterMode)
+	health, err := h.filterPredictor.AnalyzeFilterHealth(postDeviceID, preReadings, postReadings, filterMode)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to analyze filter health", err)
 		return
 	}
 
 	// Save to database
-	if err := h.store.SaveFilterHealth(health); err != nil {
-		log.Printf("Warning: Failed to save filter health: %v", err)
+	if persist {
+		if err := h.recordFilterHealth(r, health); err != nil {
+			log.Printf("Warning: Failed to save filter health: %v", err)
+		}
 	}
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "Filter health analysis completed",
-		"health":  health,
+		"message":       "Filter health analysis completed",
+		"health":        health,
+		"persisted":     persist,
+		"window":        window,
+		"pre_readings":  len(preReadings),
+		"post_readings": len(postReadings),
 	})
 }
 
+// defaultEfficiencyHistoryRange is the period covered by the efficiency history
+// when no start is given
+const defaultEfficiencyHistoryRange = 30 * 24 * time.Hour
+
+// GetEfficiencyHistory returns average filter efficiency per time bucket
+// Query params: device_id (post-filtration device, defaults to the classified one),
+// bucket (hour, day or week; default day), start/end (RFC3339; default last 30 days)
+func (h *MLHandlers) GetEfficiencyHistory(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	bucketSize, ok := ml.EfficiencyBucketSizes[bucket]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid bucket. Use hour, day or week", fmt.Errorf("invalid bucket: %q", bucket))
+		return
+	}
+
+	end := time.Now()
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid end date format. Use RFC3339 format", err)
+			return
+		}
+		end = parsed
+	}
+	start := end.Add(-defaultEfficiencyHistoryRange)
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid start date format. Use RFC3339 format", err)
+			return
+		}
+		start = parsed
+	}
+	if end.Before(start) {
+		respondWithError(w, http.StatusBadRequest, "end must not be before start", fmt.Errorf("end %s before start %s", end, start))
+		return
+	}
+
+	preDeviceID, postDeviceID := store.ResolveFilterDevices(h.storeFor(r))
+	if deviceID := r.URL.Query().Get("device_id"); deviceID != "" {
+		postDeviceID = deviceID
+	}
+
+	var preReadings, postReadings []models.SensorReading
+	for _, reading := range h.storeFor(r).GetReadingsInRange(start, end) {
+		switch reading.DeviceID {
+		case preDeviceID:
+			preReadings = append(preReadings, reading)
+		case postDeviceID:
+			postReadings = append(postReadings, reading)
+		}
+	}
+
+	history := h.filterPredictor.EfficiencyHistory(preReadings, postReadings, bucketSize)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id":     postDeviceID,
+		"pre_device_id": preDeviceID,
+		"bucket":        bucket,
+		"start":         start,
+		"end":           end,
+		"count":         len(history),
+		"history":       history,
+	})
+}
+
+// GetWaterEfficiency returns the share of the input water the filter turns into
+// clean output, from the flow of matched pre/post filtration readings
+// Query params: device_id (post-filtration device, defaults to the classified one),
+// start/end (RFC3339; default last 30 days)
+func (h *MLHandlers) GetWaterEfficiency(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseAnalyticsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	preDeviceID, postDeviceID := store.ResolveFilterDevices(h.storeFor(r))
+	if deviceID := r.URL.Query().Get("device_id"); deviceID != "" {
+		postDeviceID = deviceID
+	}
+
+	var preReadings, postReadings []models.SensorReading
+	for _, reading := range h.storeFor(r).GetReadingsInRange(start, end) {
+		switch reading.DeviceID {
+		case preDeviceID:
+			preReadings = append(preReadings, reading)
+		case postDeviceID:
+			postReadings = append(postReadings, reading)
+		}
+	}
+
+	response := map[string]interface{}{
+		"device_id":      postDeviceID,
+		"pre_device_id":  preDeviceID,
+		"start":          start,
+		"end":            end,
+		"pre_readings":   len(preReadings),
+		"post_readings":  len(postReadings),
+		"wasteful_below": ml.WastefulRecoveryRatio,
+	}
+	efficiency := h.filterPredictor.WaterEfficiency(preReadings, postReadings)
+	response["efficiency"] = efficiency
+
+	switch {
+	case len(preReadings) == 0 && len(postReadings) == 0:
+		response["message"] = "No pre or post filtration readings in the range"
+	case len(preReadings) == 0:
+		response["message"] = "No pre-filtration readings in the range, so the input flow is unknown"
+	case len(postReadings) == 0:
+		response["message"] = "No post-filtration readings in the range, so the clean output is unknown"
+	case efficiency.Status == models.WaterEfficiencyInsufficient:
+		response["message"] = "No pre and post filtration readings with flow were taken close enough together to compare"
+	case efficiency.Status == models.WaterEfficiencyWasteful:
+		response["message"] = fmt.Sprintf("Less than %.0f%% of the input water comes out clean; check the filter for excessive waste water", ml.WastefulRecoveryRatio*100)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
 // GetAnomalies returns detected anomalies
 func (h *MLHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
@@ -109,11 +516,11 @@ func (h *MLHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if deviceID != "" {
-		anomalies, err = h.store.GetAnomaliesByDevice(deviceID, limit)
+		anomalies, err = h.storeFor(r).GetAnomaliesByDevice(deviceID, limit)
 	} else if severity != "" {
-		anomalies, err = h.store.GetAnomaliesBySeverity(severity, limit)
+		anomalies, err = h.storeFor(r).GetAnomaliesBySeverity(severity, limit)
 	} else {
-		anomalies, err = h.store.GetAnomalies(limit)
+		anomalies, err = h.storeFor(r).GetAnomalies(limit)
 	}
 
 	if err != nil {
@@ -123,13 +530,13 @@ func (h *MLHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"count":     len(anomalies),
-		"anomalies": anomalies,
+		"anomalies": nonNilAnomalies(anomalies),
 	})
 }
 
 // GetUnresolvedAnomalies returns all unresolved anomalies
 func (h *MLHandlers) GetUnresolvedAnomalies(w http.ResponseWriter, r *http.Request) {
-	anomalies, err := h.store.GetUnresolvedAnomalies()
+	anomalies, err := h.storeFor(r).GetUnresolvedAnomalies()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to get unresolved anomalies", err)
 		return
@@ -137,10 +544,18 @@ func (h *MLHandlers) GetUnresolvedAnomalies(w http.ResponseWriter, r *http.Reque
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"count":     len(anomalies),
-		"anomalies": anomalies,
+		"anomalies": nonNilAnomalies(anomalies),
 	})
 }
 
+// nonNilAnomalies ensures an empty anomaly list is encoded as [] instead of null
+func nonNilAnomalies(anomalies []models.AnomalyDetection) []models.AnomalyDetection {
+	if anomalies == nil {
+		return []models.AnomalyDetection{}
+	}
+	return anomalies
+}
+
 // ResolveAnomaly marks an anomaly as resolved
 func (h *MLHandlers) ResolveAnomaly(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -150,7 +565,7 @@ func (h *MLHandlers) ResolveAnomaly(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.ResolveAnomaly(id); err != nil {
+	if err := h.storeFor(r).ResolveAnomaly(id); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to resolve anomaly", err)
 		return
 	}
@@ -160,6 +575,66 @@ func (h *MLHandlers) ResolveAnomaly(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxAnomalyContextReadings bounds the before/after window of an anomaly context request
+const maxAnomalyContextReadings = 500
+
+// GetAnomalyContext returns an anomaly with the device's readings immediately
+// before and after it was detected (?before=10&after=10)
+func (h *MLHandlers) GetAnomalyContext(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid anomaly ID", err)
+		return
+	}
+
+	window := map[string]int{"before": 10, "after": 10}
+	for param := range window {
+		if value := r.URL.Query().Get(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > maxAnomalyContextReadings {
+				respondWithError(w, http.StatusBadRequest, "Invalid "+param+" parameter",
+					fmt.Errorf("%s must be an integer between 0 and %d", param, maxAnomalyContextReadings))
+				return
+			}
+			window[param] = n
+		}
+	}
+
+	anomaly, err := h.storeFor(r).GetAnomaly(id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get anomaly", err)
+		return
+	}
+	if anomaly == nil {
+		respondWithError(w, http.StatusNotFound, "Anomaly not found", fmt.Errorf("no anomaly with ID %d", id))
+		return
+	}
+
+	readings, err := h.storeFor(r).GetReadingsAround(anomaly.DeviceID, anomaly.DetectedAt, window["before"], window["after"])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get surrounding readings", err)
+		return
+	}
+
+	// Split the chronological window at the detection time
+	before := []models.SensorReading{}
+	after := []models.SensorReading{}
+	for _, reading := range readings {
+		if reading.Timestamp.After(anomaly.DetectedAt) {
+			after = append(after, reading)
+		} else {
+			before = append(before, reading)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"anomaly": anomaly,
+		"before":  before,
+		"after":   after,
+		"count":   len(readings),
+	})
+}
+
 // MarkAnomalyFalsePositive marks an anomaly as false positive
 func (h *MLHandlers) MarkAnomalyFalsePositive(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -169,7 +644,7 @@ func (h *MLHandlers) MarkAnomalyFalsePositive(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := h.store.MarkAnomalyFalsePositive(id); err != nil {
+	if err := h.storeFor(r).MarkAnomalyFalsePositive(id); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to mark anomaly as false positive", err)
 		return
 	}
@@ -181,7 +656,7 @@ func (h *MLHandlers) MarkAnomalyFalsePositive(w http.ResponseWriter, r *http.Req
 
 // GetAnomalyStats returns anomaly statistics
 func (h *MLHandlers) GetAnomalyStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.store.GetAnomalyStats()
+	stats, err := h.storeFor(r).GetAnomalyStats()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to get anomaly stats", err)
 		return
@@ -190,38 +665,114 @@ func (h *MLHandlers) GetAnomalyStats(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, stats)
 }
 
+// GetAnomalyOpsMetrics returns anomaly resolution rate, MTTR and false-positive
+// rate for maintenance reporting
+func (h *MLHandlers) GetAnomalyOpsMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := h.storeFor(r).GetAnomalyOpsMetrics()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get anomaly ops metrics", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, metrics)
+}
+
+// GetFilterEconomics returns the cost per liter and remaining life of a
+// device's filter from its recorded capacity and cost and the volume it has
+// processed since installation. device_id defaults to the post-filtration device.
+func (h *MLHandlers) GetFilterEconomics(w http.ResponseWriter, r *http.Request) {
+	dataStore := h.storeFor(r)
+	deviceID := r.URL.Query().Get("device_id")
+	if deviceID == "" {
+		_, deviceID = store.ResolveFilterDevices(dataStore)
+	}
+
+	spec, exists, err := dataStore.GetFilterSpec(deviceID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get filter metadata", err)
+		return
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "No filter metadata recorded for device "+deviceID,
+			fmt.Errorf("set capacity_liters, cost and installed_at with PUT /api/v1/devices/%s/filter", deviceID))
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Incomplete filter metadata for device "+deviceID, err)
+		return
+	}
+
+	processed, err := dataStore.GetProcessedVolume(deviceID, spec.InstalledAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get processed volume", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.CalculateFilterEconomics(spec, processed, time.Now()))
+}
+
 // CalculateBaselines calculates sensor baselines for anomaly detection
 func (h *MLHandlers) CalculateBaselines(w http.ResponseWriter, r *http.Request) {
-	devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+	devices := store.DeviceIDs(h.storeFor(r))
 	modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold}
 
 	baselinesCreated := 0
+	results := []baselineResult{}
 
 	for _, device := range devices {
+		allReadings := h.storeFor(r).GetReadingsByDevice(device)
+
 		for _, mode := range modes {
-			// Get recent readings for this device/mode combination
-			allReadings := h.store.GetReadingsByDevice(device)
+			result := baselineResult{DeviceID: device, FilterMode: mode}
 
 			baseline := h.anomalyDetector.CalculateBaseline(allReadings, device, mode)
-			if baseline != nil {
-				if err := h.store.SaveBaseline(baseline); err != nil {
+			switch {
+			case baseline == nil:
+				samples := 0
+				for _, reading := range allReadings {
+					if reading.FilterMode == mode {
+						samples++
+					}
+				}
+				result.Status = insufficientDataStatus
+				result.SampleSize = samples
+				result.Error = fmt.Sprintf("need at least %d readings, have %d", ml.MinBaselineSamples, samples)
+			default:
+				result.SampleSize = baseline.SampleSize
+				if err := h.storeFor(r).SaveBaseline(baseline); err != nil {
 					log.Printf("Warning: Failed to save baseline for %s/%s: %v", device, mode, err)
+					result.Status = "error"
+					result.Error = err.Error()
 				} else {
+					result.Status = "created"
 					baselinesCreated++
 				}
 			}
+
+			results = append(results, result)
 		}
 	}
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"message":           "Baseline calculation completed",
 		"baselines_created": baselinesCreated,
+		"baselines_failed":  len(results) - baselinesCreated,
+		"results":           results,
 	})
 }
 
+// baselineResult reports the outcome of building one device/mode baseline
+type baselineResult struct {
+	DeviceID   string            `json:"device_id"`
+	FilterMode models.FilterMode `json:"filter_mode"`
+	Status     string            `json:"status"` // "created", "insufficient_data" or "error"
+	SampleSize int               `json:"sample_size"`
+	Error      string            `json:"error,omitempty"`
+}
+
 // GetBaselines returns all sensor baselines
 func (h *MLHandlers) GetBaselines(w http.ResponseWriter, r *http.Request) {
-	baselines, err := h.store.GetAllBaselines()
+	baselines, err := h.storeFor(r).GetAllBaselines()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to get baselines", err)
 		return
@@ -235,18 +786,18 @@ func (h *MLHandlers) GetBaselines(w http.ResponseWriter, r *http.Request) {
 
 // DetectAnomaliesNow performs real-time anomaly detection on latest readings
 func (h *MLHandlers) DetectAnomaliesNow(w http.ResponseWriter, r *http.Request) {
-	devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+	devices := store.DeviceIDs(h.storeFor(r))
 	totalAnomalies := 0
 
 	for _, device := range devices {
 		// Get latest reading
-		reading, exists := h.store.GetLatestReadingByDevice(device)
+		reading, exists := h.storeFor(r).GetLatestReadingByDevice(device)
 		if !exists {
 			continue
 		}
 
 		// Get baseline for this device/mode
-		baseline, err := h.store.GetBaseline(device, reading.FilterMode)
+		baseline, err := h.storeFor(r).GetBaseline(device, reading.FilterMode)
 		if err != nil {
 			log.Printf("Warning: Failed to get baseline for %s: %v", device, err)
 			continue
@@ -260,7 +811,7 @@ func (h *MLHandlers) DetectAnomaliesNow(w http.ResponseWriter, r *http.Request)
 		// Detect anomalies
 		anomalies := h.anomalyDetector.DetectAnomalies(reading, baseline)
 		for _, anomaly := range anomalies {
-			if err := h.store.SaveAnomaly(&anomaly); err != nil {
+			if err := h.storeFor(r).SaveAnomaly(&anomaly); err != nil {
 				log.Printf("Warning: Failed to save anomaly: %v", err)
 			} else {
 				totalAnomalies++
@@ -278,24 +829,34 @@ func (h *MLHandlers) DetectAnomaliesNow(w http.ResponseWriter, r *http.Request)
 // GetMLDashboard returns a comprehensive ML dashboard with all metrics
 func (h *MLHandlers) GetMLDashboard(w http.ResponseWriter, r *http.Request) {
 	// Get filter health
-	filterHealth, _ := h.store.GetLatestFilterHealth("filter_system")
+	filterHealth, _ := latestSystemFilterHealth(h.storeFor(r))
 
 	// Get unresolved anomalies
-	unresolvedAnomalies, _ := h.store.GetUnresolvedAnomalies()
+	unresolvedAnomalies, _ := h.storeFor(r).GetUnresolvedAnomalies()
 
 	// Get anomaly stats
-	anomalyStats, _ := h.store.GetAnomalyStats()
+	anomalyStats, _ := h.storeFor(r).GetAnomalyStats()
 
 	// Get recent anomalies
-	recentAnomalies, _ := h.store.GetAnomalies(10)
+	recentAnomalies, _ := h.storeFor(r).GetAnomalies(10)
+
+	// Get recently resolved anomalies, with whether each was resolved automatically or by a user
+	resolvedAnomalies := []models.AnomalyDetection{}
+	if h.resolvedLimit > 0 {
+		if resolved, err := h.storeFor(r).GetRecentlyResolvedAnomalies(h.resolvedLimit); err == nil && resolved != nil {
+			resolvedAnomalies = resolved
+		}
+	}
 
 	dashboard := map[string]interface{}{
+		"live_deviation": h.liveDeviation(h.storeFor(r)),
 		"filter_health": filterHealth,
 		"anomalies": map[string]interface{}{
-			"unresolved_count": len(unresolvedAnomalies),
-			"unresolved":       unresolvedAnomalies,
-			"recent":           recentAnomalies,
-			"stats":            anomalyStats,
+			"unresolved_count":  len(unresolvedAnomalies),
+			"unresolved":        nonNilAnomalies(unresolvedAnomalies),
+			"recent":            nonNilAnomalies(recentAnomalies),
+			"recently_resolved": resolvedAnomalies,
+			"stats":             anomalyStats,
 		},
 		"system_status": map[string]interface{}{
 			"ml_features_enabled": true,
@@ -306,7 +867,35 @@ func (h *MLHandlers) GetMLDashboard(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, dashboard)
 }
 
-// GetPredictions returns sensor value predictions
+// liveDeviation scores each device's latest reading against its baseline for the
+// reading's filter mode. Devices without a baseline report baseline_available=false.
+func (h *MLHandlers) liveDeviation(dataStore store.DataStore) map[string]interface{} {
+	deviation := map[string]interface{}{}
+	for deviceID, reading := range dataStore.GetAllLatestReadingsByDevice() {
+		entry := map[string]interface{}{
+			"filter_mode":        reading.FilterMode,
+			"timestamp":          reading.Timestamp,
+			"baseline_available": false,
+		}
+
+		baseline, err := dataStore.GetBaseline(deviceID, reading.FilterMode)
+		if err == nil && baseline != nil {
+			entry["baseline_available"] = true
+			entry["metrics"] = h.anomalyDetector.CurrentZScores(&reading, baseline)
+		}
+		deviation[deviceID] = entry
+	}
+	return deviation
+}
+
+// defaultPredictionListRange is how far ahead /predictions lists forecasts by default
+const defaultPredictionListRange = 7 * 24 * time.Hour
+
+// GetPredictions returns a device's stored sensor predictions, soonest first.
+// Forecasts lose confidence the further ahead they are, so min_confidence hides
+// the unreliable far-horizon ones.
+// Query params: device_id (default stm32_pre), min_confidence (0-1, default 0),
+// limit (default 24), start/end (RFC3339; default the next 7 days)
 func (h *MLHandlers) GetPredictions(w http.ResponseWriter, r *http.Request) {
 	deviceID := r.URL.Query().Get("device_id")
 	if deviceID == "" {
@@ -321,13 +910,141 @@ func (h *MLHandlers) GetPredictions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// TODO: Implement GetSensorPredictions in store
-	// For now, return placeholder
+	minConfidence := 0.0
+	if confidenceStr := r.URL.Query().Get("min_confidence"); confidenceStr != "" {
+		parsed, err := strconv.ParseFloat(confidenceStr, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid min_confidence. Use a number from 0 to 1", fmt.Errorf("invalid min_confidence: %q", confidenceStr))
+			return
+		}
+		minConfidence = parsed
+	}
+
+	start := time.Now()
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid start date format. Use RFC3339 format", err)
+			return
+		}
+		start = parsed
+	}
+	end := start.Add(defaultPredictionListRange)
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid end date format. Use RFC3339 format", err)
+			return
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		respondWithError(w, http.StatusBadRequest, "end must not be before start", fmt.Errorf("end %s before start %s", end, start))
+		return
+	}
+
+	predictions, err := h.storeFor(r).GetConfidentSensorPredictions(deviceID, minConfidence, start, end, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get predictions", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "Prediction retrieval not yet fully implemented",
-		"device_id": deviceID,
-		"requested_limit": limit,
-		"note": "Store methods need to be implemented for database access",
+		"device_id":      deviceID,
+		"min_confidence": minConfidence,
+		"start":          start,
+		"end":            end,
+		"count":          len(predictions),
+		"predictions":    predictions,
+	})
+}
+
+// defaultPredictionOverlayRange is how far either side of now /predictions/vs-actual
+// covers by default: the past day's validated forecasts and the day ahead
+const defaultPredictionOverlayRange = 24 * time.Hour
+
+// GetPredictionsVsActual returns a device's predicted values of a metric aligned
+// with the values measured at each predicted time, for overlaying the forecast
+// on what happened. Actual is null for predictions not validated yet, which
+// includes every future prediction.
+// Query params: device_id (default stm32_pre), metric (ph, tds, turbidity, flow),
+// filter_mode (default drinking_water), start/end (RFC3339; default a day either side of now)
+func (h *MLHandlers) GetPredictionsVsActual(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("device_id")
+	if deviceID == "" {
+		deviceID, _ = store.ResolveFilterDevices(h.storeFor(r))
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if !models.IsValidSensorMetric(metric) {
+		respondWithError(w, http.StatusBadRequest, "Invalid metric. Use one of: ph, tds, turbidity, flow", fmt.Errorf("invalid metric: %q", metric))
+		return
+	}
+
+	filterMode := models.FilterModeDrinking
+	if r.URL.Query().Get("filter_mode") == string(models.FilterModeHousehold) {
+		filterMode = models.FilterModeHousehold
+	}
+
+	now := time.Now()
+	start, end := now.Add(-defaultPredictionOverlayRange), now.Add(defaultPredictionOverlayRange)
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid start date format. Use RFC3339 format", err)
+			return
+		}
+		start = parsed
+	}
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid end date format. Use RFC3339 format", err)
+			return
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		respondWithError(w, http.StatusBadRequest, "end must not be before start", fmt.Errorf("end %s before start %s", end, start))
+		return
+	}
+
+	predictions, err := h.storeFor(r).GetSensorPredictions(deviceID, start, end)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get predictions", err)
+		return
+	}
+
+	points := []models.PredictionVsActual{}
+	validated := 0
+	for i := range predictions {
+		prediction := &predictions[i]
+		if prediction.FilterMode != filterMode {
+			continue
+		}
+		predicted, _ := prediction.PredictedMetric(metric)
+		point := models.PredictionVsActual{
+			PredictedFor:    prediction.PredictedFor,
+			Predicted:       predicted,
+			Actual:          prediction.ActualMetric(metric),
+			ConfidenceScore: prediction.ConfidenceScore,
+			IsValidated:     prediction.IsValidated,
+		}
+		if point.Actual != nil {
+			validated++
+		}
+		points = append(points, point)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id":   deviceID,
+		"metric":      metric,
+		"filter_mode": filterMode,
+		"start":       start,
+		"end":         end,
+		"count":       len(points),
+		"validated":   validated,
+		"points":      points,
 	})
 }
 
@@ -348,10 +1065,11 @@ func (h *MLHandlers) GeneratePredictions(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Get historical readings
-	historicalReadings := h.store.GetRecentReadingsByDevice(deviceID, 200)
+	historicalReadings := h.storeFor(r).GetRecentReadingsByDevice(deviceID, 200)
 
 	if len(historicalReadings) < 50 {
 		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status": insufficientDataStatus,
 			"message": "Insufficient historical data for predictions",
 			"device_id": deviceID,
 			"readings_available": len(historicalReadings),
@@ -381,15 +1099,62 @@ func (h *MLHandlers) GeneratePredictions(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// GetPredictionAccuracy returns prediction accuracy metrics
+// predictionAccuracyLookback is how many recent daily summaries the latest
+// accuracy is picked from, enough for every device and mode to have one
+const predictionAccuracyLookback = 60
+
+// GetPredictionAccuracy returns the latest accuracy summary of each device and
+// filter mode, as saved when predictions are validated
 func (h *MLHandlers) GetPredictionAccuracy(w http.ResponseWriter, r *http.Request) {
 	deviceID := r.URL.Query().Get("device_id")
 
-	// TODO: Implement GetPredictionAccuracySummary in store
+	recent, err := h.storeFor(r).GetPredictionAccuracyHistory(deviceID, predictionAccuracyLookback)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get prediction accuracy", err)
+		return
+	}
+
+	// History is oldest first, so later summaries replace earlier ones
+	latest := make(map[string]int)
+	summaries := []models.PredictionAccuracySummary{}
+	for _, summary := range recent {
+		key := summary.DeviceID + "/" + string(summary.FilterMode)
+		if i, ok := latest[key]; ok {
+			summaries[i] = summary
+			continue
+		}
+		latest[key] = len(summaries)
+		summaries = append(summaries, summary)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id": deviceID,
+		"count":     len(summaries),
+		"summaries": summaries,
+	})
+}
+
+// GetPredictionAccuracyHistory returns prediction accuracy summaries across periods, oldest first
+func (h *MLHandlers) GetPredictionAccuracyHistory(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("device_id")
+
+	limit := 30
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	history, err := h.storeFor(r).GetPredictionAccuracyHistory(deviceID, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get prediction accuracy history", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "Prediction accuracy tracking not yet fully implemented",
 		"device_id": deviceID,
-		"note": "Store methods need to be implemented for accuracy retrieval",
+		"count":     len(history),
+		"history":   history,
 	})
 }
 
@@ -397,15 +1162,16 @@ func (h *MLHandlers) GetPredictionAccuracy(w http.ResponseWriter, r *http.Reques
 func (h *MLHandlers) TriggerPredictionUpdate(w http.ResponseWriter, r *http.Request) {
 	log.Println("Manual prediction update triggered via API")
 
-	// Trigger update asynchronously
+	// Trigger update asynchronously, through the request's store like the other handlers
+	dataStore := h.storeFor(r)
 	go func() {
-		devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+		devices := store.DeviceIDs(dataStore)
 		modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold}
 
 		updated := 0
 		for _, device := range devices {
 			for _, mode := range modes {
-				historicalReadings := h.store.GetRecentReadingsByDevice(device, 200)
+				historicalReadings := dataStore.GetRecentReadingsByDevice(device, 200)
 				if len(historicalReadings) >= 50 {
 					_, err := h.sensorPredictor.PredictSensorValues(historicalReadings, device, mode)
 					if err == nil {
@@ -443,7 +1209,7 @@ func (h *MLHandlers) GetPredictionStatus(w http.ResponseWriter, r *http.Request)
 			"trigger_on_new_data": "enabled",
 			"scheduled_updates": "enabled",
 			"forecast_horizon": "24 time periods",
-			"prediction_method": "exponential_smoothing_with_trend",
+			"prediction_method": "holt_winters",
 		},
 	})
 }
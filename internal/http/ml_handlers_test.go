@@ -0,0 +1,1149 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/ml"
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// seedFilterReadings adds matched pre/post filtration reading pairs, 10 minutes apart
+func seedFilterReadings(s *store.Store, base time.Time, pairs int) {
+	for i := 0; i < pairs; i++ {
+		ts := base.Add(time.Duration(i) * 10 * time.Minute)
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 6.5, Turbidity: 10.0, TDS: 300,
+		})
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: ts.Add(5 * time.Second), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 7.0, Turbidity: 1.0, TDS: 50,
+		})
+	}
+}
+
+func TestAnalyzeFilterHealth_CustomWindow(t *testing.T) {
+	s := store.NewStore(1000)
+	seedFilterReadings(s, time.Now().Add(-12*time.Hour), 40)
+	h := NewMLHandlers(s, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?window=25", nil)
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body["pre_readings"] != float64(25) || body["post_readings"] != float64(25) {
+		t.Errorf("Expected 25 pre/post readings, got %v/%v", body["pre_readings"], body["post_readings"])
+	}
+	if body["persisted"] != true {
+		t.Errorf("Expected analysis to be persisted by default, got %v", body["persisted"])
+	}
+
+	if health, _ := s.GetLatestFilterHealth("stm32_post"); health == nil {
+		t.Error("Expected filter health to be saved")
+	}
+}
+
+func TestAnalyzeFilterHealth_TimeRange(t *testing.T) {
+	s := store.NewStore(1000)
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Minute)
+	seedFilterReadings(s, base, 40)
+	h := NewMLHandlers(s, nil)
+
+	// Covers the first 30 pairs (0..290 minutes)
+	query := url.Values{}
+	query.Set("start", base.Add(-time.Minute).Format(time.RFC3339))
+	query.Set("end", base.Add(295*time.Minute).Format(time.RFC3339))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, req)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body["pre_readings"] != float64(30) {
+		t.Errorf("Expected 30 pre readings in range, got %v", body["pre_readings"])
+	}
+}
+
+func TestAnalyzeFilterHealth_RejectsInvalidTimeRanges(t *testing.T) {
+	h := NewMLHandlers(store.NewStore(100), nil)
+	end := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, tt := range map[string]struct{ start, end time.Time }{
+		"inverted": {end.Add(time.Hour), end},
+		"too long": {end.Add(-maxFilterHealthRange - time.Hour), end},
+	} {
+		query := url.Values{}
+		query.Set("start", tt.start.Format(time.RFC3339))
+		query.Set("end", tt.end.Format(time.RFC3339))
+		rec := httptest.NewRecorder()
+		h.AnalyzeFilterHealth(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?"+query.Encode(), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestAnalyzeFilterHealth_EndOnlyCoversBoundedPeriod(t *testing.T) {
+	s := store.NewStore(1000)
+	end := time.Now().Truncate(time.Minute)
+	seedFilterReadings(s, end.Add(-maxFilterHealthRange-3*time.Hour), 10) // Before the period
+	seedFilterReadings(s, end.Add(-10*time.Hour), 25)
+	h := NewMLHandlers(s, nil)
+
+	query := url.Values{}
+	query.Set("end", end.Format(time.RFC3339))
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?"+query.Encode(), nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["pre_readings"] != float64(25) || body["post_readings"] != float64(25) {
+		t.Errorf("Expected only the 25 pairs in the period before end, got %v/%v", body["pre_readings"], body["post_readings"])
+	}
+}
+
+func TestAnalyzeFilterHealth_NoPersist(t *testing.T) {
+	s := store.NewStore(1000)
+	seedFilterReadings(s, time.Now().Add(-12*time.Hour), 40)
+	h := NewMLHandlers(s, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?persist=false", nil)
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body["health"] == nil {
+		t.Error("Expected health analysis in response")
+	}
+	if body["persisted"] != false {
+		t.Errorf("Expected persisted=false, got %v", body["persisted"])
+	}
+
+	if health, _ := s.GetLatestFilterHealth("stm32_post"); health != nil {
+		t.Error("Expected filter health NOT to be saved with persist=false")
+	}
+}
+
+func TestFilterHealth_SavedUnderPostDeviceAndRetrievable(t *testing.T) {
+	s := store.NewStore(1000)
+	seedFilterReadings(s, time.Now().Add(-12*time.Hour), 40)
+	h := NewMLHandlers(s, nil)
+
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, path := range []string{"/api/v1/ml/filter/health?device_id=stm32_post", "/api/v1/ml/filter/health"} {
+		rec := httptest.NewRecorder()
+		h.GetFilterHealth(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var health models.FilterHealth
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", path, err)
+		}
+		if health.DeviceID != "stm32_post" || health.HealthScore == 0 {
+			t.Errorf("%s: expected saved stm32_post health, got %s", path, rec.Body.String())
+		}
+	}
+}
+
+func TestAnalyzeFilterHealth_DeviceIDOverride(t *testing.T) {
+	s := store.NewStore(1000)
+	base := time.Now().Add(-12 * time.Hour)
+	for i := 0; i < 40; i++ {
+		ts := base.Add(time.Duration(i) * 10 * time.Minute)
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 6.5, Turbidity: 10.0, TDS: 300,
+		})
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_main", Timestamp: ts.Add(5 * time.Second), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 7.0, Turbidity: 1.0, TDS: 50,
+		})
+	}
+	h := NewMLHandlers(s, nil)
+
+	rec := httptest.NewRecorder()
+	h.AnalyzeFilterHealth(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?device_id=stm32_main", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetFilterHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/filter/health?device_id=stm32_main", nil))
+	var health models.FilterHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.DeviceID != "stm32_main" || health.HealthScore == 0 {
+		t.Errorf("Expected health recorded under stm32_main, got %s", rec.Body.String())
+	}
+
+	if other, err := s.GetLatestFilterHealth("stm32_post"); err != nil || other != nil {
+		t.Errorf("Expected nothing recorded for the default post device, got %v (%v)", other, err)
+	}
+}
+
+func TestGetFilterHealth_FallsBackToLegacyDeviceID(t *testing.T) {
+	s := store.NewStore(100)
+	if err := s.SaveFilterHealth(&models.FilterHealth{DeviceID: "filter_system", HealthScore: 64}); err != nil {
+		t.Fatalf("Failed to save health: %v", err)
+	}
+	h := NewMLHandlers(s, nil)
+
+	rec := httptest.NewRecorder()
+	h.GetFilterHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/filter/health", nil))
+
+	var health models.FilterHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.DeviceID != "filter_system" || health.HealthScore != 64 {
+		t.Errorf("Expected legacy filter_system health, got %s", rec.Body.String())
+	}
+}
+
+func TestNewFilterHealthResponse_FreshVsStale(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		calculated time.Time
+		staleAfter time.Duration
+		wantStale  bool
+		wantAge    int64
+	}{
+		{"just calculated", now, 2 * time.Hour, false, 0},
+		{"at the threshold", now.Add(-2 * time.Hour), 2 * time.Hour, false, 7200},
+		{"past the threshold", now.Add(-3 * time.Hour), 2 * time.Hour, true, 10800},
+		{"threshold disabled", now.Add(-72 * time.Hour), 0, false, 259200},
+		{"clock ahead", now.Add(time.Minute), 2 * time.Hour, false, 0},
+	}
+	for _, tt := range tests {
+		response := newFilterHealthResponse(&models.FilterHealth{LastCalculated: tt.calculated}, now, tt.staleAfter)
+		if response.IsStale != tt.wantStale || response.LastCalculatedAgeSeconds != tt.wantAge {
+			t.Errorf("%s: expected stale=%v age=%d, got stale=%v age=%d", tt.name, tt.wantStale, tt.wantAge,
+				response.IsStale, response.LastCalculatedAgeSeconds)
+		}
+	}
+}
+
+func TestGetFilterHealth_FlagsStaleAssessment(t *testing.T) {
+	s := store.NewStore(100)
+	h := NewMLHandlers(s, nil)
+	h.healthStaleAfter = time.Hour
+
+	for _, tt := range []struct {
+		age       time.Duration
+		wantStale bool
+	}{{5 * time.Minute, false}, {3 * time.Hour, true}} {
+		s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", HealthScore: 80, LastCalculated: time.Now().Add(-tt.age)})
+
+		rec := httptest.NewRecorder()
+		h.GetFilterHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/filter/health?device_id=stm32_post", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["is_stale"] != tt.wantStale || body["health_score"] != 80.0 {
+			t.Errorf("%s old: expected is_stale=%v alongside the health fields, got %s", tt.age, tt.wantStale, rec.Body.String())
+		}
+		if age, _ := body["last_calculated_age_seconds"].(float64); age < tt.age.Seconds()-1 {
+			t.Errorf("%s old: expected last_calculated_age_seconds of about %v, got %v", tt.age, tt.age.Seconds(), age)
+		}
+	}
+}
+
+func TestGetFilterHealth_IncludesRecentMaintenanceOfDevice(t *testing.T) {
+	s := store.NewStore(100)
+	h := NewMLHandlers(s, nil)
+	s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", HealthScore: 80, LastCalculated: time.Now()})
+
+	getMaintenance := func() []models.MaintenanceEvent {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.GetFilterHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/filter/health?device_id=stm32_post", nil))
+		var body struct {
+			RecentMaintenance []models.MaintenanceEvent `json:"recent_maintenance"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body.RecentMaintenance
+	}
+
+	if maintenance := getMaintenance(); len(maintenance) != 0 {
+		t.Errorf("Expected no maintenance before any was logged, got %v", maintenance)
+	}
+
+	// Another device's maintenance isn't shown, and only the latest events are
+	s.AddMaintenanceEvent(&models.MaintenanceEvent{DeviceID: "stm32_pre", Type: models.MaintenanceCleaning, PerformedBy: "technician"})
+	for i := 0; i <= filterHealthMaintenanceLimit; i++ {
+		s.AddMaintenanceEvent(&models.MaintenanceEvent{DeviceID: "stm32_post", Type: models.MaintenanceCleaning, PerformedBy: "technician " + strconv.Itoa(i)})
+	}
+
+	maintenance := getMaintenance()
+	if len(maintenance) != filterHealthMaintenanceLimit {
+		t.Fatalf("Expected the latest %d maintenance events, got %d", filterHealthMaintenanceLimit, len(maintenance))
+	}
+	for _, event := range maintenance {
+		if event.DeviceID != "stm32_post" {
+			t.Errorf("Expected only the health's device maintenance, got %+v", event)
+		}
+	}
+	if want := "technician " + strconv.Itoa(filterHealthMaintenanceLimit); maintenance[0].PerformedBy != want {
+		t.Errorf("Expected the most recent event first (%s), got %s", want, maintenance[0].PerformedBy)
+	}
+}
+
+func TestAnalyzeFilterHealth_InvalidWindow(t *testing.T) {
+	h := NewMLHandlers(store.NewStore(100), nil)
+
+	for _, window := range []string{"-5", "0", "abc", "1001"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter/analyze?window="+window, nil)
+		rec := httptest.NewRecorder()
+		h.AnalyzeFilterHealth(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("window=%s: expected status 400, got %d", window, rec.Code)
+		}
+	}
+}
+
+func TestGetPredictionAccuracyHistory_SortedChronologically(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Saved out of order, plus one summary for another device
+	for _, day := range []int{3, 1, 2} {
+		s.SavePredictionAccuracySummary(&models.PredictionAccuracySummary{
+			DeviceID:        "stm32_post",
+			FilterMode:      models.FilterModeDrinking,
+			PeriodStart:     base.AddDate(0, 0, day),
+			PeriodEnd:       base.AddDate(0, 0, day+1),
+			OverallAccuracy: float64(80 + day),
+		})
+	}
+	s.SavePredictionAccuracySummary(&models.PredictionAccuracySummary{
+		DeviceID:    "stm32_pre",
+		FilterMode:  models.FilterModeDrinking,
+		PeriodStart: base,
+		PeriodEnd:   base.AddDate(0, 0, 1),
+	})
+
+	h := NewMLHandlers(s, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ml/predictions/accuracy/history?device_id=stm32_post", nil)
+	rec := httptest.NewRecorder()
+	h.GetPredictionAccuracyHistory(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Count   int                                `json:"count"`
+		History []models.PredictionAccuracySummary `json:"history"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body.Count != 3 || len(body.History) != 3 {
+		t.Fatalf("Expected 3 periods for stm32_post, got %d", len(body.History))
+	}
+	for i := 1; i < len(body.History); i++ {
+		if !body.History[i-1].PeriodStart.Before(body.History[i].PeriodStart) {
+			t.Errorf("Expected periods sorted chronologically, got %v before %v",
+				body.History[i-1].PeriodStart, body.History[i].PeriodStart)
+		}
+	}
+	if body.History[0].OverallAccuracy != 81 {
+		t.Errorf("Expected oldest period first, got accuracy %.0f", body.History[0].OverallAccuracy)
+	}
+}
+
+func TestGetPredictionAccuracy_LatestValidatedSummaries(t *testing.T) {
+	s := store.NewStore(100)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day, accuracy := range []float64{70, 80} {
+		s.SavePredictionAccuracySummary(&models.PredictionAccuracySummary{
+			DeviceID:        "stm32_post",
+			FilterMode:      models.FilterModeDrinking,
+			PeriodStart:     base.AddDate(0, 0, day),
+			PeriodEnd:       base.AddDate(0, 0, day+1),
+			OverallAccuracy: accuracy,
+		})
+	}
+
+	// Validating a prediction saves the summary the endpoint reports
+	predictedFor := time.Now().Add(-time.Hour)
+	s.SaveSensorPrediction(&models.SensorPrediction{
+		DeviceID: "stm32_pre", FilterMode: models.FilterModeDrinking, PredictedFor: predictedFor,
+		PredictedFlow: 2, PredictedPh: 7, PredictedTurbidity: 1, PredictedTDS: 100,
+	})
+	s.AddSensorReading(models.SensorReading{
+		DeviceID: "stm32_pre", Timestamp: predictedFor, FilterMode: models.FilterModeDrinking,
+		Flow: 2, Ph: 7, Turbidity: 1, TDS: 100,
+	})
+	ml.NewMLService(s).ValidatePredictions()
+
+	h := NewMLHandlers(s, nil)
+	rec := httptest.NewRecorder()
+	h.GetPredictionAccuracy(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/predictions/accuracy", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Count     int                                `json:"count"`
+		Summaries []models.PredictionAccuracySummary `json:"summaries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Count != 2 || len(body.Summaries) != 2 {
+		t.Fatalf("Expected the latest summary of each device, got %+v", body.Summaries)
+	}
+	for _, summary := range body.Summaries {
+		switch summary.DeviceID {
+		case "stm32_post":
+			if summary.OverallAccuracy != 80 {
+				t.Errorf("Expected the latest stm32_post period, got %+v", summary)
+			}
+		case "stm32_pre":
+			if summary.ValidatedPredictions != 1 || summary.OverallAccuracy != 100 {
+				t.Errorf("Expected the validated prediction summarized, got %+v", summary)
+			}
+		}
+	}
+}
+
+func TestGetMLDashboard_LiveDeviation(t *testing.T) {
+	s := store.NewStore(10)
+	now := time.Now()
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: now, FilterMode: models.FilterModeDrinking, Ph: 7.6, TDS: 300})
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: now, FilterMode: models.FilterModeDrinking, Ph: 7.0, TDS: 50})
+	s.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_pre", FilterMode: models.FilterModeDrinking,
+		PhMean: 7.0, PhStdDev: 0.2, TDSMean: 300, TDSStdDev: 30,
+	})
+
+	h := NewMLHandlers(s, nil)
+	rec := httptest.NewRecorder()
+	h.GetMLDashboard(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/dashboard", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		LiveDeviation map[string]struct {
+			BaselineAvailable bool `json:"baseline_available"`
+			Metrics           []struct {
+				Metric string   `json:"metric"`
+				ZScore *float64 `json:"z_score"`
+			} `json:"metrics"`
+		} `json:"live_deviation"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	pre := body.LiveDeviation["stm32_pre"]
+	if !pre.BaselineAvailable {
+		t.Fatal("Expected stm32_pre to have a baseline")
+	}
+	for _, metric := range pre.Metrics {
+		if metric.Metric == "ph" && (metric.ZScore == nil || *metric.ZScore < 2.99 || *metric.ZScore > 3.01) {
+			t.Errorf("Expected pH z-score of 3, got %v", metric.ZScore)
+		}
+	}
+
+	post, ok := body.LiveDeviation["stm32_post"]
+	if !ok || post.BaselineAvailable || len(post.Metrics) != 0 {
+		t.Errorf("Expected stm32_post without a baseline to report baseline_available=false, got %+v", post)
+	}
+}
+
+func TestGetMLDashboard_RecentlyResolvedAnomalies(t *testing.T) {
+	s := store.NewStore(10)
+	ids := make([]int, 4)
+	for i := range ids {
+		anomaly := &models.AnomalyDetection{
+			DeviceID:       "stm32_post",
+			DetectedAt:     time.Now().Add(-time.Hour),
+			AnomalyType:    "spike",
+			Severity:       "medium",
+			AffectedMetric: "tds",
+		}
+		if err := s.SaveAnomaly(anomaly); err != nil {
+			t.Fatalf("Failed to save anomaly: %v", err)
+		}
+		ids[i] = anomaly.ID
+	}
+	s.ResolveAnomaly(ids[0])
+	s.AutoResolveAnomaly(ids[1])
+	s.MarkAnomalyFalsePositive(ids[2])
+
+	type dashboard struct {
+		Anomalies struct {
+			UnresolvedCount  int                       `json:"unresolved_count"`
+			RecentlyResolved []models.AnomalyDetection `json:"recently_resolved"`
+		} `json:"anomalies"`
+	}
+	get := func(h *MLHandlers) dashboard {
+		rec := httptest.NewRecorder()
+		h.GetMLDashboard(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/dashboard", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		var body dashboard
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body
+	}
+
+	h := NewMLHandlers(s, nil)
+	body := get(h)
+	resolved := body.Anomalies.RecentlyResolved
+	if body.Anomalies.UnresolvedCount != 1 {
+		t.Errorf("Expected 1 unresolved anomaly, got %d", body.Anomalies.UnresolvedCount)
+	}
+	if len(resolved) != 2 {
+		t.Fatalf("Expected the 2 resolved anomalies without the false positive, got %+v", resolved)
+	}
+	if resolved[0].ID != ids[1] || !resolved[0].AutoResolved || resolved[0].ResolvedAt == nil {
+		t.Errorf("Expected the auto-resolved anomaly first, got %+v", resolved[0])
+	}
+	if resolved[1].ID != ids[0] || resolved[1].AutoResolved || resolved[1].ResolvedAt == nil {
+		t.Errorf("Expected the manually resolved anomaly second, got %+v", resolved[1])
+	}
+
+	h.resolvedLimit = 1
+	if resolved := get(h).Anomalies.RecentlyResolved; len(resolved) != 1 || resolved[0].ID != ids[1] {
+		t.Errorf("Expected only the latest resolution with a limit of 1, got %+v", resolved)
+	}
+	h.resolvedLimit = 0
+	if resolved := get(h).Anomalies.RecentlyResolved; resolved == nil || len(resolved) != 0 {
+		t.Errorf("Expected an empty list with a limit of 0, got %+v", resolved)
+	}
+}
+
+func TestGetAnomalyContext_WindowCenteredOnAnomaly(t *testing.T) {
+	s := store.NewStore(200)
+	base := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking, TDS: float64(i)})
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: ts, FilterMode: models.FilterModeDrinking, TDS: 1000})
+	}
+
+	anomaly := &models.AnomalyDetection{
+		DeviceID:       "stm32_pre",
+		DetectedAt:     base.Add(15*time.Minute + 30*time.Second),
+		AnomalyType:    "spike",
+		Severity:       "high",
+		AffectedMetric: "tds",
+	}
+	s.SaveAnomaly(anomaly)
+
+	r := chi.NewRouter()
+	r.Get("/ml/anomalies/{id}/context", NewMLHandlers(s, nil).GetAnomalyContext)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ml/anomalies/"+strconv.Itoa(anomaly.ID)+"/context?before=3&after=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Anomaly models.AnomalyDetection `json:"anomaly"`
+		Before  []models.SensorReading  `json:"before"`
+		After   []models.SensorReading  `json:"after"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body.Anomaly.ID != anomaly.ID {
+		t.Errorf("Expected anomaly %d, got %d", anomaly.ID, body.Anomaly.ID)
+	}
+	wantBefore := []float64{13, 14, 15}
+	wantAfter := []float64{16, 17}
+	if len(body.Before) != len(wantBefore) || len(body.After) != len(wantAfter) {
+		t.Fatalf("Expected %d readings before and %d after, got %d and %d", len(wantBefore), len(wantAfter), len(body.Before), len(body.After))
+	}
+	for i, reading := range body.Before {
+		if reading.DeviceID != "stm32_pre" || reading.TDS != wantBefore[i] {
+			t.Errorf("Before[%d]: expected stm32_pre reading %.0f, got %s %.0f", i, wantBefore[i], reading.DeviceID, reading.TDS)
+		}
+	}
+	for i, reading := range body.After {
+		if reading.DeviceID != "stm32_pre" || reading.TDS != wantAfter[i] {
+			t.Errorf("After[%d]: expected stm32_pre reading %.0f, got %s %.0f", i, wantAfter[i], reading.DeviceID, reading.TDS)
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/ml/anomalies/999/context":                                        http.StatusNotFound,
+		"/ml/anomalies/abc/context":                                        http.StatusBadRequest,
+		"/ml/anomalies/" + strconv.Itoa(anomaly.ID) + "/context?before=-1": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestSetFilterHealth_ManualHealthRetrievable(t *testing.T) {
+	s := store.NewStore(100)
+	h := NewMLHandlers(s, nil)
+	r := chi.NewRouter()
+	r.With(RequireAdminToken("secret")).Post("/ml/filter-health", h.SetFilterHealth)
+	r.Get("/ml/filter/health", h.GetFilterHealth)
+
+	post := func(token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/ml/filter-health", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	payload := `{"device_id":"stm32_post","health_score":42.5,"predicted_days_remaining":12}`
+	if code := post("", payload); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+	if code := post("wrong", payload); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", code)
+	}
+	for _, invalid := range []string{
+		`{"health_score":120,"predicted_days_remaining":12}`,
+		`{"health_score":50,"predicted_days_remaining":-1}`,
+	} {
+		if code := post("secret", invalid); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", invalid, code)
+		}
+	}
+	if code := post("secret", payload); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ml/filter/health?device_id=stm32_post", nil))
+	var health models.FilterHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.HealthScore != 42.5 || health.PredictedDaysRemaining != 12 {
+		t.Errorf("Expected manually set health, got %s", rec.Body.String())
+	}
+	if !health.MaintenanceRequired {
+		t.Error("Expected maintenance_required for a score below 75")
+	}
+}
+
+func TestCalculateBaselines_ReportsEachCombination(t *testing.T) {
+	s := store.NewStore(1000)
+	seedFilterReadings(s, time.Now().Add(-12*time.Hour), 12)
+	for i := 0; i < 3; i++ {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: time.Now().Add(-time.Duration(i) * time.Minute),
+			FilterMode: models.FilterModeHousehold, Flow: 2.0, Ph: 7.0, Turbidity: 1.0, TDS: 50,
+		})
+	}
+	h := NewMLHandlers(s, nil)
+
+	rec := httptest.NewRecorder()
+	h.CalculateBaselines(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/baselines/calculate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Created int              `json:"baselines_created"`
+		Failed  int              `json:"baselines_failed"`
+		Results []baselineResult `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body.Created != 2 || body.Failed != 4 || len(body.Results) != 6 {
+		t.Fatalf("Expected 2 created and 4 failed of 6, got %d/%d of %d", body.Created, body.Failed, len(body.Results))
+	}
+	for _, result := range body.Results {
+		wantStatus, wantSamples := "insufficient_data", 0
+		if result.FilterMode == models.FilterModeDrinking && result.DeviceID != "stm32_main" {
+			wantStatus, wantSamples = "created", 12
+		} else if result.DeviceID == "stm32_post" {
+			wantSamples = 3
+		}
+		if result.Status != wantStatus || result.SampleSize != wantSamples {
+			t.Errorf("%s/%s: expected %s with %d samples, got %s with %d",
+				result.DeviceID, result.FilterMode, wantStatus, wantSamples, result.Status, result.SampleSize)
+		}
+		if wantStatus == "insufficient_data" && result.Error == "" {
+			t.Errorf("%s/%s: expected a reason for the missing baseline", result.DeviceID, result.FilterMode)
+		}
+	}
+}
+
+// recordingBroadcaster counts filter health broadcasts
+type recordingBroadcaster struct {
+	broadcasts []*models.FilterHealth
+}
+
+func (b *recordingBroadcaster) BroadcastFilterHealth(health *models.FilterHealth) {
+	b.broadcasts = append(b.broadcasts, health)
+}
+
+func TestRunFilterHealthAnalysis_PersistsAndBroadcastsOnce(t *testing.T) {
+	s := store.NewStore(1000)
+	mlService := ml.NewMLService(s)
+	broadcaster := &recordingBroadcaster{}
+	mlService.SetFilterHealthBroadcaster(broadcaster)
+	h := NewMLHandlers(s, mlService)
+
+	analyze := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.RunFilterHealthAnalysis(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ml/filter-health/analyze", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rec.Code, body
+	}
+
+	// Not enough readings yet: details are reported and nothing is recorded
+	seedFilterReadings(s, time.Now().Add(-12*time.Hour), 5)
+	code, body := analyze()
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 with insufficient data, got %d: %v", code, body)
+	}
+	if body["pre_readings"] != 5.0 || body["post_readings"] != 5.0 || body["required"] != 20.0 {
+		t.Errorf("Expected reading counts in the response, got %v", body)
+	}
+	if len(broadcaster.broadcasts) != 0 {
+		t.Errorf("Expected no broadcast without an analysis, got %d", len(broadcaster.broadcasts))
+	}
+
+	seedFilterReadings(s, time.Now().Add(-6*time.Hour), 30)
+	code, body = analyze()
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", code, body)
+	}
+
+	history, err := s.GetFilterHealthHistory("stm32_post", 10)
+	if err != nil {
+		t.Fatalf("Failed to get filter health history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("Expected the analysis to be persisted once, got %d records", len(history))
+	}
+	if len(broadcaster.broadcasts) != 1 {
+		t.Fatalf("Expected exactly one broadcast, got %d", len(broadcaster.broadcasts))
+	}
+
+	health := body["health"].(map[string]interface{})
+	if health["device_id"] != "stm32_post" || health["health_score"] != broadcaster.broadcasts[0].HealthScore {
+		t.Errorf("Expected the fresh record to be returned and broadcast, got %v", health)
+	}
+}
+
+func TestAnomalyConfig_TogglesRealTimeDetection(t *testing.T) {
+	s := store.NewStore(100)
+	if err := s.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 100,
+		FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+		TurbidityMean: 1.0, TurbidityStdDev: 0.1, TDSMean: 50, TDSStdDev: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+	mlService := ml.NewMLService(s)
+	h := NewMLHandlers(s, mlService)
+
+	call := func(handler http.HandlerFunc, method, body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/api/v1/ml/anomaly/config", strings.NewReader(body)))
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rec.Code, response
+	}
+	detectedAfterReading := func() int {
+		mlService.ProcessNewReading(&models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 9.0, Turbidity: 1.0, TDS: 50,
+		})
+		anomalies, err := s.GetAnomaliesByDevice("stm32_post", 100)
+		if err != nil {
+			t.Fatalf("Failed to get anomalies: %v", err)
+		}
+		return len(anomalies)
+	}
+
+	if code, body := call(h.GetAnomalyConfig, http.MethodGet, ""); code != http.StatusOK || body["enabled"] != false {
+		t.Fatalf("Expected detection disabled by default, got %d: %v", code, body)
+	}
+	if detected := detectedAfterReading(); detected != 0 {
+		t.Errorf("Expected no anomalies while disabled, got %d", detected)
+	}
+
+	if code, body := call(h.UpdateAnomalyConfig, http.MethodPost, `{"enabled":true}`); code != http.StatusOK || body["enabled"] != true {
+		t.Fatalf("Expected detection to be enabled, got %d: %v", code, body)
+	}
+	if detected := detectedAfterReading(); detected == 0 {
+		t.Error("Expected the anomalous reading to be detected once enabled")
+	}
+
+	if code, _ := call(h.UpdateAnomalyConfig, http.MethodPost, `{}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without enabled, got %d", code)
+	}
+	if _, body := call(h.GetAnomalyConfig, http.MethodGet, ""); body["enabled"] != true {
+		t.Errorf("Expected the invalid request to leave detection enabled, got %v", body["enabled"])
+	}
+}
+
+func TestGetFilterEconomics_RequiresMetadataAndUsesProcessedVolume(t *testing.T) {
+	s := store.NewStore(1000)
+	// 2 L/min read every 5 minutes for 2 hours = 240 L; the flow over the 20
+	// minute gap before the last reading is unknown, so it isn't counted
+	base := time.Now().Add(-150 * time.Minute)
+	addReading := func(minutes int) {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: base.Add(time.Duration(minutes) * time.Minute), FilterMode: models.FilterModeDrinking, Flow: 2.0,
+		})
+	}
+	for minutes := 0; minutes <= 120; minutes += 5 {
+		addReading(minutes)
+	}
+	addReading(140)
+	handlers := NewHandlers(s, nil, nil, nil)
+	mlHandlers := NewMLHandlers(s, nil)
+	r := chi.NewRouter()
+	r.Put("/devices/{deviceID}/filter", handlers.SetDeviceFilter)
+	r.Get("/ml/filter-economics", mlHandlers.GetFilterEconomics)
+
+	get := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ml/filter-economics?device_id=stm32_post", nil))
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	if code, _ := get(); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without filter metadata, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/devices/stm32_post/filter", strings.NewReader(`{"capacity_liters":0,"cost":30}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for a zero capacity, got %d", rec.Code)
+	}
+
+	installed := time.Now().Add(-3 * time.Hour).Format(time.RFC3339)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/devices/stm32_post/filter",
+		strings.NewReader(`{"capacity_liters":1200,"cost":30,"installed_at":"`+installed+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the filter metadata to be saved, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	code, body := get()
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", code, body)
+	}
+	if body["processed_liters"] != 240.0 || body["liters_remaining"] != 960.0 {
+		t.Errorf("Expected 240 L processed and 960 L remaining, got %v and %v", body["processed_liters"], body["liters_remaining"])
+	}
+	if body["cost_per_liter_so_far"] != 0.125 || body["projected_cost_per_liter"] != 0.025 {
+		t.Errorf("Expected 0.125 so far and 0.025 projected per liter, got %v and %v",
+			body["cost_per_liter_so_far"], body["projected_cost_per_liter"])
+	}
+}
+
+func TestGetPredictionsVsActual_AlignsValidatedPredictions(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	// Hourly TDS forecast from 3 hours ago to 2 hours ahead; readings measured
+	// near the first two predicted times but none near the third
+	for step := -3; step <= 2; step++ {
+		s.SaveSensorPrediction(&models.SensorPrediction{
+			DeviceID:        "stm32_pre",
+			FilterMode:      models.FilterModeDrinking,
+			PredictedFor:    now.Add(time.Duration(step) * time.Hour),
+			PredictedTDS:    float64(200 + 10*step),
+			ConfidenceScore: 0.9,
+		})
+	}
+	s.SaveSensorPrediction(&models.SensorPrediction{
+		DeviceID:     "stm32_pre",
+		FilterMode:   models.FilterModeHousehold,
+		PredictedFor: now.Add(-3 * time.Hour),
+		PredictedTDS: 999,
+	})
+	for _, reading := range []struct {
+		offset time.Duration
+		tds    float64
+	}{{-3*time.Hour + time.Minute, 175}, {-2*time.Hour - 2*time.Minute, 182}, {-time.Hour + 20*time.Minute, 500}} {
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   "stm32_pre",
+			Timestamp:  now.Add(reading.offset),
+			FilterMode: models.FilterModeDrinking,
+			TDS:        reading.tds,
+		})
+	}
+	ml.NewMLService(s).ValidatePredictions()
+
+	h := NewMLHandlers(s, nil)
+	get := func(query string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.GetPredictionsVsActual(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/predictions/vs-actual?"+query, nil))
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	start := now.Add(-4 * time.Hour).Format(time.RFC3339)
+	end := now.Add(4 * time.Hour).Format(time.RFC3339)
+	code, body := get("device_id=stm32_pre&metric=tds&start=" + start + "&end=" + end)
+	if code != http.StatusOK || body["count"] != 6.0 || body["validated"] != 2.0 {
+		t.Fatalf("Expected 6 drinking water points with 2 validated, got %d: %v", code, body)
+	}
+
+	points := body["points"].([]interface{})
+	wantActual := []interface{}{175.0, 182.0, nil, nil, nil, nil}
+	for i, raw := range points {
+		point := raw.(map[string]interface{})
+		wantFor := now.Add(time.Duration(i-3) * time.Hour).Format(time.RFC3339)
+		if point["predicted_for"] != wantFor || point["predicted"] != float64(200+10*(i-3)) {
+			t.Errorf("Point %d: expected the prediction for %s, got %v", i, wantFor, point)
+		}
+		if point["actual"] != wantActual[i] {
+			t.Errorf("Point %d: expected actual %v, got %v", i, wantActual[i], point["actual"])
+		}
+		if point["is_validated"] != (wantActual[i] != nil) {
+			t.Errorf("Point %d: expected is_validated %v, got %v", i, wantActual[i] != nil, point["is_validated"])
+		}
+	}
+
+	if code, body := get("device_id=stm32_pre&metric=tds&filter_mode=household_water&start=" + start + "&end=" + end); code != http.StatusOK || body["count"] != 1.0 {
+		t.Errorf("Expected the household forecast only, got %d: %v", code, body)
+	}
+	// Without a device ID the pre-filtration device is used, following overrides
+	s.SetDeviceTypeOverride("inlet_1", models.DeviceTypePreFiltration)
+	s.SaveSensorPrediction(&models.SensorPrediction{DeviceID: "inlet_1", FilterMode: models.FilterModeDrinking, PredictedFor: now, PredictedTDS: 150})
+	if code, body := get("metric=tds&start=" + start + "&end=" + end); code != http.StatusOK || body["count"] != 1.0 {
+		t.Errorf("Expected the overridden pre-filtration device's forecast, got %d: %v", code, body)
+	}
+
+	for _, query := range []string{"metric=temperature", "metric=tds&start=bad", "metric=tds&start=" + end + "&end=" + start} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestGetWaterEfficiency_ReportsRatioAndMissingData(t *testing.T) {
+	s := store.NewStore(1000)
+	h := NewMLHandlers(s, nil)
+	get := func() map[string]interface{} {
+		rec := httptest.NewRecorder()
+		h.GetWaterEfficiency(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/water-efficiency", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return body
+	}
+
+	// Only post-filtration readings: the input flow is unknown
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: time.Now().Add(-time.Hour), FilterMode: models.FilterModeDrinking, Flow: 2})
+	body := get()
+	efficiency := body["efficiency"].(map[string]interface{})
+	if efficiency["status"] != models.WaterEfficiencyInsufficient || efficiency["recovery_ratio"] != nil {
+		t.Errorf("Expected insufficient data without pre readings, got %v", efficiency)
+	}
+	if !strings.Contains(body["message"].(string), "No pre-filtration readings") {
+		t.Errorf("Expected the missing pre readings to be explained, got %v", body["message"])
+	}
+
+	// Matched pairs with equal flow recover all the input
+	seedFilterReadings(s, time.Now().Add(-3*time.Hour), 5)
+	body = get()
+	efficiency = body["efficiency"].(map[string]interface{})
+	if efficiency["pair_count"] != 5.0 || efficiency["recovery_ratio"] != 1.0 || efficiency["status"] != models.WaterEfficiencyOK {
+		t.Errorf("Expected 5 pairs recovering everything, got %v", efficiency)
+	}
+}
+
+func TestGetPredictions_MinConfidenceExcludesLateHorizon(t *testing.T) {
+	s := store.NewStore(1000)
+	now := time.Now()
+	var history []models.SensorReading
+	for i := 59; i >= 0; i-- {
+		history = append(history, models.SensorReading{
+			DeviceID: "stm32_pre", Timestamp: now.Add(-time.Duration(i) * 10 * time.Minute), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0 + 0.1*float64(i%3), Ph: 7.2, Turbidity: 1.5, TDS: 200 + float64(i%5),
+		})
+	}
+	forecasts, err := ml.NewSensorPredictor().PredictSensorValues(history, "stm32_pre", models.FilterModeDrinking)
+	if err != nil {
+		t.Fatalf("Failed to predict: %v", err)
+	}
+	for _, forecast := range forecasts {
+		s.SaveSensorPrediction(&models.SensorPrediction{
+			DeviceID: "stm32_pre", FilterMode: models.FilterModeDrinking,
+			PredictedFor: forecast.Timestamp, ConfidenceScore: forecast.ConfidenceScore, PredictedTDS: forecast.PredictedTDS,
+		})
+	}
+	// Another device's predictions are never listed
+	s.SaveSensorPrediction(&models.SensorPrediction{DeviceID: "stm32_post", PredictedFor: now.Add(time.Hour), ConfidenceScore: 0.95})
+
+	h := NewMLHandlers(s, nil)
+	list := func(query string) []models.SensorPrediction {
+		rec := httptest.NewRecorder()
+		h.GetPredictions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/predictions?device_id=stm32_pre&"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Predictions []models.SensorPrediction `json:"predictions"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return body.Predictions
+	}
+
+	all := list("min_confidence=0")
+	if len(all) != len(forecasts) {
+		t.Fatalf("Expected all %d predictions without a minimum, got %d", len(forecasts), len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].PredictedFor.Before(all[i-1].PredictedFor) || all[i].ConfidenceScore > all[i-1].ConfidenceScore {
+			t.Fatalf("Expected predictions by predicted_for with decaying confidence, got %+v then %+v", all[i-1], all[i])
+		}
+	}
+
+	// Raising the minimum drops the far end of the horizon, keeping the near end
+	threshold := all[len(all)/2].ConfidenceScore
+	confident := list("min_confidence=" + strconv.FormatFloat(threshold, 'f', -1, 64))
+	if len(confident) == 0 || len(confident) >= len(all) {
+		t.Fatalf("Expected some but not all predictions at confidence %.3f, got %d of %d", threshold, len(confident), len(all))
+	}
+	for i, prediction := range confident {
+		if prediction.ConfidenceScore < threshold || !prediction.PredictedFor.Equal(all[i].PredictedFor) {
+			t.Errorf("Expected the %d soonest predictions, got %+v at %d", len(confident), prediction, i)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetPredictions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/predictions?min_confidence=1.5", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a confidence above 1, got %d", rec.Code)
+	}
+}
+
+func TestMLEndpoints_EmptyStoreReportInsufficientData(t *testing.T) {
+	h := NewMLHandlers(store.NewStore(100), nil)
+	r := chi.NewRouter()
+	r.Get("/ml/filter/health", h.GetFilterHealth)
+	r.Post("/ml/filter/analyze", h.AnalyzeFilterHealth)
+	r.Post("/ml/predictions/generate", h.GeneratePredictions)
+	r.Get("/ml/anomalies", h.GetAnomalies)
+	r.Get("/ml/anomalies/unresolved", h.GetUnresolvedAnomalies)
+	r.Get("/ml/dashboard", h.GetMLDashboard)
+
+	request := func(t *testing.T, method, path string) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body
+	}
+
+	for _, endpoint := range []struct{ method, path string }{
+		{http.MethodGet, "/ml/filter/health"},
+		{http.MethodPost, "/ml/filter/analyze"},
+		{http.MethodPost, "/ml/predictions/generate"},
+	} {
+		t.Run(endpoint.path, func(t *testing.T) {
+			body := request(t, endpoint.method, endpoint.path)
+			if body["status"] != insufficientDataStatus || body["message"] == "" || body["message"] == nil {
+				t.Errorf("Expected an insufficient data status and message, got %v", body)
+			}
+		})
+	}
+
+	t.Run("/ml/filter/analyze counts", func(t *testing.T) {
+		body := request(t, http.MethodPost, "/ml/filter/analyze")
+		if body["pre_readings"] != float64(0) || body["post_readings"] != float64(0) || body["required"] != float64(20) {
+			t.Errorf("Expected numeric reading counts, got %v", body)
+		}
+	})
+
+	for _, path := range []string{"/ml/anomalies", "/ml/anomalies?device_id=stm32_post", "/ml/anomalies/unresolved"} {
+		t.Run(path, func(t *testing.T) {
+			body := request(t, http.MethodGet, path)
+			if anomalies, ok := body["anomalies"].([]interface{}); !ok || len(anomalies) != 0 || body["count"] != float64(0) {
+				t.Errorf("Expected an empty anomaly list, got %v", body)
+			}
+		})
+	}
+
+	t.Run("/ml/dashboard", func(t *testing.T) {
+		anomalies := request(t, http.MethodGet, "/ml/dashboard")["anomalies"].(map[string]interface{})
+		for _, key := range []string{"unresolved", "recent", "recently_resolved"} {
+			if list, ok := anomalies[key].([]interface{}); !ok || len(list) != 0 {
+				t.Errorf("Expected %s to be an empty list, got %v", key, anomalies[key])
+			}
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitIdleTTL is how long a client's bucket is kept after its last request
+const rateLimitIdleTTL = 10 * time.Minute
+
+// tokenBucket holds one client's tokens as of its last request
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is a per-client token bucket: each client gets burst tokens,
+// refilled at rps per second, and every request spends one. Buckets idle for
+// longer than rateLimitIdleTTL are swept periodically so memory stays bounded
+// by the number of recently active clients.
+type RateLimiter struct {
+	rps       float64
+	burst     float64
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket // key: client IP
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rps requests per second per client
+// with bursts of up to burst requests. A non-positive rps returns nil, which
+// allows every request; burst defaults to one second's worth of requests.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rps))
+	}
+	return &RateLimiter{
+		rps:       rps,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow spends a token for the client at now, reporting whether the request is
+// allowed and, when it isn't, how long until the next token is available
+func (l *RateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitIdleTTL {
+		l.sweep(now)
+	}
+
+	bucket, exists := l.buckets[client]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[client] = bucket
+	} else if elapsed := now.Sub(bucket.lastSeen); elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed.Seconds()*l.rps)
+		bucket.lastSeen = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rps * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets idle for longer than rateLimitIdleTTL; an idle bucket
+// has refilled completely, so dropping it doesn't change any decision
+func (l *RateLimiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimitIdleTTL {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Clients returns how many clients currently have a bucket
+func (l *RateLimiter) Clients() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For header is
+// believed when identifying clients
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses proxy IP addresses and CIDR ranges
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// contains reports whether addr is one of the trusted proxies
+func (p TrustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the originating client address of a request. The
+// X-Forwarded-For header is only believed when the request comes from a
+// trusted proxy; the client is then the last entry not added by one, since
+// anything further left could have been sent by the client itself.
+func clientIP(r *http.Request, proxies TrustedProxies) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !proxies.contains(host) {
+		return host
+	}
+
+	entries := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		if ip := strings.TrimSpace(entries[i]); ip != "" && !proxies.contains(ip) {
+			return ip
+		}
+	}
+	return host
+}
+
+// RateLimitMiddleware rejects requests from clients that exceeded the limiter
+// with 429 and a Retry-After header. Clients are told apart by IP, taken from
+// X-Forwarded-For only for requests through the trusted proxies. A nil limiter
+// allows every request.
+func RateLimitMiddleware(limiter *RateLimiter, proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowed, wait := limiter.Allow(clientIP(r, proxies), time.Now()); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeAPIError(w, http.StatusTooManyRequests, "Rate limit exceeded; slow down and retry later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
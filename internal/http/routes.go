@@ -1,31 +1,73 @@
 package http
 
 import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/Capstone-E1/aquasmart_backend/internal/mqtt"
 	"github.com/Capstone-E1/aquasmart_backend/internal/ml"
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 	"github.com/Capstone-E1/aquasmart_backend/internal/services"
 	"github.com/Capstone-E1/aquasmart_backend/internal/store"
 	"github.com/Capstone-E1/aquasmart_backend/internal/ws"
 )
 
+// RouterOptions holds tunable HTTP behaviour passed to SetupRoutes
+type RouterOptions struct {
+	ExportMaxRange     time.Duration             // Longest date range a single export may cover (0 = unlimited)
+	ExportDefaultDays  int                       // Days exported when no start date is given (<= 0 = 30)
+	FlowUnit           models.FlowUnit           // Unit exported flow rates are shown in ("" = L/min)
+	QueryWarnThreshold int                       // Log a warning when a request makes more store calls than this (0 = off)
+	QueryCountHeader   bool                      // Return the per-request store call count in X-Query-Count
+	ModeChangeCooldown *store.ModeChangeCooldown // Minimum interval between filter mode changes (nil = off)
+	AllReadingsLimit   int                       // Most readings loaded by "all data" endpoints (0 = unlimited)
+	AdminToken         string                    // Bearer token for operator-only endpoints ("" = disabled)
+	ClockSkew          *store.ClockSkewCorrector // Handling of readings with skewed device clocks (nil = off)
+	JWTSecret          string                    // HS256 secret required on /api/v1 routes ("" = no authentication)
+	TokenTTL           time.Duration             // Lifetime of tokens issued by the login endpoint (0 = 24h)
+	AdminUsername      string                    // Credential accepted by the login endpoint
+	AdminPassword      string
+	RateLimitRPS       float64                   // Requests per second allowed per client IP (0 = unlimited)
+	RateLimitBurst     int                       // Requests a client may burst above the steady rate
+	TrustedProxies     TrustedProxies            // Proxies whose X-Forwarded-For identifies the client for rate limiting
+	TargetVolumeMin    float64                   // Smallest filtration target volume in liters (0 = default)
+	TargetVolumeMax    float64                   // Largest filtration target volume in liters (0 = default)
+	FilterHealthStale  *time.Duration            // Age at which filter health is flagged as stale (nil = 2h, 0 = never)
+	ResolvedAnomalies  *int                      // Recently resolved anomalies on the ML dashboard (nil = 10, 0 = none)
+	ExpectedInterval   time.Duration             // How often each device should send a reading (0 = inferred)
+	StoreBreaker       *store.CircuitBreaker     // Circuit breaker guarding the data store (nil = none)
+	SafetyMaxAge       time.Duration             // Age at which drinking water data is stale (0 = 10m)
+	StalenessPolicy    models.StalenessPolicy    // Safe-to-drink verdict on stale data ("" = fail_safe)
+	Retention          *services.Retention       // Pruning of old data behind the admin prune endpoint (nil = disabled)
+}
+
 // SetupRoutes configures all HTTP routes for the water purification API
-func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.Scheduler, mqttClient *mqtt.Client, mlService *ml.MLService) *chi.Mux {
+func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.Scheduler, mqttClient *mqtt.Client, mlService *ml.MLService, deduplicator *store.ReadingDeduplicator, opts RouterOptions) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	// Rate limit before RealIP rewrites the remote address from client-supplied headers
+	if opts.RateLimitRPS > 0 {
+		r.Use(RateLimitMiddleware(NewRateLimiter(opts.RateLimitRPS, opts.RateLimitBurst), opts.TrustedProxies))
+	}
 	r.Use(middleware.RealIP)
+	if opts.QueryWarnThreshold > 0 || opts.QueryCountHeader {
+		r.Use(QueryCountMiddleware(opts.QueryWarnThreshold, opts.QueryCountHeader))
+	}
 
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // In production, specify allowed origins
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", DeviceKeyHeader},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -33,13 +75,56 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 
 	// Create handlers with scheduler, MQTT support, and ML service support
 	handlers := NewHandlers(dataStore, scheduler, mqttClient, mlService)
+	handlers.deduplicator = deduplicator
+	handlers.exportMaxRange = opts.ExportMaxRange
+	handlers.exportService.SetFlowUnit(opts.FlowUnit)
+	if opts.ExportDefaultDays > 0 {
+		handlers.exportDefaultDays = opts.ExportDefaultDays
+	}
+	handlers.modeCooldown = opts.ModeChangeCooldown
+	if opts.TargetVolumeMin > 0 {
+		handlers.targetVolumes.Min = opts.TargetVolumeMin
+	}
+	if opts.TargetVolumeMax > 0 {
+		handlers.targetVolumes.Max = opts.TargetVolumeMax
+	}
+	handlers.allReadingsLimit = opts.AllReadingsLimit
+	handlers.clockSkew = opts.ClockSkew
+	handlers.expectedInterval = opts.ExpectedInterval
+	handlers.jwtSecret = []byte(opts.JWTSecret)
+	handlers.tokenTTL = opts.TokenTTL
+	handlers.adminUsername = opts.AdminUsername
+	handlers.adminPassword = opts.AdminPassword
+	handlers.storeBreaker = opts.StoreBreaker
+	if opts.SafetyMaxAge > 0 {
+		handlers.safetyMaxAge = opts.SafetyMaxAge
+	}
+	if opts.StalenessPolicy.IsValid() {
+		handlers.stalenessPolicy = opts.StalenessPolicy
+	}
+	handlers.retention = opts.Retention
 	mlHandlers := NewMLHandlers(dataStore, mlService)
+	if opts.FilterHealthStale != nil {
+		mlHandlers.healthStaleAfter = *opts.FilterHealthStale
+	}
+	if opts.ResolvedAnomalies != nil {
+		mlHandlers.resolvedLimit = *opts.ResolvedAnomalies
+	}
 	// Health check endpoint (outside /api/v1 for simplicity)
 	r.Get("/health", handlers.HealthCheck)
 	r.Head("/health", handlers.HealthCheck)
 
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
+		// Bearer JWT required on everything except the public paths
+		r.Use(RequireJWT(opts.JWTSecret, opts.AdminToken))
+		if opts.StoreBreaker != nil {
+			r.Use(StoreBreakerMiddleware(opts.StoreBreaker))
+		}
+
+		r.Get("/health", handlers.HealthCheck)
+		r.Post("/auth/login", handlers.Login)
+
 		// System stats
 		r.Get("/stats", handlers.GetSystemStats)
 
@@ -48,12 +133,39 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 			// Latest readings
 			r.Get("/latest", handlers.GetLatestReadings)
 
+			// Long-poll for the next reading (for clients without WebSocket support)
+			r.Get("/latest/longpoll", handlers.GetLatestReadingLongPoll)
+
 			// Recent readings with optional filtering
 			r.Get("/recent", handlers.GetRecentReadings)
 
 			// Get all sensor data (with pagination and filters)
 			r.Get("/all", handlers.GetAllSensorData)
 
+			// Search readings with device/mode/time filters and value predicates
+			r.Get("/search", handlers.SearchSensorReadings)
+
+			// Day-of-week x hour-of-day averages of a metric
+			r.Get("/heatmap", handlers.GetMetricHeatmap)
+
+			// Distribution of a metric over equal-width bins
+			r.Get("/histogram", handlers.GetMetricHistogram)
+
+			// Min/max/average of a metric per hour or day
+			r.Get("/aggregate", handlers.GetMetricAggregates)
+
+			// Readings per minute per device compared to the expected rate
+			r.Get("/ingest-rate", handlers.GetIngestRate)
+
+			// Composite 0-100 water quality index of the latest readings
+			r.Get("/wqi", handlers.GetWaterQualityIndex)
+
+			// Bare WQI score and label of the latest drinking water reading (widgets)
+			r.Get("/score", handlers.GetWaterQualityScore)
+
+			// Distinct filter modes seen in the data with reading counts
+			r.Get("/modes", handlers.GetFilterModeCounts)
+
 			// Get all sensor data (simple format)
 			r.Get("/all/simple", handlers.GetAllSensorDataSimple)
 
@@ -63,15 +175,33 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 			// Historical data in time range
 			r.Get("/history", handlers.GetReadingsInRange)
 
+			// Dates with at least one reading, for date pickers
+			r.Get("/available-days", handlers.GetAvailableDays)
+
 			// Water quality status
 			r.Get("/quality", handlers.GetWaterQualityStatus)
 
-			// Add sensor data manually (for testing)
-			r.Post("/data", handlers.AddSensorData)
+			// Readings per overall quality label, grouped by filter mode or day
+			r.Get("/quality/breakdown", handlers.GetQualityBreakdown)
+
+			// Command poll for the STM32 (filter mode and LED; no JWT)
+			r.Get("/stm32/command", handlers.GetSTM32Command)
+
+			// Add sensor data (devices authenticate with X-Device-Key once keys are provisioned)
+			r.With(RequireDeviceKey(dataStore)).Post("/data", handlers.AddSensorData)
+
+			// Re-assess stored reading quality after the quality rules change
+			r.With(RequireAdminToken(opts.AdminToken)).Post("/quality/backfill", handlers.BackfillReadingQuality)
+
+			// Dry-run validation of a reading without storing it (firmware testing)
+			r.Post("/validate", handlers.ValidateSensorData)
 
 			// Delete all sensor data
 			r.Delete("/all", handlers.DeleteAllSensorData)
 
+			// Single safe/unsafe verdict for the latest drinking water reading
+			r.Get("/safe-to-drink", handlers.GetSafeToDrink)
+
 			// Best daily values for today
 			r.Get("/best-daily", handlers.GetBestDailyValues)
 
@@ -87,19 +217,42 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 		r.Route("/commands", func(r chi.Router) {
 			r.Get("/filter", handlers.GetFilterStatus)   // Get current filter status
 			r.Post("/filter", handlers.SetFilterMode)
+			r.Get("/filter/changes", handlers.GetFilterModeChanges) // Mode change audit log
+			r.Post("/led", handlers.SetLEDCommand)                  // LED command picked up by the STM32 poll
 		})
 
 		// Schedule management routes
 		r.Route("/schedules", func(r chi.Router) {
 			r.Get("/", handlers.GetAllSchedules)                  // List all schedules
 			r.Post("/", handlers.CreateSchedule)                  // Create new schedule
+			r.Get("/next", handlers.GetNextSchedule)              // Earliest upcoming execution
 			r.Get("/{id}", handlers.GetSchedule)                  // Get specific schedule
 			r.Put("/{id}", handlers.UpdateSchedule)               // Update schedule
 			r.Delete("/{id}", handlers.DeleteSchedule)            // Delete schedule
 			r.Post("/{id}/toggle", handlers.ToggleSchedule)       // Enable/disable schedule
+			r.Get("/{id}/preview", handlers.PreviewSchedule)      // Next N execution times
 			r.Get("/executions", handlers.GetScheduleExecutionHistory) // Execution history
 		})
 
+		// Device metadata
+		r.Route("/devices", func(r chi.Router) {
+			r.Get("/", handlers.GetDevices)                                                                       // Name, activation and state of every device
+			r.Get("/types", handlers.GetDeviceTypes)                                                              // Device ID -> pre/post/unknown classification
+			r.Get("/overview", handlers.GetDevicesOverview)                                                       // Status, quality and filter health of every device
+			r.Get("/{deviceID}", handlers.GetDevice)                                                              // A single device's status
+			r.Patch("/{deviceID}", handlers.UpdateDevice)                                                         // Rename or (de)activate a device
+			r.Put("/{deviceID}/type", handlers.SetDeviceType)                                                     // Override a device's classification
+			r.With(RequireAdminToken(opts.AdminToken)).Put("/{deviceID}/filter", handlers.SetDeviceFilter)        // Installed filter capacity, cost and install date
+			r.Get("/{deviceID}/alert-prefs", handlers.GetAlertPreferences)                                        // Anomaly metrics and severities muted for the device
+			r.Put("/{deviceID}/alert-prefs", handlers.SetAlertPreferences)                                        // Mute or unmute anomaly alerts
+			r.Get("/{deviceID}/commands", handlers.GetDeviceCommands)                                             // Recent commands sent to the device
+			r.Get("/{deviceID}/effective-config", handlers.GetEffectiveDeviceConfig)                              // Config in effect after overrides resolve
+			r.With(RequireAdminToken(opts.AdminToken)).Get("/{deviceID}/ml-profile", handlers.GetDeviceMLProfile) // Every ML section for support sessions
+			r.Post("/{deviceID}/maintenance", handlers.AddMaintenanceEvent)                                       // Log maintenance performed on the device
+			r.Get("/{deviceID}/maintenance", handlers.GetMaintenanceEvents)                                       // Maintenance log, most recent first
+			r.With(RequireAdminToken(opts.AdminToken)).Post("/{deviceID}/key", handlers.RegisterDeviceKey)        // Generate an ingestion API key
+		})
+
 		// ML Features - Anomaly Detection & Filter Lifespan Prediction
 		r.Route("/ml", func(r chi.Router) {
 			// Dashboard - Overall ML metrics
@@ -108,14 +261,23 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 			// Filter Health & Lifespan Prediction
 			r.Get("/filter/health", mlHandlers.GetFilterHealth)
 			r.Post("/filter/analyze", mlHandlers.AnalyzeFilterHealth)
+			r.With(RequireAdminToken(opts.AdminToken)).Post("/filter-health", mlHandlers.SetFilterHealth) // Manual assessment
+			r.Post("/filter-health/analyze", mlHandlers.RunFilterHealthAnalysis)                         // Analyze now, record and broadcast
+			r.Get("/efficiency/history", mlHandlers.GetEfficiencyHistory)
+			r.Get("/filter-economics", mlHandlers.GetFilterEconomics) // Cost per liter and liters remaining
+			r.Get("/water-efficiency", mlHandlers.GetWaterEfficiency) // Clean output over input flow
 
 			// Anomaly Detection
 			r.Get("/anomalies", mlHandlers.GetAnomalies)
 			r.Get("/anomalies/unresolved", mlHandlers.GetUnresolvedAnomalies)
 			r.Get("/anomalies/stats", mlHandlers.GetAnomalyStats)
+			r.Get("/anomalies/ops-metrics", mlHandlers.GetAnomalyOpsMetrics) // Resolution rate and MTTR
 			r.Post("/anomalies/detect", mlHandlers.DetectAnomaliesNow)
+			r.Get("/anomalies/{id}/context", mlHandlers.GetAnomalyContext)
 			r.Post("/anomalies/{id}/resolve", mlHandlers.ResolveAnomaly)
 			r.Post("/anomalies/{id}/false-positive", mlHandlers.MarkAnomalyFalsePositive)
+			r.Get("/anomaly/config", mlHandlers.GetAnomalyConfig)
+			r.With(RequireAdminToken(opts.AdminToken)).Post("/anomaly/config", mlHandlers.UpdateAnomalyConfig) // Toggle real-time detection
 
 			// Baselines for anomaly detection
 			r.Get("/baselines", mlHandlers.GetBaselines)
@@ -125,14 +287,24 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 			r.Get("/predictions", mlHandlers.GetPredictions)
 			r.Post("/predictions/generate", mlHandlers.GeneratePredictions)
 			r.Get("/predictions/accuracy", mlHandlers.GetPredictionAccuracy)
+			r.Get("/predictions/accuracy/history", mlHandlers.GetPredictionAccuracyHistory)
+			r.Get("/predictions/vs-actual", mlHandlers.GetPredictionsVsActual)
 			r.Post("/predictions/update", mlHandlers.TriggerPredictionUpdate)
 			r.Get("/predictions/status", mlHandlers.GetPredictionStatus)
 		})
 
+		// Operator-only maintenance
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(RequireAdminToken(opts.AdminToken))
+			r.Post("/prune", handlers.PruneOldData) // Delete data older than DATA_RETENTION_DAYS now
+		})
+
 		// Export routes for data history
 		r.Route("/export", func(r chi.Router) {
 			r.Get("/history.xlsx", handlers.ExportHistoryExcel)
 			r.Get("/history.csv", handlers.ExportHistoryCSV)
+			r.Get("/history.json", handlers.ExportHistoryJSON)
+			r.Get("/history.pdf", handlers.ExportHistoryPDF)
 		})
 	})
 
@@ -140,4 +312,37 @@ func SetupRoutes(dataStore store.DataStore, wsHub *ws.Hub, scheduler *services.S
 	r.HandleFunc("/ws", wsHub.HandleWebSocket)
 
 	return r
-}
\ No newline at end of file
+}
+
+// RouteInfo describes a registered HTTP route and the methods it serves
+type RouteInfo struct {
+	Path    string
+	Methods []string
+}
+
+// RegisteredRoutes walks the router and returns every registered route sorted by
+// path, so startup logs always reflect the routes actually served
+func RegisteredRoutes(r chi.Routes) ([]RouteInfo, error) {
+	methodsByPath := map[string][]string{}
+	walkFn := func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		// Index routes of sub-routers are reported with a trailing "/"
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		methodsByPath[route] = append(methodsByPath[route], method)
+		return nil
+	}
+	if err := chi.Walk(r, walkFn); err != nil {
+		return nil, err
+	}
+
+	routes := make([]RouteInfo, 0, len(methodsByPath))
+	for path, methods := range methodsByPath {
+		sort.Strings(methods)
+		routes = append(routes, RouteInfo{Path: path, Methods: methods})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes, nil
+}
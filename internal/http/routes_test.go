@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	"github.com/Capstone-E1/aquasmart_backend/internal/ws"
+)
+
+func TestRegisteredRoutes_ListsExpectedRoutes(t *testing.T) {
+	router := SetupRoutes(store.NewStore(10), ws.NewHub(), nil, nil, nil, nil, RouterOptions{})
+
+	routes, err := RegisteredRoutes(router)
+	if err != nil {
+		t.Fatalf("Failed to walk routes: %v", err)
+	}
+
+	registered := map[string]map[string]bool{}
+	for i, route := range routes {
+		if i > 0 && routes[i-1].Path >= route.Path {
+			t.Errorf("Expected routes sorted by path, got %s before %s", routes[i-1].Path, route.Path)
+		}
+		registered[route.Path] = map[string]bool{}
+		for _, method := range route.Methods {
+			registered[route.Path][method] = true
+		}
+	}
+
+	expected := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/health"},
+		{"GET", "/api/v1/stats"},
+		{"GET", "/api/v1/sensors/latest"},
+		{"POST", "/api/v1/sensors/data"},
+		{"GET", "/api/v1/schedules"},
+		{"POST", "/api/v1/schedules"},
+		{"DELETE", "/api/v1/schedules/{id}"},
+		{"GET", "/api/v1/export/history.csv"},
+		{"GET", "/api/v1/ml/dashboard"},
+		{"GET", "/ws"},
+	}
+	for _, e := range expected {
+		if !registered[e.path][e.method] {
+			t.Errorf("Expected %s %s to be registered", e.method, e.path)
+		}
+	}
+}
+
+func TestSetupRoutes_FilterHealthStaleZeroTurnsFlagOff(t *testing.T) {
+	s := store.NewStore(10)
+	s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", HealthScore: 80, LastCalculated: time.Now().Add(-24 * time.Hour)})
+
+	off := time.Duration(0)
+	for _, tt := range []struct {
+		staleAfter *time.Duration
+		wantStale  bool
+	}{{nil, true}, {&off, false}} {
+		router := SetupRoutes(s, ws.NewHub(), nil, nil, nil, nil, RouterOptions{FilterHealthStale: tt.staleAfter})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ml/filter/health?device_id=stm32_post", nil))
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["is_stale"] != tt.wantStale {
+			t.Errorf("stale after %v: expected is_stale=%v, got %s", tt.staleAfter, tt.wantStale, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ml
+
+import (
+	"sync"
+	"time"
+)
+
+// AlertThrottle limits anomaly alerts to one per device and metric within a
+// window. Anomalies inside the window are still recorded, only the alert is
+// suppressed; the number suppressed is reported with the next alert.
+type AlertThrottle struct {
+	window time.Duration
+	mu     sync.Mutex
+	states map[string]*alertThrottleState // key: device ID | metric
+}
+
+// alertThrottleState tracks alerts for one device/metric combination
+type alertThrottleState struct {
+	lastAlert  time.Time
+	suppressed int
+}
+
+// NewAlertThrottle creates an alert throttle; a zero window alerts on every anomaly
+func NewAlertThrottle(window time.Duration) *AlertThrottle {
+	return &AlertThrottle{
+		window: window,
+		states: make(map[string]*alertThrottleState),
+	}
+}
+
+// Window returns the minimum time between alerts for a device/metric
+func (t *AlertThrottle) Window() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return t.window
+}
+
+// Allow reports whether an anomaly on the device's metric at now should raise
+// an alert. When it should, the number of alerts suppressed since the previous
+// one is returned and the count is reset.
+func (t *AlertThrottle) Allow(deviceID, metric string, now time.Time) (bool, int) {
+	if t == nil || t.window <= 0 {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := deviceID + "|" + metric
+	state, exists := t.states[key]
+	if !exists {
+		state = &alertThrottleState{}
+		t.states[key] = state
+	}
+
+	if !state.lastAlert.IsZero() && now.Sub(state.lastAlert) < t.window {
+		state.suppressed++
+		return false, 0
+	}
+
+	suppressed := state.suppressed
+	state.lastAlert = now
+	state.suppressed = 0
+	return true, suppressed
+}
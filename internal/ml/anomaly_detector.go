@@ -3,6 +3,7 @@ package ml
 import (
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
@@ -22,11 +23,23 @@ func NewAnomalyDetector() *AnomalyDetector {
 	}
 }
 
+// ZScoreThreshold returns how many standard deviations from the baseline mean
+// a value may lie before it is anomalous
+func (ad *AnomalyDetector) ZScoreThreshold() float64 {
+	return ad.zScoreThreshold
+}
+
+// SpikeMultiplier returns how many times the baseline's normal range a value
+// may exceed before it counts as a spike
+func (ad *AnomalyDetector) SpikeMultiplier() float64 {
+	return ad.spikeMultiplier
+}
+
 // DetectAnomalies analyzes a sensor reading against baseline and detects anomalies
 func (ad *AnomalyDetector) DetectAnomalies(reading *models.SensorReading, baseline *models.SensorBaseline) []models.AnomalyDetection {
 	anomalies := []models.AnomalyDetection{}
 
-	if baseline == nil || baseline.SampleSize < 10 {
+	if baseline == nil || baseline.SampleSize < MinBaselineSamples {
 		// Not enough baseline data
 		return anomalies
 	}
@@ -92,6 +105,53 @@ func (ad *AnomalyDetector) DetectAnomalies(reading *models.SensorReading, baseli
 	return anomalies
 }
 
+// MetricZScore is how far a metric's current value sits from its baseline mean,
+// in baseline standard deviations
+type MetricZScore struct {
+	Metric   string   `json:"metric"`
+	Value    float64  `json:"value"`
+	Mean     float64  `json:"mean"`
+	StdDev   float64  `json:"std_dev"`
+	ZScore   *float64 `json:"z_score"`  // nil when the baseline has no spread
+	Severity string   `json:"severity"` // "normal" within the z-score threshold
+}
+
+// CurrentZScores scores each metric of a reading against its baseline so a live
+// deviation can be shown even when no anomaly has been persisted
+func (ad *AnomalyDetector) CurrentZScores(reading *models.SensorReading, baseline *models.SensorBaseline) []MetricZScore {
+	metrics := []struct {
+		name   string
+		value  float64
+		mean   float64
+		stdDev float64
+	}{
+		{"flow", reading.Flow, baseline.FlowMean, baseline.FlowStdDev},
+		{"ph", reading.Ph, baseline.PhMean, baseline.PhStdDev},
+		{"turbidity", reading.Turbidity, baseline.TurbidityMean, baseline.TurbidityStdDev},
+		{"tds", reading.TDS, baseline.TDSMean, baseline.TDSStdDev},
+	}
+
+	scores := make([]MetricZScore, 0, len(metrics))
+	for _, m := range metrics {
+		score := MetricZScore{
+			Metric:   m.name,
+			Value:    m.value,
+			Mean:     m.mean,
+			StdDev:   m.stdDev,
+			Severity: "normal",
+		}
+		if m.stdDev > 0 {
+			zScore := (m.value - m.mean) / m.stdDev
+			score.ZScore = &zScore
+			if math.Abs(zScore) > ad.zScoreThreshold {
+				score.Severity = ad.calculateSeverity(math.Abs(zScore))
+			}
+		}
+		scores = append(scores, score)
+	}
+	return scores
+}
+
 // checkMetricAnomaly checks a single metric for anomalies
 func (ad *AnomalyDetector) checkMetricAnomaly(
 	metricName string,
@@ -194,9 +254,12 @@ func (ad *AnomalyDetector) isPossibleSensorFailure(metricName string, value floa
 	}
 }
 
+// MinBaselineSamples is the number of readings a device/mode needs for a baseline
+const MinBaselineSamples = 10
+
 // CalculateBaseline computes statistical baseline from historical readings
 func (ad *AnomalyDetector) CalculateBaseline(readings []models.SensorReading, deviceID string, filterMode models.FilterMode) *models.SensorBaseline {
-	if len(readings) < 10 {
+	if len(readings) < MinBaselineSamples {
 		return nil // Need at least 10 samples for meaningful statistics
 	}
 
@@ -208,7 +271,7 @@ func (ad *AnomalyDetector) CalculateBaseline(readings []models.SensorReading, de
 		}
 	}
 
-	if len(filteredReadings) < 10 {
+	if len(filteredReadings) < MinBaselineSamples {
 		return nil
 	}
 
@@ -340,3 +403,181 @@ func (ad *AnomalyDetector) DetectDrift(recentReadings []models.SensorReading, ba
 
 	return anomalies
 }
+
+// AnomalyMethod selects how readings are checked for anomalies
+type AnomalyMethod string
+
+const (
+	AnomalyMethodZScore AnomalyMethod = "zscore" // Distance from the baseline mean in standard deviations
+	AnomalyMethodIQR    AnomalyMethod = "iqr"    // Outside the interquartile fences of recent readings
+	AnomalyMethodBoth   AnomalyMethod = "both"   // Either method; z-score wins when both flag a metric
+)
+
+// IsValid reports whether the method is one of the supported methods
+func (m AnomalyMethod) IsValid() bool {
+	return m == AnomalyMethodZScore || m == AnomalyMethodIQR || m == AnomalyMethodBoth
+}
+
+// iqrFenceMultiplier is how many interquartile ranges beyond the quartiles a
+// value may lie before it is an outlier (Tukey's fences)
+const iqrFenceMultiplier = 1.5
+
+// iqrFence is the quartiles of a metric's values
+type iqrFence struct {
+	q1, median, q3 float64
+}
+
+func (f iqrFence) iqr() float64 {
+	return f.q3 - f.q1
+}
+
+// lower and upper are the values beyond which a value is an outlier
+func (f iqrFence) lower() float64 {
+	return f.q1 - iqrFenceMultiplier*f.iqr()
+}
+
+func (f iqrFence) upper() float64 {
+	return f.q3 + iqrFenceMultiplier*f.iqr()
+}
+
+// iqrMetrics are the metrics checked by the IQR method
+var iqrMetrics = []struct {
+	name  string
+	value func(models.SensorReading) float64
+}{
+	{"flow", func(r models.SensorReading) float64 { return r.Flow }},
+	{"ph", func(r models.SensorReading) float64 { return r.Ph }},
+	{"turbidity", func(r models.SensorReading) float64 { return r.Turbidity }},
+	{"tds", func(r models.SensorReading) float64 { return r.TDS }},
+}
+
+// DetectOutliersIQR flags every metric value outside Q1-1.5*IQR .. Q3+1.5*IQR,
+// with the quartiles taken over the readings themselves. Unlike the mean and
+// standard deviation, the quartiles barely move for the spikes being looked
+// for. Metrics with fewer than MinBaselineSamples values or no spread are
+// skipped. Each anomaly is dated at its reading's timestamp.
+func (ad *AnomalyDetector) DetectOutliersIQR(readings []models.SensorReading) []models.AnomalyDetection {
+	anomalies := []models.AnomalyDetection{}
+
+	for _, metric := range iqrMetrics {
+		values := make([]float64, len(readings))
+		for i, reading := range readings {
+			values[i] = metric.value(reading)
+		}
+		fence, ok := iqrFences(values)
+		if !ok {
+			continue
+		}
+
+		for i := range readings {
+			if anomaly := ad.checkMetricIQR(metric.name, values[i], fence, &readings[i]); anomaly != nil {
+				anomaly.DetectedAt = readings[i].Timestamp
+				anomalies = append(anomalies, *anomaly)
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// DetectAnomaliesIQR checks a new reading against the interquartile fences of
+// the device's earlier readings
+func (ad *AnomalyDetector) DetectAnomaliesIQR(reading *models.SensorReading, history []models.SensorReading) []models.AnomalyDetection {
+	anomalies := []models.AnomalyDetection{}
+	now := time.Now()
+
+	for _, metric := range iqrMetrics {
+		values := make([]float64, len(history))
+		for i, r := range history {
+			values[i] = metric.value(r)
+		}
+		fence, ok := iqrFences(values)
+		if !ok {
+			continue
+		}
+
+		if anomaly := ad.checkMetricIQR(metric.name, metric.value(*reading), fence, reading); anomaly != nil {
+			anomaly.DetectedAt = now
+			anomalies = append(anomalies, *anomaly)
+		}
+	}
+
+	return anomalies
+}
+
+// iqrFences returns the quartiles of values, or false when there are too few
+// values or they have no spread to judge outliers by
+func iqrFences(values []float64) (iqrFence, bool) {
+	if len(values) < MinBaselineSamples {
+		return iqrFence{}, false
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	fence := iqrFence{
+		q1:     quantile(sorted, 0.25),
+		median: quantile(sorted, 0.5),
+		q3:     quantile(sorted, 0.75),
+	}
+	if fence.iqr() <= 0 {
+		return iqrFence{}, false
+	}
+	return fence, true
+}
+
+// quantile interpolates the q-th quantile of sorted values linearly between
+// the closest ranks
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (position-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// checkMetricIQR flags a value outside the metric's fences as an outlier, with
+// the median as the expected value and severity growing with how many
+// interquartile ranges the value lies beyond the nearer quartile
+func (ad *AnomalyDetector) checkMetricIQR(metricName string, actualValue float64, fence iqrFence, reading *models.SensorReading) *models.AnomalyDetection {
+	var beyond float64
+	switch {
+	case actualValue < fence.lower():
+		beyond = (fence.q1 - actualValue) / fence.iqr()
+	case actualValue > fence.upper():
+		beyond = (actualValue - fence.q3) / fence.iqr()
+	default:
+		return nil
+	}
+
+	var severity string
+	switch {
+	case beyond >= 6:
+		severity = "critical"
+	case beyond >= 4.5:
+		severity = "high"
+	case beyond >= 3:
+		severity = "medium" // Tukey's "far out"
+	default:
+		severity = "low"
+	}
+
+	deviation := 0.0
+	if fence.median != 0 {
+		deviation = math.Abs((actualValue - fence.median) / fence.median * 100)
+	}
+
+	return &models.AnomalyDetection{
+		DeviceID:       reading.DeviceID,
+		AnomalyType:    "outlier",
+		Severity:       severity,
+		AffectedMetric: metricName,
+		ExpectedValue:  fence.median,
+		ActualValue:    actualValue,
+		Deviation:      deviation,
+		FilterMode:     reading.FilterMode,
+		Description: fmt.Sprintf("%s outlier detected: %.2f outside interquartile range %.2f to %.2f",
+			metricName, actualValue, fence.lower(), fence.upper()),
+		AlertSent:    false,
+		AutoResolved: false,
+		CreatedAt:    time.Now(),
+	}
+}
@@ -0,0 +1,97 @@
+package ml
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+func TestCurrentZScores_KnownDeviation(t *testing.T) {
+	ad := NewAnomalyDetector()
+	baseline := &models.SensorBaseline{
+		FlowMean: 2.0, FlowStdDev: 0.5,
+		PhMean: 7.0, PhStdDev: 0.2,
+		TurbidityMean: 1.0, TurbidityStdDev: 0, // No spread: z-score undefined
+		TDSMean: 200, TDSStdDev: 20,
+	}
+	reading := &models.SensorReading{Flow: 2.5, Ph: 6.0, Turbidity: 3.0, TDS: 200}
+
+	scores := map[string]MetricZScore{}
+	for _, score := range ad.CurrentZScores(reading, baseline) {
+		scores[score.Metric] = score
+	}
+	if len(scores) != 4 {
+		t.Fatalf("Expected a score for each of 4 metrics, got %d", len(scores))
+	}
+
+	expected := map[string]struct {
+		z        float64
+		severity string
+	}{
+		"flow": {1.0, "normal"},
+		"ph":   {-5.0, "high"},
+		"tds":  {0.0, "normal"},
+	}
+	for metric, want := range expected {
+		score := scores[metric]
+		if score.ZScore == nil || math.Abs(*score.ZScore-want.z) > 1e-9 {
+			t.Errorf("%s: expected z-score %.1f, got %v", metric, want.z, score.ZScore)
+		}
+		if score.Severity != want.severity {
+			t.Errorf("%s: expected severity %s, got %s", metric, want.severity, score.Severity)
+		}
+	}
+
+	if turbidity := scores["turbidity"]; turbidity.ZScore != nil || turbidity.Severity != "normal" {
+		t.Errorf("Expected no z-score for a zero-spread baseline, got %v (%s)", turbidity.ZScore, turbidity.Severity)
+	}
+}
+
+func TestDetectOutliersIQR_KnownDataset(t *testing.T) {
+	ad := NewAnomalyDetector()
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	// TDS quartiles are 99, 100 (median) and 101.25, so the fences are
+	// 95.625 .. 104.625; pH quartiles are 6.9375, 7.0 and 7.0125 with fences
+	// 6.825 .. 7.125. Flow has no spread and turbidity stays inside its fences.
+	tds := []float64{100, 102, 98, 101, 99, 103, 97, 100, 101, 99, 100, 180}
+	ph := []float64{7.0, 7.1, 6.9, 7.0, 7.05, 6.95, 7.0, 7.1, 6.9, 7.0, 7.0, 6.7}
+	turbidity := []float64{1.0, 1.1, 1.2, 1.0, 1.1, 1.2, 1.0, 1.1, 1.2, 1.0, 1.1, 1.2}
+	var readings []models.SensorReading
+	for i := range tds {
+		readings = append(readings, models.SensorReading{
+			DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Flow: 2.0, Ph: ph[i], Turbidity: turbidity[i], TDS: tds[i],
+		})
+	}
+
+	anomalies := ad.DetectOutliersIQR(readings)
+	if len(anomalies) != 2 {
+		t.Fatalf("Expected the TDS spike and the low pH to be flagged, got %+v", anomalies)
+	}
+	byMetric := map[string]models.AnomalyDetection{}
+	for _, anomaly := range anomalies {
+		byMetric[anomaly.AffectedMetric] = anomaly
+		if anomaly.AnomalyType != "outlier" || anomaly.DeviceID != "stm32_post" || anomaly.FilterMode != models.FilterModeDrinking {
+			t.Errorf("Expected an outlier record for the reading's device and mode, got %+v", anomaly)
+		}
+		if !anomaly.DetectedAt.Equal(start.Add(11 * time.Minute)) {
+			t.Errorf("Expected the anomaly dated at its reading, got %s", anomaly.DetectedAt)
+		}
+	}
+
+	spike := byMetric["tds"]
+	if spike.ActualValue != 180 || spike.ExpectedValue != 100 || math.Abs(spike.Deviation-80) > 1e-9 || spike.Severity != "critical" {
+		t.Errorf("Expected a critical TDS outlier 80%% above the median, got %+v", spike)
+	}
+	low := byMetric["ph"]
+	if low.ActualValue != 6.7 || math.Abs(low.ExpectedValue-7.0) > 1e-9 || math.Abs(low.Deviation-30.0/7) > 1e-9 || low.Severity != "medium" {
+		t.Errorf("Expected a medium pH outlier about 4.3%% below the median, got %+v", low)
+	}
+
+	if anomalies := ad.DetectOutliersIQR(readings[:MinBaselineSamples-1]); len(anomalies) != 0 {
+		t.Errorf("Expected no outliers from fewer than %d readings, got %+v", MinBaselineSamples, anomalies)
+	}
+}
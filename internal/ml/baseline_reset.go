@@ -0,0 +1,149 @@
+package ml
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// filterEfficiencyMetrics are the anomaly metrics that depend on how well the
+// filter works, so a new filter invalidates what was expected of them
+var filterEfficiencyMetrics = map[string]bool{
+	"flow":      true,
+	"turbidity": true,
+	"tds":       true,
+}
+
+// filterReplacementLookback is how many recent maintenance events are searched
+// for a device's latest filter replacement
+const filterReplacementLookback = 50
+
+// FilterReplacementReset is what a filter replacement reset on a device's
+// anomaly detection
+type FilterReplacementReset struct {
+	DeviceID          string                  `json:"device_id"`
+	ReplacedAt        time.Time               `json:"replaced_at"`
+	BaselinesReset    []models.SensorBaseline `json:"baselines_reset"`    // Recomputed from readings since the replacement
+	AnomaliesResolved int                     `json:"anomalies_resolved"` // Open efficiency anomalies detected before the replacement
+}
+
+// SetBaselineResetOnFilterReplacement sets whether a filter replacement resets
+// the device's baselines and resolves its open efficiency anomalies
+func (s *MLService) SetBaselineResetOnFilterReplacement(enabled bool) {
+	s.mu.Lock()
+	s.resetBaselineOnReplacement = enabled
+	s.mu.Unlock()
+	log.Printf("Baseline reset on filter replacement: %v", enabled)
+}
+
+// BaselineResetOnFilterReplacement reports whether a filter replacement resets
+// the device's baselines
+func (s *MLService) BaselineResetOnFilterReplacement() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resetBaselineOnReplacement
+}
+
+// HandleFilterReplacement re-arms the device's replacement alerts, recomputes
+// its existing baselines from the readings taken since the filter was replaced
+// and resolves the open efficiency anomalies detected before it. A baseline
+// without enough new readings is reset to an empty one, which keeps anomaly
+// detection off until the new filter has a baseline. Returns nil when the
+// baseline reset is disabled.
+func (s *MLService) HandleFilterReplacement(deviceID string, replacedAt time.Time) (*FilterReplacementReset, error) {
+	s.replacementAlerter.Reset(deviceID)
+
+	if !s.BaselineResetOnFilterReplacement() {
+		return nil, nil
+	}
+
+	reset := &FilterReplacementReset{
+		DeviceID:       deviceID,
+		ReplacedAt:     replacedAt,
+		BaselinesReset: []models.SensorBaseline{},
+	}
+	readings := readingsSince(s.store.GetReadingsByDevice(deviceID), replacedAt)
+
+	for _, mode := range []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold} {
+		existing, err := s.store.GetBaseline(deviceID, mode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get baseline for %s/%s: %w", deviceID, mode, err)
+		}
+		if existing == nil {
+			continue
+		}
+
+		baseline := s.anomalyDetector.CalculateBaseline(readings, deviceID, mode)
+		if baseline == nil {
+			sampleSize := 0
+			for _, reading := range readings {
+				if reading.FilterMode == mode {
+					sampleSize++
+				}
+			}
+			now := time.Now()
+			baseline = &models.SensorBaseline{
+				DeviceID:     deviceID,
+				FilterMode:   mode,
+				SampleSize:   sampleSize,
+				CalculatedAt: now,
+				UpdatedAt:    now,
+			}
+		}
+		if err := s.store.SaveBaseline(baseline); err != nil {
+			return nil, fmt.Errorf("failed to reset baseline for %s/%s: %w", deviceID, mode, err)
+		}
+		reset.BaselinesReset = append(reset.BaselinesReset, *baseline)
+	}
+
+	anomalies, err := s.store.GetUnresolvedAnomalies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unresolved anomalies: %w", err)
+	}
+	for _, anomaly := range anomalies {
+		if anomaly.DeviceID != deviceID || !filterEfficiencyMetrics[anomaly.AffectedMetric] || anomaly.DetectedAt.After(replacedAt) {
+			continue
+		}
+		if err := s.store.AutoResolveAnomaly(anomaly.ID); err != nil {
+			return nil, fmt.Errorf("failed to resolve anomaly %d: %w", anomaly.ID, err)
+		}
+		reset.AnomaliesResolved++
+	}
+
+	log.Printf("🔄 Filter replaced on %s: %d baselines reset, %d anomalies resolved",
+		deviceID, len(reset.BaselinesReset), reset.AnomaliesResolved)
+	return reset, nil
+}
+
+// lastFilterReplacement returns when the device's filter was last replaced,
+// or false when unknown or the baseline reset is disabled
+func (s *MLService) lastFilterReplacement(deviceID string) (time.Time, bool) {
+	if !s.BaselineResetOnFilterReplacement() {
+		return time.Time{}, false
+	}
+
+	events, err := s.store.GetMaintenanceEvents(deviceID, filterReplacementLookback)
+	if err != nil {
+		log.Printf("Warning: Failed to get maintenance events for %s: %v", deviceID, err)
+		return time.Time{}, false
+	}
+	for _, event := range events {
+		if event.Type == models.MaintenanceFilterReplacement {
+			return event.PerformedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// readingsSince returns the readings taken at or after since
+func readingsSince(readings []models.SensorReading, since time.Time) []models.SensorReading {
+	var result []models.SensorReading
+	for _, reading := range readings {
+		if !reading.Timestamp.Before(since) {
+			result = append(result, reading)
+		}
+	}
+	return result
+}
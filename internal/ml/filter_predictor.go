@@ -3,6 +3,7 @@ package ml
 import (
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
@@ -14,8 +15,17 @@ type FilterPredictor struct {
 	degradationThreshold  float64 // Efficiency drop threshold for concern
 	maxFilterLifeDays     int     // Maximum filter lifespan in days
 	maxFilterVolumeLiters float64 // Maximum volume before replacement (liters)
+	minPreTurbidity       float64 // Pre-filtration turbidity (NTU) below which a pair's turbidity reduction and efficiency are ignored
+	minPreTDS             float64 // Pre-filtration TDS (ppm) below which a pair's TDS reduction and efficiency are ignored
 }
 
+// Default pre-filtration values below which the input water is already too
+// clean for a reduction to say anything about the filter
+const (
+	DefaultEfficiencyMinPreTurbidity = 1.0  // NTU
+	DefaultEfficiencyMinPreTDS       = 10.0 // ppm
+)
+
 // NewFilterPredictor creates a new filter predictor
 func NewFilterPredictor() *FilterPredictor {
 	return &FilterPredictor{
@@ -23,22 +33,49 @@ func NewFilterPredictor() *FilterPredictor {
 		degradationThreshold:  10.0,     // 10% efficiency drop is concerning
 		maxFilterLifeDays:     180,      // 6 months maximum filter life
 		maxFilterVolumeLiters: 100000.0, // 100,000 liters capacity
+		minPreTurbidity:       DefaultEfficiencyMinPreTurbidity,
+		minPreTDS:             DefaultEfficiencyMinPreTDS,
 	}
 }
 
+// SetMinPreValues sets the pre-filtration turbidity (NTU) and TDS (ppm) below
+// which a pair is excluded from that metric's average reduction and efficiency;
+// reducing near-zero input is mostly sensor noise. Negative values are treated as 0.
+func (fp *FilterPredictor) SetMinPreValues(turbidity, tds float64) {
+	fp.minPreTurbidity = math.Max(turbidity, 0)
+	fp.minPreTDS = math.Max(tds, 0)
+}
+
 
-// AnalyzeFilterHealth performs comprehensive filter health analysis
+// LegacyFilterHealthDeviceID is the device ID filter health was recorded under
+// before it was tied to the post-filtration device
+const LegacyFilterHealthDeviceID = "filter_system"
+
+// AnalyzeFilterHealth performs comprehensive filter health analysis. The result
+// is recorded under deviceID (normally the post-filtration device), or under
+// LegacyFilterHealthDeviceID when deviceID is empty.
 func (fp *FilterPredictor) AnalyzeFilterHealth(
+	deviceID string,
 	preReadings []models.SensorReading,
 	postReadings []models.SensorReading,
 	filterMode models.FilterMode,
 ) (*models.FilterHealth, error) {
 
+	// Only analyze readings taken in the requested filter mode
+	if filterMode != "" {
+		preReadings = filterReadingsByMode(preReadings, filterMode)
+		postReadings = filterReadingsByMode(postReadings, filterMode)
+	}
+
+	if deviceID == "" {
+		deviceID = LegacyFilterHealthDeviceID
+	}
+
 	if len(preReadings) < fp.minDataPoints || len(postReadings) < fp.minDataPoints {
 		return nil, fmt.Errorf("insufficient data: need at least %d readings", fp.minDataPoints)
 	}
 
-	// Match pre and post readings by timestamp (within 1 minute)
+	// Match pre and post readings by timestamp (within 1 minute) and filter mode
 	matchedPairs := fp.matchReadings(preReadings, postReadings)
 	if len(matchedPairs) < fp.minDataPoints/2 {
 		return nil, fmt.Errorf("insufficient matched pre/post reading pairs")
@@ -53,8 +90,8 @@ func (fp *FilterPredictor) AnalyzeFilterHealth(
 	trend := fp.detectTrend(efficiencies)
 
 	// Calculate degradation metrics
-	turbidityReduction := fp.calculateAverageReduction(matchedPairs, "turbidity")
-	tdsReduction := fp.calculateAverageReduction(matchedPairs, "tds")
+	turbidityReduction, turbidityExcluded := fp.calculateAverageReduction(matchedPairs, "turbidity")
+	tdsReduction, tdsExcluded := fp.calculateAverageReduction(matchedPairs, "tds")
 	phStabilization := fp.calculatePhStabilization(matchedPairs)
 
 	// Calculate health score (0-100)
@@ -112,7 +149,7 @@ func (fp *FilterPredictor) AnalyzeFilterHealth(
 	)
 
 	health := &models.FilterHealth{
-		DeviceID:              "filter_system", // Can be made dynamic
+		DeviceID:              deviceID,
 		FilterMode:            filterMode,
 		HealthScore:           healthScore,
 		PredictedDaysRemaining: daysRemaining,
@@ -123,6 +160,8 @@ func (fp *FilterPredictor) AnalyzeFilterHealth(
 		TurbidityReduction:    turbidityReduction,
 		TDSReduction:          tdsReduction,
 		PhStabilization:       phStabilization,
+		TurbidityPairsExcluded: turbidityExcluded,
+		TDSPairsExcluded:      tdsExcluded,
 		TotalFlowProcessed:    totalFlowProcessed,
 		FilterAgeDays:         filterAgeDays,
 		MaintenanceRequired:   healthScore < 75,
@@ -136,6 +175,108 @@ func (fp *FilterPredictor) AnalyzeFilterHealth(
 	return health, nil
 }
 
+// EfficiencyBucketSizes maps the supported efficiency history bucket names to their length
+var EfficiencyBucketSizes = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// EfficiencyHistory pairs pre and post filtration readings and averages the
+// efficiency of the pairs per time bucket (UTC aligned, weeks start on Monday).
+// Buckets without any matched pair are omitted; the result is oldest first.
+func (fp *FilterPredictor) EfficiencyHistory(preReadings, postReadings []models.SensorReading, bucketSize time.Duration) []models.EfficiencyBucket {
+	bucketOf := func(t time.Time) time.Time {
+		return t.UTC().Truncate(bucketSize)
+	}
+
+	// Pair within each bucket to keep matching cheap over long ranges
+	preByBucket := map[time.Time][]models.SensorReading{}
+	for _, reading := range preReadings {
+		start := bucketOf(reading.Timestamp)
+		preByBucket[start] = append(preByBucket[start], reading)
+	}
+	postByBucket := map[time.Time][]models.SensorReading{}
+	for _, reading := range postReadings {
+		start := bucketOf(reading.Timestamp)
+		postByBucket[start] = append(postByBucket[start], reading)
+	}
+
+	history := []models.EfficiencyBucket{}
+	for start, pre := range preByBucket {
+		pairs := fp.matchReadings(pre, postByBucket[start])
+		if len(pairs) == 0 {
+			continue
+		}
+		history = append(history, models.EfficiencyBucket{
+			BucketStart:       start,
+			AverageEfficiency: fp.calculateMean(fp.calculateEfficiencies(pairs)),
+			PairCount:         len(pairs),
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].BucketStart.Before(history[j].BucketStart)
+	})
+	return history
+}
+
+// WastefulRecoveryRatio is the share of the input water below which the
+// filter's output counts as wasteful
+const WastefulRecoveryRatio = 0.5
+
+// WaterEfficiency pairs pre and post filtration readings and compares the
+// clean (post) flow to the input (pre) flow. Pairs without input flow, taken
+// while the filter was idle, are left out.
+func (fp *FilterPredictor) WaterEfficiency(preReadings, postReadings []models.SensorReading) models.WaterEfficiency {
+	result := models.WaterEfficiency{Status: models.WaterEfficiencyInsufficient}
+
+	// Pair within each hour and its neighbours to keep matching cheap over long
+	// ranges; the neighbours catch pairs a minute apart across an hour boundary
+	postByHour := map[time.Time][]models.SensorReading{}
+	for _, reading := range postReadings {
+		hour := reading.Timestamp.UTC().Truncate(time.Hour)
+		postByHour[hour] = append(postByHour[hour], reading)
+	}
+	preByHour := map[time.Time][]models.SensorReading{}
+	for _, reading := range preReadings {
+		if reading.Flow > 0 {
+			hour := reading.Timestamp.UTC().Truncate(time.Hour)
+			preByHour[hour] = append(preByHour[hour], reading)
+		}
+	}
+
+	for hour, pre := range preByHour {
+		var candidates []models.SensorReading
+		for _, h := range []time.Time{hour.Add(-time.Hour), hour, hour.Add(time.Hour)} {
+			candidates = append(candidates, postByHour[h]...)
+		}
+		for _, pair := range fp.matchReadings(pre, candidates) {
+			result.PairCount++
+			result.InputFlow += pair.pre.Flow
+			result.OutputFlow += pair.post.Flow
+			if pair.post.Flow < pair.pre.Flow*WastefulRecoveryRatio {
+				result.WastefulPairs++
+			}
+		}
+	}
+	if result.PairCount == 0 {
+		return result
+	}
+
+	recovery := math.Round(result.OutputFlow/result.InputFlow*1000) / 1000
+	waste := math.Round((1-recovery)*1000) / 1000
+	result.RecoveryRatio = &recovery
+	result.WasteRatio = &waste
+	result.InputFlow = math.Round(result.InputFlow*100) / 100
+	result.OutputFlow = math.Round(result.OutputFlow*100) / 100
+	result.Status = models.WaterEfficiencyOK
+	if recovery < WastefulRecoveryRatio {
+		result.Status = models.WaterEfficiencyWasteful
+	}
+	return result
+}
+
 // matchReadings matches pre and post filtration readings by timestamp
 func (fp *FilterPredictor) matchReadings(preReadings, postReadings []models.SensorReading) []struct {
 	pre  models.SensorReading
@@ -146,9 +287,12 @@ func (fp *FilterPredictor) matchReadings(preReadings, postReadings []models.Sens
 		post models.SensorReading
 	}
 
-	// Match readings within 1 minute of each other
+	// Match readings within 1 minute of each other, taken in the same filter mode
 	for _, pre := range preReadings {
 		for _, post := range postReadings {
+			if pre.FilterMode != post.FilterMode {
+				continue
+			}
 			timeDiff := math.Abs(pre.Timestamp.Sub(post.Timestamp).Minutes())
 			if timeDiff <= 1.0 {
 				pairs = append(pairs, struct {
@@ -163,32 +307,67 @@ func (fp *FilterPredictor) matchReadings(preReadings, postReadings []models.Sens
 	return pairs
 }
 
-// calculateEfficiencies calculates filter efficiency for each matched pair
+// filterReadingsByMode returns the readings taken in the given filter mode
+func filterReadingsByMode(readings []models.SensorReading, filterMode models.FilterMode) []models.SensorReading {
+	filtered := make([]models.SensorReading, 0, len(readings))
+	for _, reading := range readings {
+		if reading.FilterMode == filterMode {
+			filtered = append(filtered, reading)
+		}
+	}
+	return filtered
+}
+
+// calculateEfficiencies calculates filter efficiency for each matched pair. A
+// metric whose pre-filtration value is below its minimum is left out of the
+// pair's efficiency, and pairs with neither metric left are skipped, since
+// reducing near-zero input is mostly noise. When that skips every pair, all
+// pairs are used as they are, so clean input water doesn't read as a failed filter.
 func (fp *FilterPredictor) calculateEfficiencies(pairs []struct {
 	pre  models.SensorReading
 	post models.SensorReading
 }) []float64 {
-	efficiencies := make([]float64, len(pairs))
-
-	for i, pair := range pairs {
-		efficiencies[i] = models.CalculateFilterEfficiency(&pair.pre, &pair.post)
+	efficiencies := make([]float64, 0, len(pairs))
+	for _, pair := range pairs {
+		withTurbidity := pair.pre.Turbidity >= fp.minPreTurbidity
+		withTDS := pair.pre.TDS >= fp.minPreTDS
+		if withTurbidity || withTDS {
+			efficiencies = append(efficiencies, models.CalculateFilterEfficiencyOf(&pair.pre, &pair.post, withTurbidity, withTDS))
+		}
+	}
+	if len(efficiencies) > 0 || len(pairs) == 0 {
+		return efficiencies
 	}
 
+	for _, pair := range pairs {
+		efficiencies = append(efficiencies, models.CalculateFilterEfficiency(&pair.pre, &pair.post))
+	}
 	return efficiencies
 }
 
-// calculateAverageReduction calculates average reduction percentage for a metric
+// calculateAverageReduction calculates average reduction percentage for a
+// metric, along with how many pairs were excluded because their pre-filtration
+// value was below the metric's minimum
 func (fp *FilterPredictor) calculateAverageReduction(pairs []struct {
 	pre  models.SensorReading
 	post models.SensorReading
-}, metric string) float64 {
+}, metric string) (float64, int) {
 	if len(pairs) == 0 {
-		return 0.0
+		return 0.0, 0
+	}
+
+	var minPreValue float64
+	switch metric {
+	case "turbidity":
+		minPreValue = fp.minPreTurbidity
+	case "tds":
+		minPreValue = fp.minPreTDS
 	}
 
 	totalReduction := 0.0
 	count := 0
 	negativeCount := 0 // Track cases where post > pre (filter making things worse)
+	excluded := 0      // Pairs whose input was already too clean to measure a reduction
 
 	for _, pair := range pairs {
 		var preValue, postValue float64
@@ -202,6 +381,11 @@ func (fp *FilterPredictor) calculateAverageReduction(pairs []struct {
 			postValue = pair.post.TDS
 		}
 
+		if preValue < minPreValue {
+			excluded++
+			continue
+		}
+
 		// Validate that pre-filtration value exists
 		if preValue > 0 && postValue >= 0 {
 			reduction := ((preValue - postValue) / preValue) * 100
@@ -223,25 +407,25 @@ func (fp *FilterPredictor) calculateAverageReduction(pairs []struct {
 		}
 	}
 
-	// If more than 50% of readings show negative reduction, return 0
+	// If more than 50% of the measurable readings show negative reduction, return 0
 	// This indicates serious sensor or filter issues
-	if negativeCount > len(pairs)/2 {
+	if negativeCount > (len(pairs)-excluded)/2 {
 		fmt.Printf("⚠️  CRITICAL: Majority of readings show negative %s reduction - check sensor placement and filter condition\n", metric)
-		return 0.0
+		return 0.0, excluded
 	}
 
 	if count == 0 {
-		return 0.0
+		return 0.0, excluded
 	}
 
 	avgReduction := totalReduction / float64(count)
 
 	// Final safety check: ensure result is never negative
 	if avgReduction < 0 {
-		return 0.0
+		return 0.0, excluded
 	}
 
-	return avgReduction
+	return avgReduction, excluded
 }
 
 // calculatePhStabilization measures how well pH is stabilized to neutral
@@ -0,0 +1,226 @@
+package ml
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+func TestFilterPredictor_MatchReadingsExcludesCrossModePairs(t *testing.T) {
+	fp := NewFilterPredictor()
+	now := time.Now()
+
+	pre := []models.SensorReading{
+		{DeviceID: "stm32_pre", Timestamp: now, FilterMode: models.FilterModeDrinking, Turbidity: 10, TDS: 300},
+		{DeviceID: "stm32_pre", Timestamp: now.Add(10 * time.Minute), FilterMode: models.FilterModeHousehold, Turbidity: 10, TDS: 300},
+	}
+	post := []models.SensorReading{
+		// Closest in time to the first pre reading, but in a different mode
+		{DeviceID: "stm32_post", Timestamp: now.Add(5 * time.Second), FilterMode: models.FilterModeHousehold, Turbidity: 5, TDS: 250},
+		{DeviceID: "stm32_post", Timestamp: now.Add(20 * time.Second), FilterMode: models.FilterModeDrinking, Turbidity: 1, TDS: 50},
+		// Same time as the second pre reading, but in a different mode
+		{DeviceID: "stm32_post", Timestamp: now.Add(10*time.Minute + 5*time.Second), FilterMode: models.FilterModeDrinking, Turbidity: 1, TDS: 50},
+	}
+
+	pairs := fp.matchReadings(pre, post)
+	if len(pairs) != 1 {
+		t.Fatalf("Expected 1 same-mode pair, got %d", len(pairs))
+	}
+
+	for _, pair := range pairs {
+		if pair.pre.FilterMode != pair.post.FilterMode {
+			t.Errorf("Expected matched pair to share filter mode, got %s/%s", pair.pre.FilterMode, pair.post.FilterMode)
+		}
+	}
+	if pairs[0].post.TDS != 50 {
+		t.Errorf("Expected drinking-mode post reading to be matched, got TDS %.0f", pairs[0].post.TDS)
+	}
+}
+
+func TestFilterPredictor_AnalyzeFilterHealthIgnoresOtherModes(t *testing.T) {
+	fp := NewFilterPredictor()
+	now := time.Now()
+
+	var pre, post []models.SensorReading
+	for i := 0; i < 30; i++ {
+		ts := now.Add(-time.Duration(i) * 10 * time.Minute)
+		pre = append(pre, models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeHousehold, Ph: 6.5, Turbidity: 10, TDS: 300})
+		post = append(post, models.SensorReading{DeviceID: "stm32_post", Timestamp: ts, FilterMode: models.FilterModeHousehold, Ph: 7.0, Turbidity: 1, TDS: 50})
+	}
+
+	// All readings are in household mode, so a drinking-mode analysis has no usable input
+	if _, err := fp.AnalyzeFilterHealth("stm32_post", pre, post, models.FilterModeDrinking); err == nil {
+		t.Error("Expected analysis to fail without readings in the requested filter mode")
+	}
+
+	if _, err := fp.AnalyzeFilterHealth("stm32_post", pre, post, models.FilterModeHousehold); err != nil {
+		t.Errorf("Expected household-mode analysis to succeed, got %v", err)
+	}
+}
+
+func TestFilterPredictor_EfficiencyHistoryBucketsByDay(t *testing.T) {
+	fp := NewFilterPredictor()
+	day1 := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	var pre, post []models.SensorReading
+	addPair := func(ts time.Time, postTDS float64) {
+		pre = append(pre, models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking, Ph: 7.0, Turbidity: 10, TDS: 100})
+		post = append(post, models.SensorReading{DeviceID: "stm32_post", Timestamp: ts.Add(10 * time.Second), FilterMode: models.FilterModeDrinking, Ph: 7.0, Turbidity: 10, TDS: postTDS})
+	}
+
+	// Efficiency is 0.4 * TDS reduction here: day 1 averages (40 + 20) / 2, day 2 is 8
+	addPair(day1.Add(2*time.Hour), 0)
+	addPair(day1.Add(14*time.Hour), 50)
+	addPair(day2.Add(9*time.Hour), 80)
+	// Unmatched pre reading on day 2 does not count
+	pre = append(pre, models.SensorReading{DeviceID: "stm32_pre", Timestamp: day2.Add(20 * time.Hour), FilterMode: models.FilterModeDrinking, Ph: 7.0, Turbidity: 10, TDS: 100})
+
+	history := fp.EfficiencyHistory(pre, post, EfficiencyBucketSizes["day"])
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 daily buckets, got %d", len(history))
+	}
+
+	expected := []struct {
+		start time.Time
+		avg   float64
+		pairs int
+	}{
+		{day1, 30, 2},
+		{day2, 8, 1},
+	}
+	for i, want := range expected {
+		got := history[i]
+		if !got.BucketStart.Equal(want.start) {
+			t.Errorf("Bucket %d: expected start %s, got %s", i, want.start, got.BucketStart)
+		}
+		if math.Abs(got.AverageEfficiency-want.avg) > 1e-9 {
+			t.Errorf("Bucket %d: expected average %.2f, got %.2f", i, want.avg, got.AverageEfficiency)
+		}
+		if got.PairCount != want.pairs {
+			t.Errorf("Bucket %d: expected %d pairs, got %d", i, want.pairs, got.PairCount)
+		}
+	}
+}
+
+func TestFilterPredictor_ExcludesLowPreValuePairsFromReduction(t *testing.T) {
+	fp := NewFilterPredictor()
+	now := time.Now()
+
+	// 20 pairs of dirty input halved by the filter, then 10 of already-clean
+	// input where sensor noise reads as a 90% turbidity and TDS reduction
+	var pre, post []models.SensorReading
+	for i := 0; i < 30; i++ {
+		ts := now.Add(-time.Duration(i) * 10 * time.Minute)
+		preTurbidity, postTurbidity, preTDS, postTDS := 10.0, 5.0, 300.0, 150.0
+		if i >= 20 {
+			preTurbidity, postTurbidity, preTDS, postTDS = 0.2, 0.02, 5, 0.5
+		}
+		pre = append(pre, models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, FilterMode: models.FilterModeDrinking, Ph: 7.0, Turbidity: preTurbidity, TDS: preTDS})
+		post = append(post, models.SensorReading{DeviceID: "stm32_post", Timestamp: ts, FilterMode: models.FilterModeDrinking, Ph: 7.0, Turbidity: postTurbidity, TDS: postTDS})
+	}
+
+	health, err := fp.AnalyzeFilterHealth("stm32_post", pre, post, models.FilterModeDrinking)
+	if err != nil {
+		t.Fatalf("Failed to analyze filter health: %v", err)
+	}
+	if math.Abs(health.TurbidityReduction-50) > 0.001 || math.Abs(health.TDSReduction-50) > 0.001 {
+		t.Errorf("Expected 50%% reductions from the measurable pairs only, got turbidity %.2f and TDS %.2f", health.TurbidityReduction, health.TDSReduction)
+	}
+	if health.TurbidityPairsExcluded != 10 || health.TDSPairsExcluded != 10 {
+		t.Errorf("Expected 10 pairs excluded per metric, got turbidity %d and TDS %d", health.TurbidityPairsExcluded, health.TDSPairsExcluded)
+	}
+	// The clean pairs are the most recent ones, yet don't lift the efficiency
+	if math.Abs(health.CurrentEfficiency-40) > 0.001 || math.Abs(health.AverageEfficiency-40) > 0.001 {
+		t.Errorf("Expected 40%% efficiency from the measurable pairs only, got current %.2f and average %.2f", health.CurrentEfficiency, health.AverageEfficiency)
+	}
+
+	// With the guard off every pair counts again
+	fp.SetMinPreValues(0, 0)
+	health, err = fp.AnalyzeFilterHealth("stm32_post", pre, post, models.FilterModeDrinking)
+	if err != nil {
+		t.Fatalf("Failed to analyze filter health: %v", err)
+	}
+	if health.TurbidityPairsExcluded != 0 || health.TurbidityReduction <= 60 {
+		t.Errorf("Expected the clean pairs to inflate the reduction without the guard, got %.2f with %d excluded", health.TurbidityReduction, health.TurbidityPairsExcluded)
+	}
+	if health.CurrentEfficiency <= 40 {
+		t.Errorf("Expected the clean pairs to inflate the efficiency without the guard, got %.2f", health.CurrentEfficiency)
+	}
+}
+
+func TestFilterPredictor_EfficiencyFallsBackWhenEveryPairIsClean(t *testing.T) {
+	fp := NewFilterPredictor()
+	pairs := []struct {
+		pre  models.SensorReading
+		post models.SensorReading
+	}{
+		{models.SensorReading{Turbidity: 0.2, TDS: 5, Ph: 7}, models.SensorReading{Turbidity: 0.1, TDS: 2.5, Ph: 7}},
+		{models.SensorReading{Turbidity: 0.5, TDS: 20, Ph: 7}, models.SensorReading{Turbidity: 0.25, TDS: 10, Ph: 7}},
+	}
+
+	// The second pair still has measurable TDS, so only it counts, weighted
+	// 0.4 for its 50% TDS reduction and 0.2 for its unchanged pH
+	efficiencies := fp.calculateEfficiencies(pairs)
+	if len(efficiencies) != 1 || math.Abs(efficiencies[0]-100.0/3) > 0.001 {
+		t.Errorf("Expected one efficiency of 33.3%% from TDS and pH, got %v", efficiencies)
+	}
+
+	// Without any measurable pair every pair is used as it is
+	efficiencies = fp.calculateEfficiencies(pairs[:1])
+	if len(efficiencies) != 1 || math.Abs(efficiencies[0]-40) > 0.001 {
+		t.Errorf("Expected the unguarded efficiency of the clean pair, got %v", efficiencies)
+	}
+}
+
+func TestFilterPredictor_WaterEfficiencyFromMatchedFlow(t *testing.T) {
+	fp := NewFilterPredictor()
+	base := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+
+	var pre, post []models.SensorReading
+	addPair := func(offset time.Duration, preFlow, postFlow float64) {
+		pre = append(pre, models.SensorReading{DeviceID: "stm32_pre", Timestamp: base.Add(offset), FilterMode: models.FilterModeDrinking, Flow: preFlow})
+		post = append(post, models.SensorReading{DeviceID: "stm32_post", Timestamp: base.Add(offset + 20*time.Second), FilterMode: models.FilterModeDrinking, Flow: postFlow})
+	}
+
+	// 2 + 1 + 1 L/min of output from 4 + 4 + 2 L/min of input
+	addPair(0, 4, 2)
+	addPair(10*time.Minute, 4, 1) // Below half: wasteful
+	addPair(20*time.Minute, 2, 1)
+	// An idle pair without input flow and an unmatched post reading don't count
+	addPair(30*time.Minute, 0, 0)
+	post = append(post, models.SensorReading{DeviceID: "stm32_post", Timestamp: base.Add(2 * time.Hour), FilterMode: models.FilterModeDrinking, Flow: 5})
+
+	efficiency := fp.WaterEfficiency(pre, post)
+	if efficiency.PairCount != 3 || efficiency.InputFlow != 10 || efficiency.OutputFlow != 4 {
+		t.Fatalf("Expected 3 pairs with 10 in and 4 out, got %+v", efficiency)
+	}
+	if efficiency.RecoveryRatio == nil || *efficiency.RecoveryRatio != 0.4 || *efficiency.WasteRatio != 0.6 {
+		t.Fatalf("Expected a 0.4 recovery and 0.6 waste ratio, got %+v", efficiency)
+	}
+	if efficiency.Status != models.WaterEfficiencyWasteful || efficiency.WastefulPairs != 1 {
+		t.Errorf("Expected wasteful operation with 1 wasteful pair, got %s with %d", efficiency.Status, efficiency.WastefulPairs)
+	}
+
+	// Mostly clean output is fine
+	efficiency = fp.WaterEfficiency(pre[:1], post[:1])
+	if efficiency.Status != models.WaterEfficiencyOK || *efficiency.RecoveryRatio != 0.5 {
+		t.Errorf("Expected ok at a 0.5 recovery, got %+v", efficiency)
+	}
+
+	// Pairs straddling an hour boundary are still matched
+	boundary := []models.SensorReading{{DeviceID: "stm32_pre", Timestamp: base.Add(time.Hour - 30*time.Second), FilterMode: models.FilterModeDrinking, Flow: 4}}
+	afterBoundary := []models.SensorReading{{DeviceID: "stm32_post", Timestamp: base.Add(time.Hour + 20*time.Second), FilterMode: models.FilterModeDrinking, Flow: 3}}
+	efficiency = fp.WaterEfficiency(boundary, afterBoundary)
+	if efficiency.PairCount != 1 || efficiency.RecoveryRatio == nil || *efficiency.RecoveryRatio != 0.75 {
+		t.Errorf("Expected a pair across the hour boundary at a 0.75 recovery, got %+v", efficiency)
+	}
+
+	// Without post readings there is nothing to compare
+	efficiency = fp.WaterEfficiency(pre, nil)
+	if efficiency.Status != models.WaterEfficiencyInsufficient || efficiency.RecoveryRatio != nil || efficiency.PairCount != 0 {
+		t.Errorf("Expected insufficient data without post readings, got %+v", efficiency)
+	}
+}
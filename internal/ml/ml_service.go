@@ -1,7 +1,10 @@
 package ml
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,11 +29,93 @@ type MLService struct {
 	predictionUpdateInterval   time.Duration
 	enableRealTimeAnomaly      bool
 	enableAutoPredictionUpdate bool
+	alertThrottle              *AlertThrottle
+	alertNotifier              *WebhookNotifier // Receives high and critical anomaly alerts (nil = none)
+	healthBroadcaster          FilterHealthBroadcaster
+	replacementAlerter         *ReplacementAlerter
+	replacementBroadcaster     ReplacementAlertBroadcaster
+	baselineRefresh            chan struct{} // Requests a baseline update, e.g. when anomaly detection is switched on
+	resetBaselineOnReplacement bool          // Baselines only use readings since the last filter replacement
+	anomalyMethod              AnomalyMethod // How new readings are checked for anomalies
+
+	// Prediction update concurrency (bounded, debounced and coalesced per device/mode)
+	predictionMu        sync.Mutex
+	predictionSem       chan struct{}
+	predictionStates    map[string]*predictionState
+	predictionDebounce  time.Duration
+	runPredictionUpdate func(deviceID string, filterMode models.FilterMode, triggerReason string) bool
 }
 
+// FilterHealthBroadcaster publishes new filter health assessments to live clients
+type FilterHealthBroadcaster interface {
+	BroadcastFilterHealth(health *models.FilterHealth)
+}
+
+// ReplacementAlertBroadcaster publishes filter replacement alerts to live clients
+type ReplacementAlertBroadcaster interface {
+	BroadcastFilterReplacementAlert(alert *models.FilterReplacementAlert)
+}
+
+// InsufficientFilterDataError is returned when there are too few readings to
+// analyze filter health
+type InsufficientFilterDataError struct {
+	PreReadings  int
+	PostReadings int
+	Required     int // Minimum readings needed from each device
+}
+
+func (e *InsufficientFilterDataError) Error() string {
+	return fmt.Sprintf("insufficient data for filter health analysis: need at least %d pre and post filtration readings, have %d pre and %d post",
+		e.Required, e.PreReadings, e.PostReadings)
+}
+
+// predictionState tracks prediction update scheduling for a single device/mode
+type predictionState struct {
+	deviceID   string
+	filterMode models.FilterMode
+	inFlight   bool        // An update is currently running
+	pending    bool        // Triggers arrived while the update was running
+	timer      *time.Timer // Debounced run waiting to fire
+	lastRun    time.Time   // When the latest update started
+}
+
+// PredictionRunStatus reports the prediction update scheduling of a device/mode
+type PredictionRunStatus struct {
+	DeviceID   string            `json:"device_id"`
+	FilterMode models.FilterMode `json:"filter_mode"`
+	LastRun    *time.Time        `json:"last_run"` // Null until the first update starts
+	InFlight   bool              `json:"in_flight"`
+	Pending    bool              `json:"pending"` // A debounced or trailing update is waiting
+}
+
+const (
+	// defaultPredictionConcurrency is the default number of prediction updates allowed to run at once
+	defaultPredictionConcurrency = 2
+	// defaultPredictionDebounce is the default minimum interval between prediction updates per device/mode
+	defaultPredictionDebounce = 30 * time.Second
+	// filterHealthWindow is how many recent readings per device the filter health analysis uses
+	filterHealthWindow = 100
+	// minFilterHealthReadings is the fewest readings per device the filter health analysis accepts
+	minFilterHealthReadings = 20
+	// defaultAlertThrottleWindow is the default minimum interval between anomaly alerts per device/metric
+	defaultAlertThrottleWindow = 15 * time.Minute
+	// predictionMatchWindow is how far a reading may be from a prediction's time to validate it
+	predictionMatchWindow = 5 * time.Minute
+	// predictionValidationMaxAge is how long after its time a prediction can still be validated;
+	// older ones without a matching reading stay unvalidated
+	predictionValidationMaxAge = 24 * time.Hour
+	// predictionValidationBatch is the most predictions validated per run
+	predictionValidationBatch = 1000
+	// iqrHistoryWindow is how many earlier readings of a device/mode the IQR method takes its quartiles from
+	iqrHistoryWindow = 100
+)
+
+// defaultReplacementAlertDays are the predicted days remaining at which a filter replacement alert is raised
+var defaultReplacementAlertDays = []int{14, 7}
+
 // NewMLService creates a new ML service
 func NewMLService(dataStore store.DataStore) *MLService {
-	return &MLService{
+	s := &MLService{
 		store:                      dataStore,
 		anomalyDetector:            NewAnomalyDetector(),
 		filterPredictor:            NewFilterPredictor(),
@@ -39,11 +124,119 @@ func NewMLService(dataStore store.DataStore) *MLService {
 		baselineUpdateInterval:     1 * time.Hour,   // Update baselines every hour
 		healthAnalysisInterval:     30 * time.Minute, // Analyze filter health every 30 minutes
 		predictionUpdateInterval:   2 * time.Hour,    // Update predictions every 2 hours
-		enableRealTimeAnomaly:      false, // DISABLED: Anomaly detection feature disabled
+		enableRealTimeAnomaly:      false, // Off unless enabled via config or the API
 		enableAutoPredictionUpdate: true,
+		predictionSem:              make(chan struct{}, defaultPredictionConcurrency),
+		predictionStates:           make(map[string]*predictionState),
+		baselineRefresh:            make(chan struct{}, 1),
+		predictionDebounce:         defaultPredictionDebounce,
+		alertThrottle:              NewAlertThrottle(defaultAlertThrottleWindow),
+		replacementAlerter:         NewReplacementAlerter(defaultReplacementAlertDays),
+		anomalyMethod:              AnomalyMethodZScore,
+	}
+	s.runPredictionUpdate = s.updatePredictionsForDevice
+	return s
+}
+
+// SetPredictionConcurrency sets the maximum number of prediction updates running at once
+func (s *MLService) SetPredictionConcurrency(limit int) {
+	if limit <= 0 {
+		limit = defaultPredictionConcurrency
+	}
+
+	s.predictionMu.Lock()
+	defer s.predictionMu.Unlock()
+	s.predictionSem = make(chan struct{}, limit)
+	log.Printf("Prediction update concurrency limit: %d", limit)
+}
+
+// SetPredictionDebounce sets the minimum interval between prediction updates for a device/mode.
+// Zero disables debouncing (updates run immediately, coalescing only while in flight).
+func (s *MLService) SetPredictionDebounce(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+
+	s.predictionMu.Lock()
+	defer s.predictionMu.Unlock()
+	s.predictionDebounce = interval
+	log.Printf("Prediction update debounce interval: %s", interval)
+}
+
+// SetAlertThrottleWindow sets the minimum interval between anomaly alerts for a
+// device/metric. Zero alerts on every anomaly.
+func (s *MLService) SetAlertThrottleWindow(window time.Duration) {
+	if window < 0 {
+		window = 0
+	}
+	s.alertThrottle = NewAlertThrottle(window)
+	log.Printf("Anomaly alert throttle window: %s", window)
+}
+
+// AlertThrottleWindow returns the minimum interval between anomaly alerts per device/metric
+func (s *MLService) AlertThrottleWindow() time.Duration {
+	return s.alertThrottle.Window()
+}
+
+// SetAlertWebhook sets the URL high and critical anomaly alerts are posted to.
+// An empty URL disables the webhook.
+func (s *MLService) SetAlertWebhook(url string, timeout time.Duration) {
+	s.alertNotifier = NewWebhookNotifier(url, timeout)
+	if s.alertNotifier != nil {
+		log.Printf("Anomaly alert webhook enabled (timeout %s)", s.alertNotifier.client.Timeout)
+	}
+}
+
+// SetFilterHealthBroadcaster sets where newly recorded filter health is published
+func (s *MLService) SetFilterHealthBroadcaster(broadcaster FilterHealthBroadcaster) {
+	s.healthBroadcaster = broadcaster
+}
+
+// SetReplacementAlertDays sets the predicted days remaining at which a filter
+// replacement alert is raised, once per threshold per filter install. An empty
+// list disables the alerts.
+func (s *MLService) SetReplacementAlertDays(days []int) {
+	s.replacementAlerter = NewReplacementAlerter(days)
+	log.Printf("Filter replacement alert thresholds (days): %v", s.replacementAlerter.Thresholds())
+}
+
+// ReplacementAlertDays returns the predicted days remaining at which a filter
+// replacement alert is raised, highest first
+func (s *MLService) ReplacementAlertDays() []int {
+	return s.replacementAlerter.Thresholds()
+}
+
+// SetEfficiencyMinPreValues sets the pre-filtration turbidity (NTU) and TDS
+// (ppm) below which a reading pair is left out of that metric's reduction
+func (s *MLService) SetEfficiencyMinPreValues(turbidity, tds float64) {
+	s.filterPredictor.SetMinPreValues(turbidity, tds)
+	log.Printf("Efficiency minimum pre-filtration values: turbidity %.2f NTU, TDS %.1f ppm", turbidity, tds)
+}
+
+// SetAnomalyMethod sets how new readings are checked for anomalies
+func (s *MLService) SetAnomalyMethod(method AnomalyMethod) error {
+	if !method.IsValid() {
+		return fmt.Errorf("unknown anomaly method %q", method)
 	}
+
+	s.mu.Lock()
+	s.anomalyMethod = method
+	s.mu.Unlock()
+	log.Printf("Anomaly detection method: %s", method)
+	return nil
+}
+
+// AnomalyMethod returns how new readings are checked for anomalies
+func (s *MLService) AnomalyMethod() AnomalyMethod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.anomalyMethod
 }
 
+// SetReplacementAlertBroadcaster sets where filter replacement alerts are published
+func (s *MLService) SetReplacementAlertBroadcaster(broadcaster ReplacementAlertBroadcaster) {
+	s.replacementBroadcaster = broadcaster
+}
 
 // Start begins the ML service background tasks
 func (s *MLService) Start() {
@@ -57,10 +250,11 @@ func (s *MLService) Start() {
 
 	log.Println("🤖 Starting ML Service (using statistical methods with linear regression)...")
 
-	// Start baseline update task (only if anomaly detection is enabled)
-	if s.enableRealTimeAnomaly {
-		s.wg.Add(1)
-		go s.baselineUpdateTask()
+	// Start baseline update task (idle while anomaly detection is disabled, which
+	// can be switched at runtime)
+	s.wg.Add(1)
+	go s.baselineUpdateTask()
+	if s.RealTimeAnomalyEnabled() {
 		log.Println("  ✓ Anomaly detection enabled")
 	} else {
 		log.Println("  ✗ Anomaly detection disabled")
@@ -96,23 +290,45 @@ func (s *MLService) Stop() {
 // ProcessNewReading processes a new sensor reading for anomaly detection and prediction updates
 func (s *MLService) ProcessNewReading(reading *models.SensorReading) {
 	// 1. Anomaly Detection
-	if s.enableRealTimeAnomaly {
-		// Get baseline for this device and filter mode
-		baseline, err := s.store.GetBaseline(reading.DeviceID, reading.FilterMode)
-		if err != nil {
-			log.Printf("Warning: Failed to get baseline for anomaly detection: %v", err)
-		} else if baseline != nil {
-			// Detect anomalies
-			anomalies := s.anomalyDetector.DetectAnomalies(reading, baseline)
-			if len(anomalies) > 0 {
-				log.Printf("⚠️  Detected %d anomalies in reading from %s", len(anomalies), reading.DeviceID)
+	if s.RealTimeAnomalyEnabled() {
+		anomalies := s.detectAnomalies(reading)
+		if len(anomalies) > 0 {
+			log.Printf("⚠️  Detected %d anomalies in reading from %s", len(anomalies), reading.DeviceID)
+
+			prefs, err := s.store.GetAlertPreferences(reading.DeviceID)
+			if err != nil {
+				log.Printf("Warning: Failed to get alert preferences for %s, alerting on everything: %v", reading.DeviceID, err)
+				prefs = models.DefaultAlertPreferences(reading.DeviceID)
+			}
 
-				for _, anomaly := range anomalies {
-					// Save anomaly to database
-					if err := s.store.SaveAnomaly(&anomaly); err != nil {
-						log.Printf("Error saving anomaly: %v", err)
+			for _, anomaly := range anomalies {
+				// Every anomaly is recorded, but muted ones don't alert and repeated
+				// alerts for a device/metric are throttled
+				if prefs.Allows(anomaly.AffectedMetric, anomaly.Severity) {
+					anomaly.AlertSent, anomaly.SuppressedCount = s.alertThrottle.Allow(anomaly.DeviceID, anomaly.AffectedMetric, anomaly.DetectedAt)
+				}
+
+				// Save anomaly to database
+				if err := s.store.SaveAnomaly(&anomaly); err != nil {
+					log.Printf("Error saving anomaly: %v", err)
+					continue
+				}
+				log.Printf("   - %s: %s (severity: %s)", anomaly.AffectedMetric, anomaly.Description, anomaly.Severity)
+
+				if anomaly.AlertSent {
+					if anomaly.SuppressedCount > 0 {
+						log.Printf("🚨 Alert: %s on %s - %s (%d similar alerts suppressed)", anomaly.AffectedMetric, anomaly.DeviceID, anomaly.Description, anomaly.SuppressedCount)
 					} else {
-						log.Printf("   - %s: %s (severity: %s)", anomaly.AffectedMetric, anomaly.Description, anomaly.Severity)
+						log.Printf("🚨 Alert: %s on %s - %s", anomaly.AffectedMetric, anomaly.DeviceID, anomaly.Description)
+					}
+					if webhookAlertSeverities[anomaly.Severity] {
+						id := anomaly.ID
+						s.alertNotifier.NotifyAnomaly(anomaly, func() {
+							// The alert never reached the webhook, so it wasn't sent
+							if err := s.store.SetAnomalyAlertSent(id, false); err != nil {
+								log.Printf("Warning: Failed to record the undelivered alert for anomaly %d: %v", id, err)
+							}
+						})
 					}
 				}
 			}
@@ -122,8 +338,120 @@ func (s *MLService) ProcessNewReading(reading *models.SensorReading) {
 	// 2. Autonomous Prediction Update (trigger when new data arrives)
 	if s.enableAutoPredictionUpdate {
 		// Trigger prediction update asynchronously (don't block)
-		go s.updatePredictionsForDevice(reading.DeviceID, reading.FilterMode, "new_data")
+		s.triggerPredictionUpdate(reading.DeviceID, reading.FilterMode, "new_data")
+	}
+}
+
+// detectAnomalies checks a reading with the configured method(s): z-score
+// against the device/mode baseline and IQR against the device's earlier
+// readings in the same mode. A metric flagged by both is reported once, as
+// the z-score anomaly.
+func (s *MLService) detectAnomalies(reading *models.SensorReading) []models.AnomalyDetection {
+	method := s.AnomalyMethod()
+	var anomalies []models.AnomalyDetection
+
+	if method != AnomalyMethodIQR {
+		baseline, err := s.store.GetBaseline(reading.DeviceID, reading.FilterMode)
+		if err != nil {
+			log.Printf("Warning: Failed to get baseline for anomaly detection: %v", err)
+		} else if baseline != nil {
+			anomalies = append(anomalies, s.anomalyDetector.DetectAnomalies(reading, baseline)...)
+		}
+	}
+
+	if method != AnomalyMethodZScore {
+		// Strictly earlier readings, so the new one doesn't shift its own fences
+		earlier, err := s.store.GetReadingsAround(reading.DeviceID, reading.Timestamp.Add(-time.Nanosecond), iqrHistoryWindow, 0)
+		if err != nil {
+			log.Printf("Warning: Failed to get readings for IQR anomaly detection: %v", err)
+			return anomalies
+		}
+
+		flagged := make(map[string]bool, len(anomalies))
+		for _, anomaly := range anomalies {
+			flagged[anomaly.AffectedMetric] = true
+		}
+		for _, anomaly := range s.anomalyDetector.DetectAnomaliesIQR(reading, filterReadingsByMode(earlier, reading.FilterMode)) {
+			if !flagged[anomaly.AffectedMetric] {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// predictionKey identifies a device/mode combination for prediction updates
+func predictionKey(deviceID string, filterMode models.FilterMode) string {
+	return deviceID + "|" + string(filterMode)
+}
+
+// triggerPredictionUpdate requests a background prediction update for a device/mode.
+// Updates run at most once per debounce interval: the first trigger schedules a run at the
+// end of the interval and later triggers join it. Triggers arriving while an update is
+// running schedule a single trailing run. Concurrent updates are bounded by the
+// concurrency limit. Returns false if the trigger was coalesced into an existing run.
+func (s *MLService) triggerPredictionUpdate(deviceID string, filterMode models.FilterMode, triggerReason string) bool {
+	key := predictionKey(deviceID, filterMode)
+
+	s.predictionMu.Lock()
+	defer s.predictionMu.Unlock()
+
+	state, exists := s.predictionStates[key]
+	if !exists {
+		state = &predictionState{deviceID: deviceID, filterMode: filterMode}
+		s.predictionStates[key] = state
+	}
+
+	if state.inFlight {
+		if s.predictionDebounce > 0 {
+			state.pending = true
+		}
+		return false
+	}
+	if state.timer != nil {
+		return false
+	}
+
+	if s.predictionDebounce <= 0 {
+		s.startPredictionRunLocked(state, deviceID, filterMode, triggerReason)
+	} else {
+		s.schedulePredictionRunLocked(state, deviceID, filterMode, triggerReason)
 	}
+	return true
+}
+
+// schedulePredictionRunLocked arms a debounced prediction run. Caller must hold predictionMu.
+func (s *MLService) schedulePredictionRunLocked(state *predictionState, deviceID string, filterMode models.FilterMode, triggerReason string) {
+	state.timer = time.AfterFunc(s.predictionDebounce, func() {
+		s.predictionMu.Lock()
+		defer s.predictionMu.Unlock()
+		state.timer = nil
+		s.startPredictionRunLocked(state, deviceID, filterMode, triggerReason)
+	})
+}
+
+// startPredictionRunLocked starts a prediction run bounded by the concurrency limit.
+// Caller must hold predictionMu.
+func (s *MLService) startPredictionRunLocked(state *predictionState, deviceID string, filterMode models.FilterMode, triggerReason string) {
+	state.inFlight = true
+	state.lastRun = time.Now()
+	sem := s.predictionSem
+
+	go func() {
+		sem <- struct{}{}
+		s.runPredictionUpdate(deviceID, filterMode, triggerReason)
+		<-sem
+
+		s.predictionMu.Lock()
+		defer s.predictionMu.Unlock()
+		state.inFlight = false
+		if state.pending {
+			// Trailing run for readings that arrived during this update
+			state.pending = false
+			s.schedulePredictionRunLocked(state, deviceID, filterMode, triggerReason)
+		}
+	}()
 }
 
 // baselineUpdateTask periodically updates sensor baselines
@@ -134,11 +462,17 @@ func (s *MLService) baselineUpdateTask() {
 	defer ticker.Stop()
 
 	// Run immediately on start
-	s.updateBaselines()
+	if s.RealTimeAnomalyEnabled() {
+		s.updateBaselines()
+	}
 
 	for {
 		select {
 		case <-ticker.C:
+			if s.RealTimeAnomalyEnabled() {
+				s.updateBaselines()
+			}
+		case <-s.baselineRefresh:
 			s.updateBaselines()
 		case <-s.stopChan:
 			return
@@ -150,14 +484,17 @@ func (s *MLService) baselineUpdateTask() {
 func (s *MLService) updateBaselines() {
 	log.Println("📊 Updating sensor baselines...")
 
-	devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+	devices := store.DeviceIDs(s.store)
 	modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold}
 
 	updated := 0
 	for _, device := range devices {
 		for _, mode := range modes {
-			// Get all readings for this device
+			// Get all readings for this device, since its filter was replaced
 			allReadings := s.store.GetReadingsByDevice(device)
+			if replacedAt, ok := s.lastFilterReplacement(device); ok {
+				allReadings = readingsSince(allReadings, replacedAt)
+			}
 
 			// Calculate baseline
 			baseline := s.anomalyDetector.CalculateBaseline(allReadings, device, mode)
@@ -200,29 +537,15 @@ func (s *MLService) filterHealthAnalysisTask() {
 func (s *MLService) analyzeFilterHealth() {
 	log.Println("🔬 Analyzing filter health...")
 
-	// Get recent pre and post filtration readings
-	preReadings := s.store.GetRecentReadingsByDevice("stm32_pre", 100)
-	postReadings := s.store.GetRecentReadingsByDevice("stm32_post", 100)
-
-	if len(preReadings) < 20 || len(postReadings) < 20 {
-		log.Printf("⚠️  Insufficient data for filter health analysis (pre: %d, post: %d)",
-			len(preReadings), len(postReadings))
-		return
-	}
-
-	// Get current filter mode
-	filterMode := s.store.GetCurrentFilterMode()
-
-	// Perform analysis
-	health, err := s.filterPredictor.AnalyzeFilterHealth(preReadings, postReadings, filterMode)
+	health, err := s.AnalyzeFilterHealthNow("")
 	if err != nil {
-		log.Printf("Error analyzing filter health: %v", err)
-		return
-	}
-
-	// Save to database
-	if err := s.store.SaveFilterHealth(health); err != nil {
-		log.Printf("Error saving filter health: %v", err)
+		var insufficient *InsufficientFilterDataError
+		if errors.As(err, &insufficient) {
+			log.Printf("⚠️  Insufficient data for filter health analysis (pre: %d, post: %d)",
+				insufficient.PreReadings, insufficient.PostReadings)
+		} else {
+			log.Printf("Error analyzing filter health: %v", err)
+		}
 		return
 	}
 
@@ -240,11 +563,89 @@ func (s *MLService) analyzeFilterHealth() {
 	}
 }
 
+// AnalyzeFilterHealthNow analyzes filter health from the latest pre and post
+// filtration readings, then records and broadcasts the result under the
+// post-filtration device. deviceID overrides the post-filtration device; when
+// empty the classified one is used. It returns an *InsufficientFilterDataError
+// when either device has too few readings.
+//
+// Health was first meant to default to stm32_main, the device commands are
+// sent to, but the analysis is computed from the pre and post-filtration
+// readings. Keying health by the post-filtration device ties each record to
+// the readings it came from and still keeps installations apart;
+// GetFilterHealth looks records up the same way.
+func (s *MLService) AnalyzeFilterHealthNow(deviceID string) (*models.FilterHealth, error) {
+	// Get recent pre and post filtration readings
+	preDeviceID, postDeviceID := store.ResolveFilterDevices(s.store)
+	if deviceID != "" {
+		postDeviceID = deviceID
+	}
+	preReadings := s.store.GetRecentReadingsByDevice(preDeviceID, filterHealthWindow)
+	postReadings := s.store.GetRecentReadingsByDevice(postDeviceID, filterHealthWindow)
+
+	if len(preReadings) < minFilterHealthReadings || len(postReadings) < minFilterHealthReadings {
+		return nil, &InsufficientFilterDataError{
+			PreReadings:  len(preReadings),
+			PostReadings: len(postReadings),
+			Required:     minFilterHealthReadings,
+		}
+	}
+
+	// Perform analysis for the current filter mode
+	health, err := s.filterPredictor.AnalyzeFilterHealth(postDeviceID, preReadings, postReadings, s.store.GetCurrentFilterMode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze filter health: %w", err)
+	}
+
+	if err := s.RecordFilterHealth(health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// RecordFilterHealth saves a filter health assessment and broadcasts it to live
+// clients. Every path that records filter health goes through here.
+func (s *MLService) RecordFilterHealth(health *models.FilterHealth) error {
+	if err := s.store.SaveFilterHealth(health); err != nil {
+		return fmt.Errorf("failed to save filter health: %w", err)
+	}
+
+	if s.healthBroadcaster != nil {
+		s.healthBroadcaster.BroadcastFilterHealth(health)
+	}
+	s.checkReplacementAlert(health)
+	return nil
+}
+
+// checkReplacementAlert raises a filter replacement alert when the assessment's
+// predicted days remaining crosses a threshold not yet alerted for this filter
+func (s *MLService) checkReplacementAlert(health *models.FilterHealth) {
+	threshold, crossed := s.replacementAlerter.Check(health.DeviceID, health.PredictedDaysRemaining)
+	if !crossed {
+		return
+	}
+
+	alert := &models.FilterReplacementAlert{
+		DeviceID:             health.DeviceID,
+		ThresholdDays:        threshold,
+		DaysRemaining:        health.PredictedDaysRemaining,
+		HealthScore:          health.HealthScore,
+		EstimatedReplacement: health.EstimatedReplacement,
+		Message:              fmt.Sprintf("Filter on %s needs replacing within %d days (%d predicted)", health.DeviceID, threshold, health.PredictedDaysRemaining),
+		TriggeredAt:          time.Now(),
+	}
+	log.Printf("🔔 FILTER REPLACEMENT ALERT: %s", alert.Message)
+
+	if s.replacementBroadcaster != nil {
+		s.replacementBroadcaster.BroadcastFilterReplacementAlert(alert)
+	}
+}
+
 // DetectDrift checks for sensor drift in recent readings
 func (s *MLService) DetectDrift() {
 	log.Println("📈 Checking for sensor drift...")
 
-	devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+	devices := store.DeviceIDs(s.store)
 	modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold}
 
 	driftDetected := 0
@@ -295,22 +696,73 @@ func (s *MLService) GetMLServiceStatus() map[string]interface{} {
 	running := s.running
 	s.mu.Unlock()
 
+	s.predictionMu.Lock()
+	maxConcurrentPredictions := cap(s.predictionSem)
+	predictionDebounce := s.predictionDebounce
+	predictionsInFlight := 0
+	predictionRuns := make([]PredictionRunStatus, 0, len(s.predictionStates))
+	for _, state := range s.predictionStates {
+		if state.inFlight {
+			predictionsInFlight++
+		}
+		run := PredictionRunStatus{
+			DeviceID:   state.deviceID,
+			FilterMode: state.filterMode,
+			InFlight:   state.inFlight,
+			Pending:    state.pending || state.timer != nil,
+		}
+		if !state.lastRun.IsZero() {
+			lastRun := state.lastRun
+			run.LastRun = &lastRun
+		}
+		predictionRuns = append(predictionRuns, run)
+	}
+	s.predictionMu.Unlock()
+
+	sort.Slice(predictionRuns, func(i, j int) bool {
+		if predictionRuns[i].DeviceID != predictionRuns[j].DeviceID {
+			return predictionRuns[i].DeviceID < predictionRuns[j].DeviceID
+		}
+		return predictionRuns[i].FilterMode < predictionRuns[j].FilterMode
+	})
+
 	return map[string]interface{}{
 		"running":                      running,
-		"real_time_anomaly_enabled":     s.enableRealTimeAnomaly,
+		"real_time_anomaly_enabled":     s.RealTimeAnomalyEnabled(),
 		"auto_prediction_update_enabled": s.enableAutoPredictionUpdate,
 		"baseline_update_interval":      s.baselineUpdateInterval.String(),
 		"health_analysis_interval":      s.healthAnalysisInterval.String(),
 		"prediction_update_interval":    s.predictionUpdateInterval.String(),
+		"max_concurrent_predictions":    maxConcurrentPredictions,
+		"predictions_in_flight":         predictionsInFlight,
+		"prediction_debounce_interval":  predictionDebounce.String(),
+		"prediction_runs":               predictionRuns,
 	}
 }
 
-// EnableRealTimeAnomaly enables/disables real-time anomaly detection
+// EnableRealTimeAnomaly enables/disables real-time anomaly detection. Switching
+// it on while the service runs refreshes the baselines right away.
 func (s *MLService) EnableRealTimeAnomaly(enabled bool) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	switchedOn := enabled && !s.enableRealTimeAnomaly
 	s.enableRealTimeAnomaly = enabled
+	running := s.running
+	s.mu.Unlock()
 	log.Printf("Real-time anomaly detection: %v", enabled)
+
+	if switchedOn && running {
+		select {
+		case s.baselineRefresh <- struct{}{}:
+		default: // A refresh is already pending
+		}
+	}
+}
+
+// RealTimeAnomalyEnabled reports whether new readings are checked for anomalies
+func (s *MLService) RealTimeAnomalyEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enableRealTimeAnomaly
 }
 
 // predictionUpdateTask periodically updates sensor predictions
@@ -326,6 +778,7 @@ func (s *MLService) predictionUpdateTask() {
 	for {
 		select {
 		case <-ticker.C:
+			s.ValidatePredictions()
 			s.updateAllPredictions("scheduled")
 		case <-s.stopChan:
 			return
@@ -337,7 +790,7 @@ func (s *MLService) predictionUpdateTask() {
 func (s *MLService) updateAllPredictions(triggerReason string) {
 	log.Println("🔮 Updating sensor predictions...")
 
-	devices := []string{"stm32_pre", "stm32_post", "stm32_main"}
+	devices := store.DeviceIDs(s.store)
 	modes := []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold}
 
 	updated := 0
@@ -371,10 +824,9 @@ func (s *MLService) updatePredictionsForDevice(deviceID string, filterMode model
 		return false
 	}
 
-	// Save predictions to database (note: need to implement store methods)
+	// Save predictions so they can be validated once their time has passed
 	savedCount := 0
 	for _, pred := range predictions {
-		// Convert PredictionResult to SensorPrediction model
 		sensorPred := &models.SensorPrediction{
 			DeviceID:           deviceID,
 			FilterMode:         filterMode,
@@ -385,42 +837,133 @@ func (s *MLService) updatePredictionsForDevice(deviceID string, filterMode model
 			PredictedPh:        pred.PredictedPh,
 			PredictedTurbidity: pred.PredictedTurbidity,
 			PredictedTDS:       pred.PredictedTDS,
-			IsValidated:        false,
-			CreatedAt:          time.Now(),
-			UpdatedAt:          time.Now(),
 		}
-
-		// TODO: Implement SaveSensorPrediction in store
-		// For now, skip saving until store methods are implemented
-		_ = sensorPred
+		if err := s.store.SaveSensorPrediction(sensorPred); err != nil {
+			log.Printf("Warning: Failed to save prediction for %s/%s at %s: %v", deviceID, filterMode, pred.Timestamp, err)
+			continue
+		}
 		savedCount++
 	}
 
 	// Log update
 	executionTime := int(time.Since(startTime).Milliseconds())
-	log.Printf("   ✅ Generated %d predictions for %s in %s mode (%dms)",
-		len(predictions), deviceID, filterMode, executionTime)
+	log.Printf("   ✅ Generated %d predictions for %s in %s mode, saved %d (%dms)",
+		len(predictions), deviceID, filterMode, savedCount, executionTime)
 
 	// TODO: Log to prediction_update_log table
-	_ = executionTime
 	_ = triggerReason
-	_ = savedCount
 
 	return true
 }
 
-// ValidatePredictions compares predictions with actual readings and updates accuracy
+// ValidatePredictions compares predictions whose time has passed with the
+// readings measured then and records their actual values and accuracy
 func (s *MLService) ValidatePredictions() {
 	log.Println("📊 Validating predictions against actual readings...")
+	validated := s.validatePredictionsAt(time.Now())
+	log.Printf("✅ Prediction validation complete: %d predictions validated", validated)
+}
+
+// validatePredictionsAt validates the predictions due as of now against the
+// reading of the same device and filter mode nearest their predicted time,
+// within predictionMatchWindow, returning how many were validated
+func (s *MLService) validatePredictionsAt(now time.Time) int {
+	// Wait until a matching reading could have arrived
+	due, err := s.store.GetUnvalidatedSensorPredictions(now.Add(-predictionValidationMaxAge), now.Add(-predictionMatchWindow), predictionValidationBatch)
+	if err != nil {
+		log.Printf("Warning: Failed to get predictions to validate: %v", err)
+		return 0
+	}
+	if len(due) == 0 {
+		return 0
+	}
 
-	// TODO: Implement prediction validation
-	// 1. Get unvalidated predictions
-	// 2. Find matching actual readings
-	// 3. Calculate errors
-	// 4. Update predictions with actual values and accuracy
-	// 5. Update accuracy summary
+	readings := s.store.GetReadingsInRange(
+		due[0].PredictedFor.Add(-predictionMatchWindow),
+		due[len(due)-1].PredictedFor.Add(predictionMatchWindow),
+	)
+
+	validated := 0
+	periods := make(map[accuracyPeriod]bool)
+	for i := range due {
+		prediction := &due[i]
+		var match *models.SensorReading
+		var matchGap time.Duration
+		for j := range readings {
+			reading := &readings[j]
+			if reading.DeviceID != prediction.DeviceID || reading.FilterMode != prediction.FilterMode {
+				continue
+			}
+			gap := reading.Timestamp.Sub(prediction.PredictedFor)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap <= predictionMatchWindow && (match == nil || gap < matchGap) {
+				match, matchGap = reading, gap
+			}
+		}
+		if match == nil {
+			continue
+		}
+
+		prediction.RecordActual(*match, now)
+		if err := s.store.UpdateSensorPredictionActuals(prediction); err != nil {
+			log.Printf("Warning: Failed to record actuals for prediction %d: %v", prediction.ID, err)
+			continue
+		}
+		validated++
+		periods[accuracyPeriod{
+			deviceID: prediction.DeviceID,
+			mode:     prediction.FilterMode,
+			start:    prediction.PredictedFor.UTC().Truncate(24 * time.Hour),
+		}] = true
+	}
+
+	for period := range periods {
+		s.updateAccuracySummary(period)
+	}
+
+	return validated
+}
+
+// accuracyPeriod identifies one device and filter mode's daily accuracy summary
+type accuracyPeriod struct {
+	deviceID string
+	mode     models.FilterMode
+	start    time.Time
+}
+
+// updateAccuracySummary recomputes and saves the accuracy summary of a period
+// from all of its predictions, so batches validated later update the same day
+func (s *MLService) updateAccuracySummary(period accuracyPeriod) {
+	end := period.start.Add(24 * time.Hour)
+	predictions, err := s.store.GetSensorPredictions(period.deviceID, period.start, end)
+	if err != nil {
+		log.Printf("Warning: Failed to get predictions for the %s accuracy summary: %v", period.deviceID, err)
+		return
+	}
+
+	inPeriod := predictions[:0]
+	for _, prediction := range predictions {
+		if prediction.FilterMode == period.mode && prediction.PredictedFor.Before(end) {
+			inPeriod = append(inPeriod, prediction)
+		}
+	}
+
+	summary := models.SummarizePredictionAccuracy(period.deviceID, period.mode, period.start, end, inPeriod)
+	if err := s.store.SavePredictionAccuracySummary(&summary); err != nil {
+		log.Printf("Warning: Failed to save the %s accuracy summary: %v", period.deviceID, err)
+	}
+}
+
+// GetAnomalyDetector returns the anomaly detector instance
+func (s *MLService) GetAnomalyDetector() *AnomalyDetector {
+	return s.anomalyDetector
+}
 
-	log.Println("✅ Prediction validation complete")
+// GetFilterPredictor returns the filter predictor instance
+func (s *MLService) GetFilterPredictor() *FilterPredictor {
+	return s.filterPredictor
 }
 
 // GetSensorPredictor returns the sensor predictor instance
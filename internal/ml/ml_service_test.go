@@ -0,0 +1,682 @@
+package ml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+func TestMLService_PredictionTriggersCoalescePerDevice(t *testing.T) {
+	s := NewMLService(store.NewStore(100))
+	s.SetPredictionDebounce(0)
+
+	var runs int32
+	release := make(chan struct{})
+	done := make(chan struct{}, 10)
+	s.runPredictionUpdate = func(deviceID string, filterMode models.FilterMode, triggerReason string) bool {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		done <- struct{}{}
+		return true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.triggerPredictionUpdate("stm32_post", models.FilterModeDrinking, "new_data")
+		}()
+	}
+	wg.Wait()
+
+	close(release)
+	<-done
+
+	// Give any (unexpected) extra runs a chance to start
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Errorf("Expected concurrent triggers to coalesce into 1 run, got %d", got)
+	}
+}
+
+func TestMLService_PredictionConcurrencyLimit(t *testing.T) {
+	s := NewMLService(store.NewStore(100))
+	s.SetPredictionConcurrency(1)
+	s.SetPredictionDebounce(0)
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	wg.Add(3)
+	s.runPredictionUpdate = func(deviceID string, filterMode models.FilterMode, triggerReason string) bool {
+		defer wg.Done()
+		current := atomic.AddInt32(&running, 1)
+		for {
+			observed := atomic.LoadInt32(&maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return true
+	}
+
+	for _, device := range []string{"stm32_pre", "stm32_post", "stm32_main"} {
+		if !s.triggerPredictionUpdate(device, models.FilterModeDrinking, "new_data") {
+			t.Errorf("Expected trigger for %s to be scheduled", device)
+		}
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxRunning); got != 1 {
+		t.Errorf("Expected at most 1 concurrent prediction update, got %d", got)
+	}
+}
+
+func TestMLService_PredictionTriggersDebounced(t *testing.T) {
+	s := NewMLService(store.NewStore(100))
+	s.SetPredictionDebounce(50 * time.Millisecond)
+
+	var runs int32
+	s.runPredictionUpdate = func(deviceID string, filterMode models.FilterMode, triggerReason string) bool {
+		atomic.AddInt32(&runs, 1)
+		return true
+	}
+
+	for i := 0; i < 10; i++ {
+		s.triggerPredictionUpdate("stm32_post", models.FilterModeDrinking, "new_data")
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Errorf("Expected 10 rapid triggers to produce 1 debounced run, got %d", got)
+	}
+}
+
+func TestMLService_StatusReportsPredictionRuns(t *testing.T) {
+	s := NewMLService(store.NewStore(100))
+	s.SetPredictionDebounce(time.Hour)
+	s.runPredictionUpdate = func(deviceID string, filterMode models.FilterMode, triggerReason string) bool {
+		return true
+	}
+
+	s.triggerPredictionUpdate("stm32_post", models.FilterModeDrinking, "new_data")
+	runs := s.GetMLServiceStatus()["prediction_runs"].([]PredictionRunStatus)
+	if len(runs) != 1 || !runs[0].Pending || runs[0].LastRun != nil {
+		t.Fatalf("Expected one pending device/mode that has not run yet, got %+v", runs)
+	}
+
+	// The debounce interval elapses
+	s.predictionMu.Lock()
+	state := s.predictionStates[predictionKey("stm32_post", models.FilterModeDrinking)]
+	state.timer.Stop()
+	state.timer = nil
+	s.startPredictionRunLocked(state, "stm32_post", models.FilterModeDrinking, "new_data")
+	s.predictionMu.Unlock()
+
+	runs = s.GetMLServiceStatus()["prediction_runs"].([]PredictionRunStatus)
+	if runs[0].DeviceID != "stm32_post" || runs[0].FilterMode != models.FilterModeDrinking ||
+		runs[0].LastRun == nil || time.Since(*runs[0].LastRun) > time.Minute {
+		t.Errorf("Expected the last run time of stm32_post, got %+v", runs[0])
+	}
+}
+
+func TestAlertThrottle_SuppressesWithinWindow(t *testing.T) {
+	throttle := NewAlertThrottle(15 * time.Minute)
+	start := time.Now()
+
+	if ok, suppressed := throttle.Allow("stm32_post", "ph", start); !ok || suppressed != 0 {
+		t.Fatalf("Expected the first anomaly to alert, got %v/%d", ok, suppressed)
+	}
+	for i := 1; i <= 3; i++ {
+		if ok, _ := throttle.Allow("stm32_post", "ph", start.Add(time.Duration(i)*time.Minute)); ok {
+			t.Errorf("Expected anomaly %d within the window to be suppressed", i)
+		}
+	}
+	// Other metrics and devices are throttled separately
+	if ok, _ := throttle.Allow("stm32_post", "tds", start.Add(time.Minute)); !ok {
+		t.Error("Expected a different metric to alert")
+	}
+
+	ok, suppressed := throttle.Allow("stm32_post", "ph", start.Add(15*time.Minute))
+	if !ok || suppressed != 3 {
+		t.Errorf("Expected an alert after the window with 3 suppressed, got %v/%d", ok, suppressed)
+	}
+	if ok, suppressed := throttle.Allow("stm32_post", "ph", start.Add(31*time.Minute)); !ok || suppressed != 0 {
+		t.Errorf("Expected the suppressed count to reset after an alert, got %v/%d", ok, suppressed)
+	}
+}
+
+func TestMLService_ThrottlesRepeatedAnomalyAlerts(t *testing.T) {
+	dataStore := store.NewStore(100)
+	if err := dataStore.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 100,
+		FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+		TurbidityMean: 1.0, TurbidityStdDev: 0.1, TDSMean: 50, TDSStdDev: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+
+	s := NewMLService(dataStore)
+	s.EnableRealTimeAnomaly(true)
+	s.SetAlertThrottleWindow(200 * time.Millisecond)
+
+	// pH far above the baseline on every reading
+	process := func() {
+		s.ProcessNewReading(&models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 9.0, Turbidity: 1.0, TDS: 50,
+		})
+	}
+	for i := 0; i < 4; i++ {
+		process()
+	}
+	time.Sleep(250 * time.Millisecond)
+	process()
+
+	anomalies, err := dataStore.GetAnomaliesByDevice("stm32_post", 10)
+	if err != nil {
+		t.Fatalf("Failed to get anomalies: %v", err)
+	}
+	if len(anomalies) != 5 {
+		t.Fatalf("Expected every anomaly to be recorded, got %d", len(anomalies))
+	}
+
+	var alerts []models.AnomalyDetection
+	for _, anomaly := range anomalies {
+		if anomaly.AlertSent {
+			alerts = append(alerts, anomaly)
+		}
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 alerts (first and after the window), got %d", len(alerts))
+	}
+	counts := map[int]bool{alerts[0].SuppressedCount: true, alerts[1].SuppressedCount: true}
+	if !counts[0] || !counts[3] {
+		t.Errorf("Expected suppressed counts 0 and 3, got %d and %d", alerts[0].SuppressedCount, alerts[1].SuppressedCount)
+	}
+}
+
+func TestMLService_PostsHighSeverityAnomaliesToWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var received []models.AnomalyDetection
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // Retried
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON POST, got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var anomaly models.AnomalyDetection
+		if err := json.NewDecoder(r.Body).Decode(&anomaly); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		received = append(received, anomaly)
+	}))
+	defer server.Close()
+
+	dataStore := store.NewStore(100)
+	if err := dataStore.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 100,
+		FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+		TurbidityMean: 1.0, TurbidityStdDev: 0.1, TDSMean: 50, TDSStdDev: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+
+	s := NewMLService(dataStore)
+	s.EnableRealTimeAnomaly(true)
+	s.SetAlertWebhook(server.URL, time.Second)
+	s.alertNotifier.backoff = time.Millisecond
+
+	// A critical pH spike (20 sigma) and a medium TDS one (4 sigma)
+	s.ProcessNewReading(&models.SensorReading{
+		DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking,
+		Flow: 2.0, Ph: 9.0, Turbidity: 1.0, TDS: 54,
+	})
+	s.alertNotifier.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 || len(received) != 1 {
+		t.Fatalf("Expected the critical alert to be delivered on the retry, got %d attempts and %d deliveries", attempts, len(received))
+	}
+	alert := received[0]
+	if alert.AffectedMetric != "ph" || alert.Severity != "critical" || alert.DeviceID != "stm32_post" || alert.ActualValue != 9.0 {
+		t.Errorf("Expected the pH anomaly in the payload, got %+v", alert)
+	}
+	if alert.ID == 0 || !alert.AlertSent {
+		t.Errorf("Expected the saved anomaly with alert_sent=true, got id %d alert_sent=%v", alert.ID, alert.AlertSent)
+	}
+
+	anomalies, _ := dataStore.GetAnomaliesByDevice("stm32_post", 10)
+	if len(anomalies) != 2 {
+		t.Errorf("Expected both anomalies to be recorded, got %d", len(anomalies))
+	}
+}
+
+func TestMLService_UndeliveredWebhookAlertIsNotMarkedSent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dataStore := store.NewStore(100)
+	if err := dataStore.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 100,
+		FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+		TurbidityMean: 1.0, TurbidityStdDev: 0.1, TDSMean: 50, TDSStdDev: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+
+	s := NewMLService(dataStore)
+	s.EnableRealTimeAnomaly(true)
+	s.SetAlertWebhook(server.URL, time.Second)
+	s.alertNotifier.backoff = time.Millisecond
+
+	// A critical pH spike (20 sigma) whose alert the webhook keeps rejecting
+	s.ProcessNewReading(&models.SensorReading{
+		DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking,
+		Flow: 2.0, Ph: 9.0, Turbidity: 1.0, TDS: 50,
+	})
+	s.alertNotifier.Wait()
+
+	anomalies, _ := dataStore.GetAnomaliesByDevice("stm32_post", 10)
+	if len(anomalies) != 1 || anomalies[0].AffectedMetric != "ph" {
+		t.Fatalf("Expected the pH anomaly recorded, got %+v", anomalies)
+	}
+	if anomalies[0].AlertSent {
+		t.Error("Expected alert_sent=false once every delivery attempt failed")
+	}
+}
+
+func TestMLService_MutedMetricsDoNotAlert(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var anomaly models.AnomalyDetection
+		if err := json.NewDecoder(r.Body).Decode(&anomaly); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, anomaly.AffectedMetric)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dataStore := store.NewStore(100)
+	if err := dataStore.SaveBaseline(&models.SensorBaseline{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeHousehold, SampleSize: 100,
+		FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+		TurbidityMean: 1.0, TurbidityStdDev: 0.1, TDSMean: 50, TDSStdDev: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+	// A household-water user doesn't care about drinking water TDS
+	if err := dataStore.SetAlertPreferences(models.AlertPreferences{DeviceID: "stm32_post", MutedMetrics: []string{"tds"}}); err != nil {
+		t.Fatalf("Failed to set alert preferences: %v", err)
+	}
+
+	s := NewMLService(dataStore)
+	s.EnableRealTimeAnomaly(true)
+	s.SetAlertWebhook(server.URL, time.Second)
+
+	// Critical pH (20 sigma) and TDS (10 sigma) spikes
+	s.ProcessNewReading(&models.SensorReading{
+		DeviceID: "stm32_post", Timestamp: time.Now(), FilterMode: models.FilterModeHousehold,
+		Flow: 2.0, Ph: 9.0, Turbidity: 1.0, TDS: 60,
+	})
+	s.alertNotifier.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != "ph" {
+		t.Errorf("Expected only the pH anomaly to be posted, got %v", received)
+	}
+
+	anomalies, _ := dataStore.GetAnomaliesByDevice("stm32_post", 10)
+	if len(anomalies) != 2 {
+		t.Fatalf("Expected the muted anomaly to be recorded too, got %d anomalies", len(anomalies))
+	}
+	for _, anomaly := range anomalies {
+		if wantAlert := anomaly.AffectedMetric != "tds"; anomaly.AlertSent != wantAlert {
+			t.Errorf("Expected %s alert_sent=%v, got %v", anomaly.AffectedMetric, wantAlert, anomaly.AlertSent)
+		}
+	}
+}
+
+// recordingReplacementBroadcaster collects filter replacement alerts
+type recordingReplacementBroadcaster struct {
+	alerts []models.FilterReplacementAlert
+}
+
+func (b *recordingReplacementBroadcaster) BroadcastFilterReplacementAlert(alert *models.FilterReplacementAlert) {
+	b.alerts = append(b.alerts, *alert)
+}
+
+func TestMLService_ReplacementAlertsOncePerThreshold(t *testing.T) {
+	s := NewMLService(store.NewStore(10))
+	s.SetReplacementAlertDays([]int{7, 14, 7, 0})
+	broadcaster := &recordingReplacementBroadcaster{}
+	s.SetReplacementAlertBroadcaster(broadcaster)
+
+	record := func(deviceID string, daysRemaining int) {
+		t.Helper()
+		health := &models.FilterHealth{DeviceID: deviceID, HealthScore: 60, PredictedDaysRemaining: daysRemaining}
+		if err := s.RecordFilterHealth(health); err != nil {
+			t.Fatalf("Failed to record filter health: %v", err)
+		}
+	}
+
+	// Above the thresholds, crossing 14, staying between, crossing 7, staying below 7
+	for _, days := range []int{30, 20, 14, 12, 10, 7, 5, 3, 6, 1} {
+		record("stm32_post", days)
+	}
+	if len(broadcaster.alerts) != 2 {
+		t.Fatalf("Expected 2 alerts (14 then 7 days), got %d: %+v", len(broadcaster.alerts), broadcaster.alerts)
+	}
+	if broadcaster.alerts[0].ThresholdDays != 14 || broadcaster.alerts[0].DaysRemaining != 14 {
+		t.Errorf("Expected the first alert at the 14 day threshold, got %+v", broadcaster.alerts[0])
+	}
+	if broadcaster.alerts[1].ThresholdDays != 7 || broadcaster.alerts[1].DaysRemaining != 7 {
+		t.Errorf("Expected the second alert at the 7 day threshold, got %+v", broadcaster.alerts[1])
+	}
+
+	// An estimate oscillating back above the thresholds doesn't re-arm them
+	record("stm32_post", 20)
+	record("stm32_post", 5)
+	if len(broadcaster.alerts) != 2 {
+		t.Fatalf("Expected no alerts from an oscillating estimate, got %+v", broadcaster.alerts)
+	}
+
+	// Replacing the filter re-arms the alerts, even with the baseline reset off
+	if _, err := s.HandleFilterReplacement("stm32_post", time.Now()); err != nil {
+		t.Fatalf("Unexpected error handling the replacement: %v", err)
+	}
+	record("stm32_post", 90)
+	record("stm32_post", 4)
+	if len(broadcaster.alerts) != 3 || broadcaster.alerts[2].ThresholdDays != 7 {
+		t.Fatalf("Expected one alert for the new filter skipping straight to 7 days, got %+v", broadcaster.alerts)
+	}
+
+	// Devices are tracked separately
+	record("stm32_main", 13)
+	if len(broadcaster.alerts) != 4 || broadcaster.alerts[3].DeviceID != "stm32_main" {
+		t.Errorf("Expected another device to alert independently, got %+v", broadcaster.alerts)
+	}
+}
+
+func TestMLService_ValidatePredictionsMatchesNearestReading(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	predict := func(deviceID string, at time.Time, ph float64) {
+		s.SaveSensorPrediction(&models.SensorPrediction{
+			DeviceID:     deviceID,
+			FilterMode:   models.FilterModeDrinking,
+			PredictedFor: at,
+			PredictedPh:  ph,
+		})
+	}
+	measure := func(deviceID string, at time.Time, ph float64) {
+		s.AddSensorReading(models.SensorReading{DeviceID: deviceID, Timestamp: at, FilterMode: models.FilterModeDrinking, Ph: ph})
+	}
+
+	predict("stm32_pre", now.Add(-2*time.Hour), 7.0)   // Readings 4 and 1 minutes away: the nearer one matches
+	predict("stm32_pre", now.Add(-time.Hour), 7.2)     // Nearest reading is 8 minutes away: no match
+	predict("stm32_post", now.Add(-2*time.Hour), 7.4)  // Another device's reading must not match
+	predict("stm32_pre", now.Add(-2*time.Minute), 7.1) // Not due yet: a reading could still arrive
+	measure("stm32_pre", now.Add(-2*time.Hour-4*time.Minute), 6.0)
+	measure("stm32_pre", now.Add(-2*time.Hour+time.Minute), 7.5)
+	measure("stm32_pre", now.Add(-time.Hour+8*time.Minute), 7.2)
+	measure("stm32_pre", now.Add(-2*time.Minute), 7.1)
+
+	service := NewMLService(s)
+	if validated := service.validatePredictionsAt(now); validated != 1 {
+		t.Fatalf("Expected 1 prediction validated, got %d", validated)
+	}
+
+	predictions, _ := s.GetSensorPredictions("stm32_pre", now.Add(-3*time.Hour), now)
+	if len(predictions) != 3 {
+		t.Fatalf("Expected 3 stm32_pre predictions, got %d", len(predictions))
+	}
+	first := predictions[0]
+	if !first.IsValidated || first.ActualPh == nil || *first.ActualPh != 7.5 || first.ValidatedAt == nil {
+		t.Fatalf("Expected the 2h-old prediction validated against the nearest reading (7.5), got %+v", first)
+	}
+	if *first.PhError != 0.5 || first.OverallAccuracy == nil {
+		t.Errorf("Expected a pH error of 0.5 and an overall accuracy, got %+v", first)
+	}
+	for _, prediction := range predictions[1:] {
+		if prediction.IsValidated || prediction.ActualMetric("ph") != nil {
+			t.Errorf("Expected the prediction for %s to stay unvalidated, got %+v", prediction.PredictedFor, prediction)
+		}
+	}
+
+	// Re-generating a validated prediction keeps its actuals
+	predict("stm32_pre", now.Add(-2*time.Hour), 9.0)
+	predictions, _ = s.GetSensorPredictions("stm32_pre", now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	if len(predictions) != 1 || predictions[0].PredictedPh != 7.0 || !predictions[0].IsValidated {
+		t.Errorf("Expected the validated prediction to be kept, got %+v", predictions)
+	}
+}
+
+func TestMLService_ValidatePredictionsSavesDailyAccuracySummary(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	predict := func(at time.Time, mode models.FilterMode, ph float64) {
+		s.SaveSensorPrediction(&models.SensorPrediction{
+			DeviceID:           "stm32_pre",
+			FilterMode:         mode,
+			PredictedFor:       at,
+			PredictionMethod:   "linear_regression",
+			PredictedFlow:      2,
+			PredictedPh:        ph,
+			PredictedTurbidity: 1,
+			PredictedTDS:       100,
+		})
+	}
+	measure := func(at time.Time, mode models.FilterMode) {
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: at, FilterMode: mode, Flow: 2, Ph: 7, Turbidity: 1, TDS: 100})
+	}
+
+	predict(now.Add(-3*time.Hour), models.FilterModeDrinking, 7.0)  // Exact: 100%
+	predict(now.Add(-2*time.Hour), models.FilterModeDrinking, 7.7)  // pH 10% off: 97.5%
+	predict(now.Add(-time.Hour), models.FilterModeDrinking, 7.0)    // No reading: unvalidated
+	predict(now.Add(-2*time.Hour), models.FilterModeHousehold, 7.0) // Another mode's summary
+	measure(now.Add(-3*time.Hour), models.FilterModeDrinking)
+	measure(now.Add(-2*time.Hour), models.FilterModeDrinking)
+	measure(now.Add(-2*time.Hour), models.FilterModeHousehold)
+
+	service := NewMLService(s)
+	if validated := service.validatePredictionsAt(now); validated != 3 {
+		t.Fatalf("Expected 3 predictions validated, got %d", validated)
+	}
+
+	summaries, _ := s.GetPredictionAccuracyHistory("stm32_pre", 0)
+	if len(summaries) != 2 {
+		t.Fatalf("Expected a summary per filter mode, got %+v", summaries)
+	}
+	var drinking *models.PredictionAccuracySummary
+	for i := range summaries {
+		if summaries[i].FilterMode == models.FilterModeDrinking {
+			drinking = &summaries[i]
+		}
+	}
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if drinking == nil || !drinking.PeriodStart.Equal(day) || !drinking.PeriodEnd.Equal(day.Add(24*time.Hour)) {
+		t.Fatalf("Expected a drinking summary for the day of the predictions, got %+v", summaries)
+	}
+	if drinking.TotalPredictions != 3 || drinking.ValidatedPredictions != 2 || drinking.ModelVersion != "linear_regression" {
+		t.Errorf("Expected 2 of 3 predictions validated, got %+v", drinking)
+	}
+	if math.Abs(drinking.AvgPhAccuracy-95) > 1e-9 || drinking.AvgTDSAccuracy != 100 || math.Abs(drinking.OverallAccuracy-98.75) > 1e-9 {
+		t.Errorf("Expected pH accuracy 95 and overall 98.75, got %+v", drinking)
+	}
+
+	// A later batch updates the same day's summary rather than adding one
+	measure(now.Add(-time.Hour), models.FilterModeDrinking)
+	if validated := service.validatePredictionsAt(now.Add(time.Hour)); validated != 1 {
+		t.Fatalf("Expected the remaining prediction validated, got %d", validated)
+	}
+	summaries, _ = s.GetPredictionAccuracyHistory("stm32_pre", 0)
+	if len(summaries) != 2 {
+		t.Fatalf("Expected the summaries updated in place, got %+v", summaries)
+	}
+	for _, summary := range summaries {
+		if summary.FilterMode == models.FilterModeDrinking && summary.ValidatedPredictions != 3 {
+			t.Errorf("Expected 3 validated drinking predictions, got %+v", summary)
+		}
+	}
+}
+
+func TestMLService_FilterReplacementResetsBaselinesAndResolvesAnomalies(t *testing.T) {
+	dataStore := store.NewStore(100)
+	replacedAt := time.Now().Add(-time.Hour)
+	for _, mode := range []models.FilterMode{models.FilterModeDrinking, models.FilterModeHousehold} {
+		dataStore.SaveBaseline(&models.SensorBaseline{
+			DeviceID: "stm32_post", FilterMode: mode, SampleSize: 200,
+			FlowMean: 1.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+			TurbidityMean: 5.0, TurbidityStdDev: 0.5, TDSMean: 300, TDSStdDev: 10,
+		})
+	}
+	// The old filter's readings, then a dozen from the new one in drinking mode
+	for i := 0; i < 20; i++ {
+		dataStore.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: replacedAt.Add(-time.Duration(i+1) * time.Minute), FilterMode: models.FilterModeDrinking,
+			Flow: 1.0, Ph: 7.0, Turbidity: 5.0, TDS: 300,
+		})
+	}
+	for i := 0; i < 12; i++ {
+		dataStore.AddSensorReading(models.SensorReading{
+			DeviceID: "stm32_post", Timestamp: replacedAt.Add(time.Duration(i+1) * time.Minute), FilterMode: models.FilterModeDrinking,
+			Flow: 2.0, Ph: 7.0, Turbidity: 0.5 + float64(i%2)*0.1, TDS: 50,
+		})
+	}
+
+	anomaly := func(deviceID, metric string, detectedAt time.Time) {
+		dataStore.SaveAnomaly(&models.AnomalyDetection{DeviceID: deviceID, AffectedMetric: metric, DetectedAt: detectedAt, Severity: "high"})
+	}
+	anomaly("stm32_post", "turbidity", replacedAt.Add(-30*time.Minute)) // Resolved
+	anomaly("stm32_post", "tds", replacedAt.Add(-10*time.Minute))       // Resolved
+	anomaly("stm32_post", "ph", replacedAt.Add(-10*time.Minute))        // Not efficiency related
+	anomaly("stm32_post", "turbidity", replacedAt.Add(time.Minute))     // About the new filter
+	anomaly("stm32_pre", "turbidity", replacedAt.Add(-10*time.Minute))  // Another device
+
+	s := NewMLService(dataStore)
+	if reset, err := s.HandleFilterReplacement("stm32_post", replacedAt); err != nil || reset != nil {
+		t.Fatalf("Expected no reset while disabled, got %+v, %v", reset, err)
+	}
+
+	s.SetBaselineResetOnFilterReplacement(true)
+	reset, err := s.HandleFilterReplacement("stm32_post", replacedAt)
+	if err != nil {
+		t.Fatalf("Failed to handle filter replacement: %v", err)
+	}
+	if len(reset.BaselinesReset) != 2 || reset.AnomaliesResolved != 2 {
+		t.Fatalf("Expected 2 baselines reset and 2 anomalies resolved, got %+v", reset)
+	}
+
+	drinking, _ := dataStore.GetBaseline("stm32_post", models.FilterModeDrinking)
+	if drinking.SampleSize != 12 || drinking.TDSMean != 50 || drinking.FlowMean != 2.0 {
+		t.Errorf("Expected the drinking baseline recomputed from the new filter's readings, got %+v", drinking)
+	}
+	household, _ := dataStore.GetBaseline("stm32_post", models.FilterModeHousehold)
+	if household.SampleSize != 0 || household.TDSMean != 0 {
+		t.Errorf("Expected the household baseline reset until it has new readings, got %+v", household)
+	}
+
+	open, _ := dataStore.GetUnresolvedAnomalies()
+	if len(open) != 3 {
+		t.Fatalf("Expected 3 anomalies left open, got %+v", open)
+	}
+	for _, anomaly := range open {
+		if anomaly.DeviceID == "stm32_post" && anomaly.AffectedMetric != "ph" && anomaly.DetectedAt.Before(replacedAt) {
+			t.Errorf("Expected efficiency anomaly before the replacement to be resolved, got %+v", anomaly)
+		}
+	}
+
+	// Periodic updates keep ignoring the old filter's readings
+	dataStore.AddMaintenanceEvent(&models.MaintenanceEvent{
+		DeviceID: "stm32_post", Type: models.MaintenanceFilterReplacement, PerformedAt: replacedAt, PerformedBy: "tech",
+	})
+	s.updateBaselines()
+	if drinking, _ := dataStore.GetBaseline("stm32_post", models.FilterModeDrinking); drinking.SampleSize != 12 {
+		t.Errorf("Expected the hourly update to only use readings since the replacement, got n=%d", drinking.SampleSize)
+	}
+}
+
+func TestMLService_AnomalyMethodSelectsZScoreIQROrBoth(t *testing.T) {
+	now := time.Now()
+	newStore := func() *store.Store {
+		dataStore := store.NewStore(100)
+		// Wide baseline spread on turbidity, so only the IQR method notices the jump
+		dataStore.SaveBaseline(&models.SensorBaseline{
+			DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, SampleSize: 100,
+			FlowMean: 2.0, FlowStdDev: 0.1, PhMean: 7.0, PhStdDev: 0.1,
+			TurbidityMean: 1.0, TurbidityStdDev: 5, TDSMean: 50, TDSStdDev: 1,
+		})
+		for i := 0; i < 20; i++ {
+			dataStore.AddSensorReading(models.SensorReading{
+				DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, Timestamp: now.Add(-time.Duration(20-i) * time.Minute),
+				Flow: 2.0, Ph: 7.0, Turbidity: 1.0 + float64(i%3)*0.1, TDS: 49 + float64(i%3),
+			})
+		}
+		return dataStore
+	}
+	// TDS far outside both the baseline and the recent readings; turbidity only outside the latter
+	reading := models.SensorReading{
+		DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, Timestamp: now,
+		Flow: 2.0, Ph: 7.0, Turbidity: 3.0, TDS: 90,
+	}
+
+	for _, tc := range []struct {
+		method   AnomalyMethod
+		expected map[string]string // Metric to anomaly type
+	}{
+		{AnomalyMethodZScore, map[string]string{"tds": "spike"}},
+		{AnomalyMethodIQR, map[string]string{"tds": "outlier", "turbidity": "outlier"}},
+		{AnomalyMethodBoth, map[string]string{"tds": "spike", "turbidity": "outlier"}},
+	} {
+		dataStore := newStore()
+		s := NewMLService(dataStore)
+		s.enableAutoPredictionUpdate = false
+		s.EnableRealTimeAnomaly(true)
+		if err := s.SetAnomalyMethod(tc.method); err != nil {
+			t.Fatalf("Failed to set method %s: %v", tc.method, err)
+		}
+		dataStore.AddSensorReading(reading)
+		s.ProcessNewReading(&reading)
+
+		anomalies, _ := dataStore.GetAnomaliesByDevice("stm32_post", 10)
+		got := map[string]string{}
+		for _, anomaly := range anomalies {
+			got[anomaly.AffectedMetric] = anomaly.AnomalyType
+		}
+		if len(anomalies) != len(tc.expected) || fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+			t.Errorf("Method %s: expected %v, got %v", tc.method, tc.expected, got)
+		}
+	}
+
+	if err := NewMLService(store.NewStore(10)).SetAnomalyMethod("mad"); err == nil {
+		t.Error("Expected an unknown method to be rejected")
+	}
+}
@@ -0,0 +1,86 @@
+package ml
+
+import (
+	"sort"
+	"sync"
+)
+
+// ReplacementAlerter decides when a filter's predicted days remaining has
+// crossed one of the configured day thresholds. Each threshold alerts once per
+// filter install: the lowest threshold alerted is remembered per device until
+// the device is reset when its filter is replaced. A prediction climbing back
+// above the thresholds doesn't re-arm them, so a noisy estimate can't repeat
+// the alerts.
+type ReplacementAlerter struct {
+	thresholds []int // Descending, positive and unique
+	mu         sync.Mutex
+	alerted    map[string]int // key: device ID, value: lowest threshold alerted
+}
+
+// NewReplacementAlerter creates an alerter for the given day thresholds;
+// non-positive and duplicate thresholds are ignored, and none disables alerts
+func NewReplacementAlerter(thresholds []int) *ReplacementAlerter {
+	unique := make(map[int]bool)
+	sorted := []int{}
+	for _, days := range thresholds {
+		if days > 0 && !unique[days] {
+			unique[days] = true
+			sorted = append(sorted, days)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	return &ReplacementAlerter{
+		thresholds: sorted,
+		alerted:    make(map[string]int),
+	}
+}
+
+// Thresholds returns the alert thresholds in days, highest first
+func (a *ReplacementAlerter) Thresholds() []int {
+	if a == nil {
+		return nil
+	}
+	return append([]int(nil), a.thresholds...)
+}
+
+// Check reports whether daysRemaining for the device crossed a threshold that
+// has not alerted since the filter was installed, returning that threshold.
+// When a prediction skips past several thresholds at once only the lowest
+// (most urgent) one alerts.
+func (a *ReplacementAlerter) Check(deviceID string, daysRemaining int) (int, bool) {
+	if a == nil || len(a.thresholds) == 0 {
+		return 0, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	crossed := 0
+	for _, days := range a.thresholds {
+		if daysRemaining <= days {
+			crossed = days
+		}
+	}
+
+	if crossed == 0 {
+		return 0, false
+	}
+
+	if last, exists := a.alerted[deviceID]; exists && crossed >= last {
+		return 0, false
+	}
+	a.alerted[deviceID] = crossed
+	return crossed, true
+}
+
+// Reset re-arms every threshold for the device, e.g. after its filter is replaced
+func (a *ReplacementAlerter) Reset(deviceID string) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.alerted, deviceID)
+}
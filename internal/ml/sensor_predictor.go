@@ -8,19 +8,35 @@ import (
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 )
 
-// SensorPredictor provides time-series prediction for sensor values
+// SensorPredictor provides time-series prediction for sensor values using
+// additive Holt-Winters (triple exponential smoothing). Alpha, Beta and Gamma
+// weight how quickly the level, trend and seasonal components follow new
+// readings; higher values react faster but carry more noise into forecasts.
 type SensorPredictor struct {
-	minHistoricalData int     // Minimum readings needed for prediction
-	forecastHorizon   int     // How many time steps to predict ahead
-	smoothingAlpha    float64 // Exponential smoothing parameter (0-1)
+	minHistoricalData int // Minimum readings needed for prediction
+	forecastHorizon   int // How many time steps to predict ahead
+
+	Alpha          float64       // Level smoothing (0-1)
+	Beta           float64       // Trend smoothing (0-1)
+	Gamma          float64       // Seasonal smoothing (0-1)
+	SeasonalPeriod time.Duration // Length of one season, normally a day (0 = no seasonality)
 }
 
+// Prediction methods reported on each result
+const (
+	MethodHoltWinters = "holt_winters"      // Level, trend and seasonal components
+	MethodHoltLinear  = "holt_linear_trend" // Level and trend only: too little history for a full season
+)
+
 // NewSensorPredictor creates a new sensor predictor
 func NewSensorPredictor() *SensorPredictor {
 	return &SensorPredictor{
-		minHistoricalData: 50,  // Need at least 50 historical readings
-		forecastHorizon:   24,  // Predict next 24 readings (e.g., 24 hours)
-		smoothingAlpha:    0.3, // Weight for exponential smoothing
+		minHistoricalData: 50, // Need at least 50 historical readings
+		forecastHorizon:   24, // Predict next 24 readings (e.g., 24 hours)
+		Alpha:             0.3,
+		Beta:              0.05,
+		Gamma:             0.2,
+		SeasonalPeriod:    24 * time.Hour, // Daily usage and water quality cycle
 	}
 }
 
@@ -32,7 +48,7 @@ type PredictionResult struct {
 	PredictedTurbidity float64
 	PredictedTDS     float64
 	ConfidenceScore  float64 // 0-1, based on prediction variance
-	Method           string  // "exponential_smoothing", "trend_analysis", etc.
+	Method           string  // MethodHoltWinters or MethodHoltLinear
 }
 
 // PredictSensorValues predicts future sensor values based on historical data
@@ -85,8 +101,7 @@ type Pattern struct {
 	TurbidityStdDev  float64
 	TDSStdDev        float64
 
-	HasCyclicPattern bool
-	CyclePeriod      int // Number of readings per cycle
+	SeasonLength     int // Readings per season; 0 without enough history for seasonality
 
 	IsStable         bool // Low variance indicates stability
 }
@@ -132,16 +147,14 @@ func (sp *SensorPredictor) analyzePatterns(readings []models.SensorReading) Patt
 		pattern.IsStable = coefficientOfVariation < 0.2 // Less than 20% variation
 	}
 
-	// Detect cyclic patterns (simplified - check for autocorrelation)
-	pattern.HasCyclicPattern = sp.detectCyclicPattern(flowVals)
-	if pattern.HasCyclicPattern {
-		pattern.CyclePeriod = 24 // Assume daily cycle (24 hours)
-	}
+	// Seasonality needs two full seasons of readings to initialize
+	pattern.SeasonLength = sp.seasonLength(readings, sp.estimateTimeInterval(readings))
 
 	return pattern
 }
 
-// generatePredictions creates future predictions based on patterns
+// generatePredictions fits Holt-Winters to each metric and forecasts the
+// horizon, falling back to Holt's linear trend without a full season history
 func (sp *SensorPredictor) generatePredictions(
 	readings []models.SensorReading,
 	patterns Pattern,
@@ -150,62 +163,135 @@ func (sp *SensorPredictor) generatePredictions(
 	predictions := make([]PredictionResult, sp.forecastHorizon)
 
 	// Get last reading timestamp
-	lastReading := readings[len(readings)-1]
-	lastTimestamp := lastReading.Timestamp
+	lastTimestamp := readings[len(readings)-1].Timestamp
 
 	// Determine time interval between readings
 	timeInterval := sp.estimateTimeInterval(readings)
 
-	// Use exponential smoothing with trend
-	flowSmoothed := lastReading.Flow
-	phSmoothed := lastReading.Ph
-	turbiditySmoothed := lastReading.Turbidity
-	tdsSmoothed := lastReading.TDS
+	flowVals := make([]float64, len(readings))
+	phVals := make([]float64, len(readings))
+	turbidityVals := make([]float64, len(readings))
+	tdsVals := make([]float64, len(readings))
+	for i, r := range readings {
+		flowVals[i] = r.Flow
+		phVals[i] = r.Ph
+		turbidityVals[i] = r.Turbidity
+		tdsVals[i] = r.TDS
+	}
 
-	for i := 0; i < sp.forecastHorizon; i++ {
-		// Apply exponential smoothing with trend
-		flowSmoothed = flowSmoothed + patterns.FlowTrend
-		phSmoothed = phSmoothed + patterns.PhTrend
-		turbiditySmoothed = turbiditySmoothed + patterns.TurbidityTrend
-		tdsSmoothed = tdsSmoothed + patterns.TDSTrend
-
-		// Add mean reversion (values tend to revert to historical mean)
-		meanReversionFactor := 0.1 // 10% pull towards mean
-		flowSmoothed = flowSmoothed + (patterns.FlowMean-flowSmoothed)*meanReversionFactor
-		phSmoothed = phSmoothed + (patterns.PhMean-phSmoothed)*meanReversionFactor
-		turbiditySmoothed = turbiditySmoothed + (patterns.TurbidityMean-turbiditySmoothed)*meanReversionFactor
-		tdsSmoothed = tdsSmoothed + (patterns.TDSMean-tdsSmoothed)*meanReversionFactor
-
-		// Add cyclic component if detected
-		if patterns.HasCyclicPattern {
-			cyclicOffset := math.Sin(2 * math.Pi * float64(i) / float64(patterns.CyclePeriod))
-			flowSmoothed += cyclicOffset * patterns.FlowStdDev * 0.3
-			turbiditySmoothed += cyclicOffset * patterns.TurbidityStdDev * 0.3
-		}
+	flowModel := sp.fitHoltWinters(flowVals, patterns.SeasonLength)
+	phModel := sp.fitHoltWinters(phVals, patterns.SeasonLength)
+	turbidityModel := sp.fitHoltWinters(turbidityVals, patterns.SeasonLength)
+	tdsModel := sp.fitHoltWinters(tdsVals, patterns.SeasonLength)
+
+	method := MethodHoltLinear
+	if patterns.SeasonLength > 0 {
+		method = MethodHoltWinters
+	}
 
-		// Calculate confidence (decreases with distance into future)
-		confidenceScore := sp.calculateConfidence(i, patterns)
+	for i := 0; i < sp.forecastHorizon; i++ {
+		steps := i + 1
 
 		// Ensure values are within valid ranges
-		flowSmoothed = sp.clampValue(flowSmoothed, 0, 50)
-		phSmoothed = sp.clampValue(phSmoothed, 0, 14)
-		turbiditySmoothed = sp.clampValue(turbiditySmoothed, 0, 100)
-		tdsSmoothed = sp.clampValue(tdsSmoothed, 0, 1000)
+		flow := sp.clampValue(flowModel.forecast(steps), 0, 50)
+		ph := sp.clampValue(phModel.forecast(steps), 0, 14)
+		turbidity := sp.clampValue(turbidityModel.forecast(steps), 0, 100)
+		tds := sp.clampValue(tdsModel.forecast(steps), 0, 1000)
 
 		predictions[i] = PredictionResult{
-			Timestamp:          lastTimestamp.Add(time.Duration(i+1) * timeInterval),
-			PredictedFlow:      sp.roundTo2Decimals(flowSmoothed),
-			PredictedPh:        sp.roundTo2Decimals(phSmoothed),
-			PredictedTurbidity: sp.roundTo2Decimals(turbiditySmoothed),
-			PredictedTDS:       sp.roundTo2Decimals(tdsSmoothed),
-			ConfidenceScore:    confidenceScore,
-			Method:             "exponential_smoothing_with_trend",
+			Timestamp:          lastTimestamp.Add(time.Duration(steps) * timeInterval),
+			PredictedFlow:      sp.roundTo2Decimals(flow),
+			PredictedPh:        sp.roundTo2Decimals(ph),
+			PredictedTurbidity: sp.roundTo2Decimals(turbidity),
+			PredictedTDS:       sp.roundTo2Decimals(tds),
+			ConfidenceScore:    sp.calculateConfidence(i, patterns),
+			Method:             method,
 		}
 	}
 
 	return predictions
 }
 
+// holtWinters is a series' smoothed components after its last observation
+type holtWinters struct {
+	level    float64
+	trend    float64   // Change per reading
+	season   []float64 // Additive offset per position in the season; nil without seasonality
+	observed int       // Readings the model was fitted to
+}
+
+// fitHoltWinters smooths values into level, trend and, with a season length,
+// seasonal components. The seasonal model starts from the first season's mean,
+// the change between the first two seasons' means and each position's average
+// offset from its season's mean over every complete season.
+func (sp *SensorPredictor) fitHoltWinters(values []float64, seasonLength int) holtWinters {
+	model := holtWinters{observed: len(values)}
+	if len(values) == 0 {
+		return model
+	}
+
+	start := 1
+	model.level = values[0]
+	if seasonLength > 0 && len(values) >= 2*seasonLength {
+		firstMean, _ := sp.calcMeanStdDev(values[:seasonLength])
+		secondMean, _ := sp.calcMeanStdDev(values[seasonLength : 2*seasonLength])
+		model.level = firstMean
+		model.trend = (secondMean - firstMean) / float64(seasonLength)
+
+		seasons := len(values) / seasonLength
+		model.season = make([]float64, seasonLength)
+		for k := 0; k < seasons; k++ {
+			seasonValues := values[k*seasonLength : (k+1)*seasonLength]
+			seasonMean, _ := sp.calcMeanStdDev(seasonValues)
+			for i, v := range seasonValues {
+				model.season[i] += (v - seasonMean) / float64(seasons)
+			}
+		}
+		start = seasonLength
+	} else if len(values) > 1 {
+		model.trend = values[1] - values[0]
+	}
+
+	for t := start; t < len(values); t++ {
+		seasonal := 0.0
+		if model.season != nil {
+			seasonal = model.season[t%seasonLength]
+		}
+
+		previousLevel := model.level
+		model.level = sp.Alpha*(values[t]-seasonal) + (1-sp.Alpha)*(model.level+model.trend)
+		model.trend = sp.Beta*(model.level-previousLevel) + (1-sp.Beta)*model.trend
+		if model.season != nil {
+			model.season[t%seasonLength] = sp.Gamma*(values[t]-model.level) + (1-sp.Gamma)*seasonal
+		}
+	}
+
+	return model
+}
+
+// forecast predicts the value steps readings after the last observation
+func (hw holtWinters) forecast(steps int) float64 {
+	value := hw.level + float64(steps)*hw.trend
+	if len(hw.season) > 0 {
+		value += hw.season[(hw.observed-1+steps)%len(hw.season)]
+	}
+	return value
+}
+
+// seasonLength returns how many readings one SeasonalPeriod spans at the given
+// interval, or 0 when seasonality is off or the readings don't cover two seasons
+func (sp *SensorPredictor) seasonLength(readings []models.SensorReading, interval time.Duration) int {
+	if sp.SeasonalPeriod <= 0 || interval <= 0 {
+		return 0
+	}
+
+	length := int(math.Round(float64(sp.SeasonalPeriod) / float64(interval)))
+	if length < 2 || len(readings) < 2*length {
+		return 0
+	}
+	return length
+}
+
 // CalculateAccuracy compares predictions with actual values
 func (sp *SensorPredictor) CalculateAccuracy(
 	predictions []PredictionResult,
@@ -322,35 +408,6 @@ func (sp *SensorPredictor) calculateTrend(values []float64) float64 {
 	return slope
 }
 
-func (sp *SensorPredictor) detectCyclicPattern(values []float64) bool {
-	// Simplified autocorrelation check for daily patterns
-	// In production, use proper FFT or autocorrelation function
-
-	if len(values) < 48 {
-		return false
-	}
-
-	// Check if values at 24-hour intervals are correlated
-	correlation := 0.0
-	count := 0
-	period := 24
-
-	for i := 0; i < len(values)-period; i++ {
-		correlation += math.Abs(values[i] - values[i+period])
-		count++
-	}
-
-	avgDiff := correlation / float64(count)
-	mean, stdDev := sp.calcMeanStdDev(values)
-
-	// If 24-hour differences are small relative to variance, likely cyclic
-	if mean > 0 {
-		return avgDiff < stdDev
-	}
-
-	return false
-}
-
 func (sp *SensorPredictor) estimateTimeInterval(readings []models.SensorReading) time.Duration {
 	if len(readings) < 2 {
 		return 1 * time.Hour // Default to 1 hour
@@ -0,0 +1,85 @@
+package ml
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// seasonalTurbidity is a daily turbidity cycle on a slowly rising level
+func seasonalTurbidity(hour int) float64 {
+	return 5 + 0.01*float64(hour) + 2*math.Sin(2*math.Pi*float64(hour)/24)
+}
+
+func TestSensorPredictor_HoltWintersFollowsDailySeason(t *testing.T) {
+	sp := NewSensorPredictor()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Three days of hourly readings
+	var readings []models.SensorReading
+	for hour := 0; hour < 72; hour++ {
+		readings = append(readings, models.SensorReading{
+			DeviceID: "stm32_pre", FilterMode: models.FilterModeDrinking, Timestamp: start.Add(time.Duration(hour) * time.Hour),
+			Flow: 2, Ph: 7, Turbidity: seasonalTurbidity(hour), TDS: 300,
+		})
+	}
+
+	predictions, err := sp.PredictSensorValues(readings, "stm32_pre", models.FilterModeDrinking)
+	if err != nil {
+		t.Fatalf("Failed to predict: %v", err)
+	}
+	if len(predictions) != 24 {
+		t.Fatalf("Expected 24 predictions, got %d", len(predictions))
+	}
+
+	for i, prediction := range predictions {
+		hour := 72 + i
+		if prediction.Method != MethodHoltWinters {
+			t.Fatalf("Expected the seasonal model with three days of history, got %s", prediction.Method)
+		}
+		if !prediction.Timestamp.Equal(start.Add(time.Duration(hour) * time.Hour)) {
+			t.Errorf("Prediction %d: expected timestamp %s, got %s", i, start.Add(time.Duration(hour)*time.Hour), prediction.Timestamp)
+		}
+		if expected := seasonalTurbidity(hour); math.Abs(prediction.PredictedTurbidity-expected) > 0.3 {
+			t.Errorf("Hour %d: expected turbidity near %.2f over the whole horizon, got %.2f", hour, expected, prediction.PredictedTurbidity)
+		}
+		if prediction.PredictedTDS != 300 || prediction.PredictedPh != 7 {
+			t.Errorf("Hour %d: expected flat metrics to stay flat, got pH %.2f and TDS %.2f", hour, prediction.PredictedPh, prediction.PredictedTDS)
+		}
+	}
+}
+
+func TestSensorPredictor_FallsBackToHoltLinearWithoutFullSeasons(t *testing.T) {
+	sp := NewSensorPredictor()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// 60 readings ten minutes apart cover less than two days, with TDS
+	// falling and turbidity rising steadily
+	var readings []models.SensorReading
+	for i := 0; i < 60; i++ {
+		readings = append(readings, models.SensorReading{
+			DeviceID: "stm32_post", FilterMode: models.FilterModeHousehold, Timestamp: start.Add(time.Duration(i) * 10 * time.Minute),
+			Flow: 2, Ph: 7, Turbidity: 1 + 0.1*float64(i), TDS: 40 - 0.5*float64(i),
+		})
+	}
+
+	predictions, err := sp.PredictSensorValues(readings, "stm32_post", models.FilterModeHousehold)
+	if err != nil {
+		t.Fatalf("Failed to predict: %v", err)
+	}
+	for i, prediction := range predictions {
+		step := 60 + i
+		if prediction.Method != MethodHoltLinear {
+			t.Fatalf("Expected the linear trend model without two full seasons, got %s", prediction.Method)
+		}
+		if expected := 1 + 0.1*float64(step); math.Abs(prediction.PredictedTurbidity-expected) > 0.05 {
+			t.Errorf("Step %d: expected turbidity %.2f, got %.2f", step, expected, prediction.PredictedTurbidity)
+		}
+		// TDS reaches zero inside the horizon and is clamped there
+		if expected := math.Max(0, 40-0.5*float64(step)); math.Abs(prediction.PredictedTDS-expected) > 0.05 {
+			t.Errorf("Step %d: expected TDS %.2f, got %.2f", step, expected, prediction.PredictedTDS)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+const (
+	// defaultWebhookTimeout is how long a single webhook delivery attempt may take
+	defaultWebhookTimeout = 5 * time.Second
+	// webhookMaxAttempts is how many times an alert is posted before it is given up on
+	webhookMaxAttempts = 3
+	// webhookInitialBackoff is the wait before the first retry; it doubles on each retry
+	webhookInitialBackoff = time.Second
+)
+
+// webhookAlertSeverities are the anomaly severities posted to the alert webhook
+var webhookAlertSeverities = map[string]bool{
+	"high":     true,
+	"critical": true,
+}
+
+// WebhookNotifier posts anomaly alerts as JSON to a webhook. Deliveries run in
+// the background and are retried with exponential backoff, so notifying never
+// blocks the caller.
+type WebhookNotifier struct {
+	url     string
+	client  *http.Client
+	backoff time.Duration // Wait before the first retry
+	wg      sync.WaitGroup
+}
+
+// NewWebhookNotifier creates a notifier posting to url; a non-positive timeout
+// uses the default. Returns nil when url is empty, which notifies nobody.
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	if url == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookNotifier{
+		url:     url,
+		client:  &http.Client{Timeout: timeout},
+		backoff: webhookInitialBackoff,
+	}
+}
+
+// NotifyAnomaly posts the anomaly in the background, calling onFailed (when not
+// nil) if the alert can't be delivered
+func (n *WebhookNotifier) NotifyAnomaly(anomaly models.AnomalyDetection, onFailed func()) {
+	if n == nil {
+		return
+	}
+	payload, err := json.Marshal(anomaly)
+	if err != nil {
+		log.Printf("Error encoding anomaly %d for the alert webhook: %v", anomaly.ID, err)
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.deliver(payload); err != nil {
+			log.Printf("❌ Failed to deliver alert for anomaly %d to webhook: %v", anomaly.ID, err)
+			if onFailed != nil {
+				onFailed()
+			}
+			return
+		}
+		log.Printf("📣 Alert for anomaly %d (%s %s on %s) delivered to webhook", anomaly.ID, anomaly.Severity, anomaly.AffectedMetric, anomaly.DeviceID)
+	}()
+}
+
+// Wait blocks until deliveries in progress finish or are given up on
+func (n *WebhookNotifier) Wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+// deliver posts the payload until the webhook accepts it with a 2xx status
+// or the attempts run out
+func (n *WebhookNotifier) deliver(payload []byte) error {
+	var err error
+	backoff := n.backoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if err = n.post(payload); err == nil {
+			return nil
+		}
+		if attempt < webhookMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return fmt.Errorf("%d attempts failed, last: %w", webhookMaxAttempts, err)
+}
+
+func (n *WebhookNotifier) post(payload []byte) error {
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
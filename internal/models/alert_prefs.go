@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AlertMetrics are the metrics anomaly alerts can be about
+var AlertMetrics = []string{"flow", "ph", "turbidity", "tds"}
+
+// AlertSeverities are the severities anomaly alerts can have
+var AlertSeverities = []string{"low", "medium", "high", "critical"}
+
+// AlertPreferences are the anomaly alerts a device's users don't want to be
+// notified of. Muted anomalies are still detected and recorded, they just
+// don't alert. Without preferences every alert is on.
+type AlertPreferences struct {
+	DeviceID        string   `json:"device_id"`
+	MutedMetrics    []string `json:"muted_metrics"`    // e.g. "tds" for a household-only user
+	MutedSeverities []string `json:"muted_severities"` // e.g. "low" to only hear about real problems
+}
+
+// DefaultAlertPreferences returns the preferences of a device that hasn't set
+// any: nothing muted
+func DefaultAlertPreferences(deviceID string) AlertPreferences {
+	return AlertPreferences{DeviceID: deviceID, MutedMetrics: []string{}, MutedSeverities: []string{}}
+}
+
+// Validate checks that only known metrics and severities are muted
+func (p AlertPreferences) Validate() error {
+	for _, metric := range p.MutedMetrics {
+		if !slices.Contains(AlertMetrics, metric) {
+			return fmt.Errorf("unknown metric %q (expected one of %v)", metric, AlertMetrics)
+		}
+	}
+	for _, severity := range p.MutedSeverities {
+		if !slices.Contains(AlertSeverities, severity) {
+			return fmt.Errorf("unknown severity %q (expected one of %v)", severity, AlertSeverities)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether an anomaly of the metric and severity should notify
+func (p AlertPreferences) Allows(metric, severity string) bool {
+	return !slices.Contains(p.MutedMetrics, metric) && !slices.Contains(p.MutedSeverities, severity)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReadingCursor marks a position in readings ordered by (timestamp, device_id)
+// for keyset pagination: the next page starts right after it. Clients get it
+// as an opaque string and pass it back unchanged.
+type ReadingCursor struct {
+	Timestamp time.Time
+	DeviceID  string
+}
+
+// CursorAfter returns the cursor positioned at a reading
+func CursorAfter(reading SensorReading) ReadingCursor {
+	return ReadingCursor{Timestamp: reading.Timestamp, DeviceID: reading.DeviceID}
+}
+
+// Encode returns the opaque form of the cursor
+func (c ReadingCursor) Encode() string {
+	raw := c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.DeviceID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseReadingCursor decodes a cursor produced by Encode
+func ParseReadingCursor(encoded string) (ReadingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ReadingCursor{}, fmt.Errorf("invalid cursor")
+	}
+	timestamp, deviceID, found := strings.Cut(string(raw), "|")
+	if !found {
+		return ReadingCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return ReadingCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return ReadingCursor{Timestamp: parsed, DeviceID: deviceID}, nil
+}
+
+// CompareReadingOrder orders readings by timestamp, then device ID, returning
+// -1, 0 or 1. It is the order keyset pagination walks in.
+func CompareReadingOrder(a, b SensorReading) int {
+	switch {
+	case a.Timestamp.Before(b.Timestamp):
+		return -1
+	case a.Timestamp.After(b.Timestamp):
+		return 1
+	}
+	return strings.Compare(a.DeviceID, b.DeviceID)
+}
+
+// IsPast reports whether the reading comes after the cursor when walking in
+// the given direction (oldest first when sortAsc, newest first otherwise)
+func (c ReadingCursor) IsPast(reading SensorReading, sortAsc bool) bool {
+	order := CompareReadingOrder(reading, SensorReading{Timestamp: c.Timestamp, DeviceID: c.DeviceID})
+	if sortAsc {
+		return order > 0
+	}
+	return order < 0
+}
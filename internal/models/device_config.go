@@ -0,0 +1,88 @@
+package models
+
+// Sources of an effective configuration value, from lowest to highest
+// precedence: a device value shadows a global one, which shadows the
+// built-in default
+const (
+	ConfigSourceDefault = "default" // Built into the server
+	ConfigSourceGlobal  = "global"  // Server configuration or system-wide state
+	ConfigSourceDevice  = "device"  // Device metadata or the device's own data
+)
+
+// ConfigSetting is one resolved configuration value and where it came from
+type ConfigSetting struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// ResolveSetting returns the highest precedence candidate that is set; a nil
+// value means the layer doesn't set the setting
+func ResolveSetting(candidates ...ConfigSetting) ConfigSetting {
+	resolved := ConfigSetting{Source: ConfigSourceDefault}
+	for _, candidate := range candidates {
+		if candidate.Value == nil {
+			continue
+		}
+		if resolved.Value == nil || configSourceRank(candidate.Source) >= configSourceRank(resolved.Source) {
+			resolved = candidate
+		}
+	}
+	return resolved
+}
+
+func configSourceRank(source string) int {
+	switch source {
+	case ConfigSourceGlobal:
+		return 1
+	case ConfigSourceDevice:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// EffectiveAnomalyConfig is the anomaly detection rules applied to a device's
+// readings: the detector thresholds and the baseline of the device in its
+// effective filter mode
+type EffectiveAnomalyConfig struct {
+	ZScoreThreshold ConfigSetting   `json:"z_score_threshold"`
+	SpikeMultiplier ConfigSetting   `json:"spike_multiplier"`
+	Baseline        *SensorBaseline `json:"baseline"` // Null until one is calculated for the device and mode
+	Active          bool            `json:"active"`   // False while the baseline has too few samples
+}
+
+// EffectiveTargetVolume is the filtration target volume rules in liters
+type EffectiveTargetVolume struct {
+	Default ConfigSetting `json:"default"`
+	Min     ConfigSetting `json:"min"`
+	Max     ConfigSetting `json:"max"`
+}
+
+// EffectiveCooldowns is the minimum intervals between repeated actions
+type EffectiveCooldowns struct {
+	ModeChange    ConfigSetting `json:"mode_change"`    // Between filter mode changes on the device
+	AnomalyAlerts ConfigSetting `json:"anomaly_alerts"` // Between anomaly alerts per metric
+}
+
+// EffectiveFilterConfig is the device's filter metadata and replacement alerting
+type EffectiveFilterConfig struct {
+	Spec                 *FilterSpec   `json:"spec"` // Null until registered for the device
+	ReplacementAlertDays ConfigSetting `json:"replacement_alert_days"`
+}
+
+// EffectiveDeviceConfig is every rule that applies to a device once overrides
+// are resolved, for firmware and support to see what the backend will do
+type EffectiveDeviceConfig struct {
+	DeviceID          string                 `json:"device_id"`
+	Name              string                 `json:"name,omitempty"`
+	IsActive          bool                   `json:"is_active"`
+	DeviceType        ConfigSetting          `json:"device_type"`
+	FilterMode        ConfigSetting          `json:"filter_mode"`
+	Calibration       ConfigSetting          `json:"calibration"`
+	QualityRules      ConfigSetting          `json:"quality_rules_version"`
+	QualityThresholds ConfigSetting          `json:"quality_thresholds"` // For the device's effective filter mode
+	AnomalyDetection  EffectiveAnomalyConfig `json:"anomaly_detection"`
+	TargetVolume      EffectiveTargetVolume  `json:"target_volume"`
+	Cooldowns         EffectiveCooldowns     `json:"cooldowns"`
+	Filter            EffectiveFilterConfig  `json:"filter"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestResolveSetting_PrefersHighestPrecedence(t *testing.T) {
+	resolved := ResolveSetting(
+		ConfigSetting{Value: 1, Source: ConfigSourceDevice},
+		ConfigSetting{Value: 2, Source: ConfigSourceGlobal},
+		ConfigSetting{Value: 3, Source: ConfigSourceDefault},
+	)
+	if resolved.Value != 1 || resolved.Source != ConfigSourceDevice {
+		t.Errorf("Expected the device value regardless of order, got %+v", resolved)
+	}
+
+	resolved = ResolveSetting(
+		ConfigSetting{Value: 3, Source: ConfigSourceDefault},
+		ConfigSetting{Source: ConfigSourceDevice},
+	)
+	if resolved.Value != 3 || resolved.Source != ConfigSourceDefault {
+		t.Errorf("Expected an unset device value to fall through, got %+v", resolved)
+	}
+}
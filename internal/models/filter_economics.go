@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FilterSpec is the purchase metadata of the filter installed on a device
+type FilterSpec struct {
+	DeviceID       string    `json:"device_id"`
+	CapacityLiters float64   `json:"capacity_liters"` // Rated volume the filter treats before replacement
+	Cost           float64   `json:"cost"`            // Purchase price of the filter
+	InstalledAt    time.Time `json:"installed_at"`
+}
+
+// Validate checks that the filter metadata is usable for economics
+func (s FilterSpec) Validate() error {
+	if s.CapacityLiters <= 0 {
+		return fmt.Errorf("capacity_liters must be positive")
+	}
+	if s.Cost < 0 {
+		return fmt.Errorf("cost must not be negative")
+	}
+	if s.InstalledAt.IsZero() {
+		return fmt.Errorf("installed_at is required")
+	}
+	if s.InstalledAt.After(time.Now()) {
+		return fmt.Errorf("installed_at must not be in the future")
+	}
+	return nil
+}
+
+// FilterEconomics is the running cost of a device's filter given its usage so far
+type FilterEconomics struct {
+	FilterSpec
+	ProcessedLiters       float64  `json:"processed_liters"`
+	PercentUsed           float64  `json:"percent_used"`             // Share of rated capacity used (may exceed 100)
+	CostPerLiterSoFar     *float64 `json:"cost_per_liter_so_far"`    // nil until water has been processed
+	ProjectedCostPerLiter float64  `json:"projected_cost_per_liter"` // At end of life, when the rated capacity is used
+	LitersRemaining       float64  `json:"liters_remaining"`
+	DailyUsageLiters      float64  `json:"daily_usage_liters"`
+	DaysRemaining         *float64 `json:"days_remaining"` // At the current daily usage; nil without usage
+}
+
+// CalculateFilterEconomics derives cost per liter and remaining life from the
+// filter's metadata and the liters it has processed since installation.
+// Usage is averaged over at least one day so a new filter isn't extrapolated
+// from a few minutes of flow.
+func CalculateFilterEconomics(spec FilterSpec, processedLiters float64, now time.Time) FilterEconomics {
+	processedLiters = math.Max(processedLiters, 0)
+	economics := FilterEconomics{
+		FilterSpec:            spec,
+		ProcessedLiters:       roundTo(processedLiters, 2),
+		PercentUsed:           roundTo(processedLiters/spec.CapacityLiters*100, 1),
+		ProjectedCostPerLiter: roundTo(spec.Cost/spec.CapacityLiters, 4),
+		LitersRemaining:       roundTo(math.Max(spec.CapacityLiters-processedLiters, 0), 2),
+	}
+
+	if processedLiters > 0 {
+		costPerLiter := roundTo(spec.Cost/processedLiters, 4)
+		economics.CostPerLiterSoFar = &costPerLiter
+	}
+
+	days := math.Max(now.Sub(spec.InstalledAt).Hours()/24, 1)
+	dailyUsage := processedLiters / days
+	economics.DailyUsageLiters = roundTo(dailyUsage, 2)
+	if dailyUsage > 0 {
+		daysRemaining := roundTo(math.Max(spec.CapacityLiters-processedLiters, 0)/dailyUsage, 1)
+		economics.DaysRemaining = &daysRemaining
+	}
+
+	return economics
+}
+
+// roundTo rounds value to the given number of decimal places
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
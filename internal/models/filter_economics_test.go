@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculateFilterEconomics_KnownInputs(t *testing.T) {
+	now := time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC)
+	spec := FilterSpec{DeviceID: "stm32_post", CapacityLiters: 2000, Cost: 50, InstalledAt: now.AddDate(0, 0, -10)}
+
+	economics := CalculateFilterEconomics(spec, 500, now)
+
+	if economics.CostPerLiterSoFar == nil || *economics.CostPerLiterSoFar != 0.1 {
+		t.Errorf("Expected 50 / 500 L = 0.1 per liter so far, got %v", economics.CostPerLiterSoFar)
+	}
+	if economics.ProjectedCostPerLiter != 0.025 {
+		t.Errorf("Expected 50 / 2000 L = 0.025 per liter at end of life, got %v", economics.ProjectedCostPerLiter)
+	}
+	if economics.LitersRemaining != 1500 || economics.PercentUsed != 25 {
+		t.Errorf("Expected 1500 L remaining and 25%% used, got %v L and %v%%", economics.LitersRemaining, economics.PercentUsed)
+	}
+	if economics.DailyUsageLiters != 50 {
+		t.Errorf("Expected 500 L over 10 days = 50 L/day, got %v", economics.DailyUsageLiters)
+	}
+	if economics.DaysRemaining == nil || *economics.DaysRemaining != 30 {
+		t.Errorf("Expected 1500 L at 50 L/day = 30 days remaining, got %v", economics.DaysRemaining)
+	}
+}
+
+func TestCalculateFilterEconomics_UnusedAndExhaustedFilters(t *testing.T) {
+	now := time.Now()
+	spec := FilterSpec{CapacityLiters: 1000, Cost: 40, InstalledAt: now.Add(-time.Hour)}
+
+	unused := CalculateFilterEconomics(spec, 0, now)
+	if unused.CostPerLiterSoFar != nil || unused.DaysRemaining != nil {
+		t.Errorf("Expected no cost so far or days remaining before any use, got %v and %v", unused.CostPerLiterSoFar, unused.DaysRemaining)
+	}
+	if unused.LitersRemaining != 1000 {
+		t.Errorf("Expected the full capacity remaining, got %v", unused.LitersRemaining)
+	}
+
+	// Less than a day of use is averaged over a full day
+	if young := CalculateFilterEconomics(spec, 12, now); young.DailyUsageLiters != 12 {
+		t.Errorf("Expected usage averaged over at least one day, got %v L/day", young.DailyUsageLiters)
+	}
+
+	exhausted := CalculateFilterEconomics(spec, 1500, now)
+	if exhausted.LitersRemaining != 0 || exhausted.PercentUsed != 150 || *exhausted.DaysRemaining != 0 {
+		t.Errorf("Expected an over-used filter to have nothing remaining, got %+v", exhausted)
+	}
+}
+
+func TestFilterSpec_Validate(t *testing.T) {
+	installed := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		spec    FilterSpec
+		wantErr bool
+	}{
+		{"valid", FilterSpec{CapacityLiters: 1000, Cost: 40, InstalledAt: installed}, false},
+		{"free filter", FilterSpec{CapacityLiters: 1000, InstalledAt: installed}, false},
+		{"missing capacity", FilterSpec{Cost: 40, InstalledAt: installed}, true},
+		{"negative cost", FilterSpec{CapacityLiters: 1000, Cost: -1, InstalledAt: installed}, true},
+		{"missing install date", FilterSpec{CapacityLiters: 1000, Cost: 40}, true},
+		{"installed in the future", FilterSpec{CapacityLiters: 1000, Cost: 40, InstalledAt: time.Now().Add(time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
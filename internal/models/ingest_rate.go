@@ -0,0 +1,78 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Ingestion rate statuses of a device
+const (
+	IngestRateOK      = "ok"
+	IngestRateTooFast = "too_fast"
+	IngestRateTooSlow = "too_slow"
+	IngestRateUnknown = "unknown" // No expected rate to compare against
+)
+
+// Where a device's expected ingestion rate came from
+const (
+	ExpectedRateConfig   = "config"   // The configured reading interval
+	ExpectedRateInferred = "inferred" // The device's median interval before the window
+)
+
+// IngestRate is how fast a device sent readings over a window compared to
+// how fast it is expected to
+type IngestRate struct {
+	DeviceID          string   `json:"device_id"`
+	Readings          int      `json:"readings"`
+	ReadingsPerMinute float64  `json:"readings_per_minute"`
+	ExpectedPerMinute *float64 `json:"expected_per_minute"` // Null when unknown
+	ExpectedSource    string   `json:"expected_source,omitempty"`
+	Status            string   `json:"status"`
+}
+
+// NewIngestRate computes a device's rate over the window and classifies it
+// against the expected reading interval (0 = unknown). A rate more than
+// tolerance (a fraction) away from the expected one is too fast or too slow.
+func NewIngestRate(deviceID string, readings int, window, expectedInterval time.Duration, source string, tolerance float64) IngestRate {
+	rate := IngestRate{
+		DeviceID:          deviceID,
+		Readings:          readings,
+		ReadingsPerMinute: roundTo(float64(readings)/window.Minutes(), 3),
+		Status:            IngestRateUnknown,
+	}
+	if expectedInterval <= 0 {
+		return rate
+	}
+
+	expected := roundTo(time.Minute.Seconds()/expectedInterval.Seconds(), 3)
+	rate.ExpectedPerMinute = &expected
+	rate.ExpectedSource = source
+	switch {
+	case rate.ReadingsPerMinute > expected*(1+tolerance):
+		rate.Status = IngestRateTooFast
+	case rate.ReadingsPerMinute < expected*(1-tolerance):
+		rate.Status = IngestRateTooSlow
+	default:
+		rate.Status = IngestRateOK
+	}
+	return rate
+}
+
+// MedianReadingInterval returns the median gap between consecutive readings in
+// chronological order, or false with fewer than three readings
+func MedianReadingInterval(readings []SensorReading) (time.Duration, bool) {
+	if len(readings) < 3 {
+		return 0, false
+	}
+	gaps := make([]time.Duration, 0, len(readings)-1)
+	for i := 1; i < len(readings); i++ {
+		gaps = append(gaps, readings[i].Timestamp.Sub(readings[i-1].Timestamp))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+
+	middle := len(gaps) / 2
+	if len(gaps)%2 == 0 {
+		return (gaps[middle-1] + gaps[middle]) / 2, true
+	}
+	return gaps[middle], true
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Maintenance event types
+const (
+	MaintenanceCleaning          = "cleaning"
+	MaintenanceFilterReplacement = "filter_replacement"
+	MaintenancePartReplacement   = "part_replacement"
+	MaintenanceInspection        = "inspection"
+	MaintenanceOther             = "other"
+)
+
+// MaintenanceTypes lists the accepted maintenance event types
+var MaintenanceTypes = []string{
+	MaintenanceCleaning,
+	MaintenanceFilterReplacement,
+	MaintenancePartReplacement,
+	MaintenanceInspection,
+	MaintenanceOther,
+}
+
+// MaintenanceEvent is an entry in a device's maintenance log
+type MaintenanceEvent struct {
+	ID          int       `json:"id"`
+	DeviceID    string    `json:"device_id"`
+	PerformedAt time.Time `json:"performed_at"` // When the work was done; may be backdated
+	Type        string    `json:"type"`
+	Notes       string    `json:"notes,omitempty"`
+	PerformedBy string    `json:"performed_by"`
+	CreatedAt   time.Time `json:"created_at"` // When the entry was logged
+}
+
+// Validate checks that the maintenance event can be logged
+func (e MaintenanceEvent) Validate() error {
+	if !IsMaintenanceType(e.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(MaintenanceTypes, ", "))
+	}
+	if strings.TrimSpace(e.PerformedBy) == "" {
+		return fmt.Errorf("performed_by is required")
+	}
+	if e.PerformedAt.IsZero() {
+		return fmt.Errorf("performed_at is required")
+	}
+	if e.PerformedAt.After(time.Now()) {
+		return fmt.Errorf("performed_at must not be in the future")
+	}
+	return nil
+}
+
+// IsMaintenanceType reports whether t is an accepted maintenance event type
+func IsMaintenanceType(t string) bool {
+	for _, known := range MaintenanceTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"math"
 	"time"
 )
 
@@ -22,6 +24,8 @@ type FilterHealth struct {
 	TurbidityReduction    float64   `json:"turbidity_reduction"`    // % reduction pre to post
 	TDSReduction          float64   `json:"tds_reduction"`          // % reduction pre to post
 	PhStabilization       float64   `json:"ph_stabilization"`       // How well pH is maintained
+	TurbidityPairsExcluded int      `json:"turbidity_pairs_excluded"` // Pairs left out of the turbidity reduction and efficiency: input already near zero
+	TDSPairsExcluded      int       `json:"tds_pairs_excluded"`     // Pairs left out of the TDS reduction and efficiency: input already near zero
 
 	// Additional tracking metrics
 	TotalFlowProcessed    float64   `json:"total_flow_processed"`   // Total water volume processed (liters)
@@ -59,11 +63,24 @@ type AnomalyDetection struct {
 
 	// Actions taken
 	AlertSent        bool      `json:"alert_sent"`
+	SuppressedCount  int       `json:"suppressed_count"`  // Alerts throttled since the previous alert for this device/metric
 	AutoResolved     bool      `json:"auto_resolved"`
 
 	CreatedAt        time.Time `json:"created_at"`
 }
 
+// FilterReplacementAlert is raised once when a filter's predicted days
+// remaining first falls to or below one of the configured thresholds
+type FilterReplacementAlert struct {
+	DeviceID             string    `json:"device_id"`
+	ThresholdDays        int       `json:"threshold_days"`
+	DaysRemaining        int       `json:"days_remaining"`
+	HealthScore          float64   `json:"health_score"`
+	EstimatedReplacement time.Time `json:"estimated_replacement"`
+	Message              string    `json:"message"`
+	TriggeredAt          time.Time `json:"triggered_at"`
+}
+
 // MLPrediction represents general ML predictions
 type MLPrediction struct {
 	ID              int       `json:"id"`
@@ -89,6 +106,21 @@ type AnomalyStats struct {
 	MostAffectedDevice  string             `json:"most_affected_device"`
 }
 
+// AnomalyOpsMetrics summarizes how anomalies are handled for maintenance
+// reporting. False positives are dismissed rather than resolved, so they are
+// excluded from the resolved counts and the mean time to resolution.
+type AnomalyOpsMetrics struct {
+	TotalAnomalies       int     `json:"total_anomalies"`
+	Resolved             int     `json:"resolved"`
+	Unresolved           int     `json:"unresolved"`
+	FalsePositives       int     `json:"false_positives"`
+	AutoResolved         int     `json:"auto_resolved"`
+	ManuallyResolved     int     `json:"manually_resolved"`
+	ResolutionRate       float64 `json:"resolution_rate"`     // Percentage of anomalies resolved
+	FalsePositiveRate    float64 `json:"false_positive_rate"` // Percentage of anomalies marked false positive
+	MeanTimeToResolution float64 `json:"mttr_seconds"`        // Mean resolved_at - detected_at (0 when none resolved)
+}
+
 // FilterHealthSummary provides a summary of all filter health metrics
 type FilterHealthSummary struct {
 	DeviceID              string    `json:"device_id"`
@@ -101,6 +133,33 @@ type FilterHealthSummary struct {
 	LastAssessment        time.Time `json:"last_assessment"`
 }
 
+// EfficiencyBucket is the average filter efficiency of the matched pre/post
+// reading pairs that fall in one time bucket
+type EfficiencyBucket struct {
+	BucketStart       time.Time `json:"bucket_start"`
+	AverageEfficiency float64   `json:"average_efficiency"`
+	PairCount         int       `json:"pair_count"`
+}
+
+// Water efficiency statuses
+const (
+	WaterEfficiencyOK           = "ok"
+	WaterEfficiencyWasteful     = "wasteful"          // Less clean water comes out than the filter should deliver
+	WaterEfficiencyInsufficient = "insufficient_data" // No matched pre/post readings with flow
+)
+
+// WaterEfficiency is how much of the water fed to the filter comes out clean,
+// from the flow of matched pre/post filtration readings
+type WaterEfficiency struct {
+	PairCount     int      `json:"pair_count"`
+	InputFlow     float64  `json:"input_flow"`     // Summed pre-filtration flow of the pairs
+	OutputFlow    float64  `json:"output_flow"`    // Summed post-filtration flow of the pairs
+	RecoveryRatio *float64 `json:"recovery_ratio"` // Clean output over total input; null without pairs
+	WasteRatio    *float64 `json:"waste_ratio"`    // Share of the input that does not come out clean
+	WastefulPairs int      `json:"wasteful_pairs"` // Pairs recovering less than the wasteful limit
+	Status        string   `json:"status"`
+}
+
 // SensorBaseline represents normal baseline values for anomaly detection
 type SensorBaseline struct {
 	DeviceID         string     `json:"device_id"`
@@ -135,6 +194,35 @@ type SensorBaseline struct {
 	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
+// Validate checks that a filter health record's scores and percentages are in range
+func (fh *FilterHealth) Validate() error {
+	if fh.HealthScore < 0 || fh.HealthScore > 100 {
+		return fmt.Errorf("health_score must be between 0 and 100")
+	}
+	if fh.PredictedDaysRemaining < 0 {
+		return fmt.Errorf("predicted_days_remaining must not be negative")
+	}
+	if fh.FilterAgeDays < 0 || fh.TotalFlowProcessed < 0 {
+		return fmt.Errorf("filter_age_days and total_flow_processed must not be negative")
+	}
+	percentages := map[string]float64{
+		"current_efficiency":  fh.CurrentEfficiency,
+		"average_efficiency":  fh.AverageEfficiency,
+		"turbidity_reduction": fh.TurbidityReduction,
+		"tds_reduction":       fh.TDSReduction,
+		"ph_stabilization":    fh.PhStabilization,
+	}
+	for name, value := range percentages {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	if fh.FilterMode != "" && fh.FilterMode != FilterModeDrinking && fh.FilterMode != FilterModeHousehold {
+		return fmt.Errorf("filter_mode must be drinking_water or household_water")
+	}
+	return nil
+}
+
 // GetHealthCategory returns the health category based on score
 func (fh *FilterHealth) GetHealthCategory() string {
 	switch {
@@ -184,6 +272,13 @@ func (ad *AnomalyDetection) IsStale(duration time.Duration) bool {
 
 // CalculateFilterEfficiency calculates filter efficiency from pre/post readings
 func CalculateFilterEfficiency(preReading, postReading *SensorReading) float64 {
+	return CalculateFilterEfficiencyOf(preReading, postReading, true, true)
+}
+
+// CalculateFilterEfficiencyOf calculates filter efficiency like
+// CalculateFilterEfficiency, but only counts the turbidity and TDS improvement
+// when asked to; the weights of the metrics counted are scaled to add up to 1
+func CalculateFilterEfficiencyOf(preReading, postReading *SensorReading, withTurbidity, withTDS bool) float64 {
 	if preReading == nil || postReading == nil {
 		return 0.0
 	}
@@ -208,7 +303,16 @@ func CalculateFilterEfficiency(preReading, postReading *SensorReading) float64 {
 	}
 
 	// Weighted average (turbidity and TDS are more important)
-	efficiency := (turbidityImprovement * 0.4) + (tdsImprovement * 0.4) + (phImprovement * 0.2)
+	efficiency, weight := phImprovement*0.2, 0.2
+	if withTurbidity {
+		efficiency += turbidityImprovement * 0.4
+		weight += 0.4
+	}
+	if withTDS {
+		efficiency += tdsImprovement * 0.4
+		weight += 0.4
+	}
+	efficiency /= weight
 
 	// Clamp between 0 and 100
 	if efficiency < 0 {
@@ -321,3 +425,121 @@ func (sp *SensorPrediction) IsFuturePrediction() bool {
 func (sp *SensorPrediction) TimeUntilPrediction() time.Duration {
 	return time.Until(sp.PredictedFor)
 }
+
+// PredictedMetric returns the predicted value of a named sensor metric
+func (sp *SensorPrediction) PredictedMetric(metric string) (float64, bool) {
+	switch metric {
+	case "ph":
+		return sp.PredictedPh, true
+	case "tds":
+		return sp.PredictedTDS, true
+	case "turbidity":
+		return sp.PredictedTurbidity, true
+	case "flow":
+		return sp.PredictedFlow, true
+	default:
+		return 0, false
+	}
+}
+
+// ActualMetric returns the measured value of a named sensor metric recorded at
+// validation, or nil while the prediction is unvalidated
+func (sp *SensorPrediction) ActualMetric(metric string) *float64 {
+	if !sp.IsValidated {
+		return nil
+	}
+	switch metric {
+	case "ph":
+		return sp.ActualPh
+	case "tds":
+		return sp.ActualTDS
+	case "turbidity":
+		return sp.ActualTurbidity
+	case "flow":
+		return sp.ActualFlow
+	default:
+		return nil
+	}
+}
+
+// RecordActual validates the prediction against the reading measured at its
+// predicted time, storing the actual values, absolute errors and overall
+// accuracy. The metrics are on very different scales (pH vs ppm), so the
+// accuracy is 100 times one minus the mean of the per-metric relative errors.
+func (sp *SensorPrediction) RecordActual(reading SensorReading, at time.Time) {
+	flow, ph, turbidity, tds := reading.Flow, reading.Ph, reading.Turbidity, reading.TDS
+	flowError := math.Abs(sp.PredictedFlow - flow)
+	phError := math.Abs(sp.PredictedPh - ph)
+	turbidityError := math.Abs(sp.PredictedTurbidity - turbidity)
+	tdsError := math.Abs(sp.PredictedTDS - tds)
+	meanRelativeError := (relativeError(flowError, flow) + relativeError(phError, ph) +
+		relativeError(turbidityError, turbidity) + relativeError(tdsError, tds)) / 4
+	accuracy := 100 * (1 - meanRelativeError)
+
+	sp.ActualFlow, sp.ActualPh, sp.ActualTurbidity, sp.ActualTDS = &flow, &ph, &turbidity, &tds
+	sp.FlowError, sp.PhError, sp.TurbidityError, sp.TDSError = &flowError, &phError, &turbidityError, &tdsError
+	sp.OverallAccuracy = &accuracy
+	sp.IsValidated = true
+	sp.ValidatedAt = &at
+	sp.UpdatedAt = at
+}
+
+// SummarizePredictionAccuracy builds the accuracy summary of a device and
+// filter mode over [periodStart, periodEnd) from the predictions made for that
+// period. Each metric's accuracy is averaged over the validated predictions
+// the same way RecordActual scores them.
+func SummarizePredictionAccuracy(deviceID string, mode FilterMode, periodStart, periodEnd time.Time, predictions []SensorPrediction) PredictionAccuracySummary {
+	summary := PredictionAccuracySummary{
+		DeviceID:    deviceID,
+		FilterMode:  mode,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+	}
+
+	var flow, ph, turbidity, tds, overall float64
+	for _, prediction := range predictions {
+		summary.TotalPredictions++
+		summary.ModelVersion = prediction.PredictionMethod
+		if !prediction.IsValidated || prediction.OverallAccuracy == nil {
+			continue
+		}
+		summary.ValidatedPredictions++
+		flow += 100 * (1 - relativeError(*prediction.FlowError, *prediction.ActualFlow))
+		ph += 100 * (1 - relativeError(*prediction.PhError, *prediction.ActualPh))
+		turbidity += 100 * (1 - relativeError(*prediction.TurbidityError, *prediction.ActualTurbidity))
+		tds += 100 * (1 - relativeError(*prediction.TDSError, *prediction.ActualTDS))
+		overall += *prediction.OverallAccuracy
+	}
+
+	if n := float64(summary.ValidatedPredictions); n > 0 {
+		summary.AvgFlowAccuracy = flow / n
+		summary.AvgPhAccuracy = ph / n
+		summary.AvgTurbidityAccuracy = turbidity / n
+		summary.AvgTDSAccuracy = tds / n
+		summary.OverallAccuracy = overall / n
+	}
+	return summary
+}
+
+// relativeError returns absError relative to the actual value, capped at 1 so
+// one badly missed metric can't push the accuracy below 0. Any error on an
+// actual value of 0 counts as fully wrong.
+func relativeError(absError, actual float64) float64 {
+	if absError == 0 {
+		return 0
+	}
+	if actual == 0 {
+		return 1
+	}
+	return math.Min(1, absError/math.Abs(actual))
+}
+
+// PredictionVsActual pairs a predicted metric value with the value measured at
+// the predicted time, for overlaying a forecast on what happened
+type PredictionVsActual struct {
+	PredictedFor    time.Time `json:"predicted_for"`
+	Predicted       float64   `json:"predicted"`
+	Actual          *float64  `json:"actual"` // null until validated, including future predictions
+	ConfidenceScore float64   `json:"confidence_score"`
+	IsValidated     bool      `json:"is_validated"`
+}
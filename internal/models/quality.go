@@ -0,0 +1,136 @@
+package models
+
+import (
+	"sort"
+	"sync"
+)
+
+// BuiltinQualityRulesVersion identifies the built-in quality assessment rules
+const BuiltinQualityRulesVersion = "builtin-v1"
+
+// ReadingQuality is the quality assessment of a reading, computed at ingestion
+// and stored with it so stats and exports don't re-assess every reading.
+// RulesVersion records the rules it was computed with; assessments made under
+// other rules are recomputed when read and by the quality backfill.
+type ReadingQuality struct {
+	PhStatus        string `json:"ph_status"`
+	TurbidityStatus string `json:"turbidity_status"`
+	TDSStatus       string `json:"tds_status"`
+	OverallQuality  string `json:"overall_quality"`
+	RulesVersion    string `json:"rules_version"`
+}
+
+var (
+	qualityRulesMu      sync.RWMutex
+	qualityRulesVersion = BuiltinQualityRulesVersion
+)
+
+// QualityRulesVersion returns the version of the quality rules in effect
+func QualityRulesVersion() string {
+	qualityRulesMu.RLock()
+	defer qualityRulesMu.RUnlock()
+	return qualityRulesVersion
+}
+
+// SetQualityRulesVersion records that the quality rules changed, making stored
+// assessments made under any other version stale
+func SetQualityRulesVersion(version string) {
+	qualityRulesMu.Lock()
+	defer qualityRulesMu.Unlock()
+	qualityRulesVersion = version
+}
+
+// AssessQuality computes the reading's quality under the current rules
+func (s *SensorReading) AssessQuality() ReadingQuality {
+	quality := ReadingQuality{
+		PhStatus:        s.GetPhStatus(),
+		TurbidityStatus: s.GetTurbidityStatus(),
+		TDSStatus:       s.GetTDSStatus(),
+		RulesVersion:    QualityRulesVersion(),
+	}
+
+	// Determine overall quality
+	quality.OverallQuality = "Good"
+	if quality.PhStatus != "Normal" || quality.TurbidityStatus == "Poor" || quality.TDSStatus == "Poor" {
+		quality.OverallQuality = "Danger"
+	} else if (quality.TurbidityStatus == "Good" || quality.TDSStatus == "Fair") && quality.PhStatus == "Normal" {
+		quality.OverallQuality = "Good"
+	} else if quality.TurbidityStatus == "Excellent" || quality.TDSStatus == "Excellent" || quality.PhStatus == "Normal" {
+		quality.OverallQuality = "Excellent"
+	}
+
+	return quality
+}
+
+// StampQuality stores the reading's current quality assessment on it
+func (s *SensorReading) StampQuality() {
+	quality := s.AssessQuality()
+	s.Quality = &quality
+}
+
+// HasCurrentQuality reports whether the reading carries an assessment made
+// under the current rules
+func (s *SensorReading) HasCurrentQuality() bool {
+	return s.Quality != nil && s.Quality.RulesVersion == QualityRulesVersion()
+}
+
+// CurrentQuality returns the stored assessment when it is current, otherwise
+// it assesses the reading again
+func (s *SensorReading) CurrentQuality() ReadingQuality {
+	if s.HasCurrentQuality() {
+		return *s.Quality
+	}
+	return s.AssessQuality()
+}
+
+// Groupings of a quality breakdown
+const (
+	QualityGroupByMode = "mode" // One group per filter mode
+	QualityGroupByDay  = "day"  // One group per UTC day
+)
+
+// OverallQualityLabels are the overall quality labels, best first
+var OverallQualityLabels = []string{"Excellent", "Good", "Danger"}
+
+// QualityPendingLabel counts readings in a database stats quality breakdown
+// whose stored assessment is missing or stale until the quality backfill
+// reaches them
+const QualityPendingLabel = "Pending"
+
+// QualityBreakdownGroup counts the readings of one group by overall quality
+type QualityBreakdownGroup struct {
+	Group  string         `json:"group"` // Filter mode, or day as YYYY-MM-DD
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"` // Readings per overall quality label, every label present
+}
+
+// NewQualityBreakdown groups readings by filter mode or UTC day and counts
+// each group's readings per overall quality label, using the current
+// assessment of each reading. Groups are ordered by name, so days are
+// chronological.
+func NewQualityBreakdown(readings []SensorReading, groupBy string) []QualityBreakdownGroup {
+	groups := make(map[string]*QualityBreakdownGroup)
+	for _, reading := range readings {
+		name := string(reading.FilterMode)
+		if groupBy == QualityGroupByDay {
+			name = reading.Timestamp.UTC().Format("2006-01-02")
+		}
+		group, exists := groups[name]
+		if !exists {
+			group = &QualityBreakdownGroup{Group: name, Counts: make(map[string]int)}
+			for _, label := range OverallQualityLabels {
+				group.Counts[label] = 0
+			}
+			groups[name] = group
+		}
+		group.Total++
+		group.Counts[reading.CurrentQuality().OverallQuality]++
+	}
+
+	result := make([]QualityBreakdownGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+	return result
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// QualityThresholds are the limits the per-metric quality assessment compares
+// readings against
+type QualityThresholds struct {
+	PhMin                 float64 `json:"ph_min"`                  // Below is dangerously acidic
+	PhMax                 float64 `json:"ph_max"`                  // Above is dangerously alkaline
+	TurbidityExcellentMax float64 `json:"turbidity_excellent_max"` // NTU; above is good
+	TurbidityGoodMax      float64 `json:"turbidity_good_max"`      // NTU; above is poor
+	TDSExcellentMax       float64 `json:"tds_excellent_max"`       // PPM; above is good
+	TDSGoodMax            float64 `json:"tds_good_max"`            // PPM; above is fair
+	TDSFairMax            float64 `json:"tds_fair_max"`            // PPM; above is poor
+}
+
+// DefaultQualityThresholds are the built-in limits the quality rules version
+// BuiltinQualityRulesVersion assesses readings with
+var DefaultQualityThresholds = QualityThresholds{
+	PhMin:                 7.0,
+	PhMax:                 8.5,
+	TurbidityExcellentMax: 1.0,
+	TurbidityGoodMax:      4.0,
+	TDSExcellentMax:       300,
+	TDSGoodMax:            600,
+	TDSFairMax:            900,
+}
+
+// Validate checks that every range is ordered and no limit is negative
+func (t QualityThresholds) Validate() error {
+	if t.PhMin < 0 || t.PhMax > 14 || t.PhMin >= t.PhMax {
+		return fmt.Errorf("pH range must satisfy 0 <= min < max <= 14")
+	}
+	if t.TurbidityExcellentMax < 0 || t.TurbidityExcellentMax >= t.TurbidityGoodMax {
+		return fmt.Errorf("turbidity limits must satisfy 0 <= excellent < good")
+	}
+	if t.TDSExcellentMax < 0 || t.TDSExcellentMax >= t.TDSGoodMax || t.TDSGoodMax >= t.TDSFairMax {
+		return fmt.Errorf("TDS limits must satisfy 0 <= excellent < good < fair")
+	}
+	return nil
+}
+
+var (
+	qualityThresholdsMu   sync.RWMutex
+	qualityThresholds     = DefaultQualityThresholds
+	modeQualityThresholds = map[FilterMode]QualityThresholds{}
+)
+
+// SetQualityThresholds sets the limits used by the quality assessment: the
+// defaults apply to every filter mode without an override. Any limit other
+// than the built-in ones changes the quality rules version, so stored
+// assessments are recomputed.
+func SetQualityThresholds(defaults QualityThresholds, overrides map[FilterMode]QualityThresholds) error {
+	if err := defaults.Validate(); err != nil {
+		return err
+	}
+	modes := make(map[FilterMode]QualityThresholds, len(overrides))
+	for mode, thresholds := range overrides {
+		if err := thresholds.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", mode, err)
+		}
+		modes[mode] = thresholds
+	}
+
+	qualityThresholdsMu.Lock()
+	qualityThresholds = defaults
+	modeQualityThresholds = modes
+	qualityThresholdsMu.Unlock()
+
+	SetQualityRulesVersion(qualityThresholdsVersion(defaults, modes))
+	return nil
+}
+
+// QualityThresholdsFor returns the limits readings in the filter mode are
+// assessed against
+func QualityThresholdsFor(mode FilterMode) QualityThresholds {
+	qualityThresholdsMu.RLock()
+	defer qualityThresholdsMu.RUnlock()
+	if thresholds, ok := modeQualityThresholds[mode]; ok {
+		return thresholds
+	}
+	return qualityThresholds
+}
+
+// qualityThresholdsVersion names the quality rules made of the thresholds:
+// the built-in version when every mode uses the built-in limits, otherwise a
+// fingerprint of the limits
+func qualityThresholdsVersion(defaults QualityThresholds, modes map[FilterMode]QualityThresholds) string {
+	builtin := defaults == DefaultQualityThresholds
+	names := make([]string, 0, len(modes))
+	for mode, thresholds := range modes {
+		if thresholds != DefaultQualityThresholds {
+			builtin = false
+		}
+		names = append(names, string(mode))
+	}
+	if builtin {
+		return BuiltinQualityRulesVersion
+	}
+
+	sort.Strings(names)
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%+v", defaults)
+	for _, name := range names {
+		fmt.Fprintf(hash, "|%s=%+v", name, modes[FilterMode(name)])
+	}
+	return fmt.Sprintf("thresholds-%08x", hash.Sum32())
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PruneResult counts what a data retention run deleted
+type PruneResult struct {
+	Cutoff            time.Time `json:"cutoff"` // Data older than this was deleted
+	SensorReadings    int       `json:"sensor_readings"`
+	Anomalies         int       `json:"anomalies"`
+	SensorPredictions int       `json:"sensor_predictions"`
+}
+
+// Total returns how many rows were deleted altogether
+func (r PruneResult) Total() int {
+	return r.SensorReadings + r.Anomalies + r.SensorPredictions
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSafetyMaxAge is how old the latest drinking water reading may be
+// before it is stale, unless configured otherwise
+const DefaultSafetyMaxAge = 10 * time.Minute
+
+// StalenessPolicy decides the safe-to-drink verdict when the latest drinking
+// water reading is stale, e.g. because the device went offline
+type StalenessPolicy string
+
+const (
+	StalenessFailSafe StalenessPolicy = "fail_safe" // Stale data is never reported safe
+	StalenessFailOpen StalenessPolicy = "fail_open" // The stale reading's verdict stands, with a caution
+)
+
+// IsValid reports whether the policy is one of the supported policies
+func (p StalenessPolicy) IsValid() bool {
+	return p == StalenessFailSafe || p == StalenessFailOpen
+}
+
+// DrinkingSafety is the safe-to-drink verdict for the latest drinking water reading
+type DrinkingSafety struct {
+	Safe    bool     `json:"safe"`
+	Status  string   `json:"status"` // "safe", "unsafe" or "unknown"
+	Reasons []string `json:"reasons"`
+	Stale   bool     `json:"stale"`
+	Caution string   `json:"caution,omitempty"` // Why the verdict may be out of date
+}
+
+// AssessDrinkingSafety gives the safe-to-drink verdict for the latest drinking
+// water reading (nil = none) at now. A reading older than maxAge is stale:
+// under the fail-safe policy its verdict is unknown and never safe, under
+// fail-open it is judged as usual with a caution.
+func AssessDrinkingSafety(reading *SensorReading, now time.Time, maxAge time.Duration, policy StalenessPolicy) DrinkingSafety {
+	if reading == nil {
+		return DrinkingSafety{Status: "unknown", Reasons: []string{"no drinking water reading available"}}
+	}
+
+	safety := DrinkingSafety{Reasons: []string{}}
+	if age := now.Sub(reading.Timestamp); age > maxAge {
+		safety.Stale = true
+		safety.Caution = fmt.Sprintf("latest reading is %s old, older than %s", age.Round(time.Second), maxAge)
+		if policy != StalenessFailOpen {
+			safety.Status = "unknown"
+			safety.Reasons = []string{safety.Caution}
+			return safety
+		}
+	}
+
+	if issues := reading.DrinkingSafetyIssues(); len(issues) > 0 {
+		safety.Status = "unsafe"
+		safety.Reasons = issues
+	} else {
+		safety.Safe = true
+		safety.Status = "safe"
+	}
+	return safety
+}
+
+// SafetyCaution tells live clients that the drinking water verdict rests on
+// stale data, or that fresh data arrived again
+type SafetyCaution struct {
+	Active        bool            `json:"active"` // False once fresh data arrived again
+	DeviceID      string          `json:"device_id"`
+	LastReadingAt time.Time       `json:"last_reading_at"`
+	MaxAge        string          `json:"max_age"`
+	Policy        StalenessPolicy `json:"policy"`
+	SafeToDrink   bool            `json:"safe_to_drink"` // The verdict served under the policy
+	Message       string          `json:"message"`
+}
@@ -13,7 +13,8 @@ type FilterSchedule struct {
 	FilterMode      FilterMode `json:"filter_mode"`
 	StartTime       string     `json:"start_time"`      // Format: "HH:MM:SS"
 	DurationMinutes int        `json:"duration_minutes"`
-	DaysOfWeek      []string   `json:"days_of_week"`    // e.g., ["monday", "tuesday"]
+	DaysOfWeek      []string   `json:"days_of_week"`    // e.g., ["monday", "tuesday"]; empty for one-time schedules
+	RunDate         *string    `json:"run_date,omitempty"` // Format: "YYYY-MM-DD"; set for one-time schedules
 	IsActive        bool       `json:"is_active"`
 	Timezone        string     `json:"timezone"`        // IANA Time Zone name
 	CreatedAt       time.Time  `json:"created_at"`
@@ -44,7 +45,8 @@ type CreateScheduleRequest struct {
 	FilterMode      FilterMode `json:"filter_mode" validate:"required"`
 	StartTime       string     `json:"start_time" validate:"required"`      // Format: "HH:MM" or "HH:MM:SS"
 	DurationMinutes int        `json:"duration_minutes" validate:"required,min=1,max=1440"`
-	DaysOfWeek      []string   `json:"days_of_week" validate:"required,min=1"`
+	DaysOfWeek      []string   `json:"days_of_week" validate:"required_without=RunDate"`
+	RunDate         *string    `json:"run_date,omitempty"`                  // Format: "YYYY-MM-DD"; runs once instead of weekly
 	IsActive        bool       `json:"is_active"`
 	Timezone        string     `json:"timezone" validate:"required"`        // IANA Time Zone name
 }
@@ -55,7 +57,8 @@ type UpdateScheduleRequest struct {
 	FilterMode      *FilterMode `json:"filter_mode,omitempty"`
 	StartTime       *string     `json:"start_time,omitempty"`
 	DurationMinutes *int        `json:"duration_minutes,omitempty"`
-	DaysOfWeek      []string    `json:"days_of_week,omitempty"`
+	DaysOfWeek      []string    `json:"days_of_week,omitempty"` // Makes the schedule weekly
+	RunDate         *string     `json:"run_date,omitempty"`     // Makes the schedule one-time
 	IsActive        *bool       `json:"is_active,omitempty"`
 	Timezone        *string     `json:"timezone,omitempty"`      // IANA Time Zone name
 }
@@ -96,9 +99,16 @@ func (r *CreateScheduleRequest) Validate() error {
 		return fmt.Errorf("duration_minutes must be between 1 and 1440 (24 hours)")
 	}
 
-	// Validate days of week
-	if len(r.DaysOfWeek) == 0 {
-		return fmt.Errorf("days_of_week must contain at least one day")
+	// Validate days of week or run date; exactly one of them sets when it runs
+	if len(r.DaysOfWeek) > 0 && r.RunDate != nil {
+		return fmt.Errorf("provide either days_of_week or run_date, not both")
+	}
+	if r.RunDate != nil {
+		if !isValidRunDate(*r.RunDate) {
+			return fmt.Errorf("run_date must be in YYYY-MM-DD format")
+		}
+	} else if len(r.DaysOfWeek) == 0 {
+		return fmt.Errorf("days_of_week must contain at least one day, or run_date must be set")
 	}
 
 	for _, day := range r.DaysOfWeek {
@@ -116,6 +126,15 @@ func (r *CreateScheduleRequest) Validate() error {
 	return nil
 }
 
+// RunDateLayout is the format of a one-time schedule's run date
+const RunDateLayout = "2006-01-02"
+
+// isValidRunDate checks if a string is a calendar date in YYYY-MM-DD format
+func isValidRunDate(date string) bool {
+	_, err := time.Parse(RunDateLayout, date)
+	return err == nil
+}
+
 // isValidTimezone checks if a string is a valid IANA Time Zone database name
 func isValidTimezone(tz string) bool {
 	if tz == "" {
@@ -156,7 +175,13 @@ func (r *UpdateScheduleRequest) Validate() error {
 		}
 	}
 
-	// Validate days of week if provided
+	// Validate days of week or run date if provided
+	if r.DaysOfWeek != nil && r.RunDate != nil {
+		return fmt.Errorf("provide either days_of_week or run_date, not both")
+	}
+	if r.RunDate != nil && !isValidRunDate(*r.RunDate) {
+		return fmt.Errorf("run_date must be in YYYY-MM-DD format")
+	}
 	if r.DaysOfWeek != nil {
 		if len(r.DaysOfWeek) == 0 {
 			return fmt.Errorf("days_of_week must contain at least one day")
@@ -218,13 +243,12 @@ func (s *FilterSchedule) IsScheduledForToday() bool {
 	if err != nil {
 		return false
 	}
-	today := strings.ToLower(time.Now().In(loc).Weekday().String())
-	for _, day := range s.DaysOfWeek {
-		if strings.ToLower(day) == today {
-			return true
-		}
-	}
-	return false
+	return s.runsOn(time.Now().In(loc))
+}
+
+// IsOneTime reports whether the schedule runs once on its run date instead of weekly
+func (s *FilterSchedule) IsOneTime() bool {
+	return s.RunDate != nil
 }
 
 // GetEndTime calculates the end time based on start time and duration
@@ -255,17 +279,7 @@ func (s *FilterSchedule) ShouldExecuteNow() bool {
 
 	// Get the current time in the schedule's timezone
 	nowInLoc := time.Now().In(loc)
-	currentWeekday := strings.ToLower(nowInLoc.Weekday().String())
-
-	// Check if scheduled for today
-	isScheduledToday := false
-	for _, day := range s.DaysOfWeek {
-		if strings.ToLower(day) == currentWeekday {
-			isScheduledToday = true
-			break
-		}
-	}
-	if !isScheduledToday {
+	if !s.runsOn(nowInLoc) {
 		return false
 	}
 
@@ -275,21 +289,24 @@ func (s *FilterSchedule) ShouldExecuteNow() bool {
 		return false
 	}
 
-	// Get the current time of day in the location
-	nowTimeOfDay := time.Date(0, 1, 1, nowInLoc.Hour(), nowInLoc.Minute(), nowInLoc.Second(), 0, time.UTC)
-	
-	// Get the schedule start time of day
-	scheduleTimeOfDay := time.Date(0, 1, 1, scheduleTime.Hour(), scheduleTime.Minute(), scheduleTime.Second(), 0, time.UTC)
-
-	// Check if the current time is within one minute of the schedule start time
+	// Check if the current time is within one minute of today's start instant
 	// This accounts for the scheduler running every minute
-	diff := nowTimeOfDay.Sub(scheduleTimeOfDay).Minutes()
-	return diff >= 0 && diff < 1
+	diff := nowInLoc.Sub(scheduledInstant(nowInLoc, scheduleTime, loc))
+	return diff >= 0 && diff < time.Minute
 }
 
 // CalculateNextExecution calculates when this schedule will next execute in UTC
 func (s *FilterSchedule) CalculateNextExecution() *time.Time {
-	if !s.IsActive || len(s.DaysOfWeek) == 0 {
+	return s.NextExecutionAfter(time.Now())
+}
+
+// NextExecutionAfter returns the schedule's first execution strictly after
+// from, in UTC, or nil when there is none (e.g. a one-time schedule whose run
+// has passed). StartTime is wall-clock time in the schedule's timezone; on a
+// day that skips it (a DST spring-forward gap) the schedule runs at the
+// instant the clocks jump.
+func (s *FilterSchedule) NextExecutionAfter(from time.Time) *time.Time {
+	if !s.IsActive || (len(s.DaysOfWeek) == 0 && !s.IsOneTime()) {
 		return nil
 	}
 
@@ -300,53 +317,211 @@ func (s *FilterSchedule) CalculateNextExecution() *time.Time {
 		return nil
 	}
 
-	// Get the current time in the schedule's timezone
-	nowInLoc := time.Now().In(loc)
-
 	// Parse schedule start time (without date)
 	startTime, err := time.Parse("15:04:05", s.StartTime)
 	if err != nil {
 		return nil
 	}
 
-	// Function to create a schedule time for a given day
-	createScheduleTime := func(t time.Time) time.Time {
-		return time.Date(t.Year(), t.Month(), t.Day(),
-			startTime.Hour(), startTime.Minute(), startTime.Second(), 0, loc)
+	if s.IsOneTime() {
+		day, err := time.ParseInLocation(RunDateLayout, *s.RunDate, loc)
+		if err != nil {
+			return nil
+		}
+		if execution := scheduledInstant(day, startTime, loc); execution.After(from) {
+			utcExecution := execution.UTC()
+			return &utcExecution
+		}
+		return nil
 	}
 
-	// Check for today
-	todaySchedule := createScheduleTime(nowInLoc)
-	if todaySchedule.After(nowInLoc) {
-		isScheduledToday := false
-		currentWeekday := strings.ToLower(nowInLoc.Weekday().String())
-		for _, day := range s.DaysOfWeek {
-			if strings.ToLower(day) == currentWeekday {
-				isScheduledToday = true
-				break
-			}
+	// Today and the next 7 days cover every weekday, including today's next week
+	fromInLoc := from.In(loc)
+	for i := 0; i <= 7; i++ {
+		day := time.Date(fromInLoc.Year(), fromInLoc.Month(), fromInLoc.Day()+i, 0, 0, 0, 0, loc)
+		if !s.runsOn(day) {
+			continue
 		}
-		if isScheduledToday {
-			utcExecution := todaySchedule.UTC()
+		if execution := scheduledInstant(day, startTime, loc); execution.After(from) {
+			utcExecution := execution.UTC()
 			return &utcExecution
 		}
 	}
 
-	// Find the next scheduled day
-	for i := 1; i <= 7; i++ {
-		nextDay := nowInLoc.AddDate(0, 0, i)
-		nextWeekday := strings.ToLower(nextDay.Weekday().String())
+	return nil
+}
+
+// UpcomingExecutions returns up to count executions after from, in UTC, as if
+// the schedule were active. A one-time schedule has at most one.
+func (s *FilterSchedule) UpcomingExecutions(from time.Time, count int) []time.Time {
+	preview := *s
+	preview.IsActive = true
+
+	executions := []time.Time{}
+	for len(executions) < count {
+		next := preview.NextExecutionAfter(from)
+		if next == nil {
+			break
+		}
+		executions = append(executions, *next)
+		from = *next
+	}
+	return executions
+}
 
-		for _, day := range s.DaysOfWeek {
-			if strings.ToLower(day) == nextWeekday {
-				nextSchedule := createScheduleTime(nextDay)
-				utcExecution := nextSchedule.UTC()
-				return &utcExecution
+// runsOn reports whether the schedule runs on the date of day (a time in the
+// schedule's timezone): its run date, or any of its days of the week
+func (s *FilterSchedule) runsOn(day time.Time) bool {
+	if s.IsOneTime() {
+		return day.Format(RunDateLayout) == *s.RunDate
+	}
+	name := strings.ToLower(day.Weekday().String())
+	for _, day := range s.DaysOfWeek {
+		if strings.ToLower(day) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledInstant returns the instant the wall clock in loc shows clock's
+// time of day on day's date. A time of day skipped by a DST transition
+// resolves to the transition itself, the first valid instant after it.
+func scheduledInstant(day, clock time.Time, loc *time.Location) time.Time {
+	t := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc)
+	if t.Hour() == clock.Hour() && t.Minute() == clock.Minute() && t.Second() == clock.Second() {
+		return t
+	}
+
+	// time.Date normalized the missing time into the zone on one side of the
+	// gap; the boundary of that zone is the transition
+	requested := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+	shown := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	start, end := t.ZoneBounds()
+	if shown.After(requested) {
+		return start
+	}
+	return end
+}
+
+// minutesPerWeek is the length of the weekly cycle schedules repeat on
+const minutesPerWeek = 7 * 24 * 60
+
+// weekdayIndex maps day names to their offset from Sunday
+var weekdayIndex = map[string]int{
+	"sunday":    0,
+	"monday":    1,
+	"tuesday":   2,
+	"wednesday": 3,
+	"thursday":  4,
+	"friday":    5,
+	"saturday":  6,
+}
+
+// weeklyWindows returns the schedule's run windows as [start, end) minutes
+// from Sunday 00:00 UTC, converting with the timezone's current UTC offset.
+// A window running past midnight spills into the next day, and past Saturday
+// into Sunday; end may then exceed minutesPerWeek.
+func (s *FilterSchedule) weeklyWindows() [][2]int {
+	startTime, err := time.Parse("15:04:05", s.StartTime)
+	if err != nil {
+		return nil
+	}
+	offset := 0
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		_, seconds := time.Now().In(loc).Zone()
+		offset = seconds / 60
+	}
+
+	days := s.DaysOfWeek
+	if s.IsOneTime() {
+		// A one-time run meets a weekly schedule whenever their weekdays match
+		runDate, err := time.Parse(RunDateLayout, *s.RunDate)
+		if err != nil {
+			return nil
+		}
+		days = []string{runDate.Weekday().String()}
+	}
+
+	windows := [][2]int{}
+	for _, day := range days {
+		index, ok := weekdayIndex[strings.ToLower(day)]
+		if !ok {
+			continue
+		}
+		start := index*24*60 + startTime.Hour()*60 + startTime.Minute() - offset
+		start = (start%minutesPerWeek + minutesPerWeek) % minutesPerWeek
+		windows = append(windows, [2]int{start, start + s.DurationMinutes})
+	}
+	return windows
+}
+
+// Overlaps reports whether the two schedules' run windows overlap on any day
+// of the week, regardless of whether either is active
+func (s *FilterSchedule) Overlaps(other *FilterSchedule) bool {
+	if s.IsOneTime() && other.IsOneTime() {
+		return s.oneTimeRunOverlaps(other)
+	}
+	for _, a := range s.weeklyWindows() {
+		for _, b := range other.weeklyWindows() {
+			// Compare b in this week and shifted a week either way so windows
+			// wrapping past the end of the week are caught
+			for _, shift := range []int{-minutesPerWeek, 0, minutesPerWeek} {
+				if a[0] < b[1]+shift && b[0]+shift < a[1] {
+					return true
+				}
 			}
 		}
 	}
+	return false
+}
 
-	return nil
+// oneTimeRunOverlaps reports whether two one-time schedules' runs overlap
+func (s *FilterSchedule) oneTimeRunOverlaps(other *FilterSchedule) bool {
+	aStart, aOK := s.oneTimeStart()
+	bStart, bOK := other.oneTimeStart()
+	if !aOK || !bOK {
+		return false
+	}
+	aEnd := aStart.Add(time.Duration(s.DurationMinutes) * time.Minute)
+	bEnd := bStart.Add(time.Duration(other.DurationMinutes) * time.Minute)
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
+
+// oneTimeStart returns the instant a one-time schedule runs
+func (s *FilterSchedule) oneTimeStart() (time.Time, bool) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	day, err := time.ParseInLocation(RunDateLayout, *s.RunDate, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	startTime, err := time.Parse("15:04:05", s.StartTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return scheduledInstant(day, startTime, loc), true
+}
+
+// FindScheduleConflicts returns the active schedules in existing whose run
+// windows overlap the schedule's, skipping the schedule itself. An inactive
+// schedule conflicts with nothing.
+func FindScheduleConflicts(schedule *FilterSchedule, existing []FilterSchedule) []FilterSchedule {
+	conflicts := []FilterSchedule{}
+	if !schedule.IsActive {
+		return conflicts
+	}
+	for _, other := range existing {
+		if other.ID == schedule.ID || !other.IsActive {
+			continue
+		}
+		if schedule.Overlaps(&other) {
+			conflicts = append(conflicts, other)
+		}
+	}
+	return conflicts
 }
 
 // GetStatusMessage returns a human-readable status message
@@ -370,3 +545,46 @@ func (e *ScheduleExecution) GetStatusMessage() string {
 		return "Unknown status"
 	}
 }
+
+// NextScheduledRun describes the earliest upcoming execution across all schedules
+type NextScheduledRun struct {
+	ScheduleID int        `json:"schedule_id"`
+	Name       string     `json:"name"`
+	FilterMode FilterMode `json:"filter_mode"`
+	Timezone   string     `json:"timezone"`
+	ExecutesAt time.Time  `json:"executes_at"` // UTC
+	LocalTime  string     `json:"local_time"`  // Execution time in the schedule's timezone
+}
+
+// FindNextScheduledRun returns the earliest upcoming execution among the given schedules,
+// or nil if none of them will execute (e.g. no active schedules)
+func FindNextScheduledRun(schedules []FilterSchedule) *NextScheduledRun {
+	var next *NextScheduledRun
+
+	for _, schedule := range schedules {
+		nextExecution := schedule.CalculateNextExecution()
+		if nextExecution == nil {
+			continue
+		}
+
+		if next != nil && !nextExecution.Before(next.ExecutesAt) {
+			continue
+		}
+
+		localTime := nextExecution.Format(time.RFC3339)
+		if loc, err := time.LoadLocation(schedule.Timezone); err == nil {
+			localTime = nextExecution.In(loc).Format(time.RFC3339)
+		}
+
+		next = &NextScheduledRun{
+			ScheduleID: schedule.ID,
+			Name:       schedule.Name,
+			FilterMode: schedule.FilterMode,
+			Timezone:   schedule.Timezone,
+			ExecutesAt: *nextExecution,
+			LocalTime:  localTime,
+		}
+	}
+
+	return next
+}
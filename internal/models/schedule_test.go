@@ -0,0 +1,259 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindNextScheduledRun_SelectsEarliest(t *testing.T) {
+	allDays := []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+	now := time.Now().UTC()
+
+	schedules := []FilterSchedule{
+		{ID: 1, Name: "Later", FilterMode: FilterModeHousehold, StartTime: now.Add(3 * time.Hour).Format("15:04:05"), DaysOfWeek: allDays, IsActive: true, Timezone: "UTC"},
+		{ID: 2, Name: "Earliest", FilterMode: FilterModeDrinking, StartTime: now.Add(1 * time.Hour).Format("15:04:05"), DaysOfWeek: allDays, IsActive: true, Timezone: "UTC"},
+		{ID: 3, Name: "Inactive", FilterMode: FilterModeHousehold, StartTime: now.Add(30 * time.Minute).Format("15:04:05"), DaysOfWeek: allDays, IsActive: false, Timezone: "UTC"},
+		{ID: 4, Name: "Middle", FilterMode: FilterModeHousehold, StartTime: now.Add(2 * time.Hour).Format("15:04:05"), DaysOfWeek: allDays, IsActive: true, Timezone: "UTC"},
+	}
+
+	next := FindNextScheduledRun(schedules)
+	if next == nil {
+		t.Fatal("Expected a next scheduled run, got nil")
+	}
+
+	if next.ScheduleID != 2 {
+		t.Errorf("Expected schedule 2 to run next, got %d (%s)", next.ScheduleID, next.Name)
+	}
+
+	if next.FilterMode != FilterModeDrinking {
+		t.Errorf("Expected filter mode %v, got %v", FilterModeDrinking, next.FilterMode)
+	}
+
+	if next.LocalTime == "" {
+		t.Error("Expected local time to be set")
+	}
+}
+
+func TestFindNextScheduledRun_NoActiveSchedules(t *testing.T) {
+	schedules := []FilterSchedule{
+		{ID: 1, Name: "Disabled", FilterMode: FilterModeDrinking, StartTime: "08:00:00", DaysOfWeek: []string{"monday"}, IsActive: false, Timezone: "UTC"},
+	}
+
+	if next := FindNextScheduledRun(schedules); next != nil {
+		t.Errorf("Expected nil for no active schedules, got %+v", next)
+	}
+
+	if next := FindNextScheduledRun(nil); next != nil {
+		t.Errorf("Expected nil for empty schedule list, got %+v", next)
+	}
+}
+
+func TestNextExecutionAfter_UsesScheduleTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		start    string
+		days     []string
+		from     time.Time
+		expected time.Time
+	}{
+		{
+			name:     "later today in New York",
+			timezone: "America/New_York",
+			start:    "08:00:00",
+			days:     []string{"wednesday"},
+			from:     time.Date(2024, 7, 10, 11, 0, 0, 0, time.UTC), // Wed 07:00 EDT
+			expected: time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "already passed today in New York rolls to next week",
+			timezone: "America/New_York",
+			start:    "08:00:00",
+			days:     []string{"wednesday"},
+			from:     time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC), // Exactly at the start
+			expected: time.Date(2024, 7, 17, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Tokyo day differs from the UTC day",
+			timezone: "Asia/Tokyo",
+			start:    "07:30:00",
+			days:     []string{"monday"},
+			from:     time.Date(2024, 7, 14, 20, 0, 0, 0, time.UTC), // Mon 05:00 JST, still Sunday in UTC
+			expected: time.Date(2024, 7, 14, 22, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "London winter and summer offsets",
+			timezone: "Europe/London",
+			start:    "09:00:00",
+			days:     []string{"monday"},
+			from:     time.Date(2024, 3, 26, 0, 0, 0, 0, time.UTC), // Tue before BST starts on Sun 31st
+			expected: time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "spring-forward gap rolls to the transition",
+			timezone: "America/New_York",
+			start:    "02:30:00",
+			days:     []string{"sunday"},
+			from:     time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), // 03:00 EDT
+		},
+		{
+			name:     "spring-forward gap in Europe",
+			timezone: "Europe/Berlin",
+			start:    "02:15",
+			days:     []string{"sunday"},
+			from:     time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), // 03:00 CEST
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := FilterSchedule{StartTime: NormalizeTimeFormat(tt.start), DaysOfWeek: tt.days, IsActive: true, Timezone: tt.timezone}
+			next := schedule.NextExecutionAfter(tt.from)
+			if next == nil {
+				t.Fatal("Expected a next execution, got nil")
+			}
+			if !next.Equal(tt.expected) || next.Location() != time.UTC {
+				t.Errorf("Expected %s, got %s", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestNextExecutionAfter_InvalidSchedules(t *testing.T) {
+	from := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	schedules := []FilterSchedule{
+		{StartTime: "08:00:00", DaysOfWeek: []string{"monday"}, IsActive: true, Timezone: "Mars/Olympus_Mons"},
+		{StartTime: "08:00:00", DaysOfWeek: []string{"monday"}, IsActive: false, Timezone: "UTC"},
+		{StartTime: "08:00:00", IsActive: true, Timezone: "UTC"},
+	}
+	for _, schedule := range schedules {
+		if next := schedule.NextExecutionAfter(from); next != nil {
+			t.Errorf("Expected no execution for %+v, got %s", schedule, next)
+		}
+	}
+}
+
+func TestFindScheduleConflicts(t *testing.T) {
+	existing := []FilterSchedule{
+		{ID: 1, Name: "Morning", StartTime: "08:00:00", DurationMinutes: 60, DaysOfWeek: []string{"monday", "wednesday"}, IsActive: true, Timezone: "UTC"},
+		{ID: 2, Name: "Late night", StartTime: "23:30:00", DurationMinutes: 60, DaysOfWeek: []string{"saturday"}, IsActive: true, Timezone: "UTC"},
+		{ID: 3, Name: "Disabled", StartTime: "12:00:00", DurationMinutes: 60, DaysOfWeek: []string{"friday"}, IsActive: false, Timezone: "UTC"},
+	}
+
+	tests := []struct {
+		name      string
+		schedule  FilterSchedule
+		conflicts []int
+	}{
+		{"overlaps on a shared day", FilterSchedule{StartTime: "08:30:00", DurationMinutes: 30, DaysOfWeek: []string{"Wednesday"}}, []int{1}},
+		{"back to back", FilterSchedule{StartTime: "09:00:00", DurationMinutes: 30, DaysOfWeek: []string{"monday"}}, nil},
+		{"same time on another day", FilterSchedule{StartTime: "08:00:00", DurationMinutes: 60, DaysOfWeek: []string{"tuesday"}}, nil},
+		{"spills past the end of the week", FilterSchedule{StartTime: "00:00:00", DurationMinutes: 15, DaysOfWeek: []string{"sunday"}}, []int{2}},
+		{"disabled schedules don't conflict", FilterSchedule{StartTime: "12:00:00", DurationMinutes: 60, DaysOfWeek: []string{"friday"}}, nil},
+		{"updating a schedule ignores itself", FilterSchedule{ID: 1, StartTime: "08:15:00", DurationMinutes: 60, DaysOfWeek: []string{"monday"}}, nil},
+		{"other timezone", FilterSchedule{StartTime: "17:30:00", DurationMinutes: 30, DaysOfWeek: []string{"monday"}, Timezone: "Asia/Tokyo"}, []int{1}}, // 08:30 UTC
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := tt.schedule
+			schedule.IsActive = true
+			if schedule.Timezone == "" {
+				schedule.Timezone = "UTC"
+			}
+			conflicts := FindScheduleConflicts(&schedule, existing)
+			if len(conflicts) != len(tt.conflicts) {
+				t.Fatalf("Expected conflicts with %v, got %+v", tt.conflicts, conflicts)
+			}
+			for i, id := range tt.conflicts {
+				if conflicts[i].ID != id {
+					t.Errorf("Expected conflict with schedule %d, got %d", id, conflicts[i].ID)
+				}
+			}
+		})
+	}
+
+	inactive := FilterSchedule{StartTime: "08:00:00", DurationMinutes: 60, DaysOfWeek: []string{"monday"}, Timezone: "UTC"}
+	if conflicts := FindScheduleConflicts(&inactive, existing); len(conflicts) != 0 {
+		t.Errorf("Expected an inactive schedule to conflict with nothing, got %+v", conflicts)
+	}
+}
+
+func TestNextExecutionAfter_OneTimeSchedule(t *testing.T) {
+	runDate := "2024-07-20"
+	schedule := FilterSchedule{StartTime: "06:00:00", RunDate: &runDate, IsActive: true, Timezone: "America/New_York"}
+
+	next := schedule.NextExecutionAfter(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	if expected := time.Date(2024, 7, 20, 10, 0, 0, 0, time.UTC); next == nil || !next.Equal(expected) {
+		t.Errorf("Expected the run date's start %s, got %v", expected, next)
+	}
+	if next := schedule.NextExecutionAfter(time.Date(2024, 7, 20, 10, 0, 0, 0, time.UTC)); next != nil {
+		t.Errorf("Expected no execution once the run has passed, got %s", next)
+	}
+}
+
+func TestCreateScheduleRequest_RequiresDaysOrRunDate(t *testing.T) {
+	runDate := "2024-07-20"
+	badDate := "20/07/2024"
+	base := CreateScheduleRequest{Name: "Flush", FilterMode: FilterModeDrinking, StartTime: "06:00", DurationMinutes: 30, Timezone: "UTC"}
+
+	tests := []struct {
+		name    string
+		days    []string
+		runDate *string
+		valid   bool
+	}{
+		{"days of week", []string{"monday"}, nil, true},
+		{"run date", nil, &runDate, true},
+		{"both", []string{"monday"}, &runDate, false},
+		{"neither", nil, nil, false},
+		{"malformed run date", nil, &badDate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := base
+			request.DaysOfWeek = tt.days
+			request.RunDate = tt.runDate
+			if err := request.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+
+	update := UpdateScheduleRequest{DaysOfWeek: []string{"monday"}, RunDate: &runDate}
+	if err := update.Validate(); err == nil {
+		t.Error("Expected an update setting both days_of_week and run_date to be rejected")
+	}
+}
+
+func TestUpcomingExecutions(t *testing.T) {
+	from := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC) // Wednesday
+	weekly := FilterSchedule{StartTime: "09:00:00", DaysOfWeek: []string{"monday", "thursday"}, IsActive: false, Timezone: "Asia/Tokyo"}
+
+	executions := weekly.UpcomingExecutions(from, 4)
+	expected := []time.Time{
+		time.Date(2024, 7, 11, 0, 0, 0, 0, time.UTC), // Thu 09:00 JST
+		time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), // Mon
+		time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 7, 22, 0, 0, 0, 0, time.UTC),
+	}
+	if len(executions) != len(expected) {
+		t.Fatalf("Expected %d executions for an inactive schedule, got %v", len(expected), executions)
+	}
+	for i := range expected {
+		if !executions[i].Equal(expected[i]) {
+			t.Errorf("Execution %d: expected %s, got %s", i, expected[i], executions[i])
+		}
+	}
+	if weekly.IsActive {
+		t.Error("Expected previewing to leave the schedule inactive")
+	}
+
+	runDate := "2024-07-12"
+	once := FilterSchedule{StartTime: "09:00:00", RunDate: &runDate, IsActive: true, Timezone: "UTC"}
+	if executions := once.UpcomingExecutions(from, 5); len(executions) != 1 {
+		t.Errorf("Expected a single execution for a one-time schedule, got %v", executions)
+	}
+}
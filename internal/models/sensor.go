@@ -15,14 +15,37 @@ type SensorReading struct {
 	Ph         float64    `json:"ph"`
 	Turbidity  float64    `json:"turbidity"`
 	TDS        float64    `json:"tds"`
+
+	// ServerReceivedAt is when the server ingested the reading; Timestamp holds
+	// the device-reported time when the device sent one
+	ServerReceivedAt *time.Time `json:"server_received_at,omitempty"`
+
+	// Quality is the assessment stored at ingestion (nil for readings stored
+	// before it was persisted); set by the stores, never by clients
+	Quality *ReadingQuality `json:"-"`
+}
+
+// SensorCalibration is the voltage at which each analog sensor's reading is
+// clamped; higher voltages are treated as this value
+type SensorCalibration struct {
+	PhMaxVoltage        float64 `json:"ph_max_voltage"`
+	TurbidityMaxVoltage float64 `json:"turbidity_max_voltage"`
+	TDSMaxVoltage       float64 `json:"tds_max_voltage"`
+}
+
+// DefaultSensorCalibration is the calibration the voltage conversions use
+var DefaultSensorCalibration = SensorCalibration{
+	PhMaxVoltage:        3.3,
+	TurbidityMaxVoltage: 3.0,
+	TDSMaxVoltage:       2.3,
 }
 
 func ConvertVoltageToTurbidity(voltage float64) float64 {
 	if voltage < 0 {
 		return 0
 	}
-	if voltage > 3.0 {
-		voltage = 3.0
+	if voltage > DefaultSensorCalibration.TurbidityMaxVoltage {
+		voltage = DefaultSensorCalibration.TurbidityMaxVoltage
 	}
 	return (-5 * 1000 / 3.3 * voltage) + 1005.0
 }
@@ -31,20 +54,20 @@ func ConvertVoltageToTDS(voltage float64) float64 {
 	if voltage < 0 {
 		return 0
 	}
-	if voltage > 2.3 {
-		voltage = 2.3
+	if voltage > DefaultSensorCalibration.TDSMaxVoltage {
+		voltage = DefaultSensorCalibration.TDSMaxVoltage
 	}
-	return (voltage / 2.3) * 1000.0
+	return (voltage / DefaultSensorCalibration.TDSMaxVoltage) * 1000.0
 }
 
 func ConvertVoltageToPh(voltage float64) float64 {
 	if voltage < 0 {
 		return 0
 	}
-	if voltage > 3.3 {
-		voltage = 3.3
+	if voltage > DefaultSensorCalibration.PhMaxVoltage {
+		voltage = DefaultSensorCalibration.PhMaxVoltage
 	}
-	return ((voltage / 3.3) * 14.0) - 1.0
+	return ((voltage / DefaultSensorCalibration.PhMaxVoltage) * 14.0) - 1.0
 }
 
 // SensorData represents the raw JSON structure received from the device
@@ -68,58 +91,153 @@ type WaterQualityStatus struct {
 	TDS            float64    `json:"tds"`
 	TDSStatus      string     `json:"tds_status"`
 	OverallQuality string     `json:"overall_quality"`
+	WQI            float64    `json:"wqi"`       // Composite 0-100 water quality index
+	WQILabel       string     `json:"wqi_label"` // Quality label for the WQI score
 }
 
-// GetDeviceType determines if device is pre-filtration or post-filtration based on device ID
-func (s *SensorReading) GetDeviceType() string {
-	deviceID := strings.ToLower(s.DeviceID)
-	if strings.Contains(deviceID, "pre") {
-		return "pre_filtration"
+// Device type classifications
+const (
+	DeviceTypePreFiltration  = "pre_filtration"
+	DeviceTypePostFiltration = "post_filtration"
+	DeviceTypeUnknown        = "unknown"
+)
+
+// DeviceTypeInfo describes how a device is classified
+type DeviceTypeInfo struct {
+	DeviceID     string `json:"device_id"`
+	DeviceType   string `json:"device_type"`   // Effective classification
+	InferredType string `json:"inferred_type"` // Classification from the device ID alone
+	Overridden   bool   `json:"overridden"`    // True if device_type comes from device metadata
+}
+
+// Device attention levels, from most to least urgent
+const (
+	AttentionCritical = "critical"
+	AttentionWarning  = "warning"
+	AttentionOK       = "ok"
+)
+
+// DeviceOverview combines a device's connectivity, latest water quality and
+// filter health for fleet overview screens
+type DeviceOverview struct {
+	DeviceID         string              `json:"device_id"`
+	DeviceType       string              `json:"device_type"`
+	Online           bool                `json:"online"`
+	LastSeen         *time.Time          `json:"last_seen"`       // Timestamp of the latest reading, null if none
+	FilterMode       FilterMode          `json:"filter_mode"`     // Mode of the latest reading
+	LatestQuality    *WaterQualityStatus `json:"latest_quality"`  // Null if the device has no readings
+	FilterHealth     *FilterHealth       `json:"filter_health"`   // Null if never analyzed; includes total flow processed
+	AttentionLevel   string              `json:"attention_level"` // critical, warning or ok
+	AttentionReasons []string            `json:"attention_reasons"`
+}
+
+// IsValidDeviceType checks if the device type is a known classification
+func IsValidDeviceType(deviceType string) bool {
+	return deviceType == DeviceTypePreFiltration || deviceType == DeviceTypePostFiltration || deviceType == DeviceTypeUnknown
+}
+
+// InferDeviceType classifies a device as pre/post-filtration from substrings of its ID
+func InferDeviceType(deviceID string) string {
+	id := strings.ToLower(deviceID)
+	if strings.Contains(id, "pre") {
+		return DeviceTypePreFiltration
+	}
+	if strings.Contains(id, "post") {
+		return DeviceTypePostFiltration
 	}
-	if strings.Contains(deviceID, "post") {
-		return "post_filtration"
+	return DeviceTypeUnknown
+}
+
+// ClassifyDeviceType returns the device type, preferring an override from device metadata
+// over inference from the device ID
+func ClassifyDeviceType(deviceID string, overrides map[string]string) DeviceTypeInfo {
+	info := DeviceTypeInfo{
+		DeviceID:     deviceID,
+		InferredType: InferDeviceType(deviceID),
+	}
+	info.DeviceType = info.InferredType
+
+	if override, exists := overrides[deviceID]; exists && IsValidDeviceType(override) {
+		info.DeviceType = override
+		info.Overridden = true
 	}
-	return "unknown"
+
+	return info
+}
+
+// GetDeviceType determines if device is pre-filtration or post-filtration based on device ID
+func (s *SensorReading) GetDeviceType() string {
+	return InferDeviceType(s.DeviceID)
 }
 
 // IsPreFiltration returns true if this is a pre-filtration device
 func (s *SensorReading) IsPreFiltration() bool {
-	return s.GetDeviceType() == "pre_filtration"
+	return s.GetDeviceType() == DeviceTypePreFiltration
 }
 
 // IsPostFiltration returns true if this is a post-filtration device
 func (s *SensorReading) IsPostFiltration() bool {
-	return s.GetDeviceType() == "post_filtration"
+	return s.GetDeviceType() == DeviceTypePostFiltration
 }
 
-// ValidateReading checks if sensor values are within acceptable ranges
-func (s *SensorReading) ValidateReading() bool {
-	// DeviceID must be specified and valid
+// FieldValidation is the validation outcome of a single reading field
+type FieldValidation struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"value"`
+	Valid bool        `json:"valid"`
+	Error string      `json:"error,omitempty"` // Why the value was rejected
+}
+
+// ValidateFields checks every field of the reading against the ingestion rules
+// and reports each one, so a rejected reading shows all of its problems at once
+func (s *SensorReading) ValidateFields() []FieldValidation {
+	check := func(field string, value interface{}, problem string) FieldValidation {
+		return FieldValidation{Field: field, Value: value, Valid: problem == "", Error: problem}
+	}
+
+	deviceProblem := ""
 	if s.DeviceID == "" {
-		return false
+		deviceProblem = "device_id is required"
+	} else if !s.IsValidDeviceID() {
+		deviceProblem = "device_id must be stm32_pre, stm32_post or stm32_main"
 	}
-	if !s.IsValidDeviceID() {
-		return false
+	modeProblem := ""
+	if s.FilterMode != FilterModeDrinking && s.FilterMode != FilterModeHousehold {
+		modeProblem = "filter_mode must be drinking_water or household_water"
 	}
-	// Flow should be non-negative (L/min units)
+	flowProblem := ""
 	if s.Flow < 0 {
-		return false
+		flowProblem = "flow must not be negative (L/min)"
 	}
-	// Ph should be between 0-14 (pH scale)
+	phProblem := ""
 	if s.Ph < 0 || s.Ph > 14 {
-		return false
+		phProblem = "ph must be between 0 and 14"
 	}
-	// Turbidity should be between 0-1000 NTU
+	turbidityProblem := ""
 	if s.Turbidity < 0 || s.Turbidity > 1000 {
-		return false
+		turbidityProblem = "turbidity must be between 0 and 1000 NTU"
 	}
-	// TDS should be between 0-1000 PPM
+	tdsProblem := ""
 	if s.TDS < 0 || s.TDS > 1000 {
-		return false
+		tdsProblem = "tds must be between 0 and 1000 PPM"
 	}
-	// FilterMode should be valid
-	if s.FilterMode != FilterModeDrinking && s.FilterMode != FilterModeHousehold {
-		return false
+
+	return []FieldValidation{
+		check("device_id", s.DeviceID, deviceProblem),
+		check("filter_mode", s.FilterMode, modeProblem),
+		check("flow", s.Flow, flowProblem),
+		check("ph", s.Ph, phProblem),
+		check("turbidity", s.Turbidity, turbidityProblem),
+		check("tds", s.TDS, tdsProblem),
+	}
+}
+
+// ValidateReading checks if sensor values are within acceptable ranges
+func (s *SensorReading) ValidateReading() bool {
+	for _, field := range s.ValidateFields() {
+		if !field.Valid {
+			return false
+		}
 	}
 	return true
 }
@@ -130,38 +248,41 @@ func (s *SensorReading) IsValidDeviceID() bool {
 	return deviceID == "stm32_pre" || deviceID == "stm32_post" || deviceID == "stm32_main"
 }
 
-// GetPhStatus returns the pH status based on water quality standards
+// GetPhStatus returns the pH status against the quality thresholds of the reading's mode
 func (s *SensorReading) GetPhStatus() string {
+	thresholds := QualityThresholdsFor(s.FilterMode)
 	switch {
-	case s.Ph < 7.0:
+	case s.Ph < thresholds.PhMin:
 		return "Dangerously Acidic"
-	case s.Ph > 8.5:
+	case s.Ph > thresholds.PhMax:
 		return "Dangerously Alkaline"
 	default:
 		return "Normal"
 	}
 }
 
-// GetTurbidityStatus returns turbidity status based on water quality standards
+// GetTurbidityStatus returns the turbidity status against the quality thresholds of the reading's mode
 func (s *SensorReading) GetTurbidityStatus() string {
+	thresholds := QualityThresholdsFor(s.FilterMode)
 	switch {
-	case s.Turbidity > 4.0:
+	case s.Turbidity > thresholds.TurbidityGoodMax:
 		return "Poor"
-	case s.Turbidity > 1.0:
+	case s.Turbidity > thresholds.TurbidityExcellentMax:
 		return "Good"
 	default:
 		return "Excellent"
 	}
 }
 
-// GetTDSStatus returns TDS status based on water quality standards
+// GetTDSStatus returns the TDS status against the quality thresholds of the reading's mode
 func (s *SensorReading) GetTDSStatus() string {
+	thresholds := QualityThresholdsFor(s.FilterMode)
 	switch {
-	case s.TDS > 900:
+	case s.TDS > thresholds.TDSFairMax:
 		return "Poor"
-	case s.TDS < 900 && s.TDS > 600:
+	case s.TDS < thresholds.TDSFairMax && s.TDS > thresholds.TDSGoodMax:
 		return "Fair"
-	case s.TDS < 600 && s.TDS > 300:
+	case s.TDS < thresholds.TDSGoodMax && s.TDS > thresholds.TDSExcellentMax:
 		return "Good"
 	default:
 		return "Excellent"
@@ -169,20 +290,10 @@ func (s *SensorReading) GetTDSStatus() string {
 }
 
 // ToWaterQualityStatus converts a SensorReading to WaterQualityStatus with assessments
+// The per-metric and overall statuses come from the stored assessment when it is current.
 func (s *SensorReading) ToWaterQualityStatus() WaterQualityStatus {
-	phStatus := s.GetPhStatus()
-	turbStatus := s.GetTurbidityStatus()
-	tdsStatus := s.GetTDSStatus()
-
-	// Determine overall quality
-	overallQuality := "Good"
-	if phStatus != "Normal" || turbStatus == "Poor" || tdsStatus == "Poor" {
-		overallQuality = "Danger"
-	} else if (turbStatus == "Good" || tdsStatus == "Fair") && phStatus == "Normal" {
-		overallQuality = "Good"
-	} else if turbStatus == "Excellent" || tdsStatus == "Excellent" || phStatus == "Normal" {
-		overallQuality = "Excellent"
-	}
+	quality := s.CurrentQuality()
+	wqi := s.WaterQualityIndex()
 
 	return WaterQualityStatus{
 		DeviceID:       s.DeviceID,
@@ -190,13 +301,280 @@ func (s *SensorReading) ToWaterQualityStatus() WaterQualityStatus {
 		FilterMode:     s.FilterMode,
 		Flow:           s.Flow,
 		Ph:             s.Ph,
-		PhStatus:       phStatus,
+		PhStatus:       quality.PhStatus,
 		Turbidity:      s.Turbidity,
-		TurbStatus:     turbStatus,
+		TurbStatus:     quality.TurbidityStatus,
 		TDS:            s.TDS,
-		TDSStatus:      tdsStatus,
-		OverallQuality: overallQuality,
+		TDSStatus:      quality.TDSStatus,
+		OverallQuality: quality.OverallQuality,
+		WQI:            wqi.Score,
+		WQILabel:       wqi.Label,
+	}
+}
+
+// DrinkingSafetyIssues returns why the reading is unsafe to drink, using the same
+// assessments as ToWaterQualityStatus; an empty result means the water is safe
+func (s *SensorReading) DrinkingSafetyIssues() []string {
+	issues := []string{}
+	if status := s.GetPhStatus(); status != "Normal" {
+		issues = append(issues, fmt.Sprintf("pH %.2f is %s", s.Ph, strings.ToLower(status)))
+	}
+	if s.GetTurbidityStatus() == "Poor" {
+		issues = append(issues, fmt.Sprintf("turbidity %.2f NTU is too high", s.Turbidity))
+	}
+	if s.GetTDSStatus() == "Poor" {
+		issues = append(issues, fmt.Sprintf("TDS %.0f ppm is too high", s.TDS))
+	}
+	return issues
+}
+
+// SensorMetrics lists the numeric sensor_readings columns that can be queried by name
+var SensorMetrics = []string{"ph", "tds", "turbidity", "flow"}
+
+// IsValidSensorMetric reports whether metric names a numeric sensor metric
+func IsValidSensorMetric(metric string) bool {
+	for _, m := range SensorMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricValue returns the reading's value for a named metric
+func (s *SensorReading) MetricValue(metric string) (float64, bool) {
+	switch metric {
+	case "ph":
+		return s.Ph, true
+	case "tds":
+		return s.TDS, true
+	case "turbidity":
+		return s.Turbidity, true
+	case "flow":
+		return s.Flow, true
+	default:
+		return 0, false
+	}
+}
+
+// FilterModeCount summarizes the readings recorded under one filter mode
+type FilterModeCount struct {
+	Mode            FilterMode `json:"mode"`
+	ReadingCount    int        `json:"reading_count"`
+	LatestTimestamp time.Time  `json:"latest_timestamp"`
+}
+
+// HeatmapBucket is the average of a metric for one day-of-week/hour-of-day cell
+type HeatmapBucket struct {
+	DayOfWeek int     `json:"day_of_week"` // 0 = Sunday
+	Hour      int     `json:"hour"`        // 0-23, UTC
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+}
+
+// MetricHeatmap is a 7x24 grid of metric averages indexed by [day_of_week][hour];
+// cells without readings are null
+type MetricHeatmap struct {
+	Metric   string          `json:"metric"`
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Days     []string        `json:"days"`
+	Averages [7][24]*float64 `json:"averages"`
+	Counts   [7][24]int      `json:"counts"`
+}
+
+// NewMetricHeatmap arranges aggregated buckets into a day-of-week by hour grid
+func NewMetricHeatmap(metric string, start, end time.Time, buckets []HeatmapBucket) *MetricHeatmap {
+	heatmap := &MetricHeatmap{
+		Metric: metric,
+		Start:  start,
+		End:    end,
+		Days:   make([]string, 7),
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		heatmap.Days[day] = day.String()
+	}
+
+	for _, bucket := range buckets {
+		if bucket.DayOfWeek < 0 || bucket.DayOfWeek > 6 || bucket.Hour < 0 || bucket.Hour > 23 {
+			continue
+		}
+		average := bucket.Average
+		heatmap.Averages[bucket.DayOfWeek][bucket.Hour] = &average
+		heatmap.Counts[bucket.DayOfWeek][bucket.Hour] = bucket.Count
+	}
+	return heatmap
+}
+
+// Aggregation intervals for time-bucketed metric statistics
+const (
+	AggregateIntervalHour = "hour"
+	AggregateIntervalDay  = "day"
+)
+
+// IsValidAggregateInterval reports whether interval is a supported bucket size
+func IsValidAggregateInterval(interval string) bool {
+	return interval == AggregateIntervalHour || interval == AggregateIntervalDay
+}
+
+// TruncateToInterval returns the start of the UTC hour or day containing t
+func TruncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == AggregateIntervalDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// AggregateBucket is the min/max/average of a metric over one hour or day,
+// starting at BucketStart (UTC)
+type AggregateBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+	Count       int       `json:"count"`
+}
+
+// HistogramBin counts the values in [Lower, Upper); the last bin also includes Upper
+type HistogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// MetricHistogram is the distribution of a metric over equal-width bins spanning
+// the observed min to max; Min/Max are null and Bins empty when there is no data
+type MetricHistogram struct {
+	Metric string         `json:"metric"`
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Total  int            `json:"total"`
+	Min    *float64       `json:"min"`
+	Max    *float64       `json:"max"`
+	Bins   []HistogramBin `json:"bins"`
+}
+
+// HistogramBinIndex returns the bin a value falls in when [min, max] is split into
+// bins equal-width bins; max itself belongs to the last bin
+func HistogramBinIndex(value, min, max float64, bins int) int {
+	if bins <= 1 || max <= min {
+		return 0
+	}
+	index := int((value - min) / (max - min) * float64(bins))
+	if index < 0 {
+		return 0
+	}
+	if index >= bins {
+		return bins - 1
+	}
+	return index
+}
+
+// NewMetricHistogram builds the histogram bins from per-bin counts over [min, max]
+func NewMetricHistogram(metric string, start, end time.Time, min, max float64, counts []int) *MetricHistogram {
+	histogram := &MetricHistogram{
+		Metric: metric,
+		Start:  start,
+		End:    end,
+		Bins:   []HistogramBin{},
+	}
+	for _, count := range counts {
+		histogram.Total += count
+	}
+	if histogram.Total == 0 {
+		return histogram
+	}
+
+	histogram.Min, histogram.Max = &min, &max
+	width := (max - min) / float64(len(counts))
+	for i, count := range counts {
+		bin := HistogramBin{
+			Lower: min + float64(i)*width,
+			Upper: min + float64(i+1)*width,
+			Count: count,
+		}
+		if i == len(counts)-1 {
+			bin.Upper = max
+		}
+		histogram.Bins = append(histogram.Bins, bin)
+	}
+	return histogram
+}
+
+// ValueRange is an inclusive bound on a sensor metric; nil ends are unbounded
+type ValueRange struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Contains reports whether value lies within the range
+func (vr ValueRange) Contains(value float64) bool {
+	if vr.Min != nil && value < *vr.Min {
+		return false
+	}
+	if vr.Max != nil && value > *vr.Max {
+		return false
+	}
+	return true
+}
+
+// IsSet reports whether either end of the range is bounded
+func (vr ValueRange) IsSet() bool {
+	return vr.Min != nil || vr.Max != nil
+}
+
+// Page size bounds for reading searches
+const (
+	DefaultReadingFilterLimit = 100
+	MaxReadingFilterLimit     = 1000
+)
+
+// ReadingFilter describes a sensor reading search: exact device/mode matches,
+// an inclusive time range, per-metric value ranges, sort order and pagination
+type ReadingFilter struct {
+	DeviceID   string     `json:"device_id,omitempty"`
+	FilterMode FilterMode `json:"filter_mode,omitempty"`
+	Start      *time.Time `json:"start,omitempty"`
+	End        *time.Time `json:"end,omitempty"`
+	Ph         ValueRange `json:"ph"`
+	TDS        ValueRange `json:"tds"`
+	Turbidity  ValueRange `json:"turbidity"`
+	Flow       ValueRange `json:"flow"`
+	SortAsc    bool       `json:"sort_asc"` // Oldest first when true, newest first otherwise
+	Limit      int        `json:"limit"`    // Defaults to DefaultReadingFilterLimit when <= 0
+	Offset     int        `json:"offset"`
+}
+
+// MetricRanges returns the filter's value ranges keyed by sensor_readings column
+func (f ReadingFilter) MetricRanges() map[string]ValueRange {
+	return map[string]ValueRange{
+		"ph":        f.Ph,
+		"tds":       f.TDS,
+		"turbidity": f.Turbidity,
+		"flow":      f.Flow,
+	}
+}
+
+// Matches reports whether a reading satisfies every predicate of the filter
+// (sorting and pagination are applied by the store)
+func (f ReadingFilter) Matches(reading SensorReading) bool {
+	if f.DeviceID != "" && reading.DeviceID != f.DeviceID {
+		return false
 	}
+	if f.FilterMode != "" && reading.FilterMode != f.FilterMode {
+		return false
+	}
+	if f.Start != nil && reading.Timestamp.Before(*f.Start) {
+		return false
+	}
+	if f.End != nil && reading.Timestamp.After(*f.End) {
+		return false
+	}
+	return f.Ph.Contains(reading.Ph) &&
+		f.TDS.Contains(reading.TDS) &&
+		f.Turbidity.Contains(reading.Turbidity) &&
+		f.Flow.Contains(reading.Flow)
 }
 
 // FilterMode represents the available water filtration modes
@@ -207,6 +585,10 @@ const (
 	FilterModeHousehold FilterMode = "household_water"
 )
 
+// FilterModeUnknown is reported as the current mode when it can't be read,
+// e.g. while the data store is unavailable. It is never a valid mode to set.
+const FilterModeUnknown FilterMode = "unknown"
+
 // FilterCommand represents a command to control the water filter
 type FilterCommand struct {
 	Command   string     `json:"command"`
@@ -214,6 +596,90 @@ type FilterCommand struct {
 	Timestamp time.Time  `json:"timestamp"`
 }
 
+// DeviceStatus is a device's profile and operational state
+type DeviceStatus struct {
+	DeviceID          string     `json:"device_id"`
+	Name              string     `json:"name"`
+	IsActive          bool       `json:"is_active"`
+	LastSeen          *time.Time `json:"last_seen"` // Last reading received; nil before any
+	TotalReadings     int        `json:"total_readings"`
+	CurrentFilterMode FilterMode `json:"current_filter_mode"`
+	TotalFlowLiters   float64    `json:"total_flow_liters"` // Accumulated since the current filter mode started
+}
+
+// maxDeviceNameLength matches the device_status.name column
+const maxDeviceNameLength = 100
+
+// DeviceStatusUpdate is a partial update of a device's profile; nil fields are left unchanged
+type DeviceStatusUpdate struct {
+	Name     *string `json:"name"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// Validate checks that the update changes something and the name fits
+func (u DeviceStatusUpdate) Validate() error {
+	if u.Name == nil && u.IsActive == nil {
+		return fmt.Errorf("set name or is_active")
+	}
+	if u.Name != nil && len(*u.Name) > maxDeviceNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxDeviceNameLength)
+	}
+	return nil
+}
+
+// Commands the backend sends to devices
+const (
+	DeviceCommandSetFilterMode = "set_filter_mode"
+	DeviceCommandSetLED        = "set_led"
+)
+
+// Delivery states of a command sent to a device
+const (
+	DeviceCommandSent      = "sent"      // Being published to the MQTT broker
+	DeviceCommandDelivered = "delivered" // Acknowledged by the MQTT broker or picked up by the polling device
+	DeviceCommandFailed    = "failed"    // Publishing failed
+	DeviceCommandQueued    = "queued"    // Waiting for the device to poll for it
+)
+
+// LED states the STM32 accepts
+const (
+	LEDCommandOn  = "ON"
+	LEDCommandOff = "OFF"
+)
+
+// IsValidLEDCommand reports whether command is an LED state the STM32 accepts
+func IsValidLEDCommand(command string) bool {
+	return command == LEDCommandOn || command == LEDCommandOff
+}
+
+// DeviceCommand is a log entry for a command sent to a device
+type DeviceCommand struct {
+	ID       int       `json:"id"`
+	DeviceID string    `json:"device_id"`
+	Command  string    `json:"command"`
+	Payload  string    `json:"payload"` // Command argument, e.g. the filter mode
+	Status   string    `json:"status"`  // sent, delivered, failed or queued
+	Error    string    `json:"error,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// Reasons recorded in the filter mode change audit log
+const (
+	FilterModeChangeManual      = "manual"       // Changed through the API
+	FilterModeChangeSchedule    = "schedule"     // Changed by a filter schedule
+	FilterModeChangeAutoQuality = "auto_quality" // Changed by the auto-mode policy after sustained poor quality
+)
+
+// FilterModeChange is an audit log entry for a filter mode change
+type FilterModeChange struct {
+	ID        int        `json:"id"`
+	FromMode  FilterMode `json:"from_mode"`
+	ToMode    FilterMode `json:"to_mode"`
+	Reason    string     `json:"reason"`
+	Details   string     `json:"details,omitempty"`
+	ChangedAt time.Time  `json:"changed_at"`
+}
+
 // FilterStatus represents the current status of the water filter
 type FilterStatus struct {
 	CurrentMode         FilterMode      `json:"current_mode"`
@@ -256,6 +722,27 @@ type FiltrationProcess struct {
 	CanInterrupt bool    `json:"can_interrupt"`
 }
 
+// DefaultTargetVolume is the liters filtered when no target volume is requested
+const DefaultTargetVolume = 5.0
+
+// TargetVolumeBounds is the range of target volumes a filtration process may
+// be started with. Outside it a process would complete instantly or never.
+type TargetVolumeBounds struct {
+	Min float64 // Smallest allowed target volume in liters
+	Max float64 // Largest allowed target volume in liters
+}
+
+// DefaultTargetVolumeBounds are the target volume bounds used when none are configured
+var DefaultTargetVolumeBounds = TargetVolumeBounds{Min: 0.5, Max: 1000}
+
+// Validate checks that a target volume lies within the bounds
+func (b TargetVolumeBounds) Validate(volume float64) error {
+	if volume <= 0 || volume < b.Min || volume > b.Max {
+		return fmt.Errorf("target_volume must be between %g and %g liters, got %g", b.Min, b.Max, volume)
+	}
+	return nil
+}
+
 // CommandResponse represents a response from the STM32 device
 type CommandResponse struct {
 	Command   string    `json:"command"`
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"testing"
 	"time"
 )
@@ -259,4 +260,200 @@ func TestFiltrationProcess_IsProcessingOrSwitching(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestClassifyDeviceType_Inference(t *testing.T) {
+	tests := map[string]string{
+		"stm32_pre":      DeviceTypePreFiltration,
+		"STM32_POST":     DeviceTypePostFiltration,
+		"stm32_main":     DeviceTypeUnknown,
+		"tank_inlet_pre": DeviceTypePreFiltration,
+	}
+
+	for deviceID, expected := range tests {
+		info := ClassifyDeviceType(deviceID, nil)
+		if info.DeviceType != expected {
+			t.Errorf("Expected %s to be classified as %s, got %s", deviceID, expected, info.DeviceType)
+		}
+		if info.Overridden {
+			t.Errorf("Expected %s classification not to be overridden", deviceID)
+		}
+	}
+}
+
+func TestClassifyDeviceType_OverrideTakesPrecedence(t *testing.T) {
+	overrides := map[string]string{
+		"sensor_a":   DeviceTypePreFiltration,
+		"stm32_post": DeviceTypeUnknown,
+		"sensor_b":   "not_a_type",
+	}
+
+	info := ClassifyDeviceType("sensor_a", overrides)
+	if info.DeviceType != DeviceTypePreFiltration || !info.Overridden {
+		t.Errorf("Expected sensor_a to be overridden to pre_filtration, got %+v", info)
+	}
+	if info.InferredType != DeviceTypeUnknown {
+		t.Errorf("Expected inferred type unknown, got %s", info.InferredType)
+	}
+
+	info = ClassifyDeviceType("stm32_post", overrides)
+	if info.DeviceType != DeviceTypeUnknown || !info.Overridden {
+		t.Errorf("Expected override to win over substring inference, got %+v", info)
+	}
+
+	info = ClassifyDeviceType("sensor_b", overrides)
+	if info.Overridden {
+		t.Errorf("Expected invalid override to be ignored, got %+v", info)
+	}
+}
+
+func TestCalculateWQI_KnownReadings(t *testing.T) {
+	tests := []struct {
+		name      string
+		reading   SensorReading
+		wantScore float64
+		wantLabel string
+	}{
+		{"ideal water", SensorReading{Ph: 7.0, Turbidity: 0, TDS: 0}, 100, "Excellent"},
+		{"typical filtered", SensorReading{Ph: 7.3, Turbidity: 1.0, TDS: 100}, 90, "Excellent"},
+		{"moderate", SensorReading{Ph: 8.5, Turbidity: 4.0, TDS: 400}, 58, "Good"},
+		{"raw water", SensorReading{Ph: 5.5, Turbidity: 12.0, TDS: 1200}, 10, "Danger"},
+	}
+
+	for _, tt := range tests {
+		wqi := CalculateWQI(&tt.reading, DefaultWQIWeights)
+		if wqi.Score != tt.wantScore || wqi.Label != tt.wantLabel {
+			t.Errorf("%s: expected %.1f (%s), got %.1f (%s)", tt.name, tt.wantScore, tt.wantLabel, wqi.Score, wqi.Label)
+		}
+	}
+}
+
+func TestCalculateWQI_WeightSensitivity(t *testing.T) {
+	// Perfect pH, very turbid water
+	reading := SensorReading{Ph: 7.0, Turbidity: 8.0, TDS: 0}
+
+	phHeavy := CalculateWQI(&reading, WQIWeights{Ph: 0.8, Turbidity: 0.1, TDS: 0.1})
+	turbidityHeavy := CalculateWQI(&reading, WQIWeights{Ph: 0.1, Turbidity: 0.8, TDS: 0.1})
+	if phHeavy.Score != 92 || turbidityHeavy.Score != 36 {
+		t.Errorf("Expected 92 and 36, got %.1f and %.1f", phHeavy.Score, turbidityHeavy.Score)
+	}
+
+	// Weights are relative: scaling them does not change the score
+	if scaled := CalculateWQI(&reading, WQIWeights{Ph: 8, Turbidity: 1, TDS: 1}); scaled.Score != phHeavy.Score {
+		t.Errorf("Expected scaled weights to give %.1f, got %.1f", phHeavy.Score, scaled.Score)
+	}
+
+	if err := SetWQIWeights(WQIWeights{Ph: -1, Turbidity: 1, TDS: 1}); err == nil {
+		t.Error("Expected negative weights to be rejected")
+	}
+	if err := SetWQIWeights(WQIWeights{}); err == nil {
+		t.Error("Expected all-zero weights to be rejected")
+	}
+
+	if err := SetWQIWeights(WQIWeights{Ph: 0, Turbidity: 1, TDS: 0}); err != nil {
+		t.Fatalf("Failed to set weights: %v", err)
+	}
+	defer SetWQIWeights(DefaultWQIWeights)
+	if status := reading.ToWaterQualityStatus(); status.WQI != 20 || status.WQILabel != "Danger" {
+		t.Errorf("Expected the configured weights in the quality status, got %.1f (%s)", status.WQI, status.WQILabel)
+	}
+}
+
+func TestQualityThresholds_ModeOverridesAndRulesVersion(t *testing.T) {
+	drinking := SensorReading{FilterMode: FilterModeDrinking, Ph: 6.8, Turbidity: 0.8, TDS: 350}
+	household := SensorReading{FilterMode: FilterModeHousehold, Ph: 6.8, Turbidity: 0.8, TDS: 350}
+
+	// Built-in limits: pH 6.8 is below 7.0 and TDS 350 is above 300
+	if drinking.GetPhStatus() != "Dangerously Acidic" || drinking.GetTDSStatus() != "Good" {
+		t.Fatalf("Expected the built-in limits, got %s and %s", drinking.GetPhStatus(), drinking.GetTDSStatus())
+	}
+	if QualityRulesVersion() != BuiltinQualityRulesVersion {
+		t.Fatalf("Expected the built-in rules version, got %s", QualityRulesVersion())
+	}
+
+	invalid := DefaultQualityThresholds
+	invalid.TDSGoodMax = invalid.TDSFairMax
+	if err := SetQualityThresholds(DefaultQualityThresholds, map[FilterMode]QualityThresholds{FilterModeDrinking: invalid}); err == nil {
+		t.Error("Expected unordered TDS limits to be rejected")
+	}
+
+	relaxed := DefaultQualityThresholds
+	relaxed.PhMin = 6.5
+	relaxed.TDSExcellentMax = 400
+	if err := SetQualityThresholds(DefaultQualityThresholds, map[FilterMode]QualityThresholds{FilterModeHousehold: relaxed}); err != nil {
+		t.Fatalf("Failed to set thresholds: %v", err)
+	}
+	defer SetQualityThresholds(DefaultQualityThresholds, nil)
+
+	if household.GetPhStatus() != "Normal" || household.GetTDSStatus() != "Excellent" {
+		t.Errorf("Expected the household override, got %s and %s", household.GetPhStatus(), household.GetTDSStatus())
+	}
+	if drinking.GetPhStatus() != "Dangerously Acidic" || drinking.GetTDSStatus() != "Good" {
+		t.Errorf("Expected drinking to keep the defaults, got %s and %s", drinking.GetPhStatus(), drinking.GetTDSStatus())
+	}
+	if status := household.ToWaterQualityStatus(); status.OverallQuality == "Danger" {
+		t.Errorf("Expected the overall quality to use the override, got %s", status.OverallQuality)
+	}
+	if status := drinking.ToWaterQualityStatus(); status.OverallQuality != "Danger" {
+		t.Errorf("Expected acidic drinking water to be dangerous, got %s", status.OverallQuality)
+	}
+
+	// Custom limits make assessments stamped under the built-in ones stale
+	version := QualityRulesVersion()
+	if version == BuiltinQualityRulesVersion {
+		t.Error("Expected custom thresholds to change the rules version")
+	}
+	SetQualityThresholds(DefaultQualityThresholds, map[FilterMode]QualityThresholds{FilterModeHousehold: relaxed})
+	if QualityRulesVersion() != version {
+		t.Errorf("Expected the same thresholds to give the same version, got %s and %s", version, QualityRulesVersion())
+	}
+}
+
+func TestNewSensorDataStats_SpreadAndPercentiles(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []SensorReading
+	for i, tds := range []float64{100, 200, 300, 400, 500} {
+		readings = append(readings, SensorReading{
+			Timestamp: base.Add(time.Duration(i) * time.Hour), FilterMode: FilterModeDrinking,
+			Ph: 7.5, Turbidity: 0.5, TDS: tds, Flow: float64(i),
+		})
+	}
+	readings[4].FilterMode = FilterModeHousehold
+
+	stats := NewSensorDataStats(readings)
+	want := map[string]float64{"min": 100, "max": 500, "average": 300, "median": 300, "p95": 480}
+	for key, value := range want {
+		if stats.TDSStats[key] != value {
+			t.Errorf("Expected TDS %s %.1f, got %.1f", key, value, stats.TDSStats[key])
+		}
+	}
+	// Sample standard deviation of 100..500
+	if stddev := stats.TDSStats["stddev"]; stddev < 158.11 || stddev > 158.12 {
+		t.Errorf("Expected a TDS stddev of 158.11, got %.3f", stddev)
+	}
+	if stats.PhStats["stddev"] != 0 || stats.FlowStats["p95"] != 3.8 {
+		t.Errorf("Expected constant pH and interpolated flow p95, got %v and %v", stats.PhStats, stats.FlowStats)
+	}
+	if stats.TotalReadings != 5 || stats.FilterModes["drinking_water"] != 4 || stats.DateRange["latest"] != "2025-01-01 04:00:00" {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+}
+
+func TestSensorPrediction_RecordActualUsesRelativeErrors(t *testing.T) {
+	prediction := SensorPrediction{PredictedFlow: 2, PredictedPh: 7, PredictedTurbidity: 1, PredictedTDS: 220}
+	prediction.RecordActual(SensorReading{Flow: 2, Ph: 7, Turbidity: 1, TDS: 200}, time.Now())
+
+	// A 20 ppm miss on 200 ppm is a 10% error on one of four metrics, not 20 units
+	if prediction.OverallAccuracy == nil || math.Abs(*prediction.OverallAccuracy-97.5) > 1e-9 {
+		t.Errorf("Expected 97.5%% accuracy, got %v", prediction.OverallAccuracy)
+	}
+	if *prediction.TDSError != 20 {
+		t.Errorf("Expected the absolute TDS error kept, got %v", *prediction.TDSError)
+	}
+
+	// A miss on an actual value of 0 counts as fully wrong without dividing by 0
+	prediction.RecordActual(SensorReading{Flow: 0, Ph: 7, Turbidity: 1, TDS: 220}, time.Now())
+	if *prediction.OverallAccuracy != 75 {
+		t.Errorf("Expected 75%% accuracy with the flow fully missed, got %v", *prediction.OverallAccuracy)
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// SensorDataStatsTimeFormat is how the date range of sensor data stats is shown
+const SensorDataStatsTimeFormat = "2006-01-02 15:04:05"
+
+// SensorDataStats represents statistics about sensor data. Each metric's stats
+// hold its min, max, average, stddev (sample standard deviation), median and
+// p95.
+type SensorDataStats struct {
+	TotalReadings    int                `json:"total_readings"`
+	DateRange        map[string]string  `json:"date_range"`
+	PhStats          map[string]float64 `json:"ph_stats"`
+	TDSStats         map[string]float64 `json:"tds_stats"`
+	TurbidityStats   map[string]float64 `json:"turbidity_stats"`
+	FlowStats        map[string]float64 `json:"flow_stats"`
+	FilterModes      map[string]int     `json:"filter_modes"`
+	QualityBreakdown map[string]int     `json:"quality_breakdown"`
+}
+
+// EmptySensorDataStats returns the statistics of no readings: zero readings
+// and empty groups, so clients see the same shape as with data
+func EmptySensorDataStats() SensorDataStats {
+	return SensorDataStats{
+		DateRange:        map[string]string{},
+		PhStats:          map[string]float64{},
+		TDSStats:         map[string]float64{},
+		TurbidityStats:   map[string]float64{},
+		FlowStats:        map[string]float64{},
+		FilterModes:      map[string]int{},
+		QualityBreakdown: map[string]int{},
+	}
+}
+
+// NewSensorDataStats calculates the statistics of the readings in memory;
+// stores that can aggregate where the data lives should do so instead
+func NewSensorDataStats(readings []SensorReading) SensorDataStats {
+	if len(readings) == 0 {
+		return EmptySensorDataStats()
+	}
+
+	stats := SensorDataStats{
+		TotalReadings:    len(readings),
+		DateRange:        make(map[string]string),
+		FilterModes:      make(map[string]int),
+		QualityBreakdown: make(map[string]int),
+	}
+
+	ph := make([]float64, len(readings))
+	tds := make([]float64, len(readings))
+	turbidity := make([]float64, len(readings))
+	flow := make([]float64, len(readings))
+	earliest, latest := readings[0].Timestamp, readings[0].Timestamp
+	for i, reading := range readings {
+		if reading.Timestamp.Before(earliest) {
+			earliest = reading.Timestamp
+		}
+		if reading.Timestamp.After(latest) {
+			latest = reading.Timestamp
+		}
+		ph[i], tds[i], turbidity[i], flow[i] = reading.Ph, reading.TDS, reading.Turbidity, reading.Flow
+
+		stats.FilterModes[string(reading.FilterMode)]++
+		// Quality breakdown (stored at ingestion)
+		stats.QualityBreakdown[reading.CurrentQuality().OverallQuality]++
+	}
+
+	stats.DateRange["earliest"] = earliest.Format(SensorDataStatsTimeFormat)
+	stats.DateRange["latest"] = latest.Format(SensorDataStatsTimeFormat)
+	stats.PhStats = metricStats(ph)
+	stats.TDSStats = metricStats(tds)
+	stats.TurbidityStats = metricStats(turbidity)
+	stats.FlowStats = metricStats(flow)
+	return stats
+}
+
+// metricStats summarizes a metric's values; it sorts them in place
+func metricStats(values []float64) map[string]float64 {
+	sort.Float64s(values)
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	stddev := 0.0
+	if len(values) > 1 {
+		squares := 0.0
+		for _, value := range values {
+			squares += (value - mean) * (value - mean)
+		}
+		stddev = math.Sqrt(squares / float64(len(values)-1))
+	}
+
+	return map[string]float64{
+		"min":     values[0],
+		"max":     values[len(values)-1],
+		"average": mean,
+		"stddev":  stddev,
+		"median":  Percentile(values, 0.5),
+		"p95":     Percentile(values, 0.95),
+	}
+}
+
+// Percentile returns the p-th (0-1) percentile of sorted values, interpolating
+// between the closest ranks like PostgreSQL's percentile_cont
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
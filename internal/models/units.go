@@ -0,0 +1,26 @@
+package models
+
+// FlowUnit is the unit flow rates are presented in. Readings are always stored
+// in liters per minute.
+type FlowUnit string
+
+const (
+	FlowUnitLitersPerMinute  FlowUnit = "L/min"
+	FlowUnitGallonsPerMinute FlowUnit = "GPM" // US gallons per minute
+)
+
+// litersPerUSGallon converts between liters and US gallons
+const litersPerUSGallon = 3.785411784
+
+// IsValid reports whether the unit is a supported flow unit
+func (u FlowUnit) IsValid() bool {
+	return u == FlowUnitLitersPerMinute || u == FlowUnitGallonsPerMinute
+}
+
+// FromLitersPerMinute converts a stored flow rate to the unit
+func (u FlowUnit) FromLitersPerMinute(flow float64) float64 {
+	if u == FlowUnitGallonsPerMinute {
+		return flow / litersPerUSGallon
+	}
+	return flow
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Readings at or beyond these limits score 0 on the corresponding sub-index
+const (
+	wqiMaxPhDeviation = 3.0    // pH units away from neutral (7.0)
+	wqiMaxTurbidity   = 10.0   // NTU
+	wqiMaxTDS         = 1000.0 // PPM
+)
+
+// WQILabelExcellentMin and WQILabelGoodMin are the lowest scores mapped to the
+// "Excellent" and "Good" quality labels; anything lower is "Danger"
+const (
+	WQILabelExcellentMin = 80.0
+	WQILabelGoodMin      = 50.0
+)
+
+// WQIWeights sets how much each metric contributes to the water quality index.
+// Weights are relative; they are normalized by their sum.
+type WQIWeights struct {
+	Ph        float64 `json:"ph"`
+	Turbidity float64 `json:"turbidity"`
+	TDS       float64 `json:"tds"`
+}
+
+// DefaultWQIWeights weights turbidity and TDS above pH, like the filter efficiency
+var DefaultWQIWeights = WQIWeights{Ph: 0.2, Turbidity: 0.4, TDS: 0.4}
+
+// Validate checks that no weight is negative and at least one is positive
+func (w WQIWeights) Validate() error {
+	if w.Ph < 0 || w.Turbidity < 0 || w.TDS < 0 {
+		return fmt.Errorf("WQI weights must not be negative")
+	}
+	if w.Ph+w.Turbidity+w.TDS <= 0 {
+		return fmt.Errorf("at least one WQI weight must be positive")
+	}
+	return nil
+}
+
+var (
+	wqiWeightsMu sync.RWMutex
+	wqiWeights   = DefaultWQIWeights
+)
+
+// SetWQIWeights sets the weights used by WaterQualityIndex and ToWaterQualityStatus
+func SetWQIWeights(weights WQIWeights) error {
+	if err := weights.Validate(); err != nil {
+		return err
+	}
+	wqiWeightsMu.Lock()
+	defer wqiWeightsMu.Unlock()
+	wqiWeights = weights
+	return nil
+}
+
+// CurrentWQIWeights returns the weights used by WaterQualityIndex
+func CurrentWQIWeights() WQIWeights {
+	wqiWeightsMu.RLock()
+	defer wqiWeightsMu.RUnlock()
+	return wqiWeights
+}
+
+// WaterQualityIndex is a 0-100 score combining pH, turbidity and TDS, where
+// 100 is neutral, perfectly clear water without dissolved solids
+type WaterQualityIndex struct {
+	Score          float64 `json:"score"`
+	Label          string  `json:"label"` // Excellent, Good or Danger
+	PhScore        float64 `json:"ph_score"`
+	TurbidityScore float64 `json:"turbidity_score"`
+	TDSScore       float64 `json:"tds_score"`
+}
+
+// WaterQualityIndex computes the reading's index with the configured weights
+func (s *SensorReading) WaterQualityIndex() WaterQualityIndex {
+	return CalculateWQI(s, CurrentWQIWeights())
+}
+
+// CalculateWQI computes a reading's water quality index with the given weights.
+// Each metric is scored linearly from 100 at its ideal value to 0 at its limit.
+func CalculateWQI(reading *SensorReading, weights WQIWeights) WaterQualityIndex {
+	subIndex := func(distance, limit float64) float64 {
+		return math.Max(0, 100*(1-distance/limit))
+	}
+
+	index := WaterQualityIndex{
+		PhScore:        subIndex(math.Abs(reading.Ph-7.0), wqiMaxPhDeviation),
+		TurbidityScore: subIndex(math.Max(reading.Turbidity, 0), wqiMaxTurbidity),
+		TDSScore:       subIndex(math.Max(reading.TDS, 0), wqiMaxTDS),
+	}
+
+	totalWeight := weights.Ph + weights.Turbidity + weights.TDS
+	if totalWeight <= 0 {
+		weights, totalWeight = DefaultWQIWeights, 1.0
+	}
+	score := (index.PhScore*weights.Ph + index.TurbidityScore*weights.Turbidity + index.TDSScore*weights.TDS) / totalWeight
+
+	index.Score = math.Round(score*10) / 10
+	index.Label = WQILabel(index.Score)
+	return index
+}
+
+// WQILabel maps a water quality index score to the quality labels used by
+// the per-metric assessment
+func WQILabel(score float64) string {
+	switch {
+	case score >= WQILabelExcellentMin:
+		return "Excellent"
+	case score >= WQILabelGoodMin:
+		return "Good"
+	default:
+		return "Danger"
+	}
+}
+
+// DeviceWQI is the water quality index of a device's latest reading
+type DeviceWQI struct {
+	DeviceID   string            `json:"device_id"`
+	Timestamp  time.Time         `json:"timestamp"`
+	FilterMode FilterMode        `json:"filter_mode"`
+	WQI        WaterQualityIndex `json:"wqi"`
+}
@@ -1,10 +1,10 @@
 package mqtt
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
@@ -16,15 +16,38 @@ import (
 type Client struct {
 	client             MQTT.Client
 	store              store.DataStore
-	topicSensorData    string
-	topicFilterCommand string
+	topicSensorData    TopicTemplate
+	topicFilterCommand TopicTemplate
+	deduplicator       *store.ReadingDeduplicator
+	clockSkew          *store.ClockSkewCorrector
+	reconnectAttempts  atomic.Int32 // Attempts since the connection was lost
 }
 
-// NewClient creates and connects a new MQTT client
-func NewClient(brokerURL, clientID, username, password string, dataStore store.DataStore, topics map[string]string) (*Client, error) {
+// maxReconnectInterval caps the exponential backoff between reconnection
+// attempts, which starts at a second and doubles after each failure
+const maxReconnectInterval = 60 * time.Second
+
+// NewClient creates and connects a new MQTT client. TLS brokers (ssl://,
+// tls://, mqtts://) are connected to with the certificates in tlsOptions,
+// which must load before connecting is attempted.
+func NewClient(brokerURL, clientID, username, password string, dataStore store.DataStore, topics map[string]string, tlsOptions TLSOptions) (*Client, error) {
+	tlsConfig, err := newTLSConfig(brokerURL, tlsOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	topicSensorData, err := ParseTopicTemplate(topics["sensor_data"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid sensor data topic: %w", err)
+	}
+	topicFilterCommand, err := ParseTopicTemplate(topics["filter_command"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter command topic: %w", err)
+	}
+
 	opts := MQTT.NewClientOptions()
 
-	// Add broker URL - support both tcp:// and tls:// schemes
+	// Add broker URL - support both tcp:// and TLS schemes
 	opts.AddBroker(brokerURL)
 	opts.SetClientID(clientID)
 
@@ -37,30 +60,27 @@ func NewClient(brokerURL, clientID, username, password string, dataStore store.D
 	}
 
 	// Configure TLS for secure connections (HiveMQ Cloud uses TLS on port 8883)
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: false, // Set to true only for testing with self-signed certs
-		MinVersion:         tls.VersionTLS12,
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
 	}
-	opts.SetTLSConfig(tlsConfig)
 
 	// Create the client wrapper instance so we can use its methods in the handlers
 	mqttClient := &Client{
 		store:              dataStore,
-		topicSensorData:    topics["sensor_data"],
-		topicFilterCommand: topics["filter_command"],
+		topicSensorData:    topicSensorData,
+		topicFilterCommand: topicFilterCommand,
 	}
 
 	// Set callbacks
 	opts.SetDefaultPublishHandler(messageHandler)
 	// SetOnConnectHandler is the key change: it ensures re-subscription on reconnect
-	opts.SetOnConnectHandler(func(client MQTT.Client) {
-		log.Println("✅ MQTT onConnect event: Subscribing to topics...")
-		mqttClient.SubscribeToSensorData()
-	})
+	opts.SetOnConnectHandler(mqttClient.onConnect)
 	opts.SetConnectionLostHandler(onConnectionLost)
+	opts.SetReconnectingHandler(mqttClient.onReconnecting)
 
-	// Connection settings
+	// Connection settings; paho reconnects with exponential backoff
 	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(maxReconnectInterval)
 	opts.SetConnectRetry(true)
 	opts.SetKeepAlive(30 * time.Second)
 	opts.SetPingTimeout(10 * time.Second)
@@ -84,17 +104,41 @@ func NewClient(brokerURL, clientID, username, password string, dataStore store.D
 	return mqttClient, nil
 }
 
-// SubscribeToSensorData subscribes to sensor data topic
+// onConnect re-subscribes to the sensor data topic on every (re)connection,
+// since the broker forgets subscriptions of a clean session
+func (c *Client) onConnect(client MQTT.Client) {
+	if attempts := c.reconnectAttempts.Swap(0); attempts > 0 {
+		log.Printf("✅ MQTT reconnected after %d attempts", attempts)
+	}
+	log.Println("✅ MQTT onConnect event: Subscribing to topics...")
+	c.SubscribeToSensorData()
+}
+
+// onReconnecting logs each reconnection attempt
+func (c *Client) onReconnecting(client MQTT.Client, opts *MQTT.ClientOptions) {
+	attempt := c.reconnectAttempts.Add(1)
+	log.Printf("🔄 MQTT reconnect attempt %d to %v", attempt, opts.Servers)
+}
+
+// Connected reports whether the client currently has a connection to the
+// broker; false while it is reconnecting
+func (c *Client) Connected() bool {
+	return c != nil && c.client != nil && c.client.IsConnectionOpen()
+}
+
+// SubscribeToSensorData subscribes to sensor data topic (with a wildcard
+// device level when the topic is templated per device)
 func (c *Client) SubscribeToSensorData() {
-	token := c.client.Subscribe(c.topicSensorData, 1, c.handleSensorData)
+	topic := c.topicSensorData.SubscriptionTopic()
+	token := c.client.Subscribe(topic, 1, c.handleSensorData)
 	token.Wait()
 
 	if token.Error() != nil {
-		log.Printf("❌ Failed to subscribe to %s: %v", c.topicSensorData, token.Error())
+		log.Printf("❌ Failed to subscribe to %s: %v", topic, token.Error())
 		return
 	}
 
-	log.Printf("📡 Subscribed to topic: %s", c.topicSensorData)
+	log.Printf("📡 Subscribed to topic: %s", topic)
 }
 
 // handleSensorData handles incoming sensor data from MQTT
@@ -115,6 +159,7 @@ func (c *Client) handleSensorData(client MQTT.Client, msg MQTT.Message) {
 	// Parse JSON payload
 	var payload struct {
 		DeviceID         string  `json:"device_id"`
+		Timestamp        string  `json:"timestamp"`
 		Flow             float64 `json:"flow"`
 		PhVoltage        float64 `json:"ph_v"`
 		TurbidityVoltage float64 `json:"turbidity_v"`
@@ -125,6 +170,7 @@ func (c *Client) handleSensorData(client MQTT.Client, msg MQTT.Message) {
 	var filterMode models.FilterMode
 	var deviceID string
 	var flow float64
+	var deviceTimestamp string
 	isDummyData := false
 
 	// Try parsing as dummy data format (with actual values, not voltages)
@@ -135,6 +181,7 @@ func (c *Client) handleSensorData(client MQTT.Client, msg MQTT.Message) {
 		tds = dummyPayload.TDS
 		flow = dummyPayload.Flow
 		deviceID = dummyPayload.DeviceID
+		deviceTimestamp = dummyPayload.Timestamp
 		filterMode = models.FilterMode(dummyPayload.FilterMode)
 		isDummyData = true
 		log.Printf("🤖 Detected DUMMY data from %s", deviceID)
@@ -152,20 +199,49 @@ func (c *Client) handleSensorData(client MQTT.Client, msg MQTT.Message) {
 		tds = convertTDSVoltage(payload.TDSVoltage)
 		flow = payload.Flow
 		deviceID = payload.DeviceID
+		deviceTimestamp = payload.Timestamp
 		filterMode = c.store.GetCurrentFilterMode()
 		log.Printf("📡 Detected REAL sensor data from %s", deviceID)
 	}
 
-	// Create sensor reading
+	// Per-device topics are authoritative for which device sent the reading
+	if topicDeviceID, ok := c.topicSensorData.DeviceID(msg.Topic()); ok {
+		if deviceID != "" && deviceID != topicDeviceID {
+			log.Printf("⚠️  Warning: Payload device_id %q does not match topic device %q, using topic device", deviceID, topicDeviceID)
+		}
+		deviceID = topicDeviceID
+	}
+
+	// Create sensor reading; the device timestamp is kept only with clock-skew handling on
 	sensorData := models.SensorReading{
 		DeviceID:   deviceID,
-		Timestamp:  time.Now(),
 		FilterMode: filterMode,
 		Flow:       flow,
 		Ph:         ph,
 		Turbidity:  turbidity,
 		TDS:        tds,
 	}
+	if deviceTimestamp != "" {
+		if ts, err := time.Parse(time.RFC3339, deviceTimestamp); err == nil {
+			sensorData.Timestamp = ts
+		} else {
+			log.Printf("⚠️  Warning: Ignoring invalid timestamp %q from %s, using server time", deviceTimestamp, deviceID)
+		}
+	}
+
+	// Stamp the server receive time and handle device clocks that are too far off
+	if skew, err := c.clockSkew.Apply(&sensorData, time.Now()); err != nil {
+		log.Printf("⏱️  Rejected sensor data via MQTT: %v", err)
+		return
+	} else if skew != 0 {
+		log.Printf("⏱️  Device %s clock is %s off server time", deviceID, skew.Round(time.Second))
+	}
+
+	// Skip readings that repeat the device's last reading (when de-duplication is enabled)
+	if c.deduplicator.IsDuplicate(sensorData) {
+		log.Printf("♻️  Skipped duplicate sensor data from %s via MQTT", deviceID)
+		return
+	}
 
 	// Store in database
 	c.store.AddSensorReading(sensorData)
@@ -178,8 +254,86 @@ func (c *Client) handleSensorData(client MQTT.Client, msg MQTT.Message) {
 		logPrefix, deviceID, flow, ph, turbidity, tds)
 }
 
-// PublishFilterCommand publishes filter mode change command to ESP32
+// SetDeduplicator configures de-duplication of incoming sensor readings
+func (c *Client) SetDeduplicator(deduplicator *store.ReadingDeduplicator) {
+	c.deduplicator = deduplicator
+}
+
+// SetClockSkewCorrector configures handling of readings with skewed device clocks
+func (c *Client) SetClockSkewCorrector(corrector *store.ClockSkewCorrector) {
+	c.clockSkew = corrector
+}
+
+// PublishFilterCommand publishes filter mode change command to ESP32. With a
+// per-device command topic the command is sent to every active device.
+// Every attempt is recorded in the device command log and marked delivered
+// once the broker acknowledges the publish.
 func (c *Client) PublishFilterCommand(filterMode models.FilterMode) error {
+	if !c.topicFilterCommand.HasDeviceID() {
+		// One shared topic: every active device receives the command
+		var commands []*models.DeviceCommand
+		for _, deviceID := range c.store.GetActiveDevices() {
+			commands = append(commands, c.recordFilterCommand(deviceID, filterMode))
+		}
+		err := c.publishFilterCommand(c.topicFilterCommand.String(), filterMode)
+		for _, command := range commands {
+			c.completeFilterCommand(command, err)
+		}
+		return err
+	}
+
+	devices := c.store.GetActiveDevices()
+	if len(devices) == 0 {
+		return fmt.Errorf("no active devices to send filter command to")
+	}
+	for _, deviceID := range devices {
+		command := c.recordFilterCommand(deviceID, filterMode)
+		err := c.publishFilterCommand(c.topicFilterCommand.Resolve(deviceID), filterMode)
+		c.completeFilterCommand(command, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordFilterCommand logs a filter command about to be published to a
+// device. It returns nil when the command could not be logged.
+func (c *Client) recordFilterCommand(deviceID string, filterMode models.FilterMode) *models.DeviceCommand {
+	command := &models.DeviceCommand{
+		DeviceID: deviceID,
+		Command:  models.DeviceCommandSetFilterMode,
+		Payload:  string(filterMode),
+		Status:   models.DeviceCommandSent,
+	}
+
+	if err := c.store.RecordDeviceCommand(command); err != nil {
+		log.Printf("⚠️  Warning: Failed to record filter command for %s: %v", deviceID, err)
+		return nil
+	}
+	return command
+}
+
+// completeFilterCommand updates a logged filter command with the publishing
+// result: delivered once the broker acknowledged it, failed otherwise
+func (c *Client) completeFilterCommand(command *models.DeviceCommand, publishErr error) {
+	if command == nil {
+		return
+	}
+
+	command.Status = models.DeviceCommandDelivered
+	if publishErr != nil {
+		command.Status = models.DeviceCommandFailed
+		command.Error = publishErr.Error()
+	}
+
+	if err := c.store.UpdateDeviceCommandStatus(command.ID, command.Status, command.Error); err != nil {
+		log.Printf("⚠️  Warning: Failed to update filter command for %s: %v", command.DeviceID, err)
+	}
+}
+
+// publishFilterCommand publishes a filter mode change command on a concrete topic
+func (c *Client) publishFilterCommand(topic string, filterMode models.FilterMode) error {
 	payload := map[string]interface{}{
 		"filter_mode": string(filterMode),
 		"timestamp":   time.Now().Format(time.RFC3339),
@@ -190,14 +344,14 @@ func (c *Client) PublishFilterCommand(filterMode models.FilterMode) error {
 		return fmt.Errorf("failed to marshal filter command: %w", err)
 	}
 
-	token := c.client.Publish(c.topicFilterCommand, 1, false, data)
+	token := c.client.Publish(topic, 1, false, data)
 	token.Wait()
 
 	if token.Error() != nil {
 		return fmt.Errorf("failed to publish filter command: %w", token.Error())
 	}
 
-	log.Printf("📤 Published filter command via MQTT: %s", filterMode)
+	log.Printf("📤 Published filter command via MQTT to %s: %s", topic, filterMode)
 	return nil
 }
 
@@ -254,5 +408,5 @@ func messageHandler(client MQTT.Client, msg MQTT.Message) {
 
 func onConnectionLost(client MQTT.Client, err error) {
 	log.Printf("⚠️  MQTT connection lost: %v", err)
-	log.Println("🔄 Auto-reconnecting...")
+	log.Printf("🔄 Auto-reconnecting with backoff (at most %s between attempts)...", maxReconnectInterval)
 }
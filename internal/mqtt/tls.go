@@ -0,0 +1,71 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// tlsSchemes are the broker URL schemes paho connects to over TLS
+var tlsSchemes = map[string]bool{
+	"ssl":   true,
+	"tls":   true,
+	"mqtts": true,
+	"tcps":  true,
+}
+
+// TLSOptions configures the TLS connection to an mqtts:// (ssl://, tls://)
+// broker. Without a CA certificate the system roots verify the broker.
+type TLSOptions struct {
+	CACertFile         string // PEM CA certificate the broker's certificate must chain to
+	ClientCertFile     string // PEM client certificate for mutual TLS (with ClientKeyFile)
+	ClientKeyFile      string
+	InsecureSkipVerify bool // Accept any broker certificate; only for testing
+}
+
+// IsTLSBroker reports whether the broker URL's scheme connects over TLS
+func IsTLSBroker(brokerURL string) bool {
+	parsed, err := url.Parse(brokerURL)
+	return err == nil && tlsSchemes[strings.ToLower(parsed.Scheme)]
+}
+
+// newTLSConfig loads the certificate files into a TLS config for the broker,
+// or returns nil for a plaintext broker
+func newTLSConfig(brokerURL string, options TLSOptions) (*tls.Config, error) {
+	if !IsTLSBroker(brokerURL) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if options.CACertFile != "" {
+		caPEM, err := os.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MQTT CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("MQTT CA certificate %s contains no PEM certificates", options.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (options.ClientCertFile == "") != (options.ClientKeyFile == "") {
+		return nil, fmt.Errorf("MQTT client certificate and key must be configured together")
+	}
+	if options.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.ClientCertFile, options.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
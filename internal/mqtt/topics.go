@@ -0,0 +1,90 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceIDPlaceholder marks the topic level holding a device ID in a topic template
+const DeviceIDPlaceholder = "{device_id}"
+
+// TopicTemplate is an MQTT topic that may contain a {device_id} level, e.g.
+// "aquasmart/{device_id}/sensor". Templated topics are subscribed to with a
+// single-level wildcard and the device ID is recovered from each message topic.
+type TopicTemplate struct {
+	levels      []string
+	deviceLevel int // Index of the {device_id} level, or -1 for a fixed topic
+}
+
+// ParseTopicTemplate validates a topic template. The placeholder may appear at
+// most once and must make up a whole topic level; MQTT wildcards are not allowed.
+func ParseTopicTemplate(template string) (TopicTemplate, error) {
+	if template == "" {
+		return TopicTemplate{}, fmt.Errorf("topic template is empty")
+	}
+	if strings.ContainsAny(template, "+#") {
+		return TopicTemplate{}, fmt.Errorf("topic template %q must not contain MQTT wildcards", template)
+	}
+
+	tt := TopicTemplate{levels: strings.Split(template, "/"), deviceLevel: -1}
+	for i, level := range tt.levels {
+		if !strings.Contains(level, DeviceIDPlaceholder) {
+			continue
+		}
+		if level != DeviceIDPlaceholder {
+			return TopicTemplate{}, fmt.Errorf("topic template %q: %s must be a whole topic level", template, DeviceIDPlaceholder)
+		}
+		if tt.deviceLevel >= 0 {
+			return TopicTemplate{}, fmt.Errorf("topic template %q: %s may appear only once", template, DeviceIDPlaceholder)
+		}
+		tt.deviceLevel = i
+	}
+	return tt, nil
+}
+
+// String returns the template as configured
+func (tt TopicTemplate) String() string {
+	return strings.Join(tt.levels, "/")
+}
+
+// HasDeviceID reports whether the template contains a {device_id} level
+func (tt TopicTemplate) HasDeviceID() bool {
+	return tt.deviceLevel >= 0
+}
+
+// SubscriptionTopic returns the topic filter to subscribe with, replacing the
+// {device_id} level with the "+" wildcard
+func (tt TopicTemplate) SubscriptionTopic() string {
+	return tt.Resolve("+")
+}
+
+// Resolve returns the concrete topic for a device
+func (tt TopicTemplate) Resolve(deviceID string) string {
+	if !tt.HasDeviceID() {
+		return tt.String()
+	}
+	levels := make([]string, len(tt.levels))
+	copy(levels, tt.levels)
+	levels[tt.deviceLevel] = deviceID
+	return strings.Join(levels, "/")
+}
+
+// DeviceID extracts the device ID from a concrete topic matching the template
+func (tt TopicTemplate) DeviceID(topic string) (string, bool) {
+	if !tt.HasDeviceID() {
+		return "", false
+	}
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(tt.levels) {
+		return "", false
+	}
+	for i, level := range tt.levels {
+		if i != tt.deviceLevel && levels[i] != level {
+			return "", false
+		}
+	}
+	if levels[tt.deviceLevel] == "" {
+		return "", false
+	}
+	return levels[tt.deviceLevel], true
+}
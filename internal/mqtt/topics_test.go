@@ -0,0 +1,290 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeMessage is a minimal MQTT.Message for feeding the message handlers
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 1 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 1 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              {}
+
+func TestParseTopicTemplate_Validation(t *testing.T) {
+	valid := []string{"aquasmart/sensors/data", "aquasmart/{device_id}/sensor", "{device_id}/data"}
+	for _, template := range valid {
+		if _, err := ParseTopicTemplate(template); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", template, err)
+		}
+	}
+
+	invalid := []string{"", "aquasmart/+/sensor", "aquasmart/#", "aquasmart/dev-{device_id}/sensor", "{device_id}/{device_id}"}
+	for _, template := range invalid {
+		if _, err := ParseTopicTemplate(template); err == nil {
+			t.Errorf("Expected %q to be rejected", template)
+		}
+	}
+}
+
+func TestTopicTemplate_ResolveAndExtract(t *testing.T) {
+	tt, err := ParseTopicTemplate("aquasmart/{device_id}/sensor")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := tt.SubscriptionTopic(); got != "aquasmart/+/sensor" {
+		t.Errorf("Expected wildcard subscription, got %s", got)
+	}
+	if got := tt.Resolve("stm32_post"); got != "aquasmart/stm32_post/sensor" {
+		t.Errorf("Unexpected resolved topic %s", got)
+	}
+	if deviceID, ok := tt.DeviceID("aquasmart/stm32_pre/sensor"); !ok || deviceID != "stm32_pre" {
+		t.Errorf("Expected stm32_pre from topic, got %q (%v)", deviceID, ok)
+	}
+	for _, topic := range []string{"aquasmart/stm32_pre/command", "aquasmart/stm32_pre", "other/stm32_pre/sensor"} {
+		if _, ok := tt.DeviceID(topic); ok {
+			t.Errorf("Expected no device match for %s", topic)
+		}
+	}
+
+	fixed, _ := ParseTopicTemplate("aquasmart/sensors/data")
+	if fixed.HasDeviceID() || fixed.SubscriptionTopic() != "aquasmart/sensors/data" {
+		t.Errorf("Expected fixed topic to be used as-is, got %s", fixed.SubscriptionTopic())
+	}
+}
+
+func TestHandleSensorData_AttributesReadingToTopicDevice(t *testing.T) {
+	dataStore := store.NewStore(10)
+	topic, _ := ParseTopicTemplate("aquasmart/{device_id}/sensor")
+	c := &Client{store: dataStore, topicSensorData: topic}
+
+	// Payload without a device_id: the device comes from the topic
+	c.handleSensorData(nil, &fakeMessage{
+		topic:   "aquasmart/stm32_post/sensor",
+		payload: []byte(`{"filter_mode":"drinking_water","flow":1.5,"ph":7.1,"turbidity":0.8,"tds":120}`),
+	})
+	// Payload naming another device: the topic still wins
+	c.handleSensorData(nil, &fakeMessage{
+		topic:   "aquasmart/stm32_pre/sensor",
+		payload: []byte(`{"device_id":"stm32_post","filter_mode":"drinking_water","flow":1.5,"ph":6.8,"turbidity":4,"tds":300}`),
+	})
+
+	post, ok := dataStore.GetLatestReadingByDevice("stm32_post")
+	if !ok || post.Ph != 7.1 {
+		t.Errorf("Expected stm32_post reading with pH 7.1, got %+v", post)
+	}
+	pre, ok := dataStore.GetLatestReadingByDevice("stm32_pre")
+	if !ok || pre.Ph != 6.8 {
+		t.Errorf("Expected stm32_pre reading with pH 6.8, got %+v", pre)
+	}
+}
+
+func TestHandleSensorData_SkipsDuplicateReadings(t *testing.T) {
+	dataStore := store.NewStore(10)
+	topic, _ := ParseTopicTemplate("aquasmart/{device_id}/sensor")
+	c := &Client{store: dataStore, topicSensorData: topic}
+	c.SetDeduplicator(store.NewReadingDeduplicator(dataStore, true, time.Minute))
+
+	message := &fakeMessage{
+		topic:   "aquasmart/stm32_post/sensor",
+		payload: []byte(`{"filter_mode":"drinking_water","flow":1.5,"ph":7.1,"turbidity":0.8,"tds":120}`),
+	}
+	c.handleSensorData(nil, message)
+	c.handleSensorData(nil, message)
+
+	if readings := dataStore.GetRecentReadingsByDevice("stm32_post", 10); len(readings) != 1 {
+		t.Errorf("Expected the repeated reading to be skipped, got %d readings", len(readings))
+	}
+}
+
+// fakeToken is an MQTT.Token that has already completed, with err as its result
+type fakeToken struct{ err error }
+
+func (fakeToken) Wait() bool                     { return true }
+func (fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (fakeToken) Done() <-chan struct{}          { done := make(chan struct{}); close(done); return done }
+func (t fakeToken) Error() error                 { return t.err }
+
+// fakeBrokerClient is an MQTT.Client that records subscriptions and publishes
+// and reports a settable connection state; other methods are not implemented
+type fakeBrokerClient struct {
+	MQTT.Client
+	open       bool
+	subscribed []string
+	published  []string
+	publishErr error
+}
+
+func (f *fakeBrokerClient) IsConnectionOpen() bool { return f.open }
+
+func (f *fakeBrokerClient) Subscribe(topic string, qos byte, callback MQTT.MessageHandler) MQTT.Token {
+	f.subscribed = append(f.subscribed, topic)
+	return fakeToken{}
+}
+
+func (f *fakeBrokerClient) Publish(topic string, qos byte, retained bool, payload interface{}) MQTT.Token {
+	f.published = append(f.published, topic)
+	return fakeToken{err: f.publishErr}
+}
+
+func TestClient_ReconnectResubscribesAndReportsConnection(t *testing.T) {
+	topic, _ := ParseTopicTemplate("aquasmart/{device_id}/data")
+	broker := &fakeBrokerClient{open: true}
+	c := &Client{client: broker, store: store.NewStore(10), topicSensorData: topic}
+
+	var disabled *Client
+	if disabled.Connected() {
+		t.Error("Expected a nil client to report disconnected")
+	}
+	if !c.Connected() {
+		t.Error("Expected the client to report connected")
+	}
+
+	// The broker goes away and paho retries twice before getting back in
+	broker.open = false
+	if c.Connected() {
+		t.Error("Expected the client to report disconnected while reconnecting")
+	}
+	options := MQTT.NewClientOptions().AddBroker("tcp://broker:1883")
+	c.onReconnecting(broker, options)
+	c.onReconnecting(broker, options)
+	if attempts := c.reconnectAttempts.Load(); attempts != 2 {
+		t.Errorf("Expected 2 reconnect attempts, got %d", attempts)
+	}
+
+	broker.open = true
+	c.onConnect(broker)
+	if len(broker.subscribed) != 1 || broker.subscribed[0] != "aquasmart/+/data" {
+		t.Errorf("Expected a re-subscription to the sensor topic, got %v", broker.subscribed)
+	}
+	if attempts := c.reconnectAttempts.Load(); attempts != 0 {
+		t.Errorf("Expected the attempt count to reset on connect, got %d", attempts)
+	}
+	if !c.Connected() {
+		t.Error("Expected the client to report connected after reconnecting")
+	}
+}
+
+func TestPublishFilterCommand_MarksAcknowledgedCommandsDelivered(t *testing.T) {
+	dataStore := store.NewStore(10)
+	dataStore.AddSensorReading(models.SensorReading{DeviceID: "stm32_main", FilterMode: models.FilterModeDrinking, Timestamp: time.Now()})
+	deviceID := dataStore.GetActiveDevices()[0]
+	topic, _ := ParseTopicTemplate("aquasmart/{device_id}/filter/command")
+	broker := &fakeBrokerClient{open: true}
+	c := &Client{client: broker, store: dataStore, topicFilterCommand: topic}
+
+	if err := c.PublishFilterCommand(models.FilterModeHousehold); err != nil {
+		t.Fatalf("Expected the command to be published, got %v", err)
+	}
+	commands, _ := dataStore.GetDeviceCommands(deviceID, 10)
+	if len(commands) != 1 || commands[0].Status != models.DeviceCommandDelivered {
+		t.Fatalf("Expected one delivered command, got %+v", commands)
+	}
+
+	broker.publishErr = errors.New("connection lost")
+	if err := c.PublishFilterCommand(models.FilterModeDrinking); err == nil {
+		t.Fatal("Expected the publish error to be returned")
+	}
+	commands, _ = dataStore.GetDeviceCommands(deviceID, 10)
+	if len(commands) != 2 || commands[0].Status != models.DeviceCommandFailed || commands[0].Error == "" {
+		t.Errorf("Expected the unacknowledged command to be marked failed, got %+v", commands)
+	}
+}
+
+// writeTestCertificate writes a self-signed PEM certificate and its key to dir
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "aquasmart-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig_LoadsCertificatesForTLSBrokers(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	// Plaintext brokers get no TLS config, whatever is configured
+	if tlsConfig, err := newTLSConfig("tcp://broker:1883", TLSOptions{CACertFile: "missing.pem"}); tlsConfig != nil || err != nil {
+		t.Fatalf("Expected no TLS for tcp://, got %v, %v", tlsConfig, err)
+	}
+
+	options := TLSOptions{CACertFile: certFile, ClientCertFile: certFile, ClientKeyFile: keyFile}
+	for _, broker := range []string{"mqtts://broker:8883", "ssl://broker:8883", "tls://broker:8883"} {
+		tlsConfig, err := newTLSConfig(broker, options)
+		if err != nil {
+			t.Fatalf("Expected %s to load the certificates, got %v", broker, err)
+		}
+		if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.InsecureSkipVerify {
+			t.Errorf("Expected the CA and client certificate for %s, got %+v", broker, tlsConfig)
+		}
+	}
+
+	// Without a CA the system roots verify the broker
+	if tlsConfig, err := newTLSConfig("mqtts://broker:8883", TLSOptions{}); err != nil || tlsConfig.RootCAs != nil {
+		t.Errorf("Expected the system roots without a CA, got %v, %v", tlsConfig, err)
+	}
+
+	notPEM := filepath.Join(dir, "not.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+	invalid := map[string]TLSOptions{
+		"missing CA":       {CACertFile: filepath.Join(dir, "missing.pem")},
+		"CA without PEM":   {CACertFile: notPEM},
+		"cert without key": {ClientCertFile: certFile},
+		"key without PEM":  {ClientCertFile: certFile, ClientKeyFile: notPEM},
+	}
+	for name, options := range invalid {
+		if _, err := newTLSConfig("mqtts://broker:8883", options); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
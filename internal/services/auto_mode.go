@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/mqtt"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// autoModeMaxReadingAge is how old the newest post-filtration reading may be
+// before the auto-mode policy stops trusting the quality trend
+const autoModeMaxReadingAge = 10 * time.Minute
+
+// AutoModePolicy switches drinking water mode to household water mode when the
+// post-filtration sensor reports sustained dangerous water quality. Only this
+// direction is automated: switching back to drinking water is left to a person.
+type AutoModePolicy struct {
+	store             store.DataStore
+	mqttClient        *mqtt.Client
+	sustainedReadings int           // Consecutive dangerous readings required before switching
+	manualCooldown    time.Duration // How long manual and scheduled changes are left alone
+	modeCooldown      *store.ModeChangeCooldown
+
+	mu               sync.Mutex
+	lastManualChange time.Time
+}
+
+// NewAutoModePolicy creates an auto-mode policy
+func NewAutoModePolicy(dataStore store.DataStore, mqttClient *mqtt.Client, sustainedReadings int, manualCooldown time.Duration) *AutoModePolicy {
+	if sustainedReadings < 1 {
+		sustainedReadings = 1
+	}
+
+	return &AutoModePolicy{
+		store:             dataStore,
+		mqttClient:        mqttClient,
+		sustainedReadings: sustainedReadings,
+		manualCooldown:    manualCooldown,
+	}
+}
+
+// SetModeChangeCooldown makes automatic switches respect the minimum interval
+// between filter mode changes
+func (p *AutoModePolicy) SetModeChangeCooldown(cooldown *store.ModeChangeCooldown) {
+	p.modeCooldown = cooldown
+}
+
+// NoteManualChange starts the cooldown during which the policy will not
+// override a mode chosen by a person or a schedule
+func (p *AutoModePolicy) NoteManualChange() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastManualChange = time.Now()
+}
+
+// inCooldown reports whether a manual change happened within the cooldown
+func (p *AutoModePolicy) inCooldown() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.lastManualChange.IsZero() && time.Since(p.lastManualChange) < p.manualCooldown
+}
+
+// Evaluate checks the latest post-filtration readings and switches to household
+// water mode when all of the last sustainedReadings drinking water readings are
+// dangerous. It returns whether the mode was changed.
+func (p *AutoModePolicy) Evaluate() (bool, error) {
+	if p.store.GetCurrentFilterMode() != models.FilterModeDrinking {
+		return false, nil
+	}
+	if p.inCooldown() {
+		return false, nil
+	}
+
+	_, postDeviceID := store.ResolveFilterDevices(p.store)
+	readings := p.store.GetRecentReadingsByDevice(postDeviceID, p.sustainedReadings)
+	if len(readings) < p.sustainedReadings {
+		return false, nil
+	}
+	if time.Since(readings[0].Timestamp) > autoModeMaxReadingAge {
+		return false, nil
+	}
+	for _, reading := range readings {
+		if reading.FilterMode != models.FilterModeDrinking {
+			return false, nil
+		}
+		if reading.ToWaterQualityStatus().OverallQuality != "Danger" {
+			return false, nil
+		}
+	}
+
+	if canChange, reason := p.store.CanChangeFilterMode(); !canChange {
+		log.Printf("⚠️  Auto-mode: Sustained poor quality but mode change blocked: %s", reason)
+		return false, nil
+	}
+
+	if remaining, ok := p.modeCooldown.Reserve(p.store.GetActiveDevices(), time.Now()); !ok {
+		log.Printf("⚠️  Auto-mode: Sustained poor quality but mode changed recently, retrying in %s", remaining.Round(time.Second))
+		return false, nil
+	}
+
+	p.store.SetCurrentFilterMode(models.FilterModeHousehold)
+
+	if p.mqttClient != nil {
+		if err := p.mqttClient.PublishFilterCommand(models.FilterModeHousehold); err != nil {
+			log.Printf("❌ Auto-mode: Failed to publish MQTT filter command: %v", err)
+		}
+	}
+
+	change := &models.FilterModeChange{
+		FromMode: models.FilterModeDrinking,
+		ToMode:   models.FilterModeHousehold,
+		Reason:   models.FilterModeChangeAutoQuality,
+		Details: fmt.Sprintf("%d consecutive dangerous readings from %s: %s",
+			len(readings), postDeviceID, strings.Join(readings[0].DrinkingSafetyIssues(), "; ")),
+	}
+	if err := p.store.RecordFilterModeChange(change); err != nil {
+		return true, err
+	}
+
+	log.Printf("🔁 Auto-mode: Switched to %s - %s", models.FilterModeHousehold, change.Details)
+	return true, nil
+}
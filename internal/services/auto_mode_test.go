@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+func addPostReading(s *store.Store, at time.Time, ph float64) {
+	s.AddSensorReading(models.SensorReading{
+		DeviceID:   "stm32_post",
+		Timestamp:  at,
+		FilterMode: models.FilterModeDrinking,
+		Ph:         ph,
+		TDS:        150,
+		Turbidity:  0.5,
+	})
+}
+
+func TestAutoModePolicy_SustainedPoorQualitySwitches(t *testing.T) {
+	s := store.NewStore(100)
+	policy := NewAutoModePolicy(s, nil, 3, 30*time.Minute)
+
+	now := time.Now()
+	for i := 3; i > 0; i-- {
+		addPostReading(s, now.Add(-time.Duration(i)*time.Minute), 4.5)
+	}
+
+	switched, err := policy.Evaluate()
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if !switched {
+		t.Fatal("Expected sustained poor quality to switch modes")
+	}
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeHousehold {
+		t.Errorf("Expected household mode, got %s", mode)
+	}
+
+	changes, _ := s.GetFilterModeChanges(10)
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(changes))
+	}
+	if changes[0].Reason != models.FilterModeChangeAutoQuality {
+		t.Errorf("Expected reason %q, got %q", models.FilterModeChangeAutoQuality, changes[0].Reason)
+	}
+	if changes[0].FromMode != models.FilterModeDrinking || changes[0].ToMode != models.FilterModeHousehold {
+		t.Errorf("Unexpected change %s -> %s", changes[0].FromMode, changes[0].ToMode)
+	}
+}
+
+func TestAutoModePolicy_SingleBadReadingDoesNotSwitch(t *testing.T) {
+	s := store.NewStore(100)
+	policy := NewAutoModePolicy(s, nil, 3, 30*time.Minute)
+
+	now := time.Now()
+	addPostReading(s, now.Add(-3*time.Minute), 7.2)
+	addPostReading(s, now.Add(-2*time.Minute), 7.2)
+	addPostReading(s, now.Add(-1*time.Minute), 4.5)
+
+	switched, err := policy.Evaluate()
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if switched {
+		t.Error("Expected a single bad reading not to switch modes")
+	}
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeDrinking {
+		t.Errorf("Expected drinking mode to be kept, got %s", mode)
+	}
+	if changes, _ := s.GetFilterModeChanges(10); len(changes) != 0 {
+		t.Errorf("Expected no audit entries, got %d", len(changes))
+	}
+}
+
+func TestAutoModePolicy_RespectsManualCooldown(t *testing.T) {
+	s := store.NewStore(100)
+	policy := NewAutoModePolicy(s, nil, 3, 30*time.Minute)
+	policy.NoteManualChange()
+
+	now := time.Now()
+	for i := 3; i > 0; i-- {
+		addPostReading(s, now.Add(-time.Duration(i)*time.Minute), 4.5)
+	}
+
+	if switched, _ := policy.Evaluate(); switched {
+		t.Error("Expected no switch during the manual override cooldown")
+	}
+}
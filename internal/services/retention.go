@@ -0,0 +1,130 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// DefaultRetentionInterval is how often the retention job runs unless
+// configured otherwise
+const DefaultRetentionInterval = 6 * time.Hour
+
+// Retention periodically deletes sensor readings, anomalies and sensor
+// predictions older than the retention period so the database doesn't grow
+// without bound
+type Retention struct {
+	store     store.DataStore
+	days      int           // Days of data kept (0 = keep everything)
+	interval  time.Duration // Time between runs
+	batchSize int           // Rows deleted per statement (<= 0 = store default)
+
+	mu        sync.Mutex
+	ticker    *time.Ticker
+	stopChan  chan bool
+	isRunning bool
+}
+
+// NewRetention creates a retention job keeping the given number of days of
+// data; 0 disables it. A non-positive interval uses the default.
+func NewRetention(dataStore store.DataStore, days int, interval time.Duration, batchSize int) *Retention {
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	return &Retention{
+		store:     dataStore,
+		days:      days,
+		interval:  interval,
+		batchSize: batchSize,
+		stopChan:  make(chan bool),
+	}
+}
+
+// IsEnabled reports whether old data is pruned at all
+func (r *Retention) IsEnabled() bool {
+	return r != nil && r.days > 0
+}
+
+// Days returns how many days of data are kept
+func (r *Retention) Days() int {
+	return r.days
+}
+
+// Prune deletes the data older than the retention period as of now and logs
+// what was deleted. It returns nil when retention is disabled.
+func (r *Retention) Prune(now time.Time) (*models.PruneResult, error) {
+	if !r.IsEnabled() {
+		return nil, nil
+	}
+
+	cutoff := now.AddDate(0, 0, -r.days)
+	result, err := r.store.PruneDataBefore(cutoff, r.batchSize)
+	if err != nil {
+		log.Printf("❌ Retention: Failed to prune data older than %s: %v", cutoff.Format(time.RFC3339), err)
+		return result, err
+	}
+	if result.Total() > 0 {
+		log.Printf("🧹 Retention: Deleted %d readings, %d anomalies and %d sensor predictions older than %s",
+			result.SensorReadings, result.Anomalies, result.SensorPredictions, cutoff.Format(time.RFC3339))
+	}
+	return result, nil
+}
+
+// Start runs Prune now and then on every interval until Stop. It does nothing
+// when retention is disabled.
+func (r *Retention) Start() {
+	if !r.IsEnabled() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isRunning {
+		log.Println("⚠️  Retention: Already running")
+		return
+	}
+	r.ticker = time.NewTicker(r.interval)
+	r.isRunning = true
+
+	log.Printf("🧹 Retention: Started - keeping %d days of data, pruning every %s", r.days, r.interval)
+
+	go r.run()
+}
+
+// Stop halts the retention job
+func (r *Retention) Stop() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isRunning {
+		return
+	}
+
+	r.ticker.Stop()
+	r.stopChan <- true
+	r.isRunning = false
+
+	log.Println("🛑 Retention: Stopped")
+}
+
+// run is the retention loop
+func (r *Retention) run() {
+	r.Prune(time.Now())
+
+	for {
+		select {
+		case <-r.ticker.C:
+			r.Prune(time.Now())
+		case <-r.stopChan:
+			return
+		}
+	}
+}
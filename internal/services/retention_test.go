@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// seedRetentionData stores a reading, an anomaly and a sensor prediction at
+// each of the given ages
+func seedRetentionData(s *store.Store, now time.Time, ages ...time.Duration) {
+	for _, age := range ages {
+		at := now.Add(-age)
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: at, FilterMode: models.FilterModeDrinking, Ph: 7, TDS: 100})
+		s.SaveAnomaly(&models.AnomalyDetection{DeviceID: "stm32_post", AffectedMetric: "tds", Severity: "low", DetectedAt: at})
+		s.SaveSensorPrediction(&models.SensorPrediction{DeviceID: "stm32_post", FilterMode: models.FilterModeDrinking, PredictedFor: at})
+	}
+}
+
+func TestRetention_PrunesDataOlderThanRetentionPeriod(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now()
+	day := 24 * time.Hour
+	seedRetentionData(s, now, 120*day, 91*day, 89*day, time.Hour)
+
+	result, err := NewRetention(s, 90, 0, 0).Prune(now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SensorReadings != 2 || result.Anomalies != 2 || result.SensorPredictions != 2 {
+		t.Errorf("Expected 2 readings, anomalies and predictions pruned, got %+v", result)
+	}
+	if !result.Cutoff.Equal(now.AddDate(0, 0, -90)) {
+		t.Errorf("Expected the cutoff 90 days ago, got %s", result.Cutoff)
+	}
+
+	if count := s.GetReadingCount(); count != 2 {
+		t.Errorf("Expected 2 readings kept, got %d", count)
+	}
+	if anomalies, _ := s.GetAnomalies(10); len(anomalies) != 2 {
+		t.Errorf("Expected 2 anomalies kept, got %d", len(anomalies))
+	}
+	if preds, _ := s.GetSensorPredictions("stm32_post", now.Add(-200*day), now); len(preds) != 2 {
+		t.Errorf("Expected 2 sensor predictions kept, got %d", len(preds))
+	}
+
+	// A second run finds nothing left to prune
+	if result, _ := NewRetention(s, 90, 0, 0).Prune(now); result.Total() != 0 {
+		t.Errorf("Expected nothing pruned the second time, got %+v", result)
+	}
+}
+
+func TestRetention_DisabledWithZeroDays(t *testing.T) {
+	s := store.NewStore(100)
+	now := time.Now()
+	seedRetentionData(s, now, 365*24*time.Hour)
+
+	retention := NewRetention(s, 0, 0, 0)
+	if retention.IsEnabled() {
+		t.Fatal("Expected retention to be disabled with 0 days")
+	}
+	if result, err := retention.Prune(now); result != nil || err != nil {
+		t.Errorf("Expected no prune run, got %+v, %v", result, err)
+	}
+	retention.Start()
+	retention.Stop()
+
+	if count := s.GetReadingCount(); count != 1 {
+		t.Errorf("Expected the reading kept, got %d readings", count)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// SafetyCautionBroadcaster publishes drinking water safety cautions to live clients
+type SafetyCautionBroadcaster interface {
+	BroadcastSafetyCaution(caution *models.SafetyCaution)
+}
+
+// SafetyMonitor watches the age of the latest drinking water reading and
+// cautions live clients when it goes stale, e.g. because the device went
+// offline, so nobody relies on an hours-old verdict without knowing
+type SafetyMonitor struct {
+	store       store.DataStore
+	maxAge      time.Duration
+	policy      models.StalenessPolicy
+	broadcaster SafetyCautionBroadcaster
+
+	mu    sync.Mutex
+	stale bool // Whether the previous check found the data stale
+}
+
+// NewSafetyMonitor creates a safety monitor; a non-positive maxAge uses the
+// default and an unknown policy is fail-safe
+func NewSafetyMonitor(dataStore store.DataStore, maxAge time.Duration, policy models.StalenessPolicy, broadcaster SafetyCautionBroadcaster) *SafetyMonitor {
+	if maxAge <= 0 {
+		maxAge = models.DefaultSafetyMaxAge
+	}
+	if !policy.IsValid() {
+		policy = models.StalenessFailSafe
+	}
+
+	return &SafetyMonitor{
+		store:       dataStore,
+		maxAge:      maxAge,
+		policy:      policy,
+		broadcaster: broadcaster,
+	}
+}
+
+// Check broadcasts an active caution when the latest drinking water reading
+// has become stale, and an inactive one when fresh data arrives again. Nothing
+// is broadcast while the state is unchanged or before any reading arrived. It
+// returns whether the data is stale.
+func (m *SafetyMonitor) Check(now time.Time) bool {
+	reading, exists := m.store.GetLatestReadingByMode(models.FilterModeDrinking)
+	if !exists {
+		return false
+	}
+	safety := models.AssessDrinkingSafety(reading, now, m.maxAge, m.policy)
+
+	m.mu.Lock()
+	changed := safety.Stale != m.stale
+	m.stale = safety.Stale
+	m.mu.Unlock()
+	if !changed {
+		return safety.Stale
+	}
+
+	caution := &models.SafetyCaution{
+		Active:        safety.Stale,
+		DeviceID:      reading.DeviceID,
+		LastReadingAt: reading.Timestamp,
+		MaxAge:        m.maxAge.String(),
+		Policy:        m.policy,
+		SafeToDrink:   safety.Safe,
+		Message:       "fresh drinking water data received",
+	}
+	if safety.Stale {
+		caution.Message = safety.Caution
+		log.Printf("⚠️  Safety: Drinking water data from %s is stale (%s), safe-to-drink is %s", reading.DeviceID, safety.Caution, safety.Status)
+	} else {
+		log.Printf("✅ Safety: Fresh drinking water data from %s", reading.DeviceID)
+	}
+
+	if m.broadcaster != nil {
+		m.broadcaster.BroadcastSafetyCaution(caution)
+	}
+	return safety.Stale
+}
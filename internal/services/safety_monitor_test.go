@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// recordingCautionBroadcaster collects safety cautions
+type recordingCautionBroadcaster struct {
+	cautions []models.SafetyCaution
+}
+
+func (b *recordingCautionBroadcaster) BroadcastSafetyCaution(caution *models.SafetyCaution) {
+	b.cautions = append(b.cautions, *caution)
+}
+
+func TestSafetyMonitor_CautionsOnceWhenDataGoesStale(t *testing.T) {
+	s := store.NewStore(100)
+	broadcaster := &recordingCautionBroadcaster{}
+	monitor := NewSafetyMonitor(s, 10*time.Minute, models.StalenessFailSafe, broadcaster)
+
+	now := time.Now()
+	if monitor.Check(now) || len(broadcaster.cautions) != 0 {
+		t.Fatalf("Expected no caution before any reading, got %v", broadcaster.cautions)
+	}
+
+	addPostReading(s, now, 7.2)
+	if monitor.Check(now.Add(5*time.Minute)) || len(broadcaster.cautions) != 0 {
+		t.Fatalf("Expected no caution while the data is fresh, got %v", broadcaster.cautions)
+	}
+
+	// The device goes offline
+	for _, minutes := range []int{11, 12, 60} {
+		if !monitor.Check(now.Add(time.Duration(minutes) * time.Minute)) {
+			t.Errorf("Expected the data to be stale after %d minutes", minutes)
+		}
+	}
+	if len(broadcaster.cautions) != 1 {
+		t.Fatalf("Expected one caution while stale, got %d", len(broadcaster.cautions))
+	}
+	caution := broadcaster.cautions[0]
+	if !caution.Active || caution.SafeToDrink || caution.DeviceID != "stm32_post" || caution.Policy != models.StalenessFailSafe {
+		t.Errorf("Expected an active fail-safe caution for stm32_post, got %+v", caution)
+	}
+
+	// It comes back
+	addPostReading(s, now.Add(61*time.Minute), 7.2)
+	if monitor.Check(now.Add(62 * time.Minute)) {
+		t.Error("Expected fresh data after the device came back")
+	}
+	if len(broadcaster.cautions) != 2 || broadcaster.cautions[1].Active || !broadcaster.cautions[1].SafeToDrink {
+		t.Errorf("Expected the caution to be lifted, got %+v", broadcaster.cautions)
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -19,6 +21,25 @@ type Scheduler struct {
 	isRunning        bool
 	currentExecution *models.ScheduleExecution
 	mqttClient       *mqtt.Client
+	autoMode         *AutoModePolicy // Optional quality-driven mode switching
+	safetyMonitor    *SafetyMonitor  // Optional drinking water staleness cautions
+	modeCooldown     *store.ModeChangeCooldown
+	deferred         map[int]deferredRun // Runs held back by the mode change cooldown, by schedule ID
+}
+
+// deferredRun is a schedule run held back by the mode change cooldown
+type deferredRun struct {
+	retryAt time.Time // When the cooldown ends
+	until   time.Time // Past this the run is given up, as it would have ended anyway
+}
+
+// cooldownError rejects a scheduled mode change made within the cooldown
+type cooldownError struct {
+	remaining time.Duration
+}
+
+func (e *cooldownError) Error() string {
+	return fmt.Sprintf("filter mode changed recently, %s of cooldown remaining", e.remaining.Round(time.Second))
 }
 
 // NewScheduler creates a new scheduler instance
@@ -27,6 +48,7 @@ func NewScheduler(dataStore store.DataStore, mqttClient *mqtt.Client) *Scheduler
 		store:      dataStore,
 		stopChan:   make(chan bool),
 		mqttClient: mqttClient,
+		deferred:   make(map[int]deferredRun),
 	}
 }
 
@@ -65,21 +87,74 @@ func (s *Scheduler) Stop() {
 	log.Println("🛑 Scheduler: Stopped")
 }
 
+// SetAutoModePolicy enables quality-driven mode switching, evaluated on every
+// scheduler tick. Pass nil to disable it.
+func (s *Scheduler) SetAutoModePolicy(policy *AutoModePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoMode = policy
+}
+
+// SetSafetyMonitor enables drinking water staleness cautions, checked on every
+// scheduler tick. Pass nil to disable them.
+func (s *Scheduler) SetSafetyMonitor(monitor *SafetyMonitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.safetyMonitor = monitor
+}
+
+// SetModeChangeCooldown makes scheduled mode changes respect the minimum
+// interval between filter mode changes
+func (s *Scheduler) SetModeChangeCooldown(cooldown *store.ModeChangeCooldown) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modeCooldown = cooldown
+}
+
 // run is the main scheduler loop
 func (s *Scheduler) run() {
 	// Check immediately on start
 	s.checkAndExecuteSchedules()
+	s.evaluateAutoMode()
+	s.checkSafety()
 
 	for {
 		select {
 		case <-s.ticker.C:
 			s.checkAndExecuteSchedules()
+			s.evaluateAutoMode()
+			s.checkSafety()
 		case <-s.stopChan:
 			return
 		}
 	}
 }
 
+// evaluateAutoMode runs the auto-mode policy when one is configured
+func (s *Scheduler) evaluateAutoMode() {
+	s.mu.RLock()
+	policy := s.autoMode
+	s.mu.RUnlock()
+
+	if policy == nil {
+		return
+	}
+	if _, err := policy.Evaluate(); err != nil {
+		log.Printf("❌ Scheduler: Auto-mode evaluation failed: %v", err)
+	}
+}
+
+// checkSafety runs the safety monitor when one is configured
+func (s *Scheduler) checkSafety() {
+	s.mu.RLock()
+	monitor := s.safetyMonitor
+	s.mu.RUnlock()
+
+	if monitor != nil {
+		monitor.Check(time.Now())
+	}
+}
+
 // checkAndExecuteSchedules checks for active schedules and executes them
 func (s *Scheduler) checkAndExecuteSchedules() {
 	// Get all active schedules
@@ -97,25 +172,47 @@ func (s *Scheduler) checkAndExecuteSchedules() {
 	executed := 0
 
 	for _, schedule := range schedules {
-		// Check if schedule should execute now
-		if schedule.ShouldExecuteNow() {
-			// Check if already executing
-			if s.isScheduleCurrentlyExecuting(schedule.ID) {
-				continue
-			}
-
-			// Check if was already executed recently (within last minute)
-			if s.wasRecentlyExecuted(schedule.ID) {
-				continue
-			}
-
-			// Execute the schedule
-			if err := s.executeSchedule(&schedule); err != nil {
-				log.Printf("❌ Scheduler: Failed to execute schedule '%s': %v", schedule.Name, err)
-			} else {
-				executed++
-			}
+		if s.oneTimeRunMissed(&schedule, now) {
+			log.Printf("⚠️  Scheduler: One-time schedule '%s' missed its run", schedule.Name)
+			s.deactivateOneTimeSchedule(&schedule)
+			continue
+		}
+
+		// Check if schedule should execute now, or a run held back by the
+		// cooldown can go ahead
+		if !schedule.ShouldExecuteNow() && !s.isRetryDue(schedule.ID, now) {
+			continue
 		}
+
+		// Check if already executing
+		if s.isScheduleCurrentlyExecuting(schedule.ID) {
+			continue
+		}
+
+		// Check if was already executed recently (within last minute)
+		if s.wasRecentlyExecuted(schedule.ID) {
+			continue
+		}
+
+		// Execute the schedule
+		err := s.executeSchedule(&schedule)
+		var cooldownErr *cooldownError
+		if errors.As(err, &cooldownErr) && s.deferRun(&schedule, now, now.Add(cooldownErr.remaining)) {
+			log.Printf("⏳ Scheduler: Schedule '%s' deferred: %v", schedule.Name, err)
+			continue
+		}
+
+		s.mu.Lock()
+		delete(s.deferred, schedule.ID)
+		s.mu.Unlock()
+
+		if err != nil {
+			log.Printf("❌ Scheduler: Failed to execute schedule '%s': %v", schedule.Name, err)
+			s.recordFailedRun(&schedule)
+		} else {
+			executed++
+		}
+		s.deactivateOneTimeSchedule(&schedule)
 	}
 
 	if executed > 0 {
@@ -127,6 +224,13 @@ func (s *Scheduler) checkAndExecuteSchedules() {
 func (s *Scheduler) executeSchedule(schedule *models.FilterSchedule) error {
 	log.Printf("🔄 Scheduler: Executing schedule '%s' - Mode: %s", schedule.Name, schedule.FilterMode)
 
+	s.mu.RLock()
+	cooldown := s.modeCooldown
+	s.mu.RUnlock()
+	if remaining, ok := cooldown.Reserve(s.store.GetActiveDevices(), time.Now()); !ok {
+		return &cooldownError{remaining: remaining}
+	}
+
 	// Create execution record
 	execution := &models.ScheduleExecution{
 		ScheduleID: schedule.ID,
@@ -145,7 +249,9 @@ func (s *Scheduler) executeSchedule(schedule *models.FilterSchedule) error {
 	s.mu.Unlock()
 
 	// Change filter mode in the database
+	previousMode := s.store.GetCurrentFilterMode()
 	s.store.SetCurrentFilterMode(schedule.FilterMode)
+	s.recordModeChange(previousMode, schedule.FilterMode, fmt.Sprintf("Schedule '%s'", schedule.Name))
 
 	// Publish filter command via MQTT
 	if s.mqttClient != nil {
@@ -163,6 +269,98 @@ func (s *Scheduler) executeSchedule(schedule *models.FilterSchedule) error {
 	return nil
 }
 
+// isRetryDue reports whether a run of the schedule held back by the cooldown
+// can be retried at now
+func (s *Scheduler) isRetryDue(scheduleID int, now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, exists := s.deferred[scheduleID]
+	return exists && !now.Before(run.retryAt)
+}
+
+// deferRun holds a schedule run back until retryAt, when the cooldown ends.
+// It returns false when the cooldown outlasts the schedule's duration from
+// when the run was first due, so the run fails instead.
+func (s *Scheduler) deferRun(schedule *models.FilterSchedule, now, retryAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, exists := s.deferred[schedule.ID]
+	if !exists {
+		run.until = now.Add(time.Duration(schedule.DurationMinutes) * time.Minute)
+	}
+	if retryAt.After(run.until) {
+		return false
+	}
+	run.retryAt = retryAt
+	s.deferred[schedule.ID] = run
+	return true
+}
+
+// recordFailedRun records a schedule run that couldn't be carried out so it
+// shows up in the schedule's execution history
+func (s *Scheduler) recordFailedRun(schedule *models.FilterSchedule) {
+	now := time.Now()
+	execution := &models.ScheduleExecution{
+		ScheduleID:  schedule.ID,
+		ExecutedAt:  now,
+		CompletedAt: &now,
+		Status:      "failed",
+	}
+	if err := s.store.CreateScheduleExecution(execution); err != nil {
+		log.Printf("❌ Scheduler: Failed to record failed run of schedule '%s': %v", schedule.Name, err)
+	}
+}
+
+// oneTimeRunMissed reports whether a one-time schedule's run has passed without
+// it running, e.g. because the server was down at the time. A run held back by
+// the cooldown isn't missed while it can still be retried.
+func (s *Scheduler) oneTimeRunMissed(schedule *models.FilterSchedule, now time.Time) bool {
+	if !schedule.IsOneTime() {
+		return false
+	}
+	s.mu.RLock()
+	_, deferred := s.deferred[schedule.ID]
+	s.mu.RUnlock()
+
+	// A run is due for a minute from its start, see ShouldExecuteNow
+	return !deferred && schedule.NextExecutionAfter(now.Add(-time.Minute)) == nil
+}
+
+// deactivateOneTimeSchedule disables a one-time schedule once it has run, or
+// failed to, so it doesn't stay active after its date
+func (s *Scheduler) deactivateOneTimeSchedule(schedule *models.FilterSchedule) {
+	if !schedule.IsOneTime() {
+		return
+	}
+	if err := s.store.ToggleSchedule(schedule.ID, false); err != nil {
+		log.Printf("❌ Scheduler: Failed to deactivate one-time schedule '%s': %v", schedule.Name, err)
+		return
+	}
+	log.Printf("📅 Scheduler: One-time schedule '%s' was due on %s and is now inactive", schedule.Name, *schedule.RunDate)
+}
+
+// recordModeChange writes a schedule-driven change to the audit log and holds
+// off the auto-mode policy for its cooldown
+func (s *Scheduler) recordModeChange(from, to models.FilterMode, details string) {
+	change := &models.FilterModeChange{
+		FromMode: from,
+		ToMode:   to,
+		Reason:   models.FilterModeChangeSchedule,
+		Details:  details,
+	}
+	if err := s.store.RecordFilterModeChange(change); err != nil {
+		log.Printf("❌ Scheduler: Failed to record filter mode change: %v", err)
+	}
+
+	s.mu.RLock()
+	policy := s.autoMode
+	s.mu.RUnlock()
+	if policy != nil {
+		policy.NoteManualChange()
+	}
+}
+
 // completeScheduleAfterDuration marks the execution as completed after the scheduled duration
 func (s *Scheduler) completeScheduleAfterDuration(execution *models.ScheduleExecution, schedule *models.FilterSchedule) {
 	duration := time.Duration(schedule.DurationMinutes) * time.Minute
@@ -217,6 +415,11 @@ func (s *Scheduler) HandleManualOverride(reason string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Keep the auto-mode policy from reverting the user's choice
+	if s.autoMode != nil {
+		s.autoMode.NoteManualChange()
+	}
+
 	if s.currentExecution == nil {
 		return
 	}
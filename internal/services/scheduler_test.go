@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/Capstone-E1/aquasmart_backend/internal/store"
+)
+
+// scheduleStore keeps schedules and their executions in memory on top of the
+// in-memory store, which doesn't manage schedules
+type scheduleStore struct {
+	*store.Store
+	mu         sync.Mutex
+	schedules  []models.FilterSchedule
+	executions []models.ScheduleExecution
+}
+
+func (s *scheduleStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := []models.FilterSchedule{}
+	for _, schedule := range s.schedules {
+		if schedule.IsActive || !activeOnly {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (s *scheduleStore) ToggleSchedule(id int, isActive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.schedules {
+		if s.schedules[i].ID == id {
+			s.schedules[i].IsActive = isActive
+			return nil
+		}
+	}
+	return fmt.Errorf("schedule not found")
+}
+
+func (s *scheduleStore) CreateScheduleExecution(execution *models.ScheduleExecution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	execution.ID = len(s.executions) + 1
+	s.executions = append(s.executions, *execution)
+	return nil
+}
+
+func (s *scheduleStore) GetScheduleExecutions(scheduleID int, limit int) ([]models.ScheduleExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	executions := []models.ScheduleExecution{}
+	for i := len(s.executions) - 1; i >= 0 && len(executions) < limit; i-- {
+		if s.executions[i].ScheduleID == scheduleID {
+			executions = append(executions, s.executions[i])
+		}
+	}
+	return executions, nil
+}
+
+func (s *scheduleStore) UpdateScheduleExecution(*models.ScheduleExecution) error {
+	return nil
+}
+
+func TestScheduler_DeactivatesOneTimeScheduleAfterItRuns(t *testing.T) {
+	now := time.Now().UTC()
+	today := now.Format(models.RunDateLayout)
+	startTime := now.Format("15:04:05")
+	s := &scheduleStore{
+		Store: store.NewStore(10),
+		schedules: []models.FilterSchedule{
+			{ID: 1, Name: "Once", FilterMode: models.FilterModeHousehold, StartTime: startTime, DurationMinutes: 30,
+				DaysOfWeek: []string{}, RunDate: &today, IsActive: true, Timezone: "UTC"},
+		},
+	}
+	s.SetCurrentFilterMode(models.FilterModeDrinking)
+	scheduler := NewScheduler(s, nil)
+
+	scheduler.checkAndExecuteSchedules()
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeHousehold {
+		t.Fatalf("Expected the one-time schedule to switch to household mode, got %s", mode)
+	}
+	if s.schedules[0].IsActive {
+		t.Error("Expected the one-time schedule to be inactive after running")
+	}
+
+	scheduler.checkAndExecuteSchedules()
+	if len(s.executions) != 1 {
+		t.Errorf("Expected the one-time schedule to run once, got %d executions", len(s.executions))
+	}
+}
+
+func TestScheduler_KeepsWeeklyScheduleActiveAfterItRuns(t *testing.T) {
+	now := time.Now().UTC()
+	s := &scheduleStore{
+		Store: store.NewStore(10),
+		schedules: []models.FilterSchedule{
+			{ID: 1, Name: "Weekly", FilterMode: models.FilterModeHousehold, StartTime: now.Format("15:04:05"), DurationMinutes: 30,
+				DaysOfWeek: []string{strings.ToLower(now.Weekday().String())}, IsActive: true, Timezone: "UTC"},
+		},
+	}
+	NewScheduler(s, nil).checkAndExecuteSchedules()
+
+	if len(s.executions) != 1 || !s.schedules[0].IsActive {
+		t.Errorf("Expected the weekly schedule to run and stay active, got %d executions, active=%v", len(s.executions), s.schedules[0].IsActive)
+	}
+}
+
+func TestScheduler_RetriesRunHeldBackByCooldown(t *testing.T) {
+	now := time.Now().UTC()
+	s := &scheduleStore{
+		Store: store.NewStore(10),
+		schedules: []models.FilterSchedule{
+			{ID: 1, Name: "Weekly", FilterMode: models.FilterModeHousehold, StartTime: now.Format("15:04:05"), DurationMinutes: 30,
+				DaysOfWeek: []string{strings.ToLower(now.Weekday().String())}, IsActive: true, Timezone: "UTC"},
+		},
+	}
+	s.SetCurrentFilterMode(models.FilterModeDrinking)
+	cooldown := store.NewModeChangeCooldown(100 * time.Millisecond)
+	cooldown.Reserve(s.GetActiveDevices(), time.Now())
+	scheduler := NewScheduler(s, nil)
+	scheduler.SetModeChangeCooldown(cooldown)
+
+	scheduler.checkAndExecuteSchedules()
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeDrinking || len(s.executions) != 0 {
+		t.Fatalf("Expected the run held back by the cooldown, got mode %s and %d executions", mode, len(s.executions))
+	}
+
+	// A later tick, past the start minute, runs it once the cooldown is over
+	s.schedules[0].StartTime = now.Add(-5 * time.Minute).Format("15:04:05")
+	time.Sleep(150 * time.Millisecond)
+	scheduler.checkAndExecuteSchedules()
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeHousehold || len(s.executions) != 1 {
+		t.Errorf("Expected the deferred run to go ahead, got mode %s and %d executions", mode, len(s.executions))
+	}
+}
+
+func TestScheduler_DeactivatesOneTimeScheduleThatFailed(t *testing.T) {
+	now := time.Now().UTC()
+	today := now.Format(models.RunDateLayout)
+	s := &scheduleStore{
+		Store: store.NewStore(10),
+		schedules: []models.FilterSchedule{
+			{ID: 1, Name: "Once", FilterMode: models.FilterModeHousehold, StartTime: now.Format("15:04:05"), DurationMinutes: 30,
+				DaysOfWeek: []string{}, RunDate: &today, IsActive: true, Timezone: "UTC"},
+		},
+	}
+	s.SetCurrentFilterMode(models.FilterModeDrinking)
+
+	// The cooldown outlasts the schedule's 30 minutes, so the run can't be deferred
+	cooldown := store.NewModeChangeCooldown(time.Hour)
+	cooldown.Reserve(s.GetActiveDevices(), time.Now())
+	scheduler := NewScheduler(s, nil)
+	scheduler.SetModeChangeCooldown(cooldown)
+
+	scheduler.checkAndExecuteSchedules()
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeDrinking {
+		t.Errorf("Expected the mode left unchanged, got %s", mode)
+	}
+	if len(s.executions) != 1 || s.executions[0].Status != "failed" {
+		t.Errorf("Expected a failed run recorded, got %+v", s.executions)
+	}
+	if s.schedules[0].IsActive {
+		t.Error("Expected the failed one-time schedule to be inactive")
+	}
+}
+
+func TestScheduler_DeactivatesMissedOneTimeSchedule(t *testing.T) {
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(models.RunDateLayout)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(models.RunDateLayout)
+	s := &scheduleStore{
+		Store: store.NewStore(10),
+		schedules: []models.FilterSchedule{
+			{ID: 1, Name: "Missed", FilterMode: models.FilterModeHousehold, StartTime: "08:00:00", DurationMinutes: 30,
+				DaysOfWeek: []string{}, RunDate: &yesterday, IsActive: true, Timezone: "UTC"},
+			{ID: 2, Name: "Upcoming", FilterMode: models.FilterModeHousehold, StartTime: "08:00:00", DurationMinutes: 30,
+				DaysOfWeek: []string{}, RunDate: &tomorrow, IsActive: true, Timezone: "UTC"},
+		},
+	}
+	s.SetCurrentFilterMode(models.FilterModeDrinking)
+
+	NewScheduler(s, nil).checkAndExecuteSchedules()
+	if s.schedules[0].IsActive || !s.schedules[1].IsActive {
+		t.Errorf("Expected only the missed one-time schedule deactivated, got active=%v/%v", s.schedules[0].IsActive, s.schedules[1].IsActive)
+	}
+	if mode := s.GetCurrentFilterMode(); mode != models.FilterModeDrinking || len(s.executions) != 0 {
+		t.Errorf("Expected the missed run not to run late, got mode %s and %d executions", mode, len(s.executions))
+	}
+}
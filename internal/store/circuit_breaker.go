@@ -0,0 +1,821 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// ErrCircuitOpen is returned instead of calling the data store while the
+// circuit breaker is open
+var ErrCircuitOpen = errors.New("data store unavailable: circuit breaker open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState string
+
+// Circuit breaker states
+const (
+	CircuitClosed   CircuitState = "closed"    // Calls go through
+	CircuitOpen     CircuitState = "open"      // Calls fail fast until the cooldown ends
+	CircuitHalfOpen CircuitState = "half_open" // One probe call goes through; its outcome closes or re-opens the circuit
+)
+
+// CircuitBreaker stops calls to a failing dependency: after threshold
+// consecutive failures it opens and fails calls fast, and once the cooldown
+// has passed it lets a single call through to probe whether the dependency
+// recovered
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the circuit last opened
+	probing  bool      // A half-open probe is in flight
+}
+
+// NewCircuitBreaker creates a closed circuit breaker; a non-positive threshold
+// opens it on the first failure
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+// State returns the breaker's state, moving an open breaker whose cooldown
+// has passed to half-open
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *CircuitBreaker) currentState() CircuitState {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = CircuitHalfOpen
+		log.Printf("🔌 Data store circuit half-open: probing after %s", b.cooldown)
+	}
+	return b.state
+}
+
+// RetryAfter returns how long an open breaker keeps failing fast, or 0 when
+// calls go through
+func (b *CircuitBreaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.currentState() != CircuitOpen {
+		return 0
+	}
+	return b.cooldown - b.now().Sub(b.openedAt)
+}
+
+// Allow returns ErrCircuitOpen while calls must fail fast. A half-open
+// breaker allows one probe at a time; its outcome must be passed to Record.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of a call that was allowed
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	state := b.currentState()
+	if !failed {
+		if state != CircuitClosed {
+			log.Println("✅ Data store circuit closed: probe succeeded")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	switch state {
+	case CircuitHalfOpen:
+		b.open()
+	case CircuitClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	b.failures = 0
+	log.Printf("⚠️  Data store circuit open: failing fast for %s", b.cooldown)
+}
+
+// CircuitBreakerStore decorates a DataStore with a circuit breaker. Calls that
+// report errors are guarded: they fail fast with ErrCircuitOpen while the
+// breaker is open, and their failures count towards opening it. Database calls
+// without an error result can't report failures, so they only run while the
+// circuit is closed and return empty results otherwise. Calls that only touch
+// in-memory state (LED command, filtration process) go straight to the store.
+type CircuitBreakerStore struct {
+	DataStore
+	breaker   *CircuitBreaker
+	isFailure func(error) bool
+
+	modeMu   sync.Mutex
+	lastMode models.FilterMode // Filter mode last read from or written to the store
+}
+
+var _ DataStore = (*CircuitBreakerStore)(nil)
+
+// NewCircuitBreakerStore wraps dataStore with the breaker. isFailure tells
+// which errors mean the store is unavailable (nil counts every error), so
+// errors such as a missing record don't open the circuit.
+func NewCircuitBreakerStore(dataStore DataStore, breaker *CircuitBreaker, isFailure func(error) bool) *CircuitBreakerStore {
+	if isFailure == nil {
+		isFailure = func(err error) bool { return err != nil }
+	}
+	return &CircuitBreakerStore{DataStore: dataStore, breaker: breaker, isFailure: isFailure}
+}
+
+// Breaker returns the store's circuit breaker
+func (c *CircuitBreakerStore) Breaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// call runs fn unless the circuit is open and records its outcome
+func (c *CircuitBreakerStore) call(fn func() error) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	c.breaker.Record(err != nil && c.isFailure(err))
+	return err
+}
+
+func guard[T any](c *CircuitBreakerStore, fn func() (T, error)) (T, error) {
+	var result T
+	err := c.call(func() (err error) {
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+// unlessOpen runs fn while the circuit is closed and returns empty otherwise.
+// fn can't report failures, so it never counts as the half-open probe.
+func unlessOpen[T any](c *CircuitBreakerStore, empty T, fn func() T) T {
+	if c.breaker.State() != CircuitClosed {
+		return empty
+	}
+	return fn()
+}
+
+func guard2[T1, T2 any](c *CircuitBreakerStore, fn func() (T1, T2, error)) (T1, T2, error) {
+	var first T1
+	var second T2
+	err := c.call(func() (err error) {
+		first, second, err = fn()
+		return err
+	})
+	return first, second, err
+}
+
+func (c *CircuitBreakerStore) Ping() error {
+	return c.call(func() error {
+		return c.DataStore.Ping()
+	})
+}
+
+func (c *CircuitBreakerStore) AddSensorReadingsBatch(readings []models.SensorReading) error {
+	return c.call(func() error {
+		return c.DataStore.AddSensorReadingsBatch(readings)
+	})
+}
+
+func (c *CircuitBreakerStore) RecomputeReadingQuality() (int, error) {
+	return guard(c, func() (int, error) {
+		return c.DataStore.RecomputeReadingQuality()
+	})
+}
+
+func (c *CircuitBreakerStore) GetAvailableDays(start, end time.Time, loc *time.Location) ([]string, error) {
+	return guard(c, func() ([]string, error) {
+		return c.DataStore.GetAvailableDays(start, end, loc)
+	})
+}
+
+func (c *CircuitBreakerStore) GetSensorDataStats(filterMode models.FilterMode) (*models.SensorDataStats, error) {
+	return guard(c, func() (*models.SensorDataStats, error) {
+		return c.DataStore.GetSensorDataStats(filterMode)
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingsAround(deviceID string, at time.Time, before, after int) ([]models.SensorReading, error) {
+	return guard(c, func() ([]models.SensorReading, error) {
+		return c.DataStore.GetReadingsAround(deviceID, at, before, after)
+	})
+}
+
+func (c *CircuitBreakerStore) QueryReadings(filter models.ReadingFilter) ([]models.SensorReading, int, error) {
+	return guard2(c, func() ([]models.SensorReading, int, error) {
+		return c.DataStore.QueryReadings(filter)
+	})
+}
+
+func (c *CircuitBreakerStore) QueryReadingsAfter(filter models.ReadingFilter, after *models.ReadingCursor) ([]models.SensorReading, error) {
+	return guard(c, func() ([]models.SensorReading, error) {
+		return c.DataStore.QueryReadingsAfter(filter, after)
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingsPaginated(limit, offset int, deviceID string, mode *models.FilterMode, sortAsc bool) ([]models.SensorReading, int, error) {
+	return guard2(c, func() ([]models.SensorReading, int, error) {
+		return c.DataStore.GetReadingsPaginated(limit, offset, deviceID, mode, sortAsc)
+	})
+}
+
+func (c *CircuitBreakerStore) GetMetricHeatmap(metric string, start time.Time, end time.Time) ([]models.HeatmapBucket, error) {
+	return guard(c, func() ([]models.HeatmapBucket, error) {
+		return c.DataStore.GetMetricHeatmap(metric, start, end)
+	})
+}
+
+func (c *CircuitBreakerStore) GetMetricHistogram(metric string, start, end time.Time, bins int) (*models.MetricHistogram, error) {
+	return guard(c, func() (*models.MetricHistogram, error) {
+		return c.DataStore.GetMetricHistogram(metric, start, end, bins)
+	})
+}
+
+func (c *CircuitBreakerStore) GetMetricAggregates(metric, interval string, start, end time.Time) ([]models.AggregateBucket, error) {
+	return guard(c, func() ([]models.AggregateBucket, error) {
+		return c.DataStore.GetMetricAggregates(metric, interval, start, end)
+	})
+}
+
+func (c *CircuitBreakerStore) GetFilterModeCounts() ([]models.FilterModeCount, error) {
+	return guard(c, func() ([]models.FilterModeCount, error) {
+		return c.DataStore.GetFilterModeCounts()
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingCountsByDevice(start, end time.Time) (map[string]int, error) {
+	return guard(c, func() (map[string]int, error) {
+		return c.DataStore.GetReadingCountsByDevice(start, end)
+	})
+}
+
+func (c *CircuitBreakerStore) DeleteAllSensorReadings() error {
+	return c.call(func() error {
+		return c.DataStore.DeleteAllSensorReadings()
+	})
+}
+
+func (c *CircuitBreakerStore) PruneDataBefore(cutoff time.Time, batchSize int) (*models.PruneResult, error) {
+	return guard(c, func() (*models.PruneResult, error) {
+		return c.DataStore.PruneDataBefore(cutoff, batchSize)
+	})
+}
+
+func (c *CircuitBreakerStore) GetDeviceTypeOverrides() (map[string]string, error) {
+	return guard(c, func() (map[string]string, error) {
+		return c.DataStore.GetDeviceTypeOverrides()
+	})
+}
+
+func (c *CircuitBreakerStore) SetDeviceTypeOverride(deviceID string, deviceType string) error {
+	return c.call(func() error {
+		return c.DataStore.SetDeviceTypeOverride(deviceID, deviceType)
+	})
+}
+
+func (c *CircuitBreakerStore) SetDeviceAPIKeyHash(deviceID string, keyHash string) error {
+	return c.call(func() error {
+		return c.DataStore.SetDeviceAPIKeyHash(deviceID, keyHash)
+	})
+}
+
+func (c *CircuitBreakerStore) GetDeviceAPIKeyHash(deviceID string) (string, bool, error) {
+	return guard2(c, func() (string, bool, error) {
+		return c.DataStore.GetDeviceAPIKeyHash(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) CountDeviceAPIKeys() (int, error) {
+	return guard(c, func() (int, error) {
+		return c.DataStore.CountDeviceAPIKeys()
+	})
+}
+
+func (c *CircuitBreakerStore) GetDeviceStatuses() ([]models.DeviceStatus, error) {
+	return guard(c, func() ([]models.DeviceStatus, error) {
+		return c.DataStore.GetDeviceStatuses()
+	})
+}
+
+func (c *CircuitBreakerStore) GetDeviceStatus(deviceID string) (*models.DeviceStatus, bool, error) {
+	return guard2(c, func() (*models.DeviceStatus, bool, error) {
+		return c.DataStore.GetDeviceStatus(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) PatchDeviceStatus(deviceID string, update models.DeviceStatusUpdate) (*models.DeviceStatus, bool, error) {
+	return guard2(c, func() (*models.DeviceStatus, bool, error) {
+		return c.DataStore.PatchDeviceStatus(deviceID, update)
+	})
+}
+
+func (c *CircuitBreakerStore) SetFilterSpec(spec models.FilterSpec) error {
+	return c.call(func() error {
+		return c.DataStore.SetFilterSpec(spec)
+	})
+}
+
+func (c *CircuitBreakerStore) GetFilterSpec(deviceID string) (models.FilterSpec, bool, error) {
+	return guard2(c, func() (models.FilterSpec, bool, error) {
+		return c.DataStore.GetFilterSpec(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) SetAlertPreferences(prefs models.AlertPreferences) error {
+	return c.call(func() error {
+		return c.DataStore.SetAlertPreferences(prefs)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAlertPreferences(deviceID string) (models.AlertPreferences, error) {
+	return guard(c, func() (models.AlertPreferences, error) {
+		return c.DataStore.GetAlertPreferences(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) GetProcessedVolume(deviceID string, since time.Time) (float64, error) {
+	return guard(c, func() (float64, error) {
+		return c.DataStore.GetProcessedVolume(deviceID, since)
+	})
+}
+
+func (c *CircuitBreakerStore) RecordFilterModeChange(change *models.FilterModeChange) error {
+	return c.call(func() error {
+		return c.DataStore.RecordFilterModeChange(change)
+	})
+}
+
+func (c *CircuitBreakerStore) GetFilterModeChanges(limit int) ([]models.FilterModeChange, error) {
+	return guard(c, func() ([]models.FilterModeChange, error) {
+		return c.DataStore.GetFilterModeChanges(limit)
+	})
+}
+
+func (c *CircuitBreakerStore) RecordDeviceCommand(command *models.DeviceCommand) error {
+	return c.call(func() error {
+		return c.DataStore.RecordDeviceCommand(command)
+	})
+}
+
+func (c *CircuitBreakerStore) UpdateDeviceCommandStatus(id int, status, errMsg string) error {
+	return c.call(func() error {
+		return c.DataStore.UpdateDeviceCommandStatus(id, status, errMsg)
+	})
+}
+
+func (c *CircuitBreakerStore) GetDeviceCommands(deviceID string, limit int) ([]models.DeviceCommand, error) {
+	return guard(c, func() ([]models.DeviceCommand, error) {
+		return c.DataStore.GetDeviceCommands(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) AddMaintenanceEvent(event *models.MaintenanceEvent) error {
+	return c.call(func() error {
+		return c.DataStore.AddMaintenanceEvent(event)
+	})
+}
+
+func (c *CircuitBreakerStore) GetMaintenanceEvents(deviceID string, limit int) ([]models.MaintenanceEvent, error) {
+	return guard(c, func() ([]models.MaintenanceEvent, error) {
+		return c.DataStore.GetMaintenanceEvents(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) CreateSchedule(schedule *models.FilterSchedule) error {
+	return c.call(func() error {
+		return c.DataStore.CreateSchedule(schedule)
+	})
+}
+
+func (c *CircuitBreakerStore) GetSchedule(id int) (*models.FilterSchedule, error) {
+	return guard(c, func() (*models.FilterSchedule, error) {
+		return c.DataStore.GetSchedule(id)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedule, error) {
+	return guard(c, func() ([]models.FilterSchedule, error) {
+		return c.DataStore.GetAllSchedules(activeOnly)
+	})
+}
+
+func (c *CircuitBreakerStore) UpdateSchedule(schedule *models.FilterSchedule) error {
+	return c.call(func() error {
+		return c.DataStore.UpdateSchedule(schedule)
+	})
+}
+
+func (c *CircuitBreakerStore) DeleteSchedule(id int) error {
+	return c.call(func() error {
+		return c.DataStore.DeleteSchedule(id)
+	})
+}
+
+func (c *CircuitBreakerStore) ToggleSchedule(id int, isActive bool) error {
+	return c.call(func() error {
+		return c.DataStore.ToggleSchedule(id, isActive)
+	})
+}
+
+func (c *CircuitBreakerStore) CreateScheduleExecution(execution *models.ScheduleExecution) error {
+	return c.call(func() error {
+		return c.DataStore.CreateScheduleExecution(execution)
+	})
+}
+
+func (c *CircuitBreakerStore) GetScheduleExecution(id int) (*models.ScheduleExecution, error) {
+	return guard(c, func() (*models.ScheduleExecution, error) {
+		return c.DataStore.GetScheduleExecution(id)
+	})
+}
+
+func (c *CircuitBreakerStore) GetScheduleExecutions(scheduleID int, limit int) ([]models.ScheduleExecution, error) {
+	return guard(c, func() ([]models.ScheduleExecution, error) {
+		return c.DataStore.GetScheduleExecutions(scheduleID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAllScheduleExecutions(limit int) ([]models.ScheduleExecution, error) {
+	return guard(c, func() ([]models.ScheduleExecution, error) {
+		return c.DataStore.GetAllScheduleExecutions(limit)
+	})
+}
+
+func (c *CircuitBreakerStore) UpdateScheduleExecution(execution *models.ScheduleExecution) error {
+	return c.call(func() error {
+		return c.DataStore.UpdateScheduleExecution(execution)
+	})
+}
+
+func (c *CircuitBreakerStore) SaveAnomaly(anomaly *models.AnomalyDetection) error {
+	return c.call(func() error {
+		return c.DataStore.SaveAnomaly(anomaly)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomaly(id int) (*models.AnomalyDetection, error) {
+	return guard(c, func() (*models.AnomalyDetection, error) {
+		return c.DataStore.GetAnomaly(id)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetAnomalies(limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomaliesByDevice(deviceID string, limit int) ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetAnomaliesByDevice(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomaliesBySeverity(severity string, limit int) ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetAnomaliesBySeverity(severity, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomaliesInRange(start, end time.Time, filterMode models.FilterMode, limit int) ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetAnomaliesInRange(start, end, filterMode, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetUnresolvedAnomalies() ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetUnresolvedAnomalies()
+	})
+}
+
+func (c *CircuitBreakerStore) GetRecentlyResolvedAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	return guard(c, func() ([]models.AnomalyDetection, error) {
+		return c.DataStore.GetRecentlyResolvedAnomalies(limit)
+	})
+}
+
+func (c *CircuitBreakerStore) ResolveAnomaly(id int) error {
+	return c.call(func() error {
+		return c.DataStore.ResolveAnomaly(id)
+	})
+}
+
+func (c *CircuitBreakerStore) AutoResolveAnomaly(id int) error {
+	return c.call(func() error {
+		return c.DataStore.AutoResolveAnomaly(id)
+	})
+}
+
+func (c *CircuitBreakerStore) MarkAnomalyFalsePositive(id int) error {
+	return c.call(func() error {
+		return c.DataStore.MarkAnomalyFalsePositive(id)
+	})
+}
+
+func (c *CircuitBreakerStore) SetAnomalyAlertSent(id int, sent bool) error {
+	return c.call(func() error {
+		return c.DataStore.SetAnomalyAlertSent(id, sent)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomalyStats() (*models.AnomalyStats, error) {
+	return guard(c, func() (*models.AnomalyStats, error) {
+		return c.DataStore.GetAnomalyStats()
+	})
+}
+
+func (c *CircuitBreakerStore) GetAnomalyOpsMetrics() (*models.AnomalyOpsMetrics, error) {
+	return guard(c, func() (*models.AnomalyOpsMetrics, error) {
+		return c.DataStore.GetAnomalyOpsMetrics()
+	})
+}
+
+func (c *CircuitBreakerStore) SaveBaseline(baseline *models.SensorBaseline) error {
+	return c.call(func() error {
+		return c.DataStore.SaveBaseline(baseline)
+	})
+}
+
+func (c *CircuitBreakerStore) GetBaseline(deviceID string, filterMode models.FilterMode) (*models.SensorBaseline, error) {
+	return guard(c, func() (*models.SensorBaseline, error) {
+		return c.DataStore.GetBaseline(deviceID, filterMode)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAllBaselines() ([]models.SensorBaseline, error) {
+	return guard(c, func() ([]models.SensorBaseline, error) {
+		return c.DataStore.GetAllBaselines()
+	})
+}
+
+func (c *CircuitBreakerStore) UpdateBaseline(baseline *models.SensorBaseline) error {
+	return c.call(func() error {
+		return c.DataStore.UpdateBaseline(baseline)
+	})
+}
+
+func (c *CircuitBreakerStore) SaveFilterHealth(health *models.FilterHealth) error {
+	return c.call(func() error {
+		return c.DataStore.SaveFilterHealth(health)
+	})
+}
+
+func (c *CircuitBreakerStore) GetLatestFilterHealth(deviceID string) (*models.FilterHealth, error) {
+	return guard(c, func() (*models.FilterHealth, error) {
+		return c.DataStore.GetLatestFilterHealth(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) GetFilterHealthHistory(deviceID string, limit int) ([]models.FilterHealth, error) {
+	return guard(c, func() ([]models.FilterHealth, error) {
+		return c.DataStore.GetFilterHealthHistory(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetFilterHealthInRange(deviceID string, start, end time.Time, filterMode models.FilterMode, limit int) ([]models.FilterHealth, error) {
+	return guard(c, func() ([]models.FilterHealth, error) {
+		return c.DataStore.GetFilterHealthInRange(deviceID, start, end, filterMode, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetAllFilterHealth() ([]models.FilterHealth, error) {
+	return guard(c, func() ([]models.FilterHealth, error) {
+		return c.DataStore.GetAllFilterHealth()
+	})
+}
+
+func (c *CircuitBreakerStore) SavePrediction(prediction *models.MLPrediction) error {
+	return c.call(func() error {
+		return c.DataStore.SavePrediction(prediction)
+	})
+}
+
+func (c *CircuitBreakerStore) GetPredictions(predictionType string, limit int) ([]models.MLPrediction, error) {
+	return guard(c, func() ([]models.MLPrediction, error) {
+		return c.DataStore.GetPredictions(predictionType, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetPredictionsByDevice(deviceID string, limit int) ([]models.MLPrediction, error) {
+	return guard(c, func() ([]models.MLPrediction, error) {
+		return c.DataStore.GetPredictionsByDevice(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) SaveSensorPrediction(prediction *models.SensorPrediction) error {
+	return c.call(func() error {
+		return c.DataStore.SaveSensorPrediction(prediction)
+	})
+}
+
+func (c *CircuitBreakerStore) GetSensorPredictions(deviceID string, start, end time.Time) ([]models.SensorPrediction, error) {
+	return guard(c, func() ([]models.SensorPrediction, error) {
+		return c.DataStore.GetSensorPredictions(deviceID, start, end)
+	})
+}
+
+func (c *CircuitBreakerStore) GetConfidentSensorPredictions(deviceID string, minConfidence float64, start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	return guard(c, func() ([]models.SensorPrediction, error) {
+		return c.DataStore.GetConfidentSensorPredictions(deviceID, minConfidence, start, end, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetUnvalidatedSensorPredictions(start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	return guard(c, func() ([]models.SensorPrediction, error) {
+		return c.DataStore.GetUnvalidatedSensorPredictions(start, end, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) UpdateSensorPredictionActuals(prediction *models.SensorPrediction) error {
+	return c.call(func() error {
+		return c.DataStore.UpdateSensorPredictionActuals(prediction)
+	})
+}
+
+func (c *CircuitBreakerStore) SavePredictionAccuracySummary(summary *models.PredictionAccuracySummary) error {
+	return c.call(func() error {
+		return c.DataStore.SavePredictionAccuracySummary(summary)
+	})
+}
+
+func (c *CircuitBreakerStore) GetPredictionAccuracyHistory(deviceID string, limit int) ([]models.PredictionAccuracySummary, error) {
+	return guard(c, func() ([]models.PredictionAccuracySummary, error) {
+		return c.DataStore.GetPredictionAccuracyHistory(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) AddSensorReading(reading models.SensorReading) {
+	if c.breaker.State() != CircuitClosed {
+		log.Printf("⚠️  Warning: Dropping reading from %s: data store circuit is not closed", reading.DeviceID)
+		return
+	}
+	c.DataStore.AddSensorReading(reading)
+}
+
+func (c *CircuitBreakerStore) GetLatestReading() (*models.SensorReading, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.GetLatestReading()
+}
+
+func (c *CircuitBreakerStore) GetLatestReadingByMode(mode models.FilterMode) (*models.SensorReading, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.GetLatestReadingByMode(mode)
+}
+
+func (c *CircuitBreakerStore) GetLatestReadingByDevice(deviceID string) (*models.SensorReading, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.GetLatestReadingByDevice(deviceID)
+}
+
+func (c *CircuitBreakerStore) WaitForNewReading(ctx context.Context, since time.Time) (*models.SensorReading, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.WaitForNewReading(ctx, since)
+}
+
+func (c *CircuitBreakerStore) GetAllLatestReadings() []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, c.DataStore.GetAllLatestReadings)
+}
+
+func (c *CircuitBreakerStore) GetAllLatestReadingsByDevice() map[string]models.SensorReading {
+	return unlessOpen(c, map[string]models.SensorReading{}, c.DataStore.GetAllLatestReadingsByDevice)
+}
+
+func (c *CircuitBreakerStore) GetRecentReadings(limit int) []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, func() []models.SensorReading {
+		return c.DataStore.GetRecentReadings(limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetRecentReadingsByMode(mode models.FilterMode, limit int) []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, func() []models.SensorReading {
+		return c.DataStore.GetRecentReadingsByMode(mode, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetRecentReadingsByDevice(deviceID string, limit int) []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, func() []models.SensorReading {
+		return c.DataStore.GetRecentReadingsByDevice(deviceID, limit)
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingsByDevice(deviceID string) []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, func() []models.SensorReading {
+		return c.DataStore.GetReadingsByDevice(deviceID)
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingsInRange(start, end time.Time) []models.SensorReading {
+	return unlessOpen(c, []models.SensorReading{}, func() []models.SensorReading {
+		return c.DataStore.GetReadingsInRange(start, end)
+	})
+}
+
+func (c *CircuitBreakerStore) GetReadingCount() int {
+	return unlessOpen(c, 0, c.DataStore.GetReadingCount)
+}
+
+func (c *CircuitBreakerStore) GetActiveDevices() []string {
+	return unlessOpen(c, []string{}, c.DataStore.GetActiveDevices)
+}
+
+// GetCurrentFilterMode returns the store's filter mode while the circuit is
+// closed. Otherwise it returns the mode last seen, or FilterModeUnknown, rather
+// than guessing which mode the valve is in.
+func (c *CircuitBreakerStore) GetCurrentFilterMode() models.FilterMode {
+	if c.breaker.State() == CircuitClosed {
+		mode := c.DataStore.GetCurrentFilterMode()
+		c.rememberMode(mode)
+		return mode
+	}
+
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	if c.lastMode == "" {
+		return models.FilterModeUnknown
+	}
+	return c.lastMode
+}
+
+func (c *CircuitBreakerStore) rememberMode(mode models.FilterMode) {
+	c.modeMu.Lock()
+	c.lastMode = mode
+	c.modeMu.Unlock()
+}
+
+func (c *CircuitBreakerStore) SetCurrentFilterMode(mode models.FilterMode) {
+	if c.breaker.State() != CircuitClosed {
+		log.Printf("⚠️  Warning: Not setting filter mode %s: data store circuit is not closed", mode)
+		return
+	}
+	c.DataStore.SetCurrentFilterMode(mode)
+	c.rememberMode(mode)
+}
+
+func (c *CircuitBreakerStore) GetFilterModeTracking() map[string]interface{} {
+	return unlessOpen(c, EmptyFilterModeTracking(), c.DataStore.GetFilterModeTracking)
+}
+
+func (c *CircuitBreakerStore) GetWaterQualityStatus() (*models.WaterQualityStatus, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.GetWaterQualityStatus()
+}
+
+func (c *CircuitBreakerStore) GetWaterQualityStatusByMode(mode models.FilterMode) (*models.WaterQualityStatus, bool) {
+	if c.breaker.State() != CircuitClosed {
+		return nil, false
+	}
+	return c.DataStore.GetWaterQualityStatusByMode(mode)
+}
+
+func (c *CircuitBreakerStore) GetAllWaterQualityStatus() []models.WaterQualityStatus {
+	return unlessOpen(c, []models.WaterQualityStatus{}, c.DataStore.GetAllWaterQualityStatus)
+}
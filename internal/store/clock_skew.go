@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// ClockSkewPolicy decides what happens to a reading whose device timestamp is
+// too far from server time
+type ClockSkewPolicy string
+
+const (
+	ClockSkewReject ClockSkewPolicy = "reject" // Drop the reading
+	ClockSkewClamp  ClockSkewPolicy = "clamp"  // Replace the device timestamp with server time
+	ClockSkewRecord ClockSkewPolicy = "record" // Keep the device timestamp next to the server receive time
+)
+
+// IsValid reports whether the policy is one of the supported policies
+func (p ClockSkewPolicy) IsValid() bool {
+	return p == ClockSkewReject || p == ClockSkewClamp || p == ClockSkewRecord
+}
+
+// ClockSkewError is returned when a reading is rejected for clock skew
+type ClockSkewError struct {
+	DeviceID string
+	Skew     time.Duration // Device time minus server time
+	MaxSkew  time.Duration
+}
+
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("timestamp from device %q is %s off server time (max %s)", e.DeviceID, e.Skew.Round(time.Second), e.MaxSkew)
+}
+
+// ClockSkewCorrector stamps incoming readings with the server receive time and
+// handles device timestamps that deviate from it by more than maxSkew
+type ClockSkewCorrector struct {
+	maxSkew time.Duration
+	policy  ClockSkewPolicy
+}
+
+// NewClockSkewCorrector creates a corrector; a zero maxSkew disables skew
+// handling, and with it device timestamps
+func NewClockSkewCorrector(maxSkew time.Duration, policy ClockSkewPolicy) *ClockSkewCorrector {
+	return &ClockSkewCorrector{
+		maxSkew: maxSkew,
+		policy:  policy,
+	}
+}
+
+// IsEnabled reports whether skewed timestamps are handled
+func (c *ClockSkewCorrector) IsEnabled() bool {
+	return c != nil && c.maxSkew > 0 && c.policy.IsValid()
+}
+
+// Apply sets the reading's server receive time to now. Device timestamps are
+// only trusted while skew handling is enabled; otherwise, or when the device
+// sent none, the reading is stamped with now. When the device timestamp
+// deviates from now by more than the allowed skew the policy is applied:
+// reject returns a *ClockSkewError, clamp moves the timestamp to now and record
+// keeps it as reported. The returned skew is zero unless the timestamp was out
+// of bounds.
+func (c *ClockSkewCorrector) Apply(reading *models.SensorReading, now time.Time) (time.Duration, error) {
+	reading.ServerReceivedAt = &now
+	if reading.Timestamp.IsZero() || !c.IsEnabled() {
+		reading.Timestamp = now
+		return 0, nil
+	}
+
+	skew := reading.Timestamp.Sub(now)
+	if skew <= c.maxSkew && skew >= -c.maxSkew {
+		return 0, nil
+	}
+
+	switch c.policy {
+	case ClockSkewReject:
+		return skew, &ClockSkewError{DeviceID: reading.DeviceID, Skew: skew, MaxSkew: c.maxSkew}
+	case ClockSkewClamp:
+		reading.Timestamp = now
+	}
+	return skew, nil
+}
@@ -0,0 +1,602 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// QueryCounter counts data store calls made on behalf of a single request
+type QueryCounter struct {
+	count atomic.Int64
+}
+
+// Inc records one store call
+func (qc *QueryCounter) Inc() {
+	qc.count.Add(1)
+}
+
+// Count returns the number of store calls recorded so far
+func (qc *QueryCounter) Count() int {
+	return int(qc.count.Load())
+}
+
+type queryCounterKey struct{}
+
+// WithQueryCounter returns a context carrying a fresh query counter
+func WithQueryCounter(ctx context.Context) (context.Context, *QueryCounter) {
+	counter := &QueryCounter{}
+	return context.WithValue(ctx, queryCounterKey{}, counter), counter
+}
+
+// QueryCounterFromContext returns the query counter attached to ctx, if any
+func QueryCounterFromContext(ctx context.Context) (*QueryCounter, bool) {
+	counter, ok := ctx.Value(queryCounterKey{}).(*QueryCounter)
+	return counter, ok
+}
+
+// CountingStore decorates a DataStore, recording every call on a QueryCounter
+// so request handlers issuing many store calls (N+1 patterns) can be detected
+type CountingStore struct {
+	DataStore
+	counter *QueryCounter
+}
+
+var _ DataStore = (*CountingStore)(nil)
+
+// NewCountingStore wraps dataStore so that each call increments counter
+func NewCountingStore(dataStore DataStore, counter *QueryCounter) *CountingStore {
+	return &CountingStore{DataStore: dataStore, counter: counter}
+}
+
+func (c *CountingStore) Ping() error {
+	c.counter.Inc()
+	return c.DataStore.Ping()
+}
+
+func (c *CountingStore) AddSensorReading(reading models.SensorReading) {
+	c.counter.Inc()
+	c.DataStore.AddSensorReading(reading)
+}
+
+func (c *CountingStore) AddSensorReadingsBatch(readings []models.SensorReading) error {
+	c.counter.Inc()
+	return c.DataStore.AddSensorReadingsBatch(readings)
+}
+
+func (c *CountingStore) RecomputeReadingQuality() (int, error) {
+	c.counter.Inc()
+	return c.DataStore.RecomputeReadingQuality()
+}
+
+func (c *CountingStore) GetLatestReading() (*models.SensorReading, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetLatestReading()
+}
+
+func (c *CountingStore) GetLatestReadingByMode(mode models.FilterMode) (*models.SensorReading, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetLatestReadingByMode(mode)
+}
+
+func (c *CountingStore) GetLatestReadingByDevice(deviceID string) (*models.SensorReading, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetLatestReadingByDevice(deviceID)
+}
+
+func (c *CountingStore) WaitForNewReading(ctx context.Context, since time.Time) (*models.SensorReading, bool) {
+	c.counter.Inc()
+	return c.DataStore.WaitForNewReading(ctx, since)
+}
+
+func (c *CountingStore) GetAllLatestReadings() []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetAllLatestReadings()
+}
+
+func (c *CountingStore) GetAllLatestReadingsByDevice() map[string]models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetAllLatestReadingsByDevice()
+}
+
+func (c *CountingStore) GetRecentReadings(limit int) []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetRecentReadings(limit)
+}
+
+func (c *CountingStore) GetRecentReadingsByMode(mode models.FilterMode, limit int) []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetRecentReadingsByMode(mode, limit)
+}
+
+func (c *CountingStore) GetRecentReadingsByDevice(deviceID string, limit int) []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetRecentReadingsByDevice(deviceID, limit)
+}
+
+func (c *CountingStore) GetReadingsByDevice(deviceID string) []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetReadingsByDevice(deviceID)
+}
+
+func (c *CountingStore) GetReadingsInRange(start time.Time, end time.Time) []models.SensorReading {
+	c.counter.Inc()
+	return c.DataStore.GetReadingsInRange(start, end)
+}
+
+func (c *CountingStore) GetReadingsAround(deviceID string, at time.Time, before, after int) ([]models.SensorReading, error) {
+	c.counter.Inc()
+	return c.DataStore.GetReadingsAround(deviceID, at, before, after)
+}
+
+func (c *CountingStore) QueryReadings(filter models.ReadingFilter) ([]models.SensorReading, int, error) {
+	c.counter.Inc()
+	return c.DataStore.QueryReadings(filter)
+}
+
+func (c *CountingStore) QueryReadingsAfter(filter models.ReadingFilter, after *models.ReadingCursor) ([]models.SensorReading, error) {
+	c.counter.Inc()
+	return c.DataStore.QueryReadingsAfter(filter, after)
+}
+
+func (c *CountingStore) GetReadingsPaginated(limit, offset int, deviceID string, mode *models.FilterMode, sortAsc bool) ([]models.SensorReading, int, error) {
+	c.counter.Inc()
+	return c.DataStore.GetReadingsPaginated(limit, offset, deviceID, mode, sortAsc)
+}
+
+func (c *CountingStore) GetMetricHeatmap(metric string, start time.Time, end time.Time) ([]models.HeatmapBucket, error) {
+	c.counter.Inc()
+	return c.DataStore.GetMetricHeatmap(metric, start, end)
+}
+
+func (c *CountingStore) GetMetricHistogram(metric string, start, end time.Time, bins int) (*models.MetricHistogram, error) {
+	c.counter.Inc()
+	return c.DataStore.GetMetricHistogram(metric, start, end, bins)
+}
+
+func (c *CountingStore) GetMetricAggregates(metric, interval string, start, end time.Time) ([]models.AggregateBucket, error) {
+	c.counter.Inc()
+	return c.DataStore.GetMetricAggregates(metric, interval, start, end)
+}
+
+func (c *CountingStore) GetFilterModeCounts() ([]models.FilterModeCount, error) {
+	c.counter.Inc()
+	return c.DataStore.GetFilterModeCounts()
+}
+
+func (c *CountingStore) GetReadingCountsByDevice(start, end time.Time) (map[string]int, error) {
+	c.counter.Inc()
+	return c.DataStore.GetReadingCountsByDevice(start, end)
+}
+
+func (c *CountingStore) GetAvailableDays(start, end time.Time, loc *time.Location) ([]string, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAvailableDays(start, end, loc)
+}
+
+func (c *CountingStore) GetReadingCount() int {
+	c.counter.Inc()
+	return c.DataStore.GetReadingCount()
+}
+
+func (c *CountingStore) GetSensorDataStats(filterMode models.FilterMode) (*models.SensorDataStats, error) {
+	c.counter.Inc()
+	return c.DataStore.GetSensorDataStats(filterMode)
+}
+
+func (c *CountingStore) DeleteAllSensorReadings() error {
+	c.counter.Inc()
+	return c.DataStore.DeleteAllSensorReadings()
+}
+
+func (c *CountingStore) PruneDataBefore(cutoff time.Time, batchSize int) (*models.PruneResult, error) {
+	c.counter.Inc()
+	return c.DataStore.PruneDataBefore(cutoff, batchSize)
+}
+
+func (c *CountingStore) GetActiveDevices() []string {
+	c.counter.Inc()
+	return c.DataStore.GetActiveDevices()
+}
+
+func (c *CountingStore) GetDeviceTypeOverrides() (map[string]string, error) {
+	c.counter.Inc()
+	return c.DataStore.GetDeviceTypeOverrides()
+}
+
+func (c *CountingStore) SetDeviceTypeOverride(deviceID string, deviceType string) error {
+	c.counter.Inc()
+	return c.DataStore.SetDeviceTypeOverride(deviceID, deviceType)
+}
+
+func (c *CountingStore) SetDeviceAPIKeyHash(deviceID string, keyHash string) error {
+	c.counter.Inc()
+	return c.DataStore.SetDeviceAPIKeyHash(deviceID, keyHash)
+}
+
+func (c *CountingStore) GetDeviceAPIKeyHash(deviceID string) (string, bool, error) {
+	c.counter.Inc()
+	return c.DataStore.GetDeviceAPIKeyHash(deviceID)
+}
+
+func (c *CountingStore) CountDeviceAPIKeys() (int, error) {
+	c.counter.Inc()
+	return c.DataStore.CountDeviceAPIKeys()
+}
+
+func (c *CountingStore) GetDeviceStatuses() ([]models.DeviceStatus, error) {
+	c.counter.Inc()
+	return c.DataStore.GetDeviceStatuses()
+}
+
+func (c *CountingStore) GetDeviceStatus(deviceID string) (*models.DeviceStatus, bool, error) {
+	c.counter.Inc()
+	return c.DataStore.GetDeviceStatus(deviceID)
+}
+
+func (c *CountingStore) PatchDeviceStatus(deviceID string, update models.DeviceStatusUpdate) (*models.DeviceStatus, bool, error) {
+	c.counter.Inc()
+	return c.DataStore.PatchDeviceStatus(deviceID, update)
+}
+
+func (c *CountingStore) SetFilterSpec(spec models.FilterSpec) error {
+	c.counter.Inc()
+	return c.DataStore.SetFilterSpec(spec)
+}
+
+func (c *CountingStore) GetFilterSpec(deviceID string) (models.FilterSpec, bool, error) {
+	c.counter.Inc()
+	return c.DataStore.GetFilterSpec(deviceID)
+}
+
+func (c *CountingStore) SetAlertPreferences(prefs models.AlertPreferences) error {
+	c.counter.Inc()
+	return c.DataStore.SetAlertPreferences(prefs)
+}
+
+func (c *CountingStore) GetAlertPreferences(deviceID string) (models.AlertPreferences, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAlertPreferences(deviceID)
+}
+
+func (c *CountingStore) GetProcessedVolume(deviceID string, since time.Time) (float64, error) {
+	c.counter.Inc()
+	return c.DataStore.GetProcessedVolume(deviceID, since)
+}
+
+func (c *CountingStore) GetCurrentFilterMode() models.FilterMode {
+	c.counter.Inc()
+	return c.DataStore.GetCurrentFilterMode()
+}
+
+func (c *CountingStore) SetCurrentFilterMode(mode models.FilterMode) {
+	c.counter.Inc()
+	c.DataStore.SetCurrentFilterMode(mode)
+}
+
+func (c *CountingStore) GetLEDCommand() string {
+	c.counter.Inc()
+	return c.DataStore.GetLEDCommand()
+}
+
+func (c *CountingStore) SetLEDCommand(command string) {
+	c.counter.Inc()
+	c.DataStore.SetLEDCommand(command)
+}
+
+func (c *CountingStore) GetFilterModeTracking() map[string]interface{} {
+	c.counter.Inc()
+	return c.DataStore.GetFilterModeTracking()
+}
+
+func (c *CountingStore) RecordFilterModeChange(change *models.FilterModeChange) error {
+	c.counter.Inc()
+	return c.DataStore.RecordFilterModeChange(change)
+}
+
+func (c *CountingStore) GetFilterModeChanges(limit int) ([]models.FilterModeChange, error) {
+	c.counter.Inc()
+	return c.DataStore.GetFilterModeChanges(limit)
+}
+
+func (c *CountingStore) RecordDeviceCommand(command *models.DeviceCommand) error {
+	c.counter.Inc()
+	return c.DataStore.RecordDeviceCommand(command)
+}
+
+func (c *CountingStore) UpdateDeviceCommandStatus(id int, status, errMsg string) error {
+	c.counter.Inc()
+	return c.DataStore.UpdateDeviceCommandStatus(id, status, errMsg)
+}
+
+func (c *CountingStore) GetDeviceCommands(deviceID string, limit int) ([]models.DeviceCommand, error) {
+	c.counter.Inc()
+	return c.DataStore.GetDeviceCommands(deviceID, limit)
+}
+
+func (c *CountingStore) AddMaintenanceEvent(event *models.MaintenanceEvent) error {
+	c.counter.Inc()
+	return c.DataStore.AddMaintenanceEvent(event)
+}
+
+func (c *CountingStore) GetMaintenanceEvents(deviceID string, limit int) ([]models.MaintenanceEvent, error) {
+	c.counter.Inc()
+	return c.DataStore.GetMaintenanceEvents(deviceID, limit)
+}
+
+func (c *CountingStore) GetWaterQualityStatus() (*models.WaterQualityStatus, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetWaterQualityStatus()
+}
+
+func (c *CountingStore) GetWaterQualityStatusByMode(mode models.FilterMode) (*models.WaterQualityStatus, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetWaterQualityStatusByMode(mode)
+}
+
+func (c *CountingStore) GetAllWaterQualityStatus() []models.WaterQualityStatus {
+	c.counter.Inc()
+	return c.DataStore.GetAllWaterQualityStatus()
+}
+
+func (c *CountingStore) GetFiltrationProcess() (*models.FiltrationProcess, bool) {
+	c.counter.Inc()
+	return c.DataStore.GetFiltrationProcess()
+}
+
+func (c *CountingStore) SetFiltrationProcess(process *models.FiltrationProcess) {
+	c.counter.Inc()
+	c.DataStore.SetFiltrationProcess(process)
+}
+
+func (c *CountingStore) UpdateFiltrationProgress(currentFlowRate float64) {
+	c.counter.Inc()
+	c.DataStore.UpdateFiltrationProgress(currentFlowRate)
+}
+
+func (c *CountingStore) StartFiltrationProcess(mode models.FilterMode, targetVolume float64) {
+	c.counter.Inc()
+	c.DataStore.StartFiltrationProcess(mode, targetVolume)
+}
+
+func (c *CountingStore) CompleteFiltrationProcess() {
+	c.counter.Inc()
+	c.DataStore.CompleteFiltrationProcess()
+}
+
+func (c *CountingStore) ClearFiltrationProcess() {
+	c.counter.Inc()
+	c.DataStore.ClearFiltrationProcess()
+}
+
+func (c *CountingStore) CanChangeFilterMode() (bool, string) {
+	c.counter.Inc()
+	return c.DataStore.CanChangeFilterMode()
+}
+
+func (c *CountingStore) ClearCompletedProcess() {
+	c.counter.Inc()
+	c.DataStore.ClearCompletedProcess()
+}
+
+func (c *CountingStore) CreateSchedule(schedule *models.FilterSchedule) error {
+	c.counter.Inc()
+	return c.DataStore.CreateSchedule(schedule)
+}
+
+func (c *CountingStore) GetSchedule(id int) (*models.FilterSchedule, error) {
+	c.counter.Inc()
+	return c.DataStore.GetSchedule(id)
+}
+
+func (c *CountingStore) GetAllSchedules(activeOnly bool) ([]models.FilterSchedule, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAllSchedules(activeOnly)
+}
+
+func (c *CountingStore) UpdateSchedule(schedule *models.FilterSchedule) error {
+	c.counter.Inc()
+	return c.DataStore.UpdateSchedule(schedule)
+}
+
+func (c *CountingStore) DeleteSchedule(id int) error {
+	c.counter.Inc()
+	return c.DataStore.DeleteSchedule(id)
+}
+
+func (c *CountingStore) ToggleSchedule(id int, isActive bool) error {
+	c.counter.Inc()
+	return c.DataStore.ToggleSchedule(id, isActive)
+}
+
+func (c *CountingStore) CreateScheduleExecution(execution *models.ScheduleExecution) error {
+	c.counter.Inc()
+	return c.DataStore.CreateScheduleExecution(execution)
+}
+
+func (c *CountingStore) GetScheduleExecution(id int) (*models.ScheduleExecution, error) {
+	c.counter.Inc()
+	return c.DataStore.GetScheduleExecution(id)
+}
+
+func (c *CountingStore) GetScheduleExecutions(scheduleID int, limit int) ([]models.ScheduleExecution, error) {
+	c.counter.Inc()
+	return c.DataStore.GetScheduleExecutions(scheduleID, limit)
+}
+
+func (c *CountingStore) GetAllScheduleExecutions(limit int) ([]models.ScheduleExecution, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAllScheduleExecutions(limit)
+}
+
+func (c *CountingStore) UpdateScheduleExecution(execution *models.ScheduleExecution) error {
+	c.counter.Inc()
+	return c.DataStore.UpdateScheduleExecution(execution)
+}
+
+func (c *CountingStore) SaveAnomaly(anomaly *models.AnomalyDetection) error {
+	c.counter.Inc()
+	return c.DataStore.SaveAnomaly(anomaly)
+}
+
+func (c *CountingStore) GetAnomaly(id int) (*models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomaly(id)
+}
+
+func (c *CountingStore) GetAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomalies(limit)
+}
+
+func (c *CountingStore) GetAnomaliesByDevice(deviceID string, limit int) ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomaliesByDevice(deviceID, limit)
+}
+
+func (c *CountingStore) GetAnomaliesBySeverity(severity string, limit int) ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomaliesBySeverity(severity, limit)
+}
+
+func (c *CountingStore) GetAnomaliesInRange(start, end time.Time, filterMode models.FilterMode, limit int) ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomaliesInRange(start, end, filterMode, limit)
+}
+
+func (c *CountingStore) GetUnresolvedAnomalies() ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetUnresolvedAnomalies()
+}
+
+func (c *CountingStore) GetRecentlyResolvedAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	c.counter.Inc()
+	return c.DataStore.GetRecentlyResolvedAnomalies(limit)
+}
+
+func (c *CountingStore) ResolveAnomaly(id int) error {
+	c.counter.Inc()
+	return c.DataStore.ResolveAnomaly(id)
+}
+
+func (c *CountingStore) AutoResolveAnomaly(id int) error {
+	c.counter.Inc()
+	return c.DataStore.AutoResolveAnomaly(id)
+}
+
+func (c *CountingStore) MarkAnomalyFalsePositive(id int) error {
+	c.counter.Inc()
+	return c.DataStore.MarkAnomalyFalsePositive(id)
+}
+
+func (c *CountingStore) SetAnomalyAlertSent(id int, sent bool) error {
+	c.counter.Inc()
+	return c.DataStore.SetAnomalyAlertSent(id, sent)
+}
+
+func (c *CountingStore) GetAnomalyStats() (*models.AnomalyStats, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomalyStats()
+}
+
+func (c *CountingStore) GetAnomalyOpsMetrics() (*models.AnomalyOpsMetrics, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAnomalyOpsMetrics()
+}
+
+func (c *CountingStore) SaveBaseline(baseline *models.SensorBaseline) error {
+	c.counter.Inc()
+	return c.DataStore.SaveBaseline(baseline)
+}
+
+func (c *CountingStore) GetBaseline(deviceID string, filterMode models.FilterMode) (*models.SensorBaseline, error) {
+	c.counter.Inc()
+	return c.DataStore.GetBaseline(deviceID, filterMode)
+}
+
+func (c *CountingStore) GetAllBaselines() ([]models.SensorBaseline, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAllBaselines()
+}
+
+func (c *CountingStore) UpdateBaseline(baseline *models.SensorBaseline) error {
+	c.counter.Inc()
+	return c.DataStore.UpdateBaseline(baseline)
+}
+
+func (c *CountingStore) SaveFilterHealth(health *models.FilterHealth) error {
+	c.counter.Inc()
+	return c.DataStore.SaveFilterHealth(health)
+}
+
+func (c *CountingStore) GetLatestFilterHealth(deviceID string) (*models.FilterHealth, error) {
+	c.counter.Inc()
+	return c.DataStore.GetLatestFilterHealth(deviceID)
+}
+
+func (c *CountingStore) GetFilterHealthHistory(deviceID string, limit int) ([]models.FilterHealth, error) {
+	c.counter.Inc()
+	return c.DataStore.GetFilterHealthHistory(deviceID, limit)
+}
+
+func (c *CountingStore) GetFilterHealthInRange(deviceID string, start, end time.Time, filterMode models.FilterMode, limit int) ([]models.FilterHealth, error) {
+	c.counter.Inc()
+	return c.DataStore.GetFilterHealthInRange(deviceID, start, end, filterMode, limit)
+}
+
+func (c *CountingStore) GetAllFilterHealth() ([]models.FilterHealth, error) {
+	c.counter.Inc()
+	return c.DataStore.GetAllFilterHealth()
+}
+
+func (c *CountingStore) SavePrediction(prediction *models.MLPrediction) error {
+	c.counter.Inc()
+	return c.DataStore.SavePrediction(prediction)
+}
+
+func (c *CountingStore) GetPredictions(predictionType string, limit int) ([]models.MLPrediction, error) {
+	c.counter.Inc()
+	return c.DataStore.GetPredictions(predictionType, limit)
+}
+
+func (c *CountingStore) GetPredictionsByDevice(deviceID string, limit int) ([]models.MLPrediction, error) {
+	c.counter.Inc()
+	return c.DataStore.GetPredictionsByDevice(deviceID, limit)
+}
+
+func (c *CountingStore) SaveSensorPrediction(prediction *models.SensorPrediction) error {
+	c.counter.Inc()
+	return c.DataStore.SaveSensorPrediction(prediction)
+}
+
+func (c *CountingStore) GetSensorPredictions(deviceID string, start, end time.Time) ([]models.SensorPrediction, error) {
+	c.counter.Inc()
+	return c.DataStore.GetSensorPredictions(deviceID, start, end)
+}
+
+func (c *CountingStore) GetConfidentSensorPredictions(deviceID string, minConfidence float64, start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	c.counter.Inc()
+	return c.DataStore.GetConfidentSensorPredictions(deviceID, minConfidence, start, end, limit)
+}
+
+func (c *CountingStore) GetUnvalidatedSensorPredictions(start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	c.counter.Inc()
+	return c.DataStore.GetUnvalidatedSensorPredictions(start, end, limit)
+}
+
+func (c *CountingStore) UpdateSensorPredictionActuals(prediction *models.SensorPrediction) error {
+	c.counter.Inc()
+	return c.DataStore.UpdateSensorPredictionActuals(prediction)
+}
+
+func (c *CountingStore) SavePredictionAccuracySummary(summary *models.PredictionAccuracySummary) error {
+	c.counter.Inc()
+	return c.DataStore.SavePredictionAccuracySummary(summary)
+}
+
+func (c *CountingStore) GetPredictionAccuracyHistory(deviceID string, limit int) ([]models.PredictionAccuracySummary, error) {
+	c.counter.Inc()
+	return c.DataStore.GetPredictionAccuracyHistory(deviceID, limit)
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// ReadingDeduplicator detects readings that repeat a device's previous reading
+// within a short time window (e.g. firmware re-sending the same payload)
+type ReadingDeduplicator struct {
+	store   DataStore
+	enabled bool
+	window  time.Duration
+}
+
+// NewReadingDeduplicator creates a deduplicator backed by the given data store
+func NewReadingDeduplicator(dataStore DataStore, enabled bool, window time.Duration) *ReadingDeduplicator {
+	return &ReadingDeduplicator{
+		store:   dataStore,
+		enabled: enabled,
+		window:  window,
+	}
+}
+
+// IsEnabled reports whether de-duplication is active
+func (d *ReadingDeduplicator) IsEnabled() bool {
+	return d != nil && d.enabled && d.window > 0
+}
+
+// IsDuplicate returns true if the reading is identical to the device's last stored
+// reading and arrived within the configured window. Readings without a device ID
+// are compared against the latest reading overall.
+func (d *ReadingDeduplicator) IsDuplicate(reading models.SensorReading) bool {
+	if !d.IsEnabled() {
+		return false
+	}
+
+	var last *models.SensorReading
+	var exists bool
+	if reading.DeviceID != "" {
+		last, exists = d.store.GetLatestReadingByDevice(reading.DeviceID)
+	} else {
+		last, exists = d.store.GetLatestReading()
+	}
+	if !exists || last == nil {
+		return false
+	}
+
+	if last.DeviceID != reading.DeviceID ||
+		last.FilterMode != reading.FilterMode ||
+		last.Flow != reading.Flow ||
+		last.Ph != reading.Ph ||
+		last.Turbidity != reading.Turbidity ||
+		last.TDS != reading.TDS {
+		return false
+	}
+
+	elapsed := reading.Timestamp.Sub(last.Timestamp)
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+
+	return elapsed <= d.window
+}
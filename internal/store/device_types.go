@@ -0,0 +1,94 @@
+package store
+
+import (
+	"log"
+	"sort"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// defaultDeviceIDs are the devices of the standard STM32 setup
+var defaultDeviceIDs = []string{"stm32_pre", "stm32_post", "stm32_main"}
+
+// KnownDeviceIDs returns the default devices plus any device that has reported readings
+// or has device metadata, sorted by ID
+func KnownDeviceIDs(dataStore DataStore, overrides map[string]string) []string {
+	seen := make(map[string]bool)
+	for _, deviceID := range defaultDeviceIDs {
+		seen[deviceID] = true
+	}
+	for deviceID := range dataStore.GetAllLatestReadingsByDevice() {
+		seen[deviceID] = true
+	}
+	for deviceID := range overrides {
+		seen[deviceID] = true
+	}
+
+	deviceIDs := make([]string, 0, len(seen))
+	for deviceID := range seen {
+		if deviceID != "" {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+	}
+	sort.Strings(deviceIDs)
+	return deviceIDs
+}
+
+// DeviceIDs returns the known devices of the store as KnownDeviceIDs does; when
+// the type overrides can't be loaded only devices with readings are added
+func DeviceIDs(dataStore DataStore) []string {
+	overrides, err := dataStore.GetDeviceTypeOverrides()
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to load device type overrides: %v", err)
+		overrides = nil
+	}
+	return KnownDeviceIDs(dataStore, overrides)
+}
+
+// ClassifyDevices returns the type classification of every known device
+func ClassifyDevices(dataStore DataStore) ([]models.DeviceTypeInfo, error) {
+	overrides, err := dataStore.GetDeviceTypeOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	deviceIDs := KnownDeviceIDs(dataStore, overrides)
+	devices := make([]models.DeviceTypeInfo, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		devices = append(devices, models.ClassifyDeviceType(deviceID, overrides))
+	}
+	return devices, nil
+}
+
+// ResolveFilterDevices returns the device IDs used as pre- and post-filtration sensors.
+// Devices classified through a metadata override take precedence over inferred ones;
+// falls back to stm32_pre/stm32_post when no device matches.
+func ResolveFilterDevices(dataStore DataStore) (preDeviceID, postDeviceID string) {
+	preDeviceID, postDeviceID = "stm32_pre", "stm32_post"
+
+	devices, err := ClassifyDevices(dataStore)
+	if err != nil {
+		return preDeviceID, postDeviceID
+	}
+
+	pick := func(deviceType string, fallback string) string {
+		inferred := ""
+		for _, device := range devices {
+			if device.DeviceType != deviceType {
+				continue
+			}
+			if device.Overridden {
+				return device.DeviceID
+			}
+			if inferred == "" {
+				inferred = device.DeviceID
+			}
+		}
+		if inferred != "" {
+			return inferred
+		}
+		return fallback
+	}
+
+	return pick(models.DeviceTypePreFiltration, preDeviceID), pick(models.DeviceTypePostFiltration, postDeviceID)
+}
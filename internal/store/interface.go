@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"time"
+
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 )
 
@@ -10,10 +12,13 @@ type DataStore interface {
 	// Health check
 	Ping() error
 	
-	AddSensorReading(models.SensorReading)
+	AddSensorReading(models.SensorReading)               // Stores the reading with its quality assessment
+	AddSensorReadingsBatch([]models.SensorReading) error // AddSensorReading for many readings at once; all or nothing
+	RecomputeReadingQuality() (int, error)               // Re-assesses readings stored under other quality rules
 	GetLatestReading() (*models.SensorReading, bool)
 	GetLatestReadingByMode(models.FilterMode) (*models.SensorReading, bool)
 	GetLatestReadingByDevice(string) (*models.SensorReading, bool)
+	WaitForNewReading(ctx context.Context, since time.Time) (*models.SensorReading, bool)
 	GetAllLatestReadings() []models.SensorReading
 	GetAllLatestReadingsByDevice() map[string]models.SensorReading
 	GetRecentReadings(int) []models.SensorReading
@@ -21,12 +26,58 @@ type DataStore interface {
 	GetRecentReadingsByDevice(string, int) []models.SensorReading
 	GetReadingsByDevice(string) []models.SensorReading
 	GetReadingsInRange(time.Time, time.Time) []models.SensorReading
+	GetReadingsAround(deviceID string, at time.Time, before, after int) ([]models.SensorReading, error) // Chronological, up to before at/earlier than at and after later
+	QueryReadings(models.ReadingFilter) ([]models.SensorReading, int, error) // Page of matches plus total match count
+	QueryReadingsAfter(filter models.ReadingFilter, after *models.ReadingCursor) ([]models.SensorReading, error) // Keyset page: up to filter.Limit matches past the cursor (nil = first page), Offset ignored
+	GetReadingsPaginated(limit, offset int, deviceID string, mode *models.FilterMode, sortAsc bool) ([]models.SensorReading, int, error) // Page plus total after filters; empty deviceID / nil mode match all
+	GetMetricHeatmap(metric string, start, end time.Time) ([]models.HeatmapBucket, error)
+	GetMetricHistogram(metric string, start, end time.Time, bins int) (*models.MetricHistogram, error)
+	GetMetricAggregates(metric, interval string, start, end time.Time) ([]models.AggregateBucket, error) // Oldest bucket first
+	GetFilterModeCounts() ([]models.FilterModeCount, error) // Every mode present in the readings, ordered by mode
+	GetReadingCountsByDevice(start, end time.Time) (map[string]int, error) // Readings per device with a timestamp in [start, end]; devices without any are omitted
+	GetAvailableDays(start, end time.Time, loc *time.Location) ([]string, error) // Dates (YYYY-MM-DD in loc) with a reading in [start, end], oldest first
 	GetReadingCount() int
+	GetSensorDataStats(filterMode models.FilterMode) (*models.SensorDataStats, error) // "" = every mode; nil without readings
 	DeleteAllSensorReadings() error
+	PruneDataBefore(cutoff time.Time, batchSize int) (*models.PruneResult, error) // Deletes readings, anomalies and sensor predictions older than cutoff, batchSize rows per statement
 	GetActiveDevices() []string
+
+	// Device status: profile and operational state of each device that sent readings
+	GetDeviceStatuses() ([]models.DeviceStatus, error) // Ordered by device ID
+	GetDeviceStatus(deviceID string) (*models.DeviceStatus, bool, error)
+	PatchDeviceStatus(deviceID string, update models.DeviceStatusUpdate) (*models.DeviceStatus, bool, error) // false for an unknown device
+
+	// Device metadata: per-device type classification overrides
+	GetDeviceTypeOverrides() (map[string]string, error)
+	SetDeviceTypeOverride(deviceID string, deviceType string) error
+
+	// Device credentials: hashed API keys checked on sensor ingestion
+	SetDeviceAPIKeyHash(deviceID string, keyHash string) error
+	GetDeviceAPIKeyHash(deviceID string) (string, bool, error)
+	CountDeviceAPIKeys() (int, error)
+
+	// Filter metadata: rated capacity, cost and install date of a device's filter
+	SetFilterSpec(spec models.FilterSpec) error
+	GetFilterSpec(deviceID string) (models.FilterSpec, bool, error)
+	GetProcessedVolume(deviceID string, since time.Time) (float64, error) // Estimated liters that flowed through the device since a time
+
+	// Alert preferences: anomaly metrics and severities muted per device
+	SetAlertPreferences(prefs models.AlertPreferences) error
+	GetAlertPreferences(deviceID string) (models.AlertPreferences, error) // Defaults (nothing muted) when none were set
+
+	// Filter mode, device commands and water quality status
 	GetCurrentFilterMode() models.FilterMode
 	SetCurrentFilterMode(models.FilterMode)
 	GetFilterModeTracking() map[string]interface{}
+	GetLEDCommand() string // LED state the STM32 picks up when it polls
+	SetLEDCommand(command string)
+	RecordFilterModeChange(*models.FilterModeChange) error
+	GetFilterModeChanges(limit int) ([]models.FilterModeChange, error) // Newest first
+	RecordDeviceCommand(*models.DeviceCommand) error
+	UpdateDeviceCommandStatus(id int, status, errMsg string) error
+	GetDeviceCommands(deviceID string, limit int) ([]models.DeviceCommand, error) // Newest first
+	AddMaintenanceEvent(*models.MaintenanceEvent) error
+	GetMaintenanceEvents(deviceID string, limit int) ([]models.MaintenanceEvent, error) // Most recently performed first
 	GetWaterQualityStatus() (*models.WaterQualityStatus, bool)
 	GetWaterQualityStatusByMode(models.FilterMode) (*models.WaterQualityStatus, bool)
 	GetAllWaterQualityStatus() []models.WaterQualityStatus
@@ -58,13 +109,19 @@ type DataStore interface {
 
 	// ML: Anomaly Detection
 	SaveAnomaly(*models.AnomalyDetection) error
+	GetAnomaly(id int) (*models.AnomalyDetection, error) // nil, nil when not found
 	GetAnomalies(limit int) ([]models.AnomalyDetection, error)
 	GetAnomaliesByDevice(deviceID string, limit int) ([]models.AnomalyDetection, error)
 	GetAnomaliesBySeverity(severity string, limit int) ([]models.AnomalyDetection, error)
+	GetAnomaliesInRange(start, end time.Time, filterMode models.FilterMode, limit int) ([]models.AnomalyDetection, error) // Detected in [start, end], newest first; "" = every mode
 	GetUnresolvedAnomalies() ([]models.AnomalyDetection, error)
+	GetRecentlyResolvedAnomalies(limit int) ([]models.AnomalyDetection, error) // Latest resolved first; false positives excluded
 	ResolveAnomaly(id int) error
+	AutoResolveAnomaly(id int) error // Resolved by the system rather than a user
 	MarkAnomalyFalsePositive(id int) error
+	SetAnomalyAlertSent(id int, sent bool) error // Corrects alert_sent once the alert's delivery outcome is known
 	GetAnomalyStats() (*models.AnomalyStats, error)
+	GetAnomalyOpsMetrics() (*models.AnomalyOpsMetrics, error)
 
 	// ML: Sensor Baselines
 	SaveBaseline(*models.SensorBaseline) error
@@ -76,10 +133,22 @@ type DataStore interface {
 	SaveFilterHealth(*models.FilterHealth) error
 	GetLatestFilterHealth(deviceID string) (*models.FilterHealth, error)
 	GetFilterHealthHistory(deviceID string, limit int) ([]models.FilterHealth, error)
+	GetFilterHealthInRange(deviceID string, start, end time.Time, filterMode models.FilterMode, limit int) ([]models.FilterHealth, error) // Calculated in [start, end], newest first; "" = every mode
 	GetAllFilterHealth() ([]models.FilterHealth, error)
 
 	// ML: Predictions
 	SavePrediction(*models.MLPrediction) error
 	GetPredictions(predictionType string, limit int) ([]models.MLPrediction, error)
 	GetPredictionsByDevice(deviceID string, limit int) ([]models.MLPrediction, error)
+
+	// ML: Sensor Predictions
+	SaveSensorPrediction(*models.SensorPrediction) error // Replaces an unvalidated prediction for the same device, mode and time
+	GetSensorPredictions(deviceID string, start, end time.Time) ([]models.SensorPrediction, error) // By predicted_for, oldest first
+	GetConfidentSensorPredictions(deviceID string, minConfidence float64, start, end time.Time, limit int) ([]models.SensorPrediction, error) // Confidence >= minConfidence, by predicted_for
+	GetUnvalidatedSensorPredictions(start, end time.Time, limit int) ([]models.SensorPrediction, error) // Due for validation, oldest first
+	UpdateSensorPredictionActuals(*models.SensorPrediction) error
+
+	// ML: Prediction Accuracy
+	SavePredictionAccuracySummary(*models.PredictionAccuracySummary) error
+	GetPredictionAccuracyHistory(deviceID string, limit int) ([]models.PredictionAccuracySummary, error)
 }
\ No newline at end of file
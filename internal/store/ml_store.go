@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,9 +15,13 @@ type mlStore struct {
 	baselines      map[string]*models.SensorBaseline // key: "deviceID:filterMode"
 	filterHealth   []models.FilterHealth
 	predictions    []models.MLPrediction
+	accuracy       []models.PredictionAccuracySummary
+	sensorPreds    []models.SensorPrediction // Sorted by predicted_for
 	nextAnomalyID  int
 	nextHealthID   int
 	nextPredID     int
+	nextAccuracyID int
+	nextSensorPredID int
 	mu             sync.RWMutex
 }
 
@@ -26,9 +31,12 @@ func newMLStore() *mlStore {
 		baselines:    make(map[string]*models.SensorBaseline),
 		filterHealth: []models.FilterHealth{},
 		predictions:  []models.MLPrediction{},
+		accuracy:     []models.PredictionAccuracySummary{},
 		nextAnomalyID: 1,
 		nextHealthID: 1,
 		nextPredID:   1,
+		nextAccuracyID: 1,
+		nextSensorPredID: 1,
 	}
 }
 
@@ -46,6 +54,20 @@ func (s *Store) SaveAnomaly(anomaly *models.AnomalyDetection) error {
 	return nil
 }
 
+func (s *Store) GetAnomaly(id int) (*models.AnomalyDetection, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	for _, a := range s.mlData.anomalies {
+		if a.ID == id {
+			anomaly := a
+			return &anomaly, nil
+		}
+	}
+
+	return nil, nil
+}
+
 func (s *Store) GetAnomalies(limit int) ([]models.AnomalyDetection, error) {
 	s.mlData.mu.RLock()
 	defer s.mlData.mu.RUnlock()
@@ -95,6 +117,24 @@ func (s *Store) GetAnomaliesBySeverity(severity string, limit int) ([]models.Ano
 	return result, nil
 }
 
+func (s *Store) GetAnomaliesInRange(start, end time.Time, filterMode models.FilterMode, limit int) ([]models.AnomalyDetection, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	var result []models.AnomalyDetection
+	for i := len(s.mlData.anomalies) - 1; i >= 0 && len(result) < limit; i-- {
+		anomaly := s.mlData.anomalies[i]
+		if anomaly.DetectedAt.Before(start) || anomaly.DetectedAt.After(end) {
+			continue
+		}
+		if filterMode == "" || anomaly.FilterMode == filterMode {
+			result = append(result, anomaly)
+		}
+	}
+
+	return result, nil
+}
+
 func (s *Store) GetUnresolvedAnomalies() ([]models.AnomalyDetection, error) {
 	s.mlData.mu.RLock()
 	defer s.mlData.mu.RUnlock()
@@ -109,7 +149,35 @@ func (s *Store) GetUnresolvedAnomalies() ([]models.AnomalyDetection, error) {
 	return result, nil
 }
 
+func (s *Store) GetRecentlyResolvedAnomalies(limit int) ([]models.AnomalyDetection, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	var result []models.AnomalyDetection
+	for _, a := range s.mlData.anomalies {
+		if a.ResolvedAt != nil && !a.IsFalsePositive {
+			result = append(result, a)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ResolvedAt.After(*result[j].ResolvedAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
 func (s *Store) ResolveAnomaly(id int) error {
+	return s.resolveAnomaly(id, false)
+}
+
+func (s *Store) AutoResolveAnomaly(id int) error {
+	return s.resolveAnomaly(id, true)
+}
+
+func (s *Store) resolveAnomaly(id int, auto bool) error {
 	s.mlData.mu.Lock()
 	defer s.mlData.mu.Unlock()
 
@@ -117,6 +185,7 @@ func (s *Store) ResolveAnomaly(id int) error {
 		if s.mlData.anomalies[i].ID == id {
 			now := time.Now()
 			s.mlData.anomalies[i].ResolvedAt = &now
+			s.mlData.anomalies[i].AutoResolved = auto
 			return nil
 		}
 	}
@@ -140,6 +209,20 @@ func (s *Store) MarkAnomalyFalsePositive(id int) error {
 	return fmt.Errorf("anomaly not found")
 }
 
+func (s *Store) SetAnomalyAlertSent(id int, sent bool) error {
+	s.mlData.mu.Lock()
+	defer s.mlData.mu.Unlock()
+
+	for i := range s.mlData.anomalies {
+		if s.mlData.anomalies[i].ID == id {
+			s.mlData.anomalies[i].AlertSent = sent
+			return nil
+		}
+	}
+
+	return fmt.Errorf("anomaly not found")
+}
+
 func (s *Store) GetAnomalyStats() (*models.AnomalyStats, error) {
 	s.mlData.mu.RLock()
 	defer s.mlData.mu.RUnlock()
@@ -202,6 +285,41 @@ func (s *Store) GetAnomalyStats() (*models.AnomalyStats, error) {
 	return stats, nil
 }
 
+func (s *Store) GetAnomalyOpsMetrics() (*models.AnomalyOpsMetrics, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	metrics := &models.AnomalyOpsMetrics{TotalAnomalies: len(s.mlData.anomalies)}
+	var totalResolution time.Duration
+
+	for _, a := range s.mlData.anomalies {
+		switch {
+		case a.IsFalsePositive:
+			metrics.FalsePositives++
+		case a.ResolvedAt != nil:
+			metrics.Resolved++
+			if a.AutoResolved {
+				metrics.AutoResolved++
+			} else {
+				metrics.ManuallyResolved++
+			}
+			totalResolution += a.ResolvedAt.Sub(a.DetectedAt)
+		default:
+			metrics.Unresolved++
+		}
+	}
+
+	if metrics.TotalAnomalies > 0 {
+		metrics.ResolutionRate = float64(metrics.Resolved) / float64(metrics.TotalAnomalies) * 100
+		metrics.FalsePositiveRate = float64(metrics.FalsePositives) / float64(metrics.TotalAnomalies) * 100
+	}
+	if metrics.Resolved > 0 {
+		metrics.MeanTimeToResolution = totalResolution.Seconds() / float64(metrics.Resolved)
+	}
+
+	return metrics, nil
+}
+
 // ML: Sensor Baseline Methods
 
 func (s *Store) SaveBaseline(baseline *models.SensorBaseline) error {
@@ -287,6 +405,24 @@ func (s *Store) GetFilterHealthHistory(deviceID string, limit int) ([]models.Fil
 	return result, nil
 }
 
+func (s *Store) GetFilterHealthInRange(deviceID string, start, end time.Time, filterMode models.FilterMode, limit int) ([]models.FilterHealth, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	var result []models.FilterHealth
+	for i := len(s.mlData.filterHealth) - 1; i >= 0 && len(result) < limit; i-- {
+		health := s.mlData.filterHealth[i]
+		if health.DeviceID != deviceID || health.LastCalculated.Before(start) || health.LastCalculated.After(end) {
+			continue
+		}
+		if filterMode == "" || health.FilterMode == filterMode {
+			result = append(result, health)
+		}
+	}
+
+	return result, nil
+}
+
 func (s *Store) GetAllFilterHealth() ([]models.FilterHealth, error) {
 	s.mlData.mu.RLock()
 	defer s.mlData.mu.RUnlock()
@@ -338,3 +474,157 @@ func (s *Store) GetPredictionsByDevice(deviceID string, limit int) ([]models.MLP
 
 	return result, nil
 }
+
+// ML: Sensor Prediction Methods
+
+func (s *Store) SaveSensorPrediction(prediction *models.SensorPrediction) error {
+	s.mlData.mu.Lock()
+	defer s.mlData.mu.Unlock()
+
+	now := time.Now()
+	preds := s.mlData.sensorPreds
+	i := sort.Search(len(preds), func(i int) bool {
+		return !preds[i].PredictedFor.Before(prediction.PredictedFor)
+	})
+	for j := i; j < len(preds) && preds[j].PredictedFor.Equal(prediction.PredictedFor); j++ {
+		if preds[j].DeviceID != prediction.DeviceID || preds[j].FilterMode != prediction.FilterMode {
+			continue
+		}
+		if preds[j].IsValidated {
+			// Keep the validated prediction and its actuals
+			*prediction = preds[j]
+			return nil
+		}
+		prediction.ID = preds[j].ID
+		prediction.CreatedAt = preds[j].CreatedAt
+		prediction.UpdatedAt = now
+		preds[j] = *prediction
+		return nil
+	}
+
+	prediction.ID = s.mlData.nextSensorPredID
+	s.mlData.nextSensorPredID++
+	prediction.CreatedAt = now
+	prediction.UpdatedAt = now
+
+	preds = append(preds, models.SensorPrediction{})
+	copy(preds[i+1:], preds[i:])
+	preds[i] = *prediction
+	s.mlData.sensorPreds = preds
+	return nil
+}
+
+func (s *Store) GetSensorPredictions(deviceID string, start, end time.Time) ([]models.SensorPrediction, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	result := []models.SensorPrediction{}
+	for _, prediction := range s.mlData.sensorPreds {
+		if prediction.DeviceID == deviceID && !prediction.PredictedFor.Before(start) && !prediction.PredictedFor.After(end) {
+			result = append(result, prediction)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Store) GetConfidentSensorPredictions(deviceID string, minConfidence float64, start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	result := []models.SensorPrediction{}
+	for _, prediction := range s.mlData.sensorPreds {
+		if prediction.DeviceID == deviceID && prediction.ConfidenceScore >= minConfidence &&
+			!prediction.PredictedFor.Before(start) && !prediction.PredictedFor.After(end) {
+			result = append(result, prediction)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].PredictedFor.Before(result[j].PredictedFor)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Store) GetUnvalidatedSensorPredictions(start, end time.Time, limit int) ([]models.SensorPrediction, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	result := []models.SensorPrediction{}
+	for _, prediction := range s.mlData.sensorPreds {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if !prediction.IsValidated && !prediction.PredictedFor.Before(start) && !prediction.PredictedFor.After(end) {
+			result = append(result, prediction)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Store) UpdateSensorPredictionActuals(prediction *models.SensorPrediction) error {
+	s.mlData.mu.Lock()
+	defer s.mlData.mu.Unlock()
+
+	for i := range s.mlData.sensorPreds {
+		if s.mlData.sensorPreds[i].ID == prediction.ID {
+			s.mlData.sensorPreds[i] = *prediction
+			return nil
+		}
+	}
+
+	return fmt.Errorf("sensor prediction %d not found", prediction.ID)
+}
+
+// ML: Prediction Accuracy Methods
+
+func (s *Store) SavePredictionAccuracySummary(summary *models.PredictionAccuracySummary) error {
+	s.mlData.mu.Lock()
+	defer s.mlData.mu.Unlock()
+
+	summary.UpdatedAt = time.Now()
+
+	// Replace the summary for the same device/mode/period
+	for i, existing := range s.mlData.accuracy {
+		if existing.DeviceID == summary.DeviceID && existing.FilterMode == summary.FilterMode &&
+			existing.PeriodStart.Equal(summary.PeriodStart) {
+			summary.ID = existing.ID
+			summary.CreatedAt = existing.CreatedAt
+			s.mlData.accuracy[i] = *summary
+			return nil
+		}
+	}
+
+	summary.ID = s.mlData.nextAccuracyID
+	s.mlData.nextAccuracyID++
+	summary.CreatedAt = time.Now()
+
+	s.mlData.accuracy = append(s.mlData.accuracy, *summary)
+	return nil
+}
+
+func (s *Store) GetPredictionAccuracyHistory(deviceID string, limit int) ([]models.PredictionAccuracySummary, error) {
+	s.mlData.mu.RLock()
+	defer s.mlData.mu.RUnlock()
+
+	result := []models.PredictionAccuracySummary{}
+	for _, summary := range s.mlData.accuracy {
+		if deviceID == "" || summary.DeviceID == deviceID {
+			result = append(result, summary)
+		}
+	}
+
+	// Chronological order, keeping the most recent periods
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PeriodStart.Before(result[j].PeriodStart)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+
+	return result, nil
+}
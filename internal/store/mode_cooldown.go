@@ -0,0 +1,72 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// systemDeviceKey tracks mode changes when no active device is known
+const systemDeviceKey = ""
+
+// ModeChangeCooldown enforces a minimum interval between filter mode changes on
+// the same device, protecting valves from rapid back-and-forth switching
+type ModeChangeCooldown struct {
+	minInterval time.Duration
+	mu          sync.Mutex
+	lastChange  map[string]time.Time // key: device ID
+}
+
+// NewModeChangeCooldown creates a cooldown tracker; a zero interval disables it
+func NewModeChangeCooldown(minInterval time.Duration) *ModeChangeCooldown {
+	return &ModeChangeCooldown{
+		minInterval: minInterval,
+		lastChange:  make(map[string]time.Time),
+	}
+}
+
+// IsEnabled reports whether the cooldown is active
+func (c *ModeChangeCooldown) IsEnabled() bool {
+	return c != nil && c.minInterval > 0
+}
+
+// MinInterval returns the configured minimum interval between changes
+func (c *ModeChangeCooldown) MinInterval() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.minInterval
+}
+
+// Reserve records a mode change on the given devices at now if none of them
+// changed mode within the cooldown. Otherwise nothing is recorded and the
+// longest remaining wait across the devices is returned.
+func (c *ModeChangeCooldown) Reserve(deviceIDs []string, now time.Time) (time.Duration, bool) {
+	if !c.IsEnabled() {
+		return 0, true
+	}
+	if len(deviceIDs) == 0 {
+		deviceIDs = []string{systemDeviceKey}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var remaining time.Duration
+	for _, deviceID := range deviceIDs {
+		last, exists := c.lastChange[deviceID]
+		if !exists {
+			continue
+		}
+		if wait := c.minInterval - now.Sub(last); wait > remaining {
+			remaining = wait
+		}
+	}
+	if remaining > 0 {
+		return remaining, false
+	}
+
+	for _, deviceID := range deviceIDs {
+		c.lastChange[deviceID] = now
+	}
+	return 0, true
+}
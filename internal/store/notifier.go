@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// ReadingNotifier lets callers block until a new sensor reading is ingested.
+// Each Notify closes the current channel and starts a new one, so waiters
+// never hold a lock while checking for new readings.
+type ReadingNotifier struct {
+	mu      sync.Mutex
+	changed chan struct{} // Closed on the next Notify
+}
+
+// NewReadingNotifier creates a new reading notifier
+func NewReadingNotifier() *ReadingNotifier {
+	return &ReadingNotifier{changed: make(chan struct{})}
+}
+
+// Notify wakes up all waiters after a reading has been stored
+func (n *ReadingNotifier) Notify() {
+	n.mu.Lock()
+	close(n.changed)
+	n.changed = make(chan struct{})
+	n.mu.Unlock()
+}
+
+// next returns the channel closed by the next Notify
+func (n *ReadingNotifier) next() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changed
+}
+
+// Wait blocks until latest returns a reading newer than since or the context is done.
+// Returns false if the context expired before a newer reading arrived.
+func (n *ReadingNotifier) Wait(ctx context.Context, since time.Time, latest func() (*models.SensorReading, bool)) (*models.SensorReading, bool) {
+	for {
+		// Take the channel before checking so a reading stored in between
+		// still wakes us
+		changed := n.next()
+		if reading, exists := latest(); exists && reading.Timestamp.After(since) {
+			return reading, true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"iter"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+)
+
+// readingRing is a fixed-capacity ring buffer of sensor readings. Once full,
+// each push overwrites the oldest reading, so inserts are O(1) and memory stays
+// bounded by the capacity allocated up front.
+type readingRing struct {
+	buf  []models.SensorReading
+	head int // Index of the oldest reading
+	size int
+}
+
+// newReadingRing creates an empty ring holding at most capacity readings
+func newReadingRing(capacity int) *readingRing {
+	return &readingRing{buf: make([]models.SensorReading, capacity)}
+}
+
+// push appends a reading, evicting the oldest one when the ring is full
+func (r *readingRing) push(reading models.SensorReading) {
+	if r.size < len(r.buf) {
+		r.buf[(r.head+r.size)%len(r.buf)] = reading
+		r.size++
+		return
+	}
+	r.buf[r.head] = reading
+	r.head = (r.head + 1) % len(r.buf)
+}
+
+// len returns the number of readings currently stored
+func (r *readingRing) len() int {
+	return r.size
+}
+
+// capacity returns the maximum number of readings the ring holds
+func (r *readingRing) capacity() int {
+	return len(r.buf)
+}
+
+// all iterates over stored readings from oldest to newest
+func (r *readingRing) all() iter.Seq[models.SensorReading] {
+	return func(yield func(models.SensorReading) bool) {
+		for i := 0; i < r.size; i++ {
+			if !yield(r.buf[(r.head+i)%len(r.buf)]) {
+				return
+			}
+		}
+	}
+}
+
+// update calls fn with a pointer to each stored reading, oldest first, so it can be modified in place
+func (r *readingRing) update(fn func(*models.SensorReading)) {
+	for i := 0; i < r.size; i++ {
+		fn(&r.buf[(r.head+i)%len(r.buf)])
+	}
+}
+
+// removeBefore removes readings with a timestamp before cutoff, keeping the
+// rest in order, and returns how many were removed
+func (r *readingRing) removeBefore(cutoff time.Time) int {
+	kept := make([]models.SensorReading, 0, r.size)
+	for reading := range r.all() {
+		if !reading.Timestamp.Before(cutoff) {
+			kept = append(kept, reading)
+		}
+	}
+	removed := r.size - len(kept)
+	if removed == 0 {
+		return 0
+	}
+
+	r.reset()
+	for _, reading := range kept {
+		r.push(reading)
+	}
+	return removed
+}
+
+// reset removes all readings, keeping the allocated buffer
+func (r *readingRing) reset() {
+	clear(r.buf)
+	r.head = 0
+	r.size = 0
+}
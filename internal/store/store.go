@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"sort"
 	"sync"
 	"time"
@@ -9,17 +11,36 @@ import (
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 )
 
+// Bounds of the in-memory audit logs; the oldest entries are dropped first
+const (
+	maxModeChanges    = 1000
+	maxDeviceCommands = 1000
+)
+
 // Store manages sensor data storage and retrieval for filtration system
 type Store struct {
 	mu                      sync.RWMutex
-	sensorReadings          []models.SensorReading
+	sensorReadings          *readingRing                    // Bounded buffer of the most recent readings
 	latestReading           *models.SensorReading           // Latest reading overall
 	latestByMode            map[models.FilterMode]*models.SensorReading // Latest reading per filter mode
 	latestByDevice          map[string]*models.SensorReading // Latest reading per device
 	currentFilterMode       models.FilterMode               // Current active filter mode
+	ledCommand              string                          // LED state the STM32 picks up when it polls
 	filtrationProcess       *models.FiltrationProcess       // Current filtration process state
 	maxReadings             int
 	mlData                  *mlStore                        // ML-related data storage
+	notifier                *ReadingNotifier                // Wakes long-poll waiters on new readings
+	deviceTypeOverrides     map[string]string                // Device type classification overrides
+	deviceProfiles          map[string]deviceProfile         // Device names and activation by device ID
+	deviceKeyHashes         map[string]string                // Hashed ingestion API keys by device ID
+	filterSpecs             map[string]models.FilterSpec     // Installed filter metadata by device ID
+	alertPrefs              map[string]models.AlertPreferences // Muted anomaly alerts by device ID
+	modeChanges             []models.FilterModeChange        // Filter mode change audit log
+	nextModeChangeID        int
+	deviceCommands          []models.DeviceCommand           // Commands sent to devices, oldest first
+	nextDeviceCommandID     int
+	maintenanceEvents       []models.MaintenanceEvent        // Maintenance log, oldest performed first
+	nextMaintenanceEventID  int
 }
 
 // NewStore creates a new in-memory store
@@ -29,13 +50,23 @@ func NewStore(maxReadings int) *Store {
 	}
 
 	return &Store{
-		sensorReadings:    make([]models.SensorReading, 0, maxReadings),
+		sensorReadings:    newReadingRing(maxReadings),
 		latestReading:     nil,
 		latestByMode:      make(map[models.FilterMode]*models.SensorReading),
 		latestByDevice:    make(map[string]*models.SensorReading),
 		currentFilterMode: models.FilterModeDrinking, // Default to drinking water mode
+		ledCommand:        models.LEDCommandOff,
 		maxReadings:       maxReadings,
 		mlData:            newMLStore(),              // Initialize ML data storage
+		notifier:          NewReadingNotifier(),
+		deviceTypeOverrides: make(map[string]string),
+		deviceProfiles:    make(map[string]deviceProfile),
+		deviceKeyHashes:   make(map[string]string),
+		filterSpecs:       make(map[string]models.FilterSpec),
+		alertPrefs:        make(map[string]models.AlertPreferences),
+		nextModeChangeID:  1,
+		nextDeviceCommandID: 1,
+		nextMaintenanceEventID: 1,
 	}
 }
 
@@ -46,34 +77,75 @@ func (s *Store) Ping() error {
 
 // AddSensorReading stores a new sensor reading
 func (s *Store) AddSensorReading(reading models.SensorReading) {
+	// Notify waiters once the store lock has been released
+	defer s.notifier.Notify()
+
+	// Store the quality assessment with the reading
+	reading.StampQuality()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Add to readings slice
-	s.sensorReadings = append(s.sensorReadings, reading)
-
-	// Maintain maximum size by removing oldest entries
-	if len(s.sensorReadings) > s.maxReadings {
-		s.sensorReadings = s.sensorReadings[1:]
-	}
+	// Add to the ring buffer (evicts the oldest reading once at capacity)
+	s.sensorReadings.push(reading)
 
-	// Update latest reading overall
+	// Update latest reading overall, per filter mode and per device. The entries
+	// share one copy since readers only ever copy out of them.
 	s.latestReading = &reading
-
-	// Update latest reading for this filter mode
-	readingCopy := reading
-	s.latestByMode[reading.FilterMode] = &readingCopy
-
-	// Update latest reading for this device
+	s.latestByMode[reading.FilterMode] = &reading
 	if reading.DeviceID != "" {
-		deviceCopy := reading
-		s.latestByDevice[reading.DeviceID] = &deviceCopy
+		s.latestByDevice[reading.DeviceID] = &reading
 	}
 
 	// Note: Do NOT update currentFilterMode here - it should only be set via SetCurrentFilterMode()
 	// This allows manual filter mode changes via API to persist even when sensor data arrives
 }
 
+// AddSensorReadingsBatch stores the readings in order; adding to memory can't fail
+func (s *Store) AddSensorReadingsBatch(readings []models.SensorReading) error {
+	for _, reading := range readings {
+		s.AddSensorReading(reading)
+	}
+	return nil
+}
+
+// RecomputeReadingQuality re-assesses stored readings whose quality was computed
+// under other quality rules, returning how many were updated
+func (s *Store) RecomputeReadingQuality() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := 0
+	restamp := func(reading *models.SensorReading) {
+		if !reading.HasCurrentQuality() {
+			reading.StampQuality()
+			updated++
+		}
+	}
+	s.sensorReadings.update(restamp)
+
+	// The latest-reading entries point at copies outside the ring, shared
+	// between latestReading, latestByMode and latestByDevice. Restamp every copy
+	// the maps reach; latestReading is always also the latest of its mode.
+	for _, latest := range s.latestByDevice {
+		if !latest.HasCurrentQuality() {
+			latest.StampQuality()
+		}
+	}
+	for _, latest := range s.latestByMode {
+		if !latest.HasCurrentQuality() {
+			latest.StampQuality()
+		}
+	}
+
+	return updated, nil
+}
+
+// WaitForNewReading blocks until a reading newer than since is stored or ctx is done
+func (s *Store) WaitForNewReading(ctx context.Context, since time.Time) (*models.SensorReading, bool) {
+	return s.notifier.Wait(ctx, since, s.GetLatestReading)
+}
+
 // GetLatestReading returns the most recent reading
 func (s *Store) GetLatestReading() (*models.SensorReading, bool) {
 	s.mu.RLock()
@@ -100,15 +172,16 @@ func (s *Store) GetAllLatestReadings() []models.SensorReading {
 	return []models.SensorReading{*s.latestReading}
 }
 
-// GetReadingsInRange returns sensor readings within a time range
+// GetReadingsInRange returns sensor readings within a time range, bounds
+// included like the database store's BETWEEN
 func (s *Store) GetReadingsInRange(start, end time.Time) []models.SensorReading {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []models.SensorReading
 
-	for _, reading := range s.sensorReadings {
-		if reading.Timestamp.After(start) && reading.Timestamp.Before(end) {
+	for reading := range s.sensorReadings.all() {
+		if !reading.Timestamp.Before(start) && !reading.Timestamp.After(end) {
 			result = append(result, reading)
 		}
 	}
@@ -121,14 +194,327 @@ func (s *Store) GetReadingsInRange(start, end time.Time) []models.SensorReading
 	return result
 }
 
+// GetReadingsAround returns a device's readings surrounding a point in time in
+// chronological order: up to before readings at or earlier than at, followed by
+// up to after readings later than at
+func (s *Store) GetReadingsAround(deviceID string, at time.Time, before, after int) ([]models.SensorReading, error) {
+	s.mu.RLock()
+	var readings []models.SensorReading
+	for reading := range s.sensorReadings.all() {
+		if reading.DeviceID == deviceID {
+			readings = append(readings, reading)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+
+	// First reading later than at
+	split := sort.Search(len(readings), func(i int) bool {
+		return readings[i].Timestamp.After(at)
+	})
+	from := max(split-before, 0)
+	to := min(split+after, len(readings))
+
+	result := make([]models.SensorReading, to-from)
+	copy(result, readings[from:to])
+	return result, nil
+}
+
+// QueryReadings returns the page of readings matching the filter along with the
+// total number of matches before pagination
+func (s *Store) QueryReadings(filter models.ReadingFilter) ([]models.SensorReading, int, error) {
+	s.mu.RLock()
+	matches := []models.SensorReading{}
+	for reading := range s.sensorReadings.all() {
+		if filter.Matches(reading) {
+			matches = append(matches, reading)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if filter.SortAsc {
+			return matches[i].Timestamp.Before(matches[j].Timestamp)
+		}
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return []models.SensorReading{}, total, nil
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultReadingFilterLimit
+	}
+	matches = matches[filter.Offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, total, nil
+}
+
+// QueryReadingsAfter returns up to filter.Limit readings matching the filter that
+// come after the cursor in (timestamp, device_id) order, newest first unless
+// filter.SortAsc. A nil cursor starts from the first reading.
+func (s *Store) QueryReadingsAfter(filter models.ReadingFilter, after *models.ReadingCursor) ([]models.SensorReading, error) {
+	s.mu.RLock()
+	matches := []models.SensorReading{}
+	for reading := range s.sensorReadings.all() {
+		if filter.Matches(reading) && (after == nil || after.IsPast(reading, filter.SortAsc)) {
+			matches = append(matches, reading)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if filter.SortAsc {
+			return models.CompareReadingOrder(matches[i], matches[j]) < 0
+		}
+		return models.CompareReadingOrder(matches[i], matches[j]) > 0
+	})
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultReadingFilterLimit
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// GetReadingsPaginated returns one page of readings, optionally restricted to a
+// device and filter mode, along with the number of readings matching those filters
+func (s *Store) GetReadingsPaginated(limit, offset int, deviceID string, mode *models.FilterMode, sortAsc bool) ([]models.SensorReading, int, error) {
+	filter := models.ReadingFilter{
+		DeviceID: deviceID,
+		SortAsc:  sortAsc,
+		Limit:    limit,
+		Offset:   offset,
+	}
+	if mode != nil {
+		filter.FilterMode = *mode
+	}
+	return s.QueryReadings(filter)
+}
+
+// GetMetricHeatmap averages a metric per UTC day-of-week and hour-of-day
+func (s *Store) GetMetricHeatmap(metric string, start, end time.Time) ([]models.HeatmapBucket, error) {
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+
+	var sums [7][24]float64
+	var counts [7][24]int
+
+	s.mu.RLock()
+	for reading := range s.sensorReadings.all() {
+		if reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+			continue
+		}
+		value, _ := reading.MetricValue(metric)
+		ts := reading.Timestamp.UTC()
+		sums[ts.Weekday()][ts.Hour()] += value
+		counts[ts.Weekday()][ts.Hour()]++
+	}
+	s.mu.RUnlock()
+
+	buckets := []models.HeatmapBucket{}
+	for day := 0; day < 7; day++ {
+		for hour := 0; hour < 24; hour++ {
+			if counts[day][hour] == 0 {
+				continue
+			}
+			buckets = append(buckets, models.HeatmapBucket{
+				DayOfWeek: day,
+				Hour:      hour,
+				Average:   sums[day][hour] / float64(counts[day][hour]),
+				Count:     counts[day][hour],
+			})
+		}
+	}
+	return buckets, nil
+}
+
+// GetMetricAggregates computes a metric's min, max and average per UTC hour or
+// day between start and end (inclusive), oldest bucket first
+func (s *Store) GetMetricAggregates(metric, interval string, start, end time.Time) ([]models.AggregateBucket, error) {
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+	if !models.IsValidAggregateInterval(interval) {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	buckets := make(map[time.Time]*models.AggregateBucket)
+	s.mu.RLock()
+	for reading := range s.sensorReadings.all() {
+		if reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+			continue
+		}
+		value, _ := reading.MetricValue(metric)
+		bucketStart := models.TruncateToInterval(reading.Timestamp, interval)
+		bucket, exists := buckets[bucketStart]
+		if !exists {
+			bucket = &models.AggregateBucket{BucketStart: bucketStart, Min: value, Max: value}
+			buckets[bucketStart] = bucket
+		}
+		bucket.Min = math.Min(bucket.Min, value)
+		bucket.Max = math.Max(bucket.Max, value)
+		bucket.Avg += value // Sum until every reading is counted
+		bucket.Count++
+	}
+	s.mu.RUnlock()
+
+	result := make([]models.AggregateBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.Avg /= float64(bucket.Count)
+		result = append(result, *bucket)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BucketStart.Before(result[j].BucketStart)
+	})
+	return result, nil
+}
+
+// GetMetricHistogram buckets a metric's values between start and end (inclusive)
+// into equal-width bins spanning the observed range
+func (s *Store) GetMetricHistogram(metric string, start, end time.Time, bins int) (*models.MetricHistogram, error) {
+	if !models.IsValidSensorMetric(metric) {
+		return nil, fmt.Errorf("invalid metric: %s", metric)
+	}
+	if bins < 1 {
+		return nil, fmt.Errorf("bins must be positive")
+	}
+
+	var values []float64
+	s.mu.RLock()
+	for reading := range s.sensorReadings.all() {
+		if reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+			continue
+		}
+		value, _ := reading.MetricValue(metric)
+		values = append(values, value)
+	}
+	s.mu.RUnlock()
+
+	if len(values) == 0 {
+		return models.NewMetricHistogram(metric, start, end, 0, 0, nil), nil
+	}
+
+	min, max := values[0], values[0]
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+	if min == max {
+		bins = 1 // Every value is identical, so there is no range to split
+	}
+
+	counts := make([]int, bins)
+	for _, value := range values {
+		counts[models.HistogramBinIndex(value, min, max, bins)]++
+	}
+	return models.NewMetricHistogram(metric, start, end, min, max, counts), nil
+}
+
+// GetFilterModeCounts counts the stored readings per filter mode
+func (s *Store) GetFilterModeCounts() ([]models.FilterModeCount, error) {
+	s.mu.RLock()
+	byMode := make(map[models.FilterMode]*models.FilterModeCount)
+	for reading := range s.sensorReadings.all() {
+		count, exists := byMode[reading.FilterMode]
+		if !exists {
+			count = &models.FilterModeCount{Mode: reading.FilterMode}
+			byMode[reading.FilterMode] = count
+		}
+		count.ReadingCount++
+		if reading.Timestamp.After(count.LatestTimestamp) {
+			count.LatestTimestamp = reading.Timestamp
+		}
+	}
+	s.mu.RUnlock()
+
+	counts := make([]models.FilterModeCount, 0, len(byMode))
+	for _, count := range byMode {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Mode < counts[j].Mode
+	})
+	return counts, nil
+}
+
+// GetReadingCountsByDevice counts each device's readings between start and end (inclusive)
+func (s *Store) GetReadingCountsByDevice(start, end time.Time) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for reading := range s.sensorReadings.all() {
+		if !reading.Timestamp.Before(start) && !reading.Timestamp.After(end) {
+			counts[reading.DeviceID]++
+		}
+	}
+	return counts, nil
+}
+
+// GetAvailableDays returns the dates, as YYYY-MM-DD in loc, that have at least
+// one reading between start and end (inclusive), oldest first
+func (s *Store) GetAvailableDays(start, end time.Time, loc *time.Location) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	days := []string{}
+	for reading := range s.sensorReadings.all() {
+		if reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+			continue
+		}
+		day := reading.Timestamp.In(loc).Format(time.DateOnly)
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// GetSensorDataStats calculates statistics of the readings in the given filter
+// mode ("" = every mode), or returns nil when there are none
+func (s *Store) GetSensorDataStats(filterMode models.FilterMode) (*models.SensorDataStats, error) {
+	s.mu.RLock()
+	readings := make([]models.SensorReading, 0, s.sensorReadings.len())
+	for reading := range s.sensorReadings.all() {
+		if filterMode == "" || reading.FilterMode == filterMode {
+			readings = append(readings, reading)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	stats := models.NewSensorDataStats(readings)
+	return &stats, nil
+}
+
 // GetRecentReadings returns the most recent N readings
 func (s *Store) GetRecentReadings(limit int) []models.SensorReading {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	// Get all readings
-	readings := make([]models.SensorReading, len(s.sensorReadings))
-	copy(readings, s.sensorReadings)
+	readings := make([]models.SensorReading, 0, s.sensorReadings.len())
+	for reading := range s.sensorReadings.all() {
+		readings = append(readings, reading)
+	}
 
 	// Sort by timestamp descending (most recent first)
 	sort.Slice(readings, func(i, j int) bool {
@@ -203,9 +589,143 @@ func (s *Store) SetCurrentFilterMode(mode models.FilterMode) {
 	s.currentFilterMode = mode
 }
 
-// GetFilterModeTracking returns filter mode tracking (in-memory store doesn't track this)
+// GetLEDCommand returns the LED state the STM32 picks up when it polls
+func (s *Store) GetLEDCommand() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ledCommand
+}
+
+// SetLEDCommand sets the LED state the STM32 picks up when it polls
+func (s *Store) SetLEDCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ledCommand = command
+}
+
+// GetFilterModeTracking returns filter mode tracking (in-memory store doesn't track
+// this, so it always reports the zeroed tracking object)
 func (s *Store) GetFilterModeTracking() map[string]interface{} {
-	return nil // Not supported in in-memory store
+	return EmptyFilterModeTracking()
+}
+
+// RecordFilterModeChange appends an entry to the filter mode change audit log
+func (s *Store) RecordFilterModeChange(change *models.FilterModeChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change.ID = s.nextModeChangeID
+	s.nextModeChangeID++
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now()
+	}
+
+	s.modeChanges = append(s.modeChanges, *change)
+	if excess := len(s.modeChanges) - maxModeChanges; excess > 0 {
+		s.modeChanges = append([]models.FilterModeChange(nil), s.modeChanges[excess:]...)
+	}
+	return nil
+}
+
+// GetFilterModeChanges returns the most recent filter mode changes, newest first
+func (s *Store) GetFilterModeChanges(limit int) ([]models.FilterModeChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []models.FilterModeChange{}
+	for i := len(s.modeChanges) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		result = append(result, s.modeChanges[i])
+	}
+
+	return result, nil
+}
+
+// RecordDeviceCommand appends an entry to the device command log
+func (s *Store) RecordDeviceCommand(command *models.DeviceCommand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	command.ID = s.nextDeviceCommandID
+	s.nextDeviceCommandID++
+	if command.IssuedAt.IsZero() {
+		command.IssuedAt = time.Now()
+	}
+
+	s.deviceCommands = append(s.deviceCommands, *command)
+	if excess := len(s.deviceCommands) - maxDeviceCommands; excess > 0 {
+		s.deviceCommands = append([]models.DeviceCommand(nil), s.deviceCommands[excess:]...)
+	}
+	return nil
+}
+
+// UpdateDeviceCommandStatus records the delivery state of a logged command
+func (s *Store) UpdateDeviceCommandStatus(id int, status, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deviceCommands {
+		if s.deviceCommands[i].ID == id {
+			s.deviceCommands[i].Status = status
+			s.deviceCommands[i].Error = errMsg
+			return nil
+		}
+	}
+
+	return fmt.Errorf("device command not found")
+}
+
+// GetDeviceCommands returns the most recent commands sent to a device, newest first
+func (s *Store) GetDeviceCommands(deviceID string, limit int) ([]models.DeviceCommand, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []models.DeviceCommand{}
+	for i := len(s.deviceCommands) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if s.deviceCommands[i].DeviceID == deviceID {
+			result = append(result, s.deviceCommands[i])
+		}
+	}
+
+	return result, nil
+}
+
+// AddMaintenanceEvent appends an entry to a device's maintenance log
+func (s *Store) AddMaintenanceEvent(event *models.MaintenanceEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = s.nextMaintenanceEventID
+	s.nextMaintenanceEventID++
+	event.CreatedAt = time.Now()
+	if event.PerformedAt.IsZero() {
+		event.PerformedAt = event.CreatedAt
+	}
+
+	// Keep the log ordered by when the work was done; entries may be backdated
+	i := sort.Search(len(s.maintenanceEvents), func(i int) bool {
+		return s.maintenanceEvents[i].PerformedAt.After(event.PerformedAt)
+	})
+	s.maintenanceEvents = append(s.maintenanceEvents, models.MaintenanceEvent{})
+	copy(s.maintenanceEvents[i+1:], s.maintenanceEvents[i:])
+	s.maintenanceEvents[i] = *event
+	return nil
+}
+
+// GetMaintenanceEvents returns a device's most recently performed maintenance, newest first
+func (s *Store) GetMaintenanceEvents(deviceID string, limit int) ([]models.MaintenanceEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []models.MaintenanceEvent{}
+	for i := len(s.maintenanceEvents) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if s.maintenanceEvents[i].DeviceID == deviceID {
+			result = append(result, s.maintenanceEvents[i])
+		}
+	}
+
+	return result, nil
 }
 
 // GetReadingsByMode returns all readings for a specific filter mode
@@ -214,7 +734,7 @@ func (s *Store) GetReadingsByMode(mode models.FilterMode) []models.SensorReading
 	defer s.mu.RUnlock()
 
 	var result []models.SensorReading
-	for _, reading := range s.sensorReadings {
+	for reading := range s.sensorReadings.all() {
 		if reading.FilterMode == mode {
 			result = append(result, reading)
 		}
@@ -235,7 +755,7 @@ func (s *Store) GetRecentReadingsByMode(mode models.FilterMode, limit int) []mod
 
 	// Filter readings by mode
 	var readings []models.SensorReading
-	for _, reading := range s.sensorReadings {
+	for reading := range s.sensorReadings.all() {
 		if reading.FilterMode == mode {
 			readings = append(readings, reading)
 		}
@@ -260,7 +780,7 @@ func (s *Store) GetReadingsByDevice(deviceID string) []models.SensorReading {
 	defer s.mu.RUnlock()
 
 	var result []models.SensorReading
-	for _, reading := range s.sensorReadings {
+	for reading := range s.sensorReadings.all() {
 		if reading.DeviceID == deviceID {
 			result = append(result, reading)
 		}
@@ -281,7 +801,7 @@ func (s *Store) GetRecentReadingsByDevice(deviceID string, limit int) []models.S
 
 	// Filter readings by device
 	var readings []models.SensorReading
-	for _, reading := range s.sensorReadings {
+	for reading := range s.sensorReadings.all() {
 		if reading.DeviceID == deviceID {
 			readings = append(readings, reading)
 		}
@@ -311,6 +831,201 @@ func (s *Store) GetActiveDevices() []string {
 	return []string{}
 }
 
+// deviceProfile holds the editable fields of a device's status
+type deviceProfile struct {
+	name     string
+	inactive bool
+}
+
+// GetDeviceStatuses returns the status of every device that sent readings, ordered by device ID
+func (s *Store) GetDeviceStatuses() ([]models.DeviceStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]models.DeviceStatus, 0, len(s.latestByDevice))
+	for deviceID := range s.latestByDevice {
+		statuses = append(statuses, s.deviceStatusLocked(deviceID))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].DeviceID < statuses[j].DeviceID })
+	return statuses, nil
+}
+
+// GetDeviceStatus returns a device's status, if it sent readings
+func (s *Store) GetDeviceStatus(deviceID string) (*models.DeviceStatus, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.latestByDevice[deviceID]; !exists {
+		return nil, false, nil
+	}
+	status := s.deviceStatusLocked(deviceID)
+	return &status, true, nil
+}
+
+// PatchDeviceStatus renames or (de)activates a device that sent readings
+func (s *Store) PatchDeviceStatus(deviceID string, update models.DeviceStatusUpdate) (*models.DeviceStatus, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.latestByDevice[deviceID]; !exists {
+		return nil, false, nil
+	}
+	profile := s.deviceProfiles[deviceID]
+	if update.Name != nil {
+		profile.name = *update.Name
+	}
+	if update.IsActive != nil {
+		profile.inactive = !*update.IsActive
+	}
+	s.deviceProfiles[deviceID] = profile
+
+	status := s.deviceStatusLocked(deviceID)
+	return &status, true, nil
+}
+
+// deviceStatusLocked builds a device's status; s.mu must be held. The
+// in-memory store doesn't accumulate flow, so TotalFlowLiters is always 0.
+func (s *Store) deviceStatusLocked(deviceID string) models.DeviceStatus {
+	profile := s.deviceProfiles[deviceID]
+	status := models.DeviceStatus{
+		DeviceID:          deviceID,
+		Name:              profile.name,
+		IsActive:          !profile.inactive,
+		CurrentFilterMode: s.currentFilterMode,
+	}
+	if latest := s.latestByDevice[deviceID]; latest != nil {
+		lastSeen := latest.Timestamp
+		status.LastSeen = &lastSeen
+	}
+	for reading := range s.sensorReadings.all() {
+		if reading.DeviceID == deviceID {
+			status.TotalReadings++
+		}
+	}
+	return status
+}
+
+// GetDeviceTypeOverrides returns the device type overrides keyed by device ID
+func (s *Store) GetDeviceTypeOverrides() (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	overrides := make(map[string]string, len(s.deviceTypeOverrides))
+	for deviceID, deviceType := range s.deviceTypeOverrides {
+		overrides[deviceID] = deviceType
+	}
+	return overrides, nil
+}
+
+// SetDeviceTypeOverride sets the device type for a device; an empty type clears the override
+func (s *Store) SetDeviceTypeOverride(deviceID string, deviceType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if deviceType == "" {
+		delete(s.deviceTypeOverrides, deviceID)
+		return nil
+	}
+	s.deviceTypeOverrides[deviceID] = deviceType
+	return nil
+}
+
+// SetDeviceAPIKeyHash stores the hash of a device's ingestion API key, replacing any previous key
+func (s *Store) SetDeviceAPIKeyHash(deviceID string, keyHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deviceKeyHashes[deviceID] = keyHash
+	return nil
+}
+
+// GetDeviceAPIKeyHash returns the hash of a device's ingestion API key, if one is provisioned
+func (s *Store) GetDeviceAPIKeyHash(deviceID string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keyHash, exists := s.deviceKeyHashes[deviceID]
+	return keyHash, exists, nil
+}
+
+// CountDeviceAPIKeys returns how many devices have an ingestion API key
+func (s *Store) CountDeviceAPIKeys() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.deviceKeyHashes), nil
+}
+
+// SetFilterSpec stores the metadata of the filter installed on a device
+func (s *Store) SetFilterSpec(spec models.FilterSpec) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filterSpecs[spec.DeviceID] = spec
+	return nil
+}
+
+// GetFilterSpec returns the metadata of the filter installed on a device, if recorded
+func (s *Store) GetFilterSpec(deviceID string) (models.FilterSpec, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	spec, exists := s.filterSpecs[deviceID]
+	return spec, exists, nil
+}
+
+// SetAlertPreferences stores the anomaly alerts muted for a device
+func (s *Store) SetAlertPreferences(prefs models.AlertPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs.MutedMetrics = append([]string{}, prefs.MutedMetrics...)
+	prefs.MutedSeverities = append([]string{}, prefs.MutedSeverities...)
+	s.alertPrefs[prefs.DeviceID] = prefs
+	return nil
+}
+
+// GetAlertPreferences returns the anomaly alerts muted for a device, or the
+// defaults when none were set
+func (s *Store) GetAlertPreferences(deviceID string) (models.AlertPreferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs, exists := s.alertPrefs[deviceID]
+	if !exists {
+		return models.DefaultAlertPreferences(deviceID), nil
+	}
+	prefs.MutedMetrics = append([]string{}, prefs.MutedMetrics...)
+	prefs.MutedSeverities = append([]string{}, prefs.MutedSeverities...)
+	return prefs, nil
+}
+
+// GetProcessedVolume estimates the liters that flowed through a device since a
+// time: each reading's flow rate (L/min) times the minutes since the previous
+// reading, skipping gaps flow isn't accumulated over
+func (s *Store) GetProcessedVolume(deviceID string, since time.Time) (float64, error) {
+	s.mu.RLock()
+	readings := []models.SensorReading{}
+	for reading := range s.sensorReadings.all() {
+		if reading.DeviceID == deviceID && !reading.Timestamp.Before(since) {
+			readings = append(readings, reading)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+
+	var volume float64
+	for i := 1; i < len(readings); i++ {
+		if minutes := readings[i].Timestamp.Sub(readings[i-1].Timestamp).Minutes(); FlowGapCounts(minutes) {
+			volume += readings[i].Flow * minutes
+		}
+	}
+	return volume, nil
+}
+
 // GetWaterQualityStatus returns the latest water quality assessment
 func (s *Store) GetWaterQualityStatus() (*models.WaterQualityStatus, bool) {
 	reading, exists := s.GetLatestReading()
@@ -354,7 +1069,7 @@ func (s *Store) GetReadingCount() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return len(s.sensorReadings)
+	return s.sensorReadings.len()
 }
 
 // DeleteAllSensorReadings removes all sensor readings from the store
@@ -362,7 +1077,7 @@ func (s *Store) DeleteAllSensorReadings() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.sensorReadings = make([]models.SensorReading, 0, s.maxReadings)
+	s.sensorReadings.reset()
 	s.latestReading = nil
 	s.latestByMode = make(map[models.FilterMode]*models.SensorReading)
 	s.latestByDevice = make(map[string]*models.SensorReading)
@@ -370,12 +1085,45 @@ func (s *Store) DeleteAllSensorReadings() error {
 	return nil
 }
 
+// PruneDataBefore deletes readings, anomalies and sensor predictions older than
+// cutoff. The latest reading of each device and mode stays available as its
+// current state. The batch size only matters to the database store.
+func (s *Store) PruneDataBefore(cutoff time.Time, batchSize int) (*models.PruneResult, error) {
+	result := &models.PruneResult{Cutoff: cutoff}
+
+	s.mu.Lock()
+	result.SensorReadings = s.sensorReadings.removeBefore(cutoff)
+	s.mu.Unlock()
+
+	s.mlData.mu.Lock()
+	defer s.mlData.mu.Unlock()
+
+	anomalies := s.mlData.anomalies[:0]
+	for _, anomaly := range s.mlData.anomalies {
+		if anomaly.DetectedAt.Before(cutoff) {
+			result.Anomalies++
+			continue
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	s.mlData.anomalies = anomalies
+
+	// Sensor predictions are sorted by predicted_for, so the old ones lead
+	preds := s.mlData.sensorPreds
+	result.SensorPredictions = sort.Search(len(preds), func(i int) bool {
+		return !preds[i].PredictedFor.Before(cutoff)
+	})
+	s.mlData.sensorPreds = append([]models.SensorPrediction(nil), preds[result.SensorPredictions:]...)
+
+	return result, nil
+}
+
 // ClearReadings removes all stored readings (useful for testing)
 func (s *Store) ClearReadings() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.sensorReadings = make([]models.SensorReading, 0, s.maxReadings)
+	s.sensorReadings.reset()
 	s.latestReading = nil
 }
 
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -328,4 +331,692 @@ func TestStore_FiltrationProcess_StateTransitions(t *testing.T) {
 	if exists {
 		t.Error("Expected no process after clearing completed")
 	}
-}
\ No newline at end of file
+}
+
+func TestReadingDeduplicator_SkipsExactDuplicateWithinWindow(t *testing.T) {
+	store := NewStore(100)
+	dedup := NewReadingDeduplicator(store, true, 5*time.Second)
+
+	now := time.Now()
+	first := models.SensorReading{
+		DeviceID:   "stm32_pre",
+		Timestamp:  now,
+		FilterMode: models.FilterModeDrinking,
+		Flow:       1.5,
+		Ph:         7.2,
+		Turbidity:  1.1,
+		TDS:        120,
+	}
+	store.AddSensorReading(first)
+
+	duplicate := first
+	duplicate.Timestamp = now.Add(2 * time.Second)
+	if !dedup.IsDuplicate(duplicate) {
+		t.Error("Expected identical reading within window to be a duplicate")
+	}
+
+	outsideWindow := first
+	outsideWindow.Timestamp = now.Add(10 * time.Second)
+	if dedup.IsDuplicate(outsideWindow) {
+		t.Error("Expected identical reading outside window to be kept")
+	}
+}
+
+func TestReadingDeduplicator_KeepsChangedValue(t *testing.T) {
+	store := NewStore(100)
+	dedup := NewReadingDeduplicator(store, true, 5*time.Second)
+
+	now := time.Now()
+	first := models.SensorReading{
+		DeviceID:   "stm32_post",
+		Timestamp:  now,
+		FilterMode: models.FilterModeDrinking,
+		Flow:       1.5,
+		Ph:         7.2,
+		Turbidity:  0.5,
+		TDS:        80,
+	}
+	store.AddSensorReading(first)
+
+	changed := first
+	changed.Timestamp = now.Add(1 * time.Second)
+	changed.TDS = 81
+	if dedup.IsDuplicate(changed) {
+		t.Error("Expected reading with a changed value to be kept")
+	}
+}
+
+func TestReadingDeduplicator_DisabledByDefault(t *testing.T) {
+	store := NewStore(100)
+	dedup := NewReadingDeduplicator(store, false, 5*time.Second)
+
+	reading := models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking, Ph: 7.0}
+	store.AddSensorReading(reading)
+
+	if dedup.IsDuplicate(reading) {
+		t.Error("Expected disabled deduplicator to never report duplicates")
+	}
+
+	var nilDedup *ReadingDeduplicator
+	if nilDedup.IsDuplicate(reading) {
+		t.Error("Expected nil deduplicator to never report duplicates")
+	}
+}
+
+func TestResolveFilterDevices_DefaultsAndOverride(t *testing.T) {
+	store := NewStore(100)
+
+	pre, post := ResolveFilterDevices(store)
+	if pre != "stm32_pre" || post != "stm32_post" {
+		t.Errorf("Expected default stm32_pre/stm32_post, got %s/%s", pre, post)
+	}
+
+	// An oddly named device marked as pre-filtration via metadata takes precedence
+	store.AddSensorReading(models.SensorReading{DeviceID: "inlet_sensor", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking})
+	if err := store.SetDeviceTypeOverride("inlet_sensor", models.DeviceTypePreFiltration); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+
+	pre, post = ResolveFilterDevices(store)
+	if pre != "inlet_sensor" {
+		t.Errorf("Expected overridden pre device inlet_sensor, got %s", pre)
+	}
+	if post != "stm32_post" {
+		t.Errorf("Expected post device stm32_post, got %s", post)
+	}
+
+	// Clearing the override falls back to inference
+	if err := store.SetDeviceTypeOverride("inlet_sensor", ""); err != nil {
+		t.Fatalf("Failed to clear override: %v", err)
+	}
+	pre, _ = ResolveFilterDevices(store)
+	if pre != "stm32_pre" {
+		t.Errorf("Expected stm32_pre after clearing override, got %s", pre)
+	}
+}
+
+func TestDeviceIDs_IncludesDevicesBeyondTheDefaults(t *testing.T) {
+	store := NewStore(100)
+	store.AddSensorReading(models.SensorReading{DeviceID: "inlet_sensor", Timestamp: time.Now(), FilterMode: models.FilterModeDrinking})
+	if err := store.SetDeviceTypeOverride("outlet_2", models.DeviceTypePostFiltration); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+
+	got := strings.Join(DeviceIDs(store), ",")
+	if want := "inlet_sensor,outlet_2,stm32_main,stm32_post,stm32_pre"; got != want {
+		t.Errorf("Expected devices %s, got %s", want, got)
+	}
+}
+
+func TestStore_ReadingsWrapAroundAtCapacity(t *testing.T) {
+	s := NewStore(5)
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		mode := models.FilterModeDrinking
+		if i%2 == 1 {
+			mode = models.FilterModeHousehold
+		}
+		s.AddSensorReading(models.SensorReading{
+			DeviceID:   "stm32_pre",
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			FilterMode: mode,
+			TDS:        float64(i),
+		})
+	}
+
+	if count := s.GetReadingCount(); count != 5 {
+		t.Fatalf("Expected 5 readings after wraparound, got %d", count)
+	}
+	if capacity := s.sensorReadings.capacity(); capacity != 5 {
+		t.Errorf("Expected ring capacity to stay at 5, got %d", capacity)
+	}
+
+	// Only the newest five readings (7..11) are retained, newest first
+	recent := s.GetRecentReadings(0)
+	for i, reading := range recent {
+		if want := float64(11 - i); reading.TDS != want {
+			t.Errorf("Recent reading %d: expected TDS %.0f, got %.0f", i, want, reading.TDS)
+		}
+	}
+
+	inRange := s.GetReadingsInRange(base, base.Add(time.Hour))
+	if len(inRange) != 5 || inRange[0].TDS != 7 || inRange[4].TDS != 11 {
+		t.Errorf("Expected range query to return readings 7..11 in order, got %v", inRange)
+	}
+
+	byMode := s.GetRecentReadingsByMode(models.FilterModeHousehold, 10)
+	if len(byMode) != 3 || byMode[0].TDS != 11 {
+		t.Errorf("Expected household readings 11, 9, 7, got %v", byMode)
+	}
+
+	if byDevice := s.GetReadingsByDevice("stm32_pre"); len(byDevice) != 5 {
+		t.Errorf("Expected 5 readings for device, got %d", len(byDevice))
+	}
+
+	if err := s.DeleteAllSensorReadings(); err != nil {
+		t.Fatalf("Unexpected error clearing readings: %v", err)
+	}
+	if count := s.GetReadingCount(); count != 0 {
+		t.Errorf("Expected no readings after delete, got %d", count)
+	}
+	s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: base, TDS: 42})
+	if recent := s.GetRecentReadings(0); len(recent) != 1 || recent[0].TDS != 42 {
+		t.Errorf("Expected a single reading after reset, got %v", recent)
+	}
+}
+
+func TestStore_GetReadingsInRange_IncludesBounds(t *testing.T) {
+	s := NewStore(10)
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	for i, ts := range []time.Time{start.Add(-time.Nanosecond), start, start.Add(30 * time.Minute), end, end.Add(time.Nanosecond)} {
+		s.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: ts, TDS: float64(i)})
+	}
+
+	inRange := s.GetReadingsInRange(start, end)
+	if len(inRange) != 3 || inRange[0].TDS != 1 || inRange[2].TDS != 3 {
+		t.Errorf("Expected readings on and between the bounds (1..3), got %v", inRange)
+	}
+}
+
+func TestReadingNotifier_WaitDoesNotHoldLockDuringLookup(t *testing.T) {
+	n := NewReadingNotifier()
+	since := time.Now()
+	calls := 0
+
+	// The lookup notifies like a concurrent ingestion would; holding the
+	// notifier's lock across it would deadlock here
+	latest := func() (*models.SensorReading, bool) {
+		calls++
+		if calls == 1 {
+			n.Notify()
+			return nil, false
+		}
+		return &models.SensorReading{Timestamp: since.Add(time.Second)}, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if reading, ok := n.Wait(ctx, since, latest); !ok || reading == nil {
+		t.Fatalf("Expected the newer reading after the notify, got %v, %v", reading, ok)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the lookup re-run once after the notify, got %d calls", calls)
+	}
+}
+
+func TestModeChangeCooldown_RejectsWithinInterval(t *testing.T) {
+	cooldown := NewModeChangeCooldown(time.Minute)
+	start := time.Now()
+
+	if _, ok := cooldown.Reserve([]string{"stm32_pre", "stm32_post"}, start); !ok {
+		t.Fatal("Expected the first change to be accepted")
+	}
+
+	remaining, ok := cooldown.Reserve([]string{"stm32_post"}, start.Add(20*time.Second))
+	if ok {
+		t.Fatal("Expected a change within the cooldown to be rejected")
+	}
+	if remaining != 40*time.Second {
+		t.Errorf("Expected 40s remaining, got %s", remaining)
+	}
+
+	// Devices that have not changed mode are not held back
+	if _, ok := cooldown.Reserve([]string{"stm32_other"}, start.Add(20*time.Second)); !ok {
+		t.Error("Expected a change on an untouched device to be accepted")
+	}
+
+	if _, ok := cooldown.Reserve([]string{"stm32_post"}, start.Add(time.Minute)); !ok {
+		t.Error("Expected a change after the cooldown to be accepted")
+	}
+
+	var disabled *ModeChangeCooldown
+	if _, ok := disabled.Reserve(nil, start); !ok {
+		t.Error("Expected a nil cooldown to accept every change")
+	}
+}
+
+func TestClockSkewCorrector_Policies(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	skewed := now.Add(3 * time.Hour)
+	inBounds := now.Add(-30 * time.Second)
+
+	tests := []struct {
+		policy        ClockSkewPolicy
+		wantErr       bool
+		wantTimestamp time.Time
+	}{
+		{ClockSkewReject, true, skewed},
+		{ClockSkewClamp, false, now},
+		{ClockSkewRecord, false, skewed},
+	}
+
+	for _, tt := range tests {
+		corrector := NewClockSkewCorrector(time.Minute, tt.policy)
+
+		reading := models.SensorReading{DeviceID: "stm32_post", Timestamp: skewed}
+		skew, err := corrector.Apply(&reading, now)
+		var skewErr *ClockSkewError
+		if tt.wantErr != errors.As(err, &skewErr) {
+			t.Errorf("%s: expected rejection %v, got %v", tt.policy, tt.wantErr, err)
+		}
+		if skew != 3*time.Hour {
+			t.Errorf("%s: expected 3h skew, got %s", tt.policy, skew)
+		}
+		if !reading.Timestamp.Equal(tt.wantTimestamp) {
+			t.Errorf("%s: expected timestamp %s, got %s", tt.policy, tt.wantTimestamp, reading.Timestamp)
+		}
+		if reading.ServerReceivedAt == nil || !reading.ServerReceivedAt.Equal(now) {
+			t.Errorf("%s: expected server receive time %s, got %v", tt.policy, now, reading.ServerReceivedAt)
+		}
+
+		// Timestamps within the allowed skew are kept as reported
+		reading = models.SensorReading{DeviceID: "stm32_post", Timestamp: inBounds}
+		if skew, err := corrector.Apply(&reading, now); err != nil || skew != 0 || !reading.Timestamp.Equal(inBounds) {
+			t.Errorf("%s: expected in-bounds reading untouched, got skew=%s err=%v ts=%s", tt.policy, skew, err, reading.Timestamp)
+		}
+	}
+
+	// Without a corrector readings are still stamped and missing timestamps filled in
+	var disabled *ClockSkewCorrector
+	reading := models.SensorReading{DeviceID: "stm32_post"}
+	if _, err := disabled.Apply(&reading, now); err != nil || !reading.Timestamp.Equal(now) || reading.ServerReceivedAt == nil {
+		t.Errorf("Expected a nil corrector to stamp server time, got ts=%s received=%v err=%v", reading.Timestamp, reading.ServerReceivedAt, err)
+	}
+
+	// Device timestamps aren't trusted while skew handling is off
+	for _, corrector := range []*ClockSkewCorrector{nil, NewClockSkewCorrector(0, ClockSkewRecord)} {
+		reading := models.SensorReading{DeviceID: "stm32_post", Timestamp: skewed}
+		if skew, err := corrector.Apply(&reading, now); err != nil || skew != 0 || !reading.Timestamp.Equal(now) {
+			t.Errorf("Expected server time without skew handling, got skew=%s err=%v ts=%s", skew, err, reading.Timestamp)
+		}
+	}
+}
+
+func TestStore_GetAnomalyOpsMetrics_MTTR(t *testing.T) {
+	store := NewStore(10)
+	detected := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	resolvedAt := func(d time.Duration) *time.Time {
+		ts := detected.Add(d)
+		return &ts
+	}
+
+	fixtures := []models.AnomalyDetection{
+		{DeviceID: "stm32_post", DetectedAt: detected, ResolvedAt: resolvedAt(30 * time.Minute)},
+		{DeviceID: "stm32_post", DetectedAt: detected, ResolvedAt: resolvedAt(90 * time.Minute), AutoResolved: true},
+		{DeviceID: "stm32_pre", DetectedAt: detected, ResolvedAt: resolvedAt(5 * time.Minute), IsFalsePositive: true},
+		{DeviceID: "stm32_pre", DetectedAt: detected},
+	}
+	for i := range fixtures {
+		if err := store.SaveAnomaly(&fixtures[i]); err != nil {
+			t.Fatalf("Failed to save anomaly: %v", err)
+		}
+	}
+
+	metrics, err := store.GetAnomalyOpsMetrics()
+	if err != nil {
+		t.Fatalf("Failed to get ops metrics: %v", err)
+	}
+
+	if metrics.TotalAnomalies != 4 || metrics.Resolved != 2 || metrics.Unresolved != 1 || metrics.FalsePositives != 1 {
+		t.Errorf("Unexpected counts: %+v", metrics)
+	}
+	if metrics.AutoResolved != 1 || metrics.ManuallyResolved != 1 {
+		t.Errorf("Expected one auto and one manual resolution, got %+v", metrics)
+	}
+	if metrics.ResolutionRate != 50 || metrics.FalsePositiveRate != 25 {
+		t.Errorf("Expected 50%% resolved and 25%% false positives, got %.1f%% and %.1f%%", metrics.ResolutionRate, metrics.FalsePositiveRate)
+	}
+	// Mean of 30 and 90 minutes; the false positive is not a resolution
+	if want := (60 * time.Minute).Seconds(); metrics.MeanTimeToResolution != want {
+		t.Errorf("Expected MTTR %.0fs, got %.0fs", want, metrics.MeanTimeToResolution)
+	}
+
+	empty, _ := NewStore(10).GetAnomalyOpsMetrics()
+	if empty.ResolutionRate != 0 || empty.MeanTimeToResolution != 0 {
+		t.Errorf("Expected zero metrics for an empty store, got %+v", empty)
+	}
+}
+
+func TestStore_ReadingQualityStoredAndRecomputedOnRulesChange(t *testing.T) {
+	defer models.SetQualityRulesVersion(models.QualityRulesVersion())
+	store := NewStore(10)
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	store.AddSensorReading(models.SensorReading{DeviceID: "stm32_post", Timestamp: base, FilterMode: models.FilterModeDrinking, Ph: 7.2, Turbidity: 0.5, TDS: 120})
+	store.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: base.Add(time.Minute), FilterMode: models.FilterModeDrinking, Ph: 6.1, Turbidity: 8, TDS: 950})
+
+	readings := store.GetRecentReadings(10)
+	for _, reading := range readings {
+		if !reading.HasCurrentQuality() {
+			t.Fatalf("Expected ingestion to store the quality of %s", reading.DeviceID)
+		}
+		if want := reading.AssessQuality(); *reading.Quality != want {
+			t.Errorf("Expected stored quality %+v, got %+v", want, *reading.Quality)
+		}
+	}
+
+	// Nothing to do while the rules are unchanged
+	if updated, err := store.RecomputeReadingQuality(); err != nil || updated != 0 {
+		t.Fatalf("Expected no readings to recompute, got %d (err=%v)", updated, err)
+	}
+
+	// Changing the rules makes every stored assessment stale until backfilled
+	models.SetQualityRulesVersion("test-rules")
+	stale := store.GetRecentReadings(10)[0]
+	if stale.HasCurrentQuality() {
+		t.Fatal("Expected stored quality to be stale after a rules change")
+	}
+	if quality := stale.CurrentQuality(); quality.RulesVersion != "test-rules" {
+		t.Errorf("Expected stale quality to be re-assessed on read, got version %q", quality.RulesVersion)
+	}
+
+	updated, err := store.RecomputeReadingQuality()
+	if err != nil || updated != 2 {
+		t.Fatalf("Expected 2 readings recomputed, got %d (err=%v)", updated, err)
+	}
+	for _, reading := range store.GetRecentReadings(10) {
+		if reading.Quality == nil || reading.Quality.RulesVersion != "test-rules" {
+			t.Errorf("Expected %s to be backfilled under the new rules, got %+v", reading.DeviceID, reading.Quality)
+		}
+	}
+	if latest, _ := store.GetLatestReadingByDevice("stm32_pre"); !latest.HasCurrentQuality() {
+		t.Error("Expected the latest reading per device to be backfilled too")
+	}
+}
+
+func TestSeedDemoData_SeedsEmptyStoreOnly(t *testing.T) {
+	store := NewStore(1000)
+
+	added := SeedDemoData(store, 1, 30*time.Minute)
+	if added != 96 || store.GetReadingCount() != 96 {
+		t.Fatalf("Expected 48 readings for each of 2 devices, got %d added and %d stored", added, store.GetReadingCount())
+	}
+	modes := map[models.FilterMode]int{}
+	for _, deviceID := range []string{"stm32_pre", "stm32_post"} {
+		readings := store.GetReadingsByDevice(deviceID)
+		if len(readings) != 48 {
+			t.Errorf("Expected 48 readings for %s, got %d", deviceID, len(readings))
+		}
+		for _, reading := range readings {
+			modes[reading.FilterMode]++
+			if reading.Ph < 6.5 || reading.Ph > 8.5 || reading.Turbidity < 0 || reading.TDS <= 0 || reading.Flow < 0 {
+				t.Fatalf("Expected realistic values, got %+v", reading)
+			}
+		}
+	}
+	if modes[models.FilterModeDrinking] == 0 || modes[models.FilterModeHousehold] == 0 {
+		t.Errorf("Expected readings in both filter modes, got %v", modes)
+	}
+	pre, _ := store.GetLatestReadingByDevice("stm32_pre")
+	post, _ := store.GetLatestReadingByDevice("stm32_post")
+	if post.TDS >= pre.TDS {
+		t.Errorf("Expected filtered water to have less TDS, got %.0f before and %.0f after", pre.TDS, post.TDS)
+	}
+
+	// A store with data is left alone
+	if added := SeedDemoData(store, 1, 30*time.Minute); added != 0 || store.GetReadingCount() != 96 {
+		t.Errorf("Expected seeding a non-empty store to be a no-op, got %d added and %d stored", added, store.GetReadingCount())
+	}
+}
+
+// batchCountingStore is a store counting how readings are added to it
+type batchCountingStore struct {
+	*Store
+	singles, batches int
+}
+
+func (s *batchCountingStore) AddSensorReading(reading models.SensorReading) {
+	s.singles++
+	s.Store.AddSensorReading(reading)
+}
+
+func (s *batchCountingStore) AddSensorReadingsBatch(readings []models.SensorReading) error {
+	s.batches++
+	return s.Store.AddSensorReadingsBatch(readings)
+}
+
+func TestSeedDemoData_StoresReadingsInBatches(t *testing.T) {
+	store := &batchCountingStore{Store: NewStore(5000)}
+
+	// 2 days every 5 minutes across 2 devices is 1152 readings
+	if added := SeedDemoData(store, 2, 5*time.Minute); added != 1152 || store.GetReadingCount() != 1152 {
+		t.Fatalf("Expected 1152 readings, got %d added and %d stored", added, store.GetReadingCount())
+	}
+	if store.singles != 0 || store.batches != 2 {
+		t.Errorf("Expected 2 batches and no single inserts, got %d batches and %d singles", store.batches, store.singles)
+	}
+}
+
+// failingStore is a store whose Ping fails while down is set, counting calls
+type failingStore struct {
+	*Store
+	down  bool
+	calls int
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (f *failingStore) Ping() error {
+	f.calls++
+	if f.down {
+		return errStoreDown
+	}
+	return nil
+}
+
+func TestCircuitBreakerStore_OpensHalfOpensAndCloses(t *testing.T) {
+	inner := &failingStore{Store: NewStore(10), down: true}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(3, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	guarded := NewCircuitBreakerStore(inner, breaker, func(err error) bool { return errors.Is(err, errStoreDown) })
+
+	// Consecutive failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		if err := guarded.Ping(); !errors.Is(err, errStoreDown) {
+			t.Fatalf("Expected the store error while closed, got %v", err)
+		}
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("Expected closed below the threshold, got %s", state)
+	}
+
+	// The third opens it, after which calls fail fast without reaching the store
+	guarded.Ping()
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("Expected open after 3 failures, got %s", state)
+	}
+	if err := guarded.Ping(); !errors.Is(err, ErrCircuitOpen) || inner.calls != 3 {
+		t.Fatalf("Expected a fast failure without a store call, got %v after %d calls", err, inner.calls)
+	}
+	if retryAfter := breaker.RetryAfter(); retryAfter != 30*time.Second {
+		t.Errorf("Expected to retry after the 30s cooldown, got %s", retryAfter)
+	}
+
+	// After the cooldown a failed probe re-opens it
+	now = now.Add(30 * time.Second)
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("Expected half-open after the cooldown, got %s", state)
+	}
+	if err := guarded.Ping(); !errors.Is(err, errStoreDown) || inner.calls != 4 {
+		t.Fatalf("Expected the probe to reach the store, got %v after %d calls", err, inner.calls)
+	}
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("Expected a failed probe to re-open the circuit, got %s", state)
+	}
+
+	// A successful probe after the next cooldown closes it
+	now = now.Add(30 * time.Second)
+	inner.down = false
+	if err := guarded.Ping(); err != nil {
+		t.Fatalf("Expected the probe to succeed, got %v", err)
+	}
+	if state := breaker.State(); state != CircuitClosed || breaker.RetryAfter() != 0 {
+		t.Fatalf("Expected closed after a successful probe, got %s", state)
+	}
+
+	// Errors that don't mean the store is unavailable never open it
+	breaker = NewCircuitBreaker(1, time.Minute)
+	guarded = NewCircuitBreakerStore(inner, breaker, func(err error) bool { return errors.Is(err, errStoreDown) })
+	if err := guarded.ResolveAnomaly(42); err == nil {
+		t.Fatal("Expected an error for a missing anomaly")
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected a missing record to leave the circuit closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerStore_SkipsCallsWithoutErrorsUnlessClosed(t *testing.T) {
+	inner := &failingStore{Store: NewStore(10), down: true}
+	inner.Store.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now(), TDS: 42})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(1, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	guarded := NewCircuitBreakerStore(inner, breaker, nil)
+
+	guarded.Ping()
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("Expected open after a failure, got %s", state)
+	}
+
+	// While open, reads come back empty and writes are dropped
+	if recent := guarded.GetRecentReadings(10); recent == nil || len(recent) != 0 {
+		t.Errorf("Expected an empty non-nil slice while open, got %v", recent)
+	}
+	if _, exists := guarded.GetLatestReading(); exists {
+		t.Error("Expected no latest reading while open")
+	}
+	guarded.AddSensorReading(models.SensorReading{DeviceID: "stm32_pre", Timestamp: time.Now(), TDS: 7})
+	if count := inner.Store.GetReadingCount(); count != 1 {
+		t.Errorf("Expected the reading dropped while open, got %d stored", count)
+	}
+
+	// Half-open lets exactly one probe through at a time
+	now = now.Add(30 * time.Second)
+	if count := guarded.GetReadingCount(); count != 0 {
+		t.Errorf("Expected calls that can't probe to be skipped while half-open, got %d", count)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected the first probe allowed, got %v", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected a second concurrent probe rejected, got %v", err)
+	}
+	breaker.Record(false)
+
+	// Once closed, calls reach the store again
+	if count := guarded.GetReadingCount(); count != 1 {
+		t.Errorf("Expected the store's reading count once closed, got %d", count)
+	}
+}
+
+func TestCircuitBreakerStore_CurrentFilterModeWhileOpen(t *testing.T) {
+	inner := &failingStore{Store: NewStore(10)}
+	breaker := NewCircuitBreaker(1, time.Minute)
+	guarded := NewCircuitBreakerStore(inner, breaker, nil)
+
+	// Nothing known yet: the mode is unknown rather than guessed
+	breaker.Record(true)
+	if mode := guarded.GetCurrentFilterMode(); mode != models.FilterModeUnknown {
+		t.Errorf("Expected an unknown mode before any was read, got %s", mode)
+	}
+
+	breaker.Record(false)
+	guarded.SetCurrentFilterMode(models.FilterModeHousehold)
+	breaker.Record(true)
+	if mode := guarded.GetCurrentFilterMode(); mode != models.FilterModeHousehold {
+		t.Errorf("Expected the last known household mode while open, got %s", mode)
+	}
+}
+
+func TestStore_GetAnomaliesInRange_LimitsAfterFiltering(t *testing.T) {
+	s := NewStore(10)
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for i, at := range []time.Time{start, start.Add(time.Hour), end} {
+		mode := models.FilterModeDrinking
+		if i == 1 {
+			mode = models.FilterModeHousehold
+		}
+		s.SaveAnomaly(&models.AnomalyDetection{DeviceID: "stm32_post", DetectedAt: at, FilterMode: mode})
+	}
+	// Newer anomalies outside the range must not crowd out the ones inside it
+	for i := 0; i < 5; i++ {
+		s.SaveAnomaly(&models.AnomalyDetection{DeviceID: "stm32_post", DetectedAt: end.Add(time.Duration(i+1) * time.Hour), FilterMode: models.FilterModeDrinking})
+	}
+
+	if anomalies, _ := s.GetAnomaliesInRange(start, end, "", 3); len(anomalies) != 3 || !anomalies[0].DetectedAt.Equal(end) {
+		t.Errorf("Expected the 3 anomalies in range, newest first, got %+v", anomalies)
+	}
+	if anomalies, _ := s.GetAnomaliesInRange(start, end, models.FilterModeDrinking, 3); len(anomalies) != 2 {
+		t.Errorf("Expected the 2 drinking water anomalies in range, got %+v", anomalies)
+	}
+}
+
+func TestStore_GetFilterHealthInRange_LimitsAfterFiltering(t *testing.T) {
+	s := NewStore(10)
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for i, at := range []time.Time{start, start.Add(time.Hour), end} {
+		mode := models.FilterModeDrinking
+		if i == 1 {
+			mode = models.FilterModeHousehold
+		}
+		s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", LastCalculated: at, FilterMode: mode})
+	}
+	s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_pre", LastCalculated: start, FilterMode: models.FilterModeDrinking})
+	// Newer records outside the range must not crowd out the ones inside it
+	for i := 0; i < 5; i++ {
+		s.SaveFilterHealth(&models.FilterHealth{DeviceID: "stm32_post", LastCalculated: end.Add(time.Duration(i+1) * time.Hour), FilterMode: models.FilterModeDrinking})
+	}
+
+	if history, _ := s.GetFilterHealthInRange("stm32_post", start, end, "", 3); len(history) != 3 || !history[0].LastCalculated.Equal(end) {
+		t.Errorf("Expected the 3 stm32_post records in range, newest first, got %+v", history)
+	}
+	if history, _ := s.GetFilterHealthInRange("stm32_post", start, end, models.FilterModeDrinking, 3); len(history) != 2 {
+		t.Errorf("Expected the 2 drinking water records in range, got %+v", history)
+	}
+}
+
+func TestStore_DeviceCommandLogIsBoundedAndUpdatable(t *testing.T) {
+	s := NewStore(10)
+	for i := 0; i < maxDeviceCommands+5; i++ {
+		s.RecordDeviceCommand(&models.DeviceCommand{DeviceID: "stm32_main", Command: models.DeviceCommandSetLED, Status: models.DeviceCommandQueued})
+	}
+
+	commands, _ := s.GetDeviceCommands("stm32_main", 0)
+	if len(commands) != maxDeviceCommands {
+		t.Fatalf("Expected the log to keep %d commands, got %d", maxDeviceCommands, len(commands))
+	}
+	if oldest := commands[len(commands)-1]; oldest.ID != 6 {
+		t.Errorf("Expected the oldest commands to be dropped first, oldest kept is %d", oldest.ID)
+	}
+
+	newest := commands[0]
+	if err := s.UpdateDeviceCommandStatus(newest.ID, models.DeviceCommandDelivered, ""); err != nil {
+		t.Fatalf("Expected the status update to succeed, got %v", err)
+	}
+	commands, _ = s.GetDeviceCommands("stm32_main", 1)
+	if commands[0].Status != models.DeviceCommandDelivered {
+		t.Errorf("Expected the command to be marked delivered, got %q", commands[0].Status)
+	}
+	if err := s.UpdateDeviceCommandStatus(1, models.DeviceCommandDelivered, ""); err == nil {
+		t.Error("Expected an error updating a command dropped from the log")
+	}
+}
+
+func TestStore_FilterModeChangeLogIsBounded(t *testing.T) {
+	s := NewStore(10)
+	for i := 0; i < maxModeChanges+3; i++ {
+		s.RecordFilterModeChange(&models.FilterModeChange{FromMode: models.FilterModeDrinking, ToMode: models.FilterModeHousehold, Reason: models.FilterModeChangeManual})
+	}
+
+	changes, _ := s.GetFilterModeChanges(0)
+	if len(changes) != maxModeChanges {
+		t.Fatalf("Expected the log to keep %d changes, got %d", maxModeChanges, len(changes))
+	}
+	if newest, oldest := changes[0], changes[len(changes)-1]; newest.ID != maxModeChanges+3 || oldest.ID != 4 {
+		t.Errorf("Expected the oldest changes to be dropped first, kept %d..%d", oldest.ID, newest.ID)
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+// MaxFlowGapMinutes is the longest gap between readings over which flow is
+// accumulated; a longer gap means the flow in between is unknown
+const MaxFlowGapMinutes = 5.0
+
+// FlowGapCounts reports whether flow at a reading's rate is accumulated over
+// the minutes since the previous reading; negative gaps and gaps over
+// MaxFlowGapMinutes aren't
+func FlowGapCounts(minutes float64) bool {
+	return minutes >= 0 && minutes <= MaxFlowGapMinutes
+}
+
+// EmptyFlowStatistics returns zeroed flow statistics for today, this week and
+// this month, matching the shape of the filter mode tracking statistics
+func EmptyFlowStatistics() map[string]interface{} {
+	period := func() map[string]interface{} {
+		return map[string]interface{}{
+			"drinking_water_liters":  0,
+			"household_water_liters": 0,
+			"total_liters":           0,
+		}
+	}
+	return map[string]interface{}{
+		"today":      period(),
+		"this_week":  period(),
+		"this_month": period(),
+	}
+}
+
+// EmptyFilterModeTracking returns a well-formed tracking object for when no
+// filter mode has been tracked yet, so clients never receive null
+func EmptyFilterModeTracking() map[string]interface{} {
+	return map[string]interface{}{
+		"started_at":        nil,
+		"duration_seconds":  0,
+		"total_flow_liters": 0,
+		"statistics":        EmptyFlowStatistics(),
+	}
+}
@@ -2,28 +2,84 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/Capstone-E1/aquasmart_backend/internal/models"
 )
 
+const (
+	// DefaultPingInterval is how often clients are pinged unless SetPingInterval says otherwise
+	DefaultPingInterval = 30 * time.Second
+	// writeWait is how long a single write to a client may take
+	writeWait = 10 * time.Second
+)
+
 // Client represents a WebSocket client connection
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	deviceID string // Optional: filter for specific device
+	hub           *Hub
+	conn          *websocket.Conn
+	send          chan []byte
+	deviceID      string          // Device the client follows ("" = all); only touched by the hub once running
+	subscriptions map[string]bool // Message types the client subscribed to (none = all); only touched by the hub
+}
+
+// wants reports whether the client's filters let the message through. Messages
+// that aren't about a single device reach clients following any device.
+func (c *Client) wants(message outboundMessage) bool {
+	if len(c.subscriptions) > 0 && !c.subscriptions[message.messageType] {
+		return false
+	}
+	return c.deviceID == "" || message.deviceID == "" || message.deviceID == c.deviceID
+}
+
+// FiltrationProcessProvider supplies the filtration state sent to clients that
+// subscribe to filtration progress
+type FiltrationProcessProvider interface {
+	GetFiltrationProcess() (*models.FiltrationProcess, bool)
+	GetCurrentFilterMode() models.FilterMode
 }
 
 // Hub maintains active WebSocket connections and broadcasts messages
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
+	clients      map[*Client]bool
+	broadcast    chan outboundMessage
+	register     chan *Client
+	unregister   chan *Client
+	subscribe    chan subscription
+	filtration   FiltrationProcessProvider // Source of the filtration progress snapshot (nil = none)
+	upgrader     websocket.Upgrader
+	pingInterval time.Duration // How often clients are pinged; a client silent for two intervals is dropped
+	connected    atomic.Int64  // len(clients), readable outside Run
+}
+
+// outboundMessage is an encoded message queued for broadcast, with what the
+// clients' filters match it on
+type outboundMessage struct {
+	messageType string
+	deviceID    string // Device the message is about ("" = not device specific)
+	data        []byte
+}
+
+// subscription is a client's request to receive the given message types,
+// optionally only about one device
+type subscription struct {
+	client   *Client
+	types    []string
+	deviceID string
+}
+
+// subscribeMessage is sent by clients to subscribe to message types, e.g.
+// {"subscribe":["filtration_progress","anomaly"],"device_id":"stm32_pre"}.
+// Until a client subscribes it receives every message.
+type subscribeMessage struct {
+	Subscribe []string `json:"subscribe"`
+	DeviceID  string   `json:"device_id"`
 }
 
 // Message represents a WebSocket message structure
@@ -47,11 +103,43 @@ var upgrader = websocket.Upgrader{
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		clients:      make(map[*Client]bool),
+		broadcast:    make(chan outboundMessage, 256),
+		register:     make(chan *Client),
+		unregister:   make(chan *Client),
+		subscribe:    make(chan subscription),
+		upgrader:     upgrader,
+		pingInterval: DefaultPingInterval,
+	}
+}
+
+// SetPingInterval sets how often clients are pinged. A client that sends
+// nothing, not even a pong, for two intervals is considered gone and is
+// disconnected. A non-positive interval keeps the default. Call before serving
+// connections.
+func (h *Hub) SetPingInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPingInterval
 	}
+	h.pingInterval = interval
+}
+
+// pongWait is how long a client may stay silent before it is disconnected
+func (h *Hub) pongWait() time.Duration {
+	return 2 * h.pingInterval
+}
+
+// SetCompression sets whether permessage-deflate compression is negotiated
+// with clients that support it; other clients are sent uncompressed messages.
+// Call before serving connections.
+func (h *Hub) SetCompression(enabled bool) {
+	h.upgrader.EnableCompression = enabled
+}
+
+// SetFiltrationProcessProvider sets where the hub reads the filtration state sent
+// to clients right after they subscribe to filtration progress. Call before Run.
+func (h *Hub) SetFiltrationProcessProvider(provider FiltrationProcessProvider) {
+	h.filtration = provider
 }
 
 // Run starts the WebSocket hub
@@ -84,16 +172,37 @@ func (h *Hub) Run() {
 				log.Printf("Client disconnected. Total clients: %d", len(h.clients))
 			}
 
+		case sub := <-h.subscribe:
+			if _, ok := h.clients[sub.client]; !ok {
+				continue
+			}
+			if sub.deviceID != "" {
+				sub.client.deviceID = sub.deviceID
+			}
+			if sub.client.subscriptions == nil {
+				sub.client.subscriptions = make(map[string]bool)
+			}
+			for _, messageType := range sub.types {
+				sub.client.subscriptions[messageType] = true
+				if messageType == "filtration_progress" {
+					h.sendFiltrationSnapshot(sub.client)
+				}
+			}
+
 		case message := <-h.broadcast:
 			for client := range h.clients {
+				if !client.wants(message) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
 				}
 			}
 		}
+		h.connected.Store(int64(len(h.clients)))
 	}
 }
 
@@ -112,7 +221,7 @@ func (h *Hub) BroadcastSensorReading(reading *models.SensorReading) {
 	}
 
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: reading.DeviceID, data: data}:
 	default:
 		log.Println("Broadcast channel is full, dropping message")
 	}
@@ -133,12 +242,76 @@ func (h *Hub) BroadcastWaterQualityStatus(status *models.WaterQualityStatus) {
 	}
 
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: status.DeviceID, data: data}:
+	default:
+		log.Println("Broadcast channel is full, dropping message")
+	}
+}
+
+// BroadcastFilterHealth broadcasts a newly recorded filter health assessment to all clients
+func (h *Hub) BroadcastFilterHealth(health *models.FilterHealth) {
+	message := Message{
+		Type:      "filter_health",
+		Timestamp: time.Now(),
+		Data:      health,
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling filter health: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: health.DeviceID, data: data}:
+	default:
+		log.Println("Broadcast channel is full, dropping message")
+	}
+}
+
+// BroadcastFilterReplacementAlert broadcasts a filter replacement alert to all clients
+func (h *Hub) BroadcastFilterReplacementAlert(alert *models.FilterReplacementAlert) {
+	message := Message{
+		Type:      "filter_replacement_alert",
+		Timestamp: time.Now(),
+		Data:      alert,
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling filter replacement alert: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: alert.DeviceID, data: data}:
 	default:
 		log.Println("Broadcast channel is full, dropping message")
 	}
 }
 
+// BroadcastSafetyCaution broadcasts that the drinking water verdict rests on
+// stale data, or that fresh data arrived again
+func (h *Hub) BroadcastSafetyCaution(caution *models.SafetyCaution) {
+	message := Message{
+		Type:      "safety_caution",
+		Timestamp: time.Now(),
+		Data:      caution,
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling safety caution: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: caution.DeviceID, data: data}:
+	default:
+		log.Println("Broadcast channel is full, dropping safety caution message")
+	}
+}
+
 // BroadcastError broadcasts error messages to all clients
 func (h *Hub) BroadcastError(errorMsg string) {
 	message := Message{
@@ -154,7 +327,7 @@ func (h *Hub) BroadcastError(errorMsg string) {
 	}
 
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: "", data: data}:
 	default:
 		log.Println("Broadcast channel is full, dropping message")
 	}
@@ -162,6 +335,51 @@ func (h *Hub) BroadcastError(errorMsg string) {
 
 // BroadcastFiltrationProgress broadcasts filtration process progress to all connected clients
 func (h *Hub) BroadcastFiltrationProgress(process *models.FiltrationProcess) {
+	data, err := filtrationProgressMessage(process)
+	if err != nil {
+		log.Printf("Error marshaling filtration progress: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- outboundMessage{messageType: "filtration_progress", deviceID: "", data: data}:
+	default:
+		log.Println("Broadcast channel is full, dropping filtration progress message")
+	}
+}
+
+// sendFiltrationSnapshot sends the current filtration state to a single client
+// so progress displays are correct without waiting for the next update. Without
+// an active process an idle state is sent. Only called from Run.
+func (h *Hub) sendFiltrationSnapshot(client *Client) {
+	if h.filtration == nil {
+		return
+	}
+
+	process, exists := h.filtration.GetFiltrationProcess()
+	if !exists || process == nil {
+		process = &models.FiltrationProcess{
+			State:       models.FiltrationStateIdle,
+			CurrentMode: h.filtration.GetCurrentFilterMode(),
+		}
+	}
+
+	data, err := filtrationProgressMessage(process)
+	if err != nil {
+		log.Printf("Error marshaling filtration snapshot: %v", err)
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
+// filtrationProgressMessage builds the filtration_progress message for a process
+func filtrationProgressMessage(process *models.FiltrationProcess) ([]byte, error) {
 	// Create detailed progress data for frontend
 	progressData := map[string]interface{}{
 		"state":                process.State,
@@ -182,17 +400,7 @@ func (h *Hub) BroadcastFiltrationProgress(process *models.FiltrationProcess) {
 		Data:      progressData,
 	}
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling filtration progress: %v", err)
-		return
-	}
-
-	select {
-	case h.broadcast <- data:
-	default:
-		log.Println("Broadcast channel is full, dropping filtration progress message")
-	}
+	return json.Marshal(message)
 }
 
 // BroadcastModeChangeBlocked broadcasts when a mode change is blocked due to active filtration
@@ -217,7 +425,7 @@ func (h *Hub) BroadcastModeChangeBlocked(reason string, process *models.Filtrati
 	}
 
 	select {
-	case h.broadcast <- data:
+	case h.broadcast <- outboundMessage{messageType: message.Type, deviceID: "", data: data}:
 	default:
 		log.Println("Broadcast channel is full, dropping mode change blocked message")
 	}
@@ -225,12 +433,12 @@ func (h *Hub) BroadcastModeChangeBlocked(reason string, process *models.Filtrati
 
 // GetConnectedClientsCount returns the number of connected clients
 func (h *Hub) GetConnectedClientsCount() int {
-	return len(h.clients)
+	return int(h.connected.Load())
 }
 
 // HandleWebSocket handles WebSocket connection requests
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
@@ -260,30 +468,40 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	// Set read deadline and pong handler
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	// Every pong or message keeps the client alive for another pongWait
+	pongWait := c.hub.pongWait()
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("WebSocket client stopped responding for %s, disconnecting", pongWait)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 
-		// Handle incoming messages from clients (e.g., device filter requests)
+		// Handle incoming messages from clients (e.g., subscriptions)
+		var request subscribeMessage
+		if err := json.Unmarshal(message, &request); err == nil && (len(request.Subscribe) > 0 || request.DeviceID != "") {
+			c.hub.subscribe <- subscription{client: c, types: request.Subscribe, deviceID: request.DeviceID}
+			continue
+		}
 		log.Printf("Received message from client: %s", message)
 	}
 }
 
 // writePump handles writing messages to the WebSocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(c.hub.pingInterval)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -292,7 +510,7 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
@@ -316,7 +534,7 @@ func (c *Client) writePump() {
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
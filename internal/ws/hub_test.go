@@ -0,0 +1,264 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Capstone-E1/aquasmart_backend/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// fakeFiltration is a FiltrationProcessProvider with a fixed process
+type fakeFiltration struct {
+	process *models.FiltrationProcess
+}
+
+func (f *fakeFiltration) GetFiltrationProcess() (*models.FiltrationProcess, bool) {
+	return f.process, f.process != nil
+}
+
+func (f *fakeFiltration) GetCurrentFilterMode() models.FilterMode {
+	return models.FilterModeHousehold
+}
+
+// dialHub starts a hub behind a test server and connects a client to it
+func dialHub(t *testing.T, provider FiltrationProcessProvider) *websocket.Conn {
+	t.Helper()
+	_, url := startHub(t, provider)
+	return dial(t, url)
+}
+
+// startHub runs a hub behind a test server and returns it with its WebSocket URL
+func startHub(t *testing.T, provider FiltrationProcessProvider) (*Hub, string) {
+	t.Helper()
+	return startConfiguredHub(t, provider, false)
+}
+
+// startConfiguredHub is startHub with permessage-deflate negotiation set
+func startConfiguredHub(t *testing.T, provider FiltrationProcessProvider, compression bool) (*Hub, string) {
+	t.Helper()
+	hub := NewHub()
+	hub.SetFiltrationProcessProvider(provider)
+	hub.SetCompression(compression)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dial connects a client to the hub at url
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readMessage reads the next message from the hub
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message Message
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return message
+}
+
+func TestHub_FiltrationProgressSnapshotOnSubscribe(t *testing.T) {
+	process := &models.FiltrationProcess{
+		State:           models.FiltrationStateProcessing,
+		CurrentMode:     models.FilterModeDrinking,
+		TargetVolume:    5,
+		ProcessedVolume: 2,
+		Progress:        40,
+	}
+
+	tests := []struct {
+		name      string
+		process   *models.FiltrationProcess
+		wantState string
+		wantMode  string
+	}{
+		{"active process", process, string(models.FiltrationStateProcessing), string(models.FilterModeDrinking)},
+		{"no process", nil, string(models.FiltrationStateIdle), string(models.FilterModeHousehold)},
+	}
+
+	for _, tt := range tests {
+		conn := dialHub(t, &fakeFiltration{process: tt.process})
+		if message := readMessage(t, conn); message.Type != "connected" {
+			t.Fatalf("%s: expected the welcome message first, got %s", tt.name, message.Type)
+		}
+
+		if err := conn.WriteJSON(map[string]interface{}{"subscribe": []string{"filtration_progress"}}); err != nil {
+			t.Fatalf("%s: failed to subscribe: %v", tt.name, err)
+		}
+
+		message := readMessage(t, conn)
+		if message.Type != "filtration_progress" {
+			t.Fatalf("%s: expected an immediate filtration_progress snapshot, got %s", tt.name, message.Type)
+		}
+		data := message.Data.(map[string]interface{})
+		if data["state"] != tt.wantState || data["current_mode"] != tt.wantMode {
+			t.Errorf("%s: expected %s in %s, got %v", tt.name, tt.wantState, tt.wantMode, data)
+		}
+		if tt.process != nil && data["progress"] != 40.0 {
+			t.Errorf("%s: expected the process progress, got %v", tt.name, data["progress"])
+		}
+	}
+}
+
+// readMessageTypes reads messages until one of type last arrives and returns
+// the type and device of each; queued messages may share a frame
+func readMessageTypes(t *testing.T, conn *websocket.Conn, last string) []string {
+	t.Helper()
+	var received []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message after %v: %v", received, err)
+		}
+		for _, line := range strings.Split(string(frame), "\n") {
+			var message struct {
+				Type string `json:"type"`
+				Data struct {
+					DeviceID string `json:"device_id"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(line), &message); err != nil {
+				t.Fatalf("Failed to decode message %q: %v", line, err)
+			}
+			received = append(received, strings.TrimSuffix(message.Type+" "+message.Data.DeviceID, " "))
+			if message.Type == last {
+				return received
+			}
+		}
+	}
+}
+
+func TestHub_ForwardsOnlySubscribedTypesAndDevice(t *testing.T) {
+	hub, url := startHub(t, &fakeFiltration{})
+	filtered := dial(t, url)
+	everything := dial(t, url)
+	readMessage(t, filtered)
+	readMessage(t, everything)
+
+	if err := filtered.WriteJSON(map[string]interface{}{
+		"subscribe": []string{"sensor_reading", "filtration_progress"},
+		"device_id": "stm32_pre",
+	}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	// The snapshot confirms the subscription was applied
+	if message := readMessage(t, filtered); message.Type != "filtration_progress" {
+		t.Fatalf("Expected the filtration snapshot, got %s", message.Type)
+	}
+
+	hub.BroadcastSensorReading(&models.SensorReading{DeviceID: "stm32_post"})
+	hub.BroadcastSensorReading(&models.SensorReading{DeviceID: "stm32_pre"})
+	hub.BroadcastWaterQualityStatus(&models.WaterQualityStatus{DeviceID: "stm32_pre"})
+	hub.BroadcastFiltrationProgress(&models.FiltrationProcess{State: models.FiltrationStateProcessing})
+
+	got := readMessageTypes(t, filtered, "filtration_progress")
+	if want := []string{"sensor_reading stm32_pre", "filtration_progress"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the subscribed client to get %v, got %v", want, got)
+	}
+
+	got = readMessageTypes(t, everything, "filtration_progress")
+	want := []string{"sensor_reading stm32_post", "sensor_reading stm32_pre", "water_quality_status stm32_pre", "filtration_progress"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected a client without a subscription to get everything %v, got %v", want, got)
+	}
+}
+
+func TestHub_NegotiatesCompressionWhenEnabled(t *testing.T) {
+	dialer := websocket.Dialer{EnableCompression: true}
+	reading := &models.SensorReading{DeviceID: "stm32_pre", Ph: 7.2, TDS: 150}
+
+	for _, enabled := range []bool{true, false} {
+		hub, url := startConfiguredHub(t, nil, enabled)
+		conn, resp, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+
+		negotiated := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		if negotiated != enabled {
+			t.Errorf("Expected compression negotiated=%v, got extensions %q", enabled, resp.Header.Get("Sec-WebSocket-Extensions"))
+		}
+
+		// Messages decode the same either way
+		if message := readMessage(t, conn); message.Type != "connected" {
+			t.Fatalf("Expected the welcome message, got %s", message.Type)
+		}
+		hub.BroadcastSensorReading(reading)
+		message := readMessage(t, conn)
+		data, _ := message.Data.(map[string]interface{})
+		if message.Type != "sensor_reading" || data["device_id"] != "stm32_pre" || data["tds"] != 150.0 {
+			t.Errorf("Expected the sensor reading to decode with compression=%v, got %+v", enabled, message)
+		}
+	}
+
+	// Clients that don't support compression still connect to a compressing hub
+	_, url := startConfiguredHub(t, nil, true)
+	conn := dial(t, url)
+	if message := readMessage(t, conn); message.Type != "connected" {
+		t.Errorf("Expected a plain client to get the welcome message, got %s", message.Type)
+	}
+}
+
+func TestHub_DisconnectsClientsThatStopAnsweringPings(t *testing.T) {
+	hub := NewHub()
+	hub.SetPingInterval(50 * time.Millisecond)
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Pongs are only sent while the client reads, so one that never reads has gone silent
+	silent := dial(t, url)
+	responsive := dial(t, url)
+	go func() {
+		for {
+			if _, _, err := responsive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.GetConnectedClientsCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected only the responsive client to stay connected, got %d clients", hub.GetConnectedClientsCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The silent client's connection was closed by the server
+	silent.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := silent.ReadMessage(); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				t.Fatal("Expected the server to close the silent client's connection")
+			}
+			break
+		}
+	}
+
+	// The responsive client outlives several ping intervals
+	time.Sleep(300 * time.Millisecond)
+	if count := hub.GetConnectedClientsCount(); count != 1 {
+		t.Errorf("Expected the responsive client to stay connected, got %d clients", count)
+	}
+}
@@ -0,0 +1,8 @@
+-- Migration to allow overriding the inferred device type per device
+-- NULL means the type is inferred from the device ID (e.g. "stm32_pre" -> pre_filtration)
+
+ALTER TABLE device_status
+ADD COLUMN IF NOT EXISTS device_type VARCHAR(50)
+    CHECK (device_type IN ('pre_filtration', 'post_filtration', 'unknown'));
+
+COMMENT ON COLUMN device_status.device_type IS 'Optional override of the pre/post filtration classification inferred from device_id';
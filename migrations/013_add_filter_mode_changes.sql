@@ -0,0 +1,16 @@
+-- Migration 013: Filter mode change audit log
+-- Records every filter mode change together with what triggered it
+
+CREATE TABLE IF NOT EXISTS filter_mode_changes (
+    id SERIAL PRIMARY KEY,
+    from_mode VARCHAR(50) NOT NULL,
+    to_mode VARCHAR(50) NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    details TEXT,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_filter_mode_changes_changed_at
+ON filter_mode_changes(changed_at DESC);
+
+COMMENT ON COLUMN filter_mode_changes.reason IS 'What triggered the change: manual, schedule or auto_quality';
@@ -0,0 +1,9 @@
+-- Migration 014: Record when the server received each reading
+-- Device clocks can drift; keeping the server receive time alongside the device
+-- timestamp makes skewed readings detectable. NULL for readings stored before
+-- this migration.
+
+ALTER TABLE sensor_readings
+ADD COLUMN IF NOT EXISTS server_received_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN sensor_readings.server_received_at IS 'Server time at which the reading was ingested; timestamp holds the device-reported time';
@@ -0,0 +1,8 @@
+-- Migration 015: Anomaly alert throttling
+-- Anomalies inside the throttle window are recorded without an alert; the next
+-- alert for the same device/metric carries how many were suppressed.
+
+ALTER TABLE anomaly_detections
+ADD COLUMN IF NOT EXISTS suppressed_count INTEGER NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN anomaly_detections.suppressed_count IS 'Alerts suppressed for this device/metric since the previous alert';
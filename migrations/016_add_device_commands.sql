@@ -0,0 +1,17 @@
+-- Migration 016: Device command log
+-- Records every command the backend sends to a device and whether it went out
+
+CREATE TABLE IF NOT EXISTS device_commands (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(100) NOT NULL,
+    command VARCHAR(50) NOT NULL,
+    payload TEXT,
+    status VARCHAR(20) NOT NULL,
+    error TEXT,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_commands_device_issued_at
+ON device_commands(device_id, issued_at DESC);
+
+COMMENT ON COLUMN device_commands.status IS 'Delivery state: sent (published to the broker) or failed';
@@ -0,0 +1,8 @@
+-- Migration 017: Per-device API keys for sensor ingestion
+-- Only the SHA-256 hash of a device's key is stored; the key itself is shown once when generated
+
+ALTER TABLE devices
+ADD COLUMN IF NOT EXISTS api_key_hash VARCHAR(64),
+ADD COLUMN IF NOT EXISTS api_key_created_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN devices.api_key_hash IS 'Hex SHA-256 of the key the device sends in X-Device-Key (NULL = no key provisioned)';
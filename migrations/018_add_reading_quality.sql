@@ -0,0 +1,17 @@
+-- Migration 018: Store each reading's quality assessment
+-- Stats and exports read the assessment made at ingestion instead of re-assessing
+-- every reading. quality holds the per-metric and overall statuses plus the
+-- version of the quality rules they were computed with; readings stored before
+-- this migration (NULL) or under other rules are recomputed by the backfill.
+
+ALTER TABLE sensor_readings
+ADD COLUMN IF NOT EXISTS quality JSONB;
+
+ALTER TABLE sensor_readings
+ADD COLUMN IF NOT EXISTS overall_quality VARCHAR(20)
+    GENERATED ALWAYS AS (quality->>'overall_quality') STORED;
+
+CREATE INDEX IF NOT EXISTS idx_sensor_readings_quality_rules
+ON sensor_readings ((quality->>'rules_version'));
+
+COMMENT ON COLUMN sensor_readings.quality IS 'Quality assessment at ingestion: ph_status, turbidity_status, tds_status, overall_quality, rules_version';
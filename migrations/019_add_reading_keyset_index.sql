@@ -0,0 +1,7 @@
+-- Migration 019: Index for keyset (cursor) pagination of readings
+-- Pages are fetched with WHERE (timestamp, device_id) < ($1, $2) ORDER BY
+-- timestamp, device_id; this index lets PostgreSQL seek straight to the cursor
+-- instead of skipping rows like OFFSET does.
+
+CREATE INDEX IF NOT EXISTS idx_sensor_readings_timestamp_device
+ON sensor_readings(timestamp DESC, device_id DESC);
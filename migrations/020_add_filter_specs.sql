@@ -0,0 +1,10 @@
+-- Migration 020: Installed filter metadata for usage economics
+-- Capacity and cost come from the filter's packaging; NULL capacity = not recorded
+
+ALTER TABLE devices
+ADD COLUMN IF NOT EXISTS filter_capacity_liters DECIMAL(10,2) CHECK (filter_capacity_liters > 0),
+ADD COLUMN IF NOT EXISTS filter_cost DECIMAL(10,2) CHECK (filter_cost >= 0),
+ADD COLUMN IF NOT EXISTS filter_installed_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN devices.filter_capacity_liters IS 'Rated volume the installed filter treats before replacement';
+COMMENT ON COLUMN devices.filter_cost IS 'Purchase price of the installed filter';
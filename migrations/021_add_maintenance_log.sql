@@ -0,0 +1,18 @@
+-- Migration 021: Maintenance log
+-- Records maintenance performed on each device's filter and hardware
+
+CREATE TABLE IF NOT EXISTS maintenance_log (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(100) NOT NULL,
+    performed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    notes TEXT,
+    performed_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_log_device_performed_at
+ON maintenance_log(device_id, performed_at DESC);
+
+COMMENT ON COLUMN maintenance_log.type IS 'cleaning, filter_replacement, part_replacement, inspection or other';
+COMMENT ON COLUMN maintenance_log.performed_at IS 'When the work was done; created_at is when it was logged';
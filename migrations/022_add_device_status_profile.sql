@@ -0,0 +1,9 @@
+-- Migration 022: Device name and activation
+-- Lets operators label devices and deactivate retired ones without deleting their status
+
+ALTER TABLE device_status
+ADD COLUMN IF NOT EXISTS name VARCHAR(100),
+ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT true;
+
+COMMENT ON COLUMN device_status.name IS 'Human-friendly device name shown on the dashboard';
+COMMENT ON COLUMN device_status.is_active IS 'False once the device is deactivated through the API';
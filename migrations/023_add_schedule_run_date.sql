@@ -0,0 +1,7 @@
+-- Migration 023: One-time schedules
+-- A schedule with a run date runs once on that date instead of on its days of the week
+
+ALTER TABLE filter_schedules
+ADD COLUMN IF NOT EXISTS run_date DATE;
+
+COMMENT ON COLUMN filter_schedules.run_date IS 'Date (in the schedule timezone) a one-time schedule runs on; NULL for weekly schedules';
@@ -0,0 +1,5 @@
+-- Migration 024: Recently resolved anomalies
+-- The ML dashboard lists the latest resolved anomalies by when they were resolved
+
+CREATE INDEX IF NOT EXISTS idx_anomaly_resolved_at ON anomaly_detections(resolved_at DESC)
+WHERE resolved_at IS NOT NULL AND is_false_positive = false;
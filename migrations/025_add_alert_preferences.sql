@@ -0,0 +1,9 @@
+-- Migration 025: Per-device anomaly alert preferences
+-- Muted metrics and severities are still detected and recorded, they just don't alert; empty = every alert on
+
+ALTER TABLE devices
+ADD COLUMN IF NOT EXISTS alert_muted_metrics TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS alert_muted_severities TEXT[] NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN devices.alert_muted_metrics IS 'Metrics (flow, ph, turbidity, tds) whose anomalies do not notify';
+COMMENT ON COLUMN devices.alert_muted_severities IS 'Severities (low, medium, high, critical) whose anomalies do not notify';
@@ -0,0 +1,10 @@
+-- Migration 026: Pairs left out of filter efficiency
+-- Pairs whose pre-filtration value is below the configured minimum are left out
+-- of that metric's reduction and efficiency; record how many per assessment.
+
+ALTER TABLE filter_health
+ADD COLUMN IF NOT EXISTS turbidity_pairs_excluded INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tds_pairs_excluded INTEGER NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN filter_health.turbidity_pairs_excluded IS 'Pairs left out because their pre-filtration turbidity was below the minimum';
+COMMENT ON COLUMN filter_health.tds_pairs_excluded IS 'Pairs left out because their pre-filtration TDS was below the minimum';
@@ -0,0 +1,4 @@
+-- Migration 027: Device command delivery states
+-- Commands are now updated once the broker acknowledges them or the device polls for them
+
+COMMENT ON COLUMN device_commands.status IS 'Delivery state: sent (publishing to the broker), delivered (acknowledged by the broker or picked up by the polling device), failed or queued (waiting for the device to poll)';